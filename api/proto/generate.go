@@ -0,0 +1,4 @@
+// Package proto contains the protobuf definitions of the runner gRPC API, and the code generated from them
+package proto
+
+//go:generate buf generate
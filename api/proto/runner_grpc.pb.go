@@ -0,0 +1,249 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: runner.proto
+
+package proto
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// RunnerClient is the client API for Runner service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type RunnerClient interface {
+	// StartExecution schedules a new checks execution in the given cluster hosts
+	StartExecution(ctx context.Context, in *StartExecutionRequest, opts ...grpc.CallOption) (*StartExecutionResponse, error)
+	// GetExecutionStatus returns the status of an execution from the executions history
+	GetExecutionStatus(ctx context.Context, in *GetExecutionStatusRequest, opts ...grpc.CallOption) (*ExecutionStatus, error)
+	// StreamExecutionEvents sends the executions events as they happen, until the client leaves
+	StreamExecutionEvents(ctx context.Context, in *StreamExecutionEventsRequest, opts ...grpc.CallOption) (Runner_StreamExecutionEventsClient, error)
+	// BuildCatalog builds the checks catalog again and returns it
+	BuildCatalog(ctx context.Context, in *BuildCatalogRequest, opts ...grpc.CallOption) (*BuildCatalogResponse, error)
+}
+
+type runnerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRunnerClient(cc grpc.ClientConnInterface) RunnerClient {
+	return &runnerClient{cc}
+}
+
+func (c *runnerClient) StartExecution(ctx context.Context, in *StartExecutionRequest, opts ...grpc.CallOption) (*StartExecutionResponse, error) {
+	out := new(StartExecutionResponse)
+	err := c.cc.Invoke(ctx, "/trento.runner.v1.Runner/StartExecution", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *runnerClient) GetExecutionStatus(ctx context.Context, in *GetExecutionStatusRequest, opts ...grpc.CallOption) (*ExecutionStatus, error) {
+	out := new(ExecutionStatus)
+	err := c.cc.Invoke(ctx, "/trento.runner.v1.Runner/GetExecutionStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *runnerClient) StreamExecutionEvents(ctx context.Context, in *StreamExecutionEventsRequest, opts ...grpc.CallOption) (Runner_StreamExecutionEventsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Runner_ServiceDesc.Streams[0], "/trento.runner.v1.Runner/StreamExecutionEvents", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &runnerStreamExecutionEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Runner_StreamExecutionEventsClient interface {
+	Recv() (*ExecutionEvent, error)
+	grpc.ClientStream
+}
+
+type runnerStreamExecutionEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *runnerStreamExecutionEventsClient) Recv() (*ExecutionEvent, error) {
+	m := new(ExecutionEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *runnerClient) BuildCatalog(ctx context.Context, in *BuildCatalogRequest, opts ...grpc.CallOption) (*BuildCatalogResponse, error) {
+	out := new(BuildCatalogResponse)
+	err := c.cc.Invoke(ctx, "/trento.runner.v1.Runner/BuildCatalog", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RunnerServer is the server API for Runner service.
+// All implementations must embed UnimplementedRunnerServer
+// for forward compatibility
+type RunnerServer interface {
+	// StartExecution schedules a new checks execution in the given cluster hosts
+	StartExecution(context.Context, *StartExecutionRequest) (*StartExecutionResponse, error)
+	// GetExecutionStatus returns the status of an execution from the executions history
+	GetExecutionStatus(context.Context, *GetExecutionStatusRequest) (*ExecutionStatus, error)
+	// StreamExecutionEvents sends the executions events as they happen, until the client leaves
+	StreamExecutionEvents(*StreamExecutionEventsRequest, Runner_StreamExecutionEventsServer) error
+	// BuildCatalog builds the checks catalog again and returns it
+	BuildCatalog(context.Context, *BuildCatalogRequest) (*BuildCatalogResponse, error)
+	mustEmbedUnimplementedRunnerServer()
+}
+
+// UnimplementedRunnerServer must be embedded to have forward compatible implementations.
+type UnimplementedRunnerServer struct {
+}
+
+func (UnimplementedRunnerServer) StartExecution(context.Context, *StartExecutionRequest) (*StartExecutionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartExecution not implemented")
+}
+func (UnimplementedRunnerServer) GetExecutionStatus(context.Context, *GetExecutionStatusRequest) (*ExecutionStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetExecutionStatus not implemented")
+}
+func (UnimplementedRunnerServer) StreamExecutionEvents(*StreamExecutionEventsRequest, Runner_StreamExecutionEventsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamExecutionEvents not implemented")
+}
+func (UnimplementedRunnerServer) BuildCatalog(context.Context, *BuildCatalogRequest) (*BuildCatalogResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BuildCatalog not implemented")
+}
+func (UnimplementedRunnerServer) mustEmbedUnimplementedRunnerServer() {}
+
+// UnsafeRunnerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RunnerServer will
+// result in compilation errors.
+type UnsafeRunnerServer interface {
+	mustEmbedUnimplementedRunnerServer()
+}
+
+func RegisterRunnerServer(s grpc.ServiceRegistrar, srv RunnerServer) {
+	s.RegisterService(&Runner_ServiceDesc, srv)
+}
+
+func _Runner_StartExecution_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartExecutionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RunnerServer).StartExecution(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trento.runner.v1.Runner/StartExecution",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RunnerServer).StartExecution(ctx, req.(*StartExecutionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Runner_GetExecutionStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetExecutionStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RunnerServer).GetExecutionStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trento.runner.v1.Runner/GetExecutionStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RunnerServer).GetExecutionStatus(ctx, req.(*GetExecutionStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Runner_StreamExecutionEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamExecutionEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RunnerServer).StreamExecutionEvents(m, &runnerStreamExecutionEventsServer{stream})
+}
+
+type Runner_StreamExecutionEventsServer interface {
+	Send(*ExecutionEvent) error
+	grpc.ServerStream
+}
+
+type runnerStreamExecutionEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *runnerStreamExecutionEventsServer) Send(m *ExecutionEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Runner_BuildCatalog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BuildCatalogRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RunnerServer).BuildCatalog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trento.runner.v1.Runner/BuildCatalog",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RunnerServer).BuildCatalog(ctx, req.(*BuildCatalogRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Runner_ServiceDesc is the grpc.ServiceDesc for Runner service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Runner_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "trento.runner.v1.Runner",
+	HandlerType: (*RunnerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "StartExecution",
+			Handler:    _Runner_StartExecution_Handler,
+		},
+		{
+			MethodName: "GetExecutionStatus",
+			Handler:    _Runner_GetExecutionStatus_Handler,
+		},
+		{
+			MethodName: "BuildCatalog",
+			Handler:    _Runner_BuildCatalog_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamExecutionEvents",
+			Handler:       _Runner_StreamExecutionEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "runner.proto",
+}
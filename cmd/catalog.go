@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/trento-project/runner/runner"
+)
+
+func addCatalogCmd(runnerCmd *cobra.Command) {
+	var ansibleFolder string
+	var customChecksDir string
+
+	catalogCmd := &cobra.Command{
+		Use:   "catalog",
+		Short: "Checks catalog related commands",
+	}
+
+	validateCmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Validates the playbooks syntax and the checks metadata, printing the problems found in json",
+		Run:   validateCatalog,
+	}
+
+	validateCmd.Flags().StringVar(&ansibleFolder, "ansible-folder", "/tmp/trento", "Folder where the ansible file structure will be created")
+	validateCmd.Flags().StringVar(&customChecksDir, "custom-checks-dir", "", "Folder with custom checks, added to the embedded ones")
+
+	var oldCatalog string
+	var newCatalog string
+
+	diffCmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Compares two catalogs, or a catalog with the one of this runner, printing the added, removed and changed checks",
+		Run: func(cmd *cobra.Command, _ []string) {
+			diffCatalogs(cmd, oldCatalog, newCatalog)
+		},
+	}
+
+	diffCmd.Flags().StringVar(&oldCatalog, "old", "", "Catalog json file to compare, e.g. the catalog.json of the previous runner version")
+	diffCmd.Flags().StringVar(&newCatalog, "new", "", "Catalog json file compared with the old one. The catalog of this runner is built and used if empty")
+	diffCmd.Flags().StringVar(&ansibleFolder, "ansible-folder", "/tmp/trento", "Folder where the ansible file structure will be created")
+	diffCmd.Flags().StringVar(&customChecksDir, "custom-checks-dir", "", "Folder with custom checks, added to the embedded ones")
+	diffCmd.MarkFlagRequired("old")
+
+	catalogCmd.AddCommand(validateCmd)
+	catalogCmd.AddCommand(diffCmd)
+	runnerCmd.AddCommand(catalogCmd)
+}
+
+func validateCatalog(cmd *cobra.Command, _ []string) {
+	report, err := runner.ValidateCatalog(context.Background(), LoadConfig())
+	if err != nil {
+		log.Fatal("Error validating the catalog: ", err)
+	}
+
+	output, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.Fatal("Error printing the report: ", err)
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), string(output))
+
+	if !report.Valid {
+		os.Exit(1)
+	}
+}
+
+// diffCatalogs prints the differences of the new catalog, or the one of this runner, from the old one.
+// It exits with 1 if they are different, as diff does
+func diffCatalogs(cmd *cobra.Command, oldFile, newFile string) {
+	oldCatalog, err := runner.LoadCatalogFile(oldFile)
+	if err != nil {
+		log.Fatal("Error reading the old catalog: ", err)
+	}
+
+	var newCatalog *runner.Catalog
+	if newFile != "" {
+		newCatalog, err = runner.LoadCatalogFile(newFile)
+	} else {
+		newCatalog, err = runner.BuildRunnerCatalog(context.Background(), LoadConfig())
+	}
+	if err != nil {
+		log.Fatal("Error reading the new catalog: ", err)
+	}
+
+	diff, err := runner.DiffCatalogs(oldCatalog, newCatalog)
+	if err != nil {
+		log.Fatal("Error comparing the catalogs: ", err)
+	}
+
+	printCatalogDiff(cmd.OutOrStdout(), diff)
+
+	if !diff.Empty() {
+		os.Exit(1)
+	}
+}
+
+func printCatalogDiff(w io.Writer, diff *runner.CatalogDiff) {
+	if diff.Empty() {
+		fmt.Fprintln(w, "The catalogs have the same checks")
+		return
+	}
+
+	for _, check := range diff.Added {
+		fmt.Fprintf(w, "+ %s %s\n", check.ID, check.Name)
+	}
+	for _, check := range diff.Removed {
+		fmt.Fprintf(w, "- %s %s\n", check.ID, check.Name)
+	}
+	for _, change := range diff.Changed {
+		fmt.Fprintf(w, "~ %s %s\n", change.ID, change.Name)
+		for _, field := range change.Fields {
+			oldValue, _ := json.Marshal(field.Old)
+			newValue, _ := json.Marshal(field.New)
+			fmt.Fprintf(w, "    %s: %s -> %s\n", field.Field, oldValue, newValue)
+		}
+	}
+
+	fmt.Fprintf(w, "%d added, %d removed, %d changed\n", len(diff.Added), len(diff.Removed), len(diff.Changed))
+}
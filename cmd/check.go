@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/trento-project/runner/runner"
+)
+
+func addCheckCmd(runnerCmd *cobra.Command) {
+	var checks []string
+	var host string
+	var user string
+	var provider string
+	var ansibleFolder string
+	var customChecksDir string
+	var sshPrivateKeyFile string
+	var checkEngine string
+	var nativeChecksDir string
+
+	checkCmd := &cobra.Command{
+		Use:   "check",
+		Short: "Checks related commands",
+	}
+
+	runCmd := &cobra.Command{
+		Use:   "run",
+		Short: "Runs the given checks in a host and prints the results, without reporting them to the Trento server",
+		Run:   runCheck,
+	}
+
+	runCmd.Flags().StringSliceVar(&checks, "check", nil, "ID of the check to run. It can be repeated (required)")
+	runCmd.Flags().StringVar(&host, "host", "", "Address of the host where the checks are run (required)")
+	runCmd.Flags().StringVar(&user, "user", "root", "User to connect to the host")
+	runCmd.Flags().StringVar(&provider, "provider", "default", "Cloud provider of the host (azure, aws, gcp, default)")
+	runCmd.Flags().StringVar(&ansibleFolder, "ansible-folder", "", "Folder where the ansible file structure will be created. A temporary folder, removed once the checks are run, is used if empty")
+	runCmd.Flags().StringVar(&customChecksDir, "custom-checks-dir", "", "Folder with custom checks, added to the embedded ones")
+	runCmd.Flags().StringVar(&sshPrivateKeyFile, "ssh-private-key-file", "", "Private key file used to connect to the host. The ansible configuration is used if empty")
+	runCmd.Flags().StringVar(&checkEngine, "check-engine", runner.AnsibleCheckEngine, "Engine used to run the checks in the host (ansible, native, simulation)")
+	runCmd.Flags().StringVar(&nativeChecksDir, "native-checks-dir", "", "Folder with the declarative checks run by the native check engine")
+
+	runCmd.MarkFlagRequired("check")
+	runCmd.MarkFlagRequired("host")
+
+	checkCmd.AddCommand(runCmd)
+	runnerCmd.AddCommand(checkCmd)
+}
+
+func runCheck(cmd *cobra.Command, _ []string) {
+	// The rest of the settings, as the ssh passphrase or the vault passwords, are taken from the environment
+	// and the config file, as in the start command
+	config := LoadConfig()
+	if config.CheckEngine == runner.NativeCheckEngine && config.NativeChecksDir == "" {
+		log.Fatal("native-checks-dir is required when the native check engine is used")
+	}
+
+	// The ansible folder and the work dir of the running runner are not shared, as the ansible files are created
+	// again, only the known hosts file is. The results are only printed, they are not posted to the Trento server
+	if config.SSHKnownHostsFile == "" {
+		config.SSHKnownHostsFile = path.Join(config.AnsibleFolder, runner.DefaultKnownHostsFile)
+	}
+	config.AnsibleFolder, _ = cmd.Flags().GetString("ansible-folder")
+	config.WorkDir = ""
+	config.CallbacksUrl = ""
+	config.Upstreams = nil
+	removeAnsibleFolder := func() {}
+	if config.AnsibleFolder == "" {
+		folder, err := ioutil.TempDir("", "trento-check-")
+		if err != nil {
+			log.Fatal("Failed to create the ansible folder: ", err)
+		}
+		config.AnsibleFolder = folder
+		removeAnsibleFolder = func() {
+			if err := os.RemoveAll(folder); err != nil {
+				log.Warnf("Error removing the ansible folder %s: %s", folder, err)
+			}
+		}
+	}
+
+	cleanupSecrets, err := runner.LoadSecrets(config)
+	if err != nil {
+		removeAnsibleFolder()
+		log.Fatal("Failed to load the secrets: ", err)
+	}
+
+	checks, _ := cmd.Flags().GetStringSlice("check")
+	address, _ := cmd.Flags().GetString("host")
+	user, _ := cmd.Flags().GetString("user")
+	provider, _ := cmd.Flags().GetString("provider")
+
+	host := &runner.Host{Address: address, User: user}
+	results, err := runner.RunAdHocChecks(context.Background(), config, host, provider, checks)
+	// The secrets files are not needed once the checks are run, and os.Exit does not run the deferred calls
+	cleanupSecrets()
+	removeAnsibleFolder()
+	if err != nil {
+		log.Fatal("Error running the checks: ", err)
+	}
+
+	output, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		log.Fatal("Error printing the results: ", err)
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), string(output))
+
+	if !results.Reachable {
+		os.Exit(1)
+	}
+}
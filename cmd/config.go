@@ -1,15 +1,905 @@
 package cmd
 
 import (
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+	"github.com/trento-project/runner/internal"
 	"github.com/trento-project/runner/runner"
 )
 
 func LoadConfig() *runner.Config {
 	return &runner.Config{
-		Host:          viper.GetString("host"),
-		Port:          viper.GetInt("port"),
-		CallbacksUrl:  viper.GetString("callbacks-url"),
-		AnsibleFolder: viper.GetString("ansible-folder"),
+		Host:                viper.GetString("host"),
+		Port:                viper.GetInt("port"),
+		GrpcPort:            viper.GetInt("grpc-port"),
+		CallbacksUrl:        viper.GetString("callbacks-url"),
+		AnsibleFolder:       viper.GetString("ansible-folder"),
+		MaxParallelClusters: viper.GetInt64("max-parallel-clusters"),
+		ExecutionQueueSize:  viper.GetInt("execution-queue-size"),
+		ShutdownGracePeriod: viper.GetDuration("shutdown-grace-period"),
+		AmqpUrl:             viper.GetString("amqp-url"),
+		AmqpExchange:        viper.GetString("amqp-exchange"),
+		AmqpQueue:           viper.GetString("amqp-queue"),
+		AmqpReplyExchange:   viper.GetString("amqp-reply-exchange"),
+		ExecutionsDatabase:  viper.GetString("executions-db"),
+		TaskOutputMaxSize:   int64(viper.GetSizeInBytes("task-output-max-size")),
+		PartialResults:      viper.GetBool("partial-results"),
+		CustomChecksDir:     viper.GetString("custom-checks-dir"),
+		ShredInventories:    viper.GetBool("shred-inventories"),
+		SSHPrivateKeyFile:   viper.GetString("ssh-private-key-file"),
+		SSHPassphrase:       viper.GetString("ssh-passphrase"),
+		SSHPassphraseFile:   viper.GetString("ssh-passphrase-file"),
+		SSHAgentForwarding:  viper.GetBool("ssh-agent-forwarding"),
+		SSHBastion:          viper.GetString("ssh-bastion"),
+		SSHHostKeyChecking:  viper.GetString("ssh-host-key-checking"),
+		SSHKnownHostsFile:   viper.GetString("ssh-known-hosts-file"),
+		VaultPasswordFile:   viper.GetString("vault-password-file"),
+		VaultIDs:            getStringList("vault-id"),
+		CloudInventory:      viper.GetBool("cloud-inventory"),
+		DNSOverrides:        getDNSOverrides(),
+		ResultsCacheTTL:     viper.GetDuration("results-cache-ttl"),
+		ReportChangesOnly:   viper.GetBool("report-changes-only"),
+		FullResyncInterval:  viper.GetDuration("full-resync-interval"),
+		CheckEngine:         viper.GetString("check-engine"),
+		NativeChecksDir:     viper.GetString("native-checks-dir"),
+		SimulationResults:   getSimulationResults(),
+		SimulationLatency:   viper.GetDuration("simulation-latency"),
+		SimulationSeed:      viper.GetInt64("simulation-seed"),
+		Schedules:           viper.GetString("schedules"),
+		ScheduleOverlap:     viper.GetString("schedule-overlap"),
+		ExecutionTimeout:    viper.GetDuration("execution-timeout"),
+		TaskTimeout:         viper.GetDuration("task-timeout"),
+		CheckTimeout:        viper.GetDuration("check-timeout"),
+		CheckTimeouts:       getCheckTimeouts(),
+		CheckSeverities:     getCheckSeverities(),
+		CriticalThreshold:   viper.GetInt("critical-threshold"),
+		CriticalThresholds:  getCriticalThresholds(),
+		PreflightTimeout:    viper.GetDuration("preflight-timeout"),
+		HostRetries:         viper.GetInt("host-retries"),
+		RollingBatchSize:    viper.GetInt("rolling-batch-size"),
+		DryRun:              viper.GetBool("dry-run"),
+		DryRunDir:           viper.GetString("dry-run-dir"),
+		DryRunRetention:     viper.GetDuration("dry-run-retention"),
+		TracingEndpoint:     viper.GetString("tracing-endpoint"),
+		DebugServer:         viper.GetString("debug-server"),
+		ResultsDir:          viper.GetString("results-dir"),
+		ResultsFormats:      getStringList("results-format"),
+
+		ExecutionChunkSize:        viper.GetInt("execution-chunk-size"),
+		ExecutionChunkParallelism: viper.GetInt("execution-chunk-parallelism"),
+
+		MaintenanceWindows: viper.GetString("maintenance-windows"),
+
+		ResultsSigningKey:   viper.GetString("results-signing-key"),
+		ResultsSigningKeyID: viper.GetString("results-signing-key-id"),
+
+		WorkDir:                viper.GetString("work-dir"),
+		FailedWorkDirRetention: viper.GetDuration("failed-work-dir-retention"),
+
+		ExecutionLogsDir:     viper.GetString("execution-logs-dir"),
+		ExecutionLogsMaxSize: int64(viper.GetSizeInBytes("execution-logs-max-size")),
+		ExecutionLogsMaxAge:  viper.GetDuration("execution-logs-max-age"),
+
+		CatalogGitUrl:    viper.GetString("catalog-git-url"),
+		CatalogGitRef:    viper.GetString("catalog-git-ref"),
+		CatalogGitPath:   viper.GetString("catalog-git-path"),
+		CatalogGitVerify: viper.GetBool("catalog-git-verify"),
+
+		CallbacksRateLimit: viper.GetFloat64("callbacks-rate-limit"),
+		CallbacksBatchSize: viper.GetInt("callbacks-batch-size"),
+
+		ExecutionPreemption: viper.GetBool("execution-preemption"),
+
+		PreExecutionHooks:     getStringList("pre-execution-hook"),
+		PostExecutionHooks:    getStringList("post-execution-hook"),
+		ExecutionHooksAbort:   viper.GetBool("execution-hooks-abort"),
+		ExecutionHooksTimeout: viper.GetDuration("execution-hooks-timeout"),
+
+		Mode: viper.GetString("mode"),
+
+		WebhookUrls:           getStringList("webhook-url"),
+		WebhookSecret:         viper.GetString("webhook-secret"),
+		WebhookDeadLetterFile: viper.GetString("webhook-dead-letter-file"),
+
+		SecretsProvider:      viper.GetString("secrets-provider"),
+		SecretsDir:           viper.GetString("secrets-dir"),
+		SecretsVaultAddr:     viper.GetString("secrets-vault-addr"),
+		SecretsVaultRoleID:   viper.GetString("secrets-vault-role-id"),
+		SecretsVaultSecretID: viper.GetString("secrets-vault-secret-id"),
+		SecretsVaultPath:     viper.GetString("secrets-vault-path"),
+		HostSecretsPrefix:    viper.GetString("host-secrets-prefix"),
+
+		AnsibleForks:               viper.GetInt("ansible-forks"),
+		AnsibleDisablePipelining:   viper.GetBool("ansible-disable-pipelining"),
+		AnsibleControlPersist:      viper.GetDuration("ansible-control-persist"),
+		AnsibleGatherSubset:        viper.GetString("ansible-gather-subset"),
+		AnsibleContainerImage:      viper.GetString("ansible-container-image"),
+		AnsibleContainerRuntime:    viper.GetString("ansible-container-runtime"),
+		AnsibleMinVersion:          viper.GetString("ansible-min-version"),
+		AnsibleRequirementsFile:    viper.GetString("ansible-requirements-file"),
+		AnsibleInstallRequirements: viper.GetBool("ansible-install-requirements"),
+		AnsibleGalaxyServer:        viper.GetString("ansible-galaxy-server"),
+		AnsibleCgroup:              viper.GetString("ansible-cgroup"),
+		AnsibleCPULimit:            viper.GetFloat64("ansible-cpu-limit"),
+		AnsibleMemoryLimit:         int64(viper.GetSizeInBytes("ansible-memory-limit")),
+
+		AnsibleFactCache:              viper.GetString("ansible-fact-cache"),
+		AnsibleFactCacheTTL:           viper.GetDuration("ansible-fact-cache-ttl"),
+		AnsibleFactCacheRedis:         viper.GetString("ansible-fact-cache-redis"),
+		AnsibleFactCacheRedisPassword: viper.GetString("ansible-fact-cache-redis-password"),
+
+		AnsibleLocalTemp:      viper.GetString("ansible-local-temp"),
+		AnsibleControlPathDir: viper.GetString("ansible-control-path-dir"),
+		AnsibleFactCacheDir:   viper.GetString("ansible-fact-cache-dir"),
+
+		Proxy:   viper.GetString("proxy"),
+		NoProxy: viper.GetString("no-proxy"),
+
+		ServerCAFile:             viper.GetString("server-ca-file"),
+		ServerCertFile:           viper.GetString("server-cert-file"),
+		ServerKeyFile:            viper.GetString("server-key-file"),
+		ServerInsecureSkipVerify: viper.GetBool("server-insecure-skip-verify"),
+		ServerTLSReloadInterval:  viper.GetDuration("server-tls-reload-interval"),
+		ServerToken:              viper.GetString("server-token"),
+		ServerTokenFile:          viper.GetString("server-token-file"),
+		ServerApiKey:             viper.GetString("server-api-key"),
+		ServerApiKeyFile:         viper.GetString("server-api-key-file"),
+		ServerAuthUrl:            viper.GetString("server-auth-url"),
+		ServerHandshakeUrl:       viper.GetString("server-handshake-url"),
+		ServerSubscriptionUrl:    viper.GetString("server-subscription-url"),
+		ServerRegistrationUrl:    viper.GetString("server-registration-url"),
+		RunnerID:                 viper.GetString("runner-id"),
+		HeartbeatInterval:        viper.GetDuration("heartbeat-interval"),
+		ServerSummaryUrl:         viper.GetString("server-summary-url"),
+
+		Upstreams: getUpstreams(),
+		Sinks:     getSinks(),
+
+		CheckVariables:     getCheckVariables(),
+		InventoryVariables: getInventoryVariables(),
+	}
+}
+
+// upstreamSettings are the settings of an upstream Trento server. The upstreams are only accepted
+// in the config file, as a list
+type upstreamSettings struct {
+	Name             string        `mapstructure:"name"`
+	CallbacksUrl     string        `mapstructure:"callbacks-url"`
+	CatalogUrl       string        `mapstructure:"catalog-url"`
+	CatalogInterval  time.Duration `mapstructure:"catalog-interval"`
+	SubscriptionUrl  string        `mapstructure:"subscription-url"`
+	ServerToken      string        `mapstructure:"server-token"`
+	ServerTokenFile  string        `mapstructure:"server-token-file"`
+	ServerApiKey     string        `mapstructure:"server-api-key"`
+	ServerApiKeyFile string        `mapstructure:"server-api-key-file"`
+	ServerAuthUrl    string        `mapstructure:"server-auth-url"`
+}
+
+func getUpstreams() []*runner.Upstream {
+	var settings []*upstreamSettings
+	if err := viper.UnmarshalKey("upstreams", &settings); err != nil {
+		log.Fatal("Invalid upstreams configuration: ", err)
+	}
+
+	var upstreams []*runner.Upstream
+	for _, upstream := range settings {
+		upstreams = append(upstreams, &runner.Upstream{
+			Name:             upstream.Name,
+			CallbacksUrl:     upstream.CallbacksUrl,
+			CatalogUrl:       upstream.CatalogUrl,
+			CatalogInterval:  upstream.CatalogInterval,
+			SubscriptionUrl:  upstream.SubscriptionUrl,
+			ServerToken:      upstream.ServerToken,
+			ServerTokenFile:  upstream.ServerTokenFile,
+			ServerApiKey:     upstream.ServerApiKey,
+			ServerApiKeyFile: upstream.ServerApiKeyFile,
+			ServerAuthUrl:    upstream.ServerAuthUrl,
+		})
+	}
+
+	return upstreams
+}
+
+// sinkSettings are the settings of an execution sink. The sinks are only accepted in the config file, as a list
+type sinkSettings struct {
+	Type    string `mapstructure:"type"`
+	Url     string `mapstructure:"url"`
+	Path    string `mapstructure:"path"`
+	Network string `mapstructure:"network"`
+	Address string `mapstructure:"address"`
+	Tag     string `mapstructure:"tag"`
+}
+
+func getSinks() []*runner.Sink {
+	var settings []*sinkSettings
+	if err := viper.UnmarshalKey("sinks", &settings); err != nil {
+		log.Fatal("Invalid sinks configuration: ", err)
+	}
+
+	var sinks []*runner.Sink
+	for _, sink := range settings {
+		sinks = append(sinks, &runner.Sink{
+			Type:    sink.Type,
+			Url:     sink.Url,
+			Path:    sink.Path,
+			Network: sink.Network,
+			Address: sink.Address,
+			Tag:     sink.Tag,
+		})
+	}
+
+	return sinks
+}
+
+// getCheckTimeouts returns the timeouts of the checks tasks by check id. They are only accepted
+// in the config file, as a map. The ids are upper cased again, as viper lower cases the keys
+func getCheckTimeouts() map[string]time.Duration {
+	var settings map[string]time.Duration
+	if err := viper.UnmarshalKey("check-timeouts", &settings); err != nil {
+		log.Fatal("Invalid check timeouts configuration: ", err)
+	}
+
+	if len(settings) == 0 {
+		return nil
+	}
+
+	checkTimeouts := make(map[string]time.Duration, len(settings))
+	for checkID, timeout := range settings {
+		checkTimeouts[strings.ToUpper(checkID)] = timeout
+	}
+
+	return checkTimeouts
+}
+
+// getCheckSeverities returns the severities of the failing checks by check id, overriding the catalog ones.
+// They are only accepted in the configuration file
+func getCheckSeverities() map[string]string {
+	settings := viper.GetStringMapString("check-severities")
+	if len(settings) == 0 {
+		return nil
+	}
+
+	checkSeverities := make(map[string]string, len(settings))
+	for checkID, severity := range settings {
+		checkSeverities[strings.ToUpper(checkID)] = severity
+	}
+
+	return checkSeverities
+}
+
+// getDNSOverrides returns the ip addresses of the host names the runner cannot resolve. They are only accepted
+// in the configuration file, as a map. The names are lower cased by viper
+func getDNSOverrides() map[string]string {
+	settings := viper.GetStringMapString("dns-overrides")
+	if len(settings) == 0 {
+		return nil
+	}
+
+	return settings
+}
+
+// getCriticalThresholds returns the number of failing hosts that make a warning critical by check id,
+// overriding the catalog ones. They are only accepted in the configuration file
+func getCriticalThresholds() map[string]int {
+	var settings map[string]int
+	if err := viper.UnmarshalKey("critical-thresholds", &settings); err != nil {
+		log.Fatal("Invalid critical thresholds configuration: ", err)
+	}
+
+	if len(settings) == 0 {
+		return nil
+	}
+
+	criticalThresholds := make(map[string]int, len(settings))
+	for checkID, threshold := range settings {
+		criticalThresholds[strings.ToUpper(checkID)] = threshold
+	}
+
+	return criticalThresholds
+}
+
+// getCheckVariables returns the extra vars given to the checks of every execution. They are only accepted in the
+// config file, as a map, and their names are lower cased by viper
+func getCheckVariables() map[string]interface{} {
+	settings := viper.GetStringMap("check-variables")
+	if len(settings) == 0 {
+		return nil
+	}
+
+	return settings
+}
+
+// getInventoryVariables returns the variables set in every inventory host. They are only accepted in the config
+// file, as a map, and their names are lower cased by viper
+func getInventoryVariables() map[string]string {
+	settings := viper.GetStringMapString("inventory-variables")
+	if len(settings) == 0 {
+		return nil
+	}
+
+	return settings
+}
+
+func getSimulationResults() map[string]int {
+	var settings map[string]int
+	if err := viper.UnmarshalKey("simulation-results", &settings); err != nil {
+		log.Fatal("Invalid simulation results configuration: ", err)
+	}
+
+	if len(settings) == 0 {
+		return nil
+	}
+
+	simulationResults := make(map[string]int, len(settings))
+	for result, weight := range settings {
+		simulationResults[strings.ToLower(result)] = weight
+	}
+
+	return simulationResults
+}
+
+// getStringList returns the list in the given setting. The values are comma separated in the
+// environment variables, as in the flags
+func getStringList(key string) []string {
+	var list []string
+	for _, value := range viper.GetStringSlice(key) {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+
+	return list
+}
+
+// ValidateConfig checks that the required settings are available, no matter if they
+// come from the flags, the environment or the config file
+func ValidateConfig(config *runner.Config) error {
+	var errors []string
+
+	if config.CallbacksUrl == "" {
+		if config.ResultsDir == "" {
+			errors = append(errors, "callbacks-url is required")
+		}
+	} else if u, err := url.Parse(config.CallbacksUrl); err != nil || u.Scheme == "" || u.Host == "" {
+		errors = append(errors, fmt.Sprintf("callbacks-url %s is not a valid url", config.CallbacksUrl))
+	}
+
+	if config.AnsibleFolder == "" {
+		errors = append(errors, "ansible-folder is required")
+	}
+
+	if config.Port <= 0 || config.Port > 65535 {
+		errors = append(errors, fmt.Sprintf("port %d is out of range", config.Port))
+	}
+
+	if config.GrpcPort < 0 || config.GrpcPort > 65535 {
+		errors = append(errors, fmt.Sprintf("grpc-port %d is out of range", config.GrpcPort))
+	}
+
+	if config.CatalogGitUrl == "" && (config.CatalogGitRef != "" || config.CatalogGitPath != "" || config.CatalogGitVerify) {
+		errors = append(errors, "catalog-git-ref, catalog-git-path and catalog-git-verify require catalog-git-url")
+	}
+
+	if config.CatalogGitPath != "" &&
+		(filepath.IsAbs(config.CatalogGitPath) || strings.HasPrefix(filepath.Clean(config.CatalogGitPath), "..")) {
+		errors = append(errors, fmt.Sprintf("catalog-git-path %s must be a folder of the repository", config.CatalogGitPath))
+	}
+
+	if config.CallbacksRateLimit < 0 {
+		errors = append(errors, "callbacks-rate-limit cannot be negative")
+	}
+
+	if config.CallbacksBatchSize < 0 {
+		errors = append(errors, "callbacks-batch-size cannot be negative")
+	}
+
+	// The proxy url is not written in the errors, as it might have the proxy credentials
+	if config.Proxy != "" && !runner.IsValidProxy(config.Proxy) {
+		errors = append(errors, "proxy is not a valid proxy url, it must be an http, https or socks5 url")
+	}
+	if config.NoProxy != "" && config.Proxy == "" {
+		errors = append(errors, "no-proxy requires proxy")
+	}
+
+	for _, webhookUrl := range config.WebhookUrls {
+		if u, err := url.Parse(webhookUrl); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errors = append(errors, fmt.Sprintf("webhook-url %s is not a valid http url", webhookUrl))
+		}
+	}
+
+	for _, format := range config.ResultsFormats {
+		if _, err := runner.NewResultsExporter(format); err != nil {
+			errors = append(errors, fmt.Sprintf("results-format %s is not supported", format))
+		}
+	}
+
+	if config.ResultsSigningKeyID != "" && config.ResultsSigningKey == "" {
+		errors = append(errors, "results-signing-key-id requires results-signing-key")
+	}
+
+	if config.AnsibleForks < 0 {
+		errors = append(errors, "ansible-forks cannot be negative")
+	}
+
+	if config.AnsibleControlPersist < 0 {
+		errors = append(errors, "ansible-control-persist cannot be negative")
+	}
+
+	if config.AnsibleContainerImage != "" &&
+		config.AnsibleContainerRuntime != runner.PodmanContainerRuntime && config.AnsibleContainerRuntime != runner.DockerContainerRuntime {
+		errors = append(errors, fmt.Sprintf("ansible-container-runtime %s is not supported", config.AnsibleContainerRuntime))
+	}
+
+	if config.AnsibleInstallRequirements && config.AnsibleRequirementsFile == "" {
+		errors = append(errors, "ansible-install-requirements requires ansible-requirements-file")
+	}
+
+	if config.AnsibleGalaxyServer != "" {
+		if u, err := url.Parse(config.AnsibleGalaxyServer); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errors = append(errors, fmt.Sprintf("ansible-galaxy-server %s is not a valid http url", config.AnsibleGalaxyServer))
+		}
+	}
+
+	if config.AnsibleCPULimit < 0 {
+		errors = append(errors, "ansible-cpu-limit cannot be negative")
+	}
+
+	if (config.AnsibleCPULimit > 0 || config.AnsibleMemoryLimit > 0) &&
+		config.AnsibleCgroup == "" && config.AnsibleContainerImage == "" {
+		errors = append(errors, "ansible-cpu-limit and ansible-memory-limit require ansible-cgroup or ansible-container-image")
+	}
+
+	switch config.AnsibleFactCache {
+	case "", runner.JSONFileFactCache:
+	case runner.RedisFactCache:
+		if config.AnsibleFactCacheRedis == "" {
+			errors = append(errors, "ansible-fact-cache-redis is required when the redis fact cache is used")
+		}
+	default:
+		errors = append(errors, fmt.Sprintf("ansible-fact-cache %s is not supported", config.AnsibleFactCache))
+	}
+	if config.AnsibleFactCacheTTL < 0 {
+		errors = append(errors, "ansible-fact-cache-ttl cannot be negative")
+	}
+
+	// The paths are written in the ansible configuration file, where the relative paths are not relative to the runner folder
+	for _, folder := range []struct{ flag, path string }{
+		{"ansible-local-temp", config.AnsibleLocalTemp},
+		{"ansible-control-path-dir", config.AnsibleControlPathDir},
+		{"ansible-fact-cache-dir", config.AnsibleFactCacheDir},
+	} {
+		if folder.path != "" && !filepath.IsAbs(folder.path) {
+			errors = append(errors, fmt.Sprintf("%s %s must be an absolute path", folder.flag, folder.path))
+		}
+	}
+
+	if config.FailedWorkDirRetention < 0 {
+		errors = append(errors, "failed-work-dir-retention cannot be negative")
+	}
+
+	if config.ExecutionLogsMaxAge < 0 {
+		errors = append(errors, "execution-logs-max-age cannot be negative")
+	}
+
+	if config.MaxParallelClusters <= 0 {
+		errors = append(errors, "max-parallel-clusters must be greater than 0")
+	}
+
+	if config.ExecutionQueueSize <= 0 {
+		errors = append(errors, "execution-queue-size must be greater than 0")
 	}
+
+	if config.ShutdownGracePeriod < 0 {
+		errors = append(errors, "shutdown-grace-period cannot be negative")
+	}
+
+	if config.ExecutionTimeout < 0 {
+		errors = append(errors, "execution-timeout cannot be negative")
+	}
+
+	if config.TaskTimeout < 0 {
+		errors = append(errors, "task-timeout cannot be negative")
+	}
+
+	if config.CheckTimeout < 0 {
+		errors = append(errors, "check-timeout cannot be negative")
+	}
+
+	for checkID, timeout := range config.CheckTimeouts {
+		if timeout <= 0 {
+			errors = append(errors, fmt.Sprintf("check-timeouts %s must be greater than 0", checkID))
+		}
+	}
+
+	for checkID, severity := range config.CheckSeverities {
+		if !runner.IsValidSeverity(severity) {
+			errors = append(errors, fmt.Sprintf("check-severities %s must be warning or critical, not %s", checkID, severity))
+		}
+	}
+
+	if config.CriticalThreshold < 0 {
+		errors = append(errors, "critical-threshold cannot be negative")
+	}
+
+	for checkID, threshold := range config.CriticalThresholds {
+		if threshold < 0 {
+			errors = append(errors, fmt.Sprintf("critical-thresholds %s cannot be negative", checkID))
+		}
+	}
+
+	if config.PreflightTimeout < 0 {
+		errors = append(errors, "preflight-timeout cannot be negative")
+	}
+
+	if config.HostRetries < 0 {
+		errors = append(errors, "host-retries cannot be negative")
+	}
+
+	if config.RollingBatchSize < 0 {
+		errors = append(errors, "rolling-batch-size cannot be negative")
+	}
+
+	if config.ExecutionChunkSize < 0 {
+		errors = append(errors, "execution-chunk-size cannot be negative")
+	}
+
+	if config.ExecutionChunkParallelism < 0 {
+		errors = append(errors, "execution-chunk-parallelism cannot be negative")
+	}
+
+	if config.DryRunRetention < 0 {
+		errors = append(errors, "dry-run-retention cannot be negative")
+	}
+
+	if config.ExecutionHooksTimeout < 0 {
+		errors = append(errors, "execution-hooks-timeout cannot be negative")
+	}
+
+	if config.DryRun && config.CheckEngine == runner.NativeCheckEngine {
+		errors = append(errors, "dry-run is not supported by the native check engine")
+	}
+
+	if config.TracingEndpoint != "" {
+		if u, err := url.Parse(config.TracingEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errors = append(errors, fmt.Sprintf("tracing-endpoint %s is not a valid http url", config.TracingEndpoint))
+		}
+	}
+
+	if config.DebugServer != "" {
+		if _, _, err := net.SplitHostPort(config.DebugServer); err != nil {
+			errors = append(errors, fmt.Sprintf("debug-server %s is not a valid host:port address", config.DebugServer))
+		}
+	}
+
+	if config.ResultsCacheTTL < 0 {
+		errors = append(errors, "results-cache-ttl cannot be negative")
+	}
+
+	if config.FullResyncInterval < 0 {
+		errors = append(errors, "full-resync-interval cannot be negative")
+	}
+
+	switch config.CheckEngine {
+	case runner.AnsibleCheckEngine:
+	case runner.NativeCheckEngine:
+		if config.NativeChecksDir == "" {
+			errors = append(errors, "native-checks-dir is required when the native check engine is used")
+		}
+	case runner.SimulationCheckEngine:
+	default:
+		errors = append(errors, fmt.Sprintf("check-engine %s is not supported", config.CheckEngine))
+	}
+
+	total := 0
+	for result, weight := range config.SimulationResults {
+		if !runner.IsSimulatedResult(result) {
+			errors = append(errors, fmt.Sprintf(
+				"simulation-results %s is not a result, it must be passing, warning, critical or skipped", result))
+		}
+		if weight < 0 {
+			errors = append(errors, fmt.Sprintf("simulation-results %s cannot be negative", result))
+		}
+		total += weight
+	}
+	if len(config.SimulationResults) > 0 && total <= 0 {
+		errors = append(errors, "simulation-results must have a weight greater than 0")
+	}
+
+	if config.SimulationLatency < 0 {
+		errors = append(errors, "simulation-latency cannot be negative")
+	}
+
+	if err := runner.ValidatePlaceholders(config.CheckVariables); err != nil {
+		errors = append(errors, fmt.Sprintf("check-variables %s", err))
+	}
+
+	if err := runner.ValidatePlaceholders(config.InventoryVariables); err != nil {
+		errors = append(errors, fmt.Sprintf("inventory-variables %s", err))
+	}
+
+	switch config.ScheduleOverlap {
+	case "", runner.ScheduleOverlapSkip, runner.ScheduleOverlapQueue:
+	default:
+		errors = append(errors, fmt.Sprintf("schedule-overlap %s is not supported", config.ScheduleOverlap))
+	}
+
+	switch config.Mode {
+	case "", runner.ServeMode:
+	case runner.OneshotMode, runner.CronMode:
+		if config.Schedules == "" {
+			errors = append(errors, fmt.Sprintf("schedules are required in the %s mode", config.Mode))
+		}
+	default:
+		errors = append(errors, fmt.Sprintf("mode %s is not supported", config.Mode))
+	}
+
+	if config.AmqpUrl != "" {
+		if config.AmqpExchange == "" || config.AmqpQueue == "" || config.AmqpReplyExchange == "" {
+			errors = append(errors, "amqp-exchange, amqp-queue and amqp-reply-exchange are required when amqp-url is set")
+		}
+	}
+
+	if (config.ServerCertFile == "") != (config.ServerKeyFile == "") {
+		errors = append(errors, "server-cert-file and server-key-file must be used together")
+	}
+
+	if config.ServerTLSReloadInterval < 0 {
+		errors = append(errors, "server-tls-reload-interval cannot be negative")
+	}
+
+	hasToken := config.ServerToken != "" || config.ServerTokenFile != ""
+	hasApiKey := config.ServerApiKey != "" || config.ServerApiKeyFile != ""
+	if config.ServerToken != "" && config.ServerTokenFile != "" {
+		errors = append(errors, "server-token and server-token-file cannot be used together")
+	}
+	if config.ServerApiKey != "" && config.ServerApiKeyFile != "" {
+		errors = append(errors, "server-api-key and server-api-key-file cannot be used together")
+	}
+	if hasToken && hasApiKey {
+		errors = append(errors, "the server token and api key cannot be used together")
+	}
+	if config.ServerAuthUrl != "" && !hasApiKey {
+		errors = append(errors, "server-auth-url requires the server api key")
+	}
+	if config.ServerHandshakeUrl != "" {
+		if u, err := url.Parse(config.ServerHandshakeUrl); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errors = append(errors, "server-handshake-url must be an http or https url")
+		}
+	}
+	if config.ServerSubscriptionUrl != "" {
+		if u, err := url.Parse(config.ServerSubscriptionUrl); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errors = append(errors, "server-subscription-url must be an http or https url")
+		}
+	}
+	if config.ServerRegistrationUrl != "" {
+		if u, err := url.Parse(config.ServerRegistrationUrl); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errors = append(errors, "server-registration-url must be an http or https url")
+		}
+		if config.HeartbeatInterval <= 0 {
+			errors = append(errors, "heartbeat-interval must be greater than 0")
+		}
+	}
+	if config.ServerSummaryUrl != "" {
+		if u, err := url.Parse(config.ServerSummaryUrl); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errors = append(errors, "server-summary-url must be an http or https url")
+		}
+	}
+
+	switch config.SecretsProvider {
+	case "", runner.FileSecretsProvider:
+	case runner.KubernetesSecretsProvider:
+		if config.SecretsDir == "" {
+			errors = append(errors, "secrets-dir is required when the kubernetes secrets provider is used")
+		}
+	case runner.VaultSecretsProvider:
+		if u, err := url.Parse(config.SecretsVaultAddr); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errors = append(errors, fmt.Sprintf("secrets-vault-addr %s is not a valid http url", config.SecretsVaultAddr))
+		}
+		if config.SecretsVaultRoleID == "" || config.SecretsVaultSecretID == "" || config.SecretsVaultPath == "" {
+			errors = append(errors, "secrets-vault-role-id, secrets-vault-secret-id and secrets-vault-path are required when the vault secrets provider is used")
+		}
+	default:
+		errors = append(errors, fmt.Sprintf("secrets-provider %s is not supported", config.SecretsProvider))
+	}
+
+	upstreamNames := make(map[string]bool)
+	for _, upstream := range config.Upstreams {
+		errors = append(errors, validateUpstream(upstream, upstreamNames)...)
+		upstreamNames[upstream.Name] = true
+	}
+
+	for _, sink := range config.Sinks {
+		errors = append(errors, validateSink(sink)...)
+	}
+
+	if config.SSHPassphrase != "" && config.SSHPassphraseFile != "" {
+		errors = append(errors, "ssh-passphrase and ssh-passphrase-file cannot be used together")
+	}
+
+	if config.SSHBastion != "" && !runner.IsValidBastion(config.SSHBastion) {
+		errors = append(errors, fmt.Sprintf("ssh-bastion %s is not valid, it must be a comma separated list of [user@]host[:port]", config.SSHBastion))
+	}
+
+	switch config.SSHHostKeyChecking {
+	case "", runner.HostKeyCheckingDisabled, runner.HostKeyCheckingStrict, runner.HostKeyCheckingTOFU:
+	default:
+		errors = append(errors, fmt.Sprintf("ssh-host-key-checking %s is not supported, it must be strict, tofu or disabled", config.SSHHostKeyChecking))
+	}
+
+	for name, address := range config.DNSOverrides {
+		if net.ParseIP(address) == nil {
+			errors = append(errors, fmt.Sprintf("dns-overrides %s address %s is not a valid ip address", name, address))
+		}
+	}
+
+	for _, vaultID := range config.VaultIDs {
+		if label := strings.SplitN(vaultID, "@", 2); len(label) != 2 || label[0] == "" || label[1] == "" {
+			errors = append(errors, fmt.Sprintf("vault-id %s is not valid, it must be label@password-file", vaultID))
+		}
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf("%s", strings.Join(errors, ", "))
+	}
+
+	return nil
+}
+
+func validateUpstream(upstream *runner.Upstream, upstreamNames map[string]bool) []string {
+	var errors []string
+
+	if upstream.Name == "" {
+		return []string{"the upstreams name is required"}
+	}
+	if upstreamNames[upstream.Name] {
+		errors = append(errors, fmt.Sprintf("upstream %s is duplicated", upstream.Name))
+	}
+
+	if u, err := url.Parse(upstream.CallbacksUrl); err != nil || u.Scheme == "" || u.Host == "" {
+		errors = append(errors, fmt.Sprintf("upstream %s callbacks-url %s is not a valid url", upstream.Name, upstream.CallbacksUrl))
+	}
+
+	if upstream.CatalogUrl != "" {
+		if u, err := url.Parse(upstream.CatalogUrl); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errors = append(errors, fmt.Sprintf("upstream %s catalog-url %s is not a valid http url", upstream.Name, upstream.CatalogUrl))
+		}
+	}
+
+	if upstream.SubscriptionUrl != "" {
+		if u, err := url.Parse(upstream.SubscriptionUrl); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errors = append(errors, fmt.Sprintf("upstream %s subscription-url %s is not a valid http url", upstream.Name, upstream.SubscriptionUrl))
+		}
+	}
+
+	if upstream.CatalogInterval < 0 {
+		errors = append(errors, fmt.Sprintf("upstream %s catalog-interval cannot be negative", upstream.Name))
+	}
+
+	hasToken := upstream.ServerToken != "" || upstream.ServerTokenFile != ""
+	hasApiKey := upstream.ServerApiKey != "" || upstream.ServerApiKeyFile != ""
+	if upstream.ServerToken != "" && upstream.ServerTokenFile != "" {
+		errors = append(errors, fmt.Sprintf("upstream %s server-token and server-token-file cannot be used together", upstream.Name))
+	}
+	if upstream.ServerApiKey != "" && upstream.ServerApiKeyFile != "" {
+		errors = append(errors, fmt.Sprintf("upstream %s server-api-key and server-api-key-file cannot be used together", upstream.Name))
+	}
+	if hasToken && hasApiKey {
+		errors = append(errors, fmt.Sprintf("upstream %s token and api key cannot be used together", upstream.Name))
+	}
+	if upstream.ServerAuthUrl != "" && !hasApiKey {
+		errors = append(errors, fmt.Sprintf("upstream %s server-auth-url requires the server api key", upstream.Name))
+	}
+
+	return errors
+}
+
+func validateSink(sink *runner.Sink) []string {
+	switch sink.Type {
+	case runner.TrentoSinkType:
+		if u, err := url.Parse(sink.Url); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return []string{fmt.Sprintf("trento sink url %s is not a valid http url", sink.Url)}
+		}
+	case runner.FileSinkType:
+		if sink.Path == "" {
+			return []string{"file sink path is required"}
+		}
+	case runner.SyslogSinkType:
+		if (sink.Network == "") != (sink.Address == "") {
+			return []string{"syslog sink network and address must be used together"}
+		}
+	default:
+		return []string{fmt.Sprintf("sink type %s is not supported", sink.Type)}
+	}
+
+	return nil
+}
+
+// reloadableSettings are the settings applied without a restart when the configuration is reloaded
+var reloadableSettings = map[string]bool{
+	"log-level":             true,
+	"callbacks-url":         true,
+	"max-parallel-clusters": true,
+	"heartbeat-interval":    true,
+	"full-resync-interval":  true,
+}
+
+// configReloader reads the config file again, keeping the settings of the last configuration applied
+// to find the ones changed
+type configReloader struct {
+	settings map[string]interface{}
+	// The settings and the log level of the last configuration read, until they are committed
+	pendingSettings map[string]interface{}
+	pendingLogLevel string
+}
+
+func newConfigReloader() *configReloader {
+	return &configReloader{settings: viper.AllSettings()}
+}
+
+// Reload reads the config file again, returning the new configuration and the changed settings that are
+// only applied after a restart. An invalid configuration is not loaded. The settings and the log level
+// are applied with Commit, once the app accepts the new configuration
+func (r *configReloader) Reload() (*runner.Config, []string, error) {
+	if err := viper.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			return nil, nil, fmt.Errorf("cannot read the config file: %s", err)
+		}
+	}
+
+	config := LoadConfig()
+	if err := ValidateConfig(config); err != nil {
+		return nil, nil, err
+	}
+
+	settings := viper.AllSettings()
+	restartSettings := []string{}
+	for _, key := range changedSettings(r.settings, settings) {
+		if !reloadableSettings[key] {
+			restartSettings = append(restartSettings, key)
+		}
+	}
+	r.pendingSettings = settings
+	r.pendingLogLevel = viper.GetString("log-level")
+
+	return config, restartSettings, nil
+}
+
+// Commit keeps the settings of the last configuration read as the applied ones, and applies its log level
+func (r *configReloader) Commit() {
+	if r.pendingSettings == nil {
+		return
+	}
+
+	r.settings = r.pendingSettings
+	internal.SetLogLevel(r.pendingLogLevel)
+	r.pendingSettings = nil
+}
+
+// changedSettings returns the names of the settings with a different value, sorted
+func changedSettings(previous, current map[string]interface{}) []string {
+	changed := []string{}
+	for key, value := range current {
+		if !reflect.DeepEqual(previous[key], value) {
+			changed = append(changed, key)
+		}
+	}
+	for key := range previous {
+		if _, ok := current[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+
+	return changed
 }
@@ -2,21 +2,29 @@ package cmd
 
 import (
 	"fmt"
-	"runtime"
 
 	"github.com/spf13/cobra"
+	"github.com/trento-project/runner/runner"
 	"github.com/trento-project/runner/version"
 )
 
+func versionText() string {
+	return fmt.Sprintf("%sembedded checks %s\n", version.GetInfo(), runner.EmbeddedChecksHash())
+}
+
 func addVersionCmd(runnerCmd *cobra.Command) {
 	versionCmd := &cobra.Command{
 		Use:   "version",
 		Short: "Print the version number of Trento Runner",
 		Long:  `All software has versions. This is Trento Runner's`,
 		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Printf("Trento Runner %s version %s\nbuilt with %s %s/%s\n", version.Flavor, version.Version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+			fmt.Print(versionText())
 		},
 	}
 
 	runnerCmd.AddCommand(versionCmd)
+
+	// The --version flag prints the same information
+	runnerCmd.Version = version.Version
+	runnerCmd.SetVersionTemplate(versionText())
 }
@@ -27,7 +27,7 @@ func InitConfig(configName string) error {
 
 	viper.SetConfigType("yaml")
 	SetLogLevel(viper.GetString("log-level"))
-	SetLogFormatter("2006-01-02 15:04:05")
+	SetLogFormatter(viper.GetString("log-format"), "2006-01-02 15:04:05")
 
 	cfgFile := viper.GetString("config")
 	if cfgFile != "" {
@@ -20,7 +20,18 @@ func SetLogLevel(level string) {
 	}
 }
 
-func SetLogFormatter(timestampFormat string) {
+// SetLogFormatter sets the logs output format, text or json. The json format
+// is meant for the log aggregators, having each field in its own key
+func SetLogFormatter(format string, timestampFormat string) {
+	switch format {
+	case "json":
+		log.SetFormatter(&log.JSONFormatter{TimestampFormat: timestampFormat})
+		return
+	case "text":
+	default:
+		log.Warnln("Unrecognized log format; using 'text' as default")
+	}
+
 	customFormatter := new(log.TextFormatter)
 	customFormatter.TimestampFormat = timestampFormat
 	log.SetFormatter(customFormatter)
@@ -0,0 +1,124 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The host names are dns names, with the underscores some internal names have
+var validHostName = regexp.MustCompile(
+	`^[A-Za-z0-9_]([A-Za-z0-9_-]{0,61}[A-Za-z0-9_])?(\.[A-Za-z0-9_]([A-Za-z0-9_-]{0,61}[A-Za-z0-9_])?)*\.?$`)
+var numericHostName = regexp.MustCompile(`^[0-9.]+$`)
+
+// parseHostAddress splits the host address in its host and its port, which is empty if the address has none.
+// The address is an ipv4 address, an ipv6 address, bracketed if it has a port, or a host name, with an optional
+// port: 192.168.10.1:2222, [fd00::1]:2222, fd00::1 or node1.example.com:2222. The ipv6 addresses can have a zone
+func parseHostAddress(address string) (string, string, error) {
+	host, port := address, ""
+	if strings.HasPrefix(address, "[") {
+		end := strings.Index(address, "]")
+		if end == -1 {
+			return "", "", fmt.Errorf("address %s has an unclosed bracket", address)
+		}
+		host = address[1:end]
+		if rest := address[end+1:]; rest != "" {
+			if !strings.HasPrefix(rest, ":") {
+				return "", "", fmt.Errorf("address %s is not valid, only a port can follow the brackets", address)
+			}
+			port = rest[1:]
+		}
+		if !isIPv6(host) {
+			return "", "", fmt.Errorf("address %s is not valid, only ipv6 addresses are bracketed", address)
+		}
+	} else if strings.Count(address, ":") > 1 {
+		// An unbracketed ipv6 address cannot have a port, all its colons are part of the address
+		if !isIPv6(address) {
+			return "", "", fmt.Errorf("address %s is not a valid ipv6 address, it must be bracketed to have a port", address)
+		}
+	} else if index := strings.Index(address, ":"); index != -1 {
+		host, port = address[:index], address[index+1:]
+	}
+
+	if port != "" {
+		if number, err := strconv.Atoi(port); err != nil || number < 1 || number > 65535 {
+			return "", "", fmt.Errorf("address %s port %s is not valid", address, port)
+		}
+	} else if strings.HasSuffix(address, ":") {
+		return "", "", fmt.Errorf("address %s has an empty port", address)
+	}
+
+	if strings.Contains(host, ":") || net.ParseIP(host) != nil {
+		return host, port, nil
+	}
+	if numericHostName.MatchString(host) {
+		return "", "", fmt.Errorf("address %s is not a valid ipv4 address", address)
+	}
+	if len(host) > 253 || !validHostName.MatchString(host) {
+		return "", "", fmt.Errorf("address %s is not a valid ip address or host name", address)
+	}
+
+	return host, port, nil
+}
+
+// isIPv6 tells if the address is an ipv6 one, with an optional zone, as fe80::1%eth0
+func isIPv6(address string) bool {
+	if index := strings.Index(address, "%"); index != -1 {
+		if index == len(address)-1 {
+			return false
+		}
+		address = address[:index]
+	}
+
+	ip := net.ParseIP(address)
+	return ip != nil && strings.Contains(address, ":")
+}
+
+// hostPort returns the address to connect to the host, in the port of its address or the given one
+func hostPort(address, defaultPort string) (string, error) {
+	host, port, err := parseHostAddress(address)
+	if err != nil {
+		return "", err
+	}
+	if port == "" {
+		port = defaultPort
+	}
+
+	return net.JoinHostPort(host, port), nil
+}
+
+// overrideHostsAddresses returns a copy of the execution event with the host names of the addresses replaced by
+// their ip addresses in the dns overrides, keeping their ports, for the environments where the runner cannot
+// resolve the names reported by the Trento server. The names are matched case insensitively
+func overrideHostsAddresses(ctx context.Context, e *ExecutionEvent, overrides map[string]string) *ExecutionEvent {
+	if len(overrides) == 0 {
+		return e
+	}
+
+	overridden := *e
+	overridden.Hosts = make([]*Host, 0, len(e.Hosts))
+	for _, host := range e.Hosts {
+		overriddenHost := *host
+		overridden.Hosts = append(overridden.Hosts, &overriddenHost)
+
+		name, port, err := parseHostAddress(host.Address)
+		if err != nil {
+			continue
+		}
+		ip, ok := overrides[strings.ToLower(strings.TrimSuffix(name, "."))]
+		if !ok {
+			continue
+		}
+
+		overriddenHost.Address = ip
+		if port != "" {
+			overriddenHost.Address = net.JoinHostPort(ip, port)
+		}
+		loggerFromContext(ctx).Debugf("Host %s address overridden: %s, instead of %s", host.HostID.String(), overriddenHost.Address, host.Address)
+	}
+
+	return &overridden
+}
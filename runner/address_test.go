@@ -0,0 +1,87 @@
+package runner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHostAddress(t *testing.T) {
+	valid := []struct {
+		address string
+		host    string
+		port    string
+	}{
+		{"192.168.10.1", "192.168.10.1", ""},
+		{"192.168.10.1:2222", "192.168.10.1", "2222"},
+		{"fd00::1", "fd00::1", ""},
+		{"fe80::1%eth0", "fe80::1%eth0", ""},
+		{"[fd00::1]", "fd00::1", ""},
+		{"[fd00::1]:2222", "fd00::1", "2222"},
+		{"vmhana01", "vmhana01", ""},
+		{"vmhana01.example.com:2222", "vmhana01.example.com", "2222"},
+		{"vm_hana-01.example.com.", "vm_hana-01.example.com.", ""},
+	}
+	for _, tc := range valid {
+		host, port, err := parseHostAddress(tc.address)
+		assert.NoError(t, err, tc.address)
+		assert.Equal(t, tc.host, host, tc.address)
+		assert.Equal(t, tc.port, port, tc.address)
+	}
+
+	invalid := map[string]string{
+		"":                   "address  is not a valid ip address or host name",
+		"[fd00::1":           "address [fd00::1 has an unclosed bracket",
+		"[fd00::1]2222":      "address [fd00::1]2222 is not valid, only a port can follow the brackets",
+		"[192.168.10.1]:22":  "address [192.168.10.1]:22 is not valid, only ipv6 addresses are bracketed",
+		"fd00::1:zz":         "address fd00::1:zz is not a valid ipv6 address, it must be bracketed to have a port",
+		"192.168.10.1:":      "address 192.168.10.1: has an empty port",
+		"192.168.10.1:70000": "address 192.168.10.1:70000 port 70000 is not valid",
+		"vmhana01:ssh":       "address vmhana01:ssh port ssh is not valid",
+		"192.168.10.300":     "address 192.168.10.300 is not a valid ipv4 address",
+		"vmhana01 -o Proxy":  "address vmhana01 -o Proxy is not a valid ip address or host name",
+		"-vmhana01":          "address -vmhana01 is not a valid ip address or host name",
+	}
+	for address, message := range invalid {
+		_, _, err := parseHostAddress(address)
+		assert.EqualError(t, err, message, address)
+	}
+}
+
+func TestHostPort(t *testing.T) {
+	address, err := hostPort("fd00::1", "22")
+	assert.NoError(t, err)
+	assert.Equal(t, "[fd00::1]:22", address)
+
+	address, err = hostPort("[fd00::1]:2222", "22")
+	assert.NoError(t, err)
+	assert.Equal(t, "[fd00::1]:2222", address)
+
+	address, err = hostPort("vmhana01.example.com", "5986")
+	assert.NoError(t, err)
+	assert.Equal(t, "vmhana01.example.com:5986", address)
+}
+
+func TestOverrideHostsAddresses(t *testing.T) {
+	execution := &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Hosts: []*Host{
+			{HostID: uuid.New(), Address: "VMHANA01.example.com."},
+			{HostID: uuid.New(), Address: "vmhana02:2222"},
+			{HostID: uuid.New(), Address: "192.168.10.3"},
+		},
+	}
+	overrides := map[string]string{"vmhana01.example.com": "192.168.10.1", "vmhana02": "fd00::2"}
+
+	overridden := overrideHostsAddresses(context.Background(), execution, overrides)
+	assert.Equal(t, "192.168.10.1", overridden.Hosts[0].Address)
+	assert.Equal(t, "[fd00::2]:2222", overridden.Hosts[1].Address)
+	assert.Equal(t, "192.168.10.3", overridden.Hosts[2].Address)
+	// The execution event is not modified
+	assert.Equal(t, "vmhana02:2222", execution.Hosts[1].Address)
+
+	assert.Same(t, execution, overrideHostsAddresses(context.Background(), execution, nil))
+}
@@ -0,0 +1,73 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// RunAdHocChecks runs the given checks in a single host, out of any cluster execution, and returns the host results.
+// It is meant to debug the checks, so the results are not reported to the Trento server
+func RunAdHocChecks(ctx context.Context, config *Config, host *Host, provider string, checks []string) (*HostResults, error) {
+	if config.CheckEngine != NativeCheckEngine && config.CheckEngine != SimulationCheckEngine {
+		if err := prepareAnsibleFiles(config); err != nil {
+			return nil, err
+		}
+	}
+
+	checkEngine, err := NewCheckEngine(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if host.HostID == uuid.Nil {
+		host.HostID = uuid.New()
+	}
+
+	execution := &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Provider:    provider,
+		Checks:      checks,
+		Hosts:       []*Host{host},
+	}
+
+	ctx = withLogger(ctx, executionLogger(execution))
+	execution = overrideHostsAddresses(ctx, execution, config.DNSOverrides)
+
+	results, err := checkEngine.Run(ctx, execution, nil)
+	if err != nil {
+		return nil, err
+	}
+	if results == nil {
+		return nil, fmt.Errorf("the checks results are not available")
+	}
+
+	hostResults := results.getHost(host.HostID.String())
+	if hostResults == nil {
+		return nil, fmt.Errorf("the host %s did not return any result", host.Address)
+	}
+	if !hostResults.Reachable {
+		return hostResults, nil
+	}
+
+	// The playbook reports the checks not selected as skipped, only the given ones are returned
+	selected := make([]*CheckResult, 0, len(checks))
+	for _, checkID := range checks {
+		var found *CheckResult
+		for _, result := range hostResults.Results {
+			if result.CheckID == checkID {
+				found = result
+				break
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("check %s not found", checkID)
+		}
+		selected = append(selected, found)
+	}
+	hostResults.Results = selected
+
+	return hostResults, nil
+}
@@ -0,0 +1,82 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/trento-project/runner/runner/mocks"
+)
+
+const adHocPlaybookOutput = `{
+  "plays": [{
+    "play": {"id": "1", "name": "all"},
+    "tasks": [
+      {
+        "task": {"id": "2", "name": "set_test_result"},
+        "hosts": {"%[1]s": {"ansible_facts": {"test_check_id": "156F64", "test_result": "critical"}}}
+      },
+      {
+        "task": {"id": "3", "name": "set_test_result"},
+        "hosts": {"%[1]s": {"ansible_facts": {"test_check_id": "53D035", "test_result": "passing"}}}
+      }
+    ]
+  }],
+  "stats": {}
+}`
+
+func TestRunAdHocChecks(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	hostID := uuid.New()
+	outputFile := path.Join(tmpDir, "output.json")
+	ioutil.WriteFile(outputFile, []byte(fmt.Sprintf(adHocPlaybookOutput, hostID.String())), 0644)
+
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+	defer func() { customExecCommand = exec.Command }()
+	mockCommand.On(
+		"Execute", "ansible-playbook", path.Join(tmpDir, AnsibleMain), mock.Anything, "--check",
+	).Return(exec.Command("cat", outputFile))
+
+	host := &Host{HostID: hostID, Address: "192.168.10.1", User: "root"}
+	results, err := RunAdHocChecks(context.Background(), &Config{AnsibleFolder: tmpDir}, host, "azure", []string{"156F64"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, &HostResults{
+		HostID:    hostID.String(),
+		Reachable: true,
+		Results:   []*CheckResult{{CheckID: "156F64", Result: "critical"}},
+	}, results)
+
+	inventories, _ := ioutil.ReadDir(path.Join(tmpDir, "ansible/inventories"))
+	assert.Empty(t, inventories)
+}
+
+func TestRunAdHocChecks_CheckNotFound(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	hostID := uuid.New()
+	outputFile := path.Join(tmpDir, "output.json")
+	ioutil.WriteFile(outputFile, []byte(fmt.Sprintf(adHocPlaybookOutput, hostID.String())), 0644)
+
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+	defer func() { customExecCommand = exec.Command }()
+	mockCommand.On("Execute", "ansible-playbook", mock.Anything, mock.Anything, "--check").Return(exec.Command("cat", outputFile))
+
+	host := &Host{HostID: hostID, Address: "192.168.10.1", User: "root"}
+	_, err := RunAdHocChecks(context.Background(), &Config{AnsibleFolder: tmpDir}, host, "azure", []string{"ABCDEF"})
+
+	assert.EqualError(t, err, "check ABCDEF not found")
+}
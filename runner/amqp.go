@@ -0,0 +1,272 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultAmqpExchange      = "trento.checks"
+	DefaultAmqpQueue         = "trento.checks.executions"
+	DefaultAmqpReplyExchange = "trento.checks.results"
+
+	amqpExchangeKind  = "topic"
+	amqpAllRoutingKey = "#"
+	amqpContentType   = "application/json"
+)
+
+var amqpReconnectInterval = time.Second * 5
+
+// The broker delivers a single unacknowledged request at a time, so the requests are spread among the runners
+// consuming the same queue instead of piling up in the first one
+var amqpPrefetchCount = 1
+
+// The requests that cannot be scheduled are requeued up to these times, and then rejected without requeuing,
+// so they go to the dead letter exchange of the queue, if it has one, instead of being redelivered forever
+var amqpMaxRequeues = 5
+
+// The requests are requeued after this delay, doubled after each requeue, so the runner has time to free
+// its queue. With the prefetch count, no other request is taken meanwhile
+var amqpRequeueDelay = time.Second
+
+// AmqpConsumer listens the execution requests published in the configured queue,
+// and schedules them as the executions requested with the HTTP API
+type AmqpConsumer struct {
+	url           string
+	exchange      string
+	queue         string
+	runnerService RunnerService
+	// Times each execution request has been requeued
+	requeues map[uuid.UUID]int
+}
+
+func NewAmqpConsumer(config *Config, runnerService RunnerService) *AmqpConsumer {
+	return &AmqpConsumer{
+		url:           config.AmqpUrl,
+		exchange:      config.AmqpExchange,
+		queue:         config.AmqpQueue,
+		runnerService: runnerService,
+		requeues:      make(map[uuid.UUID]int),
+	}
+}
+
+// Run consumes the execution requests until the context is done, reconnecting if the connection is lost
+func (c *AmqpConsumer) Run(ctx context.Context) {
+	log.Infof("Starting AMQP consumer. Exchange: %s, Queue: %s", c.exchange, c.queue)
+
+	for {
+		err := c.consume(ctx)
+		if ctx.Err() != nil {
+			log.Infof("AMQP consumer is shutting down.")
+			return
+		}
+
+		log.Errorf("AMQP consumer stopped, reconnecting in %s: %s", amqpReconnectInterval, err)
+
+		select {
+		case <-time.After(amqpReconnectInterval):
+		case <-ctx.Done():
+			log.Infof("AMQP consumer is shutting down.")
+			return
+		}
+	}
+}
+
+func (c *AmqpConsumer) consume(ctx context.Context) error {
+	conn, err := amqp.Dial(c.url)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	channel, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer channel.Close()
+
+	if err := channel.ExchangeDeclare(c.exchange, amqpExchangeKind, true, false, false, false, nil); err != nil {
+		return err
+	}
+
+	if _, err := channel.QueueDeclare(c.queue, true, false, false, false, nil); err != nil {
+		return err
+	}
+
+	if err := channel.QueueBind(c.queue, amqpAllRoutingKey, c.exchange, false, nil); err != nil {
+		return err
+	}
+
+	if err := channel.Qos(amqpPrefetchCount, 0, false); err != nil {
+		return err
+	}
+
+	deliveries, err := channel.Consume(c.queue, "", false, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+
+	closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+
+	for {
+		select {
+		case delivery, ok := <-deliveries:
+			if !ok {
+				return fmt.Errorf("deliveries channel closed")
+			}
+			c.handleDelivery(ctx, delivery)
+		case err := <-closed:
+			return err
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (c *AmqpConsumer) handleDelivery(ctx context.Context, delivery amqp.Delivery) {
+	var e *ExecutionEvent
+
+	if err := json.Unmarshal(delivery.Body, &e); err != nil {
+		log.Errorf("Discarding malformed execution request: %s", err)
+		delivery.Reject(false)
+		return
+	}
+
+	if err := binding.Validator.ValidateStruct(e); err != nil {
+		log.Errorf("Discarding invalid execution request: %s", err)
+		delivery.Reject(false)
+		return
+	}
+
+	if err := e.validate(); err != nil {
+		log.Errorf("Discarding invalid execution request: %s", err)
+		delivery.Reject(false)
+		return
+	}
+
+	err := c.runnerService.ScheduleExecution(e)
+	if status, ok := DuplicateExecutionStatus(err); ok {
+		log.Warnf("Discarding the duplicated execution request %s, its status is %s", e.ExecutionID.String(), status)
+		delete(c.requeues, e.ExecutionID)
+		delivery.Ack(false)
+		return
+	}
+	if err != nil && c.requeues[e.ExecutionID] >= amqpMaxRequeues {
+		log.Errorf("Error scheduling execution %s, discarding it after %d requeues: %s",
+			e.ExecutionID.String(), amqpMaxRequeues, err)
+		delete(c.requeues, e.ExecutionID)
+		delivery.Nack(false, false)
+		return
+	}
+	if err != nil {
+		delay := amqpRequeueDelay << c.requeues[e.ExecutionID]
+		log.Errorf("Error scheduling execution %s, requeuing it in %s: %s", e.ExecutionID.String(), delay, err)
+		c.requeues[e.ExecutionID]++
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+		delivery.Nack(false, true)
+		return
+	}
+
+	delete(c.requeues, e.ExecutionID)
+	delivery.Ack(false)
+}
+
+// amqpCallbacksClient publishes the callbacks in the reply exchange,
+// using the execution ID as correlation ID and the event as routing key
+type amqpCallbacksClient struct {
+	url      string
+	exchange string
+	mu       sync.Mutex
+	conn     *amqp.Connection
+	channel  *amqp.Channel
+}
+
+func NewAmqpCallbacksClient(url, exchange string) *amqpCallbacksClient {
+	return &amqpCallbacksClient{
+		url:      url,
+		exchange: exchange,
+	}
+}
+
+func (c *amqpCallbacksClient) Callback(executionID uuid.UUID, event string, payload interface{}) error {
+	log.Debugf("Publishing callback for execution %s with event %s", executionID, event)
+
+	body, err := json.Marshal(map[string]interface{}{
+		"execution_id": executionID.String(),
+		"event":        event,
+		"payload":      payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	channel, err := c.getChannel()
+	if err != nil {
+		return err
+	}
+
+	err = channel.Publish(c.exchange, event, false, false, amqp.Publishing{
+		ContentType:   amqpContentType,
+		CorrelationId: executionID.String(),
+		DeliveryMode:  amqp.Persistent,
+		Body:          body,
+	})
+	if err != nil {
+		// Force a new connection in the next callback
+		c.close()
+		return err
+	}
+
+	return nil
+}
+
+func (c *amqpCallbacksClient) getChannel() (*amqp.Channel, error) {
+	if c.conn != nil && !c.conn.IsClosed() && c.channel != nil {
+		return c.channel, nil
+	}
+
+	c.close()
+
+	conn, err := amqp.Dial(c.url)
+	if err != nil {
+		return nil, err
+	}
+
+	channel, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := channel.ExchangeDeclare(c.exchange, amqpExchangeKind, true, false, false, false, nil); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	c.conn = conn
+	c.channel = channel
+
+	return channel, nil
+}
+
+func (c *amqpCallbacksClient) close() {
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	c.conn = nil
+	c.channel = nil
+}
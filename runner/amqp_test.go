@@ -0,0 +1,163 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type fakeAcknowledger struct {
+	acked    bool
+	nacked   bool
+	requeued bool
+	rejected bool
+}
+
+func (a *fakeAcknowledger) Ack(tag uint64, multiple bool) error {
+	a.acked = true
+	return nil
+}
+
+func (a *fakeAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	a.nacked = true
+	a.requeued = requeue
+	return nil
+}
+
+func (a *fakeAcknowledger) Reject(tag uint64, requeue bool) error {
+	a.rejected = true
+	a.requeued = requeue
+	return nil
+}
+
+type AmqpConsumerTestCase struct {
+	suite.Suite
+	runnerService *MockRunnerService
+	consumer      *AmqpConsumer
+}
+
+func TestAmqpConsumerTestCase(t *testing.T) {
+	suite.Run(t, new(AmqpConsumerTestCase))
+}
+
+func (suite *AmqpConsumerTestCase) SetupTest() {
+	amqpRequeueDelay = time.Millisecond
+	suite.runnerService = new(MockRunnerService)
+	suite.consumer = NewAmqpConsumer(&Config{
+		AmqpUrl:      "amqp://localhost",
+		AmqpExchange: DefaultAmqpExchange,
+		AmqpQueue:    DefaultAmqpQueue,
+	}, suite.runnerService)
+}
+
+func (suite *AmqpConsumerTestCase) TearDownTest() {
+	amqpRequeueDelay = time.Second
+}
+
+func (suite *AmqpConsumerTestCase) newDelivery(body []byte) (amqp.Delivery, *fakeAcknowledger) {
+	acknowledger := &fakeAcknowledger{}
+	return amqp.Delivery{Acknowledger: acknowledger, Body: body}, acknowledger
+}
+
+func (suite *AmqpConsumerTestCase) Test_HandleDelivery() {
+	execution := &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Provider:    "azure",
+		Checks:      []string{"check1"},
+		Hosts: []*Host{
+			&Host{
+				HostID:  uuid.New(),
+				Address: "192.168.10.1",
+				User:    "user1",
+			},
+		},
+	}
+	body, _ := json.Marshal(execution)
+	delivery, acknowledger := suite.newDelivery(body)
+
+	suite.runnerService.On("ScheduleExecution", execution).Return(nil)
+
+	suite.consumer.handleDelivery(context.Background(), delivery)
+
+	suite.True(acknowledger.acked)
+	suite.runnerService.AssertExpectations(suite.T())
+}
+
+func (suite *AmqpConsumerTestCase) Test_HandleDelivery_Malformed() {
+	delivery, acknowledger := suite.newDelivery([]byte("not json"))
+
+	suite.consumer.handleDelivery(context.Background(), delivery)
+
+	suite.True(acknowledger.rejected)
+	suite.False(acknowledger.requeued)
+	suite.runnerService.AssertNotCalled(suite.T(), "ScheduleExecution", mock.Anything)
+}
+
+func (suite *AmqpConsumerTestCase) Test_HandleDelivery_Invalid() {
+	delivery, acknowledger := suite.newDelivery([]byte(`{"provider": "azure"}`))
+
+	suite.consumer.handleDelivery(context.Background(), delivery)
+
+	suite.True(acknowledger.rejected)
+	suite.False(acknowledger.requeued)
+	suite.runnerService.AssertNotCalled(suite.T(), "ScheduleExecution", mock.Anything)
+}
+
+func (suite *AmqpConsumerTestCase) Test_HandleDelivery_ScheduleError() {
+	execution := &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Provider:    "azure",
+		Checks:      []string{"check1"},
+		Hosts:       []*Host{},
+	}
+	body, _ := json.Marshal(execution)
+	delivery, acknowledger := suite.newDelivery(body)
+
+	suite.runnerService.On("ScheduleExecution", mock.Anything).Return(
+		fmt.Errorf("Cannot process more executions"))
+
+	suite.consumer.handleDelivery(context.Background(), delivery)
+
+	suite.True(acknowledger.nacked)
+	suite.True(acknowledger.requeued)
+}
+
+func (suite *AmqpConsumerTestCase) Test_HandleDelivery_RequeueLimit() {
+	defer func(requeues int) { amqpMaxRequeues = requeues }(amqpMaxRequeues)
+	amqpMaxRequeues = 2
+
+	execution := &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Provider:    "azure",
+		Checks:      []string{"check1"},
+		Hosts:       []*Host{},
+	}
+	body, _ := json.Marshal(execution)
+
+	suite.runnerService.On("ScheduleExecution", mock.Anything).Return(
+		fmt.Errorf("Cannot process more executions"))
+
+	for i := 0; i < amqpMaxRequeues; i++ {
+		delivery, acknowledger := suite.newDelivery(body)
+		suite.consumer.handleDelivery(context.Background(), delivery)
+		suite.True(acknowledger.nacked)
+		suite.True(acknowledger.requeued)
+	}
+
+	// The request is dead lettered once the requeues are exhausted
+	delivery, acknowledger := suite.newDelivery(body)
+	suite.consumer.handleDelivery(context.Background(), delivery)
+	suite.True(acknowledger.nacked)
+	suite.False(acknowledger.requeued)
+	suite.NotContains(suite.consumer.requeues, execution.ExecutionID)
+}
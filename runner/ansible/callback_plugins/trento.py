@@ -8,20 +8,25 @@ Trento Callback executor.
 :since: 2021-09-16
 """
 
+import json
 import logging
 import os
+import sys
 import yaml
-import requests
 
 from ansible.plugins.callback import CallbackBase
 
-TRENTO_TEST_LABEL_KEY = "trento_labels"
-TRENTO_TEST_LABEL = "test"
 TEST_RESULT_TASK_NAME = "set_test_result"
 TEST_INCLUDE_TASK_NAME = "run_checks"
 CHECK_ID = "id"
+CHECK_ARCHITECTURES = "architectures"
+# The architecture of the host is set by the load_facts role
+ARCHITECTURE_FACT = "trento_architecture"
+ARCHITECTURE_SKIPPED_MSG = "The check does not support the {} architecture of the host, only {}"
 
-EXECUTION_COMPLETED_EVENT = "execution_completed"
+# The partial results are printed in the stderr with this prefix, so the runner can post them while the checks run
+PARTIAL_RESULTS_ENV = "TRENTO_PARTIAL_RESULTS"
+PARTIAL_RESULT_PREFIX = "TRENTO_PARTIAL_RESULT "
 
 
 class ExecutionResults(object):
@@ -72,9 +77,9 @@ class ExecutionResults(object):
 
     def add_result(self, host_id, check_id, result, msg=""):
         """
-        Add check result
+        Add check result. Returns True if the result is new
         """
-        self.cluster.add_result(host_id, check_id, result, msg)
+        return self.cluster.add_result(host_id, check_id, result, msg)
 
     def to_dict(self):
         """
@@ -106,12 +111,12 @@ class Cluster(object):
 
     def add_result(self, host_id, check_id, result, msg=""):
         """
-        Add check result
+        Add check result. Returns True if the result is new
         """
         for host in self.hosts:
             if host.host_id == host_id:
-                host.add_result(check_id, result, msg)
-                break
+                return host.add_result(check_id, result, msg)
+        return False
 
     def to_dict(self):
         """
@@ -136,7 +141,7 @@ class Host(object):
 
     def add_result(self, check_id, result, msg=""):
         """
-        Add check result
+        Add check result. Returns True if the result is new
         """
         # Check if a result already exists
         # Due how ansible callbacks system works, we might get same check results twice
@@ -144,9 +149,9 @@ class Host(object):
         # when the check has failed due abnormal behaviours
         for result_item in self.results:
             if result_item.check_id == check_id:
-                break
-        else:
-            self.results.append(CheckResult(check_id, result, msg))
+                return False
+        self.results.append(CheckResult(check_id, result, msg))
+        return True
 
     def to_dict(self):
         """
@@ -193,8 +198,7 @@ class CallbackModule(CallbackBase):
         super(CallbackModule, self).__init__()
         self.play = None
         self.execution_results = ExecutionResults()
-        self._callbacks_url = os.getenv('TRENTO_CALLBACKS_URL')
-        self._execution_id = os.getenv('TRENTO_EXECUTION_ID')
+        self._partial_results = bool(os.getenv(PARTIAL_RESULTS_ENV))
 
     def v2_playbook_on_start(self, _):
         """
@@ -209,6 +213,10 @@ class CallbackModule(CallbackBase):
         self.play = play
         play_vars = self._all_vars()
         for _, host_data in play_vars["hostvars"].items():
+            # The hosts are in the provider and role groups as well, the cluster group sets the cluster_id variable
+            if "cluster_id" in host_data:
+                self.execution_results.initialize_cluster(host_data["cluster_id"])
+                continue
             for group in host_data["group_names"]:
                 self.execution_results.initialize_cluster(group)
                 break
@@ -229,7 +237,7 @@ class CallbackModule(CallbackBase):
 
         test_result = result._task_fields["args"]["test_result"]
         self.execution_results.add_host(host, True)
-        self.execution_results.add_result(host, task_vars[CHECK_ID], test_result)
+        self._add_result(host, task_vars[CHECK_ID], test_result)
 
     def v2_runner_on_failed(self, result, ignore_errors):
         """
@@ -243,7 +251,7 @@ class CallbackModule(CallbackBase):
 
         msg = result._check_key("msg")
         self.execution_results.add_host(host, True)
-        self.execution_results.add_result(host, task_vars[CHECK_ID], "critical", msg)
+        self._add_result(host, task_vars[CHECK_ID], "critical", msg)
 
     def v2_runner_on_skipped(self, result):
         """
@@ -259,16 +267,7 @@ class CallbackModule(CallbackBase):
         host = result._host.get_name()
         msg = result._check_key("msg")
         self.execution_results.add_host(host, False, msg)
-
-    def v2_playbook_on_stats(self, _stats):
-        """
-        Post results at the end of the execution
-        """
-        if not self._is_test_execution():
-            return
-
-        self._display.banner("Publishing Trento results")
-        self._post_results()
+        self._print_partial_result({"host_id": host, "reachable": False, "msg": msg})
 
     def _all_vars(self, host=None, task=None):
         """
@@ -283,17 +282,6 @@ class CallbackModule(CallbackBase):
             task=task
         )
 
-    def _is_test_execution(self):
-        """
-        Check if the current execution is a trento test execution
-        """
-        play_vars = self._all_vars()
-        if TRENTO_TEST_LABEL_KEY not in play_vars or \
-                 TRENTO_TEST_LABEL not in play_vars[TRENTO_TEST_LABEL_KEY]:
-            self._display.banner("Not running a Trento test execution")
-            return False
-        return True
-
     def _is_test_result(self, result):
         """
         Check if the current task is a test result
@@ -317,6 +305,8 @@ class CallbackModule(CallbackBase):
         Store skipped checks
         """
         host = result._host.get_name()
+        task_vars = self._all_vars(host=result._host, task=result._task)
+        architecture = task_vars.get(ARCHITECTURE_FACT)
 
         for check_result in result._result["results"]:
             skipped = check_result.get("skipped", False)
@@ -328,18 +318,37 @@ class CallbackModule(CallbackBase):
                     check_id = data[CHECK_ID]
 
                 self.execution_results.add_host(host, True)
-                self.execution_results.add_result(host, check_id, "skipped")
+                self._add_result(host, check_id, "skipped", self._architecture_skip_reason(data, architecture))
 
-    def _post_results(self):
+    def _architecture_skip_reason(self, data, architecture):
         """
-        Post results to the trento web api server
+        Get why the check is skipped, if it is not supported in the host architecture
         """
-        callback_event = {
-            "execution_id": self._execution_id,
-            "event": EXECUTION_COMPLETED_EVENT,
-            "payload": self.execution_results.to_dict()
-        }
-        response = requests.post(self._callbacks_url, json=callback_event)
-        self._display.banner(
-            "Results of execution {} published. Return code is: {}".format(
-                self._execution_id, response.status_code))
+        architectures = [str(item) for item in data.get(CHECK_ARCHITECTURES) or []]
+        if not architectures or not architecture or architecture in architectures:
+            return ""
+
+        return ARCHITECTURE_SKIPPED_MSG.format(architecture, ", ".join(architectures))
+
+    def _add_result(self, host, check_id, result, msg=""):
+        """
+        Add check result, printing it as a partial result if it is new
+        """
+        if self.execution_results.add_result(host, check_id, result, msg):
+            self._print_partial_result({
+                "host_id": host,
+                "reachable": True,
+                "check_id": str(check_id),
+                "result": result,
+                "msg": msg
+            })
+
+    def _print_partial_result(self, partial_result):
+        """
+        Print the partial result in the stderr, if the partial results are enabled
+        """
+        if not self._partial_results:
+            return
+
+        sys.stderr.write(PARTIAL_RESULT_PREFIX + json.dumps(partial_result) + "\n")
+        sys.stderr.flush()
@@ -0,0 +1,134 @@
+package runner
+
+import (
+	"os"
+	"path"
+	"text/template"
+	"time"
+)
+
+const ansibleConfigTemplate = `[defaults]
+forks = {{ .Forks }}
+host_key_checking = {{ if .SSHHostKeyArgs }}True{{ else }}False{{ end }}
+{{- if .LocalTemp }}
+local_tmp = {{ .LocalTemp }}
+{{- end }}
+{{- if .GatherSubset }}
+gather_subset = {{ .GatherSubset }}
+{{- end }}
+{{- if .GalaxyDir }}
+collections_paths = {{ .GalaxyDir }}/collections:~/.ansible/collections:/usr/share/ansible/collections
+roles_path = {{ .GalaxyDir }}/roles:~/.ansible/roles:/usr/share/ansible/roles:/etc/ansible/roles
+{{- end }}
+{{- if .FactCache }}
+fact_caching = {{ .FactCache }}
+fact_caching_connection = {{ .FactCacheConnection }}
+fact_caching_timeout = {{ .FactCacheTimeout }}
+{{- end }}
+
+[ssh_connection]
+ssh_args = -o ControlMaster=auto -o ControlPersist={{ .ControlPersist }}s{{ if .SSHHostKeyArgs }} {{ .SSHHostKeyArgs }}{{ end }}
+control_path = %(directory)s/ansible-ssh-%%h-%%p-%%r
+{{- if .ControlPathDir }}
+control_path_dir = {{ .ControlPathDir }}
+{{- end }}
+pipelining = {{ if .Pipelining }}True{{ else }}False{{ end }}
+`
+
+// The ansible settings used if they are not configured
+var defaultAnsibleForks = 100
+var defaultAnsibleControlPersist = 5 * time.Minute
+
+type AnsibleConfigContent struct {
+	Forks          int
+	Pipelining     bool
+	ControlPersist int64
+	GatherSubset   string
+	// The gathered facts are kept in the fact cache for the timeout seconds, if it is set.
+	// The checks playbook only gathers the facts of the hosts not found in the cache
+	FactCache           string
+	FactCacheConnection string
+	FactCacheTimeout    int64
+	// The collections and roles installed from the requirements file are found in the galaxy folder
+	GalaxyDir string
+	// The local temporary files and the ssh control sockets are written in these folders, instead of the home folder.
+	// The executions write their temporary files in their work dirs anyway
+	LocalTemp      string
+	ControlPathDir string
+	// The hosts keys are verified with the known hosts file managed by the runner, if the host key checking is enabled
+	SSHHostKeyArgs string
+}
+
+func NewAnsibleConfigContent(config *Config) *AnsibleConfigContent {
+	content := &AnsibleConfigContent{
+		Forks:          config.AnsibleForks,
+		Pipelining:     !config.AnsibleDisablePipelining,
+		ControlPersist: int64(config.AnsibleControlPersist / time.Second),
+		GatherSubset:   config.AnsibleGatherSubset,
+		LocalTemp:      config.AnsibleLocalTemp,
+		ControlPathDir: config.AnsibleControlPathDir,
+	}
+
+	if config.AnsibleFactCache != "" {
+		content.FactCache = config.AnsibleFactCache
+		content.FactCacheConnection = factCacheConnection(config)
+		content.FactCacheTimeout = int64(config.AnsibleFactCacheTTL / time.Second)
+		if content.FactCacheTimeout <= 0 {
+			content.FactCacheTimeout = int64(DefaultAnsibleFactCacheTTL / time.Second)
+		}
+	}
+
+	if config.AnsibleRequirementsFile != "" {
+		content.GalaxyDir = galaxyDir(config)
+	}
+
+	if config.SSHHostKeyChecking != "" && config.SSHHostKeyChecking != HostKeyCheckingDisabled {
+		content.SSHHostKeyArgs = sshHostKeyArgs(config)
+	}
+
+	if content.Forks == 0 {
+		content.Forks = defaultAnsibleForks
+	}
+	if content.ControlPersist == 0 {
+		content.ControlPersist = int64(defaultAnsibleControlPersist / time.Second)
+	}
+
+	return content
+}
+
+// createAnsibleWritablePaths creates the configured ansible writable folders, only accessible by the runner user,
+// so a folder that cannot be written fails when the runner starts instead of in the middle of an execution
+func createAnsibleWritablePaths(config *Config) error {
+	folders := []string{config.AnsibleLocalTemp, config.AnsibleControlPathDir}
+	if config.AnsibleFactCache == JSONFileFactCache {
+		folders = append(folders, factCacheConnection(config))
+	}
+
+	for _, folder := range folders {
+		if folder == "" {
+			continue
+		}
+		if err := os.MkdirAll(folder, 0700); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CreateAnsibleConfig renders the ansible.cfg file used by the playbooks with the configured settings
+func CreateAnsibleConfig(destination string, content *AnsibleConfigContent) error {
+	t := template.Must(template.New("").Parse(ansibleConfigTemplate))
+
+	if err := os.MkdirAll(path.Dir(destination), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(destination)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return t.Execute(f, content)
+}
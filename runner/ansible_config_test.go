@@ -0,0 +1,153 @@
+package runner
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateAnsibleConfig(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	destination := path.Join(tmpDir, "ansible/ansible.cfg")
+	err := CreateAnsibleConfig(destination, NewAnsibleConfigContent(&Config{}))
+	assert.NoError(t, err)
+
+	content, _ := ioutil.ReadFile(destination)
+	expectedContent := "[defaults]\n" +
+		"forks = 100\n" +
+		"host_key_checking = False\n" +
+		"\n" +
+		"[ssh_connection]\n" +
+		"ssh_args = -o ControlMaster=auto -o ControlPersist=300s\n" +
+		"control_path = %(directory)s/ansible-ssh-%%h-%%p-%%r\n" +
+		"pipelining = True\n"
+	assert.Equal(t, expectedContent, string(content))
+}
+
+func TestCreateAnsibleConfig_Settings(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	config := &Config{
+		AnsibleForks:             250,
+		AnsibleDisablePipelining: true,
+		AnsibleControlPersist:    10 * time.Minute,
+		AnsibleGatherSubset:      "!hardware,!facter",
+	}
+
+	destination := path.Join(tmpDir, "ansible/ansible.cfg")
+	err := CreateAnsibleConfig(destination, NewAnsibleConfigContent(config))
+	assert.NoError(t, err)
+
+	content, _ := ioutil.ReadFile(destination)
+	assert.Contains(t, string(content), "forks = 250\n")
+	assert.Contains(t, string(content), "gather_subset = !hardware,!facter\n")
+	assert.Contains(t, string(content), "ControlPersist=600s")
+	assert.Contains(t, string(content), "pipelining = False\n")
+}
+
+func TestCreateAnsibleConfig_PasswordHosts(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	// The same configuration is used by the hosts with the ssh password authentication,
+	// so the ssh arguments do not restrict the authentication methods, ansible sets them in each host
+	execution := &ExecutionEvent{
+		Hosts:      []*Host{{HostID: uuid.New(), Address: "192.168.10.1", User: "trento"}},
+		Connection: &ConnectionOptions{SSHPasswordSecret: "host-ssh-password"},
+	}
+	assert.NoError(t, execution.validate())
+
+	destination := path.Join(tmpDir, "ansible/ansible.cfg")
+	config := &Config{AnsibleFolder: tmpDir, SSHHostKeyChecking: HostKeyCheckingTOFU}
+	assert.NoError(t, CreateAnsibleConfig(destination, NewAnsibleConfigContent(config)))
+
+	content, _ := ioutil.ReadFile(destination)
+	assert.NotContains(t, string(content), "PreferredAuthentications")
+	assert.NotContains(t, string(content), "PasswordAuthentication")
+	assert.Contains(t, string(content), "ssh_args = -o ControlMaster=auto -o ControlPersist=300s -o StrictHostKeyChecking=accept-new")
+}
+
+func TestCreateAnsibleConfig_FactCache(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	config := &Config{AnsibleFolder: "/usr/etc/trento", AnsibleFactCache: "jsonfile"}
+
+	destination := path.Join(tmpDir, "ansible/ansible.cfg")
+	err := CreateAnsibleConfig(destination, NewAnsibleConfigContent(config))
+	assert.NoError(t, err)
+
+	content, _ := ioutil.ReadFile(destination)
+	assert.Contains(t, string(content), "fact_caching = jsonfile\n"+
+		"fact_caching_connection = /usr/etc/trento/facts_cache\n"+
+		"fact_caching_timeout = 3600\n")
+
+	config = &Config{AnsibleFactCache: "redis", AnsibleFactCacheRedis: "localhost:6379:0", AnsibleFactCacheTTL: 2 * time.Hour}
+	err = CreateAnsibleConfig(destination, NewAnsibleConfigContent(config))
+	assert.NoError(t, err)
+
+	content, _ = ioutil.ReadFile(destination)
+	assert.Contains(t, string(content), "fact_caching = redis\n"+
+		"fact_caching_connection = localhost:6379:0\n"+
+		"fact_caching_timeout = 7200\n")
+}
+
+func TestCreateAnsibleConfig_WritablePaths(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	config := &Config{AnsibleLocalTemp: "/var/lib/trento/tmp", AnsibleControlPathDir: "/var/lib/trento/cp"}
+
+	destination := path.Join(tmpDir, "ansible/ansible.cfg")
+	err := CreateAnsibleConfig(destination, NewAnsibleConfigContent(config))
+	assert.NoError(t, err)
+
+	content, _ := ioutil.ReadFile(destination)
+	assert.Contains(t, string(content), "host_key_checking = False\n"+
+		"local_tmp = /var/lib/trento/tmp\n")
+	assert.Contains(t, string(content), "control_path = %(directory)s/ansible-ssh-%%h-%%p-%%r\n"+
+		"control_path_dir = /var/lib/trento/cp\n")
+}
+
+func TestCreateAnsibleWritablePaths(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	config := &Config{
+		AnsibleLocalTemp:      path.Join(tmpDir, "tmp"),
+		AnsibleControlPathDir: path.Join(tmpDir, "cp"),
+		AnsibleFactCache:      JSONFileFactCache,
+		AnsibleFactCacheDir:   path.Join(tmpDir, "facts"),
+	}
+	assert.NoError(t, createAnsibleWritablePaths(config))
+
+	for _, folder := range []string{"tmp", "cp", "facts"} {
+		info, err := os.Stat(path.Join(tmpDir, folder))
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+	}
+}
+
+func TestCreateAnsibleConfig_GalaxyRequirements(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	config := &Config{AnsibleFolder: "/usr/etc/trento", AnsibleRequirementsFile: "/etc/trento/requirements.yml"}
+
+	destination := path.Join(tmpDir, "ansible/ansible.cfg")
+	err := CreateAnsibleConfig(destination, NewAnsibleConfigContent(config))
+	assert.NoError(t, err)
+
+	content, _ := ioutil.ReadFile(destination)
+	assert.Contains(t, string(content),
+		"collections_paths = /usr/etc/trento/galaxy/collections:~/.ansible/collections:/usr/share/ansible/collections\n"+
+			"roles_path = /usr/etc/trento/galaxy/roles:~/.ansible/roles:/usr/share/ansible/roles:/etc/ansible/roles\n")
+}
@@ -0,0 +1,169 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	PodmanContainerRuntime = "podman"
+	DockerContainerRuntime = "docker"
+
+	sshAuthSockEnv = "SSH_AUTH_SOCK"
+)
+
+// AnsibleContainer runs ansible in a container of an image with ansible and the collections preinstalled,
+// so they are not needed in the runner host. The mounted paths are in the same location in the container,
+// and the environment variables are given to the container by name, not to expose the secrets in the process list
+type AnsibleContainer struct {
+	Runtime string
+	Image   string
+	// Name is the container name, so it can be removed if the execution is cancelled. The runtime names it if empty
+	Name   string
+	Mounts []*ContainerMount
+	// CPUs and MemoryMax limit the container resources, as the cgroup does in the runner host
+	CPUs      float64
+	MemoryMax int64
+}
+
+type ContainerMount struct {
+	Path     string
+	ReadOnly bool
+}
+
+// NewAnsibleContainer returns the container where ansible runs, or nil if ansible runs in the runner host.
+// The ansible folder is mounted with the credentials files and the ssh-agent socket, if they are used
+func NewAnsibleContainer(config *Config) *AnsibleContainer {
+	if config.AnsibleContainerImage == "" {
+		return nil
+	}
+
+	container := &AnsibleContainer{
+		Runtime:   config.AnsibleContainerRuntime,
+		Image:     config.AnsibleContainerImage,
+		CPUs:      config.AnsibleCPULimit,
+		MemoryMax: config.AnsibleMemoryLimit,
+	}
+	if container.Runtime == "" {
+		container.Runtime = PodmanContainerRuntime
+	}
+
+	// The catalog playbook writes the catalog in the ansible folder
+	container.addMount(config.AnsibleFolder, false)
+	// The executions write their logs and temporary files in their work dirs, in the ansible folder by default
+	container.addMount(config.WorkDir, false)
+	// The configured ansible writable folders, as the container root filesystem may be read-only
+	container.addMount(config.AnsibleLocalTemp, false)
+	container.addMount(config.AnsibleControlPathDir, false)
+	container.addMount(config.AnsibleFactCacheDir, false)
+	container.addMount(config.SSHPrivateKeyFile, true)
+	// ssh checks the hosts keys with the known hosts file managed by the runner
+	if config.SSHHostKeyChecking != "" && config.SSHHostKeyChecking != HostKeyCheckingDisabled {
+		container.addMount(knownHostsFile(config), true)
+	}
+	container.addMount(config.VaultPasswordFile, true)
+	for _, vaultID := range config.VaultIDs {
+		if label := strings.SplitN(vaultID, "@", 2); len(label) == 2 {
+			container.addMount(label[1], true)
+		}
+	}
+	container.addMount(os.Getenv(sshAuthSockEnv), false)
+
+	return container
+}
+
+func (c *AnsibleContainer) addMount(mountPath string, readOnly bool) {
+	if mountPath == "" {
+		return
+	}
+
+	for _, mount := range c.Mounts {
+		if mount.Path == mountPath {
+			return
+		}
+	}
+
+	c.Mounts = append(c.Mounts, &ContainerMount{Path: mountPath, ReadOnly: readOnly})
+}
+
+// command returns the container runtime command running the given command and arguments in the container.
+// The relative paths are found in the container as the working directory is the current one
+func (c *AnsibleContainer) command(name string, args []string, envs map[string]string) (string, []string, error) {
+	workdir, err := os.Getwd()
+	if err != nil {
+		return "", nil, err
+	}
+
+	runArgs := []string{"run", "--rm"}
+	if c.Name != "" {
+		runArgs = append(runArgs, fmt.Sprintf("--name=%s", c.Name))
+	}
+	runArgs = append(runArgs, "--network=host", fmt.Sprintf("--workdir=%s", workdir))
+
+	if c.CPUs > 0 {
+		runArgs = append(runArgs, fmt.Sprintf("--cpus=%s", strconv.FormatFloat(c.CPUs, 'f', -1, 64)))
+	}
+	if c.MemoryMax > 0 {
+		runArgs = append(runArgs, fmt.Sprintf("--memory=%d", c.MemoryMax))
+	}
+
+	for _, mount := range c.Mounts {
+		mountPath, err := filepath.Abs(mount.Path)
+		if err != nil {
+			return "", nil, err
+		}
+
+		volume := fmt.Sprintf("--volume=%s:%s", mountPath, mountPath)
+		if mount.ReadOnly {
+			volume += ":ro"
+		}
+		runArgs = append(runArgs, volume)
+	}
+
+	names := []string{}
+	for envName := range envs {
+		names = append(names, envName)
+	}
+	if os.Getenv(sshAuthSockEnv) != "" {
+		names = append(names, sshAuthSockEnv)
+	}
+	sort.Strings(names)
+	for _, envName := range names {
+		runArgs = append(runArgs, fmt.Sprintf("--env=%s", envName))
+	}
+
+	runArgs = append(runArgs, c.Image, name)
+
+	return c.Runtime, append(runArgs, args...), nil
+}
+
+// removeOnCancel removes the container once the context is done, as terminating the runtime client does not always
+// stop the container, e.g. with docker. The returned function stops waiting for the context
+func (c *AnsibleContainer) removeOnCancel(ctx context.Context) func() {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		select {
+		case <-ctx.Done():
+			logger := loggerFromContext(ctx)
+			logger.Warnf("Removing the container %s: %s", c.Name, ctx.Err())
+			if output, err := customExecCommand(c.Runtime, "rm", "-f", c.Name).CombinedOutput(); err != nil {
+				logger.Errorf("Error removing the container %s: %s %s", c.Name, err, strings.TrimSpace(string(output)))
+			}
+		case <-done:
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
@@ -0,0 +1,163 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trento-project/runner/runner/mocks"
+)
+
+func TestNewAnsibleContainer(t *testing.T) {
+	assert.Nil(t, NewAnsibleContainer(&Config{AnsibleFolder: "/tmp/trento"}))
+
+	defer os.Setenv("SSH_AUTH_SOCK", os.Getenv("SSH_AUTH_SOCK"))
+	os.Setenv("SSH_AUTH_SOCK", "/run/user/1000/ssh-agent.sock")
+
+	container := NewAnsibleContainer(&Config{
+		AnsibleFolder:         "/tmp/trento",
+		AnsibleContainerImage: "registry.example.com/trento-ansible:1.0.0",
+		SSHPrivateKeyFile:     "/etc/trento/id_rsa",
+		VaultPasswordFile:     "/etc/trento/vault_password",
+		VaultIDs:              []string{"hana@/etc/trento/hana_password", "aws@/etc/trento/vault_password"},
+		AnsibleLocalTemp:      "/var/lib/trento/tmp",
+		AnsibleControlPathDir: "/var/lib/trento/cp",
+		SSHHostKeyChecking:    HostKeyCheckingStrict,
+		SSHKnownHostsFile:     "/var/lib/trento/known_hosts",
+	})
+
+	expectedContainer := &AnsibleContainer{
+		Runtime: "podman",
+		Image:   "registry.example.com/trento-ansible:1.0.0",
+		Mounts: []*ContainerMount{
+			{Path: "/tmp/trento"},
+			{Path: "/var/lib/trento/tmp"},
+			{Path: "/var/lib/trento/cp"},
+			{Path: "/etc/trento/id_rsa", ReadOnly: true},
+			{Path: "/var/lib/trento/known_hosts", ReadOnly: true},
+			{Path: "/etc/trento/vault_password", ReadOnly: true},
+			{Path: "/etc/trento/hana_password", ReadOnly: true},
+			{Path: "/run/user/1000/ssh-agent.sock"},
+		},
+	}
+	assert.Equal(t, expectedContainer, container)
+
+	// The default known hosts file is in the ansible folder
+	container = NewAnsibleContainer(&Config{
+		AnsibleFolder:         "/tmp/trento",
+		AnsibleContainerImage: "registry.example.com/trento-ansible:1.0.0",
+		SSHHostKeyChecking:    HostKeyCheckingTOFU,
+	})
+	assert.Contains(t, container.Mounts, &ContainerMount{Path: "/tmp/trento/known_hosts", ReadOnly: true})
+}
+
+func TestRunPlaybookContainer(t *testing.T) {
+	workdir, _ := os.Getwd()
+	defer os.Setenv("SSH_AUTH_SOCK", os.Getenv("SSH_AUTH_SOCK"))
+	os.Unsetenv("SSH_AUTH_SOCK")
+
+	runnerInst := &AnsibleRunner{
+		Playbook: "/tmp/trento/ansible/check.yml",
+		Envs:     map[string]string{"TRENTO_SSH_PASSPHRASE": "secret", "ANSIBLE_CONFIG": "/tmp/trento/ansible/ansible.cfg"},
+		Check:    true,
+		Container: &AnsibleContainer{
+			Runtime: "docker",
+			Image:   "registry.example.com/trento-ansible:1.0.0",
+			Mounts: []*ContainerMount{
+				{Path: "/tmp/trento"},
+				{Path: "/etc/trento/id_rsa", ReadOnly: true},
+			},
+		},
+	}
+
+	cmd := exec.Command("ls")
+
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+	mockCommand.On(
+		"Execute",
+		"docker",
+		"run",
+		"--rm",
+		"--network=host",
+		"--workdir="+workdir,
+		"--volume=/tmp/trento:/tmp/trento",
+		"--volume=/etc/trento/id_rsa:/etc/trento/id_rsa:ro",
+		"--env=ANSIBLE_CONFIG",
+		"--env=TRENTO_SSH_PASSPHRASE",
+		"registry.example.com/trento-ansible:1.0.0",
+		"ansible-playbook",
+		"/tmp/trento/ansible/check.yml",
+		"--check",
+	).Return(cmd)
+
+	err := runnerInst.RunPlaybook()
+
+	assert.NoError(t, err)
+	// The values are given to the runtime environment, the container takes them by name
+	assert.Contains(t, cmd.Env, "TRENTO_SSH_PASSPHRASE=secret")
+
+	mockCommand.AssertExpectations(t)
+}
+
+func TestRunPlaybookContainerCancelled(t *testing.T) {
+	workdir, _ := os.Getwd()
+	defer os.Setenv("SSH_AUTH_SOCK", os.Getenv("SSH_AUTH_SOCK"))
+	os.Unsetenv("SSH_AUTH_SOCK")
+
+	runnerInst := &AnsibleRunner{
+		Playbook:  "/tmp/trento/ansible/check.yml",
+		Envs:      map[string]string{},
+		Container: &AnsibleContainer{Runtime: "docker", Image: "registry.example.com/trento-ansible:1.0.0", Name: "trento-execution1"},
+	}
+
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+	defer func() { customExecCommand = exec.Command }()
+	mockCommand.On(
+		"Execute",
+		"docker",
+		"run",
+		"--rm",
+		"--name=trento-execution1",
+		"--network=host",
+		"--workdir="+workdir,
+		"registry.example.com/trento-ansible:1.0.0",
+		"ansible-playbook",
+		"/tmp/trento/ansible/check.yml",
+	).Return(exec.Command("sleep", "10"))
+	mockCommand.On("Execute", "docker", "rm", "-f", "trento-execution1").Return(exec.Command("true"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := runnerInst.RunPlaybookContext(ctx)
+
+	// The container is removed, as the runtime client being terminated does not stop it
+	assert.EqualError(t, err, "signal: terminated")
+	mockCommand.AssertExpectations(t)
+}
+
+func TestAnsibleContainerLimits(t *testing.T) {
+	workdir, _ := os.Getwd()
+	defer os.Setenv("SSH_AUTH_SOCK", os.Getenv("SSH_AUTH_SOCK"))
+	os.Unsetenv("SSH_AUTH_SOCK")
+
+	container := NewAnsibleContainer(&Config{
+		AnsibleFolder:         "/tmp/trento",
+		AnsibleContainerImage: "registry.example.com/trento-ansible:1.0.0",
+		AnsibleCPULimit:       1.5,
+		AnsibleMemoryLimit:    1 << 30,
+	})
+
+	name, args, err := container.command("ansible-playbook", []string{"check.yml"}, map[string]string{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "podman", name)
+	assert.Equal(t, []string{
+		"run", "--rm", "--network=host", "--workdir=" + workdir, "--cpus=1.5", "--memory=1073741824",
+		"--volume=/tmp/trento:/tmp/trento", "registry.example.com/trento-ansible:1.0.0", "ansible-playbook", "check.yml",
+	}, args)
+}
@@ -0,0 +1,281 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	DefaultAnsibleMinVersion = "2.11"
+
+	// AnsibleGalaxyDir is where the missing collections and roles are installed. It is out of the
+	// ansible files folder, so they are kept when the runner restarts
+	AnsibleGalaxyDir = "galaxy"
+)
+
+var ansibleVersionRegexp = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// The collections tarballs are named as namespace-name-version.tar.gz
+var collectionTarballRegexp = regexp.MustCompile(`^([a-z0-9_]+)-([a-z0-9_]+)-[^-]+\.tar\.gz$`)
+
+// galaxyRequirements is an ansible-galaxy requirements file. The collections and roles
+// are names, or objects with the name and the source of the tarball or the galaxy server
+type galaxyRequirements struct {
+	Collections []interface{} `yaml:"collections"`
+	Roles       []interface{} `yaml:"roles"`
+}
+
+// CheckAnsibleDependencies verifies that ansible-playbook is available, with the minimum version, and that the
+// collections and roles of the requirements file are installed. The missing ones are installed with ansible-galaxy
+// if it is enabled. The errors tell what is missing, instead of failing later in the playbooks
+func CheckAnsibleDependencies(ctx context.Context, config *Config) error {
+	logger := loggerFromContext(ctx)
+
+	if config.AnsibleMinVersion != "" {
+		version, err := ansiblePlaybookVersion(ctx, config)
+		if err != nil {
+			return err
+		}
+		if compareVersions(version, config.AnsibleMinVersion) < 0 {
+			return fmt.Errorf(
+				"ansible %s is installed, but %s or newer is required: upgrade ansible or use ansible-container-image",
+				version, config.AnsibleMinVersion)
+		}
+		logger.Infof("Found ansible %s", version)
+	}
+
+	if config.AnsibleRequirementsFile == "" {
+		return nil
+	}
+
+	requirements, err := readGalaxyRequirements(config.AnsibleRequirementsFile)
+	if err != nil {
+		return err
+	}
+
+	missingCollections, missingRoles, err := missingGalaxyRequirements(ctx, config, requirements)
+	if err != nil {
+		return err
+	}
+	if len(missingCollections) == 0 && len(missingRoles) == 0 {
+		return nil
+	}
+
+	missing := strings.Join(append(missingCollections, missingRoles...), ", ")
+	if !config.AnsibleInstallRequirements {
+		return fmt.Errorf(
+			"the ansible requirements %s are not installed: install them with ansible-galaxy or enable ansible-install-requirements",
+			missing)
+	}
+
+	logger.Infof("Installing the missing ansible requirements: %s", missing)
+	if len(missingCollections) > 0 {
+		if err := installGalaxyRequirements(ctx, config, "collection", "collections"); err != nil {
+			return err
+		}
+	}
+	if len(missingRoles) > 0 {
+		if err := installGalaxyRequirements(ctx, config, "role", "roles"); err != nil {
+			return err
+		}
+	}
+
+	missingCollections, missingRoles, err = missingGalaxyRequirements(ctx, config, requirements)
+	if err != nil {
+		return err
+	}
+	if len(missingCollections) > 0 || len(missingRoles) > 0 {
+		return fmt.Errorf(
+			"the ansible requirements %s are still missing after installing them",
+			strings.Join(append(missingCollections, missingRoles...), ", "))
+	}
+
+	return nil
+}
+
+// galaxyDir returns the folder where the ansible-galaxy collections and roles are installed
+func galaxyDir(config *Config) string {
+	return path.Join(config.AnsibleFolder, AnsibleGalaxyDir)
+}
+
+// ansiblePlaybookVersion returns the version in the first line of the ansible-playbook --version output,
+// as "ansible-playbook [core 2.11.5]", or "ansible-playbook 2.9.27" in the old versions
+func ansiblePlaybookVersion(ctx context.Context, config *Config) (string, error) {
+	output, err := runAnsibleCommand(ctx, config, "ansible-playbook", "--version")
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return "", fmt.Errorf(
+				"ansible-playbook was not found in the PATH: install ansible %s or newer, or use ansible-container-image",
+				config.AnsibleMinVersion)
+		}
+		return "", fmt.Errorf("error getting the ansible version: %w", err)
+	}
+
+	firstLine := strings.SplitN(string(output), "\n", 2)[0]
+	version := ansibleVersionRegexp.FindString(firstLine)
+	if version == "" {
+		return "", fmt.Errorf("could not find the ansible version in %q", firstLine)
+	}
+
+	return version, nil
+}
+
+func readGalaxyRequirements(requirementsFile string) (*galaxyRequirements, error) {
+	content, err := ioutil.ReadFile(requirementsFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the ansible requirements file: %w", err)
+	}
+
+	requirements := &galaxyRequirements{}
+	if err := yaml.Unmarshal(content, requirements); err != nil {
+		return nil, fmt.Errorf("the ansible requirements file %s is not valid: %w", requirementsFile, err)
+	}
+
+	return requirements, nil
+}
+
+// requirementName returns the name of a collection or role requirement. The collections
+// installed from a tarball are named after the tarball file
+func requirementName(requirement interface{}) string {
+	var name string
+	switch value := requirement.(type) {
+	case string:
+		name = value
+	case map[interface{}]interface{}:
+		if value["name"] != nil {
+			name = fmt.Sprint(value["name"])
+		} else if value["src"] != nil {
+			name = fmt.Sprint(value["src"])
+		}
+	}
+
+	if match := collectionTarballRegexp.FindStringSubmatch(path.Base(name)); match != nil {
+		return match[1] + "." + match[2]
+	}
+
+	return name
+}
+
+// missingGalaxyRequirements returns the names of the required collections and roles not installed
+func missingGalaxyRequirements(
+	ctx context.Context, config *Config, requirements *galaxyRequirements) ([]string, []string, error) {
+
+	var missingCollections, missingRoles []string
+
+	if len(requirements.Collections) > 0 {
+		installed, err := installedCollections(ctx, config)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, collection := range requirements.Collections {
+			if name := requirementName(collection); !installed[name] {
+				missingCollections = append(missingCollections, name)
+			}
+		}
+	}
+
+	if len(requirements.Roles) > 0 {
+		installed, err := installedRoles(ctx, config)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, role := range requirements.Roles {
+			if name := requirementName(role); !installed[name] {
+				missingRoles = append(missingRoles, name)
+			}
+		}
+	}
+
+	return missingCollections, missingRoles, nil
+}
+
+// installedCollections returns the collections found by ansible-galaxy in the collections paths
+func installedCollections(ctx context.Context, config *Config) (map[string]bool, error) {
+	output, err := runAnsibleCommand(ctx, config, "ansible-galaxy", "collection", "list", "--format=json")
+	if err != nil {
+		return nil, fmt.Errorf("error listing the installed ansible collections: %w", err)
+	}
+
+	var paths map[string]map[string]interface{}
+	if err := json.Unmarshal(output, &paths); err != nil {
+		return nil, fmt.Errorf("error parsing the installed ansible collections: %w", err)
+	}
+
+	installed := make(map[string]bool)
+	for _, collections := range paths {
+		for name := range collections {
+			installed[name] = true
+		}
+	}
+
+	return installed, nil
+}
+
+// installedRoles returns the roles found by ansible-galaxy in the roles paths, listed as "- name, version"
+func installedRoles(ctx context.Context, config *Config) (map[string]bool, error) {
+	output, err := runAnsibleCommand(ctx, config, "ansible-galaxy", "role", "list")
+	if err != nil {
+		return nil, fmt.Errorf("error listing the installed ansible roles: %w", err)
+	}
+
+	installed := make(map[string]bool)
+	for _, line := range strings.Split(string(output), "\n") {
+		if !strings.HasPrefix(line, "- ") {
+			continue
+		}
+		name := strings.TrimSpace(strings.SplitN(strings.TrimPrefix(line, "- "), ",", 2)[0])
+		installed[name] = true
+	}
+
+	return installed, nil
+}
+
+// installGalaxyRequirements installs the collections or roles of the requirements file in the galaxy folder,
+// from the configured galaxy server, or the default one
+func installGalaxyRequirements(ctx context.Context, config *Config, kind, folder string) error {
+	args := []string{kind, "install", "-r", config.AnsibleRequirementsFile, "-p", path.Join(galaxyDir(config), folder)}
+	if config.AnsibleGalaxyServer != "" {
+		args = append(args, fmt.Sprintf("--server=%s", config.AnsibleGalaxyServer))
+	}
+
+	if _, err := runAnsibleCommand(ctx, config, "ansible-galaxy", args...); err != nil {
+		return fmt.Errorf("error installing the ansible %s requirements: %w", kind, err)
+	}
+
+	return nil
+}
+
+// runAnsibleCommand runs an ansible command with the runner ansible configuration, in the ansible container if it is used
+func runAnsibleCommand(ctx context.Context, config *Config, name string, args ...string) ([]byte, error) {
+	envs := map[string]string{AnsibleConfigFileEnv: path.Join(config.AnsibleFolder, AnsibleConfigFile)}
+	// ansible-galaxy downloads the requirements through the runner proxy
+	for name, value := range proxyEnvs(config) {
+		envs[name] = value
+	}
+
+	if container := NewAnsibleContainer(config); container != nil {
+		var err error
+		name, args, err = container.command(name, args, envs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cmd := customExecCommand(name, args...)
+	cmd.Env = os.Environ()
+	for envName, value := range envs {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", envName, value))
+	}
+
+	return runCommand(ctx, cmd, nil, loggerFromContext(ctx).Debugf, nil)
+}
@@ -0,0 +1,133 @@
+package runner
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/trento-project/runner/runner/mocks"
+)
+
+const testRequirements = `collections:
+  - community.general
+  - name: /opt/trento/sap-hana-1.2.0.tar.gz
+    type: file
+roles:
+  - name: trento.hana
+`
+
+func TestCheckAnsibleDependenciesVersion(t *testing.T) {
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+	mockCommand.On("Execute", "ansible-playbook", "--version").Return(
+		exec.Command("echo", "ansible-playbook [core 2.11.5]\n  config file = None"))
+
+	err := CheckAnsibleDependencies(context.Background(), &Config{AnsibleFolder: "/tmp/trento", AnsibleMinVersion: "2.11"})
+
+	assert.NoError(t, err)
+	mockCommand.AssertExpectations(t)
+}
+
+func TestCheckAnsibleDependenciesOldVersion(t *testing.T) {
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+	mockCommand.On("Execute", "ansible-playbook", "--version").Return(
+		exec.Command("echo", "ansible-playbook 2.9.27"))
+
+	err := CheckAnsibleDependencies(context.Background(), &Config{AnsibleFolder: "/tmp/trento", AnsibleMinVersion: "2.11"})
+
+	assert.EqualError(
+		t, err, "ansible 2.9.27 is installed, but 2.11 or newer is required: upgrade ansible or use ansible-container-image")
+}
+
+func TestCheckAnsibleDependenciesNotFound(t *testing.T) {
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+	mockCommand.On("Execute", "ansible-playbook", "--version").Return(exec.Command("not-existing-ansible-playbook"))
+
+	err := CheckAnsibleDependencies(context.Background(), &Config{AnsibleFolder: "/tmp/trento", AnsibleMinVersion: "2.11"})
+
+	assert.EqualError(
+		t, err, "ansible-playbook was not found in the PATH: install ansible 2.11 or newer, or use ansible-container-image")
+}
+
+func TestCheckAnsibleDependenciesRequirements(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+	requirementsFile := path.Join(tmpDir, "requirements.yml")
+	ioutil.WriteFile(requirementsFile, []byte(testRequirements), 0644)
+
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+	mockCommand.On("Execute", "ansible-galaxy", "collection", "list", "--format=json").Return(
+		exec.Command("echo", `{"/usr/share/ansible/collections": {"community.general": {"version": "3.8.0"}, "sap.hana": {"version": "1.2.0"}}}`))
+	mockCommand.On("Execute", "ansible-galaxy", "role", "list").Return(
+		exec.Command("echo", "# /etc/ansible/roles\n- trento.hana, 1.0.0"))
+
+	err := CheckAnsibleDependencies(
+		context.Background(), &Config{AnsibleFolder: tmpDir, AnsibleRequirementsFile: requirementsFile})
+
+	assert.NoError(t, err)
+	mockCommand.AssertExpectations(t)
+}
+
+func TestCheckAnsibleDependenciesMissingRequirements(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+	requirementsFile := path.Join(tmpDir, "requirements.yml")
+	ioutil.WriteFile(requirementsFile, []byte(testRequirements), 0644)
+
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+	mockCommand.On("Execute", "ansible-galaxy", "collection", "list", "--format=json").Return(
+		exec.Command("echo", `{"/usr/share/ansible/collections": {"community.general": {"version": "3.8.0"}}}`))
+	mockCommand.On("Execute", "ansible-galaxy", "role", "list").Return(exec.Command("echo", ""))
+
+	err := CheckAnsibleDependencies(
+		context.Background(), &Config{AnsibleFolder: tmpDir, AnsibleRequirementsFile: requirementsFile})
+
+	assert.EqualError(
+		t, err,
+		"the ansible requirements sap.hana, trento.hana are not installed: "+
+			"install them with ansible-galaxy or enable ansible-install-requirements")
+}
+
+func TestCheckAnsibleDependenciesInstallRequirements(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+	requirementsFile := path.Join(tmpDir, "requirements.yml")
+	ioutil.WriteFile(requirementsFile, []byte("collections:\n  - community.general\n"), 0644)
+
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+	mockCommand.On("Execute", "ansible-galaxy", "collection", "list", "--format=json").Return(
+		exec.Command("echo", `{}`)).Once()
+	mockCommand.On(
+		"Execute", "ansible-galaxy", "collection", "install", "-r", requirementsFile,
+		"-p", path.Join(tmpDir, "galaxy/collections"), "--server=https://galaxy.example.com").Return(
+		exec.Command("echo", "community.general was installed successfully"))
+	mockCommand.On("Execute", "ansible-galaxy", "collection", "list", "--format=json").Return(
+		exec.Command("echo", `{"`+path.Join(tmpDir, "galaxy/collections")+`": {"community.general": {"version": "3.8.0"}}}`)).Once()
+
+	err := CheckAnsibleDependencies(context.Background(), &Config{
+		AnsibleFolder:              tmpDir,
+		AnsibleRequirementsFile:    requirementsFile,
+		AnsibleInstallRequirements: true,
+		AnsibleGalaxyServer:        "https://galaxy.example.com",
+	})
+
+	assert.NoError(t, err)
+	mockCommand.AssertExpectations(t)
+}
+
+func TestRequirementName(t *testing.T) {
+	assert.Equal(t, "community.general", requirementName("community.general"))
+	assert.Equal(t, "sap.hana", requirementName(map[interface{}]interface{}{"name": "/opt/sap-hana-1.2.0.tar.gz", "type": "file"}))
+	assert.Equal(t, "trento.hana", requirementName(map[interface{}]interface{}{"name": "trento.hana", "version": "1.0.0"}))
+	assert.Equal(t, "https://github.com/trento/role", requirementName(map[interface{}]interface{}{"src": "https://github.com/trento/role"}))
+}
@@ -0,0 +1,182 @@
+package runner
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrAnsibleFilesModified = errors.New("The ansible files were modified after they were extracted, restart the runner to extract them again")
+var ErrAnsibleFilesIncomplete = errors.New("The ansible files were not completely extracted")
+
+// The files written in the ansible files folder while the runner works, which are not in the manifest
+var manifestIgnoredFiles = []string{
+	CatalogDestinationFile,
+	CatalogDestinationFile + catalogTemporarySuffix,
+	AnsibleSSHAskPass,
+}
+
+// AnsibleManifest has the sha256 of each file of the ansible files folder, by path
+type AnsibleManifest map[string]string
+
+// NewAnsibleManifest computes the manifest of the ansible files extracted in the given folder
+func NewAnsibleManifest(folder string) (AnsibleManifest, error) {
+	manifest := make(AnsibleManifest)
+	err := filepath.WalkDir(path.Join(folder, "ansible"), func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(folder, filePath)
+		if err != nil {
+			return err
+		}
+		if manifestIgnored(relativePath, entry) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		content, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		manifest[relativePath] = fmt.Sprintf("%x", sha256.Sum256(content))
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// embeddedAnsibleManifest computes the manifest of the ansible files embedded in the runner binary
+func embeddedAnsibleManifest() (AnsibleManifest, error) {
+	manifest := make(AnsibleManifest)
+	err := fs.WalkDir(ansibleFS, "ansible", func(fileName string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		content, err := ansibleFS.ReadFile(fileName)
+		if err != nil {
+			return err
+		}
+		manifest[fileName] = fmt.Sprintf("%x", sha256.Sum256(content))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// manifestIgnored tells if the file is written by ansible or the runner after the files are extracted,
+// as the catalog, the python bytecode or the retry files
+func manifestIgnored(relativePath string, entry fs.DirEntry) bool {
+	if entry.IsDir() {
+		return entry.Name() == "__pycache__" || relativePath == AnsibleInventories
+	}
+
+	for _, ignored := range manifestIgnoredFiles {
+		if relativePath == ignored {
+			return true
+		}
+	}
+
+	return strings.HasSuffix(relativePath, ".pyc") || strings.HasSuffix(relativePath, ".retry")
+}
+
+// Digest identifies the whole manifest, so the extracted files of different runners can be compared in their logs
+func (m AnsibleManifest) Digest() string {
+	hash := sha256.New()
+	for _, fileName := range m.files() {
+		fmt.Fprintf(hash, "%s  %s\n", m[fileName], fileName)
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
+
+func (m AnsibleManifest) files() []string {
+	files := make([]string, 0, len(m))
+	for fileName := range m {
+		files = append(files, fileName)
+	}
+	sort.Strings(files)
+
+	return files
+}
+
+// Log writes the manifest digest, and the hash of each file in debug level
+func (m AnsibleManifest) Log() {
+	log.Infof("Ansible files manifest: %d files, sha256 %s", len(m), m.Digest())
+	for _, fileName := range m.files() {
+		log.Debugf("%s  %s", m[fileName], fileName)
+	}
+}
+
+// Verify compares the ansible files of the folder with the manifest, failing with the modified,
+// removed and added files if they are not the same
+func (m AnsibleManifest) Verify(folder string) error {
+	current, err := NewAnsibleManifest(folder)
+	if err != nil {
+		return err
+	}
+
+	return m.compare(current, true, ErrAnsibleFilesModified)
+}
+
+// compare returns the files of the manifest that are not the same in the current one. The files that
+// are only in the current one are reported as well if they are not expected
+func (m AnsibleManifest) compare(current AnsibleManifest, reportAdded bool, sentinel error) error {
+	problems := []string{}
+	for _, fileName := range m.files() {
+		hash, ok := current[fileName]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s removed", fileName))
+		} else if hash != m[fileName] {
+			problems = append(problems, fmt.Sprintf("%s modified", fileName))
+		}
+	}
+	if reportAdded {
+		for _, fileName := range current.files() {
+			if _, ok := m[fileName]; !ok {
+				problems = append(problems, fmt.Sprintf("%s added", fileName))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", sentinel, strings.Join(problems, ", "))
+	}
+
+	return nil
+}
+
+// verifyExtractedFiles checks that the embedded ansible files were completely written in the folder
+func verifyExtractedFiles(folder string) error {
+	embedded, err := embeddedAnsibleManifest()
+	if err != nil {
+		return err
+	}
+	extracted, err := NewAnsibleManifest(folder)
+	if err != nil {
+		return err
+	}
+
+	return embedded.compare(extracted, false, ErrAnsibleFilesIncomplete)
+}
@@ -0,0 +1,66 @@
+package runner
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnsibleManifest(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	os.MkdirAll(path.Join(tmpDir, "ansible/roles/checks/156F64/tasks"), 0755)
+	ioutil.WriteFile(path.Join(tmpDir, "ansible/check.yml"), []byte("- hosts: all\n"), 0644)
+	ioutil.WriteFile(path.Join(tmpDir, "ansible/roles/checks/156F64/tasks/main.yml"), []byte("---\n"), 0644)
+
+	manifest, err := NewAnsibleManifest(tmpDir)
+	assert.NoError(t, err)
+	assert.Equal(t, AnsibleManifest{
+		"ansible/check.yml":                          "b5760e64952befe7cf7ff8966dd9ecdc5d901bab0b2a60dae17d495ef5786c63",
+		"ansible/roles/checks/156F64/tasks/main.yml": "f52d711103d50a437830c6fbcd04fb4bab49a0f82f6d26d1c791c6e8488dd090",
+	}, manifest)
+	assert.NoError(t, manifest.Verify(tmpDir))
+
+	// The files written while the runner works are not verified
+	ioutil.WriteFile(path.Join(tmpDir, "ansible/catalog.json"), []byte("[]"), 0644)
+	os.MkdirAll(path.Join(tmpDir, "ansible/callback_plugins/__pycache__"), 0755)
+	ioutil.WriteFile(path.Join(tmpDir, "ansible/callback_plugins/__pycache__/trento.pyc"), []byte("bytecode"), 0644)
+	assert.NoError(t, manifest.Verify(tmpDir))
+
+	ioutil.WriteFile(path.Join(tmpDir, "ansible/check.yml"), []byte("- hosts: localhost\n"), 0644)
+	os.Remove(path.Join(tmpDir, "ansible/roles/checks/156F64/tasks/main.yml"))
+	ioutil.WriteFile(path.Join(tmpDir, "ansible/roles/checks/156F64/tasks/extra.yml"), []byte("---\n"), 0644)
+
+	err = manifest.Verify(tmpDir)
+	assert.True(t, errors.Is(err, ErrAnsibleFilesModified))
+	assert.EqualError(t, err, "The ansible files were modified after they were extracted, restart the runner to extract them again: "+
+		"ansible/check.yml modified, ansible/roles/checks/156F64/tasks/main.yml removed, ansible/roles/checks/156F64/tasks/extra.yml added")
+}
+
+func TestAnsibleManifestDigest(t *testing.T) {
+	manifest := AnsibleManifest{"ansible/check.yml": "8ad5", "ansible/meta.yml": "5f2a"}
+	assert.Equal(t, manifest.Digest(), AnsibleManifest{"ansible/meta.yml": "5f2a", "ansible/check.yml": "8ad5"}.Digest())
+	assert.NotEqual(t, manifest.Digest(), AnsibleManifest{"ansible/check.yml": "8ad5"}.Digest())
+}
+
+func TestVerifyExtractedFiles(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	assert.NoError(t, createAnsibleFiles(tmpDir))
+	assert.NoError(t, verifyExtractedFiles(tmpDir))
+
+	// The checks copied on top of the embedded ones are expected
+	os.MkdirAll(path.Join(tmpDir, "ansible/roles/checks/custom"), 0755)
+	assert.NoError(t, verifyExtractedFiles(tmpDir))
+
+	ioutil.WriteFile(path.Join(tmpDir, "ansible/check.yml"), []byte("- hosts"), 0644)
+	err := verifyExtractedFiles(tmpDir)
+	assert.True(t, errors.Is(err, ErrAnsibleFilesIncomplete))
+	assert.EqualError(t, err, "The ansible files were not completely extracted: ansible/check.yml modified")
+}
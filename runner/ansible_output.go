@@ -0,0 +1,469 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// Task names used by the checks playbook. Do not change them, they are used in the trento callback as well
+	testResultTaskName  = "set_test_result"
+	testIncludeTaskName = "run_checks"
+	testResultFact      = "test_result"
+	testCheckIDFact     = "test_check_id"
+	testIncludeLoopVar  = "check_item"
+	checkMetadataFile   = "defaults/main.yml"
+	checkResultSkipped  = "skipped"
+)
+
+// PlaybookResults is the output of the ansible json stdout callback
+type PlaybookResults struct {
+	Plays []*PlayResults        `json:"plays"`
+	Stats map[string]*HostStats `json:"stats"`
+}
+
+type PlayResults struct {
+	Play  *PlayInfo      `json:"play"`
+	Tasks []*TaskResults `json:"tasks"`
+}
+
+type PlayInfo struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Duration *Duration `json:"duration"`
+}
+
+type TaskResults struct {
+	Task  *TaskInfo                  `json:"task"`
+	Hosts map[string]*TaskHostResult `json:"hosts"`
+}
+
+type TaskInfo struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Duration *Duration `json:"duration"`
+}
+
+type Duration struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+type TaskHostResult struct {
+	Action       string                   `json:"action"`
+	Changed      bool                     `json:"changed"`
+	Failed       bool                     `json:"failed"`
+	Skipped      bool                     `json:"skipped"`
+	Unreachable  bool                     `json:"unreachable"`
+	Msg          interface{}              `json:"msg"`
+	Rc           int                      `json:"rc"`
+	Stdout       string                   `json:"stdout"`
+	Stderr       string                   `json:"stderr"`
+	AnsibleFacts map[string]interface{}   `json:"ansible_facts"`
+	Results      []map[string]interface{} `json:"results"`
+}
+
+type HostStats struct {
+	Ok          int `json:"ok"`
+	Changed     int `json:"changed"`
+	Failures    int `json:"failures"`
+	Skipped     int `json:"skipped"`
+	Unreachable int `json:"unreachable"`
+	Rescued     int `json:"rescued"`
+	Ignored     int `json:"ignored"`
+}
+
+// Message returns the task message as a string, as ansible might return it as a list as well
+func (r *TaskHostResult) Message() string {
+	switch msg := r.Msg.(type) {
+	case nil:
+		return ""
+	case string:
+		return msg
+	default:
+		content, _ := json.Marshal(msg)
+		return string(content)
+	}
+}
+
+// ParsePlaybookOutput parses the ansible-playbook stdout produced by the json callback.
+// Other callback plugins might print some banners in the same output, so the
+// json document is searched in the output
+func ParsePlaybookOutput(output []byte) (*PlaybookResults, error) {
+	offset := 0
+	for _, line := range bytes.SplitAfter(output, []byte("\n")) {
+		start := offset
+		offset += len(line)
+		if !bytes.HasPrefix(line, []byte("{")) {
+			continue
+		}
+
+		var results *PlaybookResults
+		decoder := json.NewDecoder(bytes.NewReader(output[start:]))
+		if err := decoder.Decode(&results); err == nil && results != nil {
+			return results, nil
+		}
+	}
+
+	return nil, fmt.Errorf("json results not found in the playbook output")
+}
+
+// ExecutionResults are the checks results of an execution, grouped by host.
+// It follows the same structure used by the trento callback plugin
+type ExecutionResults struct {
+	ClusterID string         `json:"cluster_id"`
+	Hosts     []*HostResults `json:"hosts"`
+	// outputs are the stdout and stderr of the checks tasks, stored in the execution record
+	outputs []*TaskOutput
+	// durations are how long each check took in each host, stored in the execution record
+	durations []*CheckDuration
+}
+
+type HostResults struct {
+	HostID    string         `json:"host_id"`
+	Reachable bool           `json:"reachable"`
+	Msg       string         `json:"msg"`
+	Results   []*CheckResult `json:"results"`
+}
+
+type CheckResult struct {
+	CheckID string `json:"check_id"`
+	Result  string `json:"result"`
+	Msg     string `json:"msg"`
+}
+
+// TaskOutput is the output of a checks task in a host
+type TaskOutput struct {
+	HostID    string `json:"host_id"`
+	Task      string `json:"task"`
+	Stdout    string `json:"stdout,omitempty"`
+	Stderr    string `json:"stderr,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// CheckDuration is how long a check took in a host, from the start of its first task to the end of its result task
+type CheckDuration struct {
+	HostID  string  `json:"host_id"`
+	CheckID string  `json:"check_id"`
+	Seconds float64 `json:"seconds"`
+}
+
+// NewExecutionResults converts the playbook results in the checks results by host
+func NewExecutionResults(clusterID string, playbookResults *PlaybookResults) *ExecutionResults {
+	executionResults := &ExecutionResults{
+		ClusterID: clusterID,
+		Hosts:     []*HostResults{},
+	}
+	// The architecture of each host is set before the checks run, to tell why the checks are skipped
+	architectures := make(map[string]string)
+	// The checks of each host run one after the other after the checks include, the duration of each one
+	// goes from the start of the first task run in the host to the end of its result task
+	checkStarts := make(map[string]*time.Time)
+
+	for _, play := range playbookResults.Plays {
+		for _, task := range play.Tasks {
+			for _, host := range sortedHosts(task.Hosts) {
+				result := task.Hosts[host]
+				if architecture, ok := result.AnsibleFacts[architectureFact].(string); ok {
+					architectures[host] = architecture
+				}
+				if task.Task.Name == testIncludeTaskName {
+					checkStarts[host] = nil
+				} else if start, ok := checkStarts[host]; ok && start == nil && task.Task.Duration != nil {
+					checkStarts[host] = &task.Task.Duration.Start
+				}
+				switch {
+				case result.Unreachable:
+					executionResults.addHost(host, false, result.Message())
+				case task.Task.Name == testResultTaskName:
+					checkID, _ := result.AnsibleFacts[testCheckIDFact].(string)
+					testResult, _ := result.AnsibleFacts[testResultFact].(string)
+					start := checkStarts[host]
+					checkStarts[host] = nil
+					if checkID == "" {
+						continue
+					}
+					executionResults.addHost(host, true, "")
+					executionResults.addResult(host, checkID, testResult, "")
+					if start != nil && task.Task.Duration != nil {
+						executionResults.addDuration(host, checkID, task.Task.Duration.End.Sub(*start))
+					}
+				case task.Task.Name == testIncludeTaskName:
+					executionResults.addSkipped(host, result, architectures[host])
+				case result.Stdout != "" || result.Stderr != "":
+					executionResults.outputs = append(executionResults.outputs, &TaskOutput{
+						HostID: host,
+						Task:   task.Task.Name,
+						Stdout: result.Stdout,
+						Stderr: result.Stderr,
+					})
+				}
+			}
+		}
+	}
+
+	return executionResults
+}
+
+// CheckDurations returns how long each check took in each host
+func (e *ExecutionResults) CheckDurations() []*CheckDuration {
+	return e.durations
+}
+
+// Summary counts the checks results by result, and the unreachable hosts
+func (e *ExecutionResults) Summary() map[string]int {
+	summary := make(map[string]int)
+
+	for _, host := range e.Hosts {
+		if !host.Reachable {
+			summary["unreachable"]++
+		}
+		for _, result := range host.Results {
+			summary[result.Result]++
+		}
+	}
+
+	return summary
+}
+
+func sortedHosts(hosts map[string]*TaskHostResult) []string {
+	names := make([]string, 0, len(hosts))
+	for name := range hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (e *ExecutionResults) getHost(hostID string) *HostResults {
+	for _, host := range e.Hosts {
+		if host.HostID == hostID {
+			return host
+		}
+	}
+
+	return nil
+}
+
+func (e *ExecutionResults) addHost(hostID string, reachable bool, msg string) {
+	if host := e.getHost(hostID); host != nil {
+		host.Reachable = reachable
+		host.Msg = msg
+		return
+	}
+
+	e.Hosts = append(e.Hosts, &HostResults{
+		HostID:    hostID,
+		Reachable: reachable,
+		Msg:       msg,
+		Results:   []*CheckResult{},
+	})
+}
+
+func (e *ExecutionResults) addResult(hostID, checkID, result, msg string) {
+	host := e.getHost(hostID)
+	if host == nil {
+		return
+	}
+
+	// Same check results might come twice, the first one is the good one
+	for _, checkResult := range host.Results {
+		if checkResult.CheckID == checkID {
+			return
+		}
+	}
+
+	host.Results = append(host.Results, &CheckResult{CheckID: checkID, Result: result, Msg: msg})
+}
+
+func (e *ExecutionResults) addDuration(hostID, checkID string, duration time.Duration) {
+	// Same check results might come twice, the duration is the one of the first
+	for _, checkDuration := range e.durations {
+		if checkDuration.HostID == hostID && checkDuration.CheckID == checkID {
+			return
+		}
+	}
+
+	e.durations = append(e.durations, &CheckDuration{HostID: hostID, CheckID: checkID, Seconds: duration.Seconds()})
+}
+
+// addCachedResults adds the cached results of the hosts, keeping the reachability of the hosts already checked
+func (e *ExecutionResults) addCachedResults(cached *ExecutionResults) {
+	for _, host := range cached.Hosts {
+		if e.getHost(host.HostID) == nil {
+			e.addHost(host.HostID, host.Reachable, host.Msg)
+		}
+		for _, result := range host.Results {
+			e.addResult(host.HostID, result.CheckID, result.Result, result.Msg)
+		}
+	}
+}
+
+// moveHostsResults moves the results of the hosts in the execution results from the other ones
+func (e *ExecutionResults) moveHostsResults(other *ExecutionResults) {
+	remaining := []*HostResults{}
+	for _, otherHost := range other.Hosts {
+		host := e.getHost(otherHost.HostID)
+		if host == nil {
+			remaining = append(remaining, otherHost)
+			continue
+		}
+		for _, result := range otherHost.Results {
+			e.addResult(host.HostID, result.CheckID, result.Result, result.Msg)
+		}
+	}
+	other.Hosts = remaining
+}
+
+// Outputs returns the tasks outputs, with the stdout and stderr truncated to their last max size bytes
+func (e *ExecutionResults) Outputs(maxSize int64) []*TaskOutput {
+	outputs := make([]*TaskOutput, 0, len(e.outputs))
+	for _, output := range e.outputs {
+		truncated := *output
+		truncated.Stdout, truncated.Truncated = truncateOutput(output.Stdout, maxSize)
+		if stderr, ok := truncateOutput(output.Stderr, maxSize); ok {
+			truncated.Stderr = stderr
+			truncated.Truncated = true
+		}
+		outputs = append(outputs, &truncated)
+	}
+
+	return outputs
+}
+
+// truncateOutput keeps the end of the output, where the errors are usually found
+func truncateOutput(output string, maxSize int64) (string, bool) {
+	if int64(len(output)) <= maxSize {
+		return output, false
+	}
+
+	return output[int64(len(output))-maxSize:], true
+}
+
+// unreachableHosts returns the hosts that ansible could not reach
+func (e *ExecutionResults) unreachableHosts() []string {
+	hosts := []string{}
+	for _, host := range e.Hosts {
+		if !host.Reachable {
+			hosts = append(hosts, host.HostID)
+		}
+	}
+
+	return hosts
+}
+
+// replaceHostsResults replaces the results of the hosts with the other ones, as the results of a retry in those hosts
+func (e *ExecutionResults) replaceHostsResults(other *ExecutionResults) {
+	replacedHosts := make(map[string]bool)
+	for _, otherHost := range other.Hosts {
+		replacedHosts[otherHost.HostID] = true
+	}
+	outputs := []*TaskOutput{}
+	for _, output := range e.outputs {
+		if !replacedHosts[output.HostID] {
+			outputs = append(outputs, output)
+		}
+	}
+	e.outputs = append(outputs, other.outputs...)
+	durations := []*CheckDuration{}
+	for _, duration := range e.durations {
+		if !replacedHosts[duration.HostID] {
+			durations = append(durations, duration)
+		}
+	}
+	e.durations = append(durations, other.durations...)
+
+	for _, otherHost := range other.Hosts {
+		replaced := false
+		for index, host := range e.Hosts {
+			if host.HostID == otherHost.HostID {
+				e.Hosts[index] = otherHost
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			e.Hosts = append(e.Hosts, otherHost)
+		}
+	}
+}
+
+// replaceChecksResults replaces the results of the checks with the other ones, as the results of the checks run again
+// in some hosts. A host that is not reachable anymore keeps its previous results
+func (e *ExecutionResults) replaceChecksResults(other *ExecutionResults) {
+	for _, otherHost := range other.Hosts {
+		host := e.getHost(otherHost.HostID)
+		if host == nil || !otherHost.Reachable {
+			continue
+		}
+		for _, otherResult := range otherHost.Results {
+			for index, result := range host.Results {
+				if result.CheckID == otherResult.CheckID {
+					host.Results[index] = otherResult
+					break
+				}
+			}
+		}
+		for _, otherDuration := range other.durations {
+			if otherDuration.HostID != otherHost.HostID {
+				continue
+			}
+			e.removeDuration(otherDuration.HostID, otherDuration.CheckID)
+			e.durations = append(e.durations, otherDuration)
+		}
+	}
+	e.outputs = append(e.outputs, other.outputs...)
+}
+
+func (e *ExecutionResults) removeDuration(hostID, checkID string) {
+	for index, duration := range e.durations {
+		if duration.HostID == hostID && duration.CheckID == checkID {
+			e.durations = append(e.durations[:index], e.durations[index+1:]...)
+			return
+		}
+	}
+}
+
+func (e *ExecutionResults) addSkipped(hostID string, result *TaskHostResult, architecture string) {
+	for _, loopResult := range result.Results {
+		if skipped, _ := loopResult["skipped"].(bool); !skipped {
+			continue
+		}
+
+		checkItem, _ := loopResult[testIncludeLoopVar].(map[string]interface{})
+		checkPath, _ := checkItem["path"].(string)
+		metadata, err := readCheckMetadata(checkPath)
+		if err != nil {
+			continue
+		}
+		checkID, ok := metadata["id"]
+		if !ok {
+			continue
+		}
+
+		e.addHost(hostID, true, "")
+		e.addResult(hostID, fmt.Sprint(checkID), checkResultSkipped, architectureSkipReason(metadata, architecture))
+	}
+}
+
+func readCheckMetadata(checkPath string) (map[string]interface{}, error) {
+	content, err := ioutil.ReadFile(path.Join(checkPath, checkMetadataFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata map[string]interface{}
+	if err := yaml.Unmarshal(content, &metadata); err != nil {
+		return nil, err
+	}
+
+	return metadata, nil
+}
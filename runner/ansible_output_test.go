@@ -0,0 +1,301 @@
+package runner
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	TestAnsibleJSONOutput string = "../test/fixtures/ansible_json_output.txt"
+)
+
+type AnsibleOutputTestSuite struct {
+	suite.Suite
+}
+
+func TestAnsibleOutputTestSuite(t *testing.T) {
+	suite.Run(t, new(AnsibleOutputTestSuite))
+}
+
+func (suite *AnsibleOutputTestSuite) Test_ParsePlaybookOutput() {
+	output, _ := ioutil.ReadFile(TestAnsibleJSONOutput)
+
+	results, err := ParsePlaybookOutput(output)
+
+	suite.NoError(err)
+	suite.Len(results.Plays, 1)
+	suite.Len(results.Plays[0].Tasks, 4)
+
+	gatherFacts := results.Plays[0].Tasks[0]
+	suite.Equal("gather facts", gatherFacts.Task.Name)
+	suite.Equal("2022-05-10T10:00:00.2Z", gatherFacts.Task.Duration.Start.Format("2006-01-02T15:04:05.999999Z07:00"))
+	suite.False(gatherFacts.Hosts["host1"].Unreachable)
+	suite.True(gatherFacts.Hosts["host2"].Unreachable)
+	suite.Equal(
+		"Failed to connect to the host via ssh: ssh: connect to host 192.168.10.2 port 22: No route to host",
+		gatherFacts.Hosts["host2"].Message())
+
+	suite.Equal(&HostStats{Ok: 4, Skipped: 1}, results.Stats["host1"])
+	suite.Equal(&HostStats{Unreachable: 1}, results.Stats["host2"])
+}
+
+func (suite *AnsibleOutputTestSuite) Test_ParsePlaybookOutput_NotFound() {
+	_, err := ParsePlaybookOutput([]byte("PLAY [all]\n{ not json\n"))
+
+	suite.EqualError(err, "json results not found in the playbook output")
+}
+
+func (suite *AnsibleOutputTestSuite) Test_TaskHostResultMessage() {
+	suite.Equal("", (&TaskHostResult{}).Message())
+	suite.Equal("some message", (&TaskHostResult{Msg: "some message"}).Message())
+	suite.Equal(`["line1","line2"]`, (&TaskHostResult{Msg: []interface{}{"line1", "line2"}}).Message())
+}
+
+func (suite *AnsibleOutputTestSuite) Test_NewExecutionResults() {
+	output, _ := ioutil.ReadFile(TestAnsibleJSONOutput)
+	playbookResults, _ := ParsePlaybookOutput(output)
+
+	results := NewExecutionResults("cluster1", playbookResults)
+
+	expectedResults := &ExecutionResults{
+		ClusterID: "cluster1",
+		Hosts: []*HostResults{
+			&HostResults{
+				HostID:    "host2",
+				Reachable: false,
+				Msg:       "Failed to connect to the host via ssh: ssh: connect to host 192.168.10.2 port 22: No route to host",
+				Results:   []*CheckResult{},
+			},
+			&HostResults{
+				HostID:    "host1",
+				Reachable: true,
+				Results: []*CheckResult{
+					&CheckResult{
+						CheckID: "A1244C",
+						Result:  "skipped",
+					},
+					&CheckResult{
+						CheckID: "156F64",
+						Result:  "passing",
+					},
+				},
+			},
+		},
+		durations: []*CheckDuration{
+			&CheckDuration{HostID: "host1", CheckID: "156F64", Seconds: 1.3},
+		},
+	}
+
+	suite.Equal(expectedResults, results)
+}
+
+func (suite *AnsibleOutputTestSuite) Test_NewExecutionResults_Outputs() {
+	playbookResults := &PlaybookResults{
+		Plays: []*PlayResults{
+			&PlayResults{
+				Tasks: []*TaskResults{
+					&TaskResults{
+						Task: &TaskInfo{Name: "1.1.1.check"},
+						Hosts: map[string]*TaskHostResult{
+							"host1": &TaskHostResult{Rc: 1, Stdout: "token: 5000", Stderr: "mismatch"},
+							"host2": &TaskHostResult{},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	results := NewExecutionResults("cluster1", playbookResults)
+
+	suite.Empty(results.Hosts)
+	suite.Equal([]*TaskOutput{
+		&TaskOutput{HostID: "host1", Task: "1.1.1.check", Stdout: "token: 5000", Stderr: "mismatch"},
+	}, results.Outputs(1024))
+}
+
+func (suite *AnsibleOutputTestSuite) Test_NewExecutionResults_CheckDurations() {
+	at := func(seconds int) time.Time {
+		return time.Date(2022, 5, 10, 10, 0, seconds, 0, time.UTC)
+	}
+	task := func(name string, start, end int, hosts map[string]*TaskHostResult) *TaskResults {
+		return &TaskResults{
+			Task:  &TaskInfo{Name: name, Duration: &Duration{Start: at(start), End: at(end)}},
+			Hosts: hosts,
+		}
+	}
+	testResult := func(checkID string) *TaskHostResult {
+		return &TaskHostResult{AnsibleFacts: map[string]interface{}{
+			testCheckIDFact: checkID,
+			testResultFact:  "passing",
+		}}
+	}
+
+	playbookResults := &PlaybookResults{
+		Plays: []*PlayResults{
+			&PlayResults{
+				Tasks: []*TaskResults{
+					task("set the node architecture", 0, 1, map[string]*TaskHostResult{"host1": {}, "host2": {}}),
+					task(testIncludeTaskName, 1, 2, map[string]*TaskHostResult{"host1": {}, "host2": {}}),
+					task("1.1.1.check", 2, 5, map[string]*TaskHostResult{"host1": {}, "host2": {}}),
+					task(testResultTaskName, 5, 6, map[string]*TaskHostResult{
+						"host1": testResult("156F64"),
+						"host2": testResult("156F64"),
+					}),
+					// The check only runs in the first host, it is skipped in the other one
+					task("1.1.2.check", 6, 16, map[string]*TaskHostResult{"host1": {}, "host2": {Skipped: true}}),
+					task(testResultTaskName, 16, 17, map[string]*TaskHostResult{
+						"host1": testResult("53D035"),
+						"host2": {Skipped: true},
+					}),
+					task("1.1.3.check", 17, 19, map[string]*TaskHostResult{"host2": {}}),
+					task(testResultTaskName, 19, 20, map[string]*TaskHostResult{"host2": testResult("DA114A")}),
+				},
+			},
+		},
+	}
+
+	results := NewExecutionResults("cluster1", playbookResults)
+
+	suite.Equal([]*CheckDuration{
+		&CheckDuration{HostID: "host1", CheckID: "156F64", Seconds: 4},
+		&CheckDuration{HostID: "host2", CheckID: "156F64", Seconds: 4},
+		&CheckDuration{HostID: "host1", CheckID: "53D035", Seconds: 11},
+		&CheckDuration{HostID: "host2", CheckID: "DA114A", Seconds: 3},
+	}, results.CheckDurations())
+}
+
+func (suite *AnsibleOutputTestSuite) Test_ReplaceResults_CheckDurations() {
+	results := &ExecutionResults{
+		Hosts: []*HostResults{
+			&HostResults{HostID: "host1", Reachable: true, Results: []*CheckResult{{CheckID: "156F64"}}},
+			&HostResults{HostID: "host2", Reachable: true, Results: []*CheckResult{{CheckID: "156F64"}}},
+		},
+		durations: []*CheckDuration{
+			&CheckDuration{HostID: "host1", CheckID: "156F64", Seconds: 1},
+			&CheckDuration{HostID: "host2", CheckID: "156F64", Seconds: 2},
+		},
+	}
+
+	results.replaceChecksResults(&ExecutionResults{
+		Hosts:     []*HostResults{&HostResults{HostID: "host1", Reachable: true, Results: []*CheckResult{{CheckID: "156F64"}}}},
+		durations: []*CheckDuration{&CheckDuration{HostID: "host1", CheckID: "156F64", Seconds: 3}},
+	})
+	results.replaceHostsResults(&ExecutionResults{
+		Hosts:     []*HostResults{&HostResults{HostID: "host2", Reachable: true}},
+		durations: []*CheckDuration{&CheckDuration{HostID: "host2", CheckID: "156F64", Seconds: 4}},
+	})
+
+	suite.Equal([]*CheckDuration{
+		&CheckDuration{HostID: "host1", CheckID: "156F64", Seconds: 3},
+		&CheckDuration{HostID: "host2", CheckID: "156F64", Seconds: 4},
+	}, results.CheckDurations())
+}
+
+func (suite *AnsibleOutputTestSuite) Test_Outputs_Truncated() {
+	results := &ExecutionResults{
+		outputs: []*TaskOutput{
+			&TaskOutput{HostID: "host1", Task: "1.1.1.check", Stdout: "first line\nlast line"},
+			&TaskOutput{HostID: "host1", Task: "1.1.2.check", Stdout: "short", Stderr: "a long error"},
+		},
+	}
+
+	suite.Equal([]*TaskOutput{
+		&TaskOutput{HostID: "host1", Task: "1.1.1.check", Stdout: "last line", Truncated: true},
+		&TaskOutput{HostID: "host1", Task: "1.1.2.check", Stdout: "short", Stderr: "ong error", Truncated: true},
+	}, results.Outputs(9))
+	// The outputs are not changed
+	suite.Equal("first line\nlast line", results.outputs[0].Stdout)
+}
+
+func (suite *AnsibleOutputTestSuite) Test_ReplaceHostsResults_Outputs() {
+	results := &ExecutionResults{
+		Hosts: []*HostResults{&HostResults{HostID: "host1"}, &HostResults{HostID: "host2"}},
+		outputs: []*TaskOutput{
+			&TaskOutput{HostID: "host1", Task: "1.1.1.check", Stdout: "host1"},
+			&TaskOutput{HostID: "host2", Task: "1.1.1.check", Stderr: "unreachable"},
+		},
+	}
+
+	results.replaceHostsResults(&ExecutionResults{
+		Hosts:   []*HostResults{&HostResults{HostID: "host2", Reachable: true}},
+		outputs: []*TaskOutput{&TaskOutput{HostID: "host2", Task: "1.1.1.check", Stdout: "host2"}},
+	})
+
+	suite.Equal([]*TaskOutput{
+		&TaskOutput{HostID: "host1", Task: "1.1.1.check", Stdout: "host1"},
+		&TaskOutput{HostID: "host2", Task: "1.1.1.check", Stdout: "host2"},
+	}, results.outputs)
+}
+
+func (suite *AnsibleOutputTestSuite) TestAddCachedResults() {
+	results := &ExecutionResults{
+		ClusterID: "cluster1",
+		Hosts: []*HostResults{
+			&HostResults{
+				HostID:    "host1",
+				Reachable: false,
+				Msg:       "unreachable",
+				Results:   []*CheckResult{},
+			},
+		},
+	}
+
+	results.addCachedResults(&ExecutionResults{
+		Hosts: []*HostResults{
+			&HostResults{
+				HostID:    "host1",
+				Reachable: true,
+				Results:   []*CheckResult{&CheckResult{CheckID: "156F64", Result: "passing"}},
+			},
+			&HostResults{
+				HostID:    "host2",
+				Reachable: true,
+				Results:   []*CheckResult{&CheckResult{CheckID: "156F64", Result: "warning"}},
+			},
+		},
+	})
+
+	suite.Len(results.Hosts, 2)
+	suite.False(results.Hosts[0].Reachable)
+	suite.Equal("passing", results.Hosts[0].Results[0].Result)
+	suite.True(results.Hosts[1].Reachable)
+	suite.Equal("warning", results.Hosts[1].Results[0].Result)
+}
+
+func (suite *AnsibleOutputTestSuite) TestReplaceChecksResults() {
+	results := &ExecutionResults{
+		ClusterID: "cluster1",
+		Hosts: []*HostResults{
+			&HostResults{HostID: "host1", Reachable: true, Results: []*CheckResult{
+				&CheckResult{CheckID: "156F64", Result: "critical"},
+				&CheckResult{CheckID: "53D035", Result: "passing"},
+			}},
+			&HostResults{HostID: "host2", Reachable: true, Results: []*CheckResult{
+				&CheckResult{CheckID: "156F64", Result: "critical"},
+			}},
+		},
+	}
+
+	results.replaceChecksResults(&ExecutionResults{
+		Hosts: []*HostResults{
+			&HostResults{HostID: "host1", Reachable: true, Results: []*CheckResult{
+				&CheckResult{CheckID: "156F64", Result: "passing"},
+			}},
+			&HostResults{HostID: "host2", Reachable: false, Msg: "unreachable", Results: []*CheckResult{}},
+		},
+	})
+
+	suite.Equal([]*HostResults{
+		&HostResults{HostID: "host1", Reachable: true, Results: []*CheckResult{
+			&CheckResult{CheckID: "156F64", Result: "passing"},
+			&CheckResult{CheckID: "53D035", Result: "passing"},
+		}},
+		&HostResults{HostID: "host2", Reachable: true, Results: []*CheckResult{
+			&CheckResult{CheckID: "156F64", Result: "critical"},
+		}},
+	}, results.Hosts)
+}
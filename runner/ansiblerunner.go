@@ -2,18 +2,52 @@ package runner
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
-	CatalogDestination   = "CATALOG_DESTINATION"
-	TrentoCallbacksUrl   = "TRENTO_CALLBACKS_URL"
-	TrentoExecutionID    = "TRENTO_EXECUTION_ID"
-	AnsibleConfigFileEnv = "ANSIBLE_CONFIG"
+	CatalogDestination       = "CATALOG_DESTINATION"
+	TrentoExecutionID        = "TRENTO_EXECUTION_ID"
+	TrentoPartialResults     = "TRENTO_PARTIAL_RESULTS"
+	AnsibleConfigFileEnv     = "ANSIBLE_CONFIG"
+	AnsibleStdoutCallbackEnv = "ANSIBLE_STDOUT_CALLBACK"
+	AnsiblePrivateKeyFileEnv = "ANSIBLE_PRIVATE_KEY_FILE"
+	SSHAskPassEnv            = "SSH_ASKPASS"
+	SSHAskPassRequireEnv     = "SSH_ASKPASS_REQUIRE"
+	TrentoSSHPassphrase      = "TRENTO_SSH_PASSPHRASE"
+	AnsibleTaskTimeoutEnv    = "ANSIBLE_TASK_TIMEOUT"
+	AnsibleVaultPasswordEnv  = "ANSIBLE_VAULT_PASSWORD_FILE"
+	AnsibleVaultIDsEnv       = "ANSIBLE_VAULT_IDENTITY_LIST"
+	AnsibleFactCacheEnv      = "ANSIBLE_CACHE_PLUGIN_CONNECTION"
+
+	jsonStdoutCallback = "json"
+	dryRunCommandFile  = "command.sh"
+	sshAskPassForce    = "force"
+	// Some ansible outputs, as the json callback ones, might have really long lines
+	maxOutputLineSize = 10 * 1024 * 1024
+
+	// ansible-playbook exit codes when the playbook ran, but some hosts failed or were unreachable
+	ansibleFailedHostsExitCode      = 2
+	ansibleUnreachableHostsExitCode = 4
 )
 
 //go:generate mockery --name=CustomCommand
@@ -22,11 +56,40 @@ type CustomCommand func(name string, arg ...string) *exec.Cmd
 
 var customExecCommand CustomCommand = exec.Command
 
+// sensitiveEnvs are not printed in the logs
+var sensitiveEnvs = map[string]bool{
+	TrentoSSHPassphrase: true,
+	AnsibleFactCacheEnv: true,
+}
+
+func isSensitiveEnv(name string) bool {
+	return sensitiveEnvs[name] || strings.HasPrefix(name, hostSecretEnvPrefix) || isProxyEnv(name)
+}
+
 type AnsibleRunner struct {
 	Playbook  string
 	Inventory string
 	Envs      map[string]string
 	Check     bool
+	// ExtraVarsFile is a json file with extra variables, which take precedence over the playbook ones
+	ExtraVarsFile string
+	// Limit restricts the playbook to the given inventory hosts
+	Limit []string
+	// Tags restricts the playbook to the tasks with any of the given tags, and SkipTags skips the tasks with them
+	Tags     []string
+	SkipTags []string
+	// SyntaxCheck only checks the playbook syntax, without running it
+	SyntaxCheck bool
+	// Results are the parsed playbook results, available after running the playbook with the json output
+	Results *PlaybookResults
+	// OutputHandler receives each playbook output line, with the stream where it was printed
+	OutputHandler func(stream, line string)
+	// Container runs the playbook in a container instead of the runner host, if it is set
+	Container *AnsibleContainer
+	// Cgroup confines the playbook processes run in the runner host, if it is set
+	Cgroup *Cgroup
+	// secretVariables are the inventory and extra vars resolved from the secrets placeholders, masked in the dry runs
+	secretVariables map[string]bool
 }
 
 func DefaultAnsibleRunner() *AnsibleRunner {
@@ -37,6 +100,18 @@ func DefaultAnsibleRunner() *AnsibleRunner {
 	}
 }
 
+// NewAnsibleRunner returns the default ansible runner, running the playbooks in the configured container
+func NewAnsibleRunner(config *Config) *AnsibleRunner {
+	ansibleRunner := DefaultAnsibleRunner()
+	ansibleRunner.Container = NewAnsibleContainer(config)
+	// The callbacks plugin sends the results to the Trento server through the runner proxy
+	for name, value := range proxyEnvs(config) {
+		ansibleRunner.setEnv(name, value)
+	}
+
+	return ansibleRunner
+}
+
 func (a *AnsibleRunner) setEnv(name, value string) {
 	a.Envs[name] = value
 }
@@ -69,71 +144,382 @@ func (a *AnsibleRunner) SetCatalogDestination(destination string) {
 	a.setEnv(CatalogDestination, destination)
 }
 
-func (a *AnsibleRunner) SetTrentoCallbacksUrl(callbacksUrl string) {
-	a.setEnv(TrentoCallbacksUrl, callbacksUrl)
-}
-
 func (a *AnsibleRunner) SetTrentoExecutionID(executionID string) {
 	a.setEnv(TrentoExecutionID, executionID)
 }
 
+// SetPartialResults makes the trento callback print each check result in the stderr once it is known,
+// so it can be posted before the playbook is done
+func (a *AnsibleRunner) SetPartialResults() {
+	a.setEnv(TrentoPartialResults, "1")
+}
+
+// SetJSONOutput configures ansible to print the playbook results as a json document,
+// so they can be parsed once the playbook is done
+func (a *AnsibleRunner) SetJSONOutput() {
+	a.setEnv(AnsibleStdoutCallbackEnv, jsonStdoutCallback)
+}
+
+func (a *AnsibleRunner) SetPrivateKeyFile(privateKeyFile string) {
+	a.setEnv(AnsiblePrivateKeyFileEnv, privateKeyFile)
+}
+
+// SetSSHPassphrase makes ssh get the private key passphrase from the given askpass program,
+// which prints it from the environment. It requires OpenSSH 8.4 or newer
+func (a *AnsibleRunner) SetSSHPassphrase(passphrase, askPassProgram string) {
+	a.setEnv(TrentoSSHPassphrase, passphrase)
+	a.setEnv(SSHAskPassEnv, askPassProgram)
+	a.setEnv(SSHAskPassRequireEnv, sshAskPassForce)
+}
+
+// SetVaultPasswordFile makes ansible decrypt the vault encrypted variables with the password in the file.
+// If the file is executable, ansible runs it and uses its output as password
+func (a *AnsibleRunner) SetVaultPasswordFile(passwordFile string) {
+	a.setEnv(AnsibleVaultPasswordEnv, passwordFile)
+}
+
+// SetVaultIDs sets the vault identities, as label@password-file, used to decrypt the variables
+// encrypted with different passwords
+func (a *AnsibleRunner) SetVaultIDs(vaultIDs []string) {
+	a.setEnv(AnsibleVaultIDsEnv, strings.Join(vaultIDs, ","))
+}
+
+// SetTaskTimeout terminates the tasks running longer than the timeout in a host, rounded up to seconds
+func (a *AnsibleRunner) SetTaskTimeout(timeout time.Duration) {
+	a.setEnv(AnsibleTaskTimeoutEnv, strconv.FormatInt(timeoutSeconds(timeout), 10))
+}
+
+func (a *AnsibleRunner) isJSONOutput() bool {
+	return a.Envs[AnsibleStdoutCallbackEnv] == jsonStdoutCallback
+}
+
 func (a *AnsibleRunner) RunPlaybook() error {
-	var cmdItems []string
+	return a.RunPlaybookContext(context.Background())
+}
 
-	log.Infof("Ansible playbook %s", a.Playbook)
-	cmdItems = append(cmdItems, a.Playbook)
+// RunPlaybookContext runs the playbook, terminating it if the context is done before it finishes
+func (a *AnsibleRunner) RunPlaybookContext(ctx context.Context) (err error) {
+	ctx, span := tracer.Start(ctx, "RunPlaybook", trace.WithAttributes(
+		attribute.String("ansible.playbook", a.Playbook),
+		attribute.Bool("ansible.check", a.Check),
+	))
+	defer func() { endSpan(span, err) }()
 
-	if a.Inventory != "" {
-		log.Infof("Inventory %s", a.Inventory)
-		cmdItems = append(cmdItems, fmt.Sprintf("--inventory=%s", a.Inventory))
+	logger := loggerFromContext(ctx)
+	name, args, err := a.command(ctx)
+	if err != nil {
+		return err
 	}
 
-	if a.Check {
-		log.Info("Running in check mode")
-		cmdItems = append(cmdItems, "--check")
+	// The container runtime applies the limits to the container itself
+	var cgroup *Cgroup
+	if a.Container == nil && a.Cgroup != nil {
+		if err := a.Cgroup.Create(); err != nil {
+			return err
+		}
+		defer func() {
+			if removeErr := a.Cgroup.Remove(); removeErr != nil {
+				logger.Warnf("Error removing the cgroup %s: %s", a.Cgroup.Path, removeErr)
+			}
+		}()
+		cgroup = a.Cgroup
 	}
 
-	cmd := customExecCommand("ansible-playbook", cmdItems...)
+	cmd := customExecCommand(name, args...)
 
 	cmd.Env = os.Environ()
 	for key, value := range a.Envs {
 		newEnv := fmt.Sprintf("%s=%s", key, value)
-		log.Debugf("New environment variable: %s", newEnv)
+		if isSensitiveEnv(key) {
+			logger.Debugf("New environment variable: %s=********", key)
+		} else {
+			logger.Debugf("New environment variable: %s", newEnv)
+		}
 		cmd.Env = append(cmd.Env, newEnv)
 	}
 
-	logCommand(cmd)
-	err := cmd.Run()
+	// The json output is a big document, not that useful in the info logs
+	stdoutLogger := logger.Infof
+	if a.isJSONOutput() {
+		stdoutLogger = logger.Debugf
+	}
+
+	if a.Container != nil && a.Container.Name != "" {
+		stopRemoval := a.Container.removeOnCancel(ctx)
+		defer stopRemoval()
+	}
+
+	output, err := runCommand(ctx, cmd, cgroup, stdoutLogger, a.OutputHandler)
 
 	if err != nil {
-		log.Errorf("An error occurred while running ansible: %s", err)
+		logger.Errorf("An error occurred while running ansible: %s", err)
+		// The results of the playbooks failed in some hosts only are kept, so those hosts can be retried
+		if a.isJSONOutput() && isHostsFailure(err) {
+			a.Results, _ = ParsePlaybookOutput(output)
+		}
+		return err
+	}
+
+	logger.Info("Ansible playbook execution finished successfully")
+
+	if a.isJSONOutput() {
+		// The playbook did its job, not having the parsed results must not fail the execution
+		results, err := ParsePlaybookOutput(output)
+		if err != nil {
+			logger.Warnf("Could not parse the ansible output: %s", err)
+			return nil
+		}
+		a.Results = results
+	}
+
+	return nil
+}
+
+// WriteDryRun writes the command line that would run the playbook, with its environment, and copies
+// the inventory, the extra vars and the ansible configuration to the destination folder, without running it.
+// The sensitive environment variables are masked
+func (a *AnsibleRunner) WriteDryRun(ctx context.Context, destination string) error {
+	logger := loggerFromContext(ctx)
+	name, args, err := a.command(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The dry run files have the hosts addresses, so they are only readable by the runner user
+	if err := os.MkdirAll(destination, 0700); err != nil {
+		return err
+	}
+	if err := os.Chmod(destination, 0700); err != nil {
+		return err
+	}
+
+	envs := make([]string, 0, len(a.Envs))
+	for key, value := range a.Envs {
+		if isSensitiveEnv(key) {
+			value = "********"
+		}
+		envs = append(envs, fmt.Sprintf("%s=%s", key, shellQuote(value)))
+	}
+	sort.Strings(envs)
+
+	quoted := []string{shellQuote(name)}
+	for _, arg := range args {
+		quoted = append(quoted, shellQuote(arg))
+	}
+	command := strings.Join(quoted, " ")
+	logger.Infof("Dry run, the playbook is not run: %s", command)
+
+	content := strings.Join(append(envs, command), " \\\n") + "\n"
+	if err := ioutil.WriteFile(path.Join(destination, dryRunCommandFile), []byte(content), 0600); err != nil {
 		return err
 	}
 
-	log.Info("Ansible playbook execution finished successfully")
+	// The secret variables are masked in the copies of the inventory and the extra vars
+	files := []struct {
+		path string
+		mask func([]byte) ([]byte, error)
+	}{
+		{a.Inventory, a.maskInventory},
+		{a.ExtraVarsFile, a.maskExtraVars},
+		{a.Envs[AnsibleConfigFileEnv], nil},
+	}
+	for _, file := range files {
+		if file.path == "" {
+			continue
+		}
+		fileContent, err := ioutil.ReadFile(file.path)
+		if err != nil {
+			return err
+		}
+		if file.mask != nil {
+			if fileContent, err = file.mask(fileContent); err != nil {
+				return err
+			}
+		}
+		if err := ioutil.WriteFile(path.Join(destination, path.Base(file.path)), fileContent, 0600); err != nil {
+			return err
+		}
+	}
+
+	logger.Infof("Dry run files written in %s", destination)
 
 	return nil
 }
 
-func logCommand(cmd *exec.Cmd) {
+// The inventory variables are name=value, where the value might be quoted
+var inventoryVariableAssignment = regexp.MustCompile(`([A-Za-z0-9_]+)=('[^']*'|"(?:[^"\\]|\\.)*"|\S+)`)
+
+// isSecretVariable tells if the variable is masked in the dry runs, as it was resolved from the secrets
+// placeholders, or its name is the one of a credential
+func (a *AnsibleRunner) isSecretVariable(name string) bool {
+	return a.secretVariables[name] || sensitiveInventoryVariable.MatchString(name)
+}
+
+func (a *AnsibleRunner) maskInventory(content []byte) ([]byte, error) {
+	return inventoryVariableAssignment.ReplaceAllFunc(content, func(assignment []byte) []byte {
+		name := inventoryVariableAssignment.FindSubmatch(assignment)[1]
+		if !a.isSecretVariable(string(name)) {
+			return assignment
+		}
+		return append(append([]byte{}, name...), []byte("=********")...)
+	}), nil
+}
+
+func (a *AnsibleRunner) maskExtraVars(content []byte) ([]byte, error) {
+	var variables map[string]interface{}
+	if err := json.Unmarshal(content, &variables); err != nil {
+		return nil, err
+	}
+
+	for name := range variables {
+		if a.isSecretVariable(name) {
+			variables[name] = "********"
+		}
+	}
+
+	return json.Marshal(variables)
+}
+
+// command returns the ansible-playbook command line, running it in the container if it is set
+func (a *AnsibleRunner) command(ctx context.Context) (string, []string, error) {
+	logger := loggerFromContext(ctx)
+	var cmdItems []string
+
+	logger.Infof("Ansible playbook %s", a.Playbook)
+	cmdItems = append(cmdItems, a.Playbook)
+
+	if a.Inventory != "" {
+		logger.Infof("Inventory %s", a.Inventory)
+		cmdItems = append(cmdItems, fmt.Sprintf("--inventory=%s", a.Inventory))
+	}
+
+	if a.ExtraVarsFile != "" {
+		logger.Infof("Extra vars %s", a.ExtraVarsFile)
+		cmdItems = append(cmdItems, fmt.Sprintf("--extra-vars=@%s", a.ExtraVarsFile))
+	}
+
+	if len(a.Limit) > 0 {
+		logger.Infof("Limited to the hosts %s", strings.Join(a.Limit, ","))
+		cmdItems = append(cmdItems, fmt.Sprintf("--limit=%s", strings.Join(a.Limit, ",")))
+	}
+
+	if len(a.Tags) > 0 {
+		logger.Infof("Restricted to the tags %s", strings.Join(a.Tags, ","))
+		cmdItems = append(cmdItems, fmt.Sprintf("--tags=%s", strings.Join(a.Tags, ",")))
+	}
+
+	if len(a.SkipTags) > 0 {
+		logger.Infof("Skipping the tags %s", strings.Join(a.SkipTags, ","))
+		cmdItems = append(cmdItems, fmt.Sprintf("--skip-tags=%s", strings.Join(a.SkipTags, ",")))
+	}
+
+	if a.Check {
+		logger.Info("Running in check mode")
+		cmdItems = append(cmdItems, "--check")
+	}
+
+	if a.SyntaxCheck {
+		cmdItems = append(cmdItems, "--syntax-check")
+	}
+
+	if a.Container == nil {
+		return "ansible-playbook", cmdItems, nil
+	}
+
+	logger.Infof("Running in a %s container of the image %s", a.Container.Runtime, a.Container.Image)
+	return a.Container.command("ansible-playbook", cmdItems, a.Envs)
+}
+
+// isHostsFailure tells if ansible-playbook finished, but some hosts failed or were unreachable
+func isHostsFailure(err error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+
+	return exitErr.ExitCode() == ansibleFailedHostsExitCode || exitErr.ExitCode() == ansibleUnreachableHostsExitCode
+}
+
+// runCommand runs the command logging its output, and returns the stdout content.
+// The command runs in its own process group, so all the processes it creates, as the ssh
+// connections, are terminated if the context is done. It is moved to the cgroup, if it is given
+func runCommand(
+	ctx context.Context,
+	cmd *exec.Cmd,
+	cgroup *Cgroup,
+	stdoutLogger func(format string, args ...interface{}),
+	outputHandler func(stream, line string),
+) ([]byte, error) {
+	logger := loggerFromContext(ctx)
+	var output bytes.Buffer
+	var wg sync.WaitGroup
+
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		return
+		return nil, err
 	}
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		return
+		return nil, err
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	if cgroup != nil {
+		if err := cgroup.Add(cmd.Process.Pid); err != nil {
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+			cmd.Wait()
+			return nil, err
+		}
 	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			logger.Warnf("Terminating the process %d: %s", cmd.Process.Pid, ctx.Err())
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+		case <-done:
+		}
+	}()
+
+	wg.Add(2)
 	go func() {
+		defer wg.Done()
 		in := bufio.NewScanner(stdout)
+		in.Buffer(make([]byte, bufio.MaxScanTokenSize), maxOutputLineSize)
 		for in.Scan() {
-			log.Infof(in.Text())
+			stdoutLogger("%s", in.Text())
+			if outputHandler != nil {
+				outputHandler(StdoutStream, in.Text())
+			}
+			output.Write(in.Bytes())
+			output.WriteByte('\n')
 		}
 	}()
 	go func() {
+		defer wg.Done()
 		in := bufio.NewScanner(stderr)
+		in.Buffer(make([]byte, bufio.MaxScanTokenSize), maxOutputLineSize)
 		for in.Scan() {
-			log.Debugf(in.Text())
+			logger.Debugf("%s", in.Text())
+			if outputHandler != nil {
+				outputHandler(StderrStream, in.Text())
+			}
 		}
 	}()
+
+	// All the output must be read before waiting for the command
+	wg.Wait()
+	err = cmd.Wait()
+
+	return output.Bytes(), err
 }
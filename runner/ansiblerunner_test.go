@@ -1,12 +1,19 @@
 package runner
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"os"
 	"os/exec"
+	"strings"
 	"testing"
+	"time"
 
+	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/trento-project/runner/runner/mocks"
+	"gopkg.in/yaml.v2"
 )
 
 func TestRunPlaybookSimple(t *testing.T) {
@@ -56,8 +63,12 @@ func TestRunPlaybookError(t *testing.T) {
 func TestRunPlaybookComplex(t *testing.T) {
 
 	runnerInst := &AnsibleRunner{
-		Playbook:  "superplay.yml",
-		Inventory: "inventory.yml",
+		Playbook:      "superplay.yml",
+		Inventory:     "inventory.yml",
+		ExtraVarsFile: "extra_vars.json",
+		Limit:         []string{"host1", "host2"},
+		Tags:          []string{"corosync"},
+		SkipTags:      []string{"sbd", "fencing"},
 		Envs: map[string]string{
 			"env1": "value1",
 			"env2": "value2",
@@ -71,7 +82,8 @@ func TestRunPlaybookComplex(t *testing.T) {
 	customExecCommand = mockCommand.Execute
 	mockCommand.On(
 		"Execute", "ansible-playbook", "superplay.yml",
-		"--inventory=inventory.yml", "--check").Return(
+		"--inventory=inventory.yml", "--extra-vars=@extra_vars.json",
+		"--limit=host1,host2", "--tags=corosync", "--skip-tags=sbd,fencing", "--check").Return(
 		cmd,
 	)
 
@@ -86,3 +98,128 @@ func TestRunPlaybookComplex(t *testing.T) {
 
 	mockCommand.AssertExpectations(t)
 }
+
+func TestRunPlaybookJSONOutput(t *testing.T) {
+
+	runnerInst := &AnsibleRunner{
+		Playbook: "superplay.yml",
+		Envs:     map[string]string{},
+	}
+	runnerInst.SetJSONOutput()
+
+	cmd := exec.Command("cat", "../test/fixtures/ansible_json_output.txt")
+
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+	mockCommand.On("Execute", "ansible-playbook", "superplay.yml").Return(
+		cmd,
+	)
+
+	err := runnerInst.RunPlaybook()
+
+	assert.Contains(t, cmd.Env, "ANSIBLE_STDOUT_CALLBACK=json")
+	assert.NoError(t, err)
+	assert.Len(t, runnerInst.Results.Plays, 1)
+	assert.Len(t, runnerInst.Results.Stats, 2)
+
+	mockCommand.AssertExpectations(t)
+}
+
+func TestSetVaultCredentials(t *testing.T) {
+	a := DefaultAnsibleRunner()
+
+	a.SetVaultPasswordFile("/etc/trento/vault_password")
+	a.SetVaultIDs([]string{"hana@/etc/trento/hana_password", "aws@/etc/trento/aws_password"})
+
+	assert.Equal(t, "/etc/trento/vault_password", a.Envs["ANSIBLE_VAULT_PASSWORD_FILE"])
+	assert.Equal(
+		t, "hana@/etc/trento/hana_password,aws@/etc/trento/aws_password", a.Envs["ANSIBLE_VAULT_IDENTITY_LIST"])
+}
+
+func TestRunPlaybookOutputExecutionLogger(t *testing.T) {
+	var output bytes.Buffer
+	logger := log.New()
+	logger.SetOutput(&output)
+	logger.SetFormatter(&log.JSONFormatter{})
+	ctx := withLogger(context.Background(), log.NewEntry(logger).WithField("execution_id", "execution1"))
+
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+	defer func() { customExecCommand = exec.Command }()
+	mockCommand.On("Execute", "ansible-playbook", "superplay.yml").Return(exec.Command("echo", "PLAY RECAP"))
+
+	runnerInst := &AnsibleRunner{Playbook: "superplay.yml"}
+	assert.NoError(t, runnerInst.RunPlaybookContext(ctx))
+
+	// The ansible output lines are logged with the execution fields
+	found := false
+	for _, content := range bytes.Split(bytes.TrimSpace(output.Bytes()), []byte("\n")) {
+		var line map[string]interface{}
+		assert.NoError(t, json.Unmarshal(content, &line))
+		if line["msg"] == "PLAY RECAP" {
+			found = true
+			assert.Equal(t, "execution1", line["execution_id"])
+		}
+	}
+	assert.True(t, found)
+}
+
+func TestSetTaskTimeout(t *testing.T) {
+	a := DefaultAnsibleRunner()
+
+	a.SetTaskTimeout(time.Minute)
+	assert.Equal(t, "60", a.Envs["ANSIBLE_TASK_TIMEOUT"])
+
+	a.SetTaskTimeout(1500 * time.Millisecond)
+	assert.Equal(t, "2", a.Envs["ANSIBLE_TASK_TIMEOUT"])
+}
+
+func TestCheckPlaybookTimeouts(t *testing.T) {
+	content, err := ansibleFS.ReadFile("ansible/check.yml")
+	assert.NoError(t, err)
+	var plays []map[string]interface{}
+	assert.NoError(t, yaml.Unmarshal(content, &plays))
+	// The play does not replace the task timeout given by the runner in ANSIBLE_TASK_TIMEOUT
+	assert.NotContains(t, plays[0], "timeout")
+
+	// The checks tasks without a check timeout use the task timeout of the runner
+	applyTimeout := ""
+	for _, task := range plays[0]["tasks"].([]interface{}) {
+		block, _ := task.(map[interface{}]interface{})["block"].([]interface{})
+		for _, blockTask := range block {
+			includeRole, ok := blockTask.(map[interface{}]interface{})["include_role"].(map[interface{}]interface{})
+			if ok && blockTask.(map[interface{}]interface{})["name"] == testIncludeTaskName {
+				applyTimeout = includeRole["apply"].(map[interface{}]interface{})["timeout"].(string)
+			}
+		}
+	}
+	assert.True(t, strings.HasSuffix(applyTimeout, "default(trento_check_timeout | default(omit)) }}"), applyTimeout)
+}
+
+func TestProvidersVariables(t *testing.T) {
+	content, err := ansibleFS.ReadFile("ansible/roles/load_facts/tasks/main.yml")
+	assert.NoError(t, err)
+	var tasks []map[string]interface{}
+	assert.NoError(t, yaml.Unmarshal(content, &tasks))
+
+	// The default variables are loaded before the provider ones, which replace them
+	varsDirs := []string{}
+	for _, task := range tasks {
+		if includeVars, ok := task["include_vars"].(map[interface{}]interface{}); ok {
+			varsDirs = append(varsDirs, includeVars["dir"].(string))
+		}
+	}
+	assert.Equal(t, []string{
+		"{{ playbook_dir }}/vars/default",
+		"{{ playbook_dir }}/vars/{{ provider | default('azure') }}",
+	}, varsDirs)
+
+	// The providers with the default values do not copy them
+	for _, provider := range []string{"kvm", "bare-metal"} {
+		content, err := ansibleFS.ReadFile("ansible/vars/" + provider + "/10-default.yml")
+		assert.NoError(t, err)
+		var variables map[string]interface{}
+		assert.NoError(t, yaml.Unmarshal(content, &variables))
+		assert.Empty(t, variables, provider)
+	}
+}
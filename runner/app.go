@@ -3,24 +3,194 @@ package runner
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+
+	pb "github.com/trento-project/runner/api/proto"
+	"github.com/trento-project/runner/version"
 )
 
 type Config struct {
-	Host          string
-	Port          int
-	CallbacksUrl  string
-	AnsibleFolder string
+	Host                string
+	Port                int
+	GrpcPort            int
+	CallbacksUrl        string
+	AnsibleFolder       string
+	MaxParallelClusters int64
+	ExecutionQueueSize  int
+	ShutdownGracePeriod time.Duration
+	AmqpUrl             string
+	AmqpExchange        string
+	AmqpQueue           string
+	AmqpReplyExchange   string
+	ExecutionsDatabase  string
+	TaskOutputMaxSize   int64
+	PartialResults      bool
+	CustomChecksDir     string
+	ShredInventories    bool
+	SSHPrivateKeyFile   string
+	SSHPassphrase       string
+	SSHPassphraseFile   string
+	SSHAgentForwarding  bool
+	SSHBastion          string
+	SSHHostKeyChecking  string
+	SSHKnownHostsFile   string
+	VaultPasswordFile   string
+	VaultIDs            []string
+	CloudInventory      bool
+	DNSOverrides        map[string]string
+	ResultsCacheTTL     time.Duration
+	ReportChangesOnly   bool
+	FullResyncInterval  time.Duration
+	CheckEngine         string
+	NativeChecksDir     string
+	SimulationResults   map[string]int
+	SimulationLatency   time.Duration
+	SimulationSeed      int64
+	Schedules           string
+	ScheduleOverlap     string
+	ExecutionTimeout    time.Duration
+	TaskTimeout         time.Duration
+	CheckTimeout        time.Duration
+	CheckTimeouts       map[string]time.Duration
+	PreflightTimeout    time.Duration
+	HostRetries         int
+	CheckSeverities     map[string]string
+	CriticalThreshold   int
+	CriticalThresholds  map[string]int
+	RollingBatchSize    int
+	DryRun              bool
+	DryRunDir           string
+	DryRunRetention     time.Duration
+	TracingEndpoint     string
+	DebugServer         string
+	ResultsDir          string
+	ResultsFormats      []string
+	// Time ranges where the checks are not run in the clusters and hosts, in a file or an url
+	MaintenanceWindows string
+	// The executions with more hosts than the chunk size run the checks in chunks of that many hosts,
+	// up to the chunk parallelism at a time
+	ExecutionChunkSize        int
+	ExecutionChunkParallelism int
+	// The results sent to the Trento servers and written in the results folder are signed with the key, if it is set
+	ResultsSigningKey   string
+	ResultsSigningKeyID string
+	// Each execution has its own work dir under the work dir, kept for the retention if the execution fails
+	WorkDir                string
+	FailedWorkDirRetention time.Duration
+	// The full ansible output of each execution is written in its own file in the logs dir, rotated at the max size
+	// and removed after the max age
+	ExecutionLogsDir     string
+	ExecutionLogsMaxSize int64
+	ExecutionLogsMaxAge  time.Duration
+	// Git repository of checks, copied on top of the embedded checks, and below the custom checks, when the catalog is built
+	CatalogGitUrl    string
+	CatalogGitRef    string
+	CatalogGitPath   string
+	CatalogGitVerify bool
+	// The callbacks requests sent to each Trento server are limited to the rate, with the check results in batches
+	CallbacksRateLimit float64
+	CallbacksBatchSize int
+	// The high priority executions cancel the running low priority execution of their cluster, which is queued again
+	ExecutionPreemption bool
+	// Commands or playbooks run before and after the checks of each execution. A failed pre-execution hook fails
+	// the execution without running the checks if the hooks abort it. Each hook is stopped after the timeout
+	PreExecutionHooks     []string
+	PostExecutionHooks    []string
+	ExecutionHooksAbort   bool
+	ExecutionHooksTimeout time.Duration
+	// The serve mode runs until the runner is stopped, the oneshot and cron modes run the scheduled executions once
+	Mode string
+	// Webhooks notified of the executions start, finish and failure
+	WebhookUrls           []string
+	WebhookSecret         string
+	WebhookDeadLetterFile string
+	// Store of the credentials, replacing the ones in the settings
+	SecretsProvider      string
+	SecretsDir           string
+	SecretsVaultAddr     string
+	SecretsVaultRoleID   string
+	SecretsVaultSecretID string
+	SecretsVaultPath     string
+	// Only the secrets with this prefix can be the passwords of the hosts in the executions
+	HostSecretsPrefix string
+	// Settings of the ansible configuration file, the defaults are used if they are not set,
+	// and the container where ansible runs, if an image is set
+	AnsibleForks             int
+	AnsibleDisablePipelining bool
+	AnsibleControlPersist    time.Duration
+	AnsibleGatherSubset      string
+	AnsibleContainerImage    string
+	AnsibleContainerRuntime  string
+	// The ansible version and the requirements are checked before building the catalog,
+	// and the missing requirements are installed from the galaxy server if it is enabled
+	AnsibleMinVersion          string
+	AnsibleRequirementsFile    string
+	AnsibleInstallRequirements bool
+	AnsibleGalaxyServer        string
+	// The ansible processes run in a cgroup under the ansible cgroup, limited to these resources
+	AnsibleCgroup      string
+	AnsibleCPULimit    float64
+	AnsibleMemoryLimit int64
+	// The gathered facts are cached between the executions if the fact cache is set
+	AnsibleFactCache              string
+	AnsibleFactCacheTTL           time.Duration
+	AnsibleFactCacheRedis         string
+	AnsibleFactCacheRedisPassword string
+	// Writable paths of ansible, set out of the home folder to run under confined SELinux or AppArmor
+	// policies, or in containers with a read-only root filesystem. The ansible defaults are used if they are not set
+	AnsibleLocalTemp      string
+	AnsibleControlPathDir string
+	AnsibleFactCacheDir   string
+	// Proxy of the runner outbound connections and downloads, replacing the proxy environment variables
+	Proxy   string
+	NoProxy string
+	// TLS settings of the Trento server connections
+	ServerCAFile             string
+	ServerCertFile           string
+	ServerKeyFile            string
+	ServerInsecureSkipVerify bool
+	ServerTLSReloadInterval  time.Duration
+	// Authentication of the Trento server requests
+	ServerToken      string
+	ServerTokenFile  string
+	ServerApiKey     string
+	ServerApiKeyFile string
+	ServerAuthUrl    string
+	// The catalog versions are sent to the handshake url each time the catalog is built
+	ServerHandshakeUrl string
+	// The premium checks are only run if the subscription read from the subscription url includes them
+	ServerSubscriptionUrl string
+	// The runner is registered in the registration url with the runner id, and sends a heartbeat after each interval
+	ServerRegistrationUrl string
+	RunnerID              string
+	HeartbeatInterval     time.Duration
+	// The summary of each finished execution, with the results counts and the cluster health, is posted to the
+	// summary url
+	ServerSummaryUrl string
+	// Additional Trento servers served by the runner
+	Upstreams []*Upstream
+	// The report of each execution is passed to all the sinks
+	Sinks []*Sink
+	// Variables given to the checks of every execution, as extra vars, and set in every inventory host. Their
+	// ${env:VAR} and ${file:/path} placeholders are resolved each time an execution runs
+	CheckVariables     map[string]interface{}
+	InventoryVariables map[string]string
 }
 
 type App struct {
-	config *Config
+	config         *Config
+	grpcServer     *grpc.Server
+	debugServer    *http.Server
+	tracerProvider *sdktrace.TracerProvider
 	Dependencies
 }
 
@@ -28,6 +198,20 @@ type Dependencies struct {
 	webEngine           *gin.Engine
 	executionWorkerPool *ExecutionWorkerPool
 	runnerService       RunnerService
+	callbacksDispatcher *CallbacksDispatcher
+	amqpConsumer        *AmqpConsumer
+	executionsStore     ExecutionsStore
+	events              *EventsBroadcaster
+	executionLogs       *ExecutionLogs
+	scheduler           *Scheduler
+	webhooksNotifier    *WebhooksNotifier
+	catalogPublishers   []*CatalogPublisher
+	registrar           *Registrar
+	hostKeys            *HostKeys
+	serverConnectivity  *ServerConnectivity
+	inventories         *InventoryAudit
+	checkDurations      *CheckDurationMetrics
+	circuitBreakers     map[string]*CircuitBreaker
 }
 
 func DefaultDependencies(config *Config) Dependencies {
@@ -42,12 +226,57 @@ func DefaultDependencies(config *Config) Dependencies {
 		log.Fatalf("Failed to create the runner instance: %s", err)
 	}
 
-	executionWorkerPool := NewExecutionWorkerPool(runnerService)
+	executionWorkerPool := NewExecutionWorkerPool(runnerService, config.MaxParallelClusters, config.ShutdownGracePeriod)
+	if config.ExecutionPreemption {
+		executionWorkerPool.EnablePreemption()
+	}
+
+	var amqpConsumer *AmqpConsumer
+	if config.AmqpUrl != "" {
+		amqpConsumer = NewAmqpConsumer(config, runnerService)
+	}
+
+	var scheduler *Scheduler
+	if config.Schedules != "" {
+		scheduler = NewScheduler(config, runnerService, runnerService.events, runnerService.serverTransport)
+	}
+
+	var webhooksNotifier *WebhooksNotifier
+	if len(config.WebhookUrls) > 0 {
+		webhooksNotifier = NewWebhooksNotifier(config, runnerService.events)
+	}
+
+	catalogPublishers := []*CatalogPublisher{}
+	for _, upstream := range runnerService.upstreams {
+		if upstream.upstream.CatalogUrl != "" {
+			catalogPublishers = append(
+				catalogPublishers, NewCatalogPublisher(upstream.upstream, runnerService, upstream.transport))
+		}
+	}
+
+	var registrar *Registrar
+	if config.ServerRegistrationUrl != "" {
+		registrar = NewRegistrar(config, runnerService, executionWorkerPool, runnerService.serverTransport)
+	}
 
 	return Dependencies{
 		webEngine,
 		executionWorkerPool,
 		runnerService,
+		runnerService.callbacksDispatcher,
+		amqpConsumer,
+		runnerService.executionsStore,
+		runnerService.events,
+		runnerService.logs,
+		scheduler,
+		webhooksNotifier,
+		catalogPublishers,
+		registrar,
+		runnerService.hostKeys,
+		runnerService.serverConnectivity,
+		runnerService.inventories,
+		runnerService.checkDurations,
+		runnerService.circuitBreakers(),
 	}
 }
 
@@ -56,63 +285,281 @@ func NewApp(config *Config) (*App, error) {
 }
 
 func NewAppWithDeps(config *Config, deps Dependencies) (*App, error) {
+	tracerProvider, err := NewTracerProvider(config)
+	if err != nil {
+		return nil, err
+	}
+
 	app := &App{
-		config:       config,
-		Dependencies: deps,
+		config:         config,
+		tracerProvider: tracerProvider,
+		Dependencies:   deps,
 	}
 
+	deps.webEngine.GET("/healthz", LivenessHandler(deps.executionWorkerPool))
+	deps.webEngine.GET("/readyz", ReadinessHandler(deps.runnerService))
+	deps.webEngine.GET("/metrics", MetricsHandler(NewMetricsRegistry(
+		deps.executionWorkerPool, deps.scheduler, deps.checkDurations, deps.circuitBreakers)))
+
 	apiGroup := deps.webEngine.Group("/api")
 	{
 		apiGroup.GET("/health", HealthHandler)
 		apiGroup.GET("/ready", ReadyHandler(deps.runnerService))
+		apiGroup.GET("/version", VersionHandler(deps.runnerService))
 		apiGroup.GET("/catalog", CatalogHandler(deps.runnerService))
+		apiGroup.GET("/catalog/status", CatalogStatusHandler(deps.runnerService))
+		apiGroup.GET("/catalog/providers", CatalogProvidersHandler(deps.runnerService))
+		apiGroup.POST("/catalog/rebuild", CatalogRebuildHandler(deps.runnerService))
 		apiGroup.POST("/execute", ExecutionHandler(deps.runnerService))
+		apiGroup.POST("/executions", ExecutionHandler(deps.runnerService))
+		apiGroup.POST("/executions/trigger", SchedulesTriggerHandler(deps.scheduler))
+		apiGroup.GET("/executions", ExecutionsHistoryHandler(deps.executionsStore))
+		apiGroup.GET("/executions/:id", ExecutionRecordHandler(deps.executionsStore))
+		apiGroup.GET("/executions/:id/logs", ExecutionLogsHandler(deps.executionLogs))
+		apiGroup.GET("/executions/:id/logs/bundle", ExecutionLogsBundleHandler(deps.executionLogs))
+		apiGroup.GET("/schedules", SchedulesHandler(deps.scheduler))
+		apiGroup.POST("/schedules/reload", SchedulesReloadHandler(deps.scheduler))
+		apiGroup.PUT("/schedules/:cluster_id", ScheduleUpdateHandler(deps.scheduler))
+		apiGroup.POST("/schedules/:cluster_id/pause", SchedulePauseHandler(deps.scheduler))
+		apiGroup.POST("/schedules/:cluster_id/resume", ScheduleResumeHandler(deps.scheduler))
+		apiGroup.GET("/host_keys", HostKeysHandler(deps.hostKeys))
+		apiGroup.POST("/host_keys/accept", HostKeyAcceptHandler(deps.hostKeys))
+		apiGroup.GET("/inventory", InventoryHandler(deps.inventories))
+		apiGroup.GET("/inventory/changes", InventoryChangesHandler(deps.inventories))
+		apiGroup.GET("/docs/openapi.json", OpenAPIHandler)
+	}
+
+	if config.GrpcPort != 0 {
+		app.grpcServer = grpc.NewServer()
+		pb.RegisterRunnerServer(app.grpcServer, NewGrpcServer(deps.runnerService, deps.executionsStore, deps.events))
+	}
+
+	// The profiles and the runtime variables are only served if they are enabled, in their own address
+	if config.DebugServer != "" {
+		app.debugServer = &http.Server{
+			Addr:    config.DebugServer,
+			Handler: NewDebugHandler(),
+		}
 	}
 
 	return app, nil
 }
 
+// TriggerSchedules starts the scheduled executions of all the clusters now, as the trigger endpoint does
+func (a *App) TriggerSchedules() error {
+	if a.scheduler == nil {
+		return ErrSchedulerDisabled
+	}
+	a.scheduler.TriggerAll()
+
+	return nil
+}
+
+// Reload applies the settings of the reloaded configuration that do not need a restart: the maximum parallel
+// clusters, the heartbeat interval, the callbacks url and the full resync interval
+func (a *App) Reload(config *Config) error {
+	// The runner service rejects the configurations it cannot apply, nothing is applied then
+	if err := a.runnerService.Reload(config); err != nil {
+		return err
+	}
+
+	if a.executionWorkerPool != nil {
+		a.executionWorkerPool.SetWorkersNumber(config.MaxParallelClusters)
+	}
+	if a.registrar != nil {
+		a.registrar.SetHeartbeatInterval(config.HeartbeatInterval)
+	}
+
+	return nil
+}
+
 func (a *App) Start(ctx context.Context) error {
 	address := fmt.Sprintf("%s:%d", a.config.Host, a.config.Port)
+	// There is no write timeout, as the execution logs are streamed while the execution runs
 	webServer := &http.Server{
 		Addr:           address,
 		Handler:        a.webEngine,
 		ReadTimeout:    10 * time.Second,
-		WriteTimeout:   10 * time.Second,
 		MaxHeaderBytes: 1 << 20,
 	}
 
+	// With systemd socket activation, the APIs are served in the sockets of the socket units instead
+	listeners, err := systemdListeners()
+	if err != nil {
+		return err
+	}
+
 	g, ctx := errgroup.WithContext(ctx)
 
-	log.Infof("Starting web server at %s", address)
-	g.Go(func() error {
-		err := webServer.ListenAndServe()
-		if err != nil && err != http.ErrServerClosed {
-			return err
+	info := version.GetInfo()
+	log.Infof(
+		"Trento Runner %s version %s, git commit %s, built on %s, embedded checks %s",
+		info.Flavor, info.Version, info.GitSha, info.BuildDate, EmbeddedChecksHash())
+
+	if listener, ok := listeners[httpSocketName]; ok {
+		log.Infof("Starting web server at the systemd socket %s", listener.Addr())
+		g.Go(func() error {
+			err := webServer.Serve(listener)
+			if err != nil && err != http.ErrServerClosed {
+				return err
+			}
+			return nil
+		})
+	} else {
+		log.Infof("Starting web server at %s", address)
+		g.Go(func() error {
+			err := webServer.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				return err
+			}
+			return nil
+		})
+	}
+
+	if a.grpcServer != nil {
+		grpcAddress := fmt.Sprintf("%s:%d", a.config.Host, a.config.GrpcPort)
+		listener, activated := listeners[grpcSocketName]
+
+		if activated {
+			log.Infof("Starting gRPC server at the systemd socket %s", listener.Addr())
+		} else {
+			log.Infof("Starting gRPC server at %s", grpcAddress)
 		}
-		return nil
+		g.Go(func() error {
+			if !activated {
+				var err error
+				if listener, err = net.Listen("tcp", grpcAddress); err != nil {
+					return err
+				}
+			}
+
+			err := a.grpcServer.Serve(listener)
+			if err != nil && err != grpc.ErrServerStopped {
+				return err
+			}
+			return nil
+		})
+	}
+
+	if a.debugServer != nil {
+		log.Warnf("Starting debug server at %s, do not expose it publicly", a.debugServer.Addr)
+		g.Go(func() error {
+			err := a.debugServer.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				return err
+			}
+			return nil
+		})
+	}
+
+	a.runExecutionServices(ctx, g)
+
+	if a.amqpConsumer != nil {
+		log.Infof("Starting AMQP execution requests consumer....")
+		g.Go(func() error {
+			a.amqpConsumer.Run(ctx)
+			return nil
+		})
+	}
+
+	if a.scheduler != nil {
+		log.Infof("Starting executions scheduler....")
+		g.Go(func() error {
+			return a.scheduler.Run(ctx)
+		})
+	}
+
+	for _, catalogPublisher := range a.catalogPublishers {
+		catalogPublisher := catalogPublisher
+		g.Go(func() error {
+			catalogPublisher.Run(ctx)
+			return nil
+		})
+	}
+
+	if a.registrar != nil {
+		g.Go(func() error {
+			a.registrar.Run(ctx)
+			return nil
+		})
+	}
+
+	// The Trento server is checked while the catalog is built, its outages are retried without blocking the startup
+	if a.serverConnectivity != nil {
+		g.Go(func() error {
+			a.serverConnectivity.Run(ctx)
+			return nil
+		})
+	}
+
+	log.Infof("Building catalog....")
+	// The runner is ready for the systemd notify units once the catalog is built, the startup phases are reported
+	g.Go(func() error {
+		return a.runnerService.BuildCatalog()
 	})
 
+	go func() {
+		<-ctx.Done()
+		notifySystemd("STOPPING=1")
+		log.Info("Web server is shutting down.")
+		webServer.Close()
+		if a.grpcServer != nil {
+			a.grpcServer.Stop()
+		}
+		if a.debugServer != nil {
+			a.debugServer.Close()
+		}
+	}()
+
+	err = g.Wait()
+	a.shutdown()
+
+	return err
+}
+
+// runExecutionServices starts the worker pool running the executions, and the callbacks dispatcher and the webhooks
+// notifier reporting them, until the context is done. The callbacks dispatcher is stopped after the worker pool,
+// so the results of the executions drained during the shutdown are sent as well
+func (a *App) runExecutionServices(ctx context.Context, g *errgroup.Group) {
+	dispatcherCtx, stopDispatcher := context.WithCancel(context.Background())
+
+	removeStaleWorkDirs(a.config)
+
 	log.Infof("Starting execution requests worker pool....")
 	g.Go(func() error {
+		defer stopDispatcher()
 		a.executionWorkerPool.Run(ctx)
 		return nil
 	})
 
-	log.Infof("Building catalog....")
+	log.Infof("Starting callbacks dispatcher....")
 	g.Go(func() error {
-		err := a.runnerService.BuildCatalog()
-		if err != nil {
-			return err
-		}
+		a.callbacksDispatcher.Run(dispatcherCtx)
 		return nil
 	})
 
-	go func() {
-		<-ctx.Done()
-		log.Info("Web server is shutting down.")
-		webServer.Close()
-	}()
+	if a.webhooksNotifier != nil {
+		log.Infof("Starting webhooks notifier....")
+		g.Go(func() error {
+			a.webhooksNotifier.Run(dispatcherCtx)
+			return nil
+		})
+	}
+}
 
-	return g.Wait()
+// shutdown closes the executions database and flushes the traces, once the services are stopped
+func (a *App) shutdown() {
+	if a.executionsStore != nil {
+		if closeErr := a.executionsStore.Close(); closeErr != nil {
+			log.Errorf("Error closing the executions database: %s", closeErr)
+		}
+	}
+
+	if a.tracerProvider != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+		if shutdownErr := a.tracerProvider.Shutdown(shutdownCtx); shutdownErr != nil {
+			log.Errorf("Error flushing the traces: %s", shutdownErr)
+		}
+	}
 }
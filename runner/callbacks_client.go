@@ -2,15 +2,22 @@ package runner
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
+	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/google/uuid"
 )
 
+var connectivityTimeout = time.Second * 2
+
 //go:generate mockery --name=CallbacksClient
 
 type CallbacksClient interface {
@@ -18,12 +25,15 @@ type CallbacksClient interface {
 }
 
 type callbacksClient struct {
+	urlMu        sync.RWMutex
 	callbacksUrl string
 	httpClient   *http.Client
+	limiter      *rateLimiter
+	signer       *ResultsSigner
 }
 
-func NewCallbacksClient(callbacksUrl string) *callbacksClient {
-	httpClient := &http.Client{}
+func NewCallbacksClient(callbacksUrl string, transport http.RoundTripper) *callbacksClient {
+	httpClient := &http.Client{Transport: transport}
 
 	return &callbacksClient{
 		callbacksUrl: callbacksUrl,
@@ -31,29 +41,144 @@ func NewCallbacksClient(callbacksUrl string) *callbacksClient {
 	}
 }
 
+// SetCallbacksUrl changes the url where the next callbacks are sent, when the configuration is reloaded
+func (c *callbacksClient) SetCallbacksUrl(callbacksUrl string) {
+	c.urlMu.Lock()
+	defer c.urlMu.Unlock()
+
+	c.callbacksUrl = callbacksUrl
+}
+
+func (c *callbacksClient) url() string {
+	c.urlMu.RLock()
+	defer c.urlMu.RUnlock()
+
+	return c.callbacksUrl
+}
+
+// SetRateLimit limits the callbacks requests sent to the server to the given requests per second.
+// The requests are not limited if the rate is 0
+func (c *callbacksClient) SetRateLimit(rate float64) {
+	c.limiter = newRateLimiter(rate)
+}
+
+// SetSigner signs the body of each callbacks request, sent in the results signature header.
+// The requests are not signed if the signer is nil
+func (c *callbacksClient) SetSigner(signer *ResultsSigner) {
+	c.signer = signer
+}
+
+// checkServerConnectivity opens a connection with the host of the given url to check if it is available
+func checkServerConnectivity(serverUrl string) error {
+	u, err := url.Parse(serverUrl)
+	if err != nil {
+		return err
+	}
+
+	address := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+		address = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	conn, err := net.DialTimeout("tcp", address, connectivityTimeout)
+	if err != nil {
+		return err
+	}
+
+	return conn.Close()
+}
+
 func (c *callbacksClient) Callback(executionID uuid.UUID, event string, payload interface{}) error {
+	return c.CallbackContext(context.Background(), executionID, event, payload)
+}
+
+// CallbackContext sends the callback, stopping the wait for the rate limit and the request when the context is done
+func (c *callbacksClient) CallbackContext(ctx context.Context, executionID uuid.UUID, event string, payload interface{}) error {
 	log.Debugf("Executing callback for execution %s with event %s", executionID, event)
 
-	requestBody, err := json.Marshal(map[string]interface{}{
-		"execution_id": executionID.String(),
-		"event":        event,
-		"payload":      payload,
-	})
+	requestBody, err := json.Marshal(callbackBody(executionID, event, payload))
 	if err != nil {
 		return err
 	}
 
-	resp, err := c.httpClient.Post(c.callbacksUrl, "application/json", bytes.NewBuffer(requestBody))
+	statusCode, err := c.post(ctx, requestBody)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusAccepted {
+	if statusCode != http.StatusAccepted {
 		return fmt.Errorf(
 			"something wrong happened while sending the callback data. Status: %d, Execution: %s, Event: %s",
-			resp.StatusCode, executionID, event)
+			statusCode, executionID, event)
 	}
 
 	return nil
 }
+
+// CallbackBatch sends several callbacks in a single request, as a list of callbacks
+func (c *callbacksClient) CallbackBatch(ctx context.Context, requests []*callbackRequest) error {
+	log.Debugf("Executing a batch of %d callbacks", len(requests))
+
+	callbacks := make([]map[string]interface{}, 0, len(requests))
+	for _, request := range requests {
+		callbacks = append(callbacks, callbackBody(request.executionID, request.event, request.payload))
+	}
+
+	requestBody, err := json.Marshal(callbacks)
+	if err != nil {
+		return err
+	}
+
+	statusCode, err := c.post(ctx, requestBody)
+	if err != nil {
+		return err
+	}
+
+	if statusCode != http.StatusAccepted {
+		return fmt.Errorf(
+			"something wrong happened while sending the callbacks batch. Status: %d, Callbacks: %d",
+			statusCode, len(requests))
+	}
+
+	return nil
+}
+
+func (c *callbacksClient) post(ctx context.Context, requestBody []byte) (int, error) {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url(), bytes.NewBuffer(requestBody))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if c.signer != nil {
+		signature, err := c.signer.Sign(requestBody)
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set(resultsSignatureHeader, signature)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
+
+func callbackBody(executionID uuid.UUID, event string, payload interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"execution_id": executionID.String(),
+		"event":        event,
+		"payload":      payload,
+	}
+}
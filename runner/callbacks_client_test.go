@@ -1,10 +1,18 @@
 package runner
 
 import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/suite"
@@ -21,7 +29,7 @@ func TestCallbacksTestSuite(t *testing.T) {
 }
 
 func (suite *CallbacksTestSuite) SetupSuite() {
-	suite.configuredClient = NewCallbacksClient("http://192.168.1.1:8000/api/runner/callbacks")
+	suite.configuredClient = NewCallbacksClient("http://192.168.1.1:8000/api/runner/callbacks", nil)
 }
 
 func (suite *CallbacksTestSuite) Test_Callback() {
@@ -54,3 +62,93 @@ func (suite *CallbacksTestSuite) Test_Callback() {
 
 	suite.NoError(err)
 }
+
+func (suite *CallbacksTestSuite) Test_CallbackContext_RateLimitCancelled() {
+	client := NewCallbacksClient("http://192.168.1.1:8000/api/runner/callbacks", nil)
+	client.SetRateLimit(0.001)
+	requests := 0
+	client.httpClient.Transport = helpers.RoundTripFunc(func(req *http.Request) *http.Response {
+		requests++
+		return &http.Response{StatusCode: 202, Body: ioutil.NopCloser(bytes.NewReader(nil))}
+	})
+
+	suite.NoError(client.CallbackContext(context.Background(), uuid.New(), "new_callback_event", nil))
+
+	// The next callback waits for the rate limit until the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := client.CallbackContext(ctx, uuid.New(), "new_callback_event", nil)
+	suite.ErrorIs(err, context.DeadlineExceeded)
+	suite.Equal(1, requests)
+}
+
+func (suite *CallbacksTestSuite) Test_CallbackBatch() {
+	client := NewCallbacksClient("http://192.168.1.1:8000/api/runner/callbacks", nil)
+
+	dummyID := uuid.New()
+	requests := []*callbackRequest{
+		{executionID: dummyID, event: "check_result", payload: map[string]interface{}{"check_id": "156F64"}},
+		{executionID: dummyID, event: "check_result", payload: map[string]interface{}{"check_id": "53D035"}},
+	}
+
+	client.httpClient.Transport = helpers.RoundTripFunc(func(req *http.Request) *http.Response {
+		requestBody, _ := json.Marshal([]map[string]interface{}{
+			{"execution_id": dummyID, "event": "check_result", "payload": map[string]interface{}{"check_id": "156F64"}},
+			{"execution_id": dummyID, "event": "check_result", "payload": map[string]interface{}{"check_id": "53D035"}},
+		})
+
+		outgoingRequestBody, _ := ioutil.ReadAll(req.Body)
+
+		suite.EqualValues(requestBody, outgoingRequestBody)
+		return &http.Response{
+			StatusCode: 202,
+		}
+	})
+
+	suite.NoError(client.CallbackBatch(context.Background(), requests))
+
+	client.httpClient.Transport = helpers.RoundTripFunc(func(req *http.Request) *http.Response {
+		return &http.Response{
+			StatusCode: 413,
+		}
+	})
+
+	suite.EqualError(
+		client.CallbackBatch(context.Background(), requests),
+		"something wrong happened while sending the callbacks batch. Status: 413, Callbacks: 2")
+}
+
+func (suite *CallbacksTestSuite) Test_Callback_Signed() {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	signer, err := NewResultsSigner(&Config{ResultsSigningKey: writeSigningKey(suite.T(), tmpDir, key)})
+	suite.NoError(err)
+
+	client := NewCallbacksClient("http://192.168.1.1:8000/api/runner/callbacks", nil)
+	client.SetSigner(signer)
+
+	client.httpClient.Transport = helpers.RoundTripFunc(func(req *http.Request) *http.Response {
+		outgoingRequestBody, _ := ioutil.ReadAll(req.Body)
+
+		suite.Equal("application/json", req.Header.Get("Content-Type"))
+		_, err := verifyDetachedJWS(req.Header.Get(resultsSignatureHeader), outgoingRequestBody, key.Public())
+		suite.NoError(err)
+		return &http.Response{
+			StatusCode: 202,
+		}
+	})
+
+	suite.NoError(client.Callback(uuid.New(), executionFinishedEvent, map[string]string{"cluster_id": "cluster1"}))
+}
+
+func (suite *CallbacksTestSuite) Test_CheckServerConnectivity() {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	suite.NoError(checkServerConnectivity(server.URL + "/api/runner/callbacks"))
+
+	server.Close()
+	suite.Error(checkServerConnectivity(server.URL + "/api/runner/callbacks"))
+}
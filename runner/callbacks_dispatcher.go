@@ -0,0 +1,247 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	callbacksChannelSize = 999
+)
+
+var callbacksRetries = 5
+var callbacksRetryInterval = time.Second * 2
+
+func callbacksRetryPolicy() RetryPolicy {
+	return RetryPolicy{Attempts: callbacksRetries, Interval: callbacksRetryInterval}
+}
+
+// batchableEvents are the callbacks events sent in batches, if the batch size is set.
+// The execution lifecycle events are always sent one by one
+var batchableEvents = map[string]bool{
+	checkResultEvent:   true,
+	hostCompletedEvent: true,
+	partialResultEvent: true,
+}
+
+// batchCallbacksClient is a callbacks client able to send several callbacks in a single request
+type batchCallbacksClient interface {
+	CallbacksClient
+	CallbackBatch(ctx context.Context, requests []*callbackRequest) error
+}
+
+// contextCallbacksClient is a callbacks client that stops sending a callback when the context is done
+type contextCallbacksClient interface {
+	CallbacksClient
+	CallbackContext(ctx context.Context, executionID uuid.UUID, event string, payload interface{}) error
+}
+
+type callbackRequest struct {
+	upstream    string
+	executionID uuid.UUID
+	event       string
+	payload     interface{}
+}
+
+// CallbacksDispatcher sends the execution lifecycle callbacks to the Trento server
+// in the background, retrying them if the server is not able to process them.
+// Each callback is sent to all the given clients, and to the client of the upstream that requested the execution
+type CallbacksDispatcher struct {
+	callbacksClients []CallbacksClient
+	upstreamsClients map[string]CallbacksClient
+	queue            chan *callbackRequest
+	batchSize        int
+}
+
+func NewCallbacksDispatcher(callbacksClients ...CallbacksClient) *CallbacksDispatcher {
+	return &CallbacksDispatcher{
+		callbacksClients: callbacksClients,
+		upstreamsClients: make(map[string]CallbacksClient),
+		queue:            make(chan *callbackRequest, callbacksChannelSize),
+	}
+}
+
+// SetUpstreamClient sets the client receiving only the callbacks of the executions requested by the upstream.
+// The default Trento server is the upstream without name
+func (d *CallbacksDispatcher) SetUpstreamClient(upstream string, callbacksClient CallbacksClient) {
+	d.upstreamsClients[upstream] = callbacksClient
+}
+
+// SetBatchSize sets the maximum number of check results sent in a single request to the clients supporting it.
+// The callbacks are sent one by one if it is 0 or 1
+func (d *CallbacksDispatcher) SetBatchSize(batchSize int) {
+	d.batchSize = batchSize
+}
+
+// Dispatch queues a new callback of an execution requested by the default Trento server
+func (d *CallbacksDispatcher) Dispatch(executionID uuid.UUID, event string, payload interface{}) error {
+	return d.DispatchUpstream("", executionID, event, payload)
+}
+
+// DispatchUpstream queues a new callback of an execution requested by the given upstream
+func (d *CallbacksDispatcher) DispatchUpstream(upstream string, executionID uuid.UUID, event string, payload interface{}) error {
+	request := &callbackRequest{
+		upstream:    upstream,
+		executionID: executionID,
+		event:       event,
+		payload:     payload,
+	}
+
+	select {
+	case d.queue <- request:
+		log.Debugf("Dispatched callback for execution %s with event %s", executionID, event)
+		return nil
+	default:
+		return fmt.Errorf("Cannot dispatch more callbacks")
+	}
+}
+
+// Run sends the queued callbacks until the context is done.
+// The pending callbacks are flushed, without retries, before returning
+func (d *CallbacksDispatcher) Run(ctx context.Context) {
+	log.Infof("Starting callbacks dispatcher")
+
+	for {
+		select {
+		case request := <-d.queue:
+			d.dispatch(ctx, d.batch(request), true)
+		case <-ctx.Done():
+			log.Infof("Callbacks dispatcher is shutting down... Flushing pending callbacks.")
+			// The context is already done, the pending callbacks are sent without it
+			d.flush(context.Background())
+			return
+		}
+	}
+}
+
+// batch returns the callback with the batchable callbacks queued right after it, up to the batch size.
+// The first callback that cannot be batched ends the batch, so the callbacks are sent in order
+func (d *CallbacksDispatcher) batch(request *callbackRequest) []*callbackRequest {
+	requests := []*callbackRequest{request}
+	if d.batchSize <= 1 || !batchableEvents[request.event] {
+		return requests
+	}
+
+	for len(requests) < d.batchSize {
+		select {
+		case next := <-d.queue:
+			requests = append(requests, next)
+			if !batchableEvents[next.event] {
+				return requests
+			}
+		default:
+			return requests
+		}
+	}
+
+	return requests
+}
+
+// dispatch sends the callbacks to their clients, in batches to the clients that support them
+func (d *CallbacksDispatcher) dispatch(ctx context.Context, requests []*callbackRequest, retry bool) {
+	clients := []CallbacksClient{}
+	clientRequests := make(map[CallbacksClient][]*callbackRequest)
+	for _, request := range requests {
+		for _, callbacksClient := range d.requestClients(request) {
+			if _, ok := clientRequests[callbacksClient]; !ok {
+				clients = append(clients, callbacksClient)
+			}
+			clientRequests[callbacksClient] = append(clientRequests[callbacksClient], request)
+		}
+	}
+
+	for _, callbacksClient := range clients {
+		pending := clientRequests[callbacksClient]
+		batchClient, ok := callbacksClient.(batchCallbacksClient)
+		if !ok || d.batchSize <= 1 {
+			for _, request := range pending {
+				d.send(ctx, callbacksClient, request, retry)
+			}
+			continue
+		}
+
+		for len(pending) > 0 {
+			size := 0
+			for size < len(pending) && batchableEvents[pending[size].event] {
+				size++
+			}
+			if size <= 1 {
+				d.send(ctx, callbacksClient, pending[0], retry)
+				pending = pending[1:]
+				continue
+			}
+			d.sendBatch(ctx, batchClient, pending[:size], retry)
+			pending = pending[size:]
+		}
+	}
+}
+
+func (d *CallbacksDispatcher) requestClients(request *callbackRequest) []CallbacksClient {
+	upstreamClient, ok := d.upstreamsClients[request.upstream]
+	if !ok {
+		return d.callbacksClients
+	}
+
+	return append([]CallbacksClient{upstreamClient}, d.callbacksClients...)
+}
+
+func (d *CallbacksDispatcher) send(ctx context.Context, callbacksClient CallbacksClient, request *callbackRequest, retry bool) {
+	d.deliver(ctx, fmt.Sprintf("Execution ID: %s, Event: %s", request.executionID, request.event), retry,
+		func(ctx context.Context) error {
+			if contextClient, ok := callbacksClient.(contextCallbacksClient); ok {
+				return contextClient.CallbackContext(ctx, request.executionID, request.event, request.payload)
+			}
+			return callbacksClient.Callback(request.executionID, request.event, request.payload)
+		})
+}
+
+func (d *CallbacksDispatcher) sendBatch(
+	ctx context.Context, batchClient batchCallbacksClient, requests []*callbackRequest, retry bool) {
+
+	d.deliver(ctx, fmt.Sprintf("Batch of %d callbacks", len(requests)), retry, func(ctx context.Context) error {
+		return batchClient.CallbackBatch(ctx, requests)
+	})
+}
+
+func (d *CallbacksDispatcher) deliver(
+	ctx context.Context, description string, retry bool, callback func(ctx context.Context) error) {
+
+	if !retry {
+		if err := callback(ctx); err != nil {
+			log.Errorf("Error flushing callback. %s. Err: %s", description, err)
+		}
+		return
+	}
+
+	err := callbacksRetryPolicy().Do(ctx, func() error { return callback(ctx) }, func(_ int, wait time.Duration, err error) {
+		log.Warnf("Error running callback, retrying in %s. %s. Err: %s", wait, description, err)
+	})
+	if err == nil {
+		return
+	}
+
+	if ctx.Err() != nil {
+		// Shutting down, give the callback a last chance before leaving
+		if err := callback(context.Background()); err != nil {
+			log.Errorf("Error running callback on shutdown. %s. Err: %s", description, err)
+		}
+		return
+	}
+
+	log.Errorf("Error running callback, giving up after %d attempts. %s. Err: %s", callbacksRetries, description, err)
+}
+
+func (d *CallbacksDispatcher) flush(ctx context.Context) {
+	for {
+		select {
+		case request := <-d.queue:
+			d.dispatch(ctx, d.batch(request), false)
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/trento-project/runner/runner/mocks"
+)
+
+type CallbacksDispatcherTestCase struct {
+	suite.Suite
+	callbacksClient *mocks.CallbacksClient
+	dispatcher      *CallbacksDispatcher
+}
+
+func TestCallbacksDispatcherTestCase(t *testing.T) {
+	suite.Run(t, new(CallbacksDispatcherTestCase))
+}
+
+func (suite *CallbacksDispatcherTestCase) SetupTest() {
+	callbacksRetryInterval = time.Millisecond
+	suite.callbacksClient = new(mocks.CallbacksClient)
+	suite.dispatcher = NewCallbacksDispatcher(suite.callbacksClient)
+}
+
+func (suite *CallbacksDispatcherTestCase) Test_Run() {
+	dummyID := uuid.New()
+	payload := map[string]string{"cluster_id": "cluster1"}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	suite.callbacksClient.On("Callback", dummyID, "execution_finished", payload).Run(func(_ mock.Arguments) {
+		wg.Done()
+	}).Return(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go suite.dispatcher.Run(ctx)
+
+	err := suite.dispatcher.Dispatch(dummyID, "execution_finished", payload)
+	wg.Wait()
+
+	suite.NoError(err)
+	suite.callbacksClient.AssertNumberOfCalls(suite.T(), "Callback", 1)
+}
+
+func (suite *CallbacksDispatcherTestCase) Test_Run_Upstreams() {
+	dummyID := uuid.New()
+	payload := map[string]string{"cluster_id": "cluster1"}
+	upstreamClient := new(mocks.CallbacksClient)
+	suite.dispatcher.SetUpstreamClient("customer1", upstreamClient)
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+
+	suite.callbacksClient.On("Callback", dummyID, mock.Anything, payload).Run(func(_ mock.Arguments) {
+		wg.Done()
+	}).Return(nil)
+	upstreamClient.On("Callback", dummyID, "execution_finished", payload).Run(func(_ mock.Arguments) {
+		wg.Done()
+	}).Return(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go suite.dispatcher.Run(ctx)
+
+	suite.dispatcher.DispatchUpstream("customer1", dummyID, "execution_finished", payload)
+	suite.dispatcher.Dispatch(dummyID, "execution_started", payload)
+	wg.Wait()
+
+	// The callbacks of the default server executions are not sent to the upstream
+	suite.callbacksClient.AssertNumberOfCalls(suite.T(), "Callback", 2)
+	upstreamClient.AssertNumberOfCalls(suite.T(), "Callback", 1)
+}
+
+func (suite *CallbacksDispatcherTestCase) Test_Run_Retry() {
+	dummyID := uuid.New()
+	payload := map[string]string{"cluster_id": "cluster1"}
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+
+	suite.callbacksClient.On("Callback", dummyID, "execution_finished", payload).Run(func(_ mock.Arguments) {
+		wg.Done()
+	}).Return(fmt.Errorf("server unavailable")).Twice()
+	suite.callbacksClient.On("Callback", dummyID, "execution_finished", payload).Run(func(_ mock.Arguments) {
+		wg.Done()
+	}).Return(nil).Once()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go suite.dispatcher.Run(ctx)
+
+	suite.dispatcher.Dispatch(dummyID, "execution_finished", payload)
+	wg.Wait()
+
+	suite.callbacksClient.AssertNumberOfCalls(suite.T(), "Callback", 3)
+}
+
+func (suite *CallbacksDispatcherTestCase) Test_Run_GiveUp() {
+	dummyID := uuid.New()
+	payload := map[string]string{"cluster_id": "cluster1"}
+
+	var wg sync.WaitGroup
+	wg.Add(callbacksRetries)
+
+	suite.callbacksClient.On("Callback", dummyID, "execution_finished", payload).Run(func(_ mock.Arguments) {
+		wg.Done()
+	}).Return(fmt.Errorf("server unavailable"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go suite.dispatcher.Run(ctx)
+
+	suite.dispatcher.Dispatch(dummyID, "execution_finished", payload)
+	wg.Wait()
+
+	suite.callbacksClient.AssertNumberOfCalls(suite.T(), "Callback", callbacksRetries)
+}
+
+func (suite *CallbacksDispatcherTestCase) Test_Run_FlushOnShutdown() {
+	dummyID := uuid.New()
+	payload := map[string]string{"cluster_id": "cluster1"}
+
+	suite.callbacksClient.On("Callback", dummyID, "execution_finished", payload).Return(nil)
+
+	suite.dispatcher.Dispatch(dummyID, "execution_finished", payload)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	suite.dispatcher.Run(ctx)
+
+	suite.Len(suite.dispatcher.queue, 0)
+}
+
+func (suite *CallbacksDispatcherTestCase) Test_Dispatch_Full() {
+	for range [callbacksChannelSize]int64{} {
+		suite.dispatcher.queue <- &callbackRequest{executionID: uuid.New()}
+	}
+
+	err := suite.dispatcher.Dispatch(uuid.New(), "execution_finished", nil)
+	suite.EqualError(err, "Cannot dispatch more callbacks")
+}
+
+// batchCallbacksClientStub records the events of each request, a single one or a batch
+type batchCallbacksClientStub struct {
+	requests [][]string
+}
+
+func (c *batchCallbacksClientStub) Callback(_ uuid.UUID, event string, _ interface{}) error {
+	c.requests = append(c.requests, []string{event})
+	return nil
+}
+
+func (c *batchCallbacksClientStub) CallbackBatch(_ context.Context, requests []*callbackRequest) error {
+	events := []string{}
+	for _, request := range requests {
+		events = append(events, request.event)
+	}
+	c.requests = append(c.requests, events)
+	return nil
+}
+
+func (suite *CallbacksDispatcherTestCase) Test_Run_Batches() {
+	dummyID := uuid.New()
+	batchClient := &batchCallbacksClientStub{}
+	dispatcher := NewCallbacksDispatcher(batchClient, suite.callbacksClient)
+	dispatcher.SetBatchSize(3)
+
+	suite.callbacksClient.On("Callback", dummyID, mock.Anything, mock.Anything).Return(nil)
+
+	for _, event := range []string{
+		executionStartedEvent, hostCompletedEvent, checkResultEvent, checkResultEvent, checkResultEvent,
+		checkResultEvent, executionFinishedEvent,
+	} {
+		dispatcher.Dispatch(dummyID, event, nil)
+	}
+
+	request := <-dispatcher.queue
+	suite.Equal([]*callbackRequest{request}, dispatcher.batch(request))
+	dispatcher.dispatch(context.Background(), []*callbackRequest{request}, true)
+
+	request = <-dispatcher.queue
+	dispatcher.dispatch(context.Background(), dispatcher.batch(request), true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dispatcher.Run(ctx)
+
+	suite.Equal([][]string{
+		{executionStartedEvent},
+		{hostCompletedEvent, checkResultEvent, checkResultEvent},
+		{checkResultEvent, checkResultEvent},
+		{executionFinishedEvent},
+	}, batchClient.requests)
+	// The clients without batches receive the callbacks one by one
+	suite.callbacksClient.AssertNumberOfCalls(suite.T(), "Callback", 7)
+}
@@ -1,9 +1,37 @@
 package runner
 
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
 const (
 	CatalogDestinationFile = "ansible/catalog.json"
+	catalogTemporarySuffix = ".tmp"
+
+	CatalogStatusBuilding = "building"
+	CatalogStatusReady    = "ready"
+	CatalogStatusFailed   = "failed"
 )
 
+// CatalogStatus is the state of the last catalog build. The catalog might be ready even if the
+// last build failed, as the previous catalog is kept in that case
+type CatalogStatus struct {
+	Status        string     `json:"status"`
+	Ready         bool       `json:"ready"`
+	Checks        int        `json:"checks"`
+	SchemaVersion int        `json:"schema_version"`
+	Version       string     `json:"version,omitempty"`
+	LastBuildAt   *time.Time `json:"last_build_at,omitempty"`
+	Error         string     `json:"error,omitempty"`
+	// The handshake is the result of the last catalog handshake with the Trento server, if it is configured
+	Handshake *CatalogHandshake `json:"handshake,omitempty"`
+}
+
 type Catalog []*CatalogCheck
 
 type CatalogCheck struct {
@@ -16,4 +44,69 @@ type CatalogCheck struct {
 	Implementation string `json:"implementation,omitempty"`
 	Labels         string `json:"labels,omitempty"`
 	Premium        bool   `json:"premium,omitempty"`
+	// Rolling checks run in a few hosts of the cluster at a time, as they load the nodes momentarily
+	Rolling bool `json:"rolling,omitempty"`
+	// Severity is the result of the check when it fails, warning or critical
+	Severity string `json:"severity,omitempty"`
+	// CriticalThreshold is the number of hosts of the cluster where a warning check must fail to be critical
+	CriticalThreshold int `json:"critical_threshold,omitempty"`
+	// Platforms are the hosts operating systems where the check runs, linux only if they are not tagged
+	Platforms []string `json:"platforms,omitempty"`
+	// Architectures are the hosts cpu architectures where the check runs, all of them if they are not tagged
+	Architectures []string `json:"architectures,omitempty"`
+	// DependsOn are the ids of the checks that must pass in a host before the check runs in it
+	DependsOn []string `json:"depends_on,omitempty"`
+	// Remediable checks apply their fix when they are run out of the ansible check mode, in the remediation executions
+	Remediable bool `json:"remediable,omitempty"`
+	// Translations are the localized description and remediation by language, from the check metadata and the translation files
+	Translations map[string]*CheckTranslation `json:"translations,omitempty"`
+}
+
+// Version identifies the catalog content, changing if any check is changed
+func (c *Catalog) Version() string {
+	if c == nil {
+		return ""
+	}
+
+	content, _ := json.Marshal(c)
+	return fmt.Sprintf("%x", sha256.Sum256(content))
+}
+
+// Providers returns the providers with checks in the catalog, sorted by name
+func (c *Catalog) Providers() []string {
+	providers := []string{}
+	if c == nil {
+		return providers
+	}
+
+	found := make(map[string]bool)
+	for _, check := range *c {
+		if !found[check.Provider] {
+			found[check.Provider] = true
+			providers = append(providers, check.Provider)
+		}
+	}
+	sort.Strings(providers)
+
+	return providers
+}
+
+// Filter returns the checks of the given provider and group. Empty values match all the checks
+func (c *Catalog) Filter(provider, group string) *Catalog {
+	filtered := Catalog{}
+	if c == nil {
+		return &filtered
+	}
+
+	for _, check := range *c {
+		if provider != "" && !strings.EqualFold(check.Provider, provider) {
+			continue
+		}
+		if group != "" && !strings.EqualFold(check.Group, group) {
+			continue
+		}
+		filtered = append(filtered, check)
+	}
+
+	return &filtered
 }
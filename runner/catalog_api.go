@@ -1,15 +1,77 @@
 package runner
 
 import (
+	"errors"
+
 	"github.com/gin-gonic/gin"
 )
 
+// CatalogHandler returns the checks catalog, filtered by the optional provider and group query parameters.
+// With the lang query parameter or the Accept-Language header, the checks are localized to the requested languages
 func CatalogHandler(runnerService RunnerService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if runnerService.IsCatalogReady() {
-			c.JSON(200, runnerService.GetCatalog())
-		} else {
+		if !runnerService.IsCatalogReady() {
+			c.JSON(204, nil)
+			return
+		}
+
+		catalog := runnerService.GetCatalog()
+		provider := c.Query("provider")
+		group := c.Query("group")
+		if provider != "" || group != "" {
+			catalog = catalog.Filter(provider, group)
+		}
+
+		if languages := requestLanguages(c); len(languages) > 0 {
+			catalog = catalog.Localize(languages)
+		}
+
+		c.JSON(200, catalog)
+	}
+}
+
+// requestLanguages returns the languages of the lang query parameter, or of the Accept-Language header if it is not set
+func requestLanguages(c *gin.Context) []string {
+	if lang := c.Query("lang"); lang != "" {
+		return ParseAcceptLanguage(lang)
+	}
+
+	return ParseAcceptLanguage(c.GetHeader("Accept-Language"))
+}
+
+// CatalogProvidersHandler returns the providers with a catalog, so the server can request the catalog of a cluster provider
+func CatalogProvidersHandler(runnerService RunnerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !runnerService.IsCatalogReady() {
 			c.JSON(204, nil)
+			return
+		}
+
+		c.JSON(200, runnerService.GetCatalog().Providers())
+	}
+}
+
+// CatalogStatusHandler returns the state of the catalog build, with the error if the last build failed
+func CatalogStatusHandler(runnerService RunnerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, runnerService.GetCatalogStatus())
+	}
+}
+
+// CatalogRebuildHandler builds the catalog again, picking up the checks changed on disk,
+// and returns the new catalog
+func CatalogRebuildHandler(runnerService RunnerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := runnerService.RebuildCatalog()
+		if errors.Is(err, ErrCatalogRebuilding) {
+			c.AbortWithStatusJSON(409, gin.H{"status": "nok", "message": err.Error()})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(500, gin.H{"status": "nok", "message": err.Error()})
+			return
 		}
+
+		c.JSON(200, runnerService.GetCatalog())
 	}
 }
@@ -2,8 +2,10 @@ package runner
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 )
@@ -41,6 +43,42 @@ func (suite *CatalogApiTestCase) Test_GetCatalogTest_NotReady() {
 	suite.Equal("", resp.Body.String())
 }
 
+func (suite *CatalogApiTestCase) Test_GetCatalogStatus() {
+	lastBuildAt := time.Date(2022, 5, 10, 10, 0, 0, 0, time.UTC)
+	mockRunnerService := new(MockRunnerService)
+	mockRunnerService.On("GetCatalogStatus").Return(&CatalogStatus{
+		Status:        "failed",
+		Ready:         false,
+		SchemaVersion: 1,
+		LastBuildAt:   &lastBuildAt,
+		Error:         "exit status 1",
+		Handshake: &CatalogHandshake{
+			Status:               HandshakeStale,
+			ServerSchemaVersion:  1,
+			ServerCatalogVersion: "newversion",
+			CheckedAt:            lastBuildAt,
+		},
+	})
+
+	deps := setupTestDependencies()
+	deps.runnerService = mockRunnerService
+
+	app, err := NewAppWithDeps(suite.config, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/catalog/status", nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(200, resp.Code)
+	suite.JSONEq(
+		`{"status":"failed","ready":false,"checks":0,"schema_version":1,"last_build_at":"2022-05-10T10:00:00Z","error":"exit status 1",`+
+			`"handshake":{"status":"stale","server_schema_version":1,"server_catalog_version":"newversion","checked_at":"2022-05-10T10:00:00Z"}}`,
+		resp.Body.String())
+}
+
 func (suite *CatalogApiTestCase) Test_GetCatalogTest_Ready() {
 	returnedCatalog := &Catalog{
 		&CatalogCheck{
@@ -76,3 +114,165 @@ func (suite *CatalogApiTestCase) Test_GetCatalogTest_Ready() {
 	suite.Equal(200, resp.Code)
 	suite.JSONEq(string(expectedJson), resp.Body.String())
 }
+
+func (suite *CatalogApiTestCase) Test_GetCatalogTest_Filtered() {
+	returnedCatalog := &Catalog{
+		&CatalogCheck{ID: "156F64", Name: "1.1.1", Group: "Corosync", Provider: "azure"},
+		&CatalogCheck{ID: "156F64", Name: "1.1.1", Group: "Corosync", Provider: "aws"},
+		&CatalogCheck{ID: "A1244C", Name: "1.2.1", Group: "Pacemaker", Provider: "azure"},
+	}
+
+	mockRunnerService := new(MockRunnerService)
+	mockRunnerService.On("IsCatalogReady").Return(true)
+	mockRunnerService.On("GetCatalog").Return(returnedCatalog)
+
+	deps := setupTestDependencies()
+	deps.runnerService = mockRunnerService
+
+	app, err := NewAppWithDeps(suite.config, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	cases := map[string]Catalog{
+		"/api/catalog?provider=azure":                 {(*returnedCatalog)[0], (*returnedCatalog)[2]},
+		"/api/catalog?group=corosync":                 {(*returnedCatalog)[0], (*returnedCatalog)[1]},
+		"/api/catalog?provider=azure&group=Pacemaker": {(*returnedCatalog)[2]},
+		"/api/catalog?provider=gcp":                   {},
+	}
+
+	for url, expectedCatalog := range cases {
+		resp := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", url, nil)
+		app.webEngine.ServeHTTP(resp, req)
+
+		expectedJson, _ := json.Marshal(expectedCatalog)
+		suite.Equal(200, resp.Code)
+		suite.JSONEq(string(expectedJson), resp.Body.String(), url)
+	}
+}
+
+func (suite *CatalogApiTestCase) Test_GetCatalogTest_Localized() {
+	returnedCatalog := &Catalog{
+		&CatalogCheck{
+			ID: "156F64", Name: "1.1.1", Group: "Corosync", Provider: "azure",
+			Description: "description", Remediation: "remediation",
+			Translations: map[string]*CheckTranslation{
+				"de": {Description: "Beschreibung", Remediation: "Behebung"},
+				"es": {Description: "descripción"},
+			},
+		},
+		&CatalogCheck{ID: "A1244C", Name: "1.2.1", Group: "Pacemaker", Provider: "azure", Description: "description"},
+	}
+
+	mockRunnerService := new(MockRunnerService)
+	mockRunnerService.On("IsCatalogReady").Return(true)
+	mockRunnerService.On("GetCatalog").Return(returnedCatalog)
+
+	deps := setupTestDependencies()
+	deps.runnerService = mockRunnerService
+
+	app, err := NewAppWithDeps(suite.config, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/catalog?group=Corosync", nil)
+	req.Header.Set("Accept-Language", "fr-CH, de-DE;q=0.9, es;q=0.8")
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(200, resp.Code)
+	suite.JSONEq(
+		`[{"id":"156F64","name":"1.1.1","group":"Corosync","provider":"azure","description":"Beschreibung","remediation":"Behebung"}]`,
+		resp.Body.String())
+
+	resp = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/api/catalog?lang=es", nil)
+	req.Header.Set("Accept-Language", "de")
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(200, resp.Code)
+	suite.JSONEq(
+		`[{"id":"156F64","name":"1.1.1","group":"Corosync","provider":"azure","description":"descripción","remediation":"remediation"},`+
+			`{"id":"A1244C","name":"1.2.1","group":"Pacemaker","provider":"azure","description":"description"}]`,
+		resp.Body.String())
+}
+
+func (suite *CatalogApiTestCase) Test_GetCatalogProvidersTest() {
+	returnedCatalog := &Catalog{
+		&CatalogCheck{ID: "156F64", Name: "1.1.1", Group: "Corosync", Provider: "azure"},
+		&CatalogCheck{ID: "156F64", Name: "1.1.1", Group: "Corosync", Provider: "aws"},
+		&CatalogCheck{ID: "A1244C", Name: "1.2.1", Group: "Pacemaker", Provider: "azure"},
+	}
+
+	mockRunnerService := new(MockRunnerService)
+	mockRunnerService.On("IsCatalogReady").Return(true)
+	mockRunnerService.On("GetCatalog").Return(returnedCatalog)
+
+	deps := setupTestDependencies()
+	deps.runnerService = mockRunnerService
+
+	app, err := NewAppWithDeps(suite.config, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/catalog/providers", nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(200, resp.Code)
+	suite.JSONEq(`["aws", "azure"]`, resp.Body.String())
+}
+
+func (suite *CatalogApiTestCase) Test_RebuildCatalogTest() {
+	returnedCatalog := &Catalog{
+		&CatalogCheck{
+			ID:       "156F64",
+			Name:     "1.1.1",
+			Group:    "Corosync",
+			Provider: "azure",
+		},
+	}
+
+	mockRunnerService := new(MockRunnerService)
+	mockRunnerService.On("RebuildCatalog").Return(nil)
+	mockRunnerService.On("GetCatalog").Return(returnedCatalog)
+
+	deps := setupTestDependencies()
+	deps.runnerService = mockRunnerService
+
+	app, err := NewAppWithDeps(suite.config, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/catalog/rebuild", nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	expectedJson, _ := json.Marshal(returnedCatalog)
+	suite.Equal(200, resp.Code)
+	suite.JSONEq(string(expectedJson), resp.Body.String())
+}
+
+func (suite *CatalogApiTestCase) Test_RebuildCatalogTest_Errors() {
+	for err, code := range map[error]int{ErrCatalogRebuilding: 409, fmt.Errorf("playbook error"): 500} {
+		mockRunnerService := new(MockRunnerService)
+		mockRunnerService.On("RebuildCatalog").Return(err)
+
+		deps := setupTestDependencies()
+		deps.runnerService = mockRunnerService
+
+		app, _ := NewAppWithDeps(suite.config, deps)
+
+		resp := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/catalog/rebuild", nil)
+		app.webEngine.ServeHTTP(resp, req)
+
+		expectedJson, _ := json.Marshal(map[string]string{"status": "nok", "message": err.Error()})
+		suite.Equal(code, resp.Code)
+		suite.JSONEq(string(expectedJson), resp.Body.String())
+	}
+}
@@ -0,0 +1,175 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"reflect"
+	"sort"
+)
+
+// CatalogDiff is the difference between two catalogs, e.g. the ones of two runner versions,
+// with the checks sorted by id
+type CatalogDiff struct {
+	Added   []*CatalogCheck `json:"added"`
+	Removed []*CatalogCheck `json:"removed"`
+	Changed []*CheckChange  `json:"changed"`
+}
+
+// CheckChange is a check in both catalogs with different fields
+type CheckChange struct {
+	ID     string         `json:"id"`
+	Name   string         `json:"name"`
+	Fields []*FieldChange `json:"fields"`
+}
+
+// FieldChange is the old and new values of a check field, by its json name. The value is nil if the field is not set
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// Empty tells if the catalogs have the same checks
+func (d *CatalogDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffCatalogs compares the checks of the old and the new catalogs by their id
+func DiffCatalogs(oldCatalog, newCatalog *Catalog) (*CatalogDiff, error) {
+	oldChecks := catalogChecksByID(oldCatalog)
+	newChecks := catalogChecksByID(newCatalog)
+	diff := &CatalogDiff{Added: []*CatalogCheck{}, Removed: []*CatalogCheck{}, Changed: []*CheckChange{}}
+
+	for _, id := range sortedCheckIDs(newChecks) {
+		oldCheck, ok := oldChecks[id]
+		if !ok {
+			diff.Added = append(diff.Added, newChecks[id])
+			continue
+		}
+
+		fields, err := diffCheckFields(oldCheck, newChecks[id])
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) > 0 {
+			diff.Changed = append(diff.Changed, &CheckChange{ID: id, Name: newChecks[id].Name, Fields: fields})
+		}
+	}
+
+	for _, id := range sortedCheckIDs(oldChecks) {
+		if _, ok := newChecks[id]; !ok {
+			diff.Removed = append(diff.Removed, oldChecks[id])
+		}
+	}
+
+	return diff, nil
+}
+
+func catalogChecksByID(catalog *Catalog) map[string]*CatalogCheck {
+	checks := make(map[string]*CatalogCheck)
+	if catalog == nil {
+		return checks
+	}
+
+	for _, check := range *catalog {
+		checks[check.ID] = check
+	}
+
+	return checks
+}
+
+func sortedCheckIDs(checks map[string]*CatalogCheck) []string {
+	ids := make([]string, 0, len(checks))
+	for id := range checks {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+// diffCheckFields compares the checks as they are serialized in the catalog, so every field is compared by its json name
+func diffCheckFields(oldCheck, newCheck *CatalogCheck) ([]*FieldChange, error) {
+	oldFields, err := checkFields(oldCheck)
+	if err != nil {
+		return nil, err
+	}
+	newFields, err := checkFields(newCheck)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for name := range oldFields {
+		names = append(names, name)
+	}
+	for name := range newFields {
+		if _, ok := oldFields[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := []*FieldChange{}
+	for _, name := range names {
+		if !reflect.DeepEqual(oldFields[name], newFields[name]) {
+			changes = append(changes, &FieldChange{Field: name, Old: oldFields[name], New: newFields[name]})
+		}
+	}
+
+	return changes, nil
+}
+
+func checkFields(check *CatalogCheck) (map[string]interface{}, error) {
+	content, err := json.Marshal(check)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]interface{}
+	err = json.Unmarshal(content, &fields)
+
+	return fields, err
+}
+
+// LoadCatalogFile reads a catalog file, either with the schema and content versions, as the runner writes it,
+// or as the checks list served in the catalog API and written by the older runners
+func LoadCatalogFile(file string) (*Catalog, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var catalog *Catalog
+	if err := json.Unmarshal(content, &catalog); err == nil {
+		return catalog, nil
+	}
+
+	var catalogFile *CatalogFile
+	if err := json.Unmarshal(content, &catalogFile); err != nil || catalogFile.Checks == nil {
+		return nil, fmt.Errorf("%s is not a valid catalog file", file)
+	}
+
+	return catalogFile.Checks, nil
+}
+
+// BuildRunnerCatalog creates the ansible file structure with the embedded checks and the ones of the catalog sources,
+// and builds the catalog of this runner, as it is built when the runner starts
+func BuildRunnerCatalog(ctx context.Context, config *Config) (*Catalog, error) {
+	if err := createAnsibleFiles(config.AnsibleFolder); err != nil {
+		return nil, err
+	}
+	if err := createAnsibleConfigFile(config); err != nil {
+		return nil, err
+	}
+
+	checksFolders, err := copyCatalogSources(ctx, NewCatalogSources(config), path.Join(config.AnsibleFolder, AnsibleChecks))
+	if err != nil {
+		return nil, err
+	}
+
+	return runCatalogPlaybook(ctx, config, checksFolders)
+}
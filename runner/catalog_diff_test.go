@@ -0,0 +1,68 @@
+package runner
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffCatalogs(t *testing.T) {
+	oldCatalog := &Catalog{
+		&CatalogCheck{ID: "156F64", Name: "1.1.1", Group: "Corosync", Provider: "azure", Severity: "warning"},
+		&CatalogCheck{ID: "53D035", Name: "1.1.2", Group: "Corosync", Provider: "azure"},
+		&CatalogCheck{ID: "21FCA6", Name: "1.1.3", Group: "Corosync", Provider: "azure", Platforms: []string{"linux"}},
+	}
+	newCatalog := &Catalog{
+		&CatalogCheck{ID: "21FCA6", Name: "1.1.3", Group: "Corosync", Provider: "azure", Platforms: []string{"linux"}},
+		&CatalogCheck{ID: "156F64", Name: "1.1.1", Group: "Corosync", Provider: "azure", Severity: "critical", Remediable: true},
+		&CatalogCheck{ID: "A1244C", Name: "1.2.1", Group: "Pacemaker", Provider: "azure"},
+	}
+
+	diff, err := DiffCatalogs(oldCatalog, newCatalog)
+	assert.NoError(t, err)
+	assert.False(t, diff.Empty())
+	assert.Equal(t, []*CatalogCheck{(*newCatalog)[2]}, diff.Added)
+	assert.Equal(t, []*CatalogCheck{(*oldCatalog)[1]}, diff.Removed)
+	assert.Equal(t, []*CheckChange{{
+		ID:   "156F64",
+		Name: "1.1.1",
+		Fields: []*FieldChange{
+			{Field: "remediable", Old: nil, New: true},
+			{Field: "severity", Old: "warning", New: "critical"},
+		},
+	}}, diff.Changed)
+
+	diff, err = DiffCatalogs(oldCatalog, oldCatalog)
+	assert.NoError(t, err)
+	assert.True(t, diff.Empty())
+}
+
+func TestLoadCatalogFile(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	catalog := &Catalog{&CatalogCheck{ID: "156F64", Name: "1.1.1", Group: "Corosync", Provider: "azure"}}
+
+	// The versioned catalog file of the runner, and the checks list of the older ones
+	versioned, _ := json.Marshal(NewCatalogFile(catalog))
+	ioutil.WriteFile(path.Join(tmpDir, "catalog.json"), versioned, 0644)
+	list, _ := json.Marshal(catalog)
+	ioutil.WriteFile(path.Join(tmpDir, "list.json"), list, 0644)
+
+	for _, file := range []string{"catalog.json", "list.json"} {
+		loaded, err := LoadCatalogFile(path.Join(tmpDir, file))
+		assert.NoError(t, err)
+		assert.Equal(t, catalog, loaded)
+	}
+
+	ioutil.WriteFile(path.Join(tmpDir, "invalid.json"), []byte(`{"checks": "none"}`), 0644)
+	_, err := LoadCatalogFile(path.Join(tmpDir, "invalid.json"))
+	assert.EqualError(t, err, path.Join(tmpDir, "invalid.json")+" is not a valid catalog file")
+
+	_, err = LoadCatalogFile(path.Join(tmpDir, "missing.json"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,163 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/trento-project/runner/version"
+)
+
+const (
+	// CatalogSchemaVersion is the version of the catalog structure, increased on incompatible changes
+	CatalogSchemaVersion = 1
+
+	HandshakeCompatible   = "compatible"
+	HandshakeStale        = "stale"
+	HandshakeIncompatible = "incompatible"
+	HandshakeFailed       = "failed"
+)
+
+var handshakeTimeout = time.Second * 10
+var handshakeRetryPolicy = RetryPolicy{Attempts: 5, Interval: time.Second * 2}
+
+// CatalogFile is the content of the catalog file, with the schema and content versions of the catalog
+type CatalogFile struct {
+	SchemaVersion int      `json:"schema_version"`
+	Version       string   `json:"version"`
+	Checks        *Catalog `json:"checks"`
+}
+
+func NewCatalogFile(catalog *Catalog) *CatalogFile {
+	return &CatalogFile{
+		SchemaVersion: CatalogSchemaVersion,
+		Version:       catalog.Version(),
+		Checks:        catalog,
+	}
+}
+
+type catalogHandshakeRequest struct {
+	RunnerVersion        string `json:"runner_version"`
+	CatalogSchemaVersion int    `json:"catalog_schema_version"`
+	CatalogVersion       string `json:"catalog_version"`
+	Checks               int    `json:"checks"`
+}
+
+// catalogHandshakeResponse has the catalog versions expected by the server, the empty ones are not checked
+type catalogHandshakeResponse struct {
+	CatalogSchemaVersion int    `json:"catalog_schema_version"`
+	CatalogVersion       string `json:"catalog_version"`
+}
+
+// CatalogHandshake is the result of the last catalog handshake with the Trento server. The catalog is stale
+// if the server expects another catalog version, and incompatible if it expects another schema version
+type CatalogHandshake struct {
+	Status               string    `json:"status"`
+	ServerSchemaVersion  int       `json:"server_schema_version,omitempty"`
+	ServerCatalogVersion string    `json:"server_catalog_version,omitempty"`
+	Error                string    `json:"error,omitempty"`
+	CheckedAt            time.Time `json:"checked_at"`
+}
+
+// NewCatalogHandshake advertises the runner catalog versions to the Trento server handshake url,
+// comparing them with the ones expected by the server
+func NewCatalogHandshake(ctx context.Context, httpClient *http.Client, handshakeUrl string, catalog *Catalog) *CatalogHandshake {
+	var response *catalogHandshakeResponse
+
+	err := handshakeRetryPolicy.Do(ctx, func() error {
+		var err error
+		response, err = requestCatalogHandshake(httpClient, handshakeUrl, catalog)
+		return err
+	}, func(_ int, wait time.Duration, err error) {
+		log.Warnf("Error in the catalog handshake with the Trento server, retrying in %s: %s", wait, err)
+	})
+
+	handshake := &CatalogHandshake{CheckedAt: time.Now().UTC()}
+	if err != nil {
+		handshake.Status = HandshakeFailed
+		handshake.Error = err.Error()
+		return handshake
+	}
+
+	handshake.ServerSchemaVersion = response.CatalogSchemaVersion
+	handshake.ServerCatalogVersion = response.CatalogVersion
+	switch {
+	case response.CatalogSchemaVersion != 0 && response.CatalogSchemaVersion != CatalogSchemaVersion:
+		handshake.Status = HandshakeIncompatible
+	case response.CatalogVersion != "" && response.CatalogVersion != catalog.Version():
+		handshake.Status = HandshakeStale
+	default:
+		handshake.Status = HandshakeCompatible
+	}
+
+	return handshake
+}
+
+func requestCatalogHandshake(httpClient *http.Client, handshakeUrl string, catalog *Catalog) (*catalogHandshakeResponse, error) {
+	checks := 0
+	if catalog != nil {
+		checks = len(*catalog)
+	}
+
+	requestBody, err := json.Marshal(&catalogHandshakeRequest{
+		RunnerVersion:        version.Version,
+		CatalogSchemaVersion: CatalogSchemaVersion,
+		CatalogVersion:       catalog.Version(),
+		Checks:               checks,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Post(handshakeUrl, "application/json", bytes.NewBuffer(requestBody))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("the catalog handshake with %s failed, status code: %d", handshakeUrl, resp.StatusCode)
+	}
+
+	var response *catalogHandshakeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	if response == nil {
+		response = &catalogHandshakeResponse{}
+	}
+
+	return response, nil
+}
+
+// advertiseCatalog runs the catalog handshake with the Trento server, logging the mismatches.
+// The result is shown in the catalog status, unless the catalog has been rebuilt in the meantime
+func (c *runnerService) advertiseCatalog(ctx context.Context, catalog *Catalog) {
+	httpClient := &http.Client{Transport: c.serverTransport, Timeout: handshakeTimeout}
+	handshake := NewCatalogHandshake(ctx, httpClient, c.config.ServerHandshakeUrl, catalog)
+
+	switch handshake.Status {
+	case HandshakeCompatible:
+		log.Infof("The catalog version %s is the one expected by the Trento server", catalog.Version())
+	case HandshakeStale:
+		log.Warnf("The catalog version %s is stale, the Trento server expects the version %s",
+			catalog.Version(), handshake.ServerCatalogVersion)
+	case HandshakeIncompatible:
+		log.Errorf("The catalog schema version %d is not compatible with the Trento server, which expects the version %d",
+			CatalogSchemaVersion, handshake.ServerSchemaVersion)
+	case HandshakeFailed:
+		log.Errorf("Error in the catalog handshake with the Trento server: %s", handshake.Error)
+	}
+
+	c.catalogMu.Lock()
+	defer c.catalogMu.Unlock()
+
+	if c.catalog == catalog {
+		c.handshake = handshake
+	}
+}
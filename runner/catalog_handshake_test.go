@@ -0,0 +1,129 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/trento-project/runner/version"
+)
+
+type CatalogHandshakeTestSuite struct {
+	suite.Suite
+	server   *httptest.Server
+	catalog  *Catalog
+	request  *catalogHandshakeRequest
+	response string
+	status   int
+}
+
+func TestCatalogHandshakeTestSuite(t *testing.T) {
+	suite.Run(t, new(CatalogHandshakeTestSuite))
+}
+
+func (suite *CatalogHandshakeTestSuite) SetupTest() {
+	handshakeRetryPolicy = RetryPolicy{Attempts: 1}
+	suite.catalog = &Catalog{&CatalogCheck{ID: "156F64", Name: "1.1.1", Group: "Corosync", Provider: "azure"}}
+	suite.request = nil
+	suite.response = "{}"
+	suite.status = http.StatusOK
+
+	suite.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&suite.request)
+		w.WriteHeader(suite.status)
+		w.Write([]byte(suite.response))
+	}))
+}
+
+func (suite *CatalogHandshakeTestSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+func (suite *CatalogHandshakeTestSuite) handshake() *CatalogHandshake {
+	return NewCatalogHandshake(context.Background(), suite.server.Client(), suite.server.URL, suite.catalog)
+}
+
+func (suite *CatalogHandshakeTestSuite) TestNewCatalogHandshakeCompatible() {
+	suite.response = `{"catalog_schema_version":1,"catalog_version":"` + suite.catalog.Version() + `"}`
+
+	handshake := suite.handshake()
+
+	suite.Equal(&catalogHandshakeRequest{
+		RunnerVersion:        version.Version,
+		CatalogSchemaVersion: CatalogSchemaVersion,
+		CatalogVersion:       suite.catalog.Version(),
+		Checks:               1,
+	}, suite.request)
+	suite.Equal(HandshakeCompatible, handshake.Status)
+	suite.Equal(1, handshake.ServerSchemaVersion)
+	suite.Equal(suite.catalog.Version(), handshake.ServerCatalogVersion)
+	suite.False(handshake.CheckedAt.IsZero())
+}
+
+func (suite *CatalogHandshakeTestSuite) TestNewCatalogHandshakeNoExpectedVersions() {
+	suite.Equal(HandshakeCompatible, suite.handshake().Status)
+}
+
+func (suite *CatalogHandshakeTestSuite) TestNewCatalogHandshakeStale() {
+	suite.response = `{"catalog_schema_version":1,"catalog_version":"newversion"}`
+
+	handshake := suite.handshake()
+
+	suite.Equal(HandshakeStale, handshake.Status)
+	suite.Equal("newversion", handshake.ServerCatalogVersion)
+}
+
+func (suite *CatalogHandshakeTestSuite) TestNewCatalogHandshakeIncompatible() {
+	suite.response = `{"catalog_schema_version":2,"catalog_version":"newversion"}`
+
+	handshake := suite.handshake()
+
+	suite.Equal(HandshakeIncompatible, handshake.Status)
+	suite.Equal(2, handshake.ServerSchemaVersion)
+}
+
+func (suite *CatalogHandshakeTestSuite) TestNewCatalogHandshakeFailed() {
+	suite.status = http.StatusNotFound
+
+	handshake := suite.handshake()
+
+	suite.Equal(HandshakeFailed, handshake.Status)
+	suite.Equal("the catalog handshake with "+suite.server.URL+" failed, status code: 404", handshake.Error)
+}
+
+func (suite *CatalogHandshakeTestSuite) TestAdvertiseCatalog() {
+	suite.response = `{"catalog_schema_version":1,"catalog_version":"newversion"}`
+
+	runnerService, _ := NewRunnerService(&Config{ServerHandshakeUrl: suite.server.URL})
+	runnerService.setCatalog(suite.catalog, true)
+
+	runnerService.advertiseCatalog(context.Background(), suite.catalog)
+	suite.Equal(HandshakeStale, runnerService.GetCatalogStatus().Handshake.Status)
+
+	// The handshake of a replaced catalog is not shown
+	runnerService.setCatalog(&Catalog{}, true)
+	suite.Nil(runnerService.GetCatalogStatus().Handshake)
+
+	runnerService.advertiseCatalog(context.Background(), suite.catalog)
+	suite.Nil(runnerService.GetCatalogStatus().Handshake)
+}
+
+func (suite *CatalogHandshakeTestSuite) TestAdvertiseCatalogTimeout() {
+	handshakeTimeout = time.Millisecond * 10
+	defer func() { handshakeTimeout = time.Second * 10 }()
+
+	suite.server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond * 100)
+	})
+
+	runnerService, _ := NewRunnerService(&Config{ServerHandshakeUrl: suite.server.URL})
+	runnerService.setCatalog(suite.catalog, true)
+
+	runnerService.advertiseCatalog(context.Background(), suite.catalog)
+	suite.Equal(HandshakeFailed, runnerService.GetCatalogStatus().Handshake.Status)
+}
@@ -0,0 +1,228 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+const (
+	// CatalogGitDir is where the checks repository is cloned. It is out of the ansible files folder,
+	// so the repository is only fetched again when the catalog is built
+	CatalogGitDir = "catalog_git"
+
+	gitTerminalPromptEnv = "GIT_TERMINAL_PROMPT"
+)
+
+var ErrCatalogSignature = errors.New("The catalog git ref does not have a valid signature")
+
+// CatalogSource provides a folder of checks roles, copied on top of the embedded checks before the catalog is built
+type CatalogSource interface {
+	// Fetch updates the checks of the source, returning their folder
+	Fetch(ctx context.Context) (string, error)
+}
+
+// NewCatalogSources returns the configured checks sources, in the order they are copied.
+// The custom checks folder is the last one, so its checks replace the ones of the repository
+func NewCatalogSources(config *Config) []CatalogSource {
+	sources := []CatalogSource{}
+	if config.CatalogGitUrl != "" {
+		sources = append(sources, &gitCatalogSource{
+			url:       config.CatalogGitUrl,
+			ref:       config.CatalogGitRef,
+			subfolder: config.CatalogGitPath,
+			verify:    config.CatalogGitVerify,
+			folder:    path.Join(config.AnsibleFolder, CatalogGitDir),
+			envs:      proxyEnvs(config),
+		})
+	}
+	if config.CustomChecksDir != "" {
+		sources = append(sources, &folderCatalogSource{folder: config.CustomChecksDir})
+	}
+
+	return sources
+}
+
+// copyCatalogSources fetches the checks of the sources and copies them in the ansible checks folder,
+// returning the folders of the sources
+func copyCatalogSources(ctx context.Context, sources []CatalogSource, checksFolder string) ([]string, error) {
+	folders, err := fetchCatalogSources(ctx, sources)
+	if err != nil {
+		return nil, err
+	}
+
+	return folders, copyCatalogFolders(folders, checksFolder)
+}
+
+// fetchCatalogSources fetches the checks of the sources, returning their folders. They are not copied,
+// so the sources can be fetched while the ansible files are extracted
+func fetchCatalogSources(ctx context.Context, sources []CatalogSource) ([]string, error) {
+	folders := []string{}
+	for _, source := range sources {
+		folder, err := source.Fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		folders = append(folders, folder)
+	}
+
+	return folders, nil
+}
+
+// copyCatalogFolders copies the checks of the fetched sources folders in the ansible checks folder, in order
+func copyCatalogFolders(folders []string, checksFolder string) error {
+	for _, folder := range folders {
+		if err := copyCustomChecks(folder, checksFolder); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// folderCatalogSource is a local folder of checks, as the custom checks folder
+type folderCatalogSource struct {
+	folder string
+}
+
+func (s *folderCatalogSource) Fetch(_ context.Context) (string, error) {
+	return s.folder, nil
+}
+
+// gitCatalogSource is a git repository of checks, checked out in the given ref: a branch, a tag or a commit.
+// The default branch of the remote is used if the ref is not set. If the signature is verified,
+// the ref must be a signed tag, or a signed commit, with a key of the runner user gpg keyring
+type gitCatalogSource struct {
+	url       string
+	ref       string
+	subfolder string
+	verify    bool
+	folder    string
+	envs      map[string]string
+}
+
+func (s *gitCatalogSource) Fetch(ctx context.Context) (string, error) {
+	logger := loggerFromContext(ctx)
+
+	if err := s.update(ctx); err != nil {
+		if !s.cloned() {
+			return "", fmt.Errorf("error cloning the catalog git repository %s: %w", redactedUrl(s.url), err)
+		}
+		// The remote cannot be reached, the last fetched commits are used
+		logger.Warnf("Error fetching the catalog git repository %s, using the last fetched one: %s", redactedUrl(s.url), err)
+	}
+
+	commit, err := s.resolve(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if s.verify {
+		if err := s.verifySignature(ctx, commit); err != nil {
+			return "", err
+		}
+	}
+
+	if _, err := s.git(ctx, "checkout", "--force", "--detach", commit); err != nil {
+		return "", err
+	}
+	if _, err := s.git(ctx, "clean", "-ffdx"); err != nil {
+		return "", err
+	}
+	logger.Infof("Catalog git repository %s checked out in %s", redactedUrl(s.url), commit)
+
+	return path.Join(s.folder, s.subfolder), nil
+}
+
+func (s *gitCatalogSource) cloned() bool {
+	_, err := os.Stat(path.Join(s.folder, ".git"))
+	return err == nil
+}
+
+// update clones the repository, or fetches it again if it was already cloned
+func (s *gitCatalogSource) update(ctx context.Context) error {
+	if !s.cloned() {
+		if err := os.RemoveAll(s.folder); err != nil {
+			return err
+		}
+		_, err := runGit(ctx, "", s.envs, "clone", "--no-checkout", s.url, s.folder)
+		return err
+	}
+
+	if _, err := s.git(ctx, "remote", "set-url", "origin", s.url); err != nil {
+		return err
+	}
+	_, err := s.git(ctx, "fetch", "--force", "--prune", "--tags", "origin")
+	return err
+}
+
+// resolve returns the commit of the ref. The remote branches are looked up first, so a branch follows the remote
+func (s *gitCatalogSource) resolve(ctx context.Context) (string, error) {
+	candidates := []string{"origin/HEAD"}
+	if s.ref != "" {
+		candidates = []string{"origin/" + s.ref, s.ref}
+	}
+
+	for _, candidate := range candidates {
+		if commit, err := s.git(ctx, "rev-parse", "--verify", "--quiet", candidate+"^{commit}"); err == nil {
+			return commit, nil
+		}
+	}
+
+	return "", fmt.Errorf("the catalog git ref %s is not found in %s", s.ref, redactedUrl(s.url))
+}
+
+func (s *gitCatalogSource) verifySignature(ctx context.Context, commit string) error {
+	var err error
+	if _, tagErr := s.git(ctx, "rev-parse", "--verify", "--quiet", "refs/tags/"+s.ref); s.ref != "" && tagErr == nil {
+		_, err = s.git(ctx, "verify-tag", s.ref)
+	} else {
+		_, err = s.git(ctx, "verify-commit", commit)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrCatalogSignature, err)
+	}
+
+	return nil
+}
+
+// redactedUrl hides the password of the repository url, the ssh urls as git@host:repo are kept as they are
+func redactedUrl(repositoryUrl string) string {
+	u, err := url.Parse(repositoryUrl)
+	if err != nil {
+		return repositoryUrl
+	}
+
+	return u.Redacted()
+}
+
+func (s *gitCatalogSource) git(ctx context.Context, args ...string) (string, error) {
+	return runGit(ctx, s.folder, s.envs, args...)
+}
+
+// runGit runs a git command, in the given repository folder if it is set, without asking for credentials.
+// The given environment variables replace the runner ones
+func runGit(ctx context.Context, folder string, envs map[string]string, args ...string) (string, error) {
+	// The arguments are not in the errors, as the repository url may have credentials
+	command := args[0]
+	if folder != "" {
+		args = append([]string{"-C", folder}, args...)
+	}
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = append(os.Environ(), gitTerminalPromptEnv+"=0")
+	for name, value := range envs {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", name, value))
+	}
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", command, err, strings.TrimSpace(string(output)))
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
@@ -0,0 +1,153 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type CatalogSourceTestSuite struct {
+	suite.Suite
+	tmpDir     string
+	repository string
+}
+
+func TestCatalogSourceTestSuite(t *testing.T) {
+	suite.Run(t, new(CatalogSourceTestSuite))
+}
+
+func (suite *CatalogSourceTestSuite) SetupTest() {
+	suite.tmpDir, _ = ioutil.TempDir(os.TempDir(), "trentotest")
+	suite.repository = path.Join(suite.tmpDir, "repository")
+
+	os.MkdirAll(suite.repository, 0755)
+	suite.git("init", "--initial-branch=main")
+	suite.commit("checks/156F64/defaults/main.yml", "id: 156F64\n")
+	suite.git("tag", "v1.0.0")
+}
+
+func (suite *CatalogSourceTestSuite) TearDownTest() {
+	os.RemoveAll(suite.tmpDir)
+}
+
+func (suite *CatalogSourceTestSuite) git(args ...string) string {
+	output, err := runGit(context.Background(), suite.repository, nil,
+		append([]string{"-c", "user.name=trento", "-c", "user.email=trento@example.com", "-c", "commit.gpgsign=false"}, args...)...)
+	suite.Require().NoError(err)
+	return output
+}
+
+func (suite *CatalogSourceTestSuite) commit(file, content string) {
+	os.MkdirAll(path.Dir(path.Join(suite.repository, file)), 0755)
+	ioutil.WriteFile(path.Join(suite.repository, file), []byte(content), 0644)
+	suite.git("add", "-A")
+	suite.git("commit", "-m", "Update "+file)
+}
+
+func (suite *CatalogSourceTestSuite) source(ref string) *gitCatalogSource {
+	return NewCatalogSources(&Config{
+		AnsibleFolder:  suite.tmpDir,
+		CatalogGitUrl:  suite.repository,
+		CatalogGitRef:  ref,
+		CatalogGitPath: "checks",
+	})[0].(*gitCatalogSource)
+}
+
+func (suite *CatalogSourceTestSuite) Test_NewCatalogSources() {
+	sources := NewCatalogSources(&Config{
+		AnsibleFolder:   "/usr/etc/trento",
+		CatalogGitUrl:   "https://git.example.com/trento/checks.git",
+		CustomChecksDir: "/etc/trento/checks",
+	})
+
+	suite.Equal([]CatalogSource{
+		&gitCatalogSource{url: "https://git.example.com/trento/checks.git", folder: "/usr/etc/trento/catalog_git"},
+		&folderCatalogSource{folder: "/etc/trento/checks"},
+	}, sources)
+	suite.Empty(NewCatalogSources(&Config{AnsibleFolder: "/usr/etc/trento"}))
+}
+
+func (suite *CatalogSourceTestSuite) Test_Fetch() {
+	suite.commit("checks/53D035/defaults/main.yml", "id: 53D035\n")
+
+	folder, err := suite.source("").Fetch(context.Background())
+	suite.NoError(err)
+	suite.Equal(path.Join(suite.tmpDir, CatalogGitDir, "checks"), folder)
+	suite.FileExists(path.Join(folder, "156F64/defaults/main.yml"))
+	suite.FileExists(path.Join(folder, "53D035/defaults/main.yml"))
+}
+
+func (suite *CatalogSourceTestSuite) Test_Fetch_Ref() {
+	suite.commit("checks/53D035/defaults/main.yml", "id: 53D035\n")
+
+	// The pinned tag does not have the later checks
+	folder, err := suite.source("v1.0.0").Fetch(context.Background())
+	suite.NoError(err)
+	suite.FileExists(path.Join(folder, "156F64/defaults/main.yml"))
+	suite.NoFileExists(path.Join(folder, "53D035/defaults/main.yml"))
+
+	// The branch follows the remote each time the checks are fetched
+	source := suite.source("main")
+	_, err = source.Fetch(context.Background())
+	suite.NoError(err)
+	suite.FileExists(path.Join(folder, "53D035/defaults/main.yml"))
+
+	suite.commit("checks/21FCA6/defaults/main.yml", "id: 21FCA6\n")
+	_, err = source.Fetch(context.Background())
+	suite.NoError(err)
+	suite.FileExists(path.Join(folder, "21FCA6/defaults/main.yml"))
+
+	_, err = suite.source("v9.9.9").Fetch(context.Background())
+	suite.EqualError(err, "the catalog git ref v9.9.9 is not found in "+suite.repository)
+}
+
+func (suite *CatalogSourceTestSuite) Test_Fetch_Unreachable() {
+	source := suite.source("main")
+	folder, err := source.Fetch(context.Background())
+	suite.NoError(err)
+
+	// The last fetched checks are used while the repository cannot be reached
+	source.url = path.Join(suite.tmpDir, "unknown")
+	_, err = source.Fetch(context.Background())
+	suite.NoError(err)
+	suite.FileExists(path.Join(folder, "156F64/defaults/main.yml"))
+
+	source.folder = path.Join(suite.tmpDir, "other")
+	_, err = source.Fetch(context.Background())
+	suite.Error(err)
+}
+
+func (suite *CatalogSourceTestSuite) Test_Fetch_Verify() {
+	source := suite.source("v1.0.0")
+	source.verify = true
+
+	_, err := source.Fetch(context.Background())
+	suite.True(errors.Is(err, ErrCatalogSignature))
+}
+
+func (suite *CatalogSourceTestSuite) Test_CopyCatalogSources() {
+	customChecksDir := path.Join(suite.tmpDir, "custom")
+	os.MkdirAll(path.Join(customChecksDir, "156F64"), 0755)
+	ioutil.WriteFile(path.Join(customChecksDir, "156F64/custom.yml"), []byte("custom"), 0644)
+
+	checksFolder := path.Join(suite.tmpDir, "ansible/roles/checks")
+	os.MkdirAll(checksFolder, 0755)
+
+	sources := []CatalogSource{suite.source(""), &folderCatalogSource{folder: customChecksDir}}
+	folders, err := copyCatalogSources(context.Background(), sources, checksFolder)
+	suite.NoError(err)
+	suite.Equal([]string{path.Join(suite.tmpDir, CatalogGitDir, "checks"), customChecksDir}, folders)
+	// The custom checks replace the checks of the repository with the same folder name
+	suite.FileExists(path.Join(checksFolder, "156F64/custom.yml"))
+	suite.NoFileExists(path.Join(checksFolder, "156F64/defaults/main.yml"))
+
+	sources = []CatalogSource{NewCatalogSources(&Config{AnsibleFolder: suite.tmpDir, CatalogGitUrl: suite.repository})[0]}
+	_, err = copyCatalogSources(context.Background(), sources, checksFolder)
+	suite.NoError(err)
+	suite.NoDirExists(path.Join(checksFolder, ".git"))
+}
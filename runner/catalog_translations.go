@@ -0,0 +1,196 @@
+package runner
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// The custom checks folder with the translation files, named after their language, e.g. translations/de.yml.
+// It is not copied as a check role
+const customTranslationsFolder = "translations"
+
+// CheckTranslation is the localized check description and remediation. The empty fields fall back to the base ones
+type CheckTranslation struct {
+	Description string `json:"description,omitempty" yaml:"description"`
+	Remediation string `json:"remediation,omitempty" yaml:"remediation"`
+}
+
+// CatalogTranslations are the check translations of each language, by check id
+type CatalogTranslations map[string]map[string]*CheckTranslation
+
+// LoadCatalogTranslations reads the translation files of the custom checks folder. Each file has the
+// translations of a language by check id:
+//
+//	156F64:
+//	  description: Corosync `token` timeout ist auf `30000` gesetzt
+//	  remediation: ...
+func LoadCatalogTranslations(customChecksDir string) (CatalogTranslations, error) {
+	translations := make(CatalogTranslations)
+	if customChecksDir == "" {
+		return translations, nil
+	}
+
+	files, err := filepath.Glob(path.Join(customChecksDir, customTranslationsFolder, "*.yml"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Errorf("Error reading the translations file %s: %s", file, err)
+			return nil, err
+		}
+
+		var checks map[string]*CheckTranslation
+		if err := yaml.Unmarshal(content, &checks); err != nil {
+			return nil, fmt.Errorf("translations file %s is not valid: %w", file, err)
+		}
+
+		language := normalizeLanguage(strings.TrimSuffix(path.Base(file), ".yml"))
+		translations[language] = checks
+	}
+
+	return translations, nil
+}
+
+// MergeTranslations adds the translations to the checks, on top of the ones of the check metadata
+func (c *Catalog) MergeTranslations(translations CatalogTranslations) {
+	if c == nil {
+		return
+	}
+
+	for _, check := range *c {
+		// The languages of the check metadata are normalized, as the ones of the translation files
+		for language, translation := range check.Translations {
+			if normalized := normalizeLanguage(language); normalized != language {
+				delete(check.Translations, language)
+				check.Translations[normalized] = translation
+			}
+		}
+
+		for language, checks := range translations {
+			translation, ok := checks[check.ID]
+			if !ok || translation == nil {
+				continue
+			}
+
+			if check.Translations == nil {
+				check.Translations = make(map[string]*CheckTranslation)
+			}
+			current, ok := check.Translations[language]
+			if !ok || current == nil {
+				current = &CheckTranslation{}
+				check.Translations[language] = current
+			}
+			if translation.Description != "" {
+				current.Description = translation.Description
+			}
+			if translation.Remediation != "" {
+				current.Remediation = translation.Remediation
+			}
+		}
+	}
+}
+
+// Localize returns the catalog with the description and remediation in the first of the languages
+// the check is translated to, or the base ones if it is not translated to any of them.
+// The localized checks do not have the translations
+func (c *Catalog) Localize(languages []string) *Catalog {
+	localized := Catalog{}
+	if c == nil {
+		return &localized
+	}
+
+	for _, check := range *c {
+		localizedCheck := *check
+		localizedCheck.Translations = nil
+		if translation := check.translation(languages); translation != nil {
+			if translation.Description != "" {
+				localizedCheck.Description = translation.Description
+			}
+			if translation.Remediation != "" {
+				localizedCheck.Remediation = translation.Remediation
+			}
+		}
+		localized = append(localized, &localizedCheck)
+	}
+
+	return &localized
+}
+
+func (c *CatalogCheck) translation(languages []string) *CheckTranslation {
+	for _, language := range languages {
+		if translation, ok := c.Translations[language]; ok && translation != nil {
+			return translation
+		}
+	}
+
+	return nil
+}
+
+// ParseAcceptLanguage returns the languages of an Accept-Language header, sorted by preference.
+// The regional languages are followed by their base language, e.g. de-CH is followed by de
+func ParseAcceptLanguage(header string) []string {
+	type weightedLanguage struct {
+		language string
+		quality  float64
+	}
+
+	var weighted []weightedLanguage
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		language := normalizeLanguage(fields[0])
+		if language == "" || language == "*" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if value, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					quality = value
+				}
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+		weighted = append(weighted, weightedLanguage{language, quality})
+	}
+
+	sort.SliceStable(weighted, func(i, j int) bool {
+		return weighted[i].quality > weighted[j].quality
+	})
+
+	languages := []string{}
+	found := make(map[string]bool)
+	add := func(language string) {
+		if !found[language] {
+			found[language] = true
+			languages = append(languages, language)
+		}
+	}
+	for _, entry := range weighted {
+		add(entry.language)
+		if base := strings.SplitN(entry.language, "-", 2)[0]; base != entry.language {
+			add(base)
+		}
+	}
+
+	return languages
+}
+
+// normalizeLanguage lowercases the language tag, with dashes, so de_CH and de-ch are the same
+func normalizeLanguage(language string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(language), "_", "-"))
+}
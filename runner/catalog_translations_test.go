@@ -0,0 +1,80 @@
+package runner
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadCatalogTranslations(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	os.MkdirAll(path.Join(tmpDir, "translations"), 0755)
+	ioutil.WriteFile(path.Join(tmpDir, "translations/de_DE.yml"), []byte(
+		"156F64:\n  description: Beschreibung\n  remediation: Behebung\n"), 0644)
+	ioutil.WriteFile(path.Join(tmpDir, "translations/es.yml"), []byte("156F64:\n  description: descripción\n"), 0644)
+
+	translations, err := LoadCatalogTranslations(tmpDir)
+	assert.NoError(t, err)
+	assert.Equal(t, CatalogTranslations{
+		"de-de": {"156F64": {Description: "Beschreibung", Remediation: "Behebung"}},
+		"es":    {"156F64": {Description: "descripción"}},
+	}, translations)
+
+	translations, err = LoadCatalogTranslations("")
+	assert.NoError(t, err)
+	assert.Empty(t, translations)
+
+	ioutil.WriteFile(path.Join(tmpDir, "translations/fr.yml"), []byte("- invalid"), 0644)
+	_, err = LoadCatalogTranslations(tmpDir)
+	assert.Error(t, err)
+}
+
+func TestCatalogMergeTranslations(t *testing.T) {
+	catalog := &Catalog{
+		&CatalogCheck{ID: "156F64", Translations: map[string]*CheckTranslation{
+			"DE": {Description: "Beschreibung", Remediation: "Behebung"},
+		}},
+		&CatalogCheck{ID: "A1244C"},
+	}
+
+	catalog.MergeTranslations(CatalogTranslations{
+		"de": {"156F64": {Remediation: "Neue Behebung"}},
+		"es": {"156F64": {Description: "descripción"}, "A1244C": {Description: "otra descripción"}},
+	})
+
+	assert.Equal(t, map[string]*CheckTranslation{
+		"de": {Description: "Beschreibung", Remediation: "Neue Behebung"},
+		"es": {Description: "descripción"},
+	}, (*catalog)[0].Translations)
+	assert.Equal(t, map[string]*CheckTranslation{
+		"es": {Description: "otra descripción"},
+	}, (*catalog)[1].Translations)
+}
+
+func TestCatalogLocalize(t *testing.T) {
+	catalog := &Catalog{
+		&CatalogCheck{ID: "156F64", Description: "description", Remediation: "remediation",
+			Translations: map[string]*CheckTranslation{"es": {Description: "descripción"}}},
+	}
+
+	localized := catalog.Localize([]string{"de", "es"})
+	assert.Equal(t, &Catalog{
+		&CatalogCheck{ID: "156F64", Description: "descripción", Remediation: "remediation"},
+	}, localized)
+	// The catalog is not changed
+	assert.Equal(t, "description", (*catalog)[0].Description)
+
+	localized = catalog.Localize([]string{"fr"})
+	assert.Equal(t, "description", (*localized)[0].Description)
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	assert.Equal(t, []string{"de-ch", "de", "es", "en"}, ParseAcceptLanguage("es;q=0.8, de-CH, en;q=0.5, de;q=0.9"))
+	assert.Equal(t, []string{"fr"}, ParseAcceptLanguage("fr, *;q=0.5, it;q=0"))
+	assert.Equal(t, []string{}, ParseAcceptLanguage(""))
+}
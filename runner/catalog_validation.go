@@ -0,0 +1,316 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// The metadata fields every check must have in its defaults/main.yml file
+var requiredCheckFields = []string{"id", "name", "group", "description", "remediation", "implementation"}
+
+// CatalogReport is the result of the catalog validation, listing the problems found
+type CatalogReport struct {
+	Valid    bool              `json:"valid"`
+	Checks   int               `json:"checks"`
+	Problems []*CatalogProblem `json:"problems"`
+}
+
+// CatalogProblem is a problem found in the catalog. Check is the check folder, if the problem is in a check
+type CatalogProblem struct {
+	Check   string `json:"check,omitempty"`
+	Path    string `json:"path"`
+	Problem string `json:"problem"`
+}
+
+// ValidateCatalog creates the ansible file structure with the custom checks, checks the syntax
+// of the playbooks and validates the metadata of every check, without connecting to any host
+func ValidateCatalog(ctx context.Context, config *Config) (*CatalogReport, error) {
+	if err := prepareAnsibleFiles(config); err != nil {
+		return nil, err
+	}
+
+	report := &CatalogReport{Problems: []*CatalogProblem{}}
+
+	for _, playbook := range []string{AnsibleMain, AnsibleMeta} {
+		report.addProblems(checkPlaybookSyntax(ctx, config, playbook))
+	}
+
+	checksFolder := path.Join(config.AnsibleFolder, AnsibleChecks)
+	entries, err := ioutil.ReadDir(checksFolder)
+	if err != nil {
+		return nil, err
+	}
+
+	providers, err := ansibleProviders(config)
+	if err != nil {
+		return nil, err
+	}
+
+	checkIDs := make(map[string]string)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		report.Checks++
+		report.addProblems(validateCheck(path.Join(checksFolder, entry.Name()), checkIDs, providers))
+	}
+
+	report.addProblems(validateDependencies(checksFolder, checkIDs))
+	report.addProblems(validateTranslations(config.CustomChecksDir, checkIDs))
+
+	report.Valid = len(report.Problems) == 0
+
+	return report, nil
+}
+
+func (r *CatalogReport) addProblems(problems []*CatalogProblem) {
+	r.Problems = append(r.Problems, problems...)
+}
+
+// checkPlaybookSyntax runs the playbook syntax check, returning the ansible errors as problem
+func checkPlaybookSyntax(ctx context.Context, config *Config, playbook string) []*CatalogProblem {
+	playbookPath := path.Join(config.AnsibleFolder, playbook)
+
+	ansibleRunner := NewAnsibleRunner(config)
+	if err := ansibleRunner.SetPlaybook(playbookPath); err != nil {
+		return []*CatalogProblem{{Path: playbookPath, Problem: err.Error()}}
+	}
+	ansibleRunner.SetConfigFile(path.Join(config.AnsibleFolder, AnsibleConfigFile))
+	setVaultCredentials(config, ansibleRunner)
+	ansibleRunner.SyntaxCheck = true
+
+	var output []string
+	ansibleRunner.OutputHandler = func(_, line string) {
+		if strings.TrimSpace(line) != "" {
+			output = append(output, line)
+		}
+	}
+
+	if err := ansibleRunner.RunPlaybookContext(ctx); err != nil {
+		problem := fmt.Sprintf("syntax check failed: %s", err)
+		if len(output) > 0 {
+			problem = fmt.Sprintf("%s: %s", problem, strings.Join(output, "\n"))
+		}
+		return []*CatalogProblem{{Path: playbookPath, Problem: problem}}
+	}
+
+	return nil
+}
+
+// ansibleProviders returns the providers with a catalog, which are the folders of the providers variables
+func ansibleProviders(config *Config) (map[string]bool, error) {
+	entries, err := ioutil.ReadDir(path.Join(config.AnsibleFolder, AnsibleProviders))
+	if err != nil {
+		return nil, err
+	}
+
+	providers := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			providers[entry.Name()] = true
+		}
+	}
+
+	return providers, nil
+}
+
+// validateCheck validates the check metadata and tasks. The check IDs found so far are given, to find the duplicated ones,
+// and the providers with a catalog, to find the unknown ones in the check providers
+func validateCheck(checkPath string, checkIDs map[string]string, providers map[string]bool) []*CatalogProblem {
+	checkName := path.Base(checkPath)
+	metadataPath := path.Join(checkPath, checkMetadataFile)
+	problems := []*CatalogProblem{}
+	addProblem := func(problemPath, format string, args ...interface{}) {
+		problems = append(problems, &CatalogProblem{Check: checkName, Path: problemPath, Problem: fmt.Sprintf(format, args...)})
+	}
+
+	tasksPath := path.Join(checkPath, "tasks/main.yml")
+	if _, err := os.Stat(tasksPath); err != nil {
+		addProblem(tasksPath, "the check tasks file is missing")
+	}
+
+	content, err := ioutil.ReadFile(metadataPath)
+	if err != nil {
+		addProblem(metadataPath, "the check metadata file is missing")
+		return problems
+	}
+
+	var metadata map[string]interface{}
+	if err := yaml.Unmarshal(content, &metadata); err != nil {
+		addProblem(metadataPath, "the check metadata is not valid yaml: %s", err)
+		return problems
+	}
+
+	var missing []string
+	for _, field := range requiredCheckFields {
+		if _, ok := metadata[field]; !ok {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) > 0 {
+		addProblem(metadataPath, "missing required fields: %s", strings.Join(missing, ", "))
+	}
+
+	for _, field := range []string{"id", "name", "group"} {
+		if value, ok := metadata[field]; ok && (value == nil || fmt.Sprint(value) == "") {
+			addProblem(metadataPath, "the %s field is empty", field)
+		}
+	}
+
+	// The implementation and the results are found by the check name, so it must match the folder
+	if name, ok := metadata["name"]; ok && name != nil && fmt.Sprint(name) != checkName {
+		addProblem(metadataPath, "the check name %v does not match the folder name", name)
+	}
+
+	if severity, ok := metadata["on_failure"]; ok && !IsValidSeverity(fmt.Sprint(severity)) {
+		addProblem(metadataPath, "the on_failure severity %v is not warning or critical", severity)
+	}
+
+	if threshold, ok := metadata["critical_threshold"]; ok {
+		if value, isInt := threshold.(int); !isInt || value < 0 {
+			addProblem(metadataPath, "the critical_threshold %v is not a positive number", threshold)
+		}
+	}
+
+	if checkProviders, ok := metadata["providers"]; ok {
+		list, isList := checkProviders.([]interface{})
+		if !isList || len(list) == 0 {
+			addProblem(metadataPath, "the providers field is not a list of providers")
+		}
+		for _, provider := range list {
+			if !providers[fmt.Sprint(provider)] {
+				addProblem(metadataPath, "the provider %v does not have a catalog", provider)
+			}
+		}
+	}
+
+	if platforms, ok := metadata["platforms"]; ok {
+		list, isList := platforms.([]interface{})
+		if !isList || len(list) == 0 {
+			addProblem(metadataPath, "the platforms field is not a list of platforms")
+		}
+		for _, platform := range list {
+			if !IsValidPlatform(fmt.Sprint(platform)) {
+				addProblem(metadataPath, "the platform %v is not supported, it must be linux or windows", platform)
+			}
+		}
+	}
+
+	if architectures, ok := metadata["architectures"]; ok {
+		list, isList := architectures.([]interface{})
+		if !isList || len(list) == 0 {
+			addProblem(metadataPath, "the architectures field is not a list of architectures")
+		}
+		for _, architecture := range list {
+			if !IsValidArchitecture(fmt.Sprint(architecture)) {
+				addProblem(metadataPath, "the architecture %v is not supported, it must be x86_64, aarch64, s390x or ppc64le", architecture)
+			}
+		}
+	}
+
+	if dependencies, ok := metadata["depends_on"]; ok {
+		if _, isList := dependencies.([]interface{}); !isList {
+			addProblem(metadataPath, "the depends_on field is not a list of check ids")
+		}
+	}
+
+	if translations, ok := metadata["translations"]; ok {
+		languages, isMap := translations.(map[interface{}]interface{})
+		if !isMap {
+			addProblem(metadataPath, "the translations field is not a map of languages")
+		}
+		for language, translation := range languages {
+			if _, isMap := translation.(map[interface{}]interface{}); !isMap {
+				addProblem(metadataPath, "the %v translation does not have the description and remediation fields", language)
+			}
+		}
+	}
+
+	if id, ok := metadata["id"]; ok && id != nil {
+		checkID := fmt.Sprint(id)
+		if previous, duplicated := checkIDs[checkID]; duplicated {
+			addProblem(metadataPath, "the check id %s is already used by %s", checkID, previous)
+		} else {
+			checkIDs[checkID] = checkName
+		}
+	}
+
+	return problems
+}
+
+// validateDependencies validates that the checks depend on known checks, without cycles
+func validateDependencies(checksFolder string, checkIDs map[string]string) []*CatalogProblem {
+	problems := []*CatalogProblem{}
+	checks, err := loadChecksGraph(checksFolder)
+	if err != nil {
+		return problems
+	}
+
+	for _, check := range checks {
+		for _, dependency := range check.DependsOn {
+			if _, ok := checkIDs[dependency]; !ok {
+				problems = append(problems, &CatalogProblem{
+					Check:   path.Base(check.Path),
+					Path:    path.Join(check.Path, checkMetadataFile),
+					Problem: fmt.Sprintf("the check depends on the unknown check %s", dependency),
+				})
+			}
+		}
+	}
+
+	if _, err := orderChecks(checks); err != nil {
+		problems = append(problems, &CatalogProblem{Path: checksFolder, Problem: err.Error()})
+	}
+
+	return problems
+}
+
+// validateTranslations validates the translation files of the custom checks folder, which must translate known checks
+func validateTranslations(customChecksDir string, checkIDs map[string]string) []*CatalogProblem {
+	problems := []*CatalogProblem{}
+	if customChecksDir == "" {
+		return problems
+	}
+
+	files, err := filepath.Glob(path.Join(customChecksDir, customTranslationsFolder, "*.yml"))
+	if err != nil {
+		return problems
+	}
+
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			problems = append(problems, &CatalogProblem{Path: file, Problem: err.Error()})
+			continue
+		}
+
+		var checks map[string]*CheckTranslation
+		if err := yaml.Unmarshal(content, &checks); err != nil {
+			problems = append(problems, &CatalogProblem{
+				Path: file, Problem: fmt.Sprintf("the translations are not valid yaml: %s", err)})
+			continue
+		}
+
+		var unknown []string
+		for checkID := range checks {
+			if _, ok := checkIDs[checkID]; !ok {
+				unknown = append(unknown, checkID)
+			}
+		}
+		if len(unknown) > 0 {
+			sort.Strings(unknown)
+			problems = append(problems, &CatalogProblem{
+				Path: file, Problem: fmt.Sprintf("unknown checks: %s", strings.Join(unknown, ", "))})
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,141 @@
+package runner
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/trento-project/runner/runner/mocks"
+)
+
+func TestValidateCatalog(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+	defer func() { customExecCommand = exec.Command }()
+	mockCommand.On("Execute", "ansible-playbook", path.Join(tmpDir, AnsibleMain), "--syntax-check").Return(
+		exec.Command("true"))
+	mockCommand.On("Execute", "ansible-playbook", path.Join(tmpDir, AnsibleMeta), "--syntax-check").Return(
+		exec.Command("true"))
+
+	report, err := ValidateCatalog(context.Background(), &Config{AnsibleFolder: tmpDir})
+
+	assert.NoError(t, err)
+	assert.True(t, report.Valid)
+	assert.Empty(t, report.Problems)
+	assert.Greater(t, report.Checks, 0)
+	mockCommand.AssertExpectations(t)
+}
+
+func TestValidateCatalog_Problems(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	customChecksDir := path.Join(tmpDir, "custom")
+	os.MkdirAll(path.Join(customChecksDir, "9.9.1/defaults"), 0755)
+	ioutil.WriteFile(path.Join(customChecksDir, "9.9.1/defaults/main.yml"), []byte("name: 9.9.1\ngroup: \n"), 0644)
+	os.MkdirAll(path.Join(customChecksDir, "9.9.2/defaults"), 0755)
+	os.MkdirAll(path.Join(customChecksDir, "9.9.2/tasks"), 0755)
+	ioutil.WriteFile(path.Join(customChecksDir, "9.9.2/tasks/main.yml"), []byte("---\n"), 0644)
+	ioutil.WriteFile(path.Join(customChecksDir, "9.9.2/defaults/main.yml"), []byte(
+		"id: 156F64\nname: 9.9.3\ngroup: Corosync\ndescription: check\nremediation: fix\nimplementation: tasks\n"+
+			"on_failure: info\ncritical_threshold: -1\nproviders: [azure, openstack]\nplatforms: [linux, aix]\n"+
+			"architectures: [x86_64, sparc]\n"+
+			"translations:\n  de: Beschreibung\ndepends_on: [FFFFFF]\n"), 0644)
+	os.MkdirAll(path.Join(customChecksDir, "translations"), 0755)
+	ioutil.WriteFile(path.Join(customChecksDir, "translations/de.yml"), []byte(
+		"156F64:\n  description: Beschreibung\nFFFFFF:\n  description: Beschreibung\n"), 0644)
+
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+	defer func() { customExecCommand = exec.Command }()
+	mockCommand.On("Execute", "ansible-playbook", path.Join(tmpDir, AnsibleMain), "--syntax-check").Return(
+		exec.Command("sh", "-c", "echo 'ERROR! conflicting action statements' >&2; exit 4"))
+	mockCommand.On("Execute", "ansible-playbook", path.Join(tmpDir, AnsibleMeta), "--syntax-check").Return(
+		exec.Command("true"))
+
+	report, err := ValidateCatalog(context.Background(), &Config{AnsibleFolder: tmpDir, CustomChecksDir: customChecksDir})
+	assert.NoError(t, err)
+
+	checksFolder := path.Join(tmpDir, AnsibleChecks)
+	expectedProblems := []*CatalogProblem{
+		{
+			Path:    path.Join(tmpDir, AnsibleMain),
+			Problem: "syntax check failed: exit status 4: ERROR! conflicting action statements",
+		},
+		{
+			Check:   "9.9.1",
+			Path:    path.Join(checksFolder, "9.9.1/tasks/main.yml"),
+			Problem: "the check tasks file is missing",
+		},
+		{
+			Check:   "9.9.1",
+			Path:    path.Join(checksFolder, "9.9.1/defaults/main.yml"),
+			Problem: "missing required fields: id, description, remediation, implementation",
+		},
+		{
+			Check:   "9.9.1",
+			Path:    path.Join(checksFolder, "9.9.1/defaults/main.yml"),
+			Problem: "the group field is empty",
+		},
+		{
+			Check:   "9.9.2",
+			Path:    path.Join(checksFolder, "9.9.2/defaults/main.yml"),
+			Problem: "the check name 9.9.3 does not match the folder name",
+		},
+		{
+			Check:   "9.9.2",
+			Path:    path.Join(checksFolder, "9.9.2/defaults/main.yml"),
+			Problem: "the on_failure severity info is not warning or critical",
+		},
+		{
+			Check:   "9.9.2",
+			Path:    path.Join(checksFolder, "9.9.2/defaults/main.yml"),
+			Problem: "the critical_threshold -1 is not a positive number",
+		},
+		{
+			Check:   "9.9.2",
+			Path:    path.Join(checksFolder, "9.9.2/defaults/main.yml"),
+			Problem: "the provider openstack does not have a catalog",
+		},
+		{
+			Check:   "9.9.2",
+			Path:    path.Join(checksFolder, "9.9.2/defaults/main.yml"),
+			Problem: "the platform aix is not supported, it must be linux or windows",
+		},
+		{
+			Check:   "9.9.2",
+			Path:    path.Join(checksFolder, "9.9.2/defaults/main.yml"),
+			Problem: "the architecture sparc is not supported, it must be x86_64, aarch64, s390x or ppc64le",
+		},
+		{
+			Check:   "9.9.2",
+			Path:    path.Join(checksFolder, "9.9.2/defaults/main.yml"),
+			Problem: "the de translation does not have the description and remediation fields",
+		},
+		{
+			Check:   "9.9.2",
+			Path:    path.Join(checksFolder, "9.9.2/defaults/main.yml"),
+			Problem: "the check id 156F64 is already used by 1.1.1",
+		},
+		{
+			Check:   "9.9.2",
+			Path:    path.Join(checksFolder, "9.9.2/defaults/main.yml"),
+			Problem: "the check depends on the unknown check FFFFFF",
+		},
+		{
+			Path:    path.Join(customChecksDir, "translations/de.yml"),
+			Problem: "unknown checks: FFFFFF",
+		},
+	}
+
+	assert.False(t, report.Valid)
+	assert.Equal(t, expectedProblems, report.Problems)
+}
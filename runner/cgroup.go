@@ -0,0 +1,159 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// cgroupCPUPeriod is the cpu.max period, in microseconds, the cpu quota is relative to
+	cgroupCPUPeriod = 100000
+
+	cgroupProcsFile  = "cgroup.procs"
+	cgroupKillFile   = "cgroup.kill"
+	cgroupCPUMaxFile = "cpu.max"
+	cgroupMemMaxFile = "memory.max"
+)
+
+// The killed processes leave the cgroup asynchronously, its removal is retried until they are gone
+var (
+	cgroupRemoveTimeout  = time.Second * 5
+	cgroupRemoveInterval = time.Millisecond * 50
+)
+
+// Cgroup is a cgroup v2 where the ansible processes of an execution run, so a runaway check
+// cannot starve the runner host. The parent cgroup must be delegated to the runner user,
+// with the cpu and memory controllers enabled in its subtree
+type Cgroup struct {
+	Path string
+	// CPUs is the number of CPUs the processes can use, not limited if 0
+	CPUs float64
+	// MemoryMax is the maximum memory of the processes in bytes, not limited if 0
+	MemoryMax int64
+}
+
+// NewExecutionCgroup returns the cgroup of the given execution, or nil if the cgroups are not used
+func NewExecutionCgroup(config *Config, executionID string) *Cgroup {
+	if config.AnsibleCgroup == "" {
+		return nil
+	}
+
+	return &Cgroup{
+		Path:      path.Join(config.AnsibleCgroup, "trento-"+executionID),
+		CPUs:      config.AnsibleCPULimit,
+		MemoryMax: config.AnsibleMemoryLimit,
+	}
+}
+
+// Create creates the cgroup with its cpu and memory limits
+func (c *Cgroup) Create() error {
+	if err := os.Mkdir(c.Path, 0755); err != nil && !os.IsExist(err) {
+		return err
+	}
+
+	if c.CPUs > 0 {
+		quota := int64(c.CPUs * cgroupCPUPeriod)
+		if err := c.write(cgroupCPUMaxFile, fmt.Sprintf("%d %d", quota, cgroupCPUPeriod)); err != nil {
+			return err
+		}
+	}
+
+	if c.MemoryMax > 0 {
+		if err := c.write(cgroupMemMaxFile, strconv.FormatInt(c.MemoryMax, 10)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Add moves the process to the cgroup. The processes it creates afterwards are in the cgroup as well
+func (c *Cgroup) Add(pid int) error {
+	return c.write(cgroupProcsFile, strconv.Itoa(pid))
+}
+
+// Kill kills the processes left in the cgroup, e.g. the ssh ControlPersist masters outliving the playbook
+func (c *Cgroup) Kill() error {
+	file, err := os.OpenFile(path.Join(c.Path, cgroupKillFile), os.O_WRONLY, 0)
+	if err == nil {
+		_, err = file.WriteString("1")
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("error writing %s of the cgroup %s: %w", cgroupKillFile, c.Path, err)
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	// The kernels older than 5.14 do not have cgroup.kill, the processes are killed one by one
+	pids, err := c.pids()
+	if err != nil {
+		return err
+	}
+	for _, pid := range pids {
+		if err := syscall.Kill(pid, syscall.SIGKILL); err != nil && !errors.Is(err, syscall.ESRCH) {
+			return fmt.Errorf("error killing the process %d of the cgroup %s: %w", pid, c.Path, err)
+		}
+	}
+
+	return nil
+}
+
+// Remove kills the processes left in the cgroup and removes it, as it cannot be removed while it has processes
+func (c *Cgroup) Remove() error {
+	if err := c.Kill(); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(cgroupRemoveTimeout)
+	for {
+		err := os.Remove(c.Path)
+		if err == nil || os.IsNotExist(err) {
+			return nil
+		}
+		if !errors.Is(err, syscall.EBUSY) || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(cgroupRemoveInterval)
+	}
+}
+
+func (c *Cgroup) pids() ([]int, error) {
+	content, err := ioutil.ReadFile(path.Join(c.Path, cgroupProcsFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	pids := []int{}
+	for _, line := range strings.Fields(string(content)) {
+		pid, err := strconv.Atoi(line)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s of the cgroup %s: %w", cgroupProcsFile, c.Path, err)
+		}
+		pids = append(pids, pid)
+	}
+
+	return pids, nil
+}
+
+func (c *Cgroup) write(file, value string) error {
+	if err := ioutil.WriteFile(path.Join(c.Path, file), []byte(value), 0644); err != nil {
+		return fmt.Errorf("error writing %s of the cgroup %s: %w", file, c.Path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package runner
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trento-project/runner/runner/mocks"
+)
+
+func TestNewExecutionCgroup(t *testing.T) {
+	assert.Nil(t, NewExecutionCgroup(&Config{}, "execution1"))
+
+	cgroup := NewExecutionCgroup(&Config{
+		AnsibleCgroup:      "/sys/fs/cgroup/trento-runner",
+		AnsibleCPULimit:    0.5,
+		AnsibleMemoryLimit: 512 << 20,
+	}, "execution1")
+
+	assert.Equal(t, &Cgroup{
+		Path:      "/sys/fs/cgroup/trento-runner/trento-execution1",
+		CPUs:      0.5,
+		MemoryMax: 512 << 20,
+	}, cgroup)
+}
+
+func TestCgroupCreate(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	cgroup := &Cgroup{Path: path.Join(tmpDir, "trento-execution1"), CPUs: 1.5, MemoryMax: 1 << 30}
+	assert.NoError(t, cgroup.Create())
+
+	cpuMax, _ := ioutil.ReadFile(path.Join(cgroup.Path, "cpu.max"))
+	assert.Equal(t, "150000 100000", string(cpuMax))
+	memoryMax, _ := ioutil.ReadFile(path.Join(cgroup.Path, "memory.max"))
+	assert.Equal(t, "1073741824", string(memoryMax))
+
+	assert.NoError(t, cgroup.Add(1234))
+	procs, _ := ioutil.ReadFile(path.Join(cgroup.Path, "cgroup.procs"))
+	assert.Equal(t, "1234", string(procs))
+}
+
+func TestCgroupCreateWithoutLimits(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	cgroup := &Cgroup{Path: path.Join(tmpDir, "trento-execution1")}
+	assert.NoError(t, cgroup.Create())
+
+	assert.NoFileExists(t, path.Join(cgroup.Path, "cpu.max"))
+	assert.NoFileExists(t, path.Join(cgroup.Path, "memory.max"))
+
+	assert.NoError(t, cgroup.Remove())
+	assert.NoDirExists(t, cgroup.Path)
+	assert.NoError(t, cgroup.Remove())
+}
+
+func TestRunPlaybookCgroupError(t *testing.T) {
+	runnerInst := &AnsibleRunner{
+		Playbook: "superplay.yml",
+		Envs:     map[string]string{},
+		Cgroup:   &Cgroup{Path: "/not/existing/cgroup/trento-execution1"},
+	}
+
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+
+	err := runnerInst.RunPlaybook()
+
+	assert.Error(t, err)
+	mockCommand.AssertNotCalled(t, "Execute", "ansible-playbook", "superplay.yml")
+}
+
+func TestCgroupRemoveKillsTheProcesses(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	// A process outliving the playbook, as the ssh ControlPersist masters
+	cmd := exec.Command("sleep", "60")
+	assert.NoError(t, cmd.Start())
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	cgroup := &Cgroup{Path: path.Join(tmpDir, "trento-execution1")}
+	assert.NoError(t, cgroup.Create())
+	assert.NoError(t, cgroup.Add(cmd.Process.Pid))
+
+	assert.NoError(t, cgroup.Kill())
+	select {
+	case err := <-exited:
+		assert.EqualError(t, err, "signal: killed")
+	case <-time.After(5 * time.Second):
+		cmd.Process.Kill()
+		t.Fatal("the cgroup process was not killed")
+	}
+}
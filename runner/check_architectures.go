@@ -0,0 +1,47 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// The cpu architectures of the hosts, as reported by the ansible facts. The checks run in all of them,
+	// unless their metadata has the architectures they support, e.g. the x86 only kernel parameters
+	ArchitectureX86_64  = "x86_64"
+	ArchitectureAarch64 = "aarch64"
+	ArchitectureS390x   = "s390x"
+	ArchitecturePpc64le = "ppc64le"
+
+	// architectureFact is set by the load_facts role with the architecture of the host
+	architectureFact       = "trento_architecture"
+	architectureSkippedMsg = "The check does not support the %s architecture of the host, only %s"
+)
+
+func IsValidArchitecture(architecture string) bool {
+	switch architecture {
+	case ArchitectureX86_64, ArchitectureAarch64, ArchitectureS390x, ArchitecturePpc64le:
+		return true
+	}
+
+	return false
+}
+
+// architectureSkipReason returns why a check with the given metadata is skipped in a host with the architecture,
+// if the check is not supported in it
+func architectureSkipReason(metadata map[string]interface{}, architecture string) string {
+	list, ok := metadata["architectures"].([]interface{})
+	if !ok || len(list) == 0 || architecture == "" {
+		return ""
+	}
+
+	architectures := make([]string, 0, len(list))
+	for _, item := range list {
+		if fmt.Sprint(item) == architecture {
+			return ""
+		}
+		architectures = append(architectures, fmt.Sprint(item))
+	}
+
+	return fmt.Sprintf(architectureSkippedMsg, architecture, strings.Join(architectures, ", "))
+}
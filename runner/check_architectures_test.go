@@ -0,0 +1,59 @@
+package runner
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchitectureSkipReason(t *testing.T) {
+	metadata := map[string]interface{}{"id": "156F64", "architectures": []interface{}{"x86_64", "ppc64le"}}
+
+	assert.Equal(t, "", architectureSkipReason(metadata, "x86_64"))
+	assert.Equal(t, "The check does not support the aarch64 architecture of the host, only x86_64, ppc64le",
+		architectureSkipReason(metadata, "aarch64"))
+	// The checks skipped for any other reason, or in hosts with an unknown architecture, do not have a reason
+	assert.Equal(t, "", architectureSkipReason(metadata, ""))
+	assert.Equal(t, "", architectureSkipReason(map[string]interface{}{"id": "156F64"}, "s390x"))
+}
+
+func TestNewExecutionResults_ArchitectureSkipped(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	writeCheckMetadata(tmpDir, "1.1.1", "id: 156F64\narchitectures: [x86_64]\n")
+	writeCheckMetadata(tmpDir, "1.1.2", "id: 53D035\n")
+
+	skippedChecks := &TaskHostResult{Results: []map[string]interface{}{
+		{"skipped": true, testIncludeLoopVar: map[string]interface{}{"path": path.Join(tmpDir, "1.1.1")}},
+		{"skipped": true, testIncludeLoopVar: map[string]interface{}{"path": path.Join(tmpDir, "1.1.2")}},
+	}}
+	playbookResults := &PlaybookResults{
+		Plays: []*PlayResults{
+			{
+				Tasks: []*TaskResults{
+					{
+						Task: &TaskInfo{Name: "set the node architecture"},
+						Hosts: map[string]*TaskHostResult{
+							"host1": {AnsibleFacts: map[string]interface{}{architectureFact: "s390x"}},
+						},
+					},
+					{
+						Task:  &TaskInfo{Name: testIncludeTaskName},
+						Hosts: map[string]*TaskHostResult{"host1": skippedChecks},
+					},
+				},
+			},
+		},
+	}
+
+	results := NewExecutionResults("cluster1", playbookResults)
+
+	assert.Equal(t, []*CheckResult{
+		{CheckID: "156F64", Result: "skipped", Msg: "The check does not support the s390x architecture of the host, only x86_64"},
+		{CheckID: "53D035", Result: "skipped"},
+	}, results.Hosts[0].Results)
+}
@@ -0,0 +1,190 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// checksOrderVariable has the checks folders sorted by their dependencies, used by the checks playbook loop
+	checksOrderVariable  = "trento_checks_order"
+	dependencySkippedMsg = "The check depends on %s, which did not pass in the host"
+)
+
+var ErrCheckDependencyCycle = errors.New("The checks dependencies have a cycle")
+
+// checkNode is a check of the checks folder, with the ids of the checks that must pass before it runs
+type checkNode struct {
+	ID        string
+	Path      string
+	DependsOn []string
+}
+
+// loadChecksGraph reads the id and the dependencies of the checks in the checks folder, sorted by folder
+func loadChecksGraph(checksFolder string) ([]*checkNode, error) {
+	entries, err := ioutil.ReadDir(checksFolder)
+	if err != nil {
+		return nil, err
+	}
+
+	checks := []*checkNode{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		checkPath := path.Join(checksFolder, entry.Name())
+		content, err := ioutil.ReadFile(path.Join(checkPath, checkMetadataFile))
+		if err != nil {
+			continue
+		}
+
+		var metadata struct {
+			ID        interface{}   `yaml:"id"`
+			DependsOn []interface{} `yaml:"depends_on"`
+		}
+		if err := yaml.Unmarshal(content, &metadata); err != nil || metadata.ID == nil {
+			continue
+		}
+
+		check := &checkNode{ID: fmt.Sprint(metadata.ID), Path: checkPath}
+		for _, dependency := range metadata.DependsOn {
+			check.DependsOn = append(check.DependsOn, fmt.Sprint(dependency))
+		}
+		checks = append(checks, check)
+	}
+
+	return checks, nil
+}
+
+// orderChecks sorts the checks so each check comes after its dependencies, keeping the given order otherwise.
+// The dependencies that are not found are ignored
+func orderChecks(checks []*checkNode) ([]*checkNode, error) {
+	found := make(map[string]bool, len(checks))
+	for _, check := range checks {
+		found[check.ID] = true
+	}
+
+	ordered := make([]*checkNode, 0, len(checks))
+	placed := make(map[string]bool, len(checks))
+	pending := checks
+	for len(pending) > 0 {
+		remaining := []*checkNode{}
+		for _, check := range pending {
+			ready := true
+			for _, dependency := range check.DependsOn {
+				if found[dependency] && !placed[dependency] {
+					ready = false
+					break
+				}
+			}
+			if !ready {
+				remaining = append(remaining, check)
+				continue
+			}
+			ordered = append(ordered, check)
+			placed[check.ID] = true
+		}
+
+		if len(remaining) == len(pending) {
+			return nil, fmt.Errorf("%w: %s", ErrCheckDependencyCycle, checkIDs(remaining))
+		}
+		pending = remaining
+	}
+
+	return ordered, nil
+}
+
+func checkIDs(checks []*checkNode) []string {
+	ids := make([]string, 0, len(checks))
+	for _, check := range checks {
+		ids = append(ids, check.ID)
+	}
+
+	return ids
+}
+
+// orderedChecks returns the checks of the checks folder sorted by their dependencies,
+// or nil if no check has dependencies, as the folder order is used then
+func orderedChecks(config *Config) ([]*checkNode, error) {
+	checks, err := loadChecksGraph(path.Join(config.AnsibleFolder, AnsibleChecks))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	hasDependencies := false
+	for _, check := range checks {
+		if len(check.DependsOn) > 0 {
+			hasDependencies = true
+			break
+		}
+	}
+	if !hasDependencies {
+		return nil, nil
+	}
+
+	return orderChecks(checks)
+}
+
+// checksOrder returns the checks playbook loop items, the checks folders sorted by their dependencies
+func checksOrder(checks []*checkNode) ([]map[string]string, error) {
+	items := make([]map[string]string, 0, len(checks))
+	for _, check := range checks {
+		checkPath, err := filepath.Abs(check.Path)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, map[string]string{"path": checkPath})
+	}
+
+	return items, nil
+}
+
+// skipDependents adds the skipped result of the checks that did not run in a host, as one of their dependencies
+// did not pass in that host. The checks must be sorted by their dependencies, so the dependents of the skipped
+// checks are skipped as well
+func (e *ExecutionResults) skipDependents(event *ExecutionEvent, checks []*checkNode) {
+	for _, host := range event.targetHosts() {
+		hostResults := e.getHost(host.HostID.String())
+		if hostResults == nil || !hostResults.Reachable {
+			continue
+		}
+
+		selected := make(map[string]bool)
+		for _, checkID := range event.hostChecks(host) {
+			selected[checkID] = true
+		}
+
+		for _, check := range checks {
+			if !selected[check.ID] || len(check.DependsOn) == 0 || hostResults.result(check.ID) != nil {
+				continue
+			}
+
+			for _, dependency := range check.DependsOn {
+				if result := hostResults.result(dependency); result == nil || result.Result != checkResultPassing {
+					e.addResult(hostResults.HostID, check.ID, checkResultSkipped, fmt.Sprintf(dependencySkippedMsg, dependency))
+					break
+				}
+			}
+		}
+	}
+}
+
+func (h *HostResults) result(checkID string) *CheckResult {
+	for _, result := range h.Results {
+		if result.CheckID == checkID {
+			return result
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package runner
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeCheckMetadata(checksFolder, name, metadata string) {
+	os.MkdirAll(path.Join(checksFolder, name, "defaults"), 0755)
+	ioutil.WriteFile(path.Join(checksFolder, name, checkMetadataFile), []byte(metadata), 0644)
+}
+
+func TestOrderChecks(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	writeCheckMetadata(tmpDir, "1.1.1", "id: 156F64\ndepends_on: [A1244C]\n")
+	writeCheckMetadata(tmpDir, "1.1.2", "id: 53D035\n")
+	writeCheckMetadata(tmpDir, "1.1.3", "id: A1244C\ndepends_on: [53D035, FFFFFF]\n")
+	os.MkdirAll(path.Join(tmpDir, "1.1.4"), 0755)
+
+	checks, err := loadChecksGraph(tmpDir)
+	assert.NoError(t, err)
+	assert.Equal(t, []*checkNode{
+		{ID: "156F64", Path: path.Join(tmpDir, "1.1.1"), DependsOn: []string{"A1244C"}},
+		{ID: "53D035", Path: path.Join(tmpDir, "1.1.2")},
+		{ID: "A1244C", Path: path.Join(tmpDir, "1.1.3"), DependsOn: []string{"53D035", "FFFFFF"}},
+	}, checks)
+
+	ordered, err := orderChecks(checks)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"53D035", "A1244C", "156F64"}, checkIDs(ordered))
+
+	checks[1].DependsOn = []string{"156F64"}
+	_, err = orderChecks(checks)
+	assert.ErrorIs(t, err, ErrCheckDependencyCycle)
+	assert.EqualError(t, err, "The checks dependencies have a cycle: [156F64 53D035 A1244C]")
+}
+
+func TestNewAnsibleCheckRunnerChecksOrder(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+	os.MkdirAll(path.Join(tmpDir, "ansible"), 0755)
+	os.Create(path.Join(tmpDir, "ansible/check.yml"))
+	checksFolder := path.Join(tmpDir, AnsibleChecks)
+	writeCheckMetadata(checksFolder, "1.1.1", "id: 156F64\n")
+	writeCheckMetadata(checksFolder, "1.1.2", "id: 53D035\n")
+
+	executionEvent := &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Checks:      []string{"156F64", "53D035"},
+		Hosts:       []*Host{{HostID: uuid.New(), Address: "192.168.10.1", User: "root"}},
+	}
+
+	// The folder order is used if no check has dependencies
+	a, err := NewAnsibleCheckRunner(&Config{AnsibleFolder: tmpDir}, executionEvent)
+	assert.NoError(t, err)
+	assert.Empty(t, a.ExtraVarsFile)
+
+	writeCheckMetadata(checksFolder, "1.1.1", "id: 156F64\ndepends_on: [53D035]\n")
+	a, err = NewAnsibleCheckRunner(&Config{AnsibleFolder: tmpDir}, executionEvent)
+	assert.NoError(t, err)
+
+	content, _ := ioutil.ReadFile(a.ExtraVarsFile)
+	var variables map[string]interface{}
+	json.Unmarshal(content, &variables)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"path": path.Join(checksFolder, "1.1.2")},
+		map[string]interface{}{"path": path.Join(checksFolder, "1.1.1")},
+	}, variables[checksOrderVariable])
+
+	writeCheckMetadata(checksFolder, "1.1.2", "id: 53D035\ndepends_on: [156F64]\n")
+	_, err = NewAnsibleCheckRunner(&Config{AnsibleFolder: tmpDir}, executionEvent)
+	assert.ErrorIs(t, err, ErrCheckDependencyCycle)
+}
+
+func TestSkipDependents(t *testing.T) {
+	host1 := &Host{HostID: uuid.New()}
+	host2 := &Host{HostID: uuid.New()}
+	host3 := &Host{HostID: uuid.New()}
+	event := &ExecutionEvent{Checks: []string{"53D035", "A1244C", "156F64"}, Hosts: []*Host{host1, host2, host3}}
+	checks := []*checkNode{
+		{ID: "53D035"},
+		{ID: "A1244C", DependsOn: []string{"53D035"}},
+		{ID: "156F64", DependsOn: []string{"A1244C"}},
+	}
+
+	results := &ExecutionResults{Hosts: []*HostResults{}}
+	results.addHost(host1.HostID.String(), true, "")
+	results.addResult(host1.HostID.String(), "53D035", "passing", "")
+	results.addResult(host1.HostID.String(), "A1244C", "passing", "")
+	results.addResult(host1.HostID.String(), "156F64", "warning", "")
+	results.addHost(host2.HostID.String(), true, "")
+	results.addResult(host2.HostID.String(), "53D035", "critical", "")
+	results.addHost(host3.HostID.String(), false, "unreachable")
+
+	results.skipDependents(event, checks)
+
+	assert.Equal(t, []*CheckResult{
+		{CheckID: "53D035", Result: "passing"},
+		{CheckID: "A1244C", Result: "passing"},
+		{CheckID: "156F64", Result: "warning"},
+	}, results.Hosts[0].Results)
+	assert.Equal(t, []*CheckResult{
+		{CheckID: "53D035", Result: "critical"},
+		{CheckID: "A1244C", Result: "skipped", Msg: "The check depends on 53D035, which did not pass in the host"},
+		{CheckID: "156F64", Result: "skipped", Msg: "The check depends on A1244C, which did not pass in the host"},
+	}, results.Hosts[1].Results)
+	assert.Empty(t, results.Hosts[2].Results)
+}
@@ -0,0 +1,171 @@
+package runner
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	AnsibleCheckEngine = "ansible"
+	NativeCheckEngine  = "native"
+)
+
+// The unreachable hosts are retried after this interval, so the transient problems have some time to go away
+var hostRetryInterval = time.Second * 5
+
+// CheckEngine runs the selected checks in the hosts of an execution.
+// The output lines are sent to the output handler while the checks are running
+type CheckEngine interface {
+	Run(ctx context.Context, e *ExecutionEvent, outputHandler func(stream, line string)) (*ExecutionResults, error)
+}
+
+// NewCheckEngine returns the check engine configured in the runner, ansible by default
+func NewCheckEngine(config *Config) (CheckEngine, error) {
+	var engine CheckEngine = &ansibleCheckEngine{config: config}
+	if config.CheckEngine == NativeCheckEngine {
+		nativeEngine, err := NewNativeCheckEngine(config)
+		if err != nil {
+			return nil, err
+		}
+		engine = nativeEngine
+	}
+	if config.CheckEngine == SimulationCheckEngine {
+		engine = NewSimulationCheckEngine(config)
+	}
+
+	// The executions with more hosts than the chunk size are run in chunks of hosts
+	if config.ExecutionChunkSize > 0 {
+		return newChunkedCheckEngine(engine, config.ExecutionChunkSize, config.ExecutionChunkParallelism), nil
+	}
+
+	return engine, nil
+}
+
+// ansibleCheckEngine runs the checks playbook in a temporary inventory with the execution hosts
+type ansibleCheckEngine struct {
+	config *Config
+}
+
+func (a *ansibleCheckEngine) Run(
+	ctx context.Context, e *ExecutionEvent, outputHandler func(stream, line string)) (*ExecutionResults, error) {
+
+	results, err := a.run(ctx, e, outputHandler)
+
+	// The hosts lost by transient ssh or network problems are retried, and their new results replace the failed ones
+	for attempt := 1; attempt <= a.config.HostRetries && isHostsFailure(err) && results != nil; attempt++ {
+		retryEvent := e.withLimit(results.unreachableHosts())
+		if len(retryEvent.Limit) == 0 {
+			break
+		}
+
+		loggerFromContext(ctx).Warnf(
+			"Retrying the checks in %d unreachable hosts, attempt %d of %d", len(retryEvent.Limit), attempt, a.config.HostRetries)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(hostRetryInterval):
+		}
+
+		retryResults, retryErr := a.run(ctx, retryEvent, outputHandler)
+		if retryErr != nil && !isHostsFailure(retryErr) {
+			return nil, retryErr
+		}
+		if retryResults != nil {
+			results.replaceHostsResults(retryResults)
+		}
+		err = retryErr
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// dryRunDir returns the folder where the dry runs are written. The default one is out of the ansible
+// folder, which is created again each time the runner starts
+func dryRunDir(config *Config) string {
+	if config.DryRunDir != "" {
+		return config.DryRunDir
+	}
+
+	return path.Join(config.AnsibleFolder, "dry_run")
+}
+
+// pruneDryRuns removes the dry runs written before the dry run retention. Only the folders named after
+// the executions are removed, as the dry run dir might be shared
+func pruneDryRuns(config *Config) {
+	if config.DryRunRetention <= 0 {
+		return
+	}
+
+	root := dryRunDir(config)
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("Error reading the dry run dir %s: %s", root, err)
+		}
+		return
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || !isExecutionWorkDir(entry.Name()) || time.Since(entry.ModTime()) < config.DryRunRetention {
+			continue
+		}
+
+		folder := path.Join(root, entry.Name())
+		if err := os.RemoveAll(folder); err != nil {
+			log.Warnf("Error removing the dry run %s: %s", folder, err)
+			continue
+		}
+		log.Debugf("Dry run %s removed", folder)
+	}
+}
+
+func (a *ansibleCheckEngine) run(
+	ctx context.Context, e *ExecutionEvent, outputHandler func(stream, line string)) (_ *ExecutionResults, err error) {
+
+	_, span := tracer.Start(ctx, "CreateInventory", trace.WithAttributes(attribute.Int("trento.hosts", len(e.Hosts))))
+	checksRunner, err := NewAnsibleCheckRunner(a.config, e)
+	endSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if releaseErr := releaseWorkDir(ctx, a.config, path.Dir(checksRunner.Inventory), err != nil); releaseErr != nil {
+			loggerFromContext(ctx).Errorf("Error removing the execution work dir: %s", releaseErr)
+		}
+	}()
+
+	// The dry run finishes the execution without results, once the files are written
+	if a.config.DryRun || e.DryRun {
+		pruneDryRuns(a.config)
+		return nil, checksRunner.WriteDryRun(ctx, path.Join(dryRunDir(a.config), e.runID()))
+	}
+
+	pruneFactCache(a.config)
+	pruneWorkDirs(a.config)
+
+	checksRunner.OutputHandler = outputHandler
+	checksRunner.Cgroup = NewExecutionCgroup(a.config, e.runID())
+	err = checksRunner.RunPlaybookContext(ctx)
+	if checksRunner.Results == nil {
+		return nil, err
+	}
+
+	results := NewExecutionResults(e.ClusterID.String(), checksRunner.Results)
+	if checks, orderErr := orderedChecks(a.config); orderErr == nil && checks != nil {
+		results.skipDependents(e, checks)
+	}
+
+	return results, err
+}
@@ -0,0 +1,233 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/trento-project/runner/runner/mocks"
+)
+
+type AnsibleCheckEngineTestSuite struct {
+	suite.Suite
+	ansibleDir  string
+	engine      *ansibleCheckEngine
+	execution   *ExecutionEvent
+	mockCommand *mocks.CustomCommand
+}
+
+func TestAnsibleCheckEngineTestSuite(t *testing.T) {
+	suite.Run(t, new(AnsibleCheckEngineTestSuite))
+}
+
+func (suite *AnsibleCheckEngineTestSuite) SetupTest() {
+	hostRetryInterval = 0
+	suite.ansibleDir, _ = ioutil.TempDir(os.TempDir(), "trentotest")
+	os.MkdirAll(path.Join(suite.ansibleDir, "ansible"), 0755)
+	os.Create(path.Join(suite.ansibleDir, "ansible/check.yml"))
+
+	suite.engine = &ansibleCheckEngine{config: &Config{AnsibleFolder: suite.ansibleDir, HostRetries: 2}}
+	suite.execution = &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Checks:      []string{"156F64"},
+		Hosts: []*Host{
+			{HostID: uuid.New(), Address: "192.168.10.1", User: "root"},
+			{HostID: uuid.New(), Address: "192.168.10.2", User: "root"},
+		},
+	}
+
+	suite.mockCommand = new(mocks.CustomCommand)
+	customExecCommand = suite.mockCommand.Execute
+}
+
+func (suite *AnsibleCheckEngineTestSuite) TearDownTest() {
+	customExecCommand = exec.Command
+	hostRetryInterval = time.Second * 5
+	os.RemoveAll(suite.ansibleDir)
+}
+
+// playbookCommand returns a command printing the json output of a playbook run in the given hosts,
+// exiting with the unreachable hosts exit code if some of them are not reachable
+func (suite *AnsibleCheckEngineTestSuite) playbookCommand(reachable map[*Host]bool) *exec.Cmd {
+	hosts := ""
+	for host, isReachable := range reachable {
+		if hosts != "" {
+			hosts += ","
+		}
+		if isReachable {
+			hosts += fmt.Sprintf(
+				`"%s":{"ansible_facts":{"test_check_id":"156F64","test_result":"passing"}}`, host.HostID.String())
+		} else {
+			hosts += fmt.Sprintf(`"%s":{"unreachable":true,"msg":"No route to host"}`, host.HostID.String())
+		}
+	}
+
+	exitCode := 0
+	for _, isReachable := range reachable {
+		if !isReachable {
+			exitCode = ansibleUnreachableHostsExitCode
+		}
+	}
+
+	output := fmt.Sprintf(`{"plays":[{"tasks":[{"task":{"name":"set_test_result"},"hosts":{%s}}]}]}`, hosts)
+	outputFile := path.Join(suite.ansibleDir, uuid.New().String()+".json")
+	ioutil.WriteFile(outputFile, []byte(output), 0644)
+
+	return exec.Command("sh", "-c", fmt.Sprintf("cat %s; exit %d", outputFile, exitCode))
+}
+
+func (suite *AnsibleCheckEngineTestSuite) limitArg(hosts ...*Host) string {
+	limit := "--limit="
+	for index, host := range hosts {
+		if index > 0 {
+			limit += ","
+		}
+		limit += host.HostID.String()
+	}
+
+	return limit
+}
+
+func (suite *AnsibleCheckEngineTestSuite) TestRunRetriesUnreachableHosts() {
+	host1, host2 := suite.execution.Hosts[0], suite.execution.Hosts[1]
+	suite.mockCommand.On("Execute", "ansible-playbook", mock.Anything, mock.Anything, "--check").Return(
+		suite.playbookCommand(map[*Host]bool{host1: true, host2: false})).Once()
+	suite.mockCommand.On("Execute", "ansible-playbook", mock.Anything, mock.Anything, suite.limitArg(host2), "--check").Return(
+		suite.playbookCommand(map[*Host]bool{host2: true})).Once()
+
+	results, err := suite.engine.Run(context.Background(), suite.execution, nil)
+
+	suite.NoError(err)
+	suite.Len(results.Hosts, 2)
+	for _, host := range results.Hosts {
+		suite.True(host.Reachable)
+		suite.Equal([]*CheckResult{{CheckID: "156F64", Result: "passing"}}, host.Results)
+	}
+	suite.mockCommand.AssertExpectations(suite.T())
+}
+
+func (suite *AnsibleCheckEngineTestSuite) TestRunRetriesExhausted() {
+	host1, host2 := suite.execution.Hosts[0], suite.execution.Hosts[1]
+	suite.mockCommand.On("Execute", "ansible-playbook", mock.Anything, mock.Anything, "--check").Return(
+		suite.playbookCommand(map[*Host]bool{host1: true, host2: false})).Once()
+	suite.mockCommand.On("Execute", "ansible-playbook", mock.Anything, mock.Anything, suite.limitArg(host2), "--check").Return(
+		suite.playbookCommand(map[*Host]bool{host2: false})).Once()
+	suite.mockCommand.On("Execute", "ansible-playbook", mock.Anything, mock.Anything, suite.limitArg(host2), "--check").Return(
+		suite.playbookCommand(map[*Host]bool{host2: false})).Once()
+
+	results, err := suite.engine.Run(context.Background(), suite.execution, nil)
+
+	suite.Nil(results)
+	suite.EqualError(err, "exit status 4")
+	suite.mockCommand.AssertExpectations(suite.T())
+}
+
+func (suite *AnsibleCheckEngineTestSuite) TestRunKeepsFailedWorkDir() {
+	suite.engine.config.HostRetries = 0
+	suite.engine.config.FailedWorkDirRetention = time.Hour
+	host1, host2 := suite.execution.Hosts[0], suite.execution.Hosts[1]
+	suite.mockCommand.On("Execute", "ansible-playbook", mock.Anything, mock.Anything, "--check").Return(
+		suite.playbookCommand(map[*Host]bool{host1: true, host2: false})).Once()
+
+	_, err := suite.engine.Run(context.Background(), suite.execution, nil)
+
+	suite.EqualError(err, "exit status 4")
+	workDir := path.Join(suite.ansibleDir, "executions", suite.execution.ExecutionID.String())
+	suite.FileExists(path.Join(workDir, "ansible_hosts"))
+	suite.FileExists(path.Join(workDir, failedWorkDirMarker))
+}
+
+func (suite *AnsibleCheckEngineTestSuite) TestRunWithoutRetries() {
+	suite.engine.config.HostRetries = 0
+	host1, host2 := suite.execution.Hosts[0], suite.execution.Hosts[1]
+	suite.mockCommand.On("Execute", "ansible-playbook", mock.Anything, mock.Anything, "--check").Return(
+		suite.playbookCommand(map[*Host]bool{host1: true, host2: false})).Once()
+
+	_, err := suite.engine.Run(context.Background(), suite.execution, nil)
+
+	suite.EqualError(err, "exit status 4")
+	suite.mockCommand.AssertExpectations(suite.T())
+}
+
+func (suite *AnsibleCheckEngineTestSuite) TestRunDryRun() {
+	ioutil.WriteFile(path.Join(suite.ansibleDir, AnsibleConfigFile), []byte("[defaults]\n"), 0644)
+	suite.execution.DryRun = true
+	suite.execution.Variables = map[string]interface{}{"sbd_timeout": 30}
+	suite.engine.config.SSHPassphrase = "secret"
+	os.Setenv("TRENTO_TEST_HANA_PASSWORD", "hanasecret")
+	defer os.Unsetenv("TRENTO_TEST_HANA_PASSWORD")
+	suite.engine.config.CheckVariables = map[string]interface{}{"hana_password": "${env:TRENTO_TEST_HANA_PASSWORD}"}
+	suite.engine.config.InventoryVariables = map[string]string{"ansible_become_password": "becomesecret"}
+
+	results, err := suite.engine.Run(context.Background(), suite.execution, nil)
+
+	suite.NoError(err)
+	suite.Nil(results)
+	suite.mockCommand.AssertNotCalled(suite.T(), "Execute", mock.Anything, mock.Anything)
+
+	dryRunDir := path.Join(suite.ansibleDir, "dry_run", suite.execution.ExecutionID.String())
+	command, err := ioutil.ReadFile(path.Join(dryRunDir, "command.sh"))
+	suite.NoError(err)
+	suite.Contains(string(command), "TRENTO_SSH_PASSPHRASE='********'")
+	suite.NotContains(string(command), "secret")
+	suite.Contains(string(command), "'ansible-playbook' '"+path.Join(suite.ansibleDir, "ansible/check.yml")+"'")
+
+	inventory, err := ioutil.ReadFile(path.Join(dryRunDir, "ansible_hosts"))
+	suite.NoError(err)
+	suite.Contains(string(inventory), suite.execution.Hosts[0].HostID.String())
+	suite.Contains(string(inventory), "ansible_become_password=********")
+	suite.NotContains(string(inventory), "becomesecret")
+	extraVars, err := ioutil.ReadFile(path.Join(dryRunDir, "extra_vars.json"))
+	suite.NoError(err)
+	suite.Contains(string(extraVars), `"hana_password":"********"`)
+	suite.Contains(string(extraVars), `"sbd_timeout":30`)
+	suite.NotContains(string(extraVars), "hanasecret")
+	suite.FileExists(path.Join(dryRunDir, "ansible.cfg"))
+
+	// The dry run files are only readable by the runner user
+	info, err := os.Stat(dryRunDir)
+	suite.NoError(err)
+	suite.Equal(os.FileMode(0700), info.Mode().Perm())
+	for _, file := range []string{"command.sh", "ansible_hosts", "extra_vars.json", "ansible.cfg"} {
+		info, err := os.Stat(path.Join(dryRunDir, file))
+		suite.NoError(err)
+		suite.Equal(os.FileMode(0600), info.Mode().Perm())
+	}
+
+	// The dry run files are kept, but not the execution inventory
+	suite.NoDirExists(path.Join(suite.ansibleDir, "executions", suite.execution.ExecutionID.String()))
+}
+
+func (suite *AnsibleCheckEngineTestSuite) TestRunDryRunPrunesOldDryRuns() {
+	ioutil.WriteFile(path.Join(suite.ansibleDir, AnsibleConfigFile), []byte("[defaults]\n"), 0644)
+	suite.execution.DryRun = true
+	suite.engine.config.DryRunRetention = time.Hour
+	root := path.Join(suite.ansibleDir, "dry_run")
+	old := path.Join(root, uuid.New().String())
+	recent := path.Join(root, uuid.New().String()+"-chunk-1")
+	other := path.Join(root, "other")
+	for _, folder := range []string{old, recent, other} {
+		os.MkdirAll(folder, 0700)
+	}
+	twoHoursAgo := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(old, twoHoursAgo, twoHoursAgo)
+	os.Chtimes(other, twoHoursAgo, twoHoursAgo)
+
+	_, err := suite.engine.Run(context.Background(), suite.execution, nil)
+
+	suite.NoError(err)
+	suite.NoDirExists(old)
+	suite.DirExists(recent)
+	suite.DirExists(other)
+	suite.DirExists(path.Join(root, suite.execution.ExecutionID.String()))
+}
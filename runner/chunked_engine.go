@@ -0,0 +1,101 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// chunkedCheckEngine runs the checks of the executions with many hosts in chunks of hosts, each one with its own
+// inventory, so the memory of the playbook and the ssh connections are bounded by the chunk size. The chunks run
+// one after the other, or up to the parallelism at a time, and their results are merged
+type chunkedCheckEngine struct {
+	engine      CheckEngine
+	size        int
+	parallelism int
+}
+
+func newChunkedCheckEngine(engine CheckEngine, size, parallelism int) *chunkedCheckEngine {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	return &chunkedCheckEngine{engine: engine, size: size, parallelism: parallelism}
+}
+
+func (c *chunkedCheckEngine) Run(
+	ctx context.Context, e *ExecutionEvent, outputHandler func(stream, line string)) (*ExecutionResults, error) {
+
+	chunks := c.chunks(e)
+	if len(chunks) <= 1 {
+		return c.engine.Run(ctx, e, outputHandler)
+	}
+
+	loggerFromContext(ctx).Infof(
+		"Running the checks of execution %s in %d chunks of up to %d hosts, %d at a time",
+		e.ExecutionID.String(), len(chunks), c.size, c.parallelism)
+
+	// The chunks are taken in order by the workers, so they run one after the other without parallelism
+	chunksResults := make([]*ExecutionResults, len(chunks))
+	indexes := make(chan int, len(chunks))
+	for index := range chunks {
+		indexes <- index
+	}
+	close(indexes)
+
+	g, groupCtx := errgroup.WithContext(ctx)
+	for worker := 0; worker < c.parallelism && worker < len(chunks); worker++ {
+		g.Go(func() error {
+			for index := range indexes {
+				if err := groupCtx.Err(); err != nil {
+					return err
+				}
+				results, err := c.engine.Run(groupCtx, chunks[index], outputHandler)
+				if err != nil {
+					return fmt.Errorf("chunk %d of %d: %w", chunks[index].chunk, len(chunks), err)
+				}
+				chunksResults[index] = results
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	// The dry runs do not have results
+	var results *ExecutionResults
+	for _, chunkResults := range chunksResults {
+		if chunkResults == nil {
+			continue
+		}
+		if results == nil {
+			results = &ExecutionResults{ClusterID: e.ClusterID.String(), Hosts: []*HostResults{}}
+		}
+		results.replaceHostsResults(chunkResults)
+	}
+
+	return results, nil
+}
+
+// chunks splits the target hosts of the execution in events of up to the chunk size hosts, numbered from 1.
+// The hosts out of the limit are left out, the inventory of each chunk only has the hosts it checks
+func (c *chunkedCheckEngine) chunks(e *ExecutionEvent) []*ExecutionEvent {
+	hosts := e.targetHosts()
+	chunks := []*ExecutionEvent{}
+	for start := 0; start < len(hosts); start += c.size {
+		end := start + c.size
+		if end > len(hosts) {
+			end = len(hosts)
+		}
+
+		chunk := *e
+		chunk.Hosts = hosts[start:end]
+		chunk.Limit = nil
+		chunk.chunk = len(chunks) + 1
+		chunks = append(chunks, &chunk)
+	}
+
+	return chunks
+}
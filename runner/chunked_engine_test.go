@@ -0,0 +1,142 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func chunkedTestEvent(hostsCount int) *ExecutionEvent {
+	event := &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Checks:      []string{"156F64"},
+		Hosts:       []*Host{},
+	}
+	for i := 0; i < hostsCount; i++ {
+		event.Hosts = append(event.Hosts, &Host{HostID: uuid.New(), Address: "192.168.10.1", User: "root"})
+	}
+
+	return event
+}
+
+func hostsResults(e *ExecutionEvent) *ExecutionResults {
+	results := &ExecutionResults{ClusterID: e.ClusterID.String(), Hosts: []*HostResults{}}
+	for _, host := range e.targetHosts() {
+		results.addHost(host.HostID.String(), true, "")
+		results.addResult(host.HostID.String(), "156F64", checkResultPassing, "")
+	}
+
+	return results
+}
+
+func TestChunkedCheckEngine(t *testing.T) {
+	event := chunkedTestEvent(5)
+	event.Limit = []uuid.UUID{event.Hosts[0].HostID, event.Hosts[1].HostID, event.Hosts[2].HostID, event.Hosts[4].HostID}
+
+	var mu sync.Mutex
+	runIDs := []string{}
+	engine := newChunkedCheckEngine(checkEngineFunc(func(e *ExecutionEvent) (*ExecutionResults, error) {
+		mu.Lock()
+		runIDs = append(runIDs, e.runID())
+		mu.Unlock()
+		assert.Empty(t, e.Limit)
+		return hostsResults(e), nil
+	}), 2, 0)
+
+	results, err := engine.Run(context.Background(), event, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		event.ExecutionID.String() + "-chunk-1",
+		event.ExecutionID.String() + "-chunk-2",
+	}, runIDs)
+
+	// The results are merged in the order of the hosts, without the ones out of the limit
+	assert.Equal(t, event.ClusterID.String(), results.ClusterID)
+	hostIDs := []string{}
+	for _, host := range results.Hosts {
+		hostIDs = append(hostIDs, host.HostID)
+	}
+	assert.Equal(t, []string{
+		event.Hosts[0].HostID.String(),
+		event.Hosts[1].HostID.String(),
+		event.Hosts[2].HostID.String(),
+		event.Hosts[4].HostID.String(),
+	}, hostIDs)
+}
+
+func TestChunkedCheckEngine_SingleChunk(t *testing.T) {
+	event := chunkedTestEvent(2)
+
+	engine := newChunkedCheckEngine(checkEngineFunc(func(e *ExecutionEvent) (*ExecutionResults, error) {
+		assert.Equal(t, event, e)
+		return hostsResults(e), nil
+	}), 2, 1)
+
+	results, err := engine.Run(context.Background(), event, nil)
+	assert.NoError(t, err)
+	assert.Len(t, results.Hosts, 2)
+}
+
+func TestChunkedCheckEngine_Parallelism(t *testing.T) {
+	event := chunkedTestEvent(10)
+
+	var running, maxRunning int32
+	engine := newChunkedCheckEngine(checkEngineFunc(func(e *ExecutionEvent) (*ExecutionResults, error) {
+		current := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			previous := atomic.LoadInt32(&maxRunning)
+			if current <= previous || atomic.CompareAndSwapInt32(&maxRunning, previous, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		return hostsResults(e), nil
+	}), 1, 3)
+
+	results, err := engine.Run(context.Background(), event, nil)
+	assert.NoError(t, err)
+	assert.Len(t, results.Hosts, 10)
+	assert.Equal(t, int32(3), maxRunning)
+}
+
+func TestChunkedCheckEngine_ChunkFailed(t *testing.T) {
+	event := chunkedTestEvent(3)
+
+	engine := newChunkedCheckEngine(checkEngineFunc(func(e *ExecutionEvent) (*ExecutionResults, error) {
+		if e.chunk == 2 {
+			return nil, errors.New("playbook failed")
+		}
+		return hostsResults(e), nil
+	}), 1, 1)
+
+	results, err := engine.Run(context.Background(), event, nil)
+	assert.EqualError(t, err, "chunk 2 of 3: playbook failed")
+	assert.Nil(t, results)
+}
+
+func TestChunkedCheckEngine_DryRun(t *testing.T) {
+	engine := newChunkedCheckEngine(checkEngineFunc(func(e *ExecutionEvent) (*ExecutionResults, error) {
+		return nil, nil
+	}), 1, 2)
+
+	results, err := engine.Run(context.Background(), chunkedTestEvent(3), nil)
+	assert.NoError(t, err)
+	assert.Nil(t, results)
+}
+
+func TestNewCheckEngine_Chunked(t *testing.T) {
+	engine, err := NewCheckEngine(&Config{CheckEngine: "ansible", ExecutionChunkSize: 50, ExecutionChunkParallelism: 2})
+	assert.NoError(t, err)
+	assert.IsType(t, &chunkedCheckEngine{}, engine)
+	assert.IsType(t, &ansibleCheckEngine{}, engine.(*chunkedCheckEngine).engine)
+	assert.Equal(t, 50, engine.(*chunkedCheckEngine).size)
+	assert.Equal(t, 2, engine.(*chunkedCheckEngine).parallelism)
+}
@@ -0,0 +1,143 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	AzureProvider = "azure"
+	AwsProvider   = "aws"
+	GcpProvider   = "gcp"
+
+	// The provider of the clusters not discovered by the Trento server. The playbook detects the provider of
+	// their hosts from their DMI facts, instead of running the provider specific checks with wrong expectations
+	UnknownProvider = "unknown"
+)
+
+var cloudCommandTimeout = time.Second * 30
+
+// InventorySource resolves the current address of a host from an external inventory,
+// as the cloud provider API, using the host name
+type InventorySource interface {
+	ResolveAddress(ctx context.Context, hostName string) (string, error)
+}
+
+// cloudInventorySources are the inventory sources by cluster provider.
+// They use the cloud providers CLIs, which must be installed and logged in
+var cloudInventorySources = map[string]InventorySource{
+	AzureProvider: &azureInventorySource{},
+	AwsProvider:   &awsInventorySource{},
+	GcpProvider:   &gcpInventorySource{},
+}
+
+// resolveHostsAddresses returns a copy of the execution event with the hosts addresses
+// given by the provider inventory. The original address is kept if it cannot be resolved
+func resolveHostsAddresses(ctx context.Context, e *ExecutionEvent) *ExecutionEvent {
+	source, ok := cloudInventorySources[e.Provider]
+	if !ok {
+		return e
+	}
+
+	resolved := *e
+	resolved.Hosts = make([]*Host, 0, len(e.Hosts))
+	for _, host := range e.Hosts {
+		resolvedHost := *host
+		resolved.Hosts = append(resolved.Hosts, &resolvedHost)
+
+		if host.Name == "" {
+			continue
+		}
+
+		address, err := source.ResolveAddress(ctx, host.Name)
+		if err != nil {
+			loggerFromContext(ctx).Warnf("Could not resolve the address of host %s in %s, using %s: %s", host.Name, e.Provider, host.Address, err)
+			continue
+		}
+
+		if address != host.Address {
+			loggerFromContext(ctx).Infof("Host %s address resolved in %s: %s, instead of %s", host.Name, e.Provider, address, host.Address)
+		}
+		resolvedHost.Address = address
+	}
+
+	return &resolved
+}
+
+func runCloudCommand(ctx context.Context, output interface{}, name string, args ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, cloudCommandTimeout)
+	defer cancel()
+
+	content, err := runCommand(ctx, customExecCommand(name, args...), nil, loggerFromContext(ctx).Debugf, nil)
+	if err != nil {
+		return fmt.Errorf("%s command failed: %s", name, err)
+	}
+
+	return json.Unmarshal(content, output)
+}
+
+type azureInventorySource struct{}
+
+func (s *azureInventorySource) ResolveAddress(ctx context.Context, hostName string) (string, error) {
+	var vms []struct {
+		VirtualMachine struct {
+			Network struct {
+				PrivateIPAddresses []string `json:"privateIpAddresses"`
+			} `json:"network"`
+		} `json:"virtualMachine"`
+	}
+
+	if err := runCloudCommand(ctx, &vms, "az", "vm", "list-ip-addresses", "--name", hostName, "--output", "json"); err != nil {
+		return "", err
+	}
+
+	if len(vms) != 1 || len(vms[0].VirtualMachine.Network.PrivateIPAddresses) == 0 {
+		return "", fmt.Errorf("%d virtual machines found with an address", len(vms))
+	}
+
+	return vms[0].VirtualMachine.Network.PrivateIPAddresses[0], nil
+}
+
+type awsInventorySource struct{}
+
+func (s *awsInventorySource) ResolveAddress(ctx context.Context, hostName string) (string, error) {
+	var addresses []string
+
+	err := runCloudCommand(ctx, &addresses, "aws", "ec2", "describe-instances",
+		"--filters", fmt.Sprintf("Name=tag:Name,Values=%s", hostName), "Name=instance-state-name,Values=running",
+		"--query", "Reservations[].Instances[].PrivateIpAddress",
+		"--output", "json")
+	if err != nil {
+		return "", err
+	}
+
+	if len(addresses) != 1 {
+		return "", fmt.Errorf("%d running instances found", len(addresses))
+	}
+
+	return addresses[0], nil
+}
+
+type gcpInventorySource struct{}
+
+func (s *gcpInventorySource) ResolveAddress(ctx context.Context, hostName string) (string, error) {
+	var instances []struct {
+		NetworkInterfaces []struct {
+			NetworkIP string `json:"networkIP"`
+		} `json:"networkInterfaces"`
+	}
+
+	err := runCloudCommand(ctx, &instances, "gcloud", "compute", "instances", "list",
+		fmt.Sprintf("--filter=name=%s", hostName), "--format=json")
+	if err != nil {
+		return "", err
+	}
+
+	if len(instances) != 1 || len(instances[0].NetworkInterfaces) == 0 {
+		return "", fmt.Errorf("%d instances found with an address", len(instances))
+	}
+
+	return instances[0].NetworkInterfaces[0].NetworkIP, nil
+}
@@ -0,0 +1,105 @@
+package runner
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/trento-project/runner/runner/mocks"
+)
+
+func TestAzureInventorySource(t *testing.T) {
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+	mockCommand.On("Execute", "az", "vm", "list-ip-addresses", "--name", "vmhana01", "--output", "json").Return(
+		exec.Command("echo", `[{"virtualMachine": {"name": "vmhana01", "network": {"privateIpAddresses": ["10.74.1.10"]}}}]`))
+
+	address, err := (&azureInventorySource{}).ResolveAddress(context.Background(), "vmhana01")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "10.74.1.10", address)
+}
+
+func TestAwsInventorySource(t *testing.T) {
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+	mockCommand.On("Execute", "aws", "ec2", "describe-instances",
+		"--filters", "Name=tag:Name,Values=vmhana01", "Name=instance-state-name,Values=running",
+		"--query", "Reservations[].Instances[].PrivateIpAddress",
+		"--output", "json").Return(
+		exec.Command("echo", `["10.0.1.10"]`))
+
+	address, err := (&awsInventorySource{}).ResolveAddress(context.Background(), "vmhana01")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.1.10", address)
+}
+
+func TestGcpInventorySource(t *testing.T) {
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+	mockCommand.On("Execute", "gcloud", "compute", "instances", "list", "--filter=name=vmhana01", "--format=json").Return(
+		exec.Command("echo", `[{"name": "vmhana01", "networkInterfaces": [{"networkIP": "10.0.0.10"}]}]`))
+
+	address, err := (&gcpInventorySource{}).ResolveAddress(context.Background(), "vmhana01")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.10", address)
+}
+
+func TestGcpInventorySource_NotFound(t *testing.T) {
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+	mockCommand.On("Execute", "gcloud", "compute", "instances", "list", "--filter=name=vmhana01", "--format=json").Return(
+		exec.Command("echo", `[]`))
+
+	_, err := (&gcpInventorySource{}).ResolveAddress(context.Background(), "vmhana01")
+
+	assert.EqualError(t, err, "0 instances found with an address")
+}
+
+func TestResolveHostsAddresses(t *testing.T) {
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+	mockCommand.On("Execute", "aws", "ec2", "describe-instances",
+		"--filters", "Name=tag:Name,Values=vmhana01", "Name=instance-state-name,Values=running",
+		"--query", "Reservations[].Instances[].PrivateIpAddress",
+		"--output", "json").Return(
+		exec.Command("echo", `["10.0.1.11"]`))
+	mockCommand.On("Execute", "aws", "ec2", "describe-instances",
+		"--filters", "Name=tag:Name,Values=vmhana02", "Name=instance-state-name,Values=running",
+		"--query", "Reservations[].Instances[].PrivateIpAddress",
+		"--output", "json").Return(
+		exec.Command("sh", "-c", "exit 255"))
+
+	e := &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		Provider:    "aws",
+		Hosts: []*Host{
+			&Host{HostID: uuid.New(), Address: "10.0.1.10", User: "root", Name: "vmhana01"},
+			&Host{HostID: uuid.New(), Address: "10.0.1.20", User: "root", Name: "vmhana02"},
+			&Host{HostID: uuid.New(), Address: "10.0.1.30", User: "root"},
+		},
+	}
+
+	resolved := resolveHostsAddresses(context.Background(), e)
+
+	assert.Equal(t, "10.0.1.11", resolved.Hosts[0].Address)
+	assert.Equal(t, "10.0.1.20", resolved.Hosts[1].Address)
+	assert.Equal(t, "10.0.1.30", resolved.Hosts[2].Address)
+	// The original event is not modified
+	assert.Equal(t, "10.0.1.10", e.Hosts[0].Address)
+	assert.Equal(t, e.Hosts[0].HostID, resolved.Hosts[0].HostID)
+}
+
+func TestResolveHostsAddresses_UnknownProvider(t *testing.T) {
+	e := &ExecutionEvent{
+		Provider: "default",
+		Hosts:    []*Host{&Host{HostID: uuid.New(), Address: "10.0.1.10", User: "root", Name: "vmhana01"}},
+	}
+
+	assert.Equal(t, e, resolveHostsAddresses(context.Background(), e))
+}
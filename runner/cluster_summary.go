@@ -0,0 +1,165 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// The cluster health is unknown if the execution failed, as the results are not complete
+	ClusterHealthPassing  = "passing"
+	ClusterHealthWarning  = "warning"
+	ClusterHealthCritical = "critical"
+	ClusterHealthUnknown  = "unknown"
+)
+
+var summaryRequestTimeout = time.Second * 10
+
+// ClusterSummary is the outcome of an execution in its cluster: the number of results of each kind,
+// the number of unreachable hosts and the overall health, so the server does not compute them from the results
+type ClusterSummary struct {
+	ExecutionID uuid.UUID `json:"execution_id"`
+	ClusterID   string    `json:"cluster_id"`
+	Status      string    `json:"status"`
+	Health      string    `json:"health"`
+	Hosts       int       `json:"hosts"`
+	Unreachable int       `json:"unreachable"`
+	Passing     int       `json:"passing"`
+	Warning     int       `json:"warning"`
+	Critical    int       `json:"critical"`
+	Skipped     int       `json:"skipped"`
+	FinishedAt  time.Time `json:"finished_at"`
+}
+
+// NewClusterSummary counts the results of the execution report. The cluster is critical if any check is critical
+// or any host is unreachable, warning if any check is warning, and passing otherwise
+func NewClusterSummary(report *ExecutionReport) *ClusterSummary {
+	summary := &ClusterSummary{
+		ExecutionID: report.ExecutionID,
+		ClusterID:   report.ClusterID,
+		Status:      report.Status,
+		Hosts:       len(report.Hosts),
+		FinishedAt:  report.FinishedAt,
+	}
+
+	for _, host := range report.Hosts {
+		if !host.Reachable {
+			summary.Unreachable++
+		}
+		for _, result := range host.Results {
+			switch result.Result {
+			case checkResultPassing:
+				summary.Passing++
+			case checkResultWarning:
+				summary.Warning++
+			case checkResultCritical:
+				summary.Critical++
+			case checkResultSkipped:
+				summary.Skipped++
+			}
+		}
+	}
+
+	switch {
+	case report.Status == ExecutionFailed:
+		summary.Health = ClusterHealthUnknown
+	case summary.Critical > 0 || summary.Unreachable > 0:
+		summary.Health = ClusterHealthCritical
+	case summary.Warning > 0:
+		summary.Health = ClusterHealthWarning
+	default:
+		summary.Health = ClusterHealthPassing
+	}
+
+	return summary
+}
+
+// summaryClient collects the callbacks of each execution and posts its cluster summary to the Trento server
+// once it is finished. The summary is kept until it is posted, so the retried callbacks send the same one
+type summaryClient struct {
+	*reportCollector
+	url        string
+	httpClient *http.Client
+	signer     *ResultsSigner
+	mu         sync.Mutex
+	pending    map[uuid.UUID]*ClusterSummary
+}
+
+func NewSummaryClient(url string, transport http.RoundTripper, signer *ResultsSigner) *summaryClient {
+	return &summaryClient{
+		reportCollector: newReportCollector(),
+		url:             url,
+		httpClient:      &http.Client{Transport: transport, Timeout: summaryRequestTimeout},
+		signer:          signer,
+		pending:         make(map[uuid.UUID]*ClusterSummary),
+	}
+}
+
+func (s *summaryClient) Callback(executionID uuid.UUID, event string, payload interface{}) error {
+	s.mu.Lock()
+	summary, ok := s.pending[executionID]
+	s.mu.Unlock()
+
+	if !ok {
+		report, err := s.collect(executionID, event, payload)
+		if err != nil || report == nil {
+			return err
+		}
+		summary = NewClusterSummary(report)
+	}
+
+	if err := s.post(summary); err != nil {
+		s.mu.Lock()
+		s.pending[executionID] = summary
+		s.mu.Unlock()
+		return err
+	}
+
+	s.mu.Lock()
+	delete(s.pending, executionID)
+	s.mu.Unlock()
+	log.Infof("Execution %s summary sent, cluster %s is %s", executionID.String(), summary.ClusterID, summary.Health)
+
+	return nil
+}
+
+func (s *summaryClient) post(summary *ClusterSummary) error {
+	requestBody, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if s.signer != nil {
+		signature, err := s.signer.Sign(requestBody)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(resultsSignatureHeader, signature)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("cannot send the summary of execution %s, status code: %d",
+			summary.ExecutionID.String(), resp.StatusCode)
+	}
+
+	return nil
+}
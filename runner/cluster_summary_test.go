@@ -0,0 +1,89 @@
+package runner
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewClusterSummary(t *testing.T) {
+	report := &ExecutionReport{ExecutionID: uuid.New(), ClusterID: "cluster1", Status: ExecutionCompleted, Hosts: []*HostResults{
+		{HostID: "host1", Reachable: true, Results: []*CheckResult{
+			{CheckID: "156F64", Result: "passing"},
+			{CheckID: "53D035", Result: "warning"},
+			{CheckID: "A1244C", Result: "skipped"},
+		}},
+		{HostID: "host2", Reachable: true, Results: []*CheckResult{
+			{CheckID: "156F64", Result: "passing"},
+			{CheckID: "53D035", Result: "passing"},
+		}},
+	}}
+
+	summary := NewClusterSummary(report)
+	assert.Equal(t, &ClusterSummary{
+		ExecutionID: report.ExecutionID,
+		ClusterID:   "cluster1",
+		Status:      ExecutionCompleted,
+		Health:      ClusterHealthWarning,
+		Hosts:       2,
+		Passing:     3,
+		Warning:     1,
+		Skipped:     1,
+	}, summary)
+
+	report.Hosts = append(report.Hosts, &HostResults{HostID: "host3", Reachable: false, Results: []*CheckResult{}})
+	summary = NewClusterSummary(report)
+	assert.Equal(t, 1, summary.Unreachable)
+	assert.Equal(t, ClusterHealthCritical, summary.Health)
+
+	report.Hosts = report.Hosts[:1]
+	report.Hosts[0].Results = []*CheckResult{{CheckID: "156F64", Result: "passing"}}
+	assert.Equal(t, ClusterHealthPassing, NewClusterSummary(report).Health)
+
+	report.Status = ExecutionFailed
+	assert.Equal(t, ClusterHealthUnknown, NewClusterSummary(report).Health)
+}
+
+func TestSummaryClient(t *testing.T) {
+	summaries := []*ClusterSummary{}
+	failures := 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		var summary ClusterSummary
+		json.Unmarshal(body, &summary)
+		summaries = append(summaries, &summary)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	client := NewSummaryClient(server.URL, http.DefaultTransport, nil)
+	executionID := uuid.New()
+	clusterID := uuid.New().String()
+	assert.NoError(t, client.Callback(executionID, hostCompletedEvent, map[string]interface{}{
+		"cluster_id": clusterID, "host_id": "host1", "reachable": true, "msg": ""}))
+	assert.NoError(t, client.Callback(executionID, checkResultEvent, map[string]interface{}{
+		"cluster_id": clusterID, "host_id": "host1", "check_id": "156F64", "result": "critical", "msg": ""}))
+	assert.Empty(t, summaries)
+
+	// The failed summary is sent again when the callback is retried
+	err := client.Callback(executionID, executionFinishedEvent, map[string]string{"cluster_id": clusterID})
+	assert.EqualError(t, err, "cannot send the summary of execution "+executionID.String()+", status code: 503")
+	assert.NoError(t, client.Callback(executionID, executionFinishedEvent, map[string]string{"cluster_id": clusterID}))
+
+	assert.Len(t, summaries, 1)
+	assert.Equal(t, executionID, summaries[0].ExecutionID)
+	assert.Equal(t, clusterID, summaries[0].ClusterID)
+	assert.Equal(t, ClusterHealthCritical, summaries[0].Health)
+	assert.Equal(t, 1, summaries[0].Critical)
+	assert.Empty(t, client.pending)
+}
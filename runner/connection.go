@@ -0,0 +1,259 @@
+package runner
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	ansiblePassword       = "ansible_password"
+	ansibleBecomeMethod   = "ansible_become_method"
+	ansibleBecomeUser     = "ansible_become_user"
+	ansibleBecomePassword = "ansible_become_password"
+	ansibleConnection     = "ansible_connection"
+	ansiblePort           = "ansible_port"
+	ansibleWinRMTransport = "ansible_winrm_transport"
+	ansibleSSHCommonArgs  = "ansible_ssh_common_args"
+
+	winrmConnection = "winrm"
+
+	// BastionNone connects to the hosts directly, even if the runner or the execution have a bastion
+	BastionNone = "none"
+
+	// The connection passwords are given to ansible in these environment variables, one for each secret,
+	// and the inventory reads them with the env lookup, so they are never written in the runner files
+	hostSecretEnvPrefix = "TRENTO_HOST_SECRET_"
+)
+
+var validBecomeMethod = regexp.MustCompile(`^[a-z_]+$`)
+var validBecomeUser = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// The passwords secrets names are the keys of a kubernetes secret or of a vault secret
+var validSecretName = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// Each jump host of a bastion is [user@]host[:port], with the ipv6 addresses in brackets
+var validBastionHop = regexp.MustCompile(`^([A-Za-z0-9._-]+@)?([A-Za-z0-9.-]+|\[[0-9A-Fa-f:.]+\])(:[0-9]{1,5})?$`)
+
+// The winrm transports supported by ansible
+var validWinRMTransports = map[string]bool{
+	"basic":       true,
+	"certificate": true,
+	"ntlm":        true,
+	"kerberos":    true,
+	"credssp":     true,
+}
+
+// ConnectionOptions are the ansible ssh and become settings of the hosts, for the landscapes where the
+// key authentication is not allowed. The passwords are the names of secrets in the runner secrets provider.
+// The winrm settings are used in the windows hosts, where the ssh password is the winrm one.
+// The bastion is the ssh ProxyJump of the hosts, a comma separated list of jump hosts, or none to connect directly
+type ConnectionOptions struct {
+	SSHPasswordSecret    string `json:"ssh_password_secret,omitempty"`
+	BecomeMethod         string `json:"become_method,omitempty"`
+	BecomeUser           string `json:"become_user,omitempty"`
+	BecomePasswordSecret string `json:"become_password_secret,omitempty"`
+	WinRMPort            int    `json:"winrm_port,omitempty"`
+	WinRMTransport       string `json:"winrm_transport,omitempty"`
+	Bastion              string `json:"bastion,omitempty"`
+}
+
+// merge returns the options with the ones set in the overrides replaced
+func (o *ConnectionOptions) merge(overrides *ConnectionOptions) *ConnectionOptions {
+	merged := &ConnectionOptions{}
+	if o != nil {
+		*merged = *o
+	}
+	if overrides == nil {
+		return merged
+	}
+
+	if overrides.SSHPasswordSecret != "" {
+		merged.SSHPasswordSecret = overrides.SSHPasswordSecret
+	}
+	if overrides.BecomeMethod != "" {
+		merged.BecomeMethod = overrides.BecomeMethod
+	}
+	if overrides.BecomeUser != "" {
+		merged.BecomeUser = overrides.BecomeUser
+	}
+	if overrides.BecomePasswordSecret != "" {
+		merged.BecomePasswordSecret = overrides.BecomePasswordSecret
+	}
+	if overrides.WinRMPort != 0 {
+		merged.WinRMPort = overrides.WinRMPort
+	}
+	if overrides.WinRMTransport != "" {
+		merged.WinRMTransport = overrides.WinRMTransport
+	}
+	if overrides.Bastion != "" {
+		merged.Bastion = overrides.Bastion
+	}
+
+	return merged
+}
+
+func (o *ConnectionOptions) validate() error {
+	if o == nil {
+		return nil
+	}
+
+	if o.BecomeMethod != "" && !validBecomeMethod.MatchString(o.BecomeMethod) {
+		return fmt.Errorf("become method %s is not valid", o.BecomeMethod)
+	}
+	if o.BecomeUser != "" && !validBecomeUser.MatchString(o.BecomeUser) {
+		return fmt.Errorf("become user %s is not valid", o.BecomeUser)
+	}
+	for _, secret := range []string{o.SSHPasswordSecret, o.BecomePasswordSecret} {
+		if secret != "" && (!validSecretName.MatchString(secret) || secret == "." || secret == "..") {
+			return fmt.Errorf("password secret %s is not valid", secret)
+		}
+	}
+	if o.WinRMPort < 0 || o.WinRMPort > 65535 {
+		return fmt.Errorf("winrm port %d is out of range", o.WinRMPort)
+	}
+	if o.WinRMTransport != "" && !validWinRMTransports[o.WinRMTransport] {
+		return fmt.Errorf("winrm transport %s is not valid", o.WinRMTransport)
+	}
+	if o.Bastion != "" && !IsValidBastion(o.Bastion) {
+		return fmt.Errorf("bastion %s is not valid", o.Bastion)
+	}
+
+	return nil
+}
+
+// IsValidBastion tells if the bastion is none, or a comma separated list of jump hosts as the ssh ProxyJump option
+func IsValidBastion(bastion string) bool {
+	if bastion == BastionNone {
+		return true
+	}
+
+	for _, hop := range strings.Split(bastion, ",") {
+		if !validBastionHop.MatchString(hop) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// bastionAddress returns the address of the first jump host of the bastion, the one the runner connects to
+func bastionAddress(bastion string) string {
+	hop := strings.Split(bastion, ",")[0]
+	hop = hop[strings.LastIndex(hop, "@")+1:]
+	if _, _, err := net.SplitHostPort(hop); err == nil {
+		return hop
+	}
+
+	return strings.TrimSuffix(strings.TrimPrefix(hop, "["), "]")
+}
+
+// hostConnection returns the connection options of the host: the runner bastion, replaced by the options
+// of the execution and then by the ones of the host
+func hostConnection(defaultBastion string, e *ExecutionEvent, host *Host) *ConnectionOptions {
+	return (&ConnectionOptions{Bastion: defaultBastion}).merge(e.Connection).merge(host.Connection)
+}
+
+// validateConnection checks the connection options of the execution and its hosts
+func (e *ExecutionEvent) validateConnection() error {
+	if err := e.Connection.validate(); err != nil {
+		return err
+	}
+
+	for _, host := range e.Hosts {
+		if err := host.Connection.validate(); err != nil {
+			return fmt.Errorf("host %s %s", host.HostID.String(), err)
+		}
+	}
+
+	return nil
+}
+
+// setConnectionVariables sets the connection options of each host in the inventory nodes. The passwords are
+// read from the secrets provider, and given to ansible in the environment. Only the secrets with the host secrets
+// prefix can be read, so the requests cannot send the runner own secrets to the hosts. The bastion is only used by
+// the ssh hosts
+func setConnectionVariables(
+	config *Config, e *ExecutionEvent, inventoryContent *InventoryContent, ansibleRunner *AnsibleRunner) error {
+
+	var secretsProvider SecretsProvider
+	secretsEnvs := map[string]string{}
+	secretEnv := func(name string) (string, error) {
+		if env, ok := secretsEnvs[name]; ok {
+			return env, nil
+		}
+
+		if config.HostSecretsPrefix == "" || !strings.HasPrefix(name, config.HostSecretsPrefix) {
+			return "", fmt.Errorf("the %s secret is not a host secret, its name must start with the host secrets prefix", name)
+		}
+
+		if secretsProvider == nil {
+			provider, err := NewSecretsProvider(config)
+			if err != nil {
+				return "", err
+			}
+			if provider == nil {
+				return "", fmt.Errorf("the %s secret cannot be read without a secrets provider", name)
+			}
+			secretsProvider = provider
+		}
+
+		value, err := secretsProvider.GetSecret(name)
+		if err != nil {
+			return "", fmt.Errorf("cannot read the %s secret: %s", name, err)
+		}
+
+		env := hostSecretEnvPrefix + strconv.Itoa(len(secretsEnvs))
+		ansibleRunner.setEnv(env, value)
+		secretsEnvs[name] = env
+
+		return env, nil
+	}
+
+	for _, host := range e.Hosts {
+		options := hostConnection(config.SSHBastion, e, host)
+		node := inventoryContent.getNode(host.HostID.String())
+		if node == nil {
+			continue
+		}
+
+		if options.BecomeMethod != "" {
+			node.Variables[ansibleBecomeMethod] = options.BecomeMethod
+		}
+		if options.BecomeUser != "" {
+			node.Variables[ansibleBecomeUser] = options.BecomeUser
+		}
+		if host.isWindows() {
+			if options.WinRMPort != 0 {
+				node.Variables[ansiblePort] = options.WinRMPort
+			}
+			if options.WinRMTransport != "" {
+				node.Variables[ansibleWinRMTransport] = options.WinRMTransport
+			}
+		} else if options.Bastion != "" {
+			node.Variables[ansibleSSHCommonArgs] = fmt.Sprintf("'-o ProxyJump=%s'", options.Bastion)
+		}
+
+		passwords := []struct {
+			variable string
+			secret   string
+		}{
+			{ansiblePassword, options.SSHPasswordSecret},
+			{ansibleBecomePassword, options.BecomePasswordSecret},
+		}
+		for _, password := range passwords {
+			if password.secret == "" {
+				continue
+			}
+			env, err := secretEnv(password.secret)
+			if err != nil {
+				return err
+			}
+			node.Variables[password.variable] = fmt.Sprintf(`'{{ lookup("env", "%s") }}'`, env)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,213 @@
+package runner
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+)
+
+type ConnectionTestSuite struct {
+	suite.Suite
+	secretsDir string
+	config     *Config
+	execution  *ExecutionEvent
+}
+
+func TestConnectionTestSuite(t *testing.T) {
+	suite.Run(t, new(ConnectionTestSuite))
+}
+
+func (suite *ConnectionTestSuite) SetupTest() {
+	suite.secretsDir, _ = ioutil.TempDir(os.TempDir(), "trentotest")
+	ioutil.WriteFile(path.Join(suite.secretsDir, "host-ssh-password-prd"), []byte("sshsecret\n"), 0600)
+	ioutil.WriteFile(path.Join(suite.secretsDir, "host-become-password-prd"), []byte("becomesecret"), 0600)
+
+	suite.config = &Config{SecretsProvider: KubernetesSecretsProvider, SecretsDir: suite.secretsDir, HostSecretsPrefix: "host-"}
+	suite.execution = &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Provider:    "azure",
+		Checks:      []string{"check1"},
+		Hosts: []*Host{
+			{HostID: uuid.New(), Address: "192.168.10.1", User: "trento"},
+			{HostID: uuid.New(), Address: "192.168.10.2", User: "trento"},
+		},
+		Connection: &ConnectionOptions{
+			SSHPasswordSecret:    "host-ssh-password-prd",
+			BecomeMethod:         "sudo",
+			BecomePasswordSecret: "host-become-password-prd",
+		},
+	}
+}
+
+func (suite *ConnectionTestSuite) TearDownTest() {
+	os.RemoveAll(suite.secretsDir)
+}
+
+func (suite *ConnectionTestSuite) TestMerge() {
+	var options *ConnectionOptions
+	suite.Equal(&ConnectionOptions{}, options.merge(nil))
+
+	options = &ConnectionOptions{SSHPasswordSecret: "ssh-password", BecomeMethod: "sudo"}
+	suite.Equal(
+		&ConnectionOptions{SSHPasswordSecret: "ssh-password", BecomeMethod: "su", BecomeUser: "root"},
+		options.merge(&ConnectionOptions{BecomeMethod: "su", BecomeUser: "root"}))
+	suite.Equal("sudo", options.BecomeMethod)
+}
+
+func (suite *ConnectionTestSuite) TestValidateConnection() {
+	suite.NoError(suite.execution.validateConnection())
+
+	suite.execution.Connection.BecomeMethod = "sudo -i"
+	suite.EqualError(suite.execution.validateConnection(), "become method sudo -i is not valid")
+
+	suite.execution.Connection = nil
+	suite.execution.Hosts[1].Connection = &ConnectionOptions{BecomeUser: "root\n[all]"}
+	suite.EqualError(
+		suite.execution.validateConnection(),
+		"host "+suite.execution.Hosts[1].HostID.String()+" become user root\n[all] is not valid")
+
+	suite.execution.Hosts[1].Connection = &ConnectionOptions{WinRMTransport: "digest"}
+	suite.EqualError(
+		suite.execution.validateConnection(),
+		"host "+suite.execution.Hosts[1].HostID.String()+" winrm transport digest is not valid")
+
+	suite.execution.Hosts[1].Connection = &ConnectionOptions{WinRMPort: 65536}
+	suite.EqualError(
+		suite.execution.validateConnection(),
+		"host "+suite.execution.Hosts[1].HostID.String()+" winrm port 65536 is out of range")
+
+	suite.execution.Hosts[1].Connection = &ConnectionOptions{Bastion: "bastion.example.com -o ProxyCommand=sh"}
+	suite.EqualError(
+		suite.execution.validateConnection(),
+		"host "+suite.execution.Hosts[1].HostID.String()+" bastion bastion.example.com -o ProxyCommand=sh is not valid")
+
+	suite.execution.Hosts[1].Connection = &ConnectionOptions{BecomePasswordSecret: ".."}
+	suite.EqualError(
+		suite.execution.validateConnection(),
+		"host "+suite.execution.Hosts[1].HostID.String()+" password secret .. is not valid")
+
+	suite.execution.Hosts[1].Connection = &ConnectionOptions{SSHPasswordSecret: "../ssh-passphrase"}
+	suite.EqualError(
+		suite.execution.validateConnection(),
+		"host "+suite.execution.Hosts[1].HostID.String()+" password secret ../ssh-passphrase is not valid")
+}
+
+func (suite *ConnectionTestSuite) TestIsValidBastion() {
+	suite.True(IsValidBastion("bastion.example.com"))
+	suite.True(IsValidBastion("trento@bastion.example.com:2222"))
+	suite.True(IsValidBastion("trento@10.0.0.1,jump@[fd00::1]:22"))
+	suite.True(IsValidBastion("none"))
+	suite.False(IsValidBastion(""))
+	suite.False(IsValidBastion("bastion.example.com,"))
+	suite.False(IsValidBastion("'bastion.example.com'"))
+	suite.False(IsValidBastion("bastion.example.com:port"))
+}
+
+func (suite *ConnectionTestSuite) TestBastionAddress() {
+	suite.Equal("bastion.example.com", bastionAddress("trento@bastion.example.com"))
+	suite.Equal("10.0.0.1:2222", bastionAddress("10.0.0.1:2222,jump@10.0.0.2"))
+	suite.Equal("[fd00::1]:22", bastionAddress("jump@[fd00::1]:22"))
+	suite.Equal("fd00::1", bastionAddress("[fd00::1]"))
+}
+
+func (suite *ConnectionTestSuite) TestSetConnectionVariables_Bastion() {
+	suite.config.SSHBastion = "trento@bastion.example.com"
+	suite.execution.Connection = &ConnectionOptions{Bastion: "trento@bastion-prd.example.com:2222"}
+	suite.execution.Hosts = append(suite.execution.Hosts,
+		&Host{HostID: uuid.New(), Address: "192.168.10.3", User: "trento", Connection: &ConnectionOptions{Bastion: "none"}},
+		&Host{HostID: uuid.New(), Address: "192.168.10.4", User: "trento", Platform: PlatformWindows})
+	inventoryContent, _ := NewClusterInventoryContent(suite.execution)
+
+	suite.NoError(setConnectionVariables(suite.config, suite.execution, inventoryContent, DefaultAnsibleRunner()))
+
+	node1 := inventoryContent.getNode(suite.execution.Hosts[0].HostID.String())
+	suite.Equal("'-o ProxyJump=trento@bastion-prd.example.com:2222'", node1.Variables["ansible_ssh_common_args"])
+	node3 := inventoryContent.getNode(suite.execution.Hosts[2].HostID.String())
+	suite.Equal("'-o ProxyJump=none'", node3.Variables["ansible_ssh_common_args"])
+	// The windows hosts are not connected with ssh
+	node4 := inventoryContent.getNode(suite.execution.Hosts[3].HostID.String())
+	suite.NotContains(node4.Variables, "ansible_ssh_common_args")
+
+	// The runner bastion is used if the execution does not set one
+	suite.execution.Connection = nil
+	inventoryContent, _ = NewClusterInventoryContent(suite.execution)
+	suite.NoError(setConnectionVariables(suite.config, suite.execution, inventoryContent, DefaultAnsibleRunner()))
+	node1 = inventoryContent.getNode(suite.execution.Hosts[0].HostID.String())
+	suite.Equal("'-o ProxyJump=trento@bastion.example.com'", node1.Variables["ansible_ssh_common_args"])
+}
+
+func (suite *ConnectionTestSuite) TestSetConnectionVariables_Windows() {
+	suite.execution.Connection = &ConnectionOptions{WinRMPort: 5985, WinRMTransport: "ntlm"}
+	suite.execution.Hosts[1].Platform = PlatformWindows
+	suite.execution.Hosts[1].Connection = &ConnectionOptions{WinRMTransport: "kerberos"}
+	inventoryContent, _ := NewClusterInventoryContent(suite.execution)
+
+	suite.NoError(setConnectionVariables(suite.config, suite.execution, inventoryContent, DefaultAnsibleRunner()))
+
+	node1 := inventoryContent.getNode(suite.execution.Hosts[0].HostID.String())
+	suite.NotContains(node1.Variables, "ansible_port")
+	suite.NotContains(node1.Variables, "ansible_winrm_transport")
+
+	node2 := inventoryContent.getNode(suite.execution.Hosts[1].HostID.String())
+	suite.Equal(5985, node2.Variables["ansible_port"])
+	suite.Equal("kerberos", node2.Variables["ansible_winrm_transport"])
+	suite.Equal("winrm", node2.Variables["ansible_connection"])
+}
+
+func (suite *ConnectionTestSuite) TestSetConnectionVariables() {
+	suite.execution.Hosts[1].Connection = &ConnectionOptions{BecomeUser: "hacluster", BecomePasswordSecret: "host-ssh-password-prd"}
+	inventoryContent, _ := NewClusterInventoryContent(suite.execution)
+	ansibleRunner := DefaultAnsibleRunner()
+
+	suite.NoError(setConnectionVariables(suite.config, suite.execution, inventoryContent, ansibleRunner))
+
+	node1 := inventoryContent.getNode(suite.execution.Hosts[0].HostID.String())
+	suite.Equal(`'{{ lookup("env", "TRENTO_HOST_SECRET_0") }}'`, node1.Variables["ansible_password"])
+	suite.Equal("sudo", node1.Variables["ansible_become_method"])
+	suite.Equal(`'{{ lookup("env", "TRENTO_HOST_SECRET_1") }}'`, node1.Variables["ansible_become_password"])
+	suite.NotContains(node1.Variables, "ansible_become_user")
+
+	node2 := inventoryContent.getNode(suite.execution.Hosts[1].HostID.String())
+	suite.Equal(`'{{ lookup("env", "TRENTO_HOST_SECRET_0") }}'`, node2.Variables["ansible_password"])
+	suite.Equal("hacluster", node2.Variables["ansible_become_user"])
+	suite.Equal(`'{{ lookup("env", "TRENTO_HOST_SECRET_0") }}'`, node2.Variables["ansible_become_password"])
+
+	suite.Equal("sshsecret", ansibleRunner.Envs["TRENTO_HOST_SECRET_0"])
+	suite.Equal("becomesecret", ansibleRunner.Envs["TRENTO_HOST_SECRET_1"])
+	suite.True(isSensitiveEnv("TRENTO_HOST_SECRET_1"))
+}
+
+func (suite *ConnectionTestSuite) TestSetConnectionVariablesErrors() {
+	inventoryContent, _ := NewClusterInventoryContent(suite.execution)
+
+	err := setConnectionVariables(&Config{HostSecretsPrefix: "host-"}, suite.execution, inventoryContent, DefaultAnsibleRunner())
+	suite.EqualError(err, "the host-ssh-password-prd secret cannot be read without a secrets provider")
+
+	suite.execution.Connection.SSHPasswordSecret = "host-other"
+	err = setConnectionVariables(suite.config, suite.execution, inventoryContent, DefaultAnsibleRunner())
+	suite.EqualError(err, "cannot read the host-other secret: The secret is not available in the secrets provider")
+}
+
+func (suite *ConnectionTestSuite) TestSetConnectionVariables_HostSecretsPrefix() {
+	// The runner own secrets cannot be sent to the hosts
+	ioutil.WriteFile(path.Join(suite.secretsDir, "ssh-passphrase"), []byte("passphrase"), 0600)
+	suite.execution.Connection.SSHPasswordSecret = "ssh-passphrase"
+	inventoryContent, _ := NewClusterInventoryContent(suite.execution)
+	ansibleRunner := DefaultAnsibleRunner()
+
+	err := setConnectionVariables(suite.config, suite.execution, inventoryContent, ansibleRunner)
+	suite.EqualError(err, "the ssh-passphrase secret is not a host secret, its name must start with the host secrets prefix")
+	suite.NotContains(ansibleRunner.Envs, "TRENTO_HOST_SECRET_0")
+
+	// No secret can be read without a prefix
+	suite.config.HostSecretsPrefix = ""
+	suite.execution.Connection.SSHPasswordSecret = "host-ssh-password-prd"
+	err = setConnectionVariables(suite.config, suite.execution, inventoryContent, ansibleRunner)
+	suite.EqualError(
+		err, "the host-ssh-password-prd secret is not a host secret, its name must start with the host secrets prefix")
+}
@@ -0,0 +1,32 @@
+package runner
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sync"
+)
+
+var publishDebugVarsOnce sync.Once
+
+// NewDebugHandler returns the handler of the debug server, with the pprof profiles in /debug/pprof/ and
+// the expvar variables in /debug/vars. It is served in its own address, as it exposes the runner internals
+func NewDebugHandler() http.Handler {
+	// The expvar variables are global, they can only be published once in the process
+	publishDebugVarsOnce.Do(func() {
+		expvar.Publish("goroutines", expvar.Func(func() interface{} {
+			return runtime.NumGoroutine()
+		}))
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	return mux
+}
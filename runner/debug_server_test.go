@@ -0,0 +1,44 @@
+package runner
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugHandlerPprof(t *testing.T) {
+	handler := NewDebugHandler()
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest("GET", "/debug/pprof/", nil))
+	assert.Equal(t, 200, resp.Code)
+	assert.Contains(t, resp.Body.String(), "goroutine")
+
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest("GET", "/debug/pprof/heap?debug=1", nil))
+	assert.Equal(t, 200, resp.Code)
+	assert.Contains(t, resp.Body.String(), "heap profile")
+}
+
+func TestDebugHandlerVars(t *testing.T) {
+	// The variables are only published once, even if there are several handlers
+	NewDebugHandler()
+	handler := NewDebugHandler()
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest("GET", "/debug/vars", nil))
+	assert.Equal(t, 200, resp.Code)
+
+	var vars map[string]interface{}
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &vars))
+	assert.Contains(t, vars, "memstats")
+	assert.Greater(t, vars["goroutines"], float64(0))
+}
+
+func TestDebugHandlerNotFound(t *testing.T) {
+	resp := httptest.NewRecorder()
+	NewDebugHandler().ServeHTTP(resp, httptest.NewRequest("GET", "/api/health", nil))
+	assert.Equal(t, 404, resp.Code)
+}
@@ -1,7 +1,15 @@
 package runner
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 func ExecutionHandler(runnerService RunnerService) gin.HandlerFunc {
@@ -14,12 +22,183 @@ func ExecutionHandler(runnerService RunnerService) gin.HandlerFunc {
 			return
 		}
 
-		if err := runnerService.ScheduleExecution(r); err != nil {
+		if err := r.validate(); err != nil {
+			c.Error(err)
+			c.AbortWithStatusJSON(400, gin.H{"status": "nok", "message": err.Error()})
+			return
+		}
+
+		r.traceContext = executionTraceContext(propagation.HeaderCarrier(c.Request.Header))
+
+		err := runnerService.ScheduleExecution(r)
+		// The execution requested again is not run twice, the status of the existing one is returned
+		if status, ok := DuplicateExecutionStatus(err); ok {
+			c.JSON(200, map[string]string{
+				"status": "ok", "execution_id": r.ExecutionID.String(), "execution_status": status})
+			return
+		}
+		if err != nil {
+			c.Error(err)
+			status := 500
+			switch {
+			case errors.Is(err, ErrDraining):
+				status = 503
+			case errors.Is(err, ErrUpstreamNotFound):
+				status = 400
+			case errors.Is(err, ErrExecutionQueueFull):
+				status = 429
+			}
+			c.AbortWithStatusJSON(status, gin.H{"status": "nok", "message": err.Error()})
+			return
+		}
+
+		c.JSON(202, map[string]string{"status": "ok", "execution_id": r.ExecutionID.String()})
+	}
+}
+
+const (
+	defaultExecutionsPerPage = 20
+	maxExecutionsPerPage     = 100
+
+	logEvent    = "log"
+	logEndEvent = "end"
+)
+
+// ExecutionsHistoryHandler lists the stored executions, the most recent first, paginated
+// with the page and per_page query parameters
+func ExecutionsHistoryHandler(executionsStore ExecutionsStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if executionsStore == nil {
+			c.AbortWithStatusJSON(404, gin.H{"status": "nok", "message": "executions history is disabled"})
+			return
+		}
+
+		page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+		if err != nil || page < 1 {
+			c.AbortWithStatusJSON(400, gin.H{"status": "nok", "message": "invalid page"})
+			return
+		}
+
+		perPage, err := strconv.Atoi(c.DefaultQuery("per_page", strconv.Itoa(defaultExecutionsPerPage)))
+		if err != nil || perPage < 1 || perPage > maxExecutionsPerPage {
+			c.AbortWithStatusJSON(400, gin.H{"status": "nok", "message": "invalid per_page"})
+			return
+		}
+
+		records, total, err := executionsStore.List((page-1)*perPage, perPage)
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatusJSON(500, gin.H{"status": "nok", "message": err.Error()})
+			return
+		}
+
+		// The tasks outputs and the checks durations are only returned with each execution record
+		for index, record := range records {
+			if len(record.Outputs) > 0 || len(record.CheckDurations) > 0 {
+				withoutOutputs := *record
+				withoutOutputs.Outputs = nil
+				withoutOutputs.CheckDurations = nil
+				records[index] = &withoutOutputs
+			}
+		}
+
+		c.JSON(200, gin.H{
+			"items":    records,
+			"total":    total,
+			"page":     page,
+			"per_page": perPage,
+		})
+	}
+}
+
+func ExecutionRecordHandler(executionsStore ExecutionsStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if executionsStore == nil {
+			c.AbortWithStatusJSON(404, gin.H{"status": "nok", "message": "executions history is disabled"})
+			return
+		}
+
+		executionID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.AbortWithStatusJSON(400, gin.H{"status": "nok", "message": "invalid execution id"})
+			return
+		}
+
+		record, err := executionsStore.Get(executionID)
+		if err != nil {
 			c.Error(err)
 			c.AbortWithStatusJSON(500, gin.H{"status": "nok", "message": err.Error()})
 			return
 		}
 
-		c.JSON(202, map[string]string{"status": "ok"})
+		if record == nil {
+			c.AbortWithStatusJSON(404, gin.H{"status": "nok", "message": "execution not found"})
+			return
+		}
+
+		c.JSON(200, record)
+	}
+}
+
+// ExecutionLogsHandler streams the ansible output of an execution as server-sent events.
+// The lines already printed are sent first, followed by the new ones until the execution finishes
+func ExecutionLogsHandler(executionLogs *ExecutionLogs) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		executionID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.AbortWithStatusJSON(400, gin.H{"status": "nok", "message": "invalid execution id"})
+			return
+		}
+
+		history, lines, unsubscribe, ok := executionLogs.Subscribe(executionID)
+		if !ok {
+			c.AbortWithStatusJSON(404, gin.H{"status": "nok", "message": "execution logs not found"})
+			return
+		}
+		defer unsubscribe()
+
+		for _, line := range history {
+			c.SSEvent(logEvent, line)
+		}
+		c.Writer.Flush()
+
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case line, ok := <-lines:
+				if !ok {
+					c.SSEvent(logEndEvent, gin.H{"execution_id": executionID.String()})
+					return false
+				}
+				c.SSEvent(logEvent, line)
+				return true
+			case <-c.Request.Context().Done():
+				return false
+			}
+		})
+	}
+}
+
+// ExecutionLogsBundleHandler downloads the log files of an execution as a tar.gz archive, for the support cases
+func ExecutionLogsBundleHandler(executionLogs *ExecutionLogs) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		executionID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.AbortWithStatusJSON(400, gin.H{"status": "nok", "message": "invalid execution id"})
+			return
+		}
+
+		var bundle bytes.Buffer
+		err = executionLogs.WriteBundle(executionID, &bundle)
+		switch {
+		case errors.Is(err, ErrExecutionLogFilesDisabled), errors.Is(err, ErrExecutionLogFilesNotFound):
+			c.AbortWithStatusJSON(404, gin.H{"status": "nok", "message": err.Error()})
+			return
+		case err != nil:
+			c.AbortWithStatusJSON(500, gin.H{"status": "nok", "message": err.Error()})
+			return
+		}
+
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-logs.tar.gz"`, executionID.String()))
+		c.Data(200, "application/gzip", bundle.Bytes())
 	}
 }
@@ -0,0 +1,685 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type ExecutionApiTestCase struct {
+	suite.Suite
+	config *Config
+}
+
+func TestExecutionApiTestCase(t *testing.T) {
+	suite.Run(t, new(ExecutionApiTestCase))
+}
+
+func (suite *ExecutionApiTestCase) SetupTest() {
+	suite.config = &Config{}
+}
+
+func (suite *ExecutionApiTestCase) newExecutionEvent() *ExecutionEvent {
+	return &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Provider:    "azure",
+		Checks:      []string{"check1"},
+		Hosts: []*Host{
+			&Host{
+				HostID:  uuid.New(),
+				Address: "192.168.10.1",
+				User:    "user1",
+			},
+		},
+	}
+}
+
+func (suite *ExecutionApiTestCase) Test_ExecutionsTest() {
+	execution := suite.newExecutionEvent()
+
+	mockRunnerService := new(MockRunnerService)
+	mockRunnerService.On("ScheduleExecution", mock.Anything).Return(nil)
+
+	deps := setupTestDependencies()
+	deps.runnerService = mockRunnerService
+
+	app, err := NewAppWithDeps(suite.config, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	body, _ := json.Marshal(execution)
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/executions", bytes.NewBuffer(body))
+	app.webEngine.ServeHTTP(resp, req)
+
+	expectedJson, _ := json.Marshal(map[string]string{
+		"status":       "ok",
+		"execution_id": execution.ExecutionID.String(),
+	})
+	suite.Equal(202, resp.Code)
+	suite.JSONEq(string(expectedJson), resp.Body.String())
+	mockRunnerService.AssertCalled(suite.T(), "ScheduleExecution", execution)
+}
+
+func (suite *ExecutionApiTestCase) Test_ExecutionsTest_ScheduleError() {
+	execution := suite.newExecutionEvent()
+
+	mockRunnerService := new(MockRunnerService)
+	mockRunnerService.On("ScheduleExecution", mock.Anything).Return(
+		fmt.Errorf("Cannot process more executions"))
+
+	deps := setupTestDependencies()
+	deps.runnerService = mockRunnerService
+
+	app, err := NewAppWithDeps(suite.config, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	body, _ := json.Marshal(execution)
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/executions", bytes.NewBuffer(body))
+	app.webEngine.ServeHTTP(resp, req)
+
+	expectedJson, _ := json.Marshal(map[string]string{
+		"status":  "nok",
+		"message": "Cannot process more executions",
+	})
+	suite.Equal(500, resp.Code)
+	suite.JSONEq(string(expectedJson), resp.Body.String())
+}
+
+func (suite *ExecutionApiTestCase) Test_ExecutionsTest_BadRequest() {
+	mockRunnerService := new(MockRunnerService)
+
+	deps := setupTestDependencies()
+	deps.runnerService = mockRunnerService
+
+	app, err := NewAppWithDeps(suite.config, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/executions", bytes.NewBufferString(`{"provider": "azure"}`))
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(400, resp.Code)
+	mockRunnerService.AssertNotCalled(suite.T(), "ScheduleExecution", mock.Anything)
+}
+
+func (suite *ExecutionApiTestCase) Test_ExecutionsTest_InvalidLimit() {
+	execution := suite.newExecutionEvent()
+	limitHost := uuid.New()
+	execution.Limit = []uuid.UUID{limitHost}
+
+	mockRunnerService := new(MockRunnerService)
+
+	deps := setupTestDependencies()
+	deps.runnerService = mockRunnerService
+
+	app, err := NewAppWithDeps(suite.config, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	body, _ := json.Marshal(execution)
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/executions", bytes.NewBuffer(body))
+	app.webEngine.ServeHTTP(resp, req)
+
+	expectedJson, _ := json.Marshal(map[string]string{
+		"status":  "nok",
+		"message": fmt.Sprintf("limit host %s is not an execution host", limitHost.String()),
+	})
+	suite.Equal(400, resp.Code)
+	suite.JSONEq(string(expectedJson), resp.Body.String())
+	mockRunnerService.AssertNotCalled(suite.T(), "ScheduleExecution", mock.Anything)
+}
+
+func (suite *ExecutionApiTestCase) Test_ExecutionsTest_InvalidRole() {
+	execution := suite.newExecutionEvent()
+	execution.Hosts[0].Role = "hana_tertiary"
+
+	mockRunnerService := new(MockRunnerService)
+
+	deps := setupTestDependencies()
+	deps.runnerService = mockRunnerService
+
+	app, err := NewAppWithDeps(suite.config, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	body, _ := json.Marshal(execution)
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/executions", bytes.NewBuffer(body))
+	app.webEngine.ServeHTTP(resp, req)
+
+	expectedJson, _ := json.Marshal(map[string]string{
+		"status":  "nok",
+		"message": fmt.Sprintf("host %s role hana_tertiary is not valid", execution.Hosts[0].HostID.String()),
+	})
+	suite.Equal(400, resp.Code)
+	suite.JSONEq(string(expectedJson), resp.Body.String())
+	mockRunnerService.AssertNotCalled(suite.T(), "ScheduleExecution", mock.Anything)
+}
+
+func (suite *ExecutionApiTestCase) Test_ExecutionsTest_InvalidPlatform() {
+	execution := suite.newExecutionEvent()
+	execution.Hosts[0].Platform = "aix"
+
+	mockRunnerService := new(MockRunnerService)
+
+	deps := setupTestDependencies()
+	deps.runnerService = mockRunnerService
+
+	app, err := NewAppWithDeps(suite.config, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	body, _ := json.Marshal(execution)
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/executions", bytes.NewBuffer(body))
+	app.webEngine.ServeHTTP(resp, req)
+
+	expectedJson, _ := json.Marshal(map[string]string{
+		"status":  "nok",
+		"message": fmt.Sprintf("host %s platform aix is not supported", execution.Hosts[0].HostID.String()),
+	})
+	suite.Equal(400, resp.Code)
+	suite.JSONEq(string(expectedJson), resp.Body.String())
+	mockRunnerService.AssertNotCalled(suite.T(), "ScheduleExecution", mock.Anything)
+}
+
+func (suite *ExecutionApiTestCase) Test_ExecutionsTest_InvalidAddress() {
+	execution := suite.newExecutionEvent()
+	execution.Hosts[0].Address = "fd00::1:22222"
+
+	mockRunnerService := new(MockRunnerService)
+
+	deps := setupTestDependencies()
+	deps.runnerService = mockRunnerService
+
+	app, err := NewAppWithDeps(suite.config, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	body, _ := json.Marshal(execution)
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/executions", bytes.NewBuffer(body))
+	app.webEngine.ServeHTTP(resp, req)
+
+	expectedJson, _ := json.Marshal(map[string]string{
+		"status": "nok",
+		"message": fmt.Sprintf("host %s address fd00::1:22222 is not a valid ipv6 address, it must be bracketed to have a port",
+			execution.Hosts[0].HostID.String()),
+	})
+	suite.Equal(400, resp.Code)
+	suite.JSONEq(string(expectedJson), resp.Body.String())
+	mockRunnerService.AssertNotCalled(suite.T(), "ScheduleExecution", mock.Anything)
+}
+
+func (suite *ExecutionApiTestCase) Test_ExecutionsTest_InvalidRollingBatchSize() {
+	execution := suite.newExecutionEvent()
+	execution.RollingBatchSize = -1
+
+	mockRunnerService := new(MockRunnerService)
+
+	deps := setupTestDependencies()
+	deps.runnerService = mockRunnerService
+
+	app, err := NewAppWithDeps(suite.config, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	body, _ := json.Marshal(execution)
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/executions", bytes.NewBuffer(body))
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(400, resp.Code)
+	suite.JSONEq(`{"status":"nok","message":"rolling batch size -1 cannot be negative"}`, resp.Body.String())
+	mockRunnerService.AssertNotCalled(suite.T(), "ScheduleExecution", mock.Anything)
+}
+
+func (suite *ExecutionApiTestCase) setupHistoryApp() (*App, *boltExecutionsStore, func()) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	store, err := NewExecutionsStore(path.Join(tmpDir, "executions.db"))
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	deps := setupTestDependencies()
+	deps.executionsStore = store
+
+	app, err := NewAppWithDeps(suite.config, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	return app, store, func() {
+		store.Close()
+		os.RemoveAll(tmpDir)
+	}
+}
+
+func (suite *ExecutionApiTestCase) Test_ExecutionsHistoryTest() {
+	app, store, teardown := suite.setupHistoryApp()
+	defer teardown()
+
+	start := time.Date(2022, 5, 10, 10, 0, 0, 0, time.UTC)
+	records := []*ExecutionRecord{}
+	for i := 0; i < 3; i++ {
+		record := newTestExecutionRecord(start.Add(time.Duration(i) * time.Minute))
+		records = append(records, record)
+		store.Save(record)
+	}
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/executions?page=2&per_page=2", nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	expectedJson, _ := json.Marshal(map[string]interface{}{
+		"items":    []*ExecutionRecord{records[0]},
+		"total":    3,
+		"page":     2,
+		"per_page": 2,
+	})
+	suite.Equal(200, resp.Code)
+	suite.JSONEq(string(expectedJson), resp.Body.String())
+}
+
+func (suite *ExecutionApiTestCase) Test_ExecutionsHistoryTest_Outputs() {
+	app, store, teardown := suite.setupHistoryApp()
+	defer teardown()
+
+	record := newTestExecutionRecord(time.Date(2022, 5, 10, 10, 0, 0, 0, time.UTC))
+	record.Outputs = []*TaskOutput{&TaskOutput{HostID: "host1", Task: "1.1.1.check", Stdout: "token: 5000"}}
+	record.CheckDurations = []*CheckDuration{&CheckDuration{HostID: "host1", CheckID: "156F64", Seconds: 1.5}}
+	store.Save(record)
+
+	// The outputs and the checks durations are only returned with the execution record
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/executions", nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	withoutOutputs := *record
+	withoutOutputs.Outputs = nil
+	withoutOutputs.CheckDurations = nil
+	expectedJson, _ := json.Marshal([]*ExecutionRecord{&withoutOutputs})
+	suite.Equal(200, resp.Code)
+	var body map[string]json.RawMessage
+	json.Unmarshal(resp.Body.Bytes(), &body)
+	suite.JSONEq(string(expectedJson), string(body["items"]))
+
+	resp = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/api/executions/"+record.ExecutionID.String(), nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	expectedJson, _ = json.Marshal(record)
+	suite.Equal(200, resp.Code)
+	suite.JSONEq(string(expectedJson), resp.Body.String())
+}
+
+func (suite *ExecutionApiTestCase) Test_ExecutionsHistoryTest_InvalidPage() {
+	app, _, teardown := suite.setupHistoryApp()
+	defer teardown()
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/executions?page=0", nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(400, resp.Code)
+}
+
+func (suite *ExecutionApiTestCase) Test_ExecutionsHistoryTest_Disabled() {
+	app, err := NewAppWithDeps(suite.config, setupTestDependencies())
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/executions", nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(404, resp.Code)
+}
+
+func (suite *ExecutionApiTestCase) Test_ExecutionRecordTest() {
+	app, store, teardown := suite.setupHistoryApp()
+	defer teardown()
+
+	record := newTestExecutionRecord(time.Date(2022, 5, 10, 10, 0, 0, 0, time.UTC))
+	store.Save(record)
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/executions/"+record.ExecutionID.String(), nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	expectedJson, _ := json.Marshal(record)
+	suite.Equal(200, resp.Code)
+	suite.JSONEq(string(expectedJson), resp.Body.String())
+
+	resp = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/api/executions/"+uuid.New().String(), nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(404, resp.Code)
+}
+
+func (suite *ExecutionApiTestCase) Test_ExecuteTest_Draining() {
+	mockRunnerService := new(MockRunnerService)
+	mockRunnerService.On("ScheduleExecution", mock.Anything).Return(ErrDraining)
+
+	deps := setupTestDependencies()
+	deps.runnerService = mockRunnerService
+
+	app, err := NewAppWithDeps(suite.config, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	body, _ := json.Marshal(&ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Provider:    "azure",
+		Checks:      []string{},
+		Hosts:       []*Host{},
+	})
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/executions", bytes.NewBuffer(body))
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(503, resp.Code)
+}
+
+func (suite *ExecutionApiTestCase) Test_ExecuteTest_UnknownUpstream() {
+	mockRunnerService := new(MockRunnerService)
+	mockRunnerService.On("ScheduleExecution", mock.Anything).Return(fmt.Errorf("%w: customer1", ErrUpstreamNotFound))
+
+	deps := setupTestDependencies()
+	deps.runnerService = mockRunnerService
+
+	app, err := NewAppWithDeps(suite.config, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	body, _ := json.Marshal(&ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Provider:    "azure",
+		Checks:      []string{},
+		Hosts:       []*Host{},
+		Upstream:    "customer1",
+	})
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/executions", bytes.NewBuffer(body))
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(400, resp.Code)
+	suite.JSONEq(`{"status":"nok","message":"The upstream is not configured: customer1"}`, resp.Body.String())
+}
+
+func (suite *ExecutionApiTestCase) Test_ExecutionLogsTest() {
+	executionLogs := NewExecutionLogs()
+	executionID := uuid.New()
+	executionLogs.Start(executionID)
+	executionLogs.Write(executionID, StdoutStream, "PLAY [all]")
+
+	deps := setupTestDependencies()
+	deps.executionLogs = executionLogs
+
+	app, err := NewAppWithDeps(suite.config, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	server := httptest.NewServer(app.webEngine)
+	defer server.Close()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		executionLogs.Write(executionID, StderrStream, "[WARNING]: some warning")
+		executionLogs.Finish(executionID)
+	}()
+
+	resp, err := http.Get(fmt.Sprintf("%s/api/executions/%s/logs", server.URL, executionID.String()))
+	suite.NoError(err)
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	suite.Equal(200, resp.StatusCode)
+	suite.Equal("text/event-stream", resp.Header.Get("Content-Type"))
+	suite.Regexp(
+		`(?s)^event:log\ndata:\{"stream":"stdout","line":"PLAY \[all\]",.*\n\n`+
+			`event:log\ndata:\{"stream":"stderr","line":"\[WARNING\]: some warning",.*\n\n`+
+			`event:end\ndata:\{"execution_id":"`+executionID.String()+`"\}\n\n$`,
+		string(body))
+
+	resp, _ = http.Get(fmt.Sprintf("%s/api/executions/%s/logs", server.URL, uuid.New().String()))
+	suite.Equal(404, resp.StatusCode)
+}
+
+func (suite *ExecutionApiTestCase) Test_ExecuteTest_QueueFull() {
+	mockRunnerService := new(MockRunnerService)
+	mockRunnerService.On("ScheduleExecution", mock.Anything).Return(ErrExecutionQueueFull)
+
+	deps := setupTestDependencies()
+	deps.runnerService = mockRunnerService
+
+	app, err := NewAppWithDeps(suite.config, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	body, _ := json.Marshal(suite.newExecutionEvent())
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/executions", bytes.NewBuffer(body))
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(429, resp.Code)
+	suite.JSONEq(`{"status":"nok","message":"The executions queue is full, cannot process more executions"}`, resp.Body.String())
+}
+
+func (suite *ExecutionApiTestCase) Test_ExecuteTest_Duplicate() {
+	mockRunnerService := new(MockRunnerService)
+	mockRunnerService.On("ScheduleExecution", mock.Anything).Return(
+		&duplicateExecutionError{status: ExecutionRunning})
+
+	deps := setupTestDependencies()
+	deps.runnerService = mockRunnerService
+
+	app, err := NewAppWithDeps(suite.config, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	execution := suite.newExecutionEvent()
+	body, _ := json.Marshal(execution)
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/executions", bytes.NewBuffer(body))
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(200, resp.Code)
+	suite.JSONEq(fmt.Sprintf(
+		`{"status":"ok","execution_id":"%s","execution_status":"running"}`, execution.ExecutionID), resp.Body.String())
+}
+
+func (suite *ExecutionApiTestCase) Test_ExecutionsTest_InvalidPriority() {
+	execution := suite.newExecutionEvent()
+	execution.Priority = "urgent"
+
+	mockRunnerService := new(MockRunnerService)
+
+	deps := setupTestDependencies()
+	deps.runnerService = mockRunnerService
+
+	app, err := NewAppWithDeps(suite.config, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	body, _ := json.Marshal(execution)
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/executions", bytes.NewBuffer(body))
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(400, resp.Code)
+	suite.JSONEq(`{"status":"nok","message":"priority urgent is not valid, it must be low, normal or high"}`, resp.Body.String())
+	mockRunnerService.AssertNotCalled(suite.T(), "ScheduleExecution", mock.Anything)
+}
+
+func (suite *ExecutionApiTestCase) Test_ExecutionsTest_ReservedVariables() {
+	mockRunnerService := new(MockRunnerService)
+
+	deps := setupTestDependencies()
+	deps.runnerService = mockRunnerService
+
+	app, err := NewAppWithDeps(suite.config, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	for _, name := range []string{
+		"ansible_ssh_common_args", "ansible_connection", "ansible_become_password", "ANSIBLE_HOST",
+		"trento_check_timeout", "trento_checks_order", "trento_passed_checks", "cluster_selected_checks",
+		"node_platform", "provider",
+	} {
+		execution := suite.newExecutionEvent()
+		execution.Variables = map[string]interface{}{"expected_token_timeout": 30000, name: "value"}
+
+		body, _ := json.Marshal(execution)
+		resp := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/executions", bytes.NewBuffer(body))
+		app.webEngine.ServeHTTP(resp, req)
+
+		suite.Equal(400, resp.Code, name)
+		suite.JSONEq(fmt.Sprintf(`{"status":"nok","message":"variable %s is reserved"}`, name), resp.Body.String())
+	}
+	mockRunnerService.AssertNotCalled(suite.T(), "ScheduleExecution", mock.Anything)
+}
+
+func (suite *ExecutionApiTestCase) Test_ExecutionsTest_InvalidRemediateChecks() {
+	execution := suite.newExecutionEvent()
+	execution.RemediateChecks = []string{"156F64"}
+
+	mockRunnerService := new(MockRunnerService)
+
+	deps := setupTestDependencies()
+	deps.runnerService = mockRunnerService
+
+	app, err := NewAppWithDeps(suite.config, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	body, _ := json.Marshal(execution)
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/executions", bytes.NewBuffer(body))
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(400, resp.Code)
+	suite.JSONEq(`{"status":"nok","message":"remediate checks require remediate"}`, resp.Body.String())
+
+	execution.Remediate = true
+	execution.RemediateChecks = []string{"53D035"}
+	body, _ = json.Marshal(execution)
+	resp = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/api/executions", bytes.NewBuffer(body))
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(400, resp.Code)
+	suite.JSONEq(`{"status":"nok","message":"remediate check 53D035 is not a selected check"}`, resp.Body.String())
+	mockRunnerService.AssertNotCalled(suite.T(), "ScheduleExecution", mock.Anything)
+}
+
+func (suite *ExecutionApiTestCase) Test_ExecutionsTest_InvalidTags() {
+	execution := suite.newExecutionEvent()
+	execution.Tags = []string{"corosync,sbd"}
+
+	mockRunnerService := new(MockRunnerService)
+
+	deps := setupTestDependencies()
+	deps.runnerService = mockRunnerService
+
+	app, err := NewAppWithDeps(suite.config, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	body, _ := json.Marshal(execution)
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/executions", bytes.NewBuffer(body))
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(400, resp.Code)
+	suite.JSONEq(`{"status":"nok","message":"tag \"corosync,sbd\" is not valid"}`, resp.Body.String())
+
+	execution.Tags = []string{"corosync"}
+	execution.SkipTags = []string{"always"}
+	body, _ = json.Marshal(execution)
+	resp = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/api/executions", bytes.NewBuffer(body))
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(400, resp.Code)
+	suite.JSONEq(`{"status":"nok","message":"tag always cannot be skipped"}`, resp.Body.String())
+	mockRunnerService.AssertNotCalled(suite.T(), "ScheduleExecution", mock.Anything)
+}
+
+func (suite *ExecutionApiTestCase) Test_ExecutionLogsBundle() {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	executionLogs := NewExecutionLogs()
+	executionLogs.SetFiles(NewExecutionLogFiles(tmpDir, 0, 0))
+	executionID := uuid.New()
+	executionLogs.Start(executionID)
+	executionLogs.Write(executionID, StdoutStream, "PLAY [all]")
+	executionLogs.Finish(executionID)
+
+	deps := setupTestDependencies()
+	deps.executionLogs = executionLogs
+
+	app, err := NewAppWithDeps(suite.config, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", fmt.Sprintf("/api/executions/%s/logs/bundle", executionID), nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(200, resp.Code)
+	suite.Equal("application/gzip", resp.Header().Get("Content-Type"))
+	suite.Equal(
+		fmt.Sprintf(`attachment; filename="%s-logs.tar.gz"`, executionID), resp.Header().Get("Content-Disposition"))
+	suite.Contains(readBundle(suite.T(), resp.Body.Bytes())[executionID.String()+".log"], "stdout PLAY [all]")
+
+	resp = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", fmt.Sprintf("/api/executions/%s/logs/bundle", uuid.New()), nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(404, resp.Code)
+	suite.JSONEq(`{"status": "nok", "message": "The execution log files are not found"}`, resp.Body.String())
+}
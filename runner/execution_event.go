@@ -1,7 +1,12 @@
 package runner
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type ExecutionEvent struct {
@@ -10,10 +15,348 @@ type ExecutionEvent struct {
 	Provider    string    `json:"provider" binding:"required"`
 	Checks      []string  `json:"checks" binding:"required"`
 	Hosts       []*Host   `json:"hosts" binding:"required"`
+	// Variables are given to the checks as ansible extra vars, overriding the playbooks ones
+	Variables map[string]interface{} `json:"variables,omitempty"`
+	// Limit restricts the checks to the given hosts. The rest of hosts are kept in the inventory,
+	// so the checks comparing the cluster nodes can still read their variables
+	Limit []uuid.UUID `json:"limit,omitempty"`
+	// Tags restricts the checks tasks to the ones with any of the given ansible tags, and SkipTags skips
+	// the ones with them, e.g. to check only the corosync tasks again after fixing its configuration
+	Tags     []string `json:"tags,omitempty"`
+	SkipTags []string `json:"skip_tags,omitempty"`
+	// Upstream is the name of the Trento server that requested the execution, where the results are sent.
+	// The default one is used if it is empty
+	Upstream string `json:"upstream,omitempty"`
+	// Connection are the ansible connection options of all the hosts
+	Connection *ConnectionOptions `json:"connection,omitempty"`
+	// DryRun writes the ansible-playbook command and the rendered files, instead of running the checks
+	DryRun bool `json:"dry_run,omitempty"`
+	// RollingBatchSize is the number of hosts where the rolling checks run at the same time,
+	// overriding the runner one
+	RollingBatchSize int `json:"rolling_batch_size,omitempty"`
+	// Priority orders the executions waiting to be run: high, normal or low. The requested executions
+	// are normal if it is empty, and the scheduled ones are low
+	Priority string `json:"priority,omitempty"`
+	// Remediate runs the failing checks marked as remediable in the catalog again out of the ansible check mode,
+	// so their fix is applied, and checks them once more. Only the RemediateChecks are remediated if they are set
+	Remediate       bool     `json:"remediate,omitempty"`
+	RemediateChecks []string `json:"remediate_checks,omitempty"`
+	// remediation runs the checks out of the ansible check mode, applying their fix
+	remediation bool
+	// traceContext is the trace of the request that started the execution, if it is traced
+	traceContext trace.SpanContext
+	// chunk is the number of the chunk of hosts of a chunked execution, 0 if it is not chunked
+	chunk int
+	// hook is the stage and number of the playbook hook run for the execution, e.g. pre-1, empty for the checks
+	hook string
 }
 
+const (
+	HanaPrimaryRole   = "hana_primary"
+	HanaSecondaryRole = "hana_secondary"
+	MajorityMakerRole = "majority_maker"
+
+	// The checks run in the linux hosts by default. The windows hosts are connected with winrm,
+	// and only the checks tagged with the windows platform run in them
+	PlatformLinux   = "linux"
+	PlatformWindows = "windows"
+
+	PriorityLow    = "low"
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+
+	// The runner tasks of the checks playbook always run, whatever the execution tags are
+	ansibleAlwaysTag = "always"
+)
+
+// The extra vars take precedence over any other variable, so the execution variables cannot set the ansible
+// connection variables, as ansible_ssh_common_args, nor the variables of the runner and the checks playbook
+var (
+	reservedVariablePrefixes = []string{"ansible_", "trento_"}
+	reservedVariables        = []string{
+		clusterSelectedChecks, "cluster_selected_checks_list", clusterID, nodeRole, nodePlatform, provider,
+		"check_item", "checks",
+	}
+)
+
 type Host struct {
 	HostID  uuid.UUID `json:"host_id" binding:"required"`
 	Address string    `json:"address" binding:"required"`
 	User    string    `json:"user" binding:"required"`
+	// Name is used to find the host in the cloud provider inventory
+	Name string `json:"name,omitempty"`
+	// Role of the node in the cluster, the hosts of each role are grouped in the inventory
+	Role string `json:"role,omitempty"`
+	// Connection overrides the execution connection options in this host
+	Connection *ConnectionOptions `json:"connection,omitempty"`
+	// Platform of the host operating system, linux if it is empty
+	Platform string `json:"platform,omitempty"`
+	// checks replaces the execution selected checks in this host, if it is set
+	checks []string
+}
+
+// hostChecks returns the checks to run in the given host
+func (e *ExecutionEvent) hostChecks(host *Host) []string {
+	if host.checks != nil {
+		return host.checks
+	}
+
+	return e.Checks
+}
+
+// isTarget tells if the checks are run in the given host, which is the case of every host without a limit
+func (e *ExecutionEvent) isTarget(host *Host) bool {
+	if len(e.Limit) == 0 {
+		return true
+	}
+
+	for _, hostID := range e.Limit {
+		if hostID == host.HostID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// targetHosts returns the hosts where the checks are run
+func (e *ExecutionEvent) targetHosts() []*Host {
+	hosts := []*Host{}
+	for _, host := range e.Hosts {
+		if e.isTarget(host) {
+			hosts = append(hosts, host)
+		}
+	}
+
+	return hosts
+}
+
+// hasKnownProvider tells if the cluster provider is given, otherwise the playbook detects it in each host
+func (e *ExecutionEvent) hasKnownProvider() bool {
+	return e.Provider != "" && !strings.EqualFold(e.Provider, UnknownProvider)
+}
+
+// runID names the work dir, the cgroup and the dry run folder of the execution playbook, which are not shared
+// by the chunks of a chunked execution, nor by its playbook hooks
+func (e *ExecutionEvent) runID() string {
+	runID := e.ExecutionID.String()
+	if e.chunk != 0 {
+		runID = fmt.Sprintf("%s-chunk-%d", runID, e.chunk)
+	}
+	if e.hook != "" {
+		runID = fmt.Sprintf("%s-hook-%s", runID, e.hook)
+	}
+
+	return runID
+}
+
+// withHook returns a copy of the execution event running the given playbook hook, in its own work dir
+func (e *ExecutionEvent) withHook(hook string) *ExecutionEvent {
+	withHook := *e
+	withHook.hook = hook
+
+	return &withHook
+}
+
+// withLimit returns a copy of the execution event limited to the given target hosts
+func (e *ExecutionEvent) withLimit(hostIDs []string) *ExecutionEvent {
+	limited := *e
+	limited.Limit = []uuid.UUID{}
+	for _, host := range e.targetHosts() {
+		for _, hostID := range hostIDs {
+			if host.HostID.String() == hostID {
+				limited.Limit = append(limited.Limit, host.HostID)
+				break
+			}
+		}
+	}
+
+	return &limited
+}
+
+// validate checks the execution request fields that the binding cannot validate
+func (e *ExecutionEvent) validate() error {
+	if err := e.validateVariables(); err != nil {
+		return err
+	}
+
+	if err := e.validateLimit(); err != nil {
+		return err
+	}
+
+	if err := e.validateTags(); err != nil {
+		return err
+	}
+
+	if err := e.validateRoles(); err != nil {
+		return err
+	}
+
+	if err := e.validatePlatforms(); err != nil {
+		return err
+	}
+
+	if err := e.validateAddresses(); err != nil {
+		return err
+	}
+
+	if e.RollingBatchSize < 0 {
+		return fmt.Errorf("rolling batch size %d cannot be negative", e.RollingBatchSize)
+	}
+
+	switch e.Priority {
+	case "", PriorityLow, PriorityNormal, PriorityHigh:
+	default:
+		return fmt.Errorf("priority %s is not valid, it must be low, normal or high", e.Priority)
+	}
+
+	if err := e.validateRemediateChecks(); err != nil {
+		return err
+	}
+
+	return e.validateConnection()
+}
+
+// priorityRank orders the executions by their priority, the higher ranks run first
+func (e *ExecutionEvent) priorityRank() int {
+	switch e.Priority {
+	case PriorityHigh:
+		return 2
+	case PriorityLow:
+		return 0
+	default:
+		return 1
+	}
+}
+
+// validateLimit checks that the limited hosts are execution hosts
+func (e *ExecutionEvent) validateLimit() error {
+	for _, hostID := range e.Limit {
+		found := false
+		for _, host := range e.Hosts {
+			if host.HostID == hostID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("limit host %s is not an execution host", hostID.String())
+		}
+	}
+
+	return nil
+}
+
+// validateVariables checks that the execution variables do not override the connection variables or the
+// variables of the runner and the checks playbook
+func (e *ExecutionEvent) validateVariables() error {
+	for _, name := range sortedMapKeys(e.Variables) {
+		if isReservedVariable(name) {
+			return fmt.Errorf("variable %s is reserved", name)
+		}
+	}
+
+	return nil
+}
+
+func isReservedVariable(name string) bool {
+	lowerName := strings.ToLower(name)
+	for _, prefix := range reservedVariablePrefixes {
+		if strings.HasPrefix(lowerName, prefix) {
+			return true
+		}
+	}
+	for _, reserved := range reservedVariables {
+		if lowerName == reserved {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateTags checks that the tags are single ansible tags. The always tag cannot be skipped,
+// as the runner tasks of the checks playbook have it
+func (e *ExecutionEvent) validateTags() error {
+	for _, tag := range append(append([]string{}, e.Tags...), e.SkipTags...) {
+		if tag == "" || strings.ContainsAny(tag, ", \t") {
+			return fmt.Errorf("tag %q is not valid", tag)
+		}
+	}
+
+	for _, tag := range e.SkipTags {
+		if tag == ansibleAlwaysTag {
+			return fmt.Errorf("tag %s cannot be skipped", tag)
+		}
+	}
+
+	return nil
+}
+
+// validateRemediateChecks checks that the remediated checks are selected checks of a remediation execution
+func (e *ExecutionEvent) validateRemediateChecks() error {
+	if len(e.RemediateChecks) > 0 && !e.Remediate {
+		return errors.New("remediate checks require remediate")
+	}
+
+	for _, checkID := range e.RemediateChecks {
+		found := false
+		for _, selected := range e.Checks {
+			if selected == checkID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("remediate check %s is not a selected check", checkID)
+		}
+	}
+
+	return nil
+}
+
+// validateRoles checks that the hosts roles are known ones
+func (e *ExecutionEvent) validateRoles() error {
+	for _, host := range e.Hosts {
+		switch host.Role {
+		case "", HanaPrimaryRole, HanaSecondaryRole, MajorityMakerRole:
+		default:
+			return fmt.Errorf("host %s role %s is not valid", host.HostID.String(), host.Role)
+		}
+	}
+
+	return nil
+}
+
+// validatePlatforms checks that the hosts platforms are supported ones
+func (e *ExecutionEvent) validatePlatforms() error {
+	for _, host := range e.Hosts {
+		if host.Platform != "" && !IsValidPlatform(host.Platform) {
+			return fmt.Errorf("host %s platform %s is not supported", host.HostID.String(), host.Platform)
+		}
+	}
+
+	return nil
+}
+
+// validateAddresses checks that the hosts addresses are ip addresses or host names, with an optional port,
+// so the malformed ones are rejected instead of failing the ansible connection
+func (e *ExecutionEvent) validateAddresses() error {
+	for _, host := range e.Hosts {
+		if _, _, err := parseHostAddress(host.Address); err != nil {
+			return fmt.Errorf("host %s %s", host.HostID.String(), err)
+		}
+	}
+
+	return nil
+}
+
+// IsValidPlatform tells if the checks can run in the hosts of the platform
+func IsValidPlatform(platform string) bool {
+	return platform == PlatformLinux || platform == PlatformWindows
+}
+
+// isWindows tells if the host is connected with winrm instead of ssh
+func (h *Host) isWindows() bool {
+	return h.Platform == PlatformWindows
 }
@@ -0,0 +1,72 @@
+package runner
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	eventsSubscriberChannelSize = 99
+)
+
+// LifecycleEvent is an execution event, as sent in the callbacks
+type LifecycleEvent struct {
+	ExecutionID uuid.UUID
+	Event       string
+	Payload     interface{}
+}
+
+// EventsBroadcaster sends the executions lifecycle events to the local subscribers,
+// as the gRPC streams. Slow subscribers lose the events instead of blocking the executions
+type EventsBroadcaster struct {
+	mu          sync.Mutex
+	subscribers map[chan *LifecycleEvent]struct{}
+}
+
+func NewEventsBroadcaster() *EventsBroadcaster {
+	return &EventsBroadcaster{
+		subscribers: make(map[chan *LifecycleEvent]struct{}),
+	}
+}
+
+// Subscribe returns the channel where the new events are received, and the function to stop receiving them
+func (b *EventsBroadcaster) Subscribe() (<-chan *LifecycleEvent, func()) {
+	subscriber := make(chan *LifecycleEvent, eventsSubscriberChannelSize)
+
+	b.mu.Lock()
+	b.subscribers[subscriber] = struct{}{}
+	b.mu.Unlock()
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if _, ok := b.subscribers[subscriber]; ok {
+			delete(b.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+
+	return subscriber, unsubscribe
+}
+
+func (b *EventsBroadcaster) Publish(executionID uuid.UUID, event string, payload interface{}) {
+	lifecycleEvent := &LifecycleEvent{
+		ExecutionID: executionID,
+		Event:       event,
+		Payload:     payload,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for subscriber := range b.subscribers {
+		select {
+		case subscriber <- lifecycleEvent:
+		default:
+			log.Warnf("Events subscriber is full, discarding event %s of execution %s", event, executionID.String())
+		}
+	}
+}
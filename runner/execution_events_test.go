@@ -0,0 +1,48 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventsBroadcaster(t *testing.T) {
+	broadcaster := NewEventsBroadcaster()
+	executionID := uuid.New()
+
+	events1, unsubscribe1 := broadcaster.Subscribe()
+	events2, unsubscribe2 := broadcaster.Subscribe()
+	defer unsubscribe2()
+
+	broadcaster.Publish(executionID, "execution_started", map[string]string{"cluster_id": "cluster"})
+
+	expectedEvent := &LifecycleEvent{
+		ExecutionID: executionID,
+		Event:       "execution_started",
+		Payload:     map[string]string{"cluster_id": "cluster"},
+	}
+	assert.Equal(t, expectedEvent, <-events1)
+	assert.Equal(t, expectedEvent, <-events2)
+
+	unsubscribe1()
+	unsubscribe1()
+	_, ok := <-events1
+	assert.False(t, ok)
+
+	broadcaster.Publish(executionID, "execution_finished", nil)
+	assert.Equal(t, "execution_finished", (<-events2).Event)
+}
+
+func TestEventsBroadcaster_FullSubscriber(t *testing.T) {
+	broadcaster := NewEventsBroadcaster()
+	events, unsubscribe := broadcaster.Subscribe()
+	defer unsubscribe()
+
+	// Publishing must not block when the subscriber does not read the events
+	for i := 0; i < eventsSubscriberChannelSize+1; i++ {
+		broadcaster.Publish(uuid.New(), "check_result", nil)
+	}
+
+	assert.Len(t, events, eventsSubscriberChannelSize)
+}
@@ -0,0 +1,213 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+const (
+	PreExecutionHook  = "pre"
+	PostExecutionHook = "post"
+
+	TrentoClusterID       = "TRENTO_CLUSTER_ID"
+	TrentoHookStage       = "TRENTO_HOOK_STAGE"
+	TrentoHosts           = "TRENTO_HOSTS"
+	TrentoExecutionStatus = "TRENTO_EXECUTION_STATUS"
+	TrentoExecutionError  = "TRENTO_EXECUTION_ERROR"
+)
+
+var ErrExecutionHookFailed = errors.New("The execution hook failed")
+
+// executionHookError keeps the error of the failed hook, so the execution failure tells it apart from the checks failures
+type executionHookError struct {
+	stage string
+	hook  string
+	err   error
+}
+
+func (e *executionHookError) Error() string {
+	return fmt.Sprintf("%s-execution hook %s failed: %s", e.stage, e.hook, e.err)
+}
+
+func (e *executionHookError) Unwrap() error {
+	return e.err
+}
+
+func (e *executionHookError) Is(target error) bool {
+	return target == ErrExecutionHookFailed
+}
+
+// runWithHooks runs the checks of the execution between its pre-execution and post-execution hooks. A failed
+// pre-execution hook only stops the execution if the hooks abort it, the post-execution hooks are always run, even
+// if the checks failed, e.g. to close the firewall window opened before them. They run with the execution context,
+// so they are still run if the checks timed out
+func (c *runnerService) runWithHooks(
+	ctx, runCtx context.Context, e *ExecutionEvent, outputHandler func(stream, line string)) (*ExecutionResults, error) {
+
+	var results *ExecutionResults
+	err := c.runExecutionHooks(runCtx, e, PreExecutionHook, c.config.PreExecutionHooks, nil, outputHandler)
+	if err == nil || !c.config.ExecutionHooksAbort {
+		results, err = c.runChecks(runCtx, e, outputHandler)
+	}
+	c.runExecutionHooks(ctx, e, PostExecutionHook, c.config.PostExecutionHooks, err, outputHandler)
+
+	return results, err
+}
+
+// runExecutionHooks runs the hooks of the stage one after the other. Each failed hook is reported with the
+// execution_hook_failed callback, and the error of the first one is returned. The rest of the hooks are not run
+// once one fails if the hooks abort the execution, as the checks will not run either
+func (c *runnerService) runExecutionHooks(
+	ctx context.Context,
+	e *ExecutionEvent,
+	stage string,
+	hooks []string,
+	executionErr error,
+	outputHandler func(stream, line string),
+) error {
+	if len(hooks) == 0 || c.config.DryRun || e.DryRun {
+		return nil
+	}
+
+	logger := loggerFromContext(ctx)
+	envs := hookEnvs(e, stage, executionErr)
+	var failed error
+	for index, hook := range hooks {
+		logger.Infof("Running the %s-execution hook %s of execution %s", stage, hook, e.ExecutionID.String())
+
+		err := c.runExecutionHook(ctx, e, fmt.Sprintf("%s-%d", stage, index+1), hook, envs, outputHandler)
+		if err == nil {
+			continue
+		}
+
+		hookErr := &executionHookError{stage: stage, hook: hook, err: err}
+		logger.Errorf("Error running the execution hooks: %s", hookErr)
+		c.dispatchCallback(e, executionHookFailedEvent, map[string]string{
+			"cluster_id": e.ClusterID.String(),
+			"stage":      stage,
+			"hook":       hook,
+			"reason":     err.Error(),
+		})
+
+		if failed == nil {
+			failed = hookErr
+		}
+		if stage == PreExecutionHook && c.config.ExecutionHooksAbort {
+			break
+		}
+	}
+
+	return failed
+}
+
+// runExecutionHook runs the hook, stopping it once the hooks timeout is reached, as the post-execution hooks
+// run with the execution context, without the timeout of the checks
+func (c *runnerService) runExecutionHook(
+	ctx context.Context,
+	e *ExecutionEvent,
+	hookID string,
+	hook string,
+	envs map[string]string,
+	outputHandler func(stream, line string),
+) error {
+	if c.config.ExecutionHooksTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config.ExecutionHooksTimeout)
+		defer cancel()
+	}
+
+	var err error
+	if isPlaybookHook(hook) {
+		err = c.runPlaybookHook(ctx, e.withHook(hookID), hook, envs, outputHandler)
+	} else {
+		err = runCommandHook(ctx, hook, envs, outputHandler)
+	}
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s: %w", c.config.ExecutionHooksTimeout, err)
+	}
+
+	return err
+}
+
+// isPlaybookHook tells if the hook is an ansible playbook, run in the execution hosts, instead of a command run
+// in the runner host
+func isPlaybookHook(hook string) bool {
+	extension := path.Ext(hook)
+	return extension == ".yml" || extension == ".yaml"
+}
+
+// hookEnvs returns the environment of the hooks, with the execution they run for, and its outcome for the
+// post-execution hooks
+func hookEnvs(e *ExecutionEvent, stage string, executionErr error) map[string]string {
+	addresses := []string{}
+	for _, host := range e.targetHosts() {
+		addresses = append(addresses, host.Address)
+	}
+
+	envs := map[string]string{
+		TrentoExecutionID: e.ExecutionID.String(),
+		TrentoClusterID:   e.ClusterID.String(),
+		TrentoHookStage:   stage,
+		TrentoHosts:       strings.Join(addresses, ","),
+	}
+	if stage == PostExecutionHook {
+		envs[TrentoExecutionStatus] = ExecutionCompleted
+		if executionErr != nil {
+			envs[TrentoExecutionStatus] = ExecutionFailed
+			envs[TrentoExecutionError] = executionErr.Error()
+		}
+	}
+
+	return envs
+}
+
+// runCommandHook runs the hook command with the shell in the runner host
+func runCommandHook(ctx context.Context, hook string, envs map[string]string, outputHandler func(stream, line string)) error {
+	cmd := customExecCommand("sh", "-c", hook)
+	cmd.Env = os.Environ()
+	for name, value := range envs {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", name, value))
+	}
+
+	_, err := runCommand(ctx, cmd, nil, loggerFromContext(ctx).Infof, outputHandler)
+
+	return err
+}
+
+// runPlaybookHook runs the hook playbook in the target hosts of the execution, with the inventory and the connection
+// settings of the checks, out of the ansible check mode. The hook has its own work dir, named after the hook
+func (c *runnerService) runPlaybookHook(
+	ctx context.Context, e *ExecutionEvent, hook string, envs map[string]string, outputHandler func(stream, line string)) (err error) {
+
+	hookRunner, err := NewAnsibleCheckRunner(c.config, e)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// The work dir of the hook of a failed execution is kept as well, next to the checks one
+		failed := err != nil || envs[TrentoExecutionStatus] == ExecutionFailed
+		if releaseErr := releaseWorkDir(ctx, c.config, path.Dir(hookRunner.Inventory), failed); releaseErr != nil {
+			loggerFromContext(ctx).Errorf("Error removing the execution work dir: %s", releaseErr)
+		}
+	}()
+
+	if err := hookRunner.SetPlaybook(hook); err != nil {
+		return err
+	}
+	hookRunner.Check = false
+	hookRunner.ExtraVarsFile = ""
+	// The hook does not print checks results
+	for _, name := range []string{TrentoPartialResults, AnsibleStdoutCallbackEnv} {
+		delete(hookRunner.Envs, name)
+	}
+	for name, value := range envs {
+		hookRunner.setEnv(name, value)
+	}
+	hookRunner.OutputHandler = outputHandler
+
+	return hookRunner.RunPlaybookContext(ctx)
+}
@@ -0,0 +1,171 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func hooksTestService(t *testing.T, tmpDir string, config *Config) *runnerService {
+	// The hooks commands are run with the shell, other tests leave the command mocked
+	customExecCommand = exec.Command
+	config.AnsibleFolder = tmpDir
+	runnerService, err := NewRunnerService(config)
+	assert.NoError(t, err)
+
+	return runnerService
+}
+
+func TestRunWithHooks(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	hooksLog := path.Join(tmpDir, "hooks.log")
+	runnerService := hooksTestService(t, tmpDir, &Config{
+		PreExecutionHooks:  []string{"echo pre $TRENTO_EXECUTION_ID $TRENTO_HOSTS >> " + hooksLog},
+		PostExecutionHooks: []string{"echo post $TRENTO_EXECUTION_STATUS >> " + hooksLog},
+	})
+	runnerService.checkEngine = checkEngineFunc(func(e *ExecutionEvent) (*ExecutionResults, error) {
+		content, _ := ioutil.ReadFile(hooksLog)
+		assert.Contains(t, string(content), "pre "+e.ExecutionID.String()+" 192.168.10.1,192.168.10.2")
+		return &ExecutionResults{ClusterID: e.ClusterID.String(), Hosts: []*HostResults{}}, nil
+	})
+
+	e := fencesTestExecution(uuid.New(), uuid.New())
+	e.Hosts[1].Address = "192.168.10.2"
+	_, err := runnerService.runWithHooks(context.Background(), context.Background(), e, func(stream, line string) {})
+	assert.NoError(t, err)
+
+	content, err := ioutil.ReadFile(hooksLog)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	assert.Len(t, lines, 2)
+	assert.Equal(t, "post completed", lines[1])
+}
+
+func TestRunWithHooks_PreHookFailed(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	hooksLog := path.Join(tmpDir, "hooks.log")
+	runnerService := hooksTestService(t, tmpDir, &Config{
+		PreExecutionHooks:  []string{"exit 3", "echo second pre >> " + hooksLog},
+		PostExecutionHooks: []string{"echo post $TRENTO_EXECUTION_STATUS >> " + hooksLog},
+	})
+	checksRun := false
+	runnerService.checkEngine = checkEngineFunc(func(e *ExecutionEvent) (*ExecutionResults, error) {
+		checksRun = true
+		return &ExecutionResults{ClusterID: e.ClusterID.String(), Hosts: []*HostResults{}}, nil
+	})
+
+	e := fencesTestExecution(uuid.New())
+	// The failed hook is reported, but the execution goes on
+	_, err := runnerService.runWithHooks(context.Background(), context.Background(), e, func(stream, line string) {})
+	assert.NoError(t, err)
+	assert.True(t, checksRun)
+
+	request := <-runnerService.callbacksDispatcher.queue
+	assert.Equal(t, executionHookFailedEvent, request.event)
+	assert.Equal(t, map[string]string{
+		"cluster_id": e.ClusterID.String(),
+		"stage":      PreExecutionHook,
+		"hook":       "exit 3",
+		"reason":     "exit status 3",
+	}, request.payload)
+
+	content, _ := ioutil.ReadFile(hooksLog)
+	assert.Equal(t, "second pre\npost completed\n", string(content))
+}
+
+func TestRunWithHooks_Abort(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	hooksLog := path.Join(tmpDir, "hooks.log")
+	runnerService := hooksTestService(t, tmpDir, &Config{
+		PreExecutionHooks:   []string{"exit 3", "echo second pre >> " + hooksLog},
+		PostExecutionHooks:  []string{"echo post $TRENTO_EXECUTION_STATUS: $TRENTO_EXECUTION_ERROR >> " + hooksLog},
+		ExecutionHooksAbort: true,
+	})
+	runnerService.checkEngine = checkEngineFunc(func(e *ExecutionEvent) (*ExecutionResults, error) {
+		t.Fatal("the checks must not run once a pre-execution hook failed")
+		return nil, nil
+	})
+
+	// The execution fails without running the rest of the hooks nor the checks, but the post-execution hooks are run
+	_, err := runnerService.runWithHooks(
+		context.Background(), context.Background(), fencesTestExecution(uuid.New()), func(stream, line string) {})
+	assert.True(t, errors.Is(err, ErrExecutionHookFailed))
+	assert.EqualError(t, err, "pre-execution hook exit 3 failed: exit status 3")
+
+	content, _ := ioutil.ReadFile(hooksLog)
+	assert.Equal(t, "post failed: pre-execution hook exit 3 failed: exit status 3\n", string(content))
+}
+
+func TestRunWithHooks_DryRun(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	runnerService := hooksTestService(t, tmpDir, &Config{PreExecutionHooks: []string{"exit 1"}, ExecutionHooksAbort: true})
+	runnerService.checkEngine = checkEngineFunc(func(e *ExecutionEvent) (*ExecutionResults, error) {
+		return nil, nil
+	})
+
+	e := fencesTestExecution(uuid.New())
+	e.DryRun = true
+	_, err := runnerService.runWithHooks(context.Background(), context.Background(), e, func(stream, line string) {})
+	assert.NoError(t, err)
+}
+
+func TestRunWithHooks_Timeout(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	hooksLog := path.Join(tmpDir, "hooks.log")
+	runnerService := hooksTestService(t, tmpDir, &Config{
+		PostExecutionHooks:    []string{"sleep 10", "echo second post >> " + hooksLog},
+		ExecutionHooksTimeout: 100 * time.Millisecond,
+	})
+	runnerService.checkEngine = checkEngineFunc(func(e *ExecutionEvent) (*ExecutionResults, error) {
+		return &ExecutionResults{ClusterID: e.ClusterID.String(), Hosts: []*HostResults{}}, nil
+	})
+
+	// The hung post-execution hook is stopped, even if the execution context has no timeout
+	start := time.Now()
+	_, err := runnerService.runWithHooks(
+		context.Background(), context.Background(), fencesTestExecution(uuid.New()), func(stream, line string) {})
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start).Seconds(), 5.0)
+
+	request := <-runnerService.callbacksDispatcher.queue
+	assert.Equal(t, executionHookFailedEvent, request.event)
+	assert.Contains(t, request.payload.(map[string]string)["reason"], "timed out after 100ms")
+
+	content, _ := ioutil.ReadFile(hooksLog)
+	assert.Equal(t, "second post\n", string(content))
+}
+
+func TestRunID_Hook(t *testing.T) {
+	e := fencesTestExecution(uuid.New())
+	assert.Equal(t, e.ExecutionID.String()+"-hook-pre-1", e.withHook("pre-1").runID())
+
+	e.chunk = 2
+	assert.Equal(t, e.ExecutionID.String()+"-chunk-2-hook-post-1", e.withHook("post-1").runID())
+	assert.True(t, isExecutionWorkDir(e.withHook("post-1").runID()))
+	assert.Equal(t, "", e.hook)
+}
+
+func TestIsPlaybookHook(t *testing.T) {
+	assert.True(t, isPlaybookHook("/etc/trento/hooks/snapshot.yml"))
+	assert.True(t, isPlaybookHook("snapshot.yaml"))
+	assert.False(t, isPlaybookHook("/usr/local/bin/notify-cmdb --cluster $TRENTO_CLUSTER_ID"))
+}
@@ -0,0 +1,261 @@
+package runner
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultExecutionLogsMaxSize int64 = 10 * 1024 * 1024
+
+	executionLogFileExtension = ".log"
+)
+
+var ErrExecutionLogFilesDisabled = errors.New("The execution log files are disabled")
+var ErrExecutionLogFilesNotFound = errors.New("The execution log files are not found")
+
+type executionLogFile struct {
+	file      *os.File
+	size      int64
+	rotations int
+}
+
+// ExecutionLogFiles writes the full ansible output of each execution in its own file in the logs folder, for the
+// support cases. A log file bigger than the max size is rotated, compressed with gzip, and the log files older than
+// the max age are removed when a new execution starts. They are kept forever if the max age is 0
+type ExecutionLogFiles struct {
+	folder  string
+	maxSize int64
+	maxAge  time.Duration
+	mu      sync.Mutex
+	files   map[uuid.UUID]*executionLogFile
+}
+
+func NewExecutionLogFiles(folder string, maxSize int64, maxAge time.Duration) *ExecutionLogFiles {
+	if maxSize <= 0 {
+		maxSize = DefaultExecutionLogsMaxSize
+	}
+
+	return &ExecutionLogFiles{
+		folder:  folder,
+		maxSize: maxSize,
+		maxAge:  maxAge,
+		files:   make(map[uuid.UUID]*executionLogFile),
+	}
+}
+
+func (l *ExecutionLogFiles) logPath(executionID uuid.UUID) string {
+	return path.Join(l.folder, executionID.String()+executionLogFileExtension)
+}
+
+// Start opens the log file of the execution, appending to it if the execution was run before, e.g. when it was preempted
+func (l *ExecutionLogFiles) Start(executionID uuid.UUID) error {
+	if err := os.MkdirAll(l.folder, 0700); err != nil {
+		return err
+	}
+	l.prune()
+
+	file, err := os.OpenFile(l.logPath(executionID), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.files[executionID] = &executionLogFile{
+		file:      file,
+		size:      info.Size(),
+		rotations: len(l.rotatedPaths(executionID)),
+	}
+
+	return nil
+}
+
+// Write appends the line to the log file of the execution, rotating it if it reaches the max size
+func (l *ExecutionLogFiles) Write(executionID uuid.UUID, stream, line string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.files[executionID]
+	if !ok {
+		return nil
+	}
+
+	n, err := fmt.Fprintf(entry.file, "%s %s %s\n", time.Now().UTC().Format(time.RFC3339Nano), stream, line)
+	entry.size += int64(n)
+	if err != nil {
+		return err
+	}
+
+	if entry.size >= l.maxSize {
+		return l.rotate(executionID, entry)
+	}
+
+	return nil
+}
+
+// Finish closes the log file of the execution
+func (l *ExecutionLogFiles) Finish(executionID uuid.UUID) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.files[executionID]
+	if !ok {
+		return nil
+	}
+	delete(l.files, executionID)
+
+	return entry.file.Close()
+}
+
+// rotate compresses the current log file of the execution as the next rotated one, and opens a new empty file
+func (l *ExecutionLogFiles) rotate(executionID uuid.UUID, entry *executionLogFile) error {
+	if err := entry.file.Close(); err != nil {
+		return err
+	}
+
+	entry.rotations++
+	rotatedPath := fmt.Sprintf("%s.%d.gz", l.logPath(executionID), entry.rotations)
+	if err := compressFile(l.logPath(executionID), rotatedPath); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(l.logPath(executionID), os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		delete(l.files, executionID)
+		return err
+	}
+	entry.file = file
+	entry.size = 0
+
+	return nil
+}
+
+func compressFile(source, destination string) error {
+	content, err := ioutil.ReadFile(source)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(destination, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := gzip.NewWriter(file)
+	if _, err := writer.Write(content); err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+// rotatedPaths returns the compressed log files of the execution, from the oldest to the newest
+func (l *ExecutionLogFiles) rotatedPaths(executionID uuid.UUID) []string {
+	matches, _ := filepath.Glob(l.logPath(executionID) + ".*.gz")
+	sort.Slice(matches, func(i, j int) bool {
+		return len(matches[i]) < len(matches[j]) || (len(matches[i]) == len(matches[j]) && matches[i] < matches[j])
+	})
+
+	return matches
+}
+
+// prune removes the log files older than the max age
+func (l *ExecutionLogFiles) prune() {
+	if l.maxAge <= 0 {
+		return
+	}
+
+	entries, err := ioutil.ReadDir(l.folder)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.Contains(entry.Name(), executionLogFileExtension) {
+			continue
+		}
+		if time.Since(entry.ModTime()) < l.maxAge {
+			continue
+		}
+
+		if err := os.Remove(path.Join(l.folder, entry.Name())); err != nil {
+			log.Warnf("Error removing the expired execution log file %s: %s", entry.Name(), err)
+			continue
+		}
+		log.Debugf("Expired execution log file %s removed", entry.Name())
+	}
+}
+
+// WriteBundle writes the log files of the execution in the writer, as a tar.gz archive
+func (l *ExecutionLogFiles) WriteBundle(executionID uuid.UUID, w io.Writer) error {
+	// The running execution does not write in its files nor rotate them while they are archived
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	paths := l.rotatedPaths(executionID)
+	if _, err := os.Stat(l.logPath(executionID)); err == nil {
+		paths = append(paths, l.logPath(executionID))
+	}
+	if len(paths) == 0 {
+		return ErrExecutionLogFilesNotFound
+	}
+
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, logPath := range paths {
+		if err := addBundleFile(tarWriter, logPath); err != nil {
+			return err
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+
+	return gzipWriter.Close()
+}
+
+func addBundleFile(tarWriter *tar.Writer, logPath string) error {
+	content, err := ioutil.ReadFile(logPath)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(logPath)
+	if err != nil {
+		return err
+	}
+
+	if err := tarWriter.WriteHeader(&tar.Header{
+		Name:    path.Base(logPath),
+		Mode:    0600,
+		Size:    int64(len(content)),
+		ModTime: info.ModTime(),
+	}); err != nil {
+		return err
+	}
+	_, err = tarWriter.Write(content)
+
+	return err
+}
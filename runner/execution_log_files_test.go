@@ -0,0 +1,126 @@
+package runner
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// readBundle returns the content of each file of the tar.gz bundle by name
+func readBundle(t *testing.T, bundle []byte) map[string]string {
+	gzipReader, err := gzip.NewReader(bytes.NewReader(bundle))
+	assert.NoError(t, err)
+	tarReader := tar.NewReader(gzipReader)
+
+	files := map[string]string{}
+	for {
+		header, err := tarReader.Next()
+		if err != nil {
+			break
+		}
+		content, _ := ioutil.ReadAll(tarReader)
+		files[header.Name] = string(content)
+	}
+
+	return files
+}
+
+func TestExecutionLogFiles(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	files := NewExecutionLogFiles(path.Join(tmpDir, "logs"), 0, 0)
+	executionID := uuid.New()
+
+	assert.NoError(t, files.Start(executionID))
+	assert.NoError(t, files.Write(executionID, StdoutStream, "PLAY [all]"))
+	assert.NoError(t, files.Write(executionID, StderrStream, "[WARNING]: some warning"))
+	assert.NoError(t, files.Finish(executionID))
+
+	// The lines of the finished executions are not written
+	assert.NoError(t, files.Write(executionID, StdoutStream, "PLAY RECAP"))
+
+	content, err := ioutil.ReadFile(path.Join(tmpDir, "logs", executionID.String()+".log"))
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	assert.Len(t, lines, 2)
+	assert.Regexp(t, `^\S+ stdout PLAY \[all\]$`, lines[0])
+	assert.Regexp(t, `^\S+ stderr \[WARNING\]: some warning$`, lines[1])
+}
+
+func TestExecutionLogFiles_Rotation(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	files := NewExecutionLogFiles(tmpDir, 100, 0)
+	executionID := uuid.New()
+
+	assert.NoError(t, files.Start(executionID))
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, files.Write(executionID, StdoutStream, strings.Repeat("x", 60)))
+	}
+	assert.NoError(t, files.Finish(executionID))
+
+	// Each two lines reach the max size, and they are rotated and compressed
+	logFile := path.Join(tmpDir, executionID.String()+".log")
+	assert.Equal(t, []string{logFile + ".1.gz", logFile + ".2.gz"}, files.rotatedPaths(executionID))
+
+	var bundle bytes.Buffer
+	assert.NoError(t, files.WriteBundle(executionID, &bundle))
+	bundleFiles := readBundle(t, bundle.Bytes())
+	assert.Len(t, bundleFiles, 3)
+	assert.Equal(t, 1, strings.Count(bundleFiles[executionID.String()+".log"], "\n"))
+
+	gzipReader, err := gzip.NewReader(strings.NewReader(bundleFiles[executionID.String()+".log.1.gz"]))
+	assert.NoError(t, err)
+	rotated, _ := ioutil.ReadAll(gzipReader)
+	assert.Equal(t, 2, strings.Count(string(rotated), "\n"))
+
+	assert.Equal(t, ErrExecutionLogFilesNotFound, files.WriteBundle(uuid.New(), &bundle))
+}
+
+func TestExecutionLogFiles_Prune(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	files := NewExecutionLogFiles(tmpDir, 0, time.Hour)
+	expiredID := uuid.New()
+	assert.NoError(t, files.Start(expiredID))
+	assert.NoError(t, files.Finish(expiredID))
+	expiredFile := path.Join(tmpDir, expiredID.String()+".log")
+	os.Chtimes(expiredFile, time.Now().Add(-2*time.Hour), time.Now().Add(-2*time.Hour))
+
+	recentID := uuid.New()
+	assert.NoError(t, files.Start(recentID))
+	assert.NoError(t, files.Finish(recentID))
+
+	assert.NoFileExists(t, expiredFile)
+	assert.FileExists(t, path.Join(tmpDir, recentID.String()+".log"))
+}
+
+func TestExecutionLogs_Files(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	executionLogs := NewExecutionLogs()
+	executionID := uuid.New()
+	assert.Equal(t, ErrExecutionLogFilesDisabled, executionLogs.WriteBundle(executionID, &bytes.Buffer{}))
+
+	executionLogs.SetFiles(NewExecutionLogFiles(tmpDir, 0, 0))
+	executionLogs.Start(executionID)
+	executionLogs.Write(executionID, StdoutStream, "PLAY [all]")
+	executionLogs.Finish(executionID)
+
+	var bundle bytes.Buffer
+	assert.NoError(t, executionLogs.WriteBundle(executionID, &bundle))
+	assert.Contains(t, readBundle(t, bundle.Bytes())[executionID.String()+".log"], "stdout PLAY [all]")
+}
@@ -0,0 +1,174 @@
+package runner
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	StdoutStream = "stdout"
+	StderrStream = "stderr"
+
+	logsSubscriberChannelSize = 999
+)
+
+// Logs of the finished executions are kept for some time, so they can still be read
+var executionLogsRetention = time.Minute * 10
+
+// Only the last lines of each execution are kept
+var maxExecutionLogLines = 10000
+
+type LogLine struct {
+	Stream string    `json:"stream"`
+	Line   string    `json:"line"`
+	Time   time.Time `json:"time"`
+}
+
+type executionLog struct {
+	lines       []*LogLine
+	subscribers map[chan *LogLine]struct{}
+	finishedAt  *time.Time
+}
+
+// ExecutionLogs stores the ansible output of the running executions, and sends it live to the subscribers
+type ExecutionLogs struct {
+	mu         sync.Mutex
+	executions map[uuid.UUID]*executionLog
+	files      *ExecutionLogFiles
+}
+
+func NewExecutionLogs() *ExecutionLogs {
+	return &ExecutionLogs{
+		executions: make(map[uuid.UUID]*executionLog),
+	}
+}
+
+// SetFiles writes the full logs of each execution in the log files as well.
+// The logs are only kept in memory if the files are nil
+func (l *ExecutionLogs) SetFiles(files *ExecutionLogFiles) {
+	l.files = files
+}
+
+// Start begins storing the logs of an execution, removing the expired logs of the finished ones
+func (l *ExecutionLogs) Start(executionID uuid.UUID) {
+	if l.files != nil {
+		if err := l.files.Start(executionID); err != nil {
+			log.Errorf("Error creating the log file of execution %s: %s", executionID.String(), err)
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for id, entry := range l.executions {
+		if entry.finishedAt != nil && time.Since(*entry.finishedAt) > executionLogsRetention {
+			delete(l.executions, id)
+		}
+	}
+
+	l.executions[executionID] = &executionLog{
+		lines:       []*LogLine{},
+		subscribers: make(map[chan *LogLine]struct{}),
+	}
+}
+
+func (l *ExecutionLogs) Write(executionID uuid.UUID, stream, line string) {
+	if l.files != nil {
+		if err := l.files.Write(executionID, stream, line); err != nil {
+			log.Errorf("Error writing the log file of execution %s: %s", executionID.String(), err)
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.executions[executionID]
+	if !ok || entry.finishedAt != nil {
+		return
+	}
+
+	logLine := &LogLine{Stream: stream, Line: line, Time: time.Now().UTC()}
+	entry.lines = append(entry.lines, logLine)
+	if len(entry.lines) > maxExecutionLogLines {
+		entry.lines = entry.lines[len(entry.lines)-maxExecutionLogLines:]
+	}
+
+	for subscriber := range entry.subscribers {
+		// Slow subscribers lose lines instead of blocking the playbook output
+		select {
+		case subscriber <- logLine:
+		default:
+		}
+	}
+}
+
+// Finish marks the execution as finished, closing the subscribers channels
+func (l *ExecutionLogs) Finish(executionID uuid.UUID) {
+	if l.files != nil {
+		if err := l.files.Finish(executionID); err != nil {
+			log.Errorf("Error closing the log file of execution %s: %s", executionID.String(), err)
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.executions[executionID]
+	if !ok || entry.finishedAt != nil {
+		return
+	}
+
+	finishedAt := time.Now()
+	entry.finishedAt = &finishedAt
+	for subscriber := range entry.subscribers {
+		close(subscriber)
+	}
+	entry.subscribers = nil
+}
+
+// Subscribe returns the execution lines logged so far and the channel where the next ones are received.
+// The channel is closed once the execution finishes. It returns false if the execution logs are not available
+func (l *ExecutionLogs) Subscribe(executionID uuid.UUID) ([]*LogLine, <-chan *LogLine, func(), bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.executions[executionID]
+	if !ok {
+		return nil, nil, nil, false
+	}
+
+	history := make([]*LogLine, len(entry.lines))
+	copy(history, entry.lines)
+
+	subscriber := make(chan *LogLine, logsSubscriberChannelSize)
+	if entry.finishedAt != nil {
+		close(subscriber)
+		return history, subscriber, func() {}, true
+	}
+	entry.subscribers[subscriber] = struct{}{}
+
+	unsubscribe := func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if _, ok := entry.subscribers[subscriber]; ok {
+			delete(entry.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+
+	return history, subscriber, unsubscribe, true
+}
+
+// WriteBundle writes the log files of the execution in the writer, as a tar.gz archive
+func (l *ExecutionLogs) WriteBundle(executionID uuid.UUID, w io.Writer) error {
+	if l.files == nil {
+		return ErrExecutionLogFilesDisabled
+	}
+
+	return l.files.WriteBundle(executionID, w)
+}
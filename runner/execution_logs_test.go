@@ -0,0 +1,75 @@
+package runner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecutionLogs(t *testing.T) {
+	logs := NewExecutionLogs()
+	executionID := uuid.New()
+
+	_, _, _, ok := logs.Subscribe(executionID)
+	assert.False(t, ok)
+
+	logs.Start(executionID)
+	logs.Write(executionID, StdoutStream, "PLAY [all]")
+
+	history, lines, unsubscribe, ok := logs.Subscribe(executionID)
+	defer unsubscribe()
+	assert.True(t, ok)
+	assert.Len(t, history, 1)
+	assert.Equal(t, "PLAY [all]", history[0].Line)
+	assert.Equal(t, StdoutStream, history[0].Stream)
+
+	logs.Write(executionID, StderrStream, "[WARNING]: some warning")
+	line := <-lines
+	assert.Equal(t, "[WARNING]: some warning", line.Line)
+	assert.Equal(t, StderrStream, line.Stream)
+
+	logs.Finish(executionID)
+	_, ok = <-lines
+	assert.False(t, ok)
+
+	// The finished executions logs are still available, but no new lines are added
+	logs.Write(executionID, StdoutStream, "late line")
+	history, lines, _, ok = logs.Subscribe(executionID)
+	assert.True(t, ok)
+	assert.Len(t, history, 2)
+	_, ok = <-lines
+	assert.False(t, ok)
+}
+
+func TestExecutionLogs_Limits(t *testing.T) {
+	defaultMaxLines := maxExecutionLogLines
+	defaultRetention := executionLogsRetention
+	defer func() {
+		maxExecutionLogLines = defaultMaxLines
+		executionLogsRetention = defaultRetention
+	}()
+	maxExecutionLogLines = 2
+	executionLogsRetention = 0
+
+	logs := NewExecutionLogs()
+	executionID := uuid.New()
+	logs.Start(executionID)
+	logs.Write(executionID, StdoutStream, "line 1")
+	logs.Write(executionID, StdoutStream, "line 2")
+	logs.Write(executionID, StdoutStream, "line 3")
+
+	history, _, unsubscribe, _ := logs.Subscribe(executionID)
+	unsubscribe()
+	assert.Len(t, history, 2)
+	assert.Equal(t, "line 2", history[0].Line)
+
+	// The expired logs are removed when a new execution starts
+	logs.Finish(executionID)
+	time.Sleep(time.Millisecond)
+	logs.Start(uuid.New())
+
+	_, _, _, ok := logs.Subscribe(executionID)
+	assert.False(t, ok)
+}
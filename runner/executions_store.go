@@ -0,0 +1,203 @@
+package runner
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	ExecutionQueued    = "queued"
+	ExecutionRunning   = "running"
+	ExecutionCompleted = "completed"
+	ExecutionFailed    = "failed"
+	ExecutionTimedOut  = "timed_out"
+	// A preempted execution is waiting to run again after the execution that preempted it
+	ExecutionPreempted = "preempted"
+
+	executionsBucket      = "executions"
+	executionsIndexBucket = "executions_index"
+	storeOpenTimeout      = time.Second * 5
+
+	errExecutionInterrupted = "the runner stopped while the execution was running"
+)
+
+// ExecutionRecord stores the metadata of an execution
+type ExecutionRecord struct {
+	ExecutionID uuid.UUID      `json:"execution_id"`
+	ClusterID   uuid.UUID      `json:"cluster_id"`
+	Upstream    string         `json:"upstream,omitempty"`
+	Status      string         `json:"status"`
+	StartedAt   time.Time      `json:"started_at"`
+	FinishedAt  *time.Time     `json:"finished_at,omitempty"`
+	ReturnCode  int            `json:"return_code"`
+	Summary     map[string]int `json:"summary,omitempty"`
+	Error       string         `json:"error,omitempty"`
+	// Outputs are the stdout and stderr of the checks tasks, if they are captured
+	Outputs []*TaskOutput `json:"outputs,omitempty"`
+	// CheckDurations are how long each check took in each host, if the checks run with ansible
+	CheckDurations []*CheckDuration `json:"check_durations,omitempty"`
+}
+
+type ExecutionsStore interface {
+	Save(record *ExecutionRecord) error
+	Get(executionID uuid.UUID) (*ExecutionRecord, error)
+	// List returns the records sorted by start time, the most recent first, and the total number of records
+	List(offset, limit int) ([]*ExecutionRecord, int, error)
+	Close() error
+}
+
+type boltExecutionsStore struct {
+	db *bolt.DB
+}
+
+// NewExecutionsStore opens, or creates, the executions database in the given file
+func NewExecutionsStore(dbFile string) (*boltExecutionsStore, error) {
+	if err := os.MkdirAll(path.Dir(dbFile), 0755); err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(dbFile, 0600, &bolt.Options{Timeout: storeOpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("cannot open the executions database %s: %s", dbFile, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range []string{executionsBucket, executionsIndexBucket} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(bucket)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &boltExecutionsStore{db: db}, nil
+}
+
+// indexKey sorts the executions by the start time, using the execution ID to avoid collisions
+func indexKey(record *ExecutionRecord) []byte {
+	key := make([]byte, 8, 8+len(record.ExecutionID))
+	binary.BigEndian.PutUint64(key, uint64(record.StartedAt.UnixNano()))
+	return append(key, record.ExecutionID[:]...)
+}
+
+func (s *boltExecutionsStore) Save(record *ExecutionRecord) error {
+	content, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		executions := tx.Bucket([]byte(executionsBucket))
+		index := tx.Bucket([]byte(executionsIndexBucket))
+
+		// The start time might change if the same execution is saved again
+		if previous := executions.Get(record.ExecutionID[:]); previous != nil {
+			var previousRecord *ExecutionRecord
+			if err := json.Unmarshal(previous, &previousRecord); err == nil {
+				index.Delete(indexKey(previousRecord))
+			}
+		}
+
+		if err := executions.Put(record.ExecutionID[:], content); err != nil {
+			return err
+		}
+
+		return index.Put(indexKey(record), record.ExecutionID[:])
+	})
+}
+
+func (s *boltExecutionsStore) Get(executionID uuid.UUID) (*ExecutionRecord, error) {
+	var record *ExecutionRecord
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		content := tx.Bucket([]byte(executionsBucket)).Get(executionID[:])
+		if content == nil {
+			return nil
+		}
+		return json.Unmarshal(content, &record)
+	})
+
+	return record, err
+}
+
+func (s *boltExecutionsStore) List(offset, limit int) ([]*ExecutionRecord, int, error) {
+	records := []*ExecutionRecord{}
+	total := 0
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		executions := tx.Bucket([]byte(executionsBucket))
+		index := tx.Bucket([]byte(executionsIndexBucket))
+		total = index.Stats().KeyN
+
+		cursor := index.Cursor()
+		position := 0
+		for key, executionID := cursor.Last(); key != nil && len(records) < limit; key, executionID = cursor.Prev() {
+			if position < offset {
+				position++
+				continue
+			}
+			position++
+
+			var record *ExecutionRecord
+			if err := json.Unmarshal(executions.Get(executionID), &record); err != nil {
+				return err
+			}
+			records = append(records, record)
+		}
+
+		return nil
+	})
+
+	return records, total, err
+}
+
+// FailInterrupted marks the executions left running, or preempted, by a previous runner process as failed,
+// as they are not run anymore. It returns the number of updated records
+func (s *boltExecutionsStore) FailInterrupted() (int, error) {
+	interrupted := 0
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		executions := tx.Bucket([]byte(executionsBucket))
+		finishedAt := time.Now().UTC()
+
+		return executions.ForEach(func(executionID, content []byte) error {
+			var record *ExecutionRecord
+			if err := json.Unmarshal(content, &record); err != nil {
+				return err
+			}
+			if record.Status != ExecutionRunning && record.Status != ExecutionPreempted {
+				return nil
+			}
+
+			record.Status = ExecutionFailed
+			record.FinishedAt = &finishedAt
+			record.Error = errExecutionInterrupted
+			record.ReturnCode = -1
+			updated, err := json.Marshal(record)
+			if err != nil {
+				return err
+			}
+			interrupted++
+
+			// The index does not change, as the start time is the same
+			return executions.Put(executionID, updated)
+		})
+	})
+
+	return interrupted, err
+}
+
+func (s *boltExecutionsStore) Close() error {
+	return s.db.Close()
+}
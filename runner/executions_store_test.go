@@ -0,0 +1,138 @@
+package runner
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+)
+
+type ExecutionsStoreTestSuite struct {
+	suite.Suite
+	tmpDir string
+	store  *boltExecutionsStore
+}
+
+func TestExecutionsStoreTestSuite(t *testing.T) {
+	suite.Run(t, new(ExecutionsStoreTestSuite))
+}
+
+func (suite *ExecutionsStoreTestSuite) SetupTest() {
+	suite.tmpDir, _ = ioutil.TempDir(os.TempDir(), "trentotest")
+	store, err := NewExecutionsStore(path.Join(suite.tmpDir, "db", "executions.db"))
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	suite.store = store
+}
+
+func (suite *ExecutionsStoreTestSuite) TearDownTest() {
+	suite.store.Close()
+	os.RemoveAll(suite.tmpDir)
+}
+
+func newTestExecutionRecord(startedAt time.Time) *ExecutionRecord {
+	return &ExecutionRecord{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Status:      ExecutionRunning,
+		StartedAt:   startedAt,
+	}
+}
+
+func (suite *ExecutionsStoreTestSuite) Test_SaveAndGet() {
+	record := newTestExecutionRecord(time.Date(2022, 5, 10, 10, 0, 0, 0, time.UTC))
+
+	err := suite.store.Save(record)
+	suite.NoError(err)
+
+	finishedAt := record.StartedAt.Add(time.Minute)
+	record.Status = ExecutionCompleted
+	record.FinishedAt = &finishedAt
+	record.Summary = map[string]int{"passing": 2, "critical": 1}
+
+	err = suite.store.Save(record)
+	suite.NoError(err)
+
+	stored, err := suite.store.Get(record.ExecutionID)
+	suite.NoError(err)
+	suite.Equal(record, stored)
+
+	_, total, _ := suite.store.List(0, 10)
+	suite.Equal(1, total)
+}
+
+func (suite *ExecutionsStoreTestSuite) Test_GetNotFound() {
+	stored, err := suite.store.Get(uuid.New())
+
+	suite.NoError(err)
+	suite.Nil(stored)
+}
+
+func (suite *ExecutionsStoreTestSuite) Test_List() {
+	start := time.Date(2022, 5, 10, 10, 0, 0, 0, time.UTC)
+	records := []*ExecutionRecord{}
+	for i := 0; i < 5; i++ {
+		record := newTestExecutionRecord(start.Add(time.Duration(i) * time.Minute))
+		records = append(records, record)
+		suite.store.Save(record)
+	}
+
+	page, total, err := suite.store.List(0, 2)
+	suite.NoError(err)
+	suite.Equal(5, total)
+	suite.Equal([]*ExecutionRecord{records[4], records[3]}, page)
+
+	page, _, _ = suite.store.List(4, 2)
+	suite.Equal([]*ExecutionRecord{records[0]}, page)
+
+	page, _, _ = suite.store.List(10, 2)
+	suite.Equal([]*ExecutionRecord{}, page)
+}
+
+func (suite *ExecutionsStoreTestSuite) Test_Persistence() {
+	record := newTestExecutionRecord(time.Date(2022, 5, 10, 10, 0, 0, 0, time.UTC))
+	suite.store.Save(record)
+	suite.store.Close()
+
+	store, err := NewExecutionsStore(path.Join(suite.tmpDir, "db", "executions.db"))
+	suite.NoError(err)
+	suite.store = store
+
+	stored, _ := suite.store.Get(record.ExecutionID)
+	suite.Equal(record, stored)
+}
+
+func (suite *ExecutionsStoreTestSuite) Test_FailInterrupted() {
+	running := newTestExecutionRecord(time.Date(2022, 5, 10, 10, 0, 0, 0, time.UTC))
+	preempted := newTestExecutionRecord(time.Date(2022, 5, 10, 11, 0, 0, 0, time.UTC))
+	preempted.Status = ExecutionPreempted
+	completed := newTestExecutionRecord(time.Date(2022, 5, 10, 12, 0, 0, 0, time.UTC))
+	completed.Status = ExecutionCompleted
+	for _, record := range []*ExecutionRecord{running, preempted, completed} {
+		suite.store.Save(record)
+	}
+
+	interrupted, err := suite.store.FailInterrupted()
+	suite.NoError(err)
+	suite.Equal(2, interrupted)
+
+	for _, record := range []*ExecutionRecord{running, preempted} {
+		stored, _ := suite.store.Get(record.ExecutionID)
+		suite.Equal(ExecutionFailed, stored.Status)
+		suite.Equal(errExecutionInterrupted, stored.Error)
+		suite.Equal(-1, stored.ReturnCode)
+		suite.NotNil(stored.FinishedAt)
+	}
+	stored, _ := suite.store.Get(completed.ExecutionID)
+	suite.Equal(completed, stored)
+
+	// The index keeps the records sorted
+	records, total, _ := suite.store.List(0, 10)
+	suite.Equal(3, total)
+	suite.Equal(running.ExecutionID, records[2].ExecutionID)
+}
@@ -0,0 +1,97 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The finished executions are remembered for this interval, so the requests sent again are not run twice
+var executionDedupInterval = time.Hour
+
+var ErrDuplicateExecution = errors.New("The execution was already requested")
+
+// duplicateExecutionError has the status of the execution requested before with the same id
+type duplicateExecutionError struct {
+	status string
+}
+
+func (e *duplicateExecutionError) Error() string {
+	return fmt.Sprintf("%s, its status is %s", ErrDuplicateExecution, e.status)
+}
+
+func (e *duplicateExecutionError) Is(target error) bool {
+	return target == ErrDuplicateExecution
+}
+
+// DuplicateExecutionStatus returns the status of the existing execution, if the error is a duplicated execution
+func DuplicateExecutionStatus(err error) (string, bool) {
+	var duplicateErr *duplicateExecutionError
+	if errors.As(err, &duplicateErr) {
+		return duplicateErr.status, true
+	}
+
+	return "", false
+}
+
+type trackedExecution struct {
+	status     string
+	finishedAt time.Time
+}
+
+// ExecutionsTracker keeps the status of the queued, running and recently finished executions by id,
+// so the executions requested again, e.g. by a server retry, are not run twice
+type ExecutionsTracker struct {
+	mu         sync.Mutex
+	executions map[uuid.UUID]*trackedExecution
+}
+
+func NewExecutionsTracker() *ExecutionsTracker {
+	return &ExecutionsTracker{executions: make(map[uuid.UUID]*trackedExecution)}
+}
+
+// Track adds the execution as queued. If the execution is already tracked, its status is returned instead
+func (t *ExecutionsTracker) Track(executionID uuid.UUID) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune()
+
+	if execution, ok := t.executions[executionID]; ok {
+		return execution.status, false
+	}
+	t.executions[executionID] = &trackedExecution{status: ExecutionQueued}
+
+	return ExecutionQueued, true
+}
+
+// Forget removes the execution, when it could not be scheduled, so it can be requested again
+func (t *ExecutionsTracker) Forget(executionID uuid.UUID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.executions, executionID)
+}
+
+// SetStatus updates the execution status. The finished executions are removed after the dedup interval
+func (t *ExecutionsTracker) SetStatus(executionID uuid.UUID, status string, finished bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	execution := &trackedExecution{status: status}
+	if finished {
+		execution.finishedAt = time.Now()
+	}
+	t.executions[executionID] = execution
+}
+
+func (t *ExecutionsTracker) prune() {
+	for executionID, execution := range t.executions {
+		if !execution.finishedAt.IsZero() && time.Since(execution.finishedAt) >= executionDedupInterval {
+			delete(t.executions, executionID)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package runner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecutionsTrackerTrack(t *testing.T) {
+	tracker := NewExecutionsTracker()
+	executionID := uuid.New()
+
+	status, ok := tracker.Track(executionID)
+	assert.True(t, ok)
+	assert.Equal(t, ExecutionQueued, status)
+
+	status, ok = tracker.Track(executionID)
+	assert.False(t, ok)
+	assert.Equal(t, ExecutionQueued, status)
+
+	tracker.SetStatus(executionID, ExecutionRunning, false)
+	status, ok = tracker.Track(executionID)
+	assert.False(t, ok)
+	assert.Equal(t, ExecutionRunning, status)
+
+	_, ok = tracker.Track(uuid.New())
+	assert.True(t, ok)
+}
+
+func TestExecutionsTrackerForget(t *testing.T) {
+	tracker := NewExecutionsTracker()
+	executionID := uuid.New()
+
+	tracker.Track(executionID)
+	tracker.Forget(executionID)
+
+	_, ok := tracker.Track(executionID)
+	assert.True(t, ok)
+}
+
+func TestExecutionsTrackerPrune(t *testing.T) {
+	tracker := NewExecutionsTracker()
+	finishedID := uuid.New()
+	runningID := uuid.New()
+
+	tracker.SetStatus(finishedID, ExecutionCompleted, true)
+	tracker.SetStatus(runningID, ExecutionRunning, false)
+
+	status, ok := tracker.Track(finishedID)
+	assert.False(t, ok)
+	assert.Equal(t, ExecutionCompleted, status)
+
+	tracker.executions[finishedID].finishedAt = time.Now().Add(-executionDedupInterval)
+	tracker.executions[runningID].finishedAt = time.Time{}
+
+	_, ok = tracker.Track(finishedID)
+	assert.True(t, ok)
+	_, ok = tracker.Track(runningID)
+	assert.False(t, ok)
+}
+
+func TestDuplicateExecutionStatus(t *testing.T) {
+	var err error = &duplicateExecutionError{status: ExecutionRunning}
+
+	assert.ErrorIs(t, err, ErrDuplicateExecution)
+	status, ok := DuplicateExecutionStatus(err)
+	assert.True(t, ok)
+	assert.Equal(t, ExecutionRunning, status)
+
+	_, ok = DuplicateExecutionStatus(ErrExecutionQueueFull)
+	assert.False(t, ok)
+}
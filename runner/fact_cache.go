@@ -0,0 +1,78 @@
+package runner
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	JSONFileFactCache = "jsonfile"
+	RedisFactCache    = "redis"
+
+	// AnsibleFactCacheDir is where the jsonfile fact cache keeps a file with the facts of each host.
+	// It is out of the ansible files folder, so the cache is kept when the runner restarts
+	AnsibleFactCacheDir = "facts_cache"
+
+	DefaultAnsibleFactCacheTTL = time.Hour
+)
+
+// factCacheConnection returns the fact cache connection written in the ansible configuration.
+// The redis password is not written in the file, it is given to ansible in the environment
+func factCacheConnection(config *Config) string {
+	switch config.AnsibleFactCache {
+	case JSONFileFactCache:
+		if config.AnsibleFactCacheDir != "" {
+			return config.AnsibleFactCacheDir
+		}
+		return path.Join(config.AnsibleFolder, AnsibleFactCacheDir)
+	case RedisFactCache:
+		return config.AnsibleFactCacheRedis
+	default:
+		return ""
+	}
+}
+
+// setFactCacheCredentials gives the redis password to ansible, as part of the cache connection
+func setFactCacheCredentials(config *Config, ansibleRunner *AnsibleRunner) {
+	if config.AnsibleFactCache == RedisFactCache && config.AnsibleFactCacheRedisPassword != "" {
+		ansibleRunner.setEnv(AnsibleFactCacheEnv, config.AnsibleFactCacheRedis+":"+config.AnsibleFactCacheRedisPassword)
+	}
+}
+
+// pruneFactCache removes the jsonfile cached facts older than the ttl. Ansible ignores them already,
+// but it never removes them, and the hosts of the removed clusters would be kept forever otherwise
+func pruneFactCache(config *Config) {
+	if config.AnsibleFactCache != JSONFileFactCache {
+		return
+	}
+
+	ttl := config.AnsibleFactCacheTTL
+	if ttl <= 0 {
+		ttl = DefaultAnsibleFactCacheTTL
+	}
+
+	cacheDir := factCacheConnection(config)
+	files, err := ioutil.ReadDir(cacheDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("Error reading the facts cache %s: %s", cacheDir, err)
+		}
+		return
+	}
+
+	for _, file := range files {
+		if file.IsDir() || time.Since(file.ModTime()) < ttl {
+			continue
+		}
+
+		if err := os.Remove(path.Join(cacheDir, file.Name())); err != nil && !os.IsNotExist(err) {
+			log.Warnf("Error removing the expired cached facts %s: %s", file.Name(), err)
+			continue
+		}
+		log.Debugf("Expired cached facts of %s removed", file.Name())
+	}
+}
@@ -0,0 +1,60 @@
+package runner
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPruneFactCache(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	cacheDir := path.Join(tmpDir, AnsibleFactCacheDir)
+	os.MkdirAll(cacheDir, 0755)
+	ioutil.WriteFile(path.Join(cacheDir, "host1"), []byte("{}"), 0644)
+	ioutil.WriteFile(path.Join(cacheDir, "host2"), []byte("{}"), 0644)
+	expired := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(path.Join(cacheDir, "host2"), expired, expired)
+
+	pruneFactCache(&Config{AnsibleFolder: tmpDir, AnsibleFactCache: JSONFileFactCache})
+
+	assert.FileExists(t, path.Join(cacheDir, "host1"))
+	assert.NoFileExists(t, path.Join(cacheDir, "host2"))
+
+	// The redis cache entries expire in the redis server
+	os.Chtimes(path.Join(cacheDir, "host1"), expired, expired)
+	pruneFactCache(&Config{AnsibleFolder: tmpDir, AnsibleFactCache: RedisFactCache})
+	assert.FileExists(t, path.Join(cacheDir, "host1"))
+}
+
+func TestFactCacheConnection(t *testing.T) {
+	config := &Config{AnsibleFolder: "/usr/etc/trento", AnsibleFactCache: JSONFileFactCache}
+	assert.Equal(t, "/usr/etc/trento/facts_cache", factCacheConnection(config))
+
+	config.AnsibleFactCacheDir = "/var/lib/trento/facts"
+	assert.Equal(t, "/var/lib/trento/facts", factCacheConnection(config))
+
+	config = &Config{AnsibleFactCache: RedisFactCache, AnsibleFactCacheRedis: "localhost:6379:0", AnsibleFactCacheDir: "/var/lib/trento/facts"}
+	assert.Equal(t, "localhost:6379:0", factCacheConnection(config))
+}
+
+func TestSetFactCacheCredentials(t *testing.T) {
+	ansibleRunner := DefaultAnsibleRunner()
+	setFactCacheCredentials(&Config{
+		AnsibleFactCache:              RedisFactCache,
+		AnsibleFactCacheRedis:         "localhost:6379:0",
+		AnsibleFactCacheRedisPassword: "secret",
+	}, ansibleRunner)
+
+	assert.Equal(t, map[string]string{"ANSIBLE_CACHE_PLUGIN_CONNECTION": "localhost:6379:0:secret"}, ansibleRunner.Envs)
+
+	ansibleRunner = DefaultAnsibleRunner()
+	setFactCacheCredentials(&Config{AnsibleFactCache: RedisFactCache, AnsibleFactCacheRedis: "localhost:6379:0"}, ansibleRunner)
+
+	assert.Empty(t, ansibleRunner.Envs)
+}
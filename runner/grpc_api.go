@@ -0,0 +1,267 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "github.com/trento-project/runner/api/proto"
+)
+
+// GrpcServer implements the runner gRPC API on top of the same services used by the HTTP API
+type GrpcServer struct {
+	pb.UnimplementedRunnerServer
+	runnerService   RunnerService
+	executionsStore ExecutionsStore
+	events          *EventsBroadcaster
+}
+
+func NewGrpcServer(runnerService RunnerService, executionsStore ExecutionsStore, events *EventsBroadcaster) *GrpcServer {
+	return &GrpcServer{
+		runnerService:   runnerService,
+		executionsStore: executionsStore,
+		events:          events,
+	}
+}
+
+func (s *GrpcServer) StartExecution(ctx context.Context, request *pb.StartExecutionRequest) (*pb.StartExecutionResponse, error) {
+	e, err := newExecutionEventFromRequest(request)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := binding.Validator.ValidateStruct(e); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	err = s.runnerService.ScheduleExecution(e)
+	if executionStatus, ok := DuplicateExecutionStatus(err); ok {
+		return &pb.StartExecutionResponse{ExecutionId: e.ExecutionID.String(), Status: executionStatus}, nil
+	}
+	if errors.Is(err, ErrDraining) {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	if errors.Is(err, ErrUpstreamNotFound) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+
+	return &pb.StartExecutionResponse{ExecutionId: e.ExecutionID.String(), Status: ExecutionQueued}, nil
+}
+
+func (s *GrpcServer) GetExecutionStatus(ctx context.Context, request *pb.GetExecutionStatusRequest) (*pb.ExecutionStatus, error) {
+	if s.executionsStore == nil {
+		return nil, status.Error(codes.FailedPrecondition, "executions history is disabled")
+	}
+
+	executionID, err := uuid.Parse(request.ExecutionId)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid execution id")
+	}
+
+	record, err := s.executionsStore.Get(executionID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if record == nil {
+		return nil, status.Error(codes.NotFound, "execution not found")
+	}
+
+	executionStatus := &pb.ExecutionStatus{
+		ExecutionId: record.ExecutionID.String(),
+		ClusterId:   record.ClusterID.String(),
+		Status:      record.Status,
+		StartedAt:   timestamppb.New(record.StartedAt),
+		ReturnCode:  int32(record.ReturnCode),
+		Summary:     make(map[string]int32),
+		Error:       record.Error,
+	}
+	if record.FinishedAt != nil {
+		executionStatus.FinishedAt = timestamppb.New(*record.FinishedAt)
+	}
+	for result, count := range record.Summary {
+		executionStatus.Summary[result] = int32(count)
+	}
+
+	return executionStatus, nil
+}
+
+func (s *GrpcServer) StreamExecutionEvents(request *pb.StreamExecutionEventsRequest, stream pb.Runner_StreamExecutionEventsServer) error {
+	var executionID uuid.UUID
+	if request.ExecutionId != "" {
+		var err error
+		if executionID, err = uuid.Parse(request.ExecutionId); err != nil {
+			return status.Error(codes.InvalidArgument, "invalid execution id")
+		}
+	}
+
+	events, unsubscribe := s.events.Subscribe()
+	defer unsubscribe()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if executionID != uuid.Nil && event.ExecutionID != executionID {
+				continue
+			}
+
+			message, err := newExecutionEventMessage(event)
+			if err != nil {
+				log.Errorf("Error converting the event %s of execution %s: %s", event.Event, event.ExecutionID.String(), err)
+				continue
+			}
+			if err := stream.Send(message); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func (s *GrpcServer) BuildCatalog(ctx context.Context, request *pb.BuildCatalogRequest) (*pb.BuildCatalogResponse, error) {
+	err := s.runnerService.RebuildCatalog()
+	if errors.Is(err, ErrCatalogRebuilding) {
+		return nil, status.Error(codes.Aborted, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	response := &pb.BuildCatalogResponse{Checks: []*pb.CatalogCheck{}}
+	if catalog := s.runnerService.GetCatalog(); catalog != nil {
+		for _, check := range *catalog {
+			response.Checks = append(response.Checks, &pb.CatalogCheck{
+				Id:             check.ID,
+				Name:           check.Name,
+				Group:          check.Group,
+				Provider:       check.Provider,
+				Description:    check.Description,
+				Remediation:    check.Remediation,
+				Implementation: check.Implementation,
+				Labels:         check.Labels,
+				Premium:        check.Premium,
+			})
+		}
+	}
+
+	return response, nil
+}
+
+func newExecutionEventFromRequest(request *pb.StartExecutionRequest) (*ExecutionEvent, error) {
+	executionID, err := uuid.Parse(request.ExecutionId)
+	if err != nil {
+		return nil, errors.New("invalid execution id")
+	}
+
+	clusterID, err := uuid.Parse(request.ClusterId)
+	if err != nil {
+		return nil, errors.New("invalid cluster id")
+	}
+
+	e := &ExecutionEvent{
+		ExecutionID:      executionID,
+		ClusterID:        clusterID,
+		Provider:         request.Provider,
+		Checks:           request.Checks,
+		Hosts:            []*Host{},
+		Upstream:         request.Upstream,
+		Connection:       newConnectionOptions(request.Connection),
+		DryRun:           request.DryRun,
+		RollingBatchSize: int(request.RollingBatchSize),
+		Priority:         request.Priority,
+		Remediate:        request.Remediate,
+		RemediateChecks:  request.RemediateChecks,
+		Tags:             request.Tags,
+		SkipTags:         request.SkipTags,
+	}
+	if e.Checks == nil {
+		e.Checks = []string{}
+	}
+	if request.Variables != nil {
+		e.Variables = request.Variables.AsMap()
+	}
+
+	for _, host := range request.Hosts {
+		hostID, err := uuid.Parse(host.HostId)
+		if err != nil {
+			return nil, errors.New("invalid host id")
+		}
+		e.Hosts = append(e.Hosts, &Host{
+			HostID:     hostID,
+			Address:    host.Address,
+			User:       host.User,
+			Name:       host.Name,
+			Role:       host.Role,
+			Connection: newConnectionOptions(host.Connection),
+			Platform:   host.Platform,
+		})
+	}
+
+	for _, limitHost := range request.Limit {
+		hostID, err := uuid.Parse(limitHost)
+		if err != nil {
+			return nil, errors.New("invalid limit host id")
+		}
+		e.Limit = append(e.Limit, hostID)
+	}
+
+	if err := e.validate(); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func newConnectionOptions(options *pb.ConnectionOptions) *ConnectionOptions {
+	if options == nil {
+		return nil
+	}
+
+	return &ConnectionOptions{
+		SSHPasswordSecret:    options.SshPasswordSecret,
+		BecomeMethod:         options.BecomeMethod,
+		BecomeUser:           options.BecomeUser,
+		BecomePasswordSecret: options.BecomePasswordSecret,
+		WinRMPort:            int(options.WinrmPort),
+		WinRMTransport:       options.WinrmTransport,
+		Bastion:              options.Bastion,
+	}
+}
+
+// newExecutionEventMessage converts the event payload, which is sent as json in the callbacks, in a protobuf struct
+func newExecutionEventMessage(event *LifecycleEvent) (*pb.ExecutionEvent, error) {
+	content, err := json.Marshal(event.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return nil, err
+	}
+
+	payload, err := structpb.NewStruct(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.ExecutionEvent{
+		ExecutionId: event.ExecutionID.String(),
+		Event:       event.Event,
+		Payload:     payload,
+	}, nil
+}
@@ -0,0 +1,271 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	pb "github.com/trento-project/runner/api/proto"
+)
+
+type GrpcApiTestCase struct {
+	suite.Suite
+	tmpDir          string
+	runnerService   *MockRunnerService
+	executionsStore *boltExecutionsStore
+	events          *EventsBroadcaster
+	server          *grpc.Server
+	conn            *grpc.ClientConn
+	client          pb.RunnerClient
+}
+
+func TestGrpcApiTestCase(t *testing.T) {
+	suite.Run(t, new(GrpcApiTestCase))
+}
+
+func (suite *GrpcApiTestCase) SetupTest() {
+	suite.tmpDir, _ = ioutil.TempDir(os.TempDir(), "trentotest")
+	store, err := NewExecutionsStore(path.Join(suite.tmpDir, "executions.db"))
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	suite.runnerService = new(MockRunnerService)
+	suite.executionsStore = store
+	suite.events = NewEventsBroadcaster()
+	suite.startServer(NewGrpcServer(suite.runnerService, suite.executionsStore, suite.events))
+}
+
+func (suite *GrpcApiTestCase) startServer(grpcServer *GrpcServer) {
+	listener := bufconn.Listen(1024 * 1024)
+	suite.server = grpc.NewServer()
+	pb.RegisterRunnerServer(suite.server, grpcServer)
+	go suite.server.Serve(listener)
+
+	conn, err := grpc.Dial(
+		"bufnet",
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return listener.Dial()
+		}),
+	)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	suite.conn = conn
+	suite.client = pb.NewRunnerClient(conn)
+}
+
+func (suite *GrpcApiTestCase) TearDownTest() {
+	suite.conn.Close()
+	suite.server.Stop()
+	suite.executionsStore.Close()
+	os.RemoveAll(suite.tmpDir)
+}
+
+func (suite *GrpcApiTestCase) Test_StartExecution() {
+	executionID := uuid.New()
+	clusterID := uuid.New()
+	hostID := uuid.New()
+
+	expectedEvent := &ExecutionEvent{
+		ExecutionID: executionID,
+		ClusterID:   clusterID,
+		Provider:    "azure",
+		Checks:      []string{"A1244C"},
+		Hosts:       []*Host{&Host{HostID: hostID, Address: "192.168.1.1", User: "root", Platform: "windows"}},
+		Variables:   map[string]interface{}{"token_timeout": float64(30000)},
+		Upstream:    "customer1",
+		Tags:        []string{"corosync"},
+		SkipTags:    []string{"sbd"},
+
+		RollingBatchSize: 2,
+	}
+	suite.runnerService.On("ScheduleExecution", expectedEvent).Return(nil)
+
+	variables, _ := structpb.NewStruct(map[string]interface{}{"token_timeout": 30000})
+
+	response, err := suite.client.StartExecution(context.Background(), &pb.StartExecutionRequest{
+		ExecutionId: executionID.String(),
+		ClusterId:   clusterID.String(),
+		Provider:    "azure",
+		Checks:      []string{"A1244C"},
+		Hosts:       []*pb.Host{&pb.Host{HostId: hostID.String(), Address: "192.168.1.1", User: "root", Platform: "windows"}},
+		Variables:   variables,
+		Upstream:    "customer1",
+		Tags:        []string{"corosync"},
+		SkipTags:    []string{"sbd"},
+
+		RollingBatchSize: 2,
+	})
+
+	suite.NoError(err)
+	suite.Equal(executionID.String(), response.ExecutionId)
+	suite.Equal(ExecutionQueued, response.Status)
+	suite.runnerService.AssertExpectations(suite.T())
+}
+
+func (suite *GrpcApiTestCase) Test_StartExecution_Duplicate() {
+	executionID := uuid.New()
+	suite.runnerService.On("ScheduleExecution", mock.Anything).Return(
+		&duplicateExecutionError{status: ExecutionCompleted})
+
+	response, err := suite.client.StartExecution(context.Background(), &pb.StartExecutionRequest{
+		ExecutionId: executionID.String(),
+		ClusterId:   uuid.New().String(),
+		Provider:    "azure",
+		Checks:      []string{},
+		Hosts:       []*pb.Host{},
+	})
+
+	suite.NoError(err)
+	suite.Equal(executionID.String(), response.ExecutionId)
+	suite.Equal(ExecutionCompleted, response.Status)
+}
+
+func (suite *GrpcApiTestCase) Test_StartExecution_Errors() {
+	_, err := suite.client.StartExecution(context.Background(), &pb.StartExecutionRequest{ExecutionId: "invalid"})
+	suite.Equal(codes.InvalidArgument, status.Code(err))
+
+	_, err = suite.client.StartExecution(context.Background(), &pb.StartExecutionRequest{
+		ExecutionId: uuid.New().String(),
+		ClusterId:   uuid.New().String(),
+	})
+	suite.Equal(codes.InvalidArgument, status.Code(err))
+
+	_, err = suite.client.StartExecution(context.Background(), &pb.StartExecutionRequest{
+		ExecutionId: uuid.New().String(),
+		ClusterId:   uuid.New().String(),
+		Provider:    "azure",
+		Checks:      []string{},
+		Hosts:       []*pb.Host{},
+		Limit:       []string{uuid.New().String()},
+	})
+	suite.Equal(codes.InvalidArgument, status.Code(err))
+
+	_, err = suite.client.StartExecution(context.Background(), &pb.StartExecutionRequest{
+		ExecutionId: uuid.New().String(),
+		ClusterId:   uuid.New().String(),
+		Provider:    "azure",
+		Checks:      []string{},
+		Hosts:       []*pb.Host{&pb.Host{HostId: uuid.New().String(), Address: "192.168.1.1", User: "root", Role: "hana_tertiary"}},
+	})
+	suite.Equal(codes.InvalidArgument, status.Code(err))
+
+	suite.runnerService.On("ScheduleExecution", mock.Anything).Return(fmt.Errorf("Cannot process more executions"))
+	_, err = suite.client.StartExecution(context.Background(), &pb.StartExecutionRequest{
+		ExecutionId: uuid.New().String(),
+		ClusterId:   uuid.New().String(),
+		Provider:    "azure",
+		Checks:      []string{},
+		Hosts:       []*pb.Host{},
+	})
+	suite.Equal(codes.ResourceExhausted, status.Code(err))
+}
+
+func (suite *GrpcApiTestCase) Test_GetExecutionStatus() {
+	startedAt := time.Date(2022, 5, 10, 10, 0, 0, 0, time.UTC)
+	finishedAt := startedAt.Add(time.Minute)
+	record := &ExecutionRecord{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Status:      ExecutionFailed,
+		StartedAt:   startedAt,
+		FinishedAt:  &finishedAt,
+		ReturnCode:  2,
+		Summary:     map[string]int{"passing": 1},
+		Error:       "exit status 2",
+	}
+	suite.executionsStore.Save(record)
+
+	response, err := suite.client.GetExecutionStatus(
+		context.Background(), &pb.GetExecutionStatusRequest{ExecutionId: record.ExecutionID.String()})
+
+	suite.NoError(err)
+	suite.Equal(record.ExecutionID.String(), response.ExecutionId)
+	suite.Equal(record.ClusterID.String(), response.ClusterId)
+	suite.Equal("failed", response.Status)
+	suite.Equal(startedAt, response.StartedAt.AsTime())
+	suite.Equal(finishedAt, response.FinishedAt.AsTime())
+	suite.Equal(int32(2), response.ReturnCode)
+	suite.Equal(map[string]int32{"passing": 1}, response.Summary)
+	suite.Equal("exit status 2", response.Error)
+
+	_, err = suite.client.GetExecutionStatus(
+		context.Background(), &pb.GetExecutionStatusRequest{ExecutionId: uuid.New().String()})
+	suite.Equal(codes.NotFound, status.Code(err))
+}
+
+func (suite *GrpcApiTestCase) Test_GetExecutionStatus_Disabled() {
+	suite.conn.Close()
+	suite.server.Stop()
+	suite.startServer(NewGrpcServer(suite.runnerService, nil, suite.events))
+
+	_, err := suite.client.GetExecutionStatus(
+		context.Background(), &pb.GetExecutionStatusRequest{ExecutionId: uuid.New().String()})
+	suite.Equal(codes.FailedPrecondition, status.Code(err))
+}
+
+func (suite *GrpcApiTestCase) Test_StreamExecutionEvents() {
+	executionID := uuid.New()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := suite.client.StreamExecutionEvents(ctx, &pb.StreamExecutionEventsRequest{ExecutionId: executionID.String()})
+	suite.NoError(err)
+
+	// Wait until the stream is subscribed
+	for {
+		suite.events.mu.Lock()
+		subscribers := len(suite.events.subscribers)
+		suite.events.mu.Unlock()
+		if subscribers > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	suite.events.Publish(uuid.New(), "execution_started", map[string]string{"cluster_id": "other"})
+	suite.events.Publish(executionID, "check_result", map[string]interface{}{"check_id": "A1244C", "result": "passing"})
+
+	event, err := stream.Recv()
+	suite.NoError(err)
+	suite.Equal(executionID.String(), event.ExecutionId)
+	suite.Equal("check_result", event.Event)
+	suite.Equal(map[string]interface{}{"check_id": "A1244C", "result": "passing"}, event.Payload.AsMap())
+}
+
+func (suite *GrpcApiTestCase) Test_BuildCatalog() {
+	suite.runnerService.On("RebuildCatalog").Return(nil)
+	suite.runnerService.On("GetCatalog").Return(&Catalog{
+		&CatalogCheck{ID: "156F64", Name: "1.1.1", Group: "Corosync", Provider: "azure"},
+	})
+
+	response, err := suite.client.BuildCatalog(context.Background(), &pb.BuildCatalogRequest{})
+
+	suite.NoError(err)
+	suite.Len(response.Checks, 1)
+	suite.Equal("156F64", response.Checks[0].Id)
+	suite.Equal("azure", response.Checks[0].Provider)
+}
+
+func (suite *GrpcApiTestCase) Test_BuildCatalog_InProgress() {
+	suite.runnerService.On("RebuildCatalog").Return(ErrCatalogRebuilding)
+
+	_, err := suite.client.BuildCatalog(context.Background(), &pb.BuildCatalogRequest{})
+	suite.Equal(codes.Aborted, status.Code(err))
+}
@@ -8,6 +8,41 @@ func HealthHandler(c *gin.Context) {
 	c.JSON(200, map[string]string{"status": "ok"})
 }
 
+// LivenessHandler reports if the runner is still able to process execution requests
+func LivenessHandler(executionWorkerPool *ExecutionWorkerPool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !executionWorkerPool.IsAlive() {
+			c.JSON(503, map[string]string{"status": "nok"})
+			return
+		}
+
+		c.JSON(200, map[string]string{"status": "ok"})
+	}
+}
+
+// ReadinessHandler reports if the runner is ready to run checks, having the catalog
+// available and being able to talk with the Trento server. It is not ready while shutting down
+func ReadinessHandler(runnerService RunnerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		catalogReady := runnerService.IsCatalogReady()
+		serverReachable := runnerService.IsServerReachable()
+		draining := runnerService.IsDraining()
+		ready := catalogReady && serverReachable && !draining
+
+		status := 200
+		if !ready {
+			status = 503
+		}
+
+		c.JSON(status, map[string]bool{
+			"ready":            ready,
+			"catalog_ready":    catalogReady,
+			"server_reachable": serverReachable,
+			"draining":         draining,
+		})
+	}
+}
+
 func ReadyHandler(runnerService RunnerService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.JSON(200, map[string]bool{"ready": runnerService.IsCatalogReady()})
@@ -56,3 +56,114 @@ func (suite *HealthApiTestCase) Test_ApiReadyTest() {
 	suite.Equal(200, resp.Code)
 	suite.JSONEq(string(expectedJson), resp.Body.String())
 }
+
+func (suite *HealthApiTestCase) Test_ApiLivenessTest() {
+	deps := setupTestDependencies()
+	deps.executionWorkerPool = NewExecutionWorkerPool(new(MockRunnerService), 1, DefaultShutdownGracePeriod)
+
+	app, err := NewAppWithDeps(suite.config, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/healthz", nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	expectedJson, _ := json.Marshal(map[string]string{"status": "nok"})
+	suite.Equal(503, resp.Code)
+	suite.JSONEq(string(expectedJson), resp.Body.String())
+
+	deps.executionWorkerPool.alive = 1
+
+	resp = httptest.NewRecorder()
+	app.webEngine.ServeHTTP(resp, req)
+
+	expectedJson, _ = json.Marshal(map[string]string{"status": "ok"})
+	suite.Equal(200, resp.Code)
+	suite.JSONEq(string(expectedJson), resp.Body.String())
+}
+
+func (suite *HealthApiTestCase) Test_ApiReadinessTest() {
+	mockRunnerService := new(MockRunnerService)
+	mockRunnerService.On("IsCatalogReady").Return(true)
+	mockRunnerService.On("IsServerReachable").Return(true)
+	mockRunnerService.On("IsDraining").Return(false)
+
+	deps := setupTestDependencies()
+	deps.runnerService = mockRunnerService
+
+	app, err := NewAppWithDeps(suite.config, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/readyz", nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	expectedJson, _ := json.Marshal(map[string]bool{
+		"ready":            true,
+		"catalog_ready":    true,
+		"server_reachable": true,
+		"draining":         false,
+	})
+	suite.Equal(200, resp.Code)
+	suite.JSONEq(string(expectedJson), resp.Body.String())
+}
+
+func (suite *HealthApiTestCase) Test_ApiReadinessTest_NotReady() {
+	mockRunnerService := new(MockRunnerService)
+	mockRunnerService.On("IsCatalogReady").Return(true)
+	mockRunnerService.On("IsServerReachable").Return(false)
+	mockRunnerService.On("IsDraining").Return(false)
+
+	deps := setupTestDependencies()
+	deps.runnerService = mockRunnerService
+
+	app, err := NewAppWithDeps(suite.config, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/readyz", nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	expectedJson, _ := json.Marshal(map[string]bool{
+		"ready":            false,
+		"catalog_ready":    true,
+		"server_reachable": false,
+		"draining":         false,
+	})
+	suite.Equal(503, resp.Code)
+	suite.JSONEq(string(expectedJson), resp.Body.String())
+}
+
+func (suite *HealthApiTestCase) Test_ApiReadinessTest_Draining() {
+	mockRunnerService := new(MockRunnerService)
+	mockRunnerService.On("IsCatalogReady").Return(true)
+	mockRunnerService.On("IsServerReachable").Return(true)
+	mockRunnerService.On("IsDraining").Return(true)
+
+	deps := setupTestDependencies()
+	deps.runnerService = mockRunnerService
+
+	app, err := NewAppWithDeps(suite.config, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/readyz", nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	expectedJson, _ := json.Marshal(map[string]bool{
+		"ready":            false,
+		"catalog_ready":    true,
+		"server_reachable": true,
+		"draining":         true,
+	})
+	suite.Equal(503, resp.Code)
+	suite.JSONEq(string(expectedJson), resp.Body.String())
+}
@@ -0,0 +1,102 @@
+package runner
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// hostFences keeps the hosts targeted by the running playbooks, so two executions never run a playbook in the same
+// host at once, e.g. in the majority maker shared by two clusters, avoiding the conflicting facts gathering and the
+// ssh multiplexing failures. The execution targeting a busy host waits for the running one to release it
+type hostFences struct {
+	mu    sync.Mutex
+	hosts map[uuid.UUID]uuid.UUID
+	// released is closed each time some hosts are released, waking up the waiting executions
+	released chan struct{}
+}
+
+func newHostFences() *hostFences {
+	return &hostFences{
+		hosts:    make(map[uuid.UUID]uuid.UUID),
+		released: make(chan struct{}),
+	}
+}
+
+// acquire waits until none of the target hosts of the execution is used by another one, and takes all of them at
+// once, so two executions waiting for each other hosts never block. The returned function releases the hosts
+func (f *hostFences) acquire(ctx context.Context, e *ExecutionEvent) (func(), error) {
+	hosts := e.targetHosts()
+	logged := false
+
+	for {
+		f.mu.Lock()
+		busyHost, runningExecution := f.busy(e.ExecutionID, hosts)
+		if busyHost == nil {
+			for _, host := range hosts {
+				f.hosts[host.HostID] = e.ExecutionID
+			}
+			f.mu.Unlock()
+
+			return func() { f.release(e.ExecutionID, hosts) }, nil
+		}
+		released := f.released
+		f.mu.Unlock()
+
+		if !logged {
+			loggerFromContext(ctx).Infof("Host %s is used by execution %s, execution %s waits for it to finish",
+				busyHost.HostID.String(), runningExecution.String(), e.ExecutionID.String())
+			logged = true
+		}
+
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// busy returns the first host used by another execution, and that execution
+func (f *hostFences) busy(executionID uuid.UUID, hosts []*Host) (*Host, uuid.UUID) {
+	for _, host := range hosts {
+		if running, ok := f.hosts[host.HostID]; ok && running != executionID {
+			return host, running
+		}
+	}
+
+	return nil, uuid.Nil
+}
+
+func (f *hostFences) release(executionID uuid.UUID, hosts []*Host) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, host := range hosts {
+		if f.hosts[host.HostID] == executionID {
+			delete(f.hosts, host.HostID)
+		}
+	}
+	close(f.released)
+	f.released = make(chan struct{})
+}
+
+// runChecks runs the checks of the execution, and remediates them if it is requested, once none of its target hosts
+// is used by the playbook of another execution
+func (c *runnerService) runChecks(
+	ctx context.Context, e *ExecutionEvent, outputHandler func(stream, line string)) (*ExecutionResults, error) {
+
+	release, err := c.hostFences.acquire(ctx, e)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	results, err := c.checkEngine.Run(ctx, e, outputHandler)
+	if err == nil && e.Remediate && results != nil {
+		results, err = c.remediate(ctx, e, results, outputHandler)
+	}
+
+	return results, err
+}
@@ -0,0 +1,107 @@
+package runner
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func fencesTestExecution(hostIDs ...uuid.UUID) *ExecutionEvent {
+	hosts := []*Host{}
+	for _, hostID := range hostIDs {
+		hosts = append(hosts, &Host{HostID: hostID, Address: "192.168.10.1", User: "root"})
+	}
+
+	return &ExecutionEvent{ExecutionID: uuid.New(), ClusterID: uuid.New(), Checks: []string{"156F64"}, Hosts: hosts}
+}
+
+func TestHostFences(t *testing.T) {
+	fences := newHostFences()
+	majorityMaker := uuid.New()
+
+	first := fencesTestExecution(uuid.New(), majorityMaker)
+	release, err := fences.acquire(context.Background(), first)
+	assert.NoError(t, err)
+
+	// The executions in other hosts are not blocked
+	other, err := fences.acquire(context.Background(), fencesTestExecution(uuid.New()))
+	assert.NoError(t, err)
+	other()
+
+	acquired := make(chan struct{})
+	go func() {
+		secondRelease, err := fences.acquire(context.Background(), fencesTestExecution(uuid.New(), majorityMaker))
+		assert.NoError(t, err)
+		close(acquired)
+		secondRelease()
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("the shared host must not be used by two executions at once")
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("the shared host must be acquired once it is released")
+	}
+}
+
+func TestHostFences_Cancelled(t *testing.T) {
+	fences := newHostFences()
+	hostID := uuid.New()
+
+	release, err := fences.acquire(context.Background(), fencesTestExecution(hostID))
+	assert.NoError(t, err)
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	_, err = fences.acquire(ctx, fencesTestExecution(hostID))
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestRunChecks_SharedHost(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	runnerService, err := NewRunnerService(&Config{AnsibleFolder: tmpDir})
+	assert.NoError(t, err)
+
+	var running, maxRunning int32
+	runnerService.checkEngine = checkEngineFunc(func(e *ExecutionEvent) (*ExecutionResults, error) {
+		current := atomic.AddInt32(&running, 1)
+		if current > atomic.LoadInt32(&maxRunning) {
+			atomic.StoreInt32(&maxRunning, current)
+		}
+		time.Sleep(time.Millisecond * 20)
+		atomic.AddInt32(&running, -1)
+		return &ExecutionResults{ClusterID: e.ClusterID.String(), Hosts: []*HostResults{}}, nil
+	})
+
+	majorityMaker := uuid.New()
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := runnerService.runChecks(
+				context.Background(), fencesTestExecution(uuid.New(), majorityMaker), func(stream, line string) {})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), maxRunning)
+	assert.Empty(t, runnerService.hostFences.hosts)
+}
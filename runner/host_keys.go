@@ -0,0 +1,357 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	// Without host key checking, as before, the hosts keys are not verified. The strict checking only trusts the keys
+	// of the known hosts file, and the trust on first use one adds the keys of the new hosts to the file
+	HostKeyCheckingDisabled = "disabled"
+	HostKeyCheckingStrict   = "strict"
+	HostKeyCheckingTOFU     = "tofu"
+
+	// The known hosts file is kept in the ansible folder by default, out of the ansible files created on each start
+	DefaultKnownHostsFile = "known_hosts"
+
+	// The reasons a host key is not trusted
+	HostKeyUnknown  = "unknown"
+	HostKeyMismatch = "mismatch"
+
+	// The checks of the hosts with a changed key are reported with this result, instead of running them
+	checkResultHostKeyMismatch = "host_key_mismatch"
+)
+
+var ErrHostKeyNotPending = errors.New("The host key is not pending")
+
+var hostKeyScanTimeout = time.Second * 10
+
+// errHostKeyScanned stops the ssh handshake once the host key is received, without authenticating
+var errHostKeyScanned = errors.New("host key scanned")
+
+// PendingHostKey is a host key that is not trusted, waiting to be accepted
+type PendingHostKey struct {
+	// Host is the host as written in the known hosts file, with the port between brackets if it is not 22
+	Host        string    `json:"host"`
+	KeyType     string    `json:"key_type"`
+	Fingerprint string    `json:"fingerprint"`
+	Reason      string    `json:"reason"`
+	SeenAt      time.Time `json:"seen_at"`
+	key         ssh.PublicKey
+}
+
+// hostKeyError is returned when the key of a host is not trusted
+type hostKeyError struct {
+	host   string
+	reason string
+}
+
+func (e *hostKeyError) Error() string {
+	if e.reason == HostKeyMismatch {
+		return fmt.Sprintf(
+			"Host key verification failed: the host key of %s changed, it must be accepted if the change is expected", e.host)
+	}
+
+	return fmt.Sprintf("Host key verification failed: the host key of %s is unknown, it must be accepted", e.host)
+}
+
+// HostKeys verifies the ssh keys of the hosts with the known hosts file managed by the runner. The keys that are not
+// trusted are kept as pending, so they can be accepted through the API, replacing the old key of the host if any
+type HostKeys struct {
+	mode    string
+	file    string
+	mu      sync.Mutex
+	pending map[string]*PendingHostKey
+}
+
+// knownHostsFile returns the known hosts file of the runner, the one in the ansible folder if it is not configured
+func knownHostsFile(config *Config) string {
+	if config.SSHKnownHostsFile != "" {
+		return config.SSHKnownHostsFile
+	}
+
+	return path.Join(config.AnsibleFolder, DefaultKnownHostsFile)
+}
+
+// NewHostKeys creates the known hosts file if it does not exist. It returns nil if the host key checking is disabled
+func NewHostKeys(config *Config) (*HostKeys, error) {
+	if config.SSHHostKeyChecking == "" || config.SSHHostKeyChecking == HostKeyCheckingDisabled {
+		return nil, nil
+	}
+
+	file := knownHostsFile(config)
+	if err := os.MkdirAll(path.Dir(file), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_RDONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create the known hosts file: %s", err)
+	}
+	f.Close()
+
+	return &HostKeys{
+		mode:    config.SSHHostKeyChecking,
+		file:    file,
+		pending: make(map[string]*PendingHostKey),
+	}, nil
+}
+
+// Verify checks the key of the host in the given address. The unknown keys are added to the known hosts file
+// in the trust on first use mode, and kept as pending in the strict one. The changed keys are always pending
+func (h *HostKeys) Verify(address string, key ssh.PublicKey) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	callback, err := knownhosts.New(h.file)
+	if err != nil {
+		return err
+	}
+
+	host := knownhosts.Normalize(address)
+	err = callback(address, hostAddr(address), key)
+	var keyErr *knownhosts.KeyError
+	var revokedErr *knownhosts.RevokedError
+	switch {
+	case err == nil:
+		delete(h.pending, host)
+		return nil
+	case errors.As(err, &keyErr) && len(keyErr.Want) == 0:
+		if h.mode == HostKeyCheckingTOFU {
+			return h.appendKey(host, key)
+		}
+		return h.addPending(host, key, HostKeyUnknown)
+	case errors.As(err, &keyErr), errors.As(err, &revokedErr):
+		return h.addPending(host, key, HostKeyMismatch)
+	default:
+		return err
+	}
+}
+
+func (h *HostKeys) addPending(host string, key ssh.PublicKey, reason string) error {
+	h.pending[host] = &PendingHostKey{
+		Host:        host,
+		KeyType:     key.Type(),
+		Fingerprint: ssh.FingerprintSHA256(key),
+		Reason:      reason,
+		SeenAt:      time.Now().UTC(),
+		key:         key,
+	}
+
+	return &hostKeyError{host: host, reason: reason}
+}
+
+// Pending returns the keys waiting to be accepted, sorted by host
+func (h *HostKeys) Pending() []*PendingHostKey {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	pending := make([]*PendingHostKey, 0, len(h.pending))
+	for _, key := range h.pending {
+		pending = append(pending, key)
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Host < pending[j].Host
+	})
+
+	return pending
+}
+
+// Accept trusts the pending key of the host, if it has the given fingerprint, so the key accepted is the one
+// reviewed. The old keys of the host are removed from the known hosts file
+func (h *HostKeys) Accept(host, fingerprint string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	pending, ok := h.pending[host]
+	if !ok || pending.Fingerprint != fingerprint {
+		return ErrHostKeyNotPending
+	}
+
+	if err := h.removeHost(host); err != nil {
+		return err
+	}
+	if err := h.appendKey(host, pending.key); err != nil {
+		return err
+	}
+	delete(h.pending, host)
+
+	return nil
+}
+
+func (h *HostKeys) appendKey(host string, key ssh.PublicKey) error {
+	f, err := os.OpenFile(h.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.WriteString(knownhosts.Line([]string{host}, key) + "\n")
+	return err
+}
+
+// removeHost removes the lines of the host from the known hosts file. The hashed lines are kept,
+// the changed keys are found in the plain lines written by the runner
+func (h *HostKeys) removeHost(host string) error {
+	content, err := ioutil.ReadFile(h.file)
+	if err != nil {
+		return err
+	}
+
+	var kept bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if fields := strings.Fields(line); len(fields) > 0 && !strings.HasPrefix(fields[0], "#") {
+			hosts := strings.Split(fields[0], ",")
+			if containsString(hosts, host) {
+				continue
+			}
+		}
+		kept.WriteString(line + "\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return writeFileAtomically(h.file, kept.Bytes())
+}
+
+// verifyHostKeys scans the key of every ssh target host, in parallel, and verifies it. It returns a copy of the
+// execution event without the hosts whose key is not trusted, and their results, unreachable, and with the
+// host key mismatch result of their checks if their key changed. The windows hosts and the ones connected through
+// a bastion are not scanned, ssh verifies them with the known hosts file
+func (h *HostKeys) verifyHostKeys(
+	ctx context.Context, e *ExecutionEvent, defaultBastion string) (*ExecutionEvent, *ExecutionResults) {
+	errs := make([]error, len(e.Hosts))
+
+	var wg sync.WaitGroup
+	for index, host := range e.Hosts {
+		if !e.isTarget(host) || host.isWindows() || preflightBastion(defaultBastion, e, host) != "" {
+			continue
+		}
+		wg.Add(1)
+		go func(index int, host *Host) {
+			defer wg.Done()
+			address, err := hostPort(host.Address, sshPort)
+			if err != nil {
+				errs[index] = err
+				return
+			}
+			key, err := scanHostKey(ctx, address)
+			if err != nil {
+				// The unreachable hosts are reported by the playbook, as usual
+				loggerFromContext(ctx).Warnf("Cannot scan the host key of %s: %s", host.HostID.String(), err)
+				return
+			}
+			errs[index] = h.Verify(address, key)
+		}(index, host)
+	}
+	wg.Wait()
+
+	trusted := *e
+	trusted.Hosts = []*Host{}
+	untrusted := &ExecutionResults{ClusterID: e.ClusterID.String(), Hosts: []*HostResults{}}
+
+	for index, host := range e.Hosts {
+		if errs[index] == nil {
+			trusted.Hosts = append(trusted.Hosts, host)
+			continue
+		}
+
+		loggerFromContext(ctx).Errorf("Host %s is not trusted: %s", host.HostID.String(), errs[index])
+		untrusted.addHost(host.HostID.String(), false, errs[index].Error())
+
+		var keyErr *hostKeyError
+		if errors.As(errs[index], &keyErr) && keyErr.reason == HostKeyMismatch {
+			for _, checkID := range e.hostChecks(host) {
+				untrusted.addResult(host.HostID.String(), checkID, checkResultHostKeyMismatch, errs[index].Error())
+			}
+		}
+	}
+
+	return &trusted, untrusted
+}
+
+// scanHostKey returns the key presented by the ssh server in the address, without authenticating
+func scanHostKey(ctx context.Context, address string) (ssh.PublicKey, error) {
+	dialer := &net.Dialer{Timeout: hostKeyScanTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(hostKeyScanTimeout))
+
+	var hostKey ssh.PublicKey
+	_, _, _, err = ssh.NewClientConn(conn, address, &ssh.ClientConfig{
+		User: "trento",
+		HostKeyCallback: func(_ string, _ net.Addr, key ssh.PublicKey) error {
+			hostKey = key
+			return errHostKeyScanned
+		},
+		Timeout: hostKeyScanTimeout,
+	})
+	if hostKey != nil {
+		return hostKey, nil
+	}
+
+	return nil, err
+}
+
+// hostKeyCallback returns the ssh host key callback of the native engine, verifying the keys with the known hosts
+// file if the host key checking is enabled. The new hosts are already added to the file by the runner
+func hostKeyCallback(config *Config) ssh.HostKeyCallback {
+	if config.SSHHostKeyChecking == "" || config.SSHHostKeyChecking == HostKeyCheckingDisabled {
+		return ssh.InsecureIgnoreHostKey()
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		// The file is read on each connection, as the runner adds the accepted keys to it
+		callback, err := knownhosts.New(knownHostsFile(config))
+		if err != nil {
+			return err
+		}
+		return callback(hostname, remote, key)
+	}
+}
+
+// sshHostKeyArgs returns the ssh options verifying the hosts keys with the known hosts file, for the ansible engine
+func sshHostKeyArgs(config *Config) string {
+	strictHostKeyChecking := "yes"
+	if config.SSHHostKeyChecking == HostKeyCheckingTOFU {
+		strictHostKeyChecking = "accept-new"
+	}
+
+	return fmt.Sprintf("-o StrictHostKeyChecking=%s -o UserKnownHostsFile=%s", strictHostKeyChecking, knownHostsFile(config))
+}
+
+// hostAddr is the remote address of a host verified out of an ssh connection
+type hostAddr string
+
+func (a hostAddr) Network() string { return "tcp" }
+func (a hostAddr) String() string  { return string(a) }
+
+func containsString(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,53 @@
+package runner
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+type hostKeyAcceptRequest struct {
+	Host        string `json:"host" binding:"required"`
+	Fingerprint string `json:"fingerprint" binding:"required"`
+}
+
+// HostKeysHandler lists the hosts keys waiting to be accepted, the unknown ones in the strict host key checking
+// and the changed ones
+func HostKeysHandler(hostKeys *HostKeys) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if hostKeys == nil {
+			c.AbortWithStatusJSON(404, gin.H{"status": "nok", "message": "host key checking is disabled"})
+			return
+		}
+
+		c.JSON(200, hostKeys.Pending())
+	}
+}
+
+// HostKeyAcceptHandler adds the pending key of the host to the known hosts file, replacing its old keys
+func HostKeyAcceptHandler(hostKeys *HostKeys) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if hostKeys == nil {
+			c.AbortWithStatusJSON(404, gin.H{"status": "nok", "message": "host key checking is disabled"})
+			return
+		}
+
+		var request hostKeyAcceptRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.AbortWithStatusJSON(400, gin.H{"status": "nok", "message": err.Error()})
+			return
+		}
+
+		err := hostKeys.Accept(request.Host, request.Fingerprint)
+		if errors.Is(err, ErrHostKeyNotPending) {
+			c.AbortWithStatusJSON(404, gin.H{"status": "nok", "message": err.Error()})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(500, gin.H{"status": "nok", "message": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{"status": "ok"})
+	}
+}
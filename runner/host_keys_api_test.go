@@ -0,0 +1,74 @@
+package runner
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func hostKeysTestApp(t *testing.T, hostKeys *HostKeys) *App {
+	deps := setupTestDependencies()
+	deps.hostKeys = hostKeys
+
+	app, err := NewAppWithDeps(&Config{}, deps)
+	assert.NoError(t, err)
+
+	return app
+}
+
+func TestHostKeysApi(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	hostKeys, _ := NewHostKeys(&Config{AnsibleFolder: tmpDir, SSHHostKeyChecking: HostKeyCheckingStrict})
+	key := newTestHostKey(t).PublicKey()
+	hostKeys.Verify("192.168.10.1:22", key)
+	app := hostKeysTestApp(t, hostKeys)
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/host_keys", nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	var pending []*PendingHostKey
+	json.Unmarshal(resp.Body.Bytes(), &pending)
+	assert.Equal(t, 200, resp.Code)
+	assert.Len(t, pending, 1)
+	assert.Equal(t, "192.168.10.1", pending[0].Host)
+	assert.Equal(t, ssh.KeyAlgoED25519, pending[0].KeyType)
+
+	resp = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/api/host_keys/accept",
+		strings.NewReader(`{"host": "192.168.10.1", "fingerprint": "SHA256:other"}`))
+	app.webEngine.ServeHTTP(resp, req)
+	assert.Equal(t, 404, resp.Code)
+	assert.JSONEq(t, `{"status": "nok", "message": "The host key is not pending"}`, resp.Body.String())
+
+	resp = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/api/host_keys/accept",
+		strings.NewReader(`{"host": "192.168.10.1", "fingerprint": "`+ssh.FingerprintSHA256(key)+`"}`))
+	app.webEngine.ServeHTTP(resp, req)
+	assert.Equal(t, 200, resp.Code)
+	assert.NoError(t, hostKeys.Verify("192.168.10.1:22", key))
+
+	resp = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/api/host_keys/accept", strings.NewReader(`{"host": "192.168.10.1"}`))
+	app.webEngine.ServeHTTP(resp, req)
+	assert.Equal(t, 400, resp.Code)
+}
+
+func TestHostKeysApi_Disabled(t *testing.T) {
+	app := hostKeysTestApp(t, nil)
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/host_keys", nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	assert.Equal(t, 404, resp.Code)
+	assert.JSONEq(t, `{"status": "nok", "message": "host key checking is disabled"}`, resp.Body.String())
+}
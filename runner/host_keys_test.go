@@ -0,0 +1,173 @@
+package runner
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func newTestHostKey(t *testing.T) ssh.Signer {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	assert.NoError(t, err)
+
+	return signer
+}
+
+// startTestSSHServer accepts ssh connections presenting the given host key, without authentication
+func startTestSSHServer(t *testing.T, hostKey ssh.Signer) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(hostKey)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				ssh.NewServerConn(conn, config)
+				conn.Close()
+			}()
+		}
+	}()
+
+	return listener
+}
+
+func TestHostKeys_Strict(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	hostKeys, err := NewHostKeys(&Config{AnsibleFolder: tmpDir, SSHHostKeyChecking: HostKeyCheckingStrict})
+	assert.NoError(t, err)
+	assert.FileExists(t, path.Join(tmpDir, DefaultKnownHostsFile))
+
+	key := newTestHostKey(t).PublicKey()
+	err = hostKeys.Verify("192.168.10.1:22", key)
+	assert.EqualError(t, err, "Host key verification failed: the host key of 192.168.10.1 is unknown, it must be accepted")
+
+	pending := hostKeys.Pending()
+	assert.Len(t, pending, 1)
+	assert.Equal(t, "192.168.10.1", pending[0].Host)
+	assert.Equal(t, HostKeyUnknown, pending[0].Reason)
+	assert.Equal(t, ssh.FingerprintSHA256(key), pending[0].Fingerprint)
+
+	// Only the reviewed key is accepted
+	assert.Equal(t, ErrHostKeyNotPending, hostKeys.Accept("192.168.10.1", "SHA256:other"))
+	assert.NoError(t, hostKeys.Accept("192.168.10.1", pending[0].Fingerprint))
+	assert.Empty(t, hostKeys.Pending())
+	assert.NoError(t, hostKeys.Verify("192.168.10.1:22", key))
+
+	// The changed key replaces the old one once it is accepted
+	newKey := newTestHostKey(t).PublicKey()
+	err = hostKeys.Verify("192.168.10.1:22", newKey)
+	assert.EqualError(t, err, "Host key verification failed: the host key of 192.168.10.1 changed, it must be accepted if the change is expected")
+	assert.Equal(t, HostKeyMismatch, hostKeys.Pending()[0].Reason)
+
+	assert.NoError(t, hostKeys.Accept("192.168.10.1", ssh.FingerprintSHA256(newKey)))
+	assert.NoError(t, hostKeys.Verify("192.168.10.1:22", newKey))
+	assert.Error(t, hostKeys.Verify("192.168.10.1:22", key))
+
+	content, _ := ioutil.ReadFile(path.Join(tmpDir, DefaultKnownHostsFile))
+	assert.Equal(t, "192.168.10.1 "+string(ssh.MarshalAuthorizedKey(newKey)), string(content))
+}
+
+func TestHostKeys_TOFU(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	knownHosts := path.Join(tmpDir, "ssh/known_hosts")
+	hostKeys, err := NewHostKeys(&Config{SSHHostKeyChecking: HostKeyCheckingTOFU, SSHKnownHostsFile: knownHosts})
+	assert.NoError(t, err)
+
+	// The new hosts are trusted and added to the file, but their keys cannot change
+	key := newTestHostKey(t).PublicKey()
+	assert.NoError(t, hostKeys.Verify("[fd00::1]:2222", key))
+	assert.NoError(t, hostKeys.Verify("[fd00::1]:2222", key))
+	assert.Error(t, hostKeys.Verify("[fd00::1]:2222", newTestHostKey(t).PublicKey()))
+
+	content, _ := ioutil.ReadFile(knownHosts)
+	assert.Equal(t, "[fd00::1]:2222 "+string(ssh.MarshalAuthorizedKey(key)), string(content))
+}
+
+func TestNewHostKeys_Disabled(t *testing.T) {
+	hostKeys, err := NewHostKeys(&Config{SSHHostKeyChecking: HostKeyCheckingDisabled})
+	assert.NoError(t, err)
+	assert.Nil(t, hostKeys)
+}
+
+func TestVerifyHostKeys(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	hostKey := newTestHostKey(t)
+	listener := startTestSSHServer(t, hostKey)
+	defer listener.Close()
+
+	hostKeys, _ := NewHostKeys(&Config{AnsibleFolder: tmpDir, SSHHostKeyChecking: HostKeyCheckingTOFU})
+	host := &Host{HostID: uuid.New(), Address: listener.Addr().String()}
+	windowsHost := &Host{HostID: uuid.New(), Address: "192.168.10.2", Platform: PlatformWindows}
+	execution := &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Checks:      []string{"156F64", "53D035"},
+		Hosts:       []*Host{host, windowsHost},
+	}
+
+	// The key is trusted on first use
+	trusted, untrusted := hostKeys.verifyHostKeys(context.Background(), execution, "")
+	assert.Equal(t, []*Host{host, windowsHost}, trusted.Hosts)
+	assert.Empty(t, untrusted.Hosts)
+	content, _ := ioutil.ReadFile(path.Join(tmpDir, DefaultKnownHostsFile))
+	assert.Equal(t, knownHostsLine(t, listener, hostKey), string(content))
+
+	// The host with a changed key is not checked, and its checks report the mismatch
+	listener.Close()
+	changedListener := startTestSSHServer(t, newTestHostKey(t))
+	defer changedListener.Close()
+	ioutil.WriteFile(path.Join(tmpDir, DefaultKnownHostsFile), []byte(knownHostsLine(t, changedListener, hostKey)), 0600)
+	host.Address = changedListener.Addr().String()
+
+	trusted, untrusted = hostKeys.verifyHostKeys(context.Background(), execution, "")
+	assert.Equal(t, []*Host{windowsHost}, trusted.Hosts)
+	assert.Len(t, untrusted.Hosts, 1)
+	assert.False(t, untrusted.Hosts[0].Reachable)
+	assert.Contains(t, untrusted.Hosts[0].Msg, "Host key verification failed")
+	assert.Len(t, untrusted.Hosts[0].Results, 2)
+	assert.Equal(t, checkResultHostKeyMismatch, untrusted.Hosts[0].Results[0].Result)
+}
+
+// knownHostsLine returns the known hosts line of the listener address with the given key
+func knownHostsLine(t *testing.T, listener net.Listener, key ssh.Signer) string {
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	assert.NoError(t, err)
+
+	return "[" + host + "]:" + port + " " + string(ssh.MarshalAuthorizedKey(key.PublicKey()))
+}
+
+func TestCreateAnsibleConfig_HostKeyChecking(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	destination := path.Join(tmpDir, "ansible/ansible.cfg")
+	config := &Config{AnsibleFolder: tmpDir, SSHHostKeyChecking: HostKeyCheckingTOFU}
+	assert.NoError(t, CreateAnsibleConfig(destination, NewAnsibleConfigContent(config)))
+
+	content, _ := ioutil.ReadFile(destination)
+	assert.Contains(t, string(content), "host_key_checking = True\n")
+	assert.Contains(t, string(content), "-o ControlPersist=300s -o StrictHostKeyChecking=accept-new -o UserKnownHostsFile="+
+		path.Join(tmpDir, DefaultKnownHostsFile)+"\n")
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"regexp"
 	"text/template"
 
 	log "github.com/sirupsen/logrus"
@@ -18,6 +19,10 @@ type InventoryContent struct {
 type Group struct {
 	Name  string
 	Nodes []*Node
+	// Hosts are the names of the group nodes already defined in another group
+	Hosts []string
+	// Variables are written in the group vars section, and are available in all the group nodes
+	Variables map[string]interface{}
 }
 
 type Node struct {
@@ -36,20 +41,38 @@ const (
 {{- range .Nodes }}
 {{ .Name }} ansible_host={{ .AnsibleHost }} ansible_user={{ .AnsibleUser }} {{ range $key, $value := .Variables }}{{ $key }}={{ $value }} {{ end }}
 {{- end }}
+{{- range .Hosts }}
+{{ . }}
+{{- end }}
+{{- if .Variables }}
+[{{ .Name }}:vars]
+{{- range $key, $value := .Variables }}
+{{ $key }}={{ $value }}
+{{- end }}
+{{- end }}
 {{- end }}
 `
 	clusterSelectedChecks string = "cluster_selected_checks"
+	clusterID             string = "cluster_id"
+	nodeRole              string = "node_role"
+	nodePlatform          string = "node_platform"
 	provider              string = "provider"
+	sshExtraArgs          string = "ansible_ssh_extra_args"
+	sshAgentForwardingArg string = "-o ForwardAgent=yes"
 )
 
+// The provider is only used as group name if it is a valid one
+var validGroupName = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
 func CreateInventory(destination string, content *InventoryContent) error {
 	t := template.Must(template.New("").Parse(inventoryTemplate))
 
-	if err := os.MkdirAll(path.Dir(destination), 0755); err != nil {
+	// The inventory has the hosts addresses and users, so it is only readable by the runner user
+	if err := os.MkdirAll(path.Dir(destination), 0700); err != nil {
 		return err
 	}
 
-	f, err := os.Create(destination)
+	f, err := os.OpenFile(destination, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
 		return err
 	}
@@ -66,27 +89,123 @@ func NewClusterInventoryContent(e *ExecutionEvent) (*InventoryContent, error) {
 
 	nodes := []*Node{}
 
-	jsonChecks, err := json.Marshal(e.Checks)
-	if err != nil {
-		log.Errorf("error marshalling the cluster %s selected checks: %s", e.ClusterID.String(), err)
-	}
-
 	for _, host := range e.Hosts {
+		jsonChecks, err := json.Marshal(e.hostChecks(host))
+		if err != nil {
+			log.Errorf("error marshalling the cluster %s selected checks: %s", e.ClusterID.String(), err)
+		}
+
+		// The addresses resolved in the cloud provider are not validated with the request, so they are checked here
+		address, port, err := parseHostAddress(host.Address)
+		if err != nil {
+			return nil, fmt.Errorf("host %s %s", host.HostID.String(), err)
+		}
+
 		node := &Node{
 			Name:        host.HostID.String(),
-			AnsibleHost: host.Address,
+			AnsibleHost: address,
 			AnsibleUser: host.User,
 			Variables:   make(map[string]interface{}),
 		}
+		if port != "" {
+			node.Variables[ansiblePort] = port
+		}
 
 		node.Variables[clusterSelectedChecks] = fmt.Sprintf("'%s'", string(jsonChecks))
-		node.Variables[provider] = e.Provider
+		if e.hasKnownProvider() {
+			node.Variables[provider] = e.Provider
+		}
+		// The windows hosts are connected with winrm, the rest of hosts use the default ssh connection
+		if host.isWindows() {
+			node.Variables[nodePlatform] = PlatformWindows
+			node.Variables[ansibleConnection] = winrmConnection
+		}
 
 		nodes = append(nodes, node)
 	}
-	group := &Group{Name: e.ClusterID.String(), Nodes: nodes}
+	group := &Group{
+		Name:      e.ClusterID.String(),
+		Nodes:     nodes,
+		Variables: map[string]interface{}{clusterID: e.ClusterID.String()},
+	}
 
 	content.Groups = append(content.Groups, group)
 
+	// The nodes are grouped by provider and role as well, so the checks can target the nodes they apply to
+	// The group of the detected provider is created by the playbook
+	if e.hasKnownProvider() && validGroupName.MatchString(e.Provider) {
+		content.Groups = append(content.Groups, &Group{Name: e.Provider, Hosts: hostsWithRole(e, "")})
+	}
+
+	for _, role := range []string{HanaPrimaryRole, HanaSecondaryRole, MajorityMakerRole} {
+		if hosts := hostsWithRole(e, role); len(hosts) > 0 {
+			content.Groups = append(content.Groups, &Group{
+				Name:      role,
+				Hosts:     hosts,
+				Variables: map[string]interface{}{nodeRole: role},
+			})
+		}
+	}
+
+	if hosts := windowsHosts(e); len(hosts) > 0 {
+		content.Groups = append(content.Groups, &Group{Name: PlatformWindows, Hosts: hosts})
+	}
+
 	return content, nil
 }
+
+// windowsHosts returns the names of the execution windows hosts
+func windowsHosts(e *ExecutionEvent) []string {
+	hosts := []string{}
+	for _, host := range e.Hosts {
+		if host.isWindows() {
+			hosts = append(hosts, host.HostID.String())
+		}
+	}
+
+	return hosts
+}
+
+// hostsWithRole returns the names of the execution hosts with the given role, or all of them if the role is empty
+func hostsWithRole(e *ExecutionEvent, role string) []string {
+	hosts := []string{}
+	for _, host := range e.Hosts {
+		if role == "" || host.Role == role {
+			hosts = append(hosts, host.HostID.String())
+		}
+	}
+
+	return hosts
+}
+
+// getNode returns the node with the given name, in any of the inventory groups
+func (i *InventoryContent) getNode(name string) *Node {
+	for _, node := range i.Nodes {
+		if node.Name == name {
+			return node
+		}
+	}
+
+	for _, group := range i.Groups {
+		for _, node := range group.Nodes {
+			if node.Name == name {
+				return node
+			}
+		}
+	}
+
+	return nil
+}
+
+// SetNodesVariable sets the same variable in all the inventory nodes
+func (i *InventoryContent) SetNodesVariable(name string, value interface{}) {
+	for _, node := range i.Nodes {
+		node.Variables[name] = value
+	}
+
+	for _, group := range i.Groups {
+		for _, node := range group.Nodes {
+			node.Variables[name] = value
+		}
+	}
+}
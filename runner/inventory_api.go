@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// InventoryHandler returns the last inventory generated, or the last one of the cluster_id query cluster,
+// without the credentials
+func InventoryHandler(inventories *InventoryAudit) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clusterID, ok := inventoryClusterQuery(c)
+		if !ok {
+			return
+		}
+
+		var snapshot *InventorySnapshot
+		if inventories != nil {
+			snapshot = inventories.Last(clusterID)
+		}
+		if snapshot == nil {
+			c.AbortWithStatusJSON(404, gin.H{"status": "nok", "message": "inventory not generated yet"})
+			return
+		}
+
+		c.JSON(200, snapshot)
+	}
+}
+
+// InventoryChangesHandler returns the audit log of the hosts added to and removed from the inventories,
+// of the cluster_id query cluster if it is set
+func InventoryChangesHandler(inventories *InventoryAudit) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clusterID, ok := inventoryClusterQuery(c)
+		if !ok {
+			return
+		}
+
+		changes := []*InventoryChange{}
+		if inventories != nil {
+			changes = inventories.Changes(clusterID)
+		}
+
+		c.JSON(200, changes)
+	}
+}
+
+func inventoryClusterQuery(c *gin.Context) (*uuid.UUID, bool) {
+	query := c.Query("cluster_id")
+	if query == "" {
+		return nil, true
+	}
+
+	clusterID, err := uuid.Parse(query)
+	if err != nil {
+		c.AbortWithStatusJSON(400, gin.H{"status": "nok", "message": "invalid cluster id"})
+		return nil, false
+	}
+
+	return &clusterID, true
+}
@@ -0,0 +1,52 @@
+package runner
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInventoryApi(t *testing.T) {
+	event := &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Checks:      []string{"156F64"},
+		Hosts:       []*Host{{HostID: uuid.New(), Address: "192.168.10.1", User: "root"}},
+	}
+	inventories := NewInventoryAudit()
+	deps := setupTestDependencies()
+	deps.inventories = inventories
+	app, err := NewAppWithDeps(&Config{}, deps)
+	assert.NoError(t, err)
+
+	resp := httptest.NewRecorder()
+	app.webEngine.ServeHTTP(resp, httptest.NewRequest("GET", "/api/inventory", nil))
+	assert.Equal(t, 404, resp.Code)
+	assert.JSONEq(t, `{"status": "nok", "message": "inventory not generated yet"}`, resp.Body.String())
+
+	content, _ := NewClusterInventoryContent(event)
+	inventories.Record(event, content)
+
+	resp = httptest.NewRecorder()
+	app.webEngine.ServeHTTP(resp, httptest.NewRequest("GET", "/api/inventory?cluster_id="+event.ClusterID.String(), nil))
+	var snapshot InventorySnapshot
+	json.Unmarshal(resp.Body.Bytes(), &snapshot)
+	assert.Equal(t, 200, resp.Code)
+	assert.Equal(t, event.ExecutionID, snapshot.ExecutionID)
+	assert.Equal(t, "192.168.10.1", snapshot.Hosts[0].Address)
+
+	resp = httptest.NewRecorder()
+	app.webEngine.ServeHTTP(resp, httptest.NewRequest("GET", "/api/inventory/changes", nil))
+	var changes []*InventoryChange
+	json.Unmarshal(resp.Body.Bytes(), &changes)
+	assert.Equal(t, 200, resp.Code)
+	assert.Len(t, changes, 1)
+	assert.Equal(t, []string{event.Hosts[0].HostID.String()}, changes[0].Added)
+
+	resp = httptest.NewRecorder()
+	app.webEngine.ServeHTTP(resp, httptest.NewRequest("GET", "/api/inventory/changes?cluster_id=other", nil))
+	assert.Equal(t, 400, resp.Code)
+}
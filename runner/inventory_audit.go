@@ -0,0 +1,200 @@
+package runner
+
+import (
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The audit log keeps the inventory changes of the last executions only
+const inventoryAuditLogSize = 500
+
+// The inventory variables with these names are masked in the snapshots, as they might have credentials
+var sensitiveInventoryVariable = regexp.MustCompile(`(?i)pass|secret|token|key`)
+
+// InventorySnapshot is the inventory generated for an execution, without the credentials
+type InventorySnapshot struct {
+	ExecutionID uuid.UUID         `json:"execution_id"`
+	ClusterID   uuid.UUID         `json:"cluster_id"`
+	GeneratedAt time.Time         `json:"generated_at"`
+	Hosts       []*InventoryHost  `json:"hosts"`
+	Groups      []*InventoryGroup `json:"groups"`
+}
+
+type InventoryHost struct {
+	Name      string                 `json:"name"`
+	Address   string                 `json:"address"`
+	User      string                 `json:"user"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+type InventoryGroup struct {
+	Name      string                 `json:"name"`
+	Hosts     []string               `json:"hosts"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+// InventoryChange are the hosts added to and removed from the inventory of a cluster, compared to its previous one
+type InventoryChange struct {
+	ExecutionID uuid.UUID `json:"execution_id"`
+	ClusterID   uuid.UUID `json:"cluster_id"`
+	ChangedAt   time.Time `json:"changed_at"`
+	Added       []string  `json:"added"`
+	Removed     []string  `json:"removed"`
+}
+
+// InventoryAudit keeps the last inventory generated for each cluster, and the rolling log of their changes,
+// so the operators can verify the hosts the runner acts on
+type InventoryAudit struct {
+	mu       sync.Mutex
+	last     *InventorySnapshot
+	clusters map[uuid.UUID]*InventorySnapshot
+	changes  []*InventoryChange
+}
+
+func NewInventoryAudit() *InventoryAudit {
+	return &InventoryAudit{clusters: make(map[uuid.UUID]*InventorySnapshot)}
+}
+
+// Record stores the inventory of the execution, adding its hosts changes to the log if there are any
+func (a *InventoryAudit) Record(e *ExecutionEvent, content *InventoryContent) {
+	snapshot := newInventorySnapshot(e, content)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	previousHosts := []string{}
+	if previous, ok := a.clusters[e.ClusterID]; ok {
+		previousHosts = previous.hostNames()
+	}
+	added, removed := diffHosts(previousHosts, snapshot.hostNames())
+	if len(added) > 0 || len(removed) > 0 {
+		a.changes = append(a.changes, &InventoryChange{
+			ExecutionID: e.ExecutionID,
+			ClusterID:   e.ClusterID,
+			ChangedAt:   snapshot.GeneratedAt,
+			Added:       added,
+			Removed:     removed,
+		})
+		if len(a.changes) > inventoryAuditLogSize {
+			a.changes = a.changes[len(a.changes)-inventoryAuditLogSize:]
+		}
+	}
+
+	a.last = snapshot
+	a.clusters[e.ClusterID] = snapshot
+}
+
+// Last returns the last inventory generated, of the given cluster if it is set
+func (a *InventoryAudit) Last(clusterID *uuid.UUID) *InventorySnapshot {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if clusterID != nil {
+		return a.clusters[*clusterID]
+	}
+
+	return a.last
+}
+
+// Changes returns the logged inventory changes, the most recent first, of the given cluster if it is set
+func (a *InventoryAudit) Changes(clusterID *uuid.UUID) []*InventoryChange {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	changes := []*InventoryChange{}
+	for index := len(a.changes) - 1; index >= 0; index-- {
+		if clusterID == nil || a.changes[index].ClusterID == *clusterID {
+			changes = append(changes, a.changes[index])
+		}
+	}
+
+	return changes
+}
+
+func newInventorySnapshot(e *ExecutionEvent, content *InventoryContent) *InventorySnapshot {
+	snapshot := &InventorySnapshot{
+		ExecutionID: e.ExecutionID,
+		ClusterID:   e.ClusterID,
+		GeneratedAt: time.Now().UTC(),
+		Hosts:       []*InventoryHost{},
+		Groups:      []*InventoryGroup{},
+	}
+
+	for _, group := range content.Groups {
+		inventoryGroup := &InventoryGroup{
+			Name:      group.Name,
+			Hosts:     append([]string{}, group.Hosts...),
+			Variables: sanitizedVariables(group.Variables),
+		}
+		for _, node := range group.Nodes {
+			snapshot.Hosts = append(snapshot.Hosts, &InventoryHost{
+				Name:      node.Name,
+				Address:   node.AnsibleHost,
+				User:      node.AnsibleUser,
+				Variables: sanitizedVariables(node.Variables),
+			})
+			inventoryGroup.Hosts = append(inventoryGroup.Hosts, node.Name)
+		}
+		snapshot.Groups = append(snapshot.Groups, inventoryGroup)
+	}
+
+	return snapshot
+}
+
+func (s *InventorySnapshot) hostNames() []string {
+	names := make([]string, 0, len(s.Hosts))
+	for _, host := range s.Hosts {
+		names = append(names, host.Name)
+	}
+
+	return names
+}
+
+func sanitizedVariables(variables map[string]interface{}) map[string]interface{} {
+	if len(variables) == 0 {
+		return nil
+	}
+
+	sanitized := make(map[string]interface{}, len(variables))
+	for name, value := range variables {
+		if sensitiveInventoryVariable.MatchString(name) {
+			value = "********"
+		}
+		sanitized[name] = value
+	}
+
+	return sanitized
+}
+
+// diffHosts returns the hosts added and removed from the previous ones, sorted by name
+func diffHosts(previous, current []string) ([]string, []string) {
+	previousSet := make(map[string]bool, len(previous))
+	for _, host := range previous {
+		previousSet[host] = true
+	}
+	currentSet := make(map[string]bool, len(current))
+	for _, host := range current {
+		currentSet[host] = true
+	}
+
+	added := []string{}
+	for _, host := range current {
+		if !previousSet[host] {
+			added = append(added, host)
+		}
+	}
+	removed := []string{}
+	for _, host := range previous {
+		if !currentSet[host] {
+			removed = append(removed, host)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+
+	return added, removed
+}
@@ -0,0 +1,60 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInventoryAudit(t *testing.T) {
+	host1 := &Host{HostID: uuid.New(), Address: "192.168.10.1", User: "root"}
+	host2 := &Host{HostID: uuid.New(), Address: "192.168.10.2:2222", User: "root", Role: HanaPrimaryRole}
+	host3 := &Host{HostID: uuid.New(), Address: "192.168.10.3", User: "root"}
+	event := &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Provider:    "azure",
+		Checks:      []string{"156F64"},
+		Hosts:       []*Host{host1, host2},
+	}
+	audit := NewInventoryAudit()
+	assert.Nil(t, audit.Last(nil))
+
+	content, _ := NewClusterInventoryContent(event)
+	content.getNode(host1.HostID.String()).Variables[ansiblePassword] = "secret"
+	audit.Record(event, content)
+
+	snapshot := audit.Last(nil)
+	assert.Equal(t, event.ExecutionID, snapshot.ExecutionID)
+	assert.Len(t, snapshot.Hosts, 2)
+	assert.Equal(t, "192.168.10.2", snapshot.Hosts[1].Address)
+	assert.Equal(t, "2222", snapshot.Hosts[1].Variables[ansiblePort])
+	assert.Equal(t, "********", snapshot.Hosts[0].Variables[ansiblePassword])
+	assert.Equal(t, "secret", content.getNode(host1.HostID.String()).Variables[ansiblePassword])
+	assert.Equal(t, &InventoryGroup{Name: HanaPrimaryRole, Hosts: []string{host2.HostID.String()},
+		Variables: map[string]interface{}{nodeRole: HanaPrimaryRole}}, snapshot.Groups[2])
+
+	// The same hosts are not logged again, the replaced ones are
+	audit.Record(event, content)
+	nextEvent := *event
+	nextEvent.ExecutionID = uuid.New()
+	nextEvent.Hosts = []*Host{host1, host3}
+	content, _ = NewClusterInventoryContent(&nextEvent)
+	audit.Record(&nextEvent, content)
+
+	changes := audit.Changes(nil)
+	assert.Len(t, changes, 2)
+	assert.Equal(t, nextEvent.ExecutionID, changes[0].ExecutionID)
+	assert.Equal(t, []string{host3.HostID.String()}, changes[0].Added)
+	assert.Equal(t, []string{host2.HostID.String()}, changes[0].Removed)
+	assert.Equal(t, event.ExecutionID, changes[1].ExecutionID)
+	assert.Len(t, changes[1].Added, 2)
+	assert.Empty(t, changes[1].Removed)
+
+	otherCluster := uuid.New()
+	assert.Nil(t, audit.Last(&otherCluster))
+	assert.Empty(t, audit.Changes(&otherCluster))
+	assert.Len(t, audit.Changes(&event.ClusterID), 2)
+	assert.Equal(t, nextEvent.ExecutionID, audit.Last(&event.ClusterID).ExecutionID)
+}
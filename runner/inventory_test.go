@@ -1,6 +1,7 @@
 package runner
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
@@ -66,6 +67,11 @@ func (suite *InventoryTestSuite) Test_CreateInventory() {
 					},
 				},
 			},
+			&Group{
+				Name:      "group3",
+				Hosts:     []string{"node3", "node5"},
+				Variables: map[string]interface{}{"key3": "value3"},
+			},
 		},
 	}
 
@@ -83,7 +89,12 @@ func (suite *InventoryTestSuite) Test_CreateInventory() {
 		"node4 ansible_host= ansible_user= \n" +
 		"[group2]\n" +
 		"node5 ansible_host= ansible_user= \n" +
-		"node6 ansible_host= ansible_user= \n"
+		"node6 ansible_host= ansible_user= \n" +
+		"[group3]\n" +
+		"node3\n" +
+		"node5\n" +
+		"[group3:vars]\n" +
+		"key3=value3\n"
 
 	data, err := ioutil.ReadFile(destination)
 	if err == nil {
@@ -91,6 +102,21 @@ func (suite *InventoryTestSuite) Test_CreateInventory() {
 	}
 }
 
+func (suite *InventoryTestSuite) Test_CreateInventory_Permissions() {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+	destination := path.Join(tmpDir, "execution/ansible_hosts")
+
+	suite.NoError(CreateInventory(destination, &InventoryContent{}))
+
+	info, err := os.Stat(destination)
+	suite.NoError(err)
+	suite.Equal(os.FileMode(0600), info.Mode().Perm())
+	info, err = os.Stat(path.Dir(destination))
+	suite.NoError(err)
+	suite.Equal(os.FileMode(0700), info.Mode().Perm())
+}
+
 func (suite *InventoryTestSuite) Test_NewClusterInventoryContent() {
 	cluster := uuid.New()
 	host1 := uuid.New()
@@ -141,6 +167,11 @@ func (suite *InventoryTestSuite) Test_NewClusterInventoryContent() {
 						AnsibleUser: "user2",
 					},
 				},
+				Variables: map[string]interface{}{"cluster_id": cluster.String()},
+			},
+			&Group{
+				Name:  "azure",
+				Hosts: []string{host1.String(), host2.String()},
 			},
 		},
 	}
@@ -148,3 +179,114 @@ func (suite *InventoryTestSuite) Test_NewClusterInventoryContent() {
 	suite.NoError(err)
 	suite.ElementsMatch(expectedContent.Groups, content.Groups)
 }
+
+func (suite *InventoryTestSuite) Test_NewClusterInventoryContent_UnknownProvider() {
+	host := uuid.New()
+
+	executionEvent := &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Provider:    "unknown",
+		Checks:      []string{"check1"},
+		Hosts:       []*Host{{HostID: host, Address: "192.168.10.1", User: "user1"}},
+	}
+
+	content, err := NewClusterInventoryContent(executionEvent)
+
+	// The playbook detects the provider and groups the hosts by it
+	suite.NoError(err)
+	suite.Len(content.Groups, 1)
+	suite.NotContains(content.getNode(host.String()).Variables, "provider")
+}
+
+func (suite *InventoryTestSuite) Test_NewClusterInventoryContent_Roles() {
+	host1 := uuid.New()
+	host2 := uuid.New()
+	host3 := uuid.New()
+
+	executionEvent := &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Provider:    "invalid provider",
+		Checks:      []string{"check1"},
+		Hosts: []*Host{
+			{HostID: host1, Address: "192.168.10.1", User: "user1", Role: HanaSecondaryRole},
+			{HostID: host2, Address: "192.168.10.2", User: "user1", Role: HanaPrimaryRole},
+			{HostID: host3, Address: "192.168.10.3", User: "user1", Role: MajorityMakerRole},
+		},
+	}
+
+	content, err := NewClusterInventoryContent(executionEvent)
+
+	suite.NoError(err)
+	suite.Len(content.Groups, 4)
+	suite.Equal(executionEvent.ClusterID.String(), content.Groups[0].Name)
+	suite.Equal(&Group{
+		Name:      "hana_primary",
+		Hosts:     []string{host2.String()},
+		Variables: map[string]interface{}{"node_role": "hana_primary"},
+	}, content.Groups[1])
+	suite.Equal(&Group{
+		Name:      "hana_secondary",
+		Hosts:     []string{host1.String()},
+		Variables: map[string]interface{}{"node_role": "hana_secondary"},
+	}, content.Groups[2])
+	suite.Equal(&Group{
+		Name:      "majority_maker",
+		Hosts:     []string{host3.String()},
+		Variables: map[string]interface{}{"node_role": "majority_maker"},
+	}, content.Groups[3])
+}
+
+func (suite *InventoryTestSuite) Test_NewClusterInventoryContent_Windows() {
+	linuxHost := uuid.New()
+	windowsHost := uuid.New()
+
+	executionEvent := &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Provider:    "azure",
+		Checks:      []string{"check1"},
+		Hosts: []*Host{
+			{HostID: linuxHost, Address: "192.168.10.1", User: "user1", Platform: PlatformLinux},
+			{HostID: windowsHost, Address: "192.168.10.2", User: "Administrator", Platform: PlatformWindows},
+		},
+	}
+
+	content, err := NewClusterInventoryContent(executionEvent)
+
+	suite.NoError(err)
+	suite.Len(content.Groups, 3)
+	suite.NotContains(content.getNode(linuxHost.String()).Variables, "ansible_connection")
+	suite.NotContains(content.getNode(linuxHost.String()).Variables, "node_platform")
+	suite.Equal("winrm", content.getNode(windowsHost.String()).Variables["ansible_connection"])
+	suite.Equal("windows", content.getNode(windowsHost.String()).Variables["node_platform"])
+	suite.Equal(&Group{Name: "windows", Hosts: []string{windowsHost.String()}}, content.Groups[2])
+}
+
+func (suite *InventoryTestSuite) Test_NewClusterInventoryContent_Addresses() {
+	ipv6Host := uuid.New()
+	namedHost := uuid.New()
+
+	executionEvent := &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Checks:      []string{"check1"},
+		Hosts: []*Host{
+			{HostID: ipv6Host, Address: "[fd00::1]:2222", User: "user1"},
+			{HostID: namedHost, Address: "vmhana02.example.com", User: "user1"},
+		},
+	}
+
+	content, err := NewClusterInventoryContent(executionEvent)
+
+	suite.NoError(err)
+	suite.Equal("fd00::1", content.getNode(ipv6Host.String()).AnsibleHost)
+	suite.Equal("2222", content.getNode(ipv6Host.String()).Variables["ansible_port"])
+	suite.Equal("vmhana02.example.com", content.getNode(namedHost.String()).AnsibleHost)
+	suite.NotContains(content.getNode(namedHost.String()).Variables, "ansible_port")
+
+	executionEvent.Hosts[1].Address = "vmhana02:ssh"
+	_, err = NewClusterInventoryContent(executionEvent)
+	suite.EqualError(err, fmt.Sprintf("host %s address vmhana02:ssh port ssh is not valid", namedHost.String()))
+}
@@ -0,0 +1,32 @@
+package runner
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type loggerContextKey struct{}
+
+// executionLogger returns a logger adding the execution and cluster IDs to every line,
+// so the logs of an execution can be correlated
+func executionLogger(e *ExecutionEvent) *log.Entry {
+	return log.WithFields(log.Fields{
+		"execution_id": e.ExecutionID.String(),
+		"cluster_id":   e.ClusterID.String(),
+	})
+}
+
+// withLogger returns a copy of the context carrying the given logger
+func withLogger(ctx context.Context, logger *log.Entry) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+// loggerFromContext returns the logger carried by the context, or the standard one
+func loggerFromContext(ctx context.Context) *log.Entry {
+	if logger, ok := ctx.Value(loggerContextKey{}).(*log.Entry); ok {
+		return logger
+	}
+
+	return log.NewEntry(log.StandardLogger())
+}
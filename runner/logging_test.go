@@ -0,0 +1,43 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecutionLogger(t *testing.T) {
+	var output bytes.Buffer
+	logger := log.New()
+	logger.SetOutput(&output)
+	logger.SetFormatter(&log.JSONFormatter{})
+
+	executionID := uuid.New()
+	clusterID := uuid.New()
+	ctx := withLogger(context.Background(), executionLogger(&ExecutionEvent{
+		ExecutionID: executionID,
+		ClusterID:   clusterID,
+	}))
+
+	entry := loggerFromContext(ctx)
+	entry.Logger = logger
+	entry.Info("Running the checks")
+
+	var line map[string]interface{}
+	assert.NoError(t, json.Unmarshal(output.Bytes(), &line))
+	assert.Equal(t, executionID.String(), line["execution_id"])
+	assert.Equal(t, clusterID.String(), line["cluster_id"])
+	assert.Equal(t, "Running the checks", line["msg"])
+}
+
+func TestLoggerFromContext_Default(t *testing.T) {
+	entry := loggerFromContext(context.Background())
+
+	assert.Equal(t, log.StandardLogger(), entry.Logger)
+	assert.Empty(t, entry.Data)
+}
@@ -0,0 +1,188 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+var maintenanceRefreshInterval = time.Minute * 5
+
+// MaintenanceWindow is a time range where the checks are not run in a cluster or a host,
+// e.g. while a planned HANA takeover is done, so the expected failures are not reported
+type MaintenanceWindow struct {
+	// ClusterID puts all the hosts of the cluster in maintenance, and HostID only that host
+	ClusterID uuid.UUID `json:"cluster_id,omitempty"`
+	HostID    uuid.UUID `json:"host_id,omitempty"`
+	Start     time.Time `json:"start" binding:"required"`
+	End       time.Time `json:"end" binding:"required"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+// validate checks that the window is a time range of a cluster or a host
+func (w *MaintenanceWindow) validate() error {
+	if w.ClusterID == uuid.Nil && w.HostID == uuid.Nil {
+		return errors.New("the maintenance window requires a cluster_id or a host_id")
+	}
+	if !w.End.After(w.Start) {
+		return fmt.Errorf("the maintenance window end %s must be after its start %s",
+			w.End.Format(time.RFC3339), w.Start.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// covers tells if the host of the cluster is in the window at the given time
+func (w *MaintenanceWindow) covers(clusterID, hostID uuid.UUID, now time.Time) bool {
+	if now.Before(w.Start) || !now.Before(w.End) {
+		return false
+	}
+
+	return (w.ClusterID == uuid.Nil || w.ClusterID == clusterID) && (w.HostID == uuid.Nil || w.HostID == hostID)
+}
+
+// message is the reason of the checks skipped in the window
+func (w *MaintenanceWindow) message() string {
+	msg := fmt.Sprintf("skipped (maintenance) until %s", w.End.UTC().Format(time.RFC3339))
+	if w.Reason != "" {
+		msg += ": " + w.Reason
+	}
+
+	return msg
+}
+
+// MaintenanceWindows are the maintenance windows of a file, or of a url if it is a http one, as the Trento server API.
+// They are read again periodically, when they are used, so the new windows are applied without restarting the runner
+type MaintenanceWindows struct {
+	source     string
+	httpClient *http.Client
+	mu         sync.Mutex
+	windows    []*MaintenanceWindow
+	loadedAt   time.Time
+}
+
+func NewMaintenanceWindows(source string, transport http.RoundTripper) (*MaintenanceWindows, error) {
+	windows := &MaintenanceWindows{
+		source:     source,
+		httpClient: &http.Client{Transport: transport, Timeout: schedulesFetchTimeout},
+	}
+	if err := windows.Reload(); err != nil {
+		return nil, err
+	}
+
+	return windows, nil
+}
+
+// LoadMaintenanceWindows reads the maintenance windows in json from the given file or url
+func LoadMaintenanceWindows(source string, httpClient *http.Client) ([]*MaintenanceWindow, error) {
+	content, err := readSource(httpClient, source, "maintenance windows")
+	if err != nil {
+		return nil, err
+	}
+
+	var windows []*MaintenanceWindow
+	if err := json.Unmarshal(content, &windows); err != nil {
+		return nil, fmt.Errorf("cannot read the maintenance windows in %s: %s", source, err)
+	}
+
+	for _, window := range windows {
+		if err := binding.Validator.ValidateStruct(window); err != nil {
+			return nil, fmt.Errorf("invalid maintenance window in %s: %s", source, err)
+		}
+		if err := window.validate(); err != nil {
+			return nil, fmt.Errorf("invalid maintenance window in %s: %s", source, err)
+		}
+	}
+
+	return windows, nil
+}
+
+// Reload replaces the maintenance windows with the ones in the source
+func (m *MaintenanceWindows) Reload() error {
+	windows, err := LoadMaintenanceWindows(m.source, m.httpClient)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.windows = windows
+	m.loadedAt = time.Now()
+
+	log.Infof("Loaded %d maintenance windows from %s", len(windows), m.source)
+
+	return nil
+}
+
+// current returns the maintenance windows, reading them again if the refresh interval is elapsed.
+// The current ones are kept if they cannot be read
+func (m *MaintenanceWindows) current() []*MaintenanceWindow {
+	m.mu.Lock()
+	stale := time.Since(m.loadedAt) >= maintenanceRefreshInterval
+	m.mu.Unlock()
+
+	if stale {
+		if err := m.Reload(); err != nil {
+			log.Errorf("Error reloading the maintenance windows, keeping the current ones: %s", err)
+			m.mu.Lock()
+			m.loadedAt = time.Now()
+			m.mu.Unlock()
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.windows
+}
+
+// Find returns the maintenance window of the host of the cluster at the given time, if it is in one
+func (m *MaintenanceWindows) Find(clusterID, hostID uuid.UUID, now time.Time) *MaintenanceWindow {
+	for _, window := range m.current() {
+		if window.covers(clusterID, hostID, now) {
+			return window
+		}
+	}
+
+	return nil
+}
+
+// selectHostsOutOfMaintenance returns a copy of the execution event limited to the target hosts that are not
+// in a maintenance window, and the skipped results of the checks of the hosts in maintenance.
+// The limit of the copy is empty if all the target hosts are in maintenance
+func (c *runnerService) selectHostsOutOfMaintenance(ctx context.Context, e *ExecutionEvent) (*ExecutionEvent, *ExecutionResults) {
+	if c.maintenanceWindows == nil {
+		return e, nil
+	}
+
+	now := time.Now()
+	available := []string{}
+	maintenanceResults := &ExecutionResults{ClusterID: e.ClusterID.String(), Hosts: []*HostResults{}}
+	for _, host := range e.targetHosts() {
+		window := c.maintenanceWindows.Find(e.ClusterID, host.HostID, now)
+		if window == nil {
+			available = append(available, host.HostID.String())
+			continue
+		}
+
+		loggerFromContext(ctx).Infof("Host %s is in maintenance, its checks are skipped: %s", host.HostID.String(), window.message())
+		maintenanceResults.addHost(host.HostID.String(), true, window.message())
+		for _, checkID := range e.hostChecks(host) {
+			maintenanceResults.addResult(host.HostID.String(), checkID, checkResultSkipped, window.message())
+		}
+	}
+
+	if len(maintenanceResults.Hosts) == 0 {
+		return e, nil
+	}
+
+	return e.withLimit(available), maintenanceResults
+}
@@ -0,0 +1,176 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/trento-project/runner/runner/mocks"
+)
+
+type MaintenanceTestSuite struct {
+	suite.Suite
+	tmpDir           string
+	maintenanceFile  string
+	clusterID        uuid.UUID
+	hostID           uuid.UUID
+	maintenanceStart time.Time
+	maintenanceEnd   time.Time
+}
+
+func TestMaintenanceTestSuite(t *testing.T) {
+	suite.Run(t, new(MaintenanceTestSuite))
+}
+
+func (suite *MaintenanceTestSuite) SetupTest() {
+	suite.tmpDir, _ = ioutil.TempDir(os.TempDir(), "trentotest")
+	suite.maintenanceFile = path.Join(suite.tmpDir, "maintenance.json")
+	suite.clusterID = uuid.New()
+	suite.hostID = uuid.New()
+	suite.maintenanceStart = time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	suite.maintenanceEnd = time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	suite.writeWindows(`[
+		{"host_id": "%s", "start": "%s", "end": "%s", "reason": "HANA takeover"},
+		{"cluster_id": "%s", "start": "%s", "end": "%s"}
+	]`,
+		suite.hostID, suite.maintenanceStart.Format(time.RFC3339), suite.maintenanceEnd.Format(time.RFC3339),
+		suite.clusterID, suite.maintenanceEnd.Format(time.RFC3339), suite.maintenanceEnd.Add(time.Hour).Format(time.RFC3339))
+}
+
+func (suite *MaintenanceTestSuite) TearDownTest() {
+	os.RemoveAll(suite.tmpDir)
+}
+
+func (suite *MaintenanceTestSuite) writeWindows(content string, args ...interface{}) {
+	ioutil.WriteFile(suite.maintenanceFile, []byte(fmt.Sprintf(content, args...)), 0644)
+}
+
+func (suite *MaintenanceTestSuite) TestLoadMaintenanceWindows() {
+	windows, err := LoadMaintenanceWindows(suite.maintenanceFile, http.DefaultClient)
+	suite.NoError(err)
+	suite.Equal([]*MaintenanceWindow{
+		{HostID: suite.hostID, Start: suite.maintenanceStart, End: suite.maintenanceEnd, Reason: "HANA takeover"},
+		{ClusterID: suite.clusterID, Start: suite.maintenanceEnd, End: suite.maintenanceEnd.Add(time.Hour)},
+	}, windows)
+}
+
+func (suite *MaintenanceTestSuite) TestLoadMaintenanceWindows_Url() {
+	content, _ := ioutil.ReadFile(suite.maintenanceFile)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content)
+	}))
+	defer server.Close()
+
+	windows, err := LoadMaintenanceWindows(server.URL+"/api/maintenance", http.DefaultClient)
+	suite.NoError(err)
+	suite.Len(windows, 2)
+}
+
+func (suite *MaintenanceTestSuite) TestLoadMaintenanceWindows_Invalid() {
+	suite.writeWindows(`[{"start": "2022-05-10T10:00:00Z", "end": "2022-05-10T12:00:00Z"}]`)
+	_, err := LoadMaintenanceWindows(suite.maintenanceFile, http.DefaultClient)
+	suite.EqualError(err, fmt.Sprintf(
+		"invalid maintenance window in %s: the maintenance window requires a cluster_id or a host_id", suite.maintenanceFile))
+
+	suite.writeWindows(`[{"host_id": "%s", "start": "2022-05-10T12:00:00Z", "end": "2022-05-10T10:00:00Z"}]`, suite.hostID)
+	_, err = LoadMaintenanceWindows(suite.maintenanceFile, http.DefaultClient)
+	suite.EqualError(err, fmt.Sprintf(
+		"invalid maintenance window in %s: the maintenance window end 2022-05-10T10:00:00Z must be after its start 2022-05-10T12:00:00Z",
+		suite.maintenanceFile))
+
+	suite.writeWindows(`[{"host_id": "%s"}]`, suite.hostID)
+	_, err = LoadMaintenanceWindows(suite.maintenanceFile, http.DefaultClient)
+	suite.Contains(err.Error(), "invalid maintenance window in")
+}
+
+func (suite *MaintenanceTestSuite) TestFind() {
+	windows, err := NewMaintenanceWindows(suite.maintenanceFile, nil)
+	suite.NoError(err)
+
+	window := windows.Find(uuid.New(), suite.hostID, time.Now())
+	suite.Equal("HANA takeover", window.Reason)
+	suite.Nil(windows.Find(suite.clusterID, uuid.New(), time.Now()))
+	suite.NotNil(windows.Find(suite.clusterID, uuid.New(), suite.maintenanceEnd.Add(time.Minute)))
+	suite.Nil(windows.Find(suite.clusterID, suite.hostID, suite.maintenanceEnd.Add(2*time.Hour)))
+
+	// The windows are read again once the refresh interval is elapsed
+	suite.writeWindows(`[]`)
+	suite.NotNil(windows.Find(uuid.New(), suite.hostID, time.Now()))
+	windows.loadedAt = time.Now().Add(-maintenanceRefreshInterval)
+	suite.Nil(windows.Find(uuid.New(), suite.hostID, time.Now()))
+}
+
+func (suite *MaintenanceTestSuite) TestExecute_HostsInMaintenance() {
+	callbacksClient := new(mocks.CallbacksClient)
+	callbacksClient.On("Callback", mock.Anything, executionStartedEvent, mock.Anything).Return(nil)
+
+	runnerService, err := NewRunnerService(&Config{AnsibleFolder: suite.tmpDir, MaintenanceWindows: suite.maintenanceFile})
+	suite.NoError(err)
+	runnerService.callbacksClient = callbacksClient
+	runnerService.callbacksDispatcher = NewCallbacksDispatcher(callbacksClient)
+	runnerService.checkEngine = checkEngineFunc(func(e *ExecutionEvent) (*ExecutionResults, error) {
+		suite.Fail("the playbook must not be run")
+		return nil, nil
+	})
+
+	execution := &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   suite.clusterID,
+		Checks:      []string{"156F64"},
+		Hosts:       []*Host{&Host{HostID: suite.hostID, Address: "192.168.10.1", User: "user1"}},
+	}
+	suite.NoError(runnerService.Execute(context.Background(), execution))
+
+	expectedEvents := []string{hostCompletedEvent, checkResultEvent, executionFinishedEvent}
+	for _, event := range expectedEvents {
+		request := <-runnerService.callbacksDispatcher.queue
+		suite.Equal(event, request.event)
+		if event == checkResultEvent {
+			msg := fmt.Sprintf("skipped (maintenance) until %s: HANA takeover", suite.maintenanceEnd.Format(time.RFC3339))
+			suite.Equal(map[string]interface{}{
+				"cluster_id": suite.clusterID.String(),
+				"host_id":    suite.hostID.String(),
+				"check_id":   "156F64",
+				"result":     checkResultSkipped,
+				"msg":        msg,
+			}, request.payload)
+		}
+	}
+}
+
+func (suite *MaintenanceTestSuite) TestSelectHostsOutOfMaintenance() {
+	runnerService, err := NewRunnerService(&Config{AnsibleFolder: suite.tmpDir, MaintenanceWindows: suite.maintenanceFile})
+	suite.NoError(err)
+
+	otherHostID := uuid.New()
+	execution := &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   suite.clusterID,
+		Checks:      []string{"156F64"},
+		Hosts: []*Host{
+			&Host{HostID: suite.hostID, Address: "192.168.10.1", User: "user1"},
+			&Host{HostID: otherHostID, Address: "192.168.10.2", User: "user2"},
+		},
+	}
+
+	selected, maintenanceResults := runnerService.selectHostsOutOfMaintenance(context.Background(), execution)
+	suite.Equal([]uuid.UUID{otherHostID}, selected.Limit)
+	suite.Len(selected.Hosts, 2)
+	suite.Len(maintenanceResults.Hosts, 1)
+	suite.Equal(suite.hostID.String(), maintenanceResults.Hosts[0].HostID)
+
+	execution.Hosts = execution.Hosts[1:]
+	selected, maintenanceResults = runnerService.selectHostsOutOfMaintenance(context.Background(), execution)
+	suite.Equal(execution, selected)
+	suite.Nil(maintenanceResults)
+}
@@ -0,0 +1,107 @@
+package runner
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsNamespace = "trento_runner"
+
+// The checks take from less than a second to a few minutes
+var checkDurationBuckets = prometheus.ExponentialBuckets(0.25, 2, 11)
+
+// CheckDurationMetrics are the histograms of how long each check takes in each host, observed
+// by the runner when the executions finish
+type CheckDurationMetrics struct {
+	histogram *prometheus.HistogramVec
+}
+
+func NewCheckDurationMetrics() *CheckDurationMetrics {
+	return &CheckDurationMetrics{
+		histogram: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "check_duration_seconds",
+			Help:      "Duration of the checks in each host, from the start of their first task to their result.",
+			Buckets:   checkDurationBuckets,
+		}, []string{"check_id", "host_id"}),
+	}
+}
+
+// Observe adds the checks durations of the execution results to the histograms
+func (m *CheckDurationMetrics) Observe(results *ExecutionResults) {
+	for _, duration := range results.CheckDurations() {
+		m.histogram.WithLabelValues(duration.CheckID, duration.HostID).Observe(duration.Seconds)
+	}
+}
+
+// NewMetricsRegistry returns the registry of the metrics served in /metrics. Each app has its own registry,
+// with the executions queue metrics read from the worker pool, and the scheduler and circuit breakers ones, when they
+// are scraped
+func NewMetricsRegistry(
+	executionWorkerPool *ExecutionWorkerPool, scheduler *Scheduler, checkDurations *CheckDurationMetrics,
+	circuitBreakers map[string]*CircuitBreaker) *prometheus.Registry {
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+
+	if checkDurations != nil {
+		registry.MustRegister(checkDurations.histogram)
+	}
+
+	if scheduler != nil {
+		registry.MustRegister(
+			prometheus.NewCounterFunc(prometheus.CounterOpts{
+				Namespace: metricsNamespace,
+				Name:      "schedule_missed_ticks_total",
+				Help:      "Number of scheduled executions skipped or queued because the previous one of the cluster was running.",
+			}, func() float64 {
+				return float64(scheduler.MissedTicks())
+			}),
+		)
+	}
+
+	for name, breaker := range circuitBreakers {
+		breaker := breaker
+		registry.MustRegister(
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Namespace:   metricsNamespace,
+				Name:        "circuit_breaker_state",
+				Help:        "State of the circuit breaker of the Trento server or upstream: 0 closed, 1 open, 2 half open.",
+				ConstLabels: prometheus.Labels{"upstream": name},
+			}, func() float64 {
+				return float64(breaker.State())
+			}),
+		)
+	}
+
+	if executionWorkerPool == nil {
+		return registry
+	}
+
+	registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "execution_queue_depth",
+			Help:      "Number of executions waiting to be run.",
+		}, func() float64 {
+			return float64(executionWorkerPool.QueueDepth())
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "executions_running",
+			Help:      "Number of executions being run.",
+		}, func() float64 {
+			return float64(executionWorkerPool.Running())
+		}),
+	)
+
+	return registry
+}
+
+func MetricsHandler(registry *prometheus.Registry) gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+}
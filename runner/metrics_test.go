@@ -0,0 +1,121 @@
+package runner
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type MetricsTestCase struct {
+	suite.Suite
+}
+
+func TestMetricsTestCase(t *testing.T) {
+	suite.Run(t, new(MetricsTestCase))
+}
+
+func (suite *MetricsTestCase) Test_Metrics() {
+	channel := make(chan *ExecutionEvent, 2)
+	channel <- &ExecutionEvent{}
+	channel <- &ExecutionEvent{}
+
+	mockRunnerService := new(MockRunnerService)
+	mockRunnerService.On("GetChannel").Return(channel)
+
+	deps := setupTestDependencies()
+	deps.runnerService = mockRunnerService
+	deps.executionWorkerPool = NewExecutionWorkerPool(mockRunnerService, 1, DefaultShutdownGracePeriod)
+
+	app, err := NewAppWithDeps(&Config{}, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(200, resp.Code)
+	suite.Contains(resp.Body.String(), "trento_runner_execution_queue_depth 2\n")
+	suite.Contains(resp.Body.String(), "trento_runner_executions_running 0\n")
+	suite.Contains(resp.Body.String(), "go_goroutines")
+	suite.NotContains(resp.Body.String(), "trento_runner_schedule_missed_ticks_total")
+}
+
+func (suite *MetricsTestCase) Test_Metrics_Scheduler() {
+	deps := setupTestDependencies()
+	deps.scheduler = NewScheduler(&Config{}, new(MockRunnerService), NewEventsBroadcaster(), nil)
+	deps.scheduler.missedTicks = 3
+
+	app, err := NewAppWithDeps(&Config{}, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(200, resp.Code)
+	suite.Contains(resp.Body.String(), "trento_runner_schedule_missed_ticks_total 3\n")
+}
+
+func (suite *MetricsTestCase) Test_Metrics_CheckDurations() {
+	deps := setupTestDependencies()
+	deps.checkDurations = NewCheckDurationMetrics()
+	deps.checkDurations.Observe(&ExecutionResults{
+		durations: []*CheckDuration{
+			&CheckDuration{HostID: "host1", CheckID: "156F64", Seconds: 0.2},
+			&CheckDuration{HostID: "host1", CheckID: "156F64", Seconds: 40},
+		},
+	})
+
+	app, err := NewAppWithDeps(&Config{}, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(200, resp.Code)
+	suite.Contains(resp.Body.String(),
+		`trento_runner_check_duration_seconds_bucket{check_id="156F64",host_id="host1",le="0.25"} 1`+"\n")
+	suite.Contains(resp.Body.String(),
+		`trento_runner_check_duration_seconds_bucket{check_id="156F64",host_id="host1",le="64"} 2`+"\n")
+	suite.Contains(resp.Body.String(), `trento_runner_check_duration_seconds_sum{check_id="156F64",host_id="host1"} 40.2`+"\n")
+	suite.Contains(resp.Body.String(), `trento_runner_check_duration_seconds_count{check_id="156F64",host_id="host1"} 2`+"\n")
+}
+
+func (suite *MetricsTestCase) Test_Metrics_CircuitBreakers() {
+	defer func(cooldown time.Duration) { circuitBreakerCooldown = cooldown }(circuitBreakerCooldown)
+	circuitBreakerCooldown = time.Hour
+
+	upstreamBreaker := NewCircuitBreaker("upstream")
+	for i := 0; i < circuitBreakerThreshold; i++ {
+		upstreamBreaker.Record(fmt.Errorf("server unavailable"))
+	}
+
+	deps := setupTestDependencies()
+	deps.circuitBreakers = map[string]*CircuitBreaker{
+		"":         NewCircuitBreaker("Trento server"),
+		"upstream": upstreamBreaker,
+	}
+
+	app, err := NewAppWithDeps(&Config{}, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(200, resp.Code)
+	suite.Contains(resp.Body.String(), `trento_runner_circuit_breaker_state{upstream=""} 0`+"\n")
+	suite.Contains(resp.Body.String(), `trento_runner_circuit_breaker_state{upstream="upstream"} 1`+"\n")
+}
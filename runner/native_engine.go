@@ -0,0 +1,492 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	NativeFileCheck    = "file"
+	NativeSysctlCheck  = "sysctl"
+	NativePackageCheck = "package"
+	NativeSystemdCheck = "systemd"
+
+	checkResultPassing  = "passing"
+	checkResultWarning  = "warning"
+	checkResultCritical = "critical"
+
+	sshPort       = "22"
+	sshAuthSocket = "SSH_AUTH_SOCK"
+)
+
+var nativeSSHTimeout = time.Second * 10
+
+var ErrNativeDryRun = errors.New("The dry run is not supported by the native check engine")
+
+// The default keys used by ssh if a private key is not configured
+var defaultSSHKeys = []string{".ssh/id_rsa", ".ssh/id_ecdsa", ".ssh/id_ed25519"}
+
+// The systemd unit states given by systemctl is-enabled, the rest are given by systemctl is-active
+var systemdEnablementStates = map[string]bool{
+	"enabled":  true,
+	"disabled": true,
+	"static":   true,
+	"masked":   true,
+}
+
+// NativeCheck is a declarative check run by the native engine with a single command in the host
+type NativeCheck struct {
+	ID   string `yaml:"id"`
+	Type string `yaml:"type"`
+	// Severity is the result of the check when it fails, critical by default
+	Severity string `yaml:"severity"`
+	// Path and Pattern are used by the file checks, the file content must match the regular expression
+	Path    string `yaml:"path"`
+	Pattern string `yaml:"pattern"`
+	// Key is the sysctl key of the sysctl checks
+	Key string `yaml:"key"`
+	// Package is the rpm package of the package checks
+	Package string `yaml:"package"`
+	// Unit is the systemd unit of the systemd checks
+	Unit string `yaml:"unit"`
+	// Expected is the sysctl value, the minimum package version or the systemd unit state
+	Expected string `yaml:"expected"`
+
+	pattern *regexp.Regexp
+}
+
+// remoteSession runs commands in a host, returning the command stdout and exit code
+type remoteSession interface {
+	Run(command string) (string, int, error)
+	Close() error
+}
+
+// nativeCheckEngine runs the declarative checks over SSH, without using ansible in the runner
+// or python in the hosts. The checks that are not defined as native checks are skipped
+type nativeCheckEngine struct {
+	checks map[string]*NativeCheck
+	dial   func(ctx context.Context, host *Host) (remoteSession, error)
+}
+
+func NewNativeCheckEngine(config *Config) (*nativeCheckEngine, error) {
+	checks, err := LoadNativeChecks(config.NativeChecksDir)
+	if err != nil {
+		return nil, err
+	}
+
+	authMethods, err := sshAuthMethods(config)
+	if err != nil {
+		return nil, err
+	}
+
+	hostKeys := hostKeyCallback(config)
+
+	return &nativeCheckEngine{
+		checks: checks,
+		dial: func(ctx context.Context, host *Host) (remoteSession, error) {
+			return dialSSH(ctx, host, authMethods, hostKeys)
+		},
+	}, nil
+}
+
+// LoadNativeChecks reads the native checks defined in the yaml files of the given folder
+func LoadNativeChecks(checksDir string) (map[string]*NativeCheck, error) {
+	checks := make(map[string]*NativeCheck)
+
+	if _, err := os.Stat(checksDir); err != nil {
+		return nil, err
+	}
+
+	files, err := filepath.Glob(path.Join(checksDir, "*.y*ml"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		var fileChecks []*NativeCheck
+		if err := yaml.Unmarshal(content, &fileChecks); err != nil {
+			return nil, fmt.Errorf("cannot read the native checks in %s: %s", file, err)
+		}
+
+		for _, check := range fileChecks {
+			if err := check.validate(); err != nil {
+				return nil, fmt.Errorf("invalid native check in %s: %s", file, err)
+			}
+			if _, ok := checks[check.ID]; ok {
+				return nil, fmt.Errorf("native check %s is defined twice", check.ID)
+			}
+			checks[check.ID] = check
+		}
+	}
+
+	log.Infof("Loaded %d native checks from %s", len(checks), checksDir)
+
+	return checks, nil
+}
+
+func (n *NativeCheck) validate() error {
+	if n.ID == "" {
+		return fmt.Errorf("the check id is required")
+	}
+
+	if n.Severity == "" {
+		n.Severity = checkResultCritical
+	}
+
+	switch n.Type {
+	case NativeFileCheck:
+		if n.Path == "" || n.Pattern == "" {
+			return fmt.Errorf("check %s requires a path and a pattern", n.ID)
+		}
+		pattern, err := regexp.Compile(n.Pattern)
+		if err != nil {
+			return fmt.Errorf("check %s pattern is not valid: %s", n.ID, err)
+		}
+		n.pattern = pattern
+	case NativeSysctlCheck:
+		if n.Key == "" {
+			return fmt.Errorf("check %s requires a sysctl key", n.ID)
+		}
+	case NativePackageCheck:
+		if n.Package == "" || n.Expected == "" {
+			return fmt.Errorf("check %s requires a package and the expected version", n.ID)
+		}
+	case NativeSystemdCheck:
+		if n.Unit == "" || n.Expected == "" {
+			return fmt.Errorf("check %s requires a unit and the expected state", n.ID)
+		}
+	default:
+		return fmt.Errorf("check %s type %s is not supported", n.ID, n.Type)
+	}
+
+	return nil
+}
+
+// Command returns the command run in the host to gather the value evaluated by the check
+func (n *NativeCheck) Command() string {
+	switch n.Type {
+	case NativeFileCheck:
+		return "cat -- " + shellQuote(n.Path)
+	case NativeSysctlCheck:
+		return "sysctl -n " + shellQuote(n.Key)
+	case NativePackageCheck:
+		return "rpm -q --queryformat '%{VERSION}' " + shellQuote(n.Package)
+	case NativeSystemdCheck:
+		if systemdEnablementStates[n.Expected] {
+			return "systemctl is-enabled " + shellQuote(n.Unit)
+		}
+		return "systemctl is-active " + shellQuote(n.Unit)
+	}
+
+	return ""
+}
+
+// Evaluate returns the check result and message from the command output and exit code
+func (n *NativeCheck) Evaluate(output string, exitCode int) (string, string) {
+	value := strings.TrimSpace(output)
+
+	// systemctl exits with an error code when the unit is not in the active or enabled state
+	if exitCode != 0 && n.Type != NativeSystemdCheck {
+		return n.Severity, fmt.Sprintf("command failed with exit code %d: %s", exitCode, value)
+	}
+
+	passing := false
+	switch n.Type {
+	case NativeFileCheck:
+		passing = n.pattern.MatchString(output)
+		if !passing {
+			return n.Severity, fmt.Sprintf("%s content does not match %s", n.Path, n.Pattern)
+		}
+	case NativeSysctlCheck:
+		// Multiple values are separated by tabs in the sysctl output
+		passing = strings.Join(strings.Fields(value), " ") == strings.Join(strings.Fields(n.Expected), " ")
+	case NativePackageCheck:
+		passing = compareVersions(value, n.Expected) >= 0
+	case NativeSystemdCheck:
+		passing = value == n.Expected
+	}
+
+	if !passing {
+		return n.Severity, fmt.Sprintf("expected %s, got %s", n.Expected, value)
+	}
+
+	return checkResultPassing, ""
+}
+
+func (n *nativeCheckEngine) Run(
+	ctx context.Context, e *ExecutionEvent, outputHandler func(stream, line string)) (*ExecutionResults, error) {
+
+	if e.DryRun {
+		return nil, ErrNativeDryRun
+	}
+
+	hosts := e.targetHosts()
+	hostsResults := make([]*HostResults, len(hosts))
+
+	var wg sync.WaitGroup
+	for index, host := range hosts {
+		wg.Add(1)
+		go func(index int, host *Host) {
+			defer wg.Done()
+			hostsResults[index] = n.runHost(ctx, host, e.hostChecks(host), outputHandler)
+		}(index, host)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	return &ExecutionResults{ClusterID: e.ClusterID.String(), Hosts: hostsResults}, nil
+}
+
+func (n *nativeCheckEngine) runHost(
+	ctx context.Context, host *Host, checks []string, outputHandler func(stream, line string)) *HostResults {
+
+	hostID := host.HostID.String()
+	hostResults := &HostResults{HostID: hostID, Reachable: true, Results: []*CheckResult{}}
+	output := func(stream, format string, args ...interface{}) {
+		if outputHandler != nil {
+			outputHandler(stream, fmt.Sprintf("%s: %s", hostID, fmt.Sprintf(format, args...)))
+		}
+	}
+
+	// The native engine only connects with ssh, the checks of the windows hosts need the ansible engine
+	if host.isWindows() {
+		output(StdoutStream, "windows hosts are not supported by the native engine, skipping the checks")
+		for _, checkID := range checks {
+			hostResults.Results = append(hostResults.Results, &CheckResult{
+				CheckID: checkID,
+				Result:  checkResultSkipped,
+				Msg:     "the windows hosts are not supported by the native engine",
+			})
+		}
+		return hostResults
+	}
+
+	session, err := n.dial(ctx, host)
+	if err != nil {
+		loggerFromContext(ctx).Warnf("Host %s is not reachable: %s", hostID, err)
+		output(StderrStream, "unreachable: %s", err)
+		hostResults.Reachable = false
+		hostResults.Msg = err.Error()
+		return hostResults
+	}
+	defer session.Close()
+
+	for _, checkID := range checks {
+		if ctx.Err() != nil {
+			break
+		}
+
+		check, ok := n.checks[checkID]
+		if !ok {
+			output(StdoutStream, "check %s is not a native check, skipping it", checkID)
+			hostResults.Results = append(hostResults.Results, &CheckResult{
+				CheckID: checkID,
+				Result:  checkResultSkipped,
+				Msg:     "the check is not available in the native engine",
+			})
+			continue
+		}
+
+		commandOutput, exitCode, err := session.Run(check.Command())
+		if err != nil {
+			output(StderrStream, "unreachable: %s", err)
+			hostResults.Reachable = false
+			hostResults.Msg = err.Error()
+			return hostResults
+		}
+
+		result, msg := check.Evaluate(commandOutput, exitCode)
+		output(StdoutStream, "check %s %s %s", checkID, result, msg)
+		hostResults.Results = append(hostResults.Results, &CheckResult{CheckID: checkID, Result: result, Msg: msg})
+	}
+
+	return hostResults
+}
+
+// shellQuote quotes the argument to be used in a posix shell command
+func shellQuote(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// compareVersions compares the dotted versions numerically, falling back to the
+// string comparison for the non numeric parts
+func compareVersions(a, b string) int {
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		aPart, bPart := "0", "0"
+		if i < len(aParts) {
+			aPart = aParts[i]
+		}
+		if i < len(bParts) {
+			bPart = bParts[i]
+		}
+
+		aNumber, aErr := strconv.Atoi(aPart)
+		bNumber, bErr := strconv.Atoi(bPart)
+		switch {
+		case aErr == nil && bErr == nil && aNumber != bNumber:
+			if aNumber < bNumber {
+				return -1
+			}
+			return 1
+		case (aErr != nil || bErr != nil) && aPart != bPart:
+			return strings.Compare(aPart, bPart)
+		}
+	}
+
+	return 0
+}
+
+// sshAuthMethods uses the configured private key, or the default ones, and the ssh agent if it is running
+func sshAuthMethods(config *Config) ([]ssh.AuthMethod, error) {
+	passphrase, err := readSSHPassphrase(config)
+	if err != nil {
+		return nil, err
+	}
+
+	keyFiles := []string{config.SSHPrivateKeyFile}
+	if config.SSHPrivateKeyFile == "" {
+		keyFiles = []string{}
+		if home, err := os.UserHomeDir(); err == nil {
+			for _, key := range defaultSSHKeys {
+				keyFiles = append(keyFiles, path.Join(home, key))
+			}
+		}
+	}
+
+	signers := []ssh.Signer{}
+	for _, keyFile := range keyFiles {
+		content, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			if config.SSHPrivateKeyFile == "" && os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		var signer ssh.Signer
+		if passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(content, []byte(passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(content)
+		}
+		if err != nil && config.SSHPrivateKeyFile == "" {
+			log.Warnf("Skipping the private key %s: %s", keyFile, err)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the private key %s: %s", keyFile, err)
+		}
+		signers = append(signers, signer)
+	}
+
+	authMethods := []ssh.AuthMethod{ssh.PublicKeys(signers...)}
+
+	if socket := os.Getenv(sshAuthSocket); socket != "" {
+		authMethods = append(authMethods, ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+			conn, err := net.Dial("unix", socket)
+			if err != nil {
+				return nil, err
+			}
+			return agent.NewClient(conn).Signers()
+		}))
+	}
+
+	return authMethods, nil
+}
+
+type sshSession struct {
+	client *ssh.Client
+	done   chan struct{}
+}
+
+func dialSSH(
+	ctx context.Context, host *Host, authMethods []ssh.AuthMethod, hostKeys ssh.HostKeyCallback) (*sshSession, error) {
+	address, err := hostPort(host.Address, sshPort)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: nativeSSHTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	sshConn, channels, requests, err := ssh.NewClientConn(conn, address, &ssh.ClientConfig{
+		User: host.User,
+		Auth: authMethods,
+		// Same as the host key checking of the ansible engine
+		HostKeyCallback: hostKeys,
+		Timeout:         nativeSSHTimeout,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	session := &sshSession{
+		client: ssh.NewClient(sshConn, channels, requests),
+		done:   make(chan struct{}),
+	}
+
+	// Closing the connection stops the running command if the execution is cancelled
+	go func() {
+		select {
+		case <-ctx.Done():
+			session.client.Close()
+		case <-session.done:
+		}
+	}()
+
+	return session, nil
+}
+
+func (s *sshSession) Run(command string) (string, int, error) {
+	session, err := s.client.NewSession()
+	if err != nil {
+		return "", 0, err
+	}
+	defer session.Close()
+
+	output, err := session.Output(command)
+	if exitErr, ok := err.(*ssh.ExitError); ok {
+		return string(output), exitErr.ExitStatus(), nil
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	return string(output), 0, nil
+}
+
+func (s *sshSession) Close() error {
+	close(s.done)
+	return s.client.Close()
+}
@@ -0,0 +1,279 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	TestNativeChecksFolder string = "../test/fixtures/native_checks"
+)
+
+type NativeEngineTestSuite struct {
+	suite.Suite
+}
+
+func TestNativeEngineTestSuite(t *testing.T) {
+	suite.Run(t, new(NativeEngineTestSuite))
+}
+
+type commandOutput struct {
+	output   string
+	exitCode int
+	err      error
+}
+
+type fakeSession struct {
+	outputs map[string]commandOutput
+	closed  bool
+}
+
+func (f *fakeSession) Run(command string) (string, int, error) {
+	output, ok := f.outputs[command]
+	if !ok {
+		return "", 127, nil
+	}
+	return output.output, output.exitCode, output.err
+}
+
+func (f *fakeSession) Close() error {
+	f.closed = true
+	return nil
+}
+
+func (suite *NativeEngineTestSuite) TestLoadNativeChecks() {
+	checks, err := LoadNativeChecks(TestNativeChecksFolder)
+	suite.NoError(err)
+	suite.Len(checks, 4)
+
+	suite.Equal("critical", checks["156F64"].Severity)
+	suite.Equal("warning", checks["53D035"].Severity)
+	suite.Equal("sysctl -n 'net.ipv4.tcp_timestamps'", checks["156F64"].Command())
+	suite.Equal("cat -- '/etc/corosync/corosync.conf'", checks["53D035"].Command())
+	suite.Equal("rpm -q --queryformat '%{VERSION}' 'pacemaker'", checks["21FCA6"].Command())
+	suite.Equal("systemctl is-enabled 'pacemaker.service'", checks["A1244C"].Command())
+}
+
+func (suite *NativeEngineTestSuite) TestNewCheckEngine() {
+	engine, err := NewCheckEngine(&Config{CheckEngine: "ansible"})
+	suite.NoError(err)
+	suite.IsType(&ansibleCheckEngine{}, engine)
+
+	engine, err = NewCheckEngine(&Config{CheckEngine: "native", NativeChecksDir: TestNativeChecksFolder})
+	suite.NoError(err)
+	suite.IsType(&nativeCheckEngine{}, engine)
+
+	_, err = NewCheckEngine(&Config{CheckEngine: "native", NativeChecksDir: "/not/found"})
+	suite.Error(err)
+}
+
+func (suite *NativeEngineTestSuite) TestLoadNativeChecks_Invalid() {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	invalidChecks := map[string]string{
+		"- type: sysctl\n  key: vm.swappiness":                "the check id is required",
+		"- id: 156F64\n  type: sysctl":                        "check 156F64 requires a sysctl key",
+		"- id: 156F64\n  type: file\n  path: /etc/hosts":      "check 156F64 requires a path and a pattern",
+		"- id: 156F64\n  type: command\n  expected: \"0\"":    "check 156F64 type command is not supported",
+		"- id: 156F64\n  type: package\n  package: pacemaker": "check 156F64 requires a package and the expected version",
+	}
+
+	checksFile := path.Join(tmpDir, "checks.yaml")
+	for content, expectedError := range invalidChecks {
+		ioutil.WriteFile(checksFile, []byte(content), 0644)
+
+		_, err := LoadNativeChecks(tmpDir)
+		suite.EqualError(err, fmt.Sprintf("invalid native check in %s: %s", checksFile, expectedError))
+	}
+}
+
+func (suite *NativeEngineTestSuite) TestNativeCheckEvaluate() {
+	checks, _ := LoadNativeChecks(TestNativeChecksFolder)
+
+	cases := []struct {
+		checkID        string
+		output         string
+		exitCode       int
+		expectedResult string
+		expectedMsg    string
+	}{
+		{"156F64", "0\n", 0, "passing", ""},
+		{"156F64", "1\n", 0, "critical", "expected 0, got 1"},
+		{"156F64", "", 255, "critical", "command failed with exit code 255: "},
+		{"53D035", "totem {\n    token: 30000\n}\n", 0, "passing", ""},
+		{"53D035", "totem {\n    token: 5000\n}\n", 0, "warning", "/etc/corosync/corosync.conf content does not match token:\\s+30000"},
+		{"21FCA6", "2.0.5", 0, "passing", ""},
+		{"21FCA6", "2.0.3", 0, "passing", ""},
+		{"21FCA6", "1.1.24", 0, "critical", "expected 2.0.3, got 1.1.24"},
+		{"21FCA6", "package pacemaker is not installed", 1, "critical", "command failed with exit code 1: package pacemaker is not installed"},
+		{"A1244C", "enabled\n", 0, "passing", ""},
+		{"A1244C", "disabled\n", 1, "critical", "expected enabled, got disabled"},
+	}
+
+	for _, c := range cases {
+		result, msg := checks[c.checkID].Evaluate(c.output, c.exitCode)
+		suite.Equal(c.expectedResult, result, c.checkID)
+		suite.Equal(c.expectedMsg, msg, c.checkID)
+	}
+}
+
+func (suite *NativeEngineTestSuite) TestCompareVersions() {
+	suite.Equal(0, compareVersions("2.0.3", "2.0.3"))
+	suite.Equal(0, compareVersions("2.0", "2.0.0"))
+	suite.Equal(1, compareVersions("2.10", "2.9"))
+	suite.Equal(-1, compareVersions("1.1.24", "2.0.3"))
+	suite.Equal(1, compareVersions("2.0.3b", "2.0.3a"))
+}
+
+func (suite *NativeEngineTestSuite) TestNativeCheckEngineRun() {
+	checks, _ := LoadNativeChecks(TestNativeChecksFolder)
+	host1ID := uuid.New()
+	host2ID := uuid.New()
+	clusterID := uuid.New()
+
+	session := &fakeSession{
+		outputs: map[string]commandOutput{
+			"sysctl -n 'net.ipv4.tcp_timestamps'":           {output: "0\n"},
+			"systemctl is-enabled 'pacemaker.service'":      {output: "disabled\n", exitCode: 1},
+			"rpm -q --queryformat '%{VERSION}' 'pacemaker'": {output: "2.1.2"},
+			"cat -- '/etc/corosync/corosync.conf'":          {output: "token: 30000"},
+		},
+	}
+
+	engine := &nativeCheckEngine{
+		checks: checks,
+		dial: func(ctx context.Context, host *Host) (remoteSession, error) {
+			if host.HostID == host2ID {
+				return nil, fmt.Errorf("connection refused")
+			}
+			return session, nil
+		},
+	}
+
+	execution := &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   clusterID,
+		Checks:      []string{"156F64", "A1244C", "FFFFFF"},
+		Hosts: []*Host{
+			&Host{HostID: host1ID, Address: "192.168.10.1", User: "root"},
+			&Host{HostID: host2ID, Address: "192.168.10.2", User: "root"},
+		},
+	}
+
+	// The hosts run concurrently, so their lines are interleaved in any order
+	var mu sync.Mutex
+	lines := []string{}
+	results, err := engine.Run(context.Background(), execution, func(stream, line string) {
+		mu.Lock()
+		lines = append(lines, stream+" "+line)
+		mu.Unlock()
+	})
+	suite.NoError(err)
+
+	expectedResults := &ExecutionResults{
+		ClusterID: clusterID.String(),
+		Hosts: []*HostResults{
+			&HostResults{
+				HostID:    host1ID.String(),
+				Reachable: true,
+				Results: []*CheckResult{
+					&CheckResult{CheckID: "156F64", Result: "passing"},
+					&CheckResult{CheckID: "A1244C", Result: "critical", Msg: "expected enabled, got disabled"},
+					&CheckResult{CheckID: "FFFFFF", Result: "skipped", Msg: "the check is not available in the native engine"},
+				},
+			},
+			&HostResults{
+				HostID:    host2ID.String(),
+				Reachable: false,
+				Msg:       "connection refused",
+				Results:   []*CheckResult{},
+			},
+		},
+	}
+	suite.Equal(expectedResults, results)
+	suite.True(session.closed)
+	suite.Contains(lines, fmt.Sprintf("stderr %s: unreachable: connection refused", host2ID.String()))
+}
+
+func (suite *NativeEngineTestSuite) TestNativeCheckEngineRun_Windows() {
+	checks, _ := LoadNativeChecks(TestNativeChecksFolder)
+	hostID := uuid.New()
+
+	engine := &nativeCheckEngine{
+		checks: checks,
+		dial: func(ctx context.Context, host *Host) (remoteSession, error) {
+			return nil, fmt.Errorf("the windows hosts must not be dialed")
+		},
+	}
+
+	execution := &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Checks:      []string{"156F64"},
+		Hosts:       []*Host{&Host{HostID: hostID, Address: "192.168.10.1", User: "Administrator", Platform: PlatformWindows}},
+	}
+
+	results, err := engine.Run(context.Background(), execution, nil)
+	suite.NoError(err)
+	suite.Equal(&HostResults{
+		HostID:    hostID.String(),
+		Reachable: true,
+		Results: []*CheckResult{
+			&CheckResult{CheckID: "156F64", Result: "skipped", Msg: "the windows hosts are not supported by the native engine"},
+		},
+	}, results.Hosts[0])
+}
+
+func (suite *NativeEngineTestSuite) TestNativeCheckEngineRun_Cancelled() {
+	checks, _ := LoadNativeChecks(TestNativeChecksFolder)
+	engine := &nativeCheckEngine{
+		checks: checks,
+		dial: func(ctx context.Context, host *Host) (remoteSession, error) {
+			return &fakeSession{}, nil
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	execution := &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Checks:      []string{"156F64"},
+		Hosts:       []*Host{&Host{HostID: uuid.New(), Address: "192.168.10.1", User: "root"}},
+	}
+
+	_, err := engine.Run(ctx, execution, nil)
+	suite.Equal(context.Canceled, err)
+}
+
+func (suite *NativeEngineTestSuite) TestNativeCheckEngineRun_DryRun() {
+	engine := &nativeCheckEngine{
+		checks: map[string]*NativeCheck{},
+		dial: func(ctx context.Context, host *Host) (remoteSession, error) {
+			suite.Fail("the hosts must not be dialed in a dry run")
+			return nil, nil
+		},
+	}
+
+	execution := &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Checks:      []string{"156F64"},
+		Hosts:       []*Host{&Host{HostID: uuid.New(), Address: "192.168.10.1", User: "root"}},
+		DryRun:      true,
+	}
+
+	results, err := engine.Run(context.Background(), execution, nil)
+	suite.Nil(results)
+	suite.Equal(ErrNativeDryRun, err)
+}
@@ -0,0 +1,305 @@
+package runner
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/trento-project/runner/version"
+)
+
+const openAPIVersion = "3.0.3"
+
+var openAPISpecOnce sync.Once
+var openAPISpec map[string]interface{}
+
+// The path parameters of gin, as :id, are {id} in the OpenAPI paths
+var ginPathParam = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+
+var (
+	uuidType = reflect.TypeOf(uuid.UUID{})
+	timeType = reflect.TypeOf(time.Time{})
+)
+
+// The responses bodies built with gin.H in the handlers, documented with these types
+type apiStatus struct {
+	Status string `json:"status"`
+}
+
+type apiError struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+type executionAccepted struct {
+	Status      string `json:"status"`
+	ExecutionID string `json:"execution_id"`
+	// ExecutionStatus is the status of the execution already requested with the same id
+	ExecutionStatus string `json:"execution_status,omitempty"`
+}
+
+type executionsPage struct {
+	Items   []*ExecutionRecord `json:"items"`
+	Total   int                `json:"total"`
+	Page    int                `json:"page"`
+	PerPage int                `json:"per_page"`
+}
+
+type schedulesCount struct {
+	Status   string `json:"status"`
+	Clusters int    `json:"clusters"`
+}
+
+type readiness struct {
+	Ready bool `json:"ready"`
+}
+
+// openAPIOperation documents an endpoint of the runner, with the Go types of its request and responses bodies.
+// The responses without a type have no body, and the text ones are documented with their media type
+type openAPIOperation struct {
+	Method    string
+	Path      string
+	Summary   string
+	Query     []string
+	Request   interface{}
+	Responses map[int]interface{}
+	MediaType string
+}
+
+// openAPIOperations are the endpoints served by the runner, a test checks that every route is documented
+var openAPIOperations = []*openAPIOperation{
+	{Method: "GET", Path: "/healthz", Summary: "Liveness of the executions worker pool",
+		Responses: map[int]interface{}{200: apiStatus{}, 503: apiStatus{}}},
+	{Method: "GET", Path: "/readyz", Summary: "Readiness of the catalog and the executions worker pool",
+		Responses: map[int]interface{}{200: map[string]bool{}, 503: map[string]bool{}}},
+	{Method: "GET", Path: "/metrics", Summary: "Prometheus metrics", MediaType: "text/plain",
+		Responses: map[int]interface{}{200: ""}},
+	{Method: "GET", Path: "/api/health", Summary: "Health of the runner",
+		Responses: map[int]interface{}{200: apiStatus{}}},
+	{Method: "GET", Path: "/api/ready", Summary: "Readiness of the checks catalog",
+		Responses: map[int]interface{}{200: readiness{}}},
+	{Method: "GET", Path: "/api/version", Summary: "Runner build, embedded checks and catalog versions",
+		Responses: map[int]interface{}{200: VersionInfo{}}},
+	{Method: "GET", Path: "/api/catalog", Summary: "Checks catalog", Query: []string{"provider", "group", "lang"},
+		Responses: map[int]interface{}{200: Catalog{}, 204: nil}},
+	{Method: "GET", Path: "/api/catalog/status", Summary: "State of the last catalog build",
+		Responses: map[int]interface{}{200: CatalogStatus{}}},
+	{Method: "GET", Path: "/api/catalog/providers", Summary: "Providers with checks in the catalog",
+		Responses: map[int]interface{}{200: []string{}, 204: nil}},
+	{Method: "POST", Path: "/api/catalog/rebuild", Summary: "Build the checks catalog again",
+		Responses: map[int]interface{}{200: Catalog{}, 500: apiError{}}},
+	{Method: "POST", Path: "/api/execute", Summary: "Request an execution, deprecated in favor of /api/executions",
+		Request: ExecutionEvent{}, Responses: executionResponses},
+	{Method: "POST", Path: "/api/executions", Summary: "Request an execution",
+		Request: ExecutionEvent{}, Responses: executionResponses},
+	{Method: "POST", Path: "/api/executions/trigger", Summary: "Start the scheduled executions of all the clusters now",
+		Responses: map[int]interface{}{202: schedulesCount{}, 404: apiError{}}},
+	{Method: "GET", Path: "/api/executions", Summary: "Executions history, the most recent first",
+		Query:     []string{"page", "per_page"},
+		Responses: map[int]interface{}{200: executionsPage{}, 400: apiError{}, 404: apiError{}}},
+	{Method: "GET", Path: "/api/executions/:id", Summary: "Execution record",
+		Responses: map[int]interface{}{200: ExecutionRecord{}, 400: apiError{}, 404: apiError{}}},
+	{Method: "GET", Path: "/api/executions/:id/logs", Summary: "Ansible output of the execution, as server-sent events",
+		MediaType: "text/event-stream", Responses: map[int]interface{}{200: "", 400: apiError{}, 404: apiError{}}},
+	{Method: "GET", Path: "/api/executions/:id/logs/bundle", Summary: "Log files of the execution, as a tar.gz archive",
+		MediaType: "application/gzip", Responses: map[int]interface{}{200: "", 400: apiError{}, 404: apiError{}}},
+	{Method: "GET", Path: "/api/schedules", Summary: "Clusters schedules",
+		Responses: map[int]interface{}{200: []*ScheduleStatus{}, 404: apiError{}}},
+	{Method: "POST", Path: "/api/schedules/reload", Summary: "Load the schedules again from their source",
+		Responses: map[int]interface{}{200: schedulesCount{}, 404: apiError{}, 500: apiError{}}},
+	{Method: "PUT", Path: "/api/schedules/:cluster_id", Summary: "Change the cron expression and jitter of a cluster schedule",
+		Request:   scheduleUpdate{},
+		Responses: map[int]interface{}{200: apiStatus{}, 400: apiError{}, 404: apiError{}}},
+	{Method: "POST", Path: "/api/schedules/:cluster_id/pause", Summary: "Pause the schedule of a cluster",
+		Responses: map[int]interface{}{200: apiStatus{}, 400: apiError{}, 404: apiError{}}},
+	{Method: "POST", Path: "/api/schedules/:cluster_id/resume", Summary: "Resume the schedule of a cluster",
+		Responses: map[int]interface{}{200: apiStatus{}, 400: apiError{}, 404: apiError{}}},
+	{Method: "GET", Path: "/api/host_keys", Summary: "Host keys waiting to be accepted",
+		Responses: map[int]interface{}{200: []*PendingHostKey{}, 404: apiError{}}},
+	{Method: "POST", Path: "/api/host_keys/accept", Summary: "Accept the pending key of a host",
+		Request:   hostKeyAcceptRequest{},
+		Responses: map[int]interface{}{200: apiStatus{}, 400: apiError{}, 404: apiError{}}},
+	{Method: "GET", Path: "/api/inventory", Summary: "Last inventory generated, without the credentials",
+		Query:     []string{"cluster_id"},
+		Responses: map[int]interface{}{200: InventorySnapshot{}, 400: apiError{}, 404: apiError{}}},
+	{Method: "GET", Path: "/api/inventory/changes", Summary: "Hosts added to and removed from the inventories, the most recent first",
+		Query:     []string{"cluster_id"},
+		Responses: map[int]interface{}{200: []*InventoryChange{}, 400: apiError{}}},
+	{Method: "GET", Path: "/api/docs/openapi.json", Summary: "OpenAPI document of the runner API",
+		Responses: map[int]interface{}{200: map[string]interface{}{}}},
+}
+
+var executionResponses = map[int]interface{}{
+	200: executionAccepted{}, 202: executionAccepted{}, 400: apiError{}, 429: apiError{}, 500: apiError{}, 503: apiError{},
+}
+
+// OpenAPIHandler serves the OpenAPI document of the runner API, so the clients can be generated from it
+func OpenAPIHandler(c *gin.Context) {
+	c.JSON(200, NewOpenAPISpec())
+}
+
+// NewOpenAPISpec builds the OpenAPI document from the documented operations, with the schemas of their Go types.
+// The document is built once, as the operations and the types do not change while the runner is running
+func NewOpenAPISpec() map[string]interface{} {
+	openAPISpecOnce.Do(func() {
+		schemas := make(map[string]interface{})
+		paths := make(map[string]map[string]interface{})
+
+		for _, operation := range openAPIOperations {
+			path := ginPathParam.ReplaceAllString(operation.Path, "{$1}")
+			if paths[path] == nil {
+				paths[path] = make(map[string]interface{})
+			}
+			paths[path][strings.ToLower(operation.Method)] = operation.spec(schemas)
+		}
+
+		openAPISpec = map[string]interface{}{
+			"openapi": openAPIVersion,
+			"info": map[string]interface{}{
+				"title":   "Trento Runner API",
+				"version": version.Version,
+			},
+			"paths":      paths,
+			"components": map[string]interface{}{"schemas": schemas},
+		}
+	})
+
+	return openAPISpec
+}
+
+func (o *openAPIOperation) spec(schemas map[string]interface{}) map[string]interface{} {
+	spec := map[string]interface{}{"summary": o.Summary}
+
+	parameters := []interface{}{}
+	for _, param := range ginPathParam.FindAllStringSubmatch(o.Path, -1) {
+		parameters = append(parameters, map[string]interface{}{
+			"name": param[1], "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"},
+		})
+	}
+	for _, param := range o.Query {
+		parameters = append(parameters, map[string]interface{}{
+			"name": param, "in": "query", "schema": map[string]interface{}{"type": "string"},
+		})
+	}
+	if len(parameters) > 0 {
+		spec["parameters"] = parameters
+	}
+
+	if o.Request != nil {
+		spec["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": schemaOf(reflect.TypeOf(o.Request), schemas)},
+			},
+		}
+	}
+
+	responses := make(map[string]interface{})
+	for code, body := range o.Responses {
+		response := map[string]interface{}{"description": http.StatusText(code)}
+		switch {
+		case body == nil:
+		case reflect.TypeOf(body).Kind() == reflect.String && o.MediaType != "":
+			response["content"] = map[string]interface{}{
+				o.MediaType: map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+			}
+		default:
+			response["content"] = map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": schemaOf(reflect.TypeOf(body), schemas)},
+			}
+		}
+		responses[strconv.Itoa(code)] = response
+	}
+	spec["responses"] = responses
+
+	return spec
+}
+
+// schemaOf returns the schema of the values of the given type, as they are encoded in JSON. The named structs
+// are added to the components schemas, and referenced
+func schemaOf(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	switch t {
+	case uuidType:
+		return map[string]interface{}{"type": "string", "format": "uuid"}
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaOf(t.Elem(), schemas)
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem(), schemas)}
+	case reflect.Struct:
+		ref := map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+		if _, ok := schemas[t.Name()]; ok {
+			return ref
+		}
+		// The schema is added before its fields, so the recursive types reference it
+		schema := map[string]interface{}{"type": "object"}
+		schemas[t.Name()] = schema
+		properties := make(map[string]interface{})
+		required := []string{}
+		addStructFields(t, schemas, properties, &required)
+		schema["properties"] = properties
+		if len(required) > 0 {
+			sort.Strings(required)
+			schema["required"] = required
+		}
+		return ref
+	default:
+		// Any value, as the interfaces
+		return map[string]interface{}{}
+	}
+}
+
+// addStructFields adds the JSON fields of the struct to the properties, including the ones of its embedded structs
+func addStructFields(t reflect.Type, schemas map[string]interface{}, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			addStructFields(embedded, schemas, properties, required)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = schemaOf(field.Type, schemas)
+		if strings.Contains(field.Tag.Get("binding"), "required") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package runner
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAPISpec_AllRoutes(t *testing.T) {
+	deps := setupTestDependencies()
+	_, err := NewAppWithDeps(&Config{}, deps)
+	assert.NoError(t, err)
+
+	paths := NewOpenAPISpec()["paths"].(map[string]map[string]interface{})
+	for _, route := range deps.webEngine.Routes() {
+		path := ginPathParam.ReplaceAllString(route.Path, "{$1}")
+		assert.Contains(t, paths[path], strings.ToLower(route.Method), "route %s %s is not documented", route.Method, route.Path)
+	}
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	deps := setupTestDependencies()
+	app, err := NewAppWithDeps(&Config{}, deps)
+	assert.NoError(t, err)
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/docs/openapi.json", nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	var spec struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			Parameters []struct {
+				Name string `json:"name"`
+				In   string `json:"in"`
+			} `json:"parameters"`
+			RequestBody struct {
+				Content map[string]struct {
+					Schema map[string]string `json:"schema"`
+				} `json:"content"`
+			} `json:"requestBody"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Required   []string                          `json:"required"`
+				Properties map[string]map[string]interface{} `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	assert.Equal(t, 200, resp.Code)
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &spec))
+	assert.Equal(t, "3.0.3", spec.OpenAPI)
+
+	execute := spec.Paths["/api/executions"]["post"]
+	assert.Equal(t, "#/components/schemas/ExecutionEvent", execute.RequestBody.Content["application/json"].Schema["$ref"])
+	assert.Equal(t, "id", spec.Paths["/api/executions/{id}"]["get"].Parameters[0].Name)
+	assert.Equal(t, "path", spec.Paths["/api/executions/{id}"]["get"].Parameters[0].In)
+
+	execution := spec.Components.Schemas["ExecutionEvent"]
+	assert.Equal(t, []string{"checks", "cluster_id", "execution_id", "hosts", "provider"}, execution.Required)
+	assert.Equal(t, map[string]interface{}{"type": "string", "format": "uuid"}, execution.Properties["execution_id"])
+	assert.Equal(t, "#/components/schemas/Host", execution.Properties["hosts"]["items"].(map[string]interface{})["$ref"])
+	assert.NotContains(t, execution.Properties, "remediation")
+
+	// The fields of the embedded structs are flattened, as they are encoded
+	assert.Contains(t, spec.Components.Schemas["VersionInfo"].Properties, "git_sha")
+	assert.Contains(t, spec.Components.Schemas["VersionInfo"].Properties, "embedded_checks_hash")
+}
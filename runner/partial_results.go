@@ -0,0 +1,80 @@
+package runner
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+const (
+	// partialResultPrefix starts the partial results lines. Do not change it, it is used in the trento callback as well
+	partialResultPrefix = "TRENTO_PARTIAL_RESULT "
+	partialResultEvent  = "partial_result"
+)
+
+// partialResult is the result of a check in a host, or the host reachability, printed by the trento callback
+type partialResult struct {
+	HostID    string      `json:"host_id"`
+	Reachable bool        `json:"reachable"`
+	CheckID   string      `json:"check_id"`
+	Result    string      `json:"result"`
+	Msg       interface{} `json:"msg"`
+}
+
+// parsePartialResult returns the partial result printed in the output line, if it is one
+func parsePartialResult(line string) (*partialResult, bool) {
+	if !strings.HasPrefix(line, partialResultPrefix) {
+		return nil, false
+	}
+
+	result := &partialResult{}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, partialResultPrefix)), result); err != nil {
+		return nil, false
+	}
+	if result.HostID == "" {
+		return nil, false
+	}
+
+	return result, true
+}
+
+// payload returns the partial result callback payload, with the severity of the failing checks.
+// The critical thresholds need the results of every host, so they are only applied in the final report
+func (r *partialResult) payload(clusterID string, mapper *SeverityMapper) map[string]interface{} {
+	msg := (&TaskHostResult{Msg: r.Msg}).Message()
+	payload := map[string]interface{}{
+		"cluster_id": clusterID,
+		"host_id":    r.HostID,
+		"reachable":  r.Reachable,
+		"msg":        msg,
+	}
+	if r.CheckID == "" {
+		return payload
+	}
+
+	result := r.Result
+	if result != checkResultPassing && result != checkResultSkipped {
+		result = mapper.severity(&CheckResult{CheckID: r.CheckID, Result: r.Result})
+	}
+	payload["check_id"] = r.CheckID
+	payload["result"] = result
+
+	return payload
+}
+
+// partialResultsHandler wraps the execution output handler, posting the partial results to the server
+// as soon as they are printed. The partial results lines are not kept in the execution logs
+func (c *runnerService) partialResultsHandler(
+	e *ExecutionEvent, outputHandler func(stream, line string)) func(stream, line string) {
+
+	mapper := NewSeverityMapper(c.config, c.GetCatalog())
+
+	return func(stream, line string) {
+		if stream == StderrStream {
+			if result, ok := parsePartialResult(line); ok {
+				c.dispatchCallback(e, partialResultEvent, result.payload(e.ClusterID.String(), mapper))
+				return
+			}
+		}
+		outputHandler(stream, line)
+	}
+}
@@ -0,0 +1,90 @@
+package runner
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePartialResult(t *testing.T) {
+	result, ok := parsePartialResult(
+		`TRENTO_PARTIAL_RESULT {"host_id": "host1", "reachable": true, "check_id": "156F64", "result": "warning", "msg": ""}`)
+	assert.True(t, ok)
+	assert.Equal(t, &partialResult{HostID: "host1", Reachable: true, CheckID: "156F64", Result: "warning", Msg: ""}, result)
+
+	result, ok = parsePartialResult(`TRENTO_PARTIAL_RESULT {"host_id": "host2", "reachable": false, "msg": ["timed out"]}`)
+	assert.True(t, ok)
+	assert.Equal(t, "host2", result.HostID)
+	assert.False(t, result.Reachable)
+
+	_, ok = parsePartialResult("[WARNING]: Could not match supplied host pattern")
+	assert.False(t, ok)
+	_, ok = parsePartialResult("TRENTO_PARTIAL_RESULT {invalid")
+	assert.False(t, ok)
+	_, ok = parsePartialResult(`TRENTO_PARTIAL_RESULT {"check_id": "156F64"}`)
+	assert.False(t, ok)
+}
+
+func TestPartialResultPayload(t *testing.T) {
+	mapper := NewSeverityMapper(&Config{CheckSeverities: map[string]string{"156F64": "warning"}}, nil)
+
+	result := &partialResult{HostID: "host1", Reachable: true, CheckID: "156F64", Result: "critical"}
+	assert.Equal(t, map[string]interface{}{
+		"cluster_id": "cluster1",
+		"host_id":    "host1",
+		"reachable":  true,
+		"check_id":   "156F64",
+		"result":     "warning",
+		"msg":        "",
+	}, result.payload("cluster1", mapper))
+
+	result = &partialResult{HostID: "host1", Reachable: true, CheckID: "53D035", Result: "skipped"}
+	assert.Equal(t, "skipped", result.payload("cluster1", mapper)["result"])
+
+	result = &partialResult{HostID: "host2", Reachable: false, Msg: []interface{}{"timed out"}}
+	assert.Equal(t, map[string]interface{}{
+		"cluster_id": "cluster1",
+		"host_id":    "host2",
+		"reachable":  false,
+		"msg":        `["timed out"]`,
+	}, result.payload("cluster1", mapper))
+}
+
+func TestPartialResultsHandler(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	runnerService, _ := NewRunnerService(&Config{AnsibleFolder: tmpDir})
+	events, unsubscribe := runnerService.events.Subscribe()
+	defer unsubscribe()
+
+	e := &ExecutionEvent{ExecutionID: uuid.New(), ClusterID: uuid.New()}
+	var lines []string
+	handler := runnerService.partialResultsHandler(e, func(stream, line string) {
+		lines = append(lines, stream+": "+line)
+	})
+
+	handler(StdoutStream, "TASK [Gathering Facts]")
+	handler(StderrStream, `TRENTO_PARTIAL_RESULT {"host_id": "host1", "reachable": true, "check_id": "156F64", "result": "passing"}`)
+	handler(StderrStream, "[WARNING]: Invalid characters were found in group names")
+
+	assert.Equal(t, []string{
+		"stdout: TASK [Gathering Facts]",
+		"stderr: [WARNING]: Invalid characters were found in group names",
+	}, lines)
+
+	event := <-events
+	assert.Equal(t, e.ExecutionID, event.ExecutionID)
+	assert.Equal(t, partialResultEvent, event.Event)
+	assert.Equal(t, map[string]interface{}{
+		"cluster_id": e.ClusterID.String(),
+		"host_id":    "host1",
+		"reachable":  true,
+		"check_id":   "156F64",
+		"result":     "passing",
+		"msg":        "",
+	}, event.Payload)
+}
@@ -0,0 +1,109 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSSHPort   = "22"
+	defaultWinRMPort = "5986"
+)
+
+// preflightHosts checks that the ssh port, or the winrm one of the windows hosts, of every target host accepts connections,
+// in parallel and with the given timeout. The bastion is checked instead of the hosts connected through it.
+// It returns a copy of the execution event with the reachable hosts only, and the results of the unreachable ones,
+// so they are reported right away, instead of waiting for the ssh timeouts in the playbook
+func preflightHosts(
+	ctx context.Context, e *ExecutionEvent, timeout time.Duration, defaultBastion string) (*ExecutionEvent, *ExecutionResults) {
+	errs := make([]error, len(e.Hosts))
+
+	var wg sync.WaitGroup
+	for index, host := range e.Hosts {
+		if !e.isTarget(host) {
+			continue
+		}
+		wg.Add(1)
+		go func(index int, host *Host) {
+			defer wg.Done()
+			if bastion := preflightBastion(defaultBastion, e, host); bastion != "" {
+				errs[index] = dialPort(ctx, bastionAddress(bastion), defaultSSHPort, timeout)
+				return
+			}
+			errs[index] = dialPort(ctx, host.Address, connectionPort(e, host), timeout)
+		}(index, host)
+	}
+	wg.Wait()
+
+	reachable := *e
+	reachable.Hosts = []*Host{}
+	unreachable := &ExecutionResults{ClusterID: e.ClusterID.String(), Hosts: []*HostResults{}}
+
+	for index, host := range e.Hosts {
+		if errs[index] == nil {
+			reachable.Hosts = append(reachable.Hosts, host)
+			continue
+		}
+
+		target, connection := "host", "ssh"
+		if host.isWindows() {
+			connection = winrmConnection
+		} else if preflightBastion(defaultBastion, e, host) != "" {
+			target = "bastion"
+		}
+		loggerFromContext(ctx).Warnf("Host %s is not reachable: %s", host.HostID.String(), errs[index])
+		unreachable.addHost(
+			host.HostID.String(), false, fmt.Sprintf("Failed to connect to the %s via %s: %s", target, connection, errs[index]))
+	}
+
+	return &reachable, unreachable
+}
+
+// preflightBastion returns the bastion of the ssh host, if it is connected through one
+func preflightBastion(defaultBastion string, e *ExecutionEvent, host *Host) string {
+	if host.isWindows() {
+		return ""
+	}
+
+	if bastion := hostConnection(defaultBastion, e, host).Bastion; bastion != BastionNone {
+		return bastion
+	}
+
+	return ""
+}
+
+// connectionPort returns the port ansible connects to in the host, the winrm one in the windows hosts
+func connectionPort(e *ExecutionEvent, host *Host) string {
+	if !host.isWindows() {
+		return defaultSSHPort
+	}
+
+	if options := e.Connection.merge(host.Connection); options.WinRMPort != 0 {
+		return strconv.Itoa(options.WinRMPort)
+	}
+
+	return defaultWinRMPort
+}
+
+// dialPort opens a tcp connection to the address, in the given port if the address does not have one
+func dialPort(ctx context.Context, address, port string, timeout time.Duration) error {
+	address, err := hostPort(address, port)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+
+	return conn.Close()
+}
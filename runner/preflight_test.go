@@ -0,0 +1,117 @@
+package runner
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreflightHosts(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	closedAddress := closed.Addr().String()
+	closed.Close()
+
+	reachableHost := &Host{HostID: uuid.New(), Address: listener.Addr().String()}
+	unreachableHost := &Host{HostID: uuid.New(), Address: closedAddress}
+	execution := &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Hosts:       []*Host{reachableHost, unreachableHost},
+	}
+
+	reachable, unreachable := preflightHosts(context.Background(), execution, time.Second, "")
+
+	assert.Equal(t, []*Host{reachableHost}, reachable.Hosts)
+	assert.Equal(t, execution.ExecutionID, reachable.ExecutionID)
+	assert.Len(t, execution.Hosts, 2)
+
+	assert.Equal(t, execution.ClusterID.String(), unreachable.ClusterID)
+	assert.Len(t, unreachable.Hosts, 1)
+	assert.Equal(t, unreachableHost.HostID.String(), unreachable.Hosts[0].HostID)
+	assert.False(t, unreachable.Hosts[0].Reachable)
+	assert.Contains(t, unreachable.Hosts[0].Msg, "Failed to connect to the host via ssh")
+
+	// The hosts out of the limit are not checked
+	execution.Limit = []uuid.UUID{reachableHost.HostID}
+	reachable, unreachable = preflightHosts(context.Background(), execution, time.Second, "")
+
+	assert.Equal(t, []*Host{reachableHost, unreachableHost}, reachable.Hosts)
+	assert.Empty(t, unreachable.Hosts)
+
+	unreachableHost.Platform = PlatformWindows
+	execution.Limit = nil
+	_, unreachable = preflightHosts(context.Background(), execution, time.Second, "")
+	assert.Contains(t, unreachable.Hosts[0].Msg, "Failed to connect to the host via winrm")
+}
+
+func TestPreflightHosts_Bastion(t *testing.T) {
+	bastion, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer bastion.Close()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	closedAddress := closed.Addr().String()
+	closed.Close()
+
+	// The hosts behind the bastion are not reachable from the runner, the bastion is checked instead
+	behindBastion := &Host{HostID: uuid.New(), Address: closedAddress}
+	direct := &Host{HostID: uuid.New(), Address: closedAddress, Connection: &ConnectionOptions{Bastion: BastionNone}}
+	execution := &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Hosts:       []*Host{behindBastion, direct},
+	}
+
+	reachable, unreachable := preflightHosts(context.Background(), execution, time.Second, "trento@"+bastion.Addr().String())
+	assert.Equal(t, []*Host{behindBastion}, reachable.Hosts)
+	assert.Len(t, unreachable.Hosts, 1)
+	assert.Contains(t, unreachable.Hosts[0].Msg, "Failed to connect to the host via ssh")
+
+	execution.Connection = &ConnectionOptions{Bastion: closedAddress}
+	_, unreachable = preflightHosts(context.Background(), execution, time.Second, "")
+	assert.Len(t, unreachable.Hosts, 2)
+	assert.Contains(t, unreachable.Hosts[0].Msg, "Failed to connect to the bastion via ssh")
+}
+
+func TestConnectionPort(t *testing.T) {
+	execution := &ExecutionEvent{Connection: &ConnectionOptions{}}
+	host := &Host{HostID: uuid.New(), Address: "192.168.10.1"}
+
+	assert.Equal(t, "22", connectionPort(execution, host))
+
+	host.Platform = PlatformWindows
+	assert.Equal(t, "5986", connectionPort(execution, host))
+
+	execution.Connection.WinRMPort = 5985
+	assert.Equal(t, "5985", connectionPort(execution, host))
+}
+
+func TestMoveHostsResults(t *testing.T) {
+	results := &ExecutionResults{Hosts: []*HostResults{}}
+	results.addHost("host1", false, "unreachable")
+
+	cached := &ExecutionResults{
+		Hosts: []*HostResults{
+			&HostResults{HostID: "host1", Reachable: true, Results: []*CheckResult{&CheckResult{CheckID: "A", Result: "passing"}}},
+			&HostResults{HostID: "host2", Reachable: true, Results: []*CheckResult{&CheckResult{CheckID: "A", Result: "warning"}}},
+		},
+	}
+
+	results.moveHostsResults(cached)
+
+	assert.Len(t, results.Hosts, 1)
+	assert.False(t, results.Hosts[0].Reachable)
+	assert.Equal(t, []*CheckResult{&CheckResult{CheckID: "A", Result: "passing"}}, results.Hosts[0].Results)
+	assert.Len(t, cached.Hosts, 1)
+	assert.Equal(t, "host2", cached.Hosts[0].HostID)
+}
@@ -0,0 +1,87 @@
+package runner
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// The proxy environment variables of the commands run by the runner. Each tool reads a different case
+var proxyEnvNames = []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY"}
+
+// The proxy schemes supported by the http clients
+var validProxySchemes = map[string]bool{
+	"http":   true,
+	"https":  true,
+	"socks5": true,
+}
+
+// IsValidProxy tells if the proxy is an url of a supported proxy scheme
+func IsValidProxy(proxy string) bool {
+	u, err := url.Parse(proxy)
+	return err == nil && validProxySchemes[u.Scheme] && u.Host != ""
+}
+
+// proxyConfig returns the proxy of the runner outbound connections: the configured one, used for both http and https,
+// or the one of the proxy environment variables if it is not set
+func proxyConfig(config *Config) *httpproxy.Config {
+	if config.Proxy == "" {
+		return httpproxy.FromEnvironment()
+	}
+
+	return &httpproxy.Config{
+		HTTPProxy:  config.Proxy,
+		HTTPSProxy: config.Proxy,
+		NoProxy:    config.NoProxy,
+	}
+}
+
+// proxyFunc returns the proxy of each request of the runner http clients
+func proxyFunc(config *Config) func(*http.Request) (*url.URL, error) {
+	proxy := proxyConfig(config).ProxyFunc()
+	return func(request *http.Request) (*url.URL, error) {
+		return proxy(request.URL)
+	}
+}
+
+// newHTTPTransport returns a transport with the settings of the default one, connecting through the runner proxy
+func newHTTPTransport(config *Config) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyFunc(config)
+
+	return transport
+}
+
+// proxyEnvs returns the proxy environment variables of the commands downloading files, as ansible-galaxy and git,
+// replacing the ones of the runner environment. The commands keep the runner environment if the proxy is not set
+func proxyEnvs(config *Config) map[string]string {
+	if config.Proxy == "" {
+		return nil
+	}
+
+	values := map[string]string{
+		"HTTP_PROXY":  config.Proxy,
+		"HTTPS_PROXY": config.Proxy,
+		"NO_PROXY":    config.NoProxy,
+	}
+	envs := make(map[string]string, 2*len(values))
+	for name, value := range values {
+		envs[name] = value
+		envs[strings.ToLower(name)] = value
+	}
+
+	return envs
+}
+
+// isProxyEnv tells if the environment variable is a proxy one, which might have the proxy credentials
+func isProxyEnv(name string) bool {
+	for _, proxyEnv := range proxyEnvNames {
+		if strings.EqualFold(name, proxyEnv) {
+			return true
+		}
+	}
+
+	return false
+}
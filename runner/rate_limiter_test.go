@@ -0,0 +1,42 @@
+package runner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(100)
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		limiter.Wait(context.Background())
+	}
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(40*time.Millisecond))
+}
+
+func TestRateLimiter_Unlimited(t *testing.T) {
+	limiter := newRateLimiter(0)
+	assert.Nil(t, limiter)
+
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		limiter.Wait(context.Background())
+	}
+	assert.Less(t, int64(time.Since(start)), int64(10*time.Millisecond))
+}
+
+func TestRateLimiter_Cancelled(t *testing.T) {
+	limiter := newRateLimiter(1)
+	assert.NoError(t, limiter.Wait(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	assert.ErrorIs(t, limiter.Wait(ctx), context.Canceled)
+	assert.Less(t, int64(time.Since(start)), int64(100*time.Millisecond))
+}
@@ -0,0 +1,243 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/trento-project/runner/version"
+)
+
+const DefaultHeartbeatInterval = time.Second * 30
+
+var registrationTimeout = time.Second * 10
+
+// errNotRegistered is returned when the server does not know the runner, after a server restart
+var errNotRegistered = errors.New("the runner is not registered in the Trento server")
+
+type registrationRequest struct {
+	RunnerID     string   `json:"runner_id"`
+	Hostname     string   `json:"hostname"`
+	Version      string   `json:"version"`
+	Flavor       string   `json:"flavor"`
+	Capabilities []string `json:"capabilities"`
+}
+
+type heartbeatRequest struct {
+	RunnerID          string `json:"runner_id"`
+	CatalogReady      bool   `json:"catalog_ready"`
+	CatalogVersion    string `json:"catalog_version"`
+	Draining          bool   `json:"draining"`
+	RunningExecutions int    `json:"running_executions"`
+	QueuedExecutions  int    `json:"queued_executions"`
+}
+
+// Registrar registers the runner in the Trento server when it starts, and sends a heartbeat
+// with its health after each heartbeat interval, so the server can find the dead runners.
+// The runner is registered again if the server does not know it, after a server restart
+type Registrar struct {
+	config              *Config
+	runnerService       RunnerService
+	executionWorkerPool *ExecutionWorkerPool
+	httpClient          *http.Client
+	registered          bool
+	// The heartbeat interval can be changed while the registrar runs, when the configuration is reloaded
+	heartbeatInterval int64
+	intervalChanged   chan struct{}
+}
+
+func NewRegistrar(
+	config *Config, runnerService RunnerService, executionWorkerPool *ExecutionWorkerPool,
+	transport http.RoundTripper) *Registrar {
+
+	return &Registrar{
+		config:              config,
+		runnerService:       runnerService,
+		executionWorkerPool: executionWorkerPool,
+		httpClient:          &http.Client{Transport: transport, Timeout: registrationTimeout},
+		heartbeatInterval:   int64(config.HeartbeatInterval),
+		intervalChanged:     make(chan struct{}, 1),
+	}
+}
+
+// SetHeartbeatInterval changes the time between the heartbeats, starting from the next one
+func (r *Registrar) SetHeartbeatInterval(interval time.Duration) {
+	if previous := atomic.SwapInt64(&r.heartbeatInterval, int64(interval)); previous == int64(interval) {
+		return
+	}
+
+	select {
+	case r.intervalChanged <- struct{}{}:
+	default:
+	}
+}
+
+func (r *Registrar) interval() time.Duration {
+	interval := time.Duration(atomic.LoadInt64(&r.heartbeatInterval))
+	if interval <= 0 {
+		return DefaultHeartbeatInterval
+	}
+
+	return interval
+}
+
+// RunnerID returns the configured runner id, or the hostname if it is not set
+func RunnerID(config *Config) string {
+	if config.RunnerID != "" {
+		return config.RunnerID
+	}
+
+	hostname, _ := os.Hostname()
+	return hostname
+}
+
+// Run registers the runner and sends the heartbeats until the context is done
+func (r *Registrar) Run(ctx context.Context) {
+	log.Infof("Starting the registration in the Trento server as runner %s", RunnerID(r.config))
+
+	ticker := time.NewTicker(r.interval())
+	defer ticker.Stop()
+
+	for {
+		if err := r.beat(ctx); err != nil && ctx.Err() == nil {
+			log.Errorf("Error sending the heartbeat to the Trento server: %s", err)
+		}
+
+		if !r.waitHeartbeat(ctx, ticker) {
+			log.Infof("Registration in the Trento server is shutting down.")
+			return
+		}
+	}
+}
+
+// waitHeartbeat waits for the next heartbeat, resetting the ticker if the interval changes,
+// and tells if it is time to send it or the context is done
+func (r *Registrar) waitHeartbeat(ctx context.Context, ticker *time.Ticker) bool {
+	for {
+		select {
+		case <-ticker.C:
+			return true
+		case <-r.intervalChanged:
+			ticker.Reset(r.interval())
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// beat registers the runner if it is not registered yet, or sends the heartbeat otherwise
+func (r *Registrar) beat(ctx context.Context) error {
+	if !r.registered {
+		if err := r.register(ctx); err != nil {
+			return err
+		}
+		r.registered = true
+		log.Infof("Runner %s registered in the Trento server", RunnerID(r.config))
+		return nil
+	}
+
+	err := r.heartbeat(ctx)
+	if errors.Is(err, errNotRegistered) {
+		log.Warnf("The Trento server does not know the runner %s, registering it again", RunnerID(r.config))
+		r.registered = false
+		return r.beat(ctx)
+	}
+
+	return err
+}
+
+func (r *Registrar) register(ctx context.Context) error {
+	hostname, _ := os.Hostname()
+	info := version.GetInfo()
+
+	return r.post(ctx, r.config.ServerRegistrationUrl, &registrationRequest{
+		RunnerID:     RunnerID(r.config),
+		Hostname:     hostname,
+		Version:      info.Version,
+		Flavor:       info.Flavor,
+		Capabilities: runnerCapabilities(r.config),
+	})
+}
+
+// heartbeat sends the runner health to the heartbeat url of the runner, under the registration url
+func (r *Registrar) heartbeat(ctx context.Context) error {
+	request := &heartbeatRequest{
+		RunnerID:       RunnerID(r.config),
+		CatalogReady:   r.runnerService.IsCatalogReady(),
+		CatalogVersion: r.runnerService.GetCatalog().Version(),
+		Draining:       r.runnerService.IsDraining(),
+	}
+	if r.executionWorkerPool != nil {
+		request.RunningExecutions = r.executionWorkerPool.Running()
+		request.QueuedExecutions = r.executionWorkerPool.QueueDepth()
+	}
+
+	heartbeatUrl := fmt.Sprintf(
+		"%s/%s/heartbeat", strings.TrimSuffix(r.config.ServerRegistrationUrl, "/"), RunnerID(r.config))
+
+	return r.post(ctx, heartbeatUrl, request)
+}
+
+func (r *Registrar) post(ctx context.Context, url string, request interface{}) error {
+	requestBody, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(requestBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return errNotRegistered
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("the request to %s failed, status code: %d", url, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// runnerCapabilities returns the features enabled in the runner, so the server knows what it can request
+func runnerCapabilities(config *Config) []string {
+	capabilities := []string{config.CheckEngine}
+	if config.CheckEngine == "" {
+		capabilities = []string{AnsibleCheckEngine}
+	}
+
+	optional := []struct {
+		name    string
+		enabled bool
+	}{
+		{"grpc", config.GrpcPort != 0},
+		{"amqp", config.AmqpUrl != ""},
+		{"schedules", config.Schedules != ""},
+		{"dry_run", config.DryRun},
+		{"premium", config.ServerSubscriptionUrl != ""},
+		{"ansible_container", config.AnsibleContainerImage != ""},
+	}
+	for _, capability := range optional {
+		if capability.enabled {
+			capabilities = append(capabilities, capability.name)
+		}
+	}
+
+	return capabilities
+}
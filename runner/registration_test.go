@@ -0,0 +1,117 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type registrationServer struct {
+	mu       sync.Mutex
+	requests []string
+	bodies   []map[string]interface{}
+	// known tells if the server knows the runner, it forgets it to simulate a server restart
+	known bool
+}
+
+func (s *registrationServer) handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		s.requests = append(s.requests, r.URL.Path)
+		s.bodies = append(s.bodies, body)
+
+		if r.URL.Path == "/api/runners" {
+			s.known = true
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		if !s.known {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+func TestRegistrar(t *testing.T) {
+	registration := &registrationServer{}
+	server := httptest.NewServer(registration.handler())
+	defer server.Close()
+
+	runnerService := new(MockRunnerService)
+	runnerService.On("IsCatalogReady").Return(true)
+	runnerService.On("GetCatalog").Return(&Catalog{&CatalogCheck{ID: "156F64"}})
+	runnerService.On("IsDraining").Return(false)
+
+	config := &Config{ServerRegistrationUrl: server.URL + "/api/runners", RunnerID: "runner1", GrpcPort: 50051}
+	registrar := NewRegistrar(config, runnerService, nil, nil)
+
+	assert.NoError(t, registrar.beat(context.Background()))
+	assert.NoError(t, registrar.beat(context.Background()))
+
+	// The server restarts, the runner is registered again
+	registration.known = false
+	assert.NoError(t, registrar.beat(context.Background()))
+
+	assert.Equal(t, []string{
+		"/api/runners", "/api/runners/runner1/heartbeat", "/api/runners/runner1/heartbeat", "/api/runners"},
+		registration.requests)
+
+	assert.Equal(t, "runner1", registration.bodies[0]["runner_id"])
+	assert.Equal(t, []interface{}{"ansible", "grpc"}, registration.bodies[0]["capabilities"])
+	assert.Equal(t, true, registration.bodies[1]["catalog_ready"])
+	assert.Equal(t, (&Catalog{&CatalogCheck{ID: "156F64"}}).Version(), registration.bodies[1]["catalog_version"])
+	assert.Equal(t, false, registration.bodies[1]["draining"])
+}
+
+func TestRegistrar_SetHeartbeatInterval(t *testing.T) {
+	registration := &registrationServer{}
+	server := httptest.NewServer(registration.handler())
+	defer server.Close()
+
+	runnerService := new(MockRunnerService)
+	runnerService.On("IsCatalogReady").Return(true)
+	runnerService.On("GetCatalog").Return(&Catalog{})
+	runnerService.On("IsDraining").Return(false)
+
+	config := &Config{ServerRegistrationUrl: server.URL + "/api/runners", RunnerID: "runner1", HeartbeatInterval: time.Hour}
+	registrar := NewRegistrar(config, runnerService, nil, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go registrar.Run(ctx)
+
+	requests := func() int {
+		registration.mu.Lock()
+		defer registration.mu.Unlock()
+		return len(registration.requests)
+	}
+	assert.Eventually(t, func() bool { return requests() == 1 }, time.Second, 5*time.Millisecond)
+
+	// The heartbeats are sent with the new interval, without waiting for the previous one
+	registrar.SetHeartbeatInterval(10 * time.Millisecond)
+	assert.Eventually(t, func() bool { return requests() >= 3 }, time.Second, 5*time.Millisecond)
+}
+
+func TestRegistrar_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	config := &Config{ServerRegistrationUrl: server.URL + "/api/runners", RunnerID: "runner1"}
+	registrar := NewRegistrar(config, new(MockRunnerService), nil, nil)
+
+	assert.EqualError(
+		t, registrar.beat(context.Background()),
+		"the request to "+server.URL+"/api/runners failed, status code: 500")
+	assert.False(t, registrar.registered)
+}
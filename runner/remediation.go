@@ -0,0 +1,90 @@
+package runner
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// remediate runs the failing remediable checks of the execution out of the ansible check mode, applying their fix
+// in the hosts, and then checks them again. The results of the remediated checks are replaced with the new ones
+func (c *runnerService) remediate(
+	ctx context.Context, e *ExecutionEvent, results *ExecutionResults, outputHandler func(stream, line string)) (*ExecutionResults, error) {
+
+	logger := loggerFromContext(ctx)
+	if c.config.CheckEngine == NativeCheckEngine {
+		logger.Warnf("The native check engine does not remediate the checks, execution %s is only checked", e.ExecutionID.String())
+		return results, nil
+	}
+
+	remediationEvent := c.selectRemediableChecks(e, results)
+	if len(remediationEvent.Limit) == 0 {
+		logger.Infof("None of the failing checks of execution %s is remediable", e.ExecutionID.String())
+		return results, nil
+	}
+
+	remediated := make(map[string][]string, len(remediationEvent.Limit))
+	for _, host := range remediationEvent.targetHosts() {
+		remediated[host.HostID.String()] = host.checks
+	}
+	logger.Warnf("Remediating the failing checks of execution %s: %v", e.ExecutionID.String(), remediated)
+
+	remediationEvent.remediation = true
+	if _, err := c.checkEngine.Run(ctx, remediationEvent, outputHandler); err != nil {
+		return nil, err
+	}
+	c.dispatchCallback(e, checksRemediatedEvent, map[string]interface{}{
+		"cluster_id": e.ClusterID.String(),
+		"hosts":      remediated,
+	})
+
+	verificationEvent := *remediationEvent
+	verificationEvent.remediation = false
+	verifiedResults, err := c.checkEngine.Run(ctx, &verificationEvent, outputHandler)
+	if err != nil {
+		return nil, err
+	}
+	results.replaceChecksResults(verifiedResults)
+
+	return results, nil
+}
+
+// selectRemediableChecks returns a copy of the execution event limited to the hosts with failing checks that are
+// remediable in the catalog and selected to be remediated, which are the checks run in each of those hosts
+func (c *runnerService) selectRemediableChecks(e *ExecutionEvent, results *ExecutionResults) *ExecutionEvent {
+	remediable := map[string]bool{}
+	if catalog := c.GetCatalog(); catalog != nil {
+		for _, check := range *catalog {
+			remediable[check.ID] = check.Remediable
+		}
+	}
+
+	selected := map[string]bool{}
+	for _, checkID := range e.RemediateChecks {
+		selected[checkID] = true
+	}
+
+	hostsChecks := map[string][]string{}
+	for _, host := range results.Hosts {
+		for _, result := range host.Results {
+			failing := result.Result == checkResultWarning || result.Result == checkResultCritical
+			if failing && remediable[result.CheckID] && (len(selected) == 0 || selected[result.CheckID]) {
+				hostsChecks[host.HostID] = append(hostsChecks[host.HostID], result.CheckID)
+			}
+		}
+	}
+
+	remediationEvent := *e
+	remediationEvent.Hosts = make([]*Host, 0, len(e.Hosts))
+	remediationEvent.Limit = []uuid.UUID{}
+	for _, host := range e.Hosts {
+		remediationHost := *host
+		if checks, ok := hostsChecks[host.HostID.String()]; ok && e.isTarget(host) {
+			remediationHost.checks = checks
+			remediationEvent.Limit = append(remediationEvent.Limit, host.HostID)
+		}
+		remediationEvent.Hosts = append(remediationEvent.Hosts, &remediationHost)
+	}
+
+	return &remediationEvent
+}
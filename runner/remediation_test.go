@@ -0,0 +1,130 @@
+package runner
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+)
+
+// checkEngineFunc runs the checks with the given function
+type checkEngineFunc func(e *ExecutionEvent) (*ExecutionResults, error)
+
+func (f checkEngineFunc) Run(
+	ctx context.Context, e *ExecutionEvent, outputHandler func(stream, line string)) (*ExecutionResults, error) {
+	return f(e)
+}
+
+type RemediationTestSuite struct {
+	suite.Suite
+	tmpDir        string
+	runnerService *runnerService
+	host1ID       uuid.UUID
+	host2ID       uuid.UUID
+	execution     *ExecutionEvent
+}
+
+func TestRemediationTestSuite(t *testing.T) {
+	suite.Run(t, new(RemediationTestSuite))
+}
+
+func (suite *RemediationTestSuite) SetupTest() {
+	suite.tmpDir, _ = ioutil.TempDir(os.TempDir(), "trentotest")
+	suite.runnerService, _ = NewRunnerService(&Config{AnsibleFolder: suite.tmpDir})
+	suite.runnerService.setCatalog(&Catalog{
+		&CatalogCheck{ID: "156F64", Remediable: true},
+		&CatalogCheck{ID: "53D035", Remediable: true},
+		&CatalogCheck{ID: "21FCA6"},
+	}, true)
+
+	suite.host1ID = uuid.New()
+	suite.host2ID = uuid.New()
+	suite.execution = &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Checks:      []string{"156F64", "53D035", "21FCA6"},
+		Hosts: []*Host{
+			&Host{HostID: suite.host1ID, Address: "192.168.10.1", User: "user1"},
+			&Host{HostID: suite.host2ID, Address: "192.168.10.2", User: "user2"},
+		},
+		Remediate: true,
+	}
+}
+
+func (suite *RemediationTestSuite) TearDownTest() {
+	os.RemoveAll(suite.tmpDir)
+}
+
+func (suite *RemediationTestSuite) results(host1Result, host2Result string) *ExecutionResults {
+	results := &ExecutionResults{ClusterID: suite.execution.ClusterID.String(), Hosts: []*HostResults{}}
+	for index, hostID := range []uuid.UUID{suite.host1ID, suite.host2ID} {
+		results.addHost(hostID.String(), true, "")
+		results.addResult(hostID.String(), "156F64", []string{host1Result, host2Result}[index], "")
+		results.addResult(hostID.String(), "53D035", "passing", "")
+		results.addResult(hostID.String(), "21FCA6", "critical", "")
+	}
+
+	return results
+}
+
+func (suite *RemediationTestSuite) Test_SelectRemediableChecks() {
+	remediationEvent := suite.runnerService.selectRemediableChecks(suite.execution, suite.results("warning", "passing"))
+
+	// Only the failing remediable checks are run, in the hosts where they failed
+	suite.Equal([]uuid.UUID{suite.host1ID}, remediationEvent.Limit)
+	suite.Equal([]string{"156F64"}, remediationEvent.hostChecks(remediationEvent.Hosts[0]))
+	suite.Len(remediationEvent.Hosts, 2)
+
+	suite.execution.RemediateChecks = []string{"53D035"}
+	remediationEvent = suite.runnerService.selectRemediableChecks(suite.execution, suite.results("warning", "passing"))
+	suite.Empty(remediationEvent.Limit)
+}
+
+func (suite *RemediationTestSuite) Test_Remediate() {
+	runs := []*ExecutionEvent{}
+	suite.runnerService.checkEngine = checkEngineFunc(func(e *ExecutionEvent) (*ExecutionResults, error) {
+		runs = append(runs, e)
+		if e.remediation {
+			return nil, nil
+		}
+		results := &ExecutionResults{ClusterID: e.ClusterID.String(), Hosts: []*HostResults{}}
+		results.addHost(suite.host2ID.String(), true, "")
+		results.addResult(suite.host2ID.String(), "156F64", "passing", "")
+		return results, nil
+	})
+
+	results, err := suite.runnerService.remediate(
+		context.Background(), suite.execution, suite.results("passing", "critical"), func(stream, line string) {})
+	suite.NoError(err)
+
+	// The checks are fixed out of the check mode, and then checked again
+	suite.Len(runs, 2)
+	suite.True(runs[0].remediation)
+	suite.False(runs[1].remediation)
+	suite.Equal([]uuid.UUID{suite.host2ID}, runs[0].Limit)
+	suite.Equal([]string{"156F64"}, runs[0].Hosts[1].checks)
+
+	suite.Equal(suite.results("passing", "passing"), results)
+
+	request := <-suite.runnerService.callbacksDispatcher.queue
+	suite.Equal(checksRemediatedEvent, request.event)
+	suite.Equal(map[string]interface{}{
+		"cluster_id": suite.execution.ClusterID.String(),
+		"hosts":      map[string][]string{suite.host2ID.String(): []string{"156F64"}},
+	}, request.payload)
+}
+
+func (suite *RemediationTestSuite) Test_Remediate_NothingRemediable() {
+	suite.runnerService.checkEngine = checkEngineFunc(func(e *ExecutionEvent) (*ExecutionResults, error) {
+		suite.Fail("no checks must be run")
+		return nil, nil
+	})
+
+	results, err := suite.runnerService.remediate(
+		context.Background(), suite.execution, suite.results("passing", "passing"), func(stream, line string) {})
+	suite.NoError(err)
+	suite.Equal(suite.results("passing", "passing"), results)
+}
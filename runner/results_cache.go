@@ -0,0 +1,72 @@
+package runner
+
+import (
+	"sync"
+	"time"
+)
+
+type resultKey struct {
+	hostID         string
+	checkID        string
+	catalogVersion string
+}
+
+type cachedResult struct {
+	result    *CheckResult
+	expiresAt time.Time
+}
+
+// ResultsCache keeps the recent checks results of each host, so the checks are not run again
+// until the results expire. Changing the catalog invalidates the results, as the checks might be different
+type ResultsCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	results map[resultKey]*cachedResult
+}
+
+func NewResultsCache(ttl time.Duration) *ResultsCache {
+	return &ResultsCache{
+		ttl:     ttl,
+		results: make(map[resultKey]*cachedResult),
+	}
+}
+
+// Get returns the cached results of the given checks in a host, and the checks that must be run
+func (r *ResultsCache) Get(hostID, catalogVersion string, checks []string) ([]*CheckResult, []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	cached := []*CheckResult{}
+	pending := []string{}
+
+	for _, checkID := range checks {
+		key := resultKey{hostID: hostID, checkID: checkID, catalogVersion: catalogVersion}
+		entry, ok := r.results[key]
+		if !ok || now.After(entry.expiresAt) {
+			delete(r.results, key)
+			pending = append(pending, checkID)
+			continue
+		}
+		cached = append(cached, entry.result)
+	}
+
+	return cached, pending
+}
+
+// Store caches the results of the reachable hosts
+func (r *ResultsCache) Store(catalogVersion string, results *ExecutionResults) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	expiresAt := time.Now().Add(r.ttl)
+	for _, host := range results.Hosts {
+		if !host.Reachable {
+			continue
+		}
+		for _, result := range host.Results {
+			key := resultKey{hostID: host.HostID, checkID: result.CheckID, catalogVersion: catalogVersion}
+			r.results[key] = &cachedResult{result: result, expiresAt: expiresAt}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package runner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResultsCache(t *testing.T) {
+	cache := NewResultsCache(time.Hour)
+	cache.Store("v1", &ExecutionResults{
+		Hosts: []*HostResults{
+			&HostResults{
+				HostID:    "host1",
+				Reachable: true,
+				Results:   []*CheckResult{&CheckResult{CheckID: "156F64", Result: "passing"}},
+			},
+			&HostResults{
+				HostID:    "host2",
+				Reachable: false,
+				Results:   []*CheckResult{&CheckResult{CheckID: "156F64", Result: "critical"}},
+			},
+		},
+	})
+
+	cached, pending := cache.Get("host1", "v1", []string{"156F64", "A1244C"})
+	assert.Equal(t, []*CheckResult{&CheckResult{CheckID: "156F64", Result: "passing"}}, cached)
+	assert.Equal(t, []string{"A1244C"}, pending)
+
+	// The unreachable hosts results are not cached
+	cached, pending = cache.Get("host2", "v1", []string{"156F64"})
+	assert.Empty(t, cached)
+	assert.Equal(t, []string{"156F64"}, pending)
+
+	// A new catalog invalidates the results
+	cached, pending = cache.Get("host1", "v2", []string{"156F64"})
+	assert.Empty(t, cached)
+	assert.Equal(t, []string{"156F64"}, pending)
+}
+
+func TestResultsCache_Expired(t *testing.T) {
+	cache := NewResultsCache(time.Millisecond)
+	cache.Store("v1", &ExecutionResults{
+		Hosts: []*HostResults{
+			&HostResults{
+				HostID:    "host1",
+				Reachable: true,
+				Results:   []*CheckResult{&CheckResult{CheckID: "156F64", Result: "passing"}},
+			},
+		},
+	})
+
+	time.Sleep(2 * time.Millisecond)
+
+	cached, pending := cache.Get("host1", "v1", []string{"156F64"})
+	assert.Empty(t, cached)
+	assert.Equal(t, []string{"156F64"}, pending)
+	assert.Empty(t, cache.results)
+}
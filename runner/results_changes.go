@@ -0,0 +1,113 @@
+package runner
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type reportedHost struct {
+	reachable bool
+	msg       string
+}
+
+// resyncDecision is whether the results of the cluster execution are all sent, as an execution reports its results
+// in several calls, e.g. the unreachable hosts before the checks results
+type resyncDecision struct {
+	executionID uuid.UUID
+	resync      bool
+}
+
+type reportedResultKey struct {
+	clusterID string
+	hostID    string
+	checkID   string
+}
+
+// ChangesFilter keeps the last reported result of each cluster host and check, so only the changed
+// results are sent to the server. All the results of a cluster are sent again once the resync interval
+// is elapsed, so the server state is fixed if a callback is lost
+type ChangesFilter struct {
+	mu             sync.Mutex
+	resyncInterval time.Duration
+	lastResync     map[string]time.Time
+	decisions      map[string]resyncDecision
+	hosts          map[reportedResultKey]*reportedHost
+	results        map[reportedResultKey]string
+}
+
+func NewChangesFilter(resyncInterval time.Duration) *ChangesFilter {
+	return &ChangesFilter{
+		resyncInterval: resyncInterval,
+		lastResync:     make(map[string]time.Time),
+		decisions:      make(map[string]resyncDecision),
+		hosts:          make(map[reportedResultKey]*reportedHost),
+		results:        make(map[reportedResultKey]string),
+	}
+}
+
+// SetResyncInterval changes the time after which all the results of a cluster are sent again
+func (f *ChangesFilter) SetResyncInterval(resyncInterval time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.resyncInterval = resyncInterval
+}
+
+// Filter returns the hosts whose reachability changed and the checks results that changed since they were
+// reported, or all of them if it is a full resync. The host is kept if any of its checks results changed.
+// The full resync is decided once per execution, in its first results
+func (f *ChangesFilter) Filter(executionID uuid.UUID, results *ExecutionResults) *ExecutionResults {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	resync := f.resync(executionID, results.ClusterID)
+
+	changed := &ExecutionResults{ClusterID: results.ClusterID, Hosts: []*HostResults{}}
+	for _, host := range results.Hosts {
+		hostKey := reportedResultKey{clusterID: results.ClusterID, hostID: host.HostID}
+		previousHost, ok := f.hosts[hostKey]
+		hostChanged := !ok || previousHost.reachable != host.Reachable || previousHost.msg != host.Msg
+		f.hosts[hostKey] = &reportedHost{reachable: host.Reachable, msg: host.Msg}
+
+		changedResults := []*CheckResult{}
+		for _, result := range host.Results {
+			resultKey := reportedResultKey{clusterID: results.ClusterID, hostID: host.HostID, checkID: result.CheckID}
+			previousResult, ok := f.results[resultKey]
+			if resync || !ok || previousResult != result.Result {
+				changedResults = append(changedResults, result)
+			}
+			f.results[resultKey] = result.Result
+		}
+
+		if !resync && !hostChanged && len(changedResults) == 0 {
+			continue
+		}
+
+		changed.Hosts = append(changed.Hosts, &HostResults{
+			HostID:    host.HostID,
+			Reachable: host.Reachable,
+			Msg:       host.Msg,
+			Results:   changedResults,
+		})
+	}
+
+	return changed
+}
+
+func (f *ChangesFilter) resync(executionID uuid.UUID, clusterID string) bool {
+	if decision, ok := f.decisions[clusterID]; ok && decision.executionID == executionID {
+		return decision.resync
+	}
+
+	now := time.Now()
+	lastResync, ok := f.lastResync[clusterID]
+	resync := !ok || (f.resyncInterval > 0 && now.Sub(lastResync) >= f.resyncInterval)
+	if resync {
+		f.lastResync[clusterID] = now
+	}
+	f.decisions[clusterID] = resyncDecision{executionID: executionID, resync: resync}
+
+	return resync
+}
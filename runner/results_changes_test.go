@@ -0,0 +1,87 @@
+package runner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func changesFilterResults(reachable bool, results ...*CheckResult) *ExecutionResults {
+	return &ExecutionResults{
+		ClusterID: "cluster1",
+		Hosts:     []*HostResults{&HostResults{HostID: "host1", Reachable: reachable, Results: results}},
+	}
+}
+
+func TestChangesFilter(t *testing.T) {
+	filter := NewChangesFilter(0)
+
+	// Everything is reported the first time
+	changed := filter.Filter(uuid.New(), changesFilterResults(
+		true, &CheckResult{CheckID: "A", Result: "passing"}, &CheckResult{CheckID: "B", Result: "passing"}))
+	assert.Len(t, changed.Hosts, 1)
+	assert.Len(t, changed.Hosts[0].Results, 2)
+
+	changed = filter.Filter(uuid.New(), changesFilterResults(
+		true, &CheckResult{CheckID: "A", Result: "passing"}, &CheckResult{CheckID: "B", Result: "passing"}))
+	assert.Equal(t, "cluster1", changed.ClusterID)
+	assert.Empty(t, changed.Hosts)
+
+	changed = filter.Filter(uuid.New(), changesFilterResults(
+		true, &CheckResult{CheckID: "A", Result: "passing"}, &CheckResult{CheckID: "B", Result: "critical"},
+		&CheckResult{CheckID: "C", Result: "warning"}))
+	assert.Len(t, changed.Hosts, 1)
+	assert.Equal(t, []*CheckResult{
+		&CheckResult{CheckID: "B", Result: "critical"}, &CheckResult{CheckID: "C", Result: "warning"}},
+		changed.Hosts[0].Results)
+
+	changed = filter.Filter(uuid.New(), changesFilterResults(false))
+	assert.Len(t, changed.Hosts, 1)
+	assert.False(t, changed.Hosts[0].Reachable)
+	assert.Empty(t, changed.Hosts[0].Results)
+}
+
+func TestChangesFilter_Resync(t *testing.T) {
+	filter := NewChangesFilter(time.Hour)
+
+	filter.Filter(uuid.New(), changesFilterResults(true, &CheckResult{CheckID: "A", Result: "passing"}))
+	assert.Empty(t, filter.Filter(uuid.New(), changesFilterResults(true, &CheckResult{CheckID: "A", Result: "passing"})).Hosts)
+
+	filter.lastResync["cluster1"] = time.Now().Add(-2 * time.Hour)
+	changed := filter.Filter(uuid.New(), changesFilterResults(true, &CheckResult{CheckID: "A", Result: "passing"}))
+	assert.Len(t, changed.Hosts, 1)
+	assert.Len(t, changed.Hosts[0].Results, 1)
+
+	assert.Empty(t, filter.Filter(uuid.New(), changesFilterResults(true, &CheckResult{CheckID: "A", Result: "passing"})).Hosts)
+}
+
+func TestChangesFilter_SetResyncInterval(t *testing.T) {
+	filter := NewChangesFilter(0)
+
+	filter.Filter(uuid.New(), changesFilterResults(true, &CheckResult{CheckID: "A", Result: "passing"}))
+	filter.lastResync["cluster1"] = time.Now().Add(-2 * time.Hour)
+	assert.Empty(t, filter.Filter(uuid.New(), changesFilterResults(true, &CheckResult{CheckID: "A", Result: "passing"})).Hosts)
+
+	filter.SetResyncInterval(time.Hour)
+	assert.Len(t, filter.Filter(uuid.New(), changesFilterResults(true, &CheckResult{CheckID: "A", Result: "passing"})).Hosts, 1)
+}
+
+func TestChangesFilter_ResyncOncePerExecution(t *testing.T) {
+	filter := NewChangesFilter(time.Hour)
+	filter.Filter(uuid.New(), changesFilterResults(true, &CheckResult{CheckID: "A", Result: "passing"}))
+
+	// The execution reporting the unreachable hosts first does not resync
+	executionID := uuid.New()
+	assert.Empty(t, filter.Filter(executionID, changesFilterResults(true)).Hosts)
+	filter.lastResync["cluster1"] = time.Now().Add(-2 * time.Hour)
+	assert.Empty(t, filter.Filter(executionID, changesFilterResults(true, &CheckResult{CheckID: "A", Result: "passing"})).Hosts)
+
+	// All the results of the execution doing the resync are sent
+	executionID = uuid.New()
+	assert.Len(t, filter.Filter(executionID, changesFilterResults(true)).Hosts, 1)
+	changed := filter.Filter(executionID, changesFilterResults(true, &CheckResult{CheckID: "A", Result: "passing"}))
+	assert.Len(t, changed.Hosts, 1)
+	assert.Len(t, changed.Hosts[0].Results, 1)
+}
@@ -0,0 +1,184 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// ExecutionReport is the outcome of an execution, written in the results folder
+type ExecutionReport struct {
+	ExecutionID uuid.UUID      `json:"execution_id"`
+	ClusterID   string         `json:"cluster_id"`
+	Status      string         `json:"status"`
+	Reason      string         `json:"reason,omitempty"`
+	FinishedAt  time.Time      `json:"finished_at"`
+	Hosts       []*HostResults `json:"hosts"`
+}
+
+// callbackPayload has the fields of the payloads of all the callbacks events
+type callbackPayload struct {
+	ClusterID string `json:"cluster_id"`
+	HostID    string `json:"host_id"`
+	Reachable bool   `json:"reachable"`
+	Msg       string `json:"msg"`
+	CheckID   string `json:"check_id"`
+	Result    string `json:"result"`
+	Reason    string `json:"reason"`
+}
+
+// reportCollector collects the callbacks of each execution, building its report once it is finished
+type reportCollector struct {
+	mu         sync.Mutex
+	executions map[uuid.UUID]*ExecutionResults
+}
+
+func newReportCollector() *reportCollector {
+	return &reportCollector{executions: make(map[uuid.UUID]*ExecutionResults)}
+}
+
+// collect adds the callback to the results of its execution, and returns the execution report
+// if the callback finishes the execution. The report is nil otherwise
+func (r *reportCollector) collect(executionID uuid.UUID, event string, payload interface{}) (*ExecutionReport, error) {
+	content, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields callbackPayload
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	results, ok := r.executions[executionID]
+	if !ok {
+		results = &ExecutionResults{ClusterID: fields.ClusterID, Hosts: []*HostResults{}}
+		r.executions[executionID] = results
+	}
+
+	switch event {
+	case hostCompletedEvent:
+		results.addHost(fields.HostID, fields.Reachable, fields.Msg)
+	case checkResultEvent:
+		results.addResult(fields.HostID, fields.CheckID, fields.Result, fields.Msg)
+	case executionFinishedEvent, executionFailedEvent:
+		report := &ExecutionReport{
+			ExecutionID: executionID,
+			ClusterID:   results.ClusterID,
+			Status:      ExecutionCompleted,
+			FinishedAt:  time.Now().UTC(),
+			Hosts:       results.Hosts,
+		}
+		if event == executionFailedEvent {
+			report.Status = ExecutionFailed
+			report.Reason = fields.Reason
+		}
+		delete(r.executions, executionID)
+
+		return report, nil
+	}
+
+	return nil, nil
+}
+
+// resultsDirClient collects the callbacks of each execution and writes its report in the results folder,
+// in each of the configured formats, once it is finished, so the checks can be run without a Trento server
+type resultsDirClient struct {
+	*reportCollector
+	folder    string
+	exporters []ResultsExporter
+	signer    *ResultsSigner
+}
+
+func NewResultsDirClient(folder string, formats []string) (*resultsDirClient, error) {
+	if len(formats) == 0 {
+		formats = []string{JSONResultsFormat}
+	}
+
+	exporters := []ResultsExporter{}
+	for _, format := range formats {
+		exporter, err := NewResultsExporter(format)
+		if err != nil {
+			return nil, err
+		}
+		exporters = append(exporters, exporter)
+	}
+
+	return &resultsDirClient{
+		reportCollector: newReportCollector(),
+		folder:          folder,
+		exporters:       exporters,
+	}, nil
+}
+
+// SetSigner writes the detached JWS of each report file next to it, with the results signature extension.
+// The reports are not signed if the signer is nil
+func (r *resultsDirClient) SetSigner(signer *ResultsSigner) {
+	r.signer = signer
+}
+
+func (r *resultsDirClient) Callback(executionID uuid.UUID, event string, payload interface{}) error {
+	report, err := r.collect(executionID, event, payload)
+	if err != nil || report == nil {
+		return err
+	}
+
+	return r.Consume(report)
+}
+
+// Consume writes the report in the results folder, so the results folder is an execution sink as well
+func (r *resultsDirClient) Consume(report *ExecutionReport) error {
+	if err := os.MkdirAll(r.folder, 0755); err != nil {
+		return err
+	}
+
+	for _, exporter := range r.exporters {
+		destination := path.Join(r.folder, report.ExecutionID.String()+exporter.Extension())
+		if err := exportReport(destination, exporter, report, r.signer); err != nil {
+			return err
+		}
+		log.Infof("Execution %s results written in %s", report.ExecutionID.String(), destination)
+	}
+
+	return nil
+}
+
+// exportReport creates the report file atomically, so the files in the folder are always complete.
+// The signature is written before the report, so a complete report always has its signature
+func exportReport(destination string, exporter ResultsExporter, report *ExecutionReport, signer *ResultsSigner) error {
+	var content bytes.Buffer
+	if err := exporter.Export(&content, report); err != nil {
+		return err
+	}
+
+	if signer != nil {
+		signature, err := signer.Sign(content.Bytes())
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomically(destination+resultsSignatureExtension, []byte(signature)); err != nil {
+			return err
+		}
+	}
+
+	return writeFileAtomically(destination, content.Bytes())
+}
+
+func writeFileAtomically(destination string, content []byte) error {
+	tmpFile := destination + ".tmp"
+	if err := ioutil.WriteFile(tmpFile, content, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile, destination)
+}
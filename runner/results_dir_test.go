@@ -0,0 +1,116 @@
+package runner
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResultsDirClient(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	client, err := NewResultsDirClient(path.Join(tmpDir, "results"), nil)
+	assert.NoError(t, err)
+	executionID := uuid.New()
+	clusterID := uuid.New().String()
+
+	assert.NoError(t, client.Callback(executionID, executionStartedEvent, map[string]string{"cluster_id": clusterID}))
+	assert.NoError(t, client.Callback(executionID, hostCompletedEvent, map[string]interface{}{
+		"cluster_id": clusterID, "host_id": "host1", "reachable": true, "msg": ""}))
+	assert.NoError(t, client.Callback(executionID, checkResultEvent, map[string]interface{}{
+		"cluster_id": clusterID, "host_id": "host1", "check_id": "156F64", "result": "passing", "msg": ""}))
+
+	reportFile := path.Join(tmpDir, "results", executionID.String()+".json")
+	assert.NoFileExists(t, reportFile)
+
+	assert.NoError(t, client.Callback(executionID, executionFinishedEvent, map[string]string{"cluster_id": clusterID}))
+
+	content, err := ioutil.ReadFile(reportFile)
+	assert.NoError(t, err)
+
+	var report *ExecutionReport
+	assert.NoError(t, json.Unmarshal(content, &report))
+	assert.Equal(t, executionID, report.ExecutionID)
+	assert.Equal(t, clusterID, report.ClusterID)
+	assert.Equal(t, ExecutionCompleted, report.Status)
+	assert.Equal(t, []*HostResults{&HostResults{
+		HostID:    "host1",
+		Reachable: true,
+		Results:   []*CheckResult{&CheckResult{CheckID: "156F64", Result: "passing"}},
+	}}, report.Hosts)
+	assert.Empty(t, client.executions)
+}
+
+func TestResultsDirClient_Failed(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	client, err := NewResultsDirClient(tmpDir, []string{"json"})
+	assert.NoError(t, err)
+	executionID := uuid.New()
+
+	assert.NoError(t, client.Callback(executionID, executionFailedEvent, map[string]string{
+		"cluster_id": "cluster1", "reason": "exit status 2"}))
+
+	content, err := ioutil.ReadFile(path.Join(tmpDir, executionID.String()+".json"))
+	assert.NoError(t, err)
+
+	var report *ExecutionReport
+	assert.NoError(t, json.Unmarshal(content, &report))
+	assert.Equal(t, ExecutionFailed, report.Status)
+	assert.Equal(t, "exit status 2", report.Reason)
+	assert.Empty(t, report.Hosts)
+}
+
+func TestResultsDirClient_Formats(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	client, err := NewResultsDirClient(tmpDir, []string{"junit", "csv"})
+	assert.NoError(t, err)
+	executionID := uuid.New()
+
+	assert.NoError(t, client.Callback(executionID, executionFinishedEvent, map[string]string{"cluster_id": "cluster1"}))
+
+	assert.FileExists(t, path.Join(tmpDir, executionID.String()+".xml"))
+	assert.FileExists(t, path.Join(tmpDir, executionID.String()+".csv"))
+	assert.NoFileExists(t, path.Join(tmpDir, executionID.String()+".json"))
+
+	_, err = NewResultsDirClient(tmpDir, []string{"html"})
+	assert.EqualError(t, err, "results format html is not supported")
+}
+
+func TestResultsDirClient_Signed(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	signer, err := NewResultsSigner(&Config{ResultsSigningKey: writeSigningKey(t, tmpDir, key)})
+	assert.NoError(t, err)
+
+	client, err := NewResultsDirClient(path.Join(tmpDir, "results"), []string{"json", "junit"})
+	assert.NoError(t, err)
+	client.SetSigner(signer)
+	executionID := uuid.New()
+
+	assert.NoError(t, client.Callback(executionID, executionFinishedEvent, map[string]string{"cluster_id": "cluster1"}))
+
+	for _, extension := range []string{".json", ".xml"} {
+		reportFile := path.Join(tmpDir, "results", executionID.String()+extension)
+		content, err := ioutil.ReadFile(reportFile)
+		assert.NoError(t, err)
+		signature, err := ioutil.ReadFile(reportFile + resultsSignatureExtension)
+		assert.NoError(t, err)
+
+		_, err = verifyDetachedJWS(string(signature), content, key.Public())
+		assert.NoError(t, err, extension)
+	}
+}
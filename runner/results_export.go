@@ -0,0 +1,171 @@
+package runner
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Formats of the results files written in the results folder
+const (
+	JSONResultsFormat  = "json"
+	JUnitResultsFormat = "junit"
+	CSVResultsFormat   = "csv"
+)
+
+// ResultsExporter writes an execution report in a file format
+type ResultsExporter interface {
+	Extension() string
+	Export(w io.Writer, report *ExecutionReport) error
+}
+
+var resultsExporters = map[string]ResultsExporter{
+	JSONResultsFormat:  jsonExporter{},
+	JUnitResultsFormat: junitExporter{},
+	CSVResultsFormat:   csvExporter{},
+}
+
+// NewResultsExporter returns the exporter of the given format
+func NewResultsExporter(format string) (ResultsExporter, error) {
+	exporter, ok := resultsExporters[format]
+	if !ok {
+		return nil, fmt.Errorf("results format %s is not supported", format)
+	}
+
+	return exporter, nil
+}
+
+type jsonExporter struct{}
+
+func (jsonExporter) Extension() string {
+	return ".json"
+}
+
+func (jsonExporter) Export(w io.Writer, report *ExecutionReport) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(report)
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name          `xml:"testsuites"`
+	Name     string            `xml:"name,attr"`
+	Tests    int               `xml:"tests,attr"`
+	Failures int               `xml:"failures,attr"`
+	Errors   int               `xml:"errors,attr"`
+	Suites   []*junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string           `xml:"name,attr"`
+	Tests     int              `xml:"tests,attr"`
+	Failures  int              `xml:"failures,attr"`
+	Errors    int              `xml:"errors,attr"`
+	Skipped   int              `xml:"skipped,attr"`
+	Timestamp string           `xml:"timestamp,attr"`
+	TestCases []*junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Content string `xml:",chardata"`
+}
+
+// junitExporter writes a test suite by host, with a test case by check. The warning and critical results
+// are failures, so the CI pipelines can gate on them, and the unreachable hosts are errors
+type junitExporter struct{}
+
+func (junitExporter) Extension() string {
+	return ".xml"
+}
+
+func (junitExporter) Export(w io.Writer, report *ExecutionReport) error {
+	suites := &junitTestSuites{Name: fmt.Sprintf("trento-%s", report.ExecutionID.String())}
+	timestamp := report.FinishedAt.Format("2006-01-02T15:04:05")
+
+	for _, host := range report.Hosts {
+		suite := &junitTestSuite{Name: host.HostID, Timestamp: timestamp, TestCases: []*junitTestCase{}}
+
+		if !host.Reachable {
+			suite.Errors++
+			suite.TestCases = append(suite.TestCases, &junitTestCase{
+				ClassName: host.HostID,
+				Name:      "reachable",
+				Error:     &junitMessage{Message: "unreachable", Content: host.Msg},
+			})
+		}
+
+		for _, result := range host.Results {
+			testCase := &junitTestCase{ClassName: host.HostID, Name: result.CheckID}
+			switch result.Result {
+			case checkResultPassing:
+			case checkResultSkipped:
+				suite.Skipped++
+				testCase.Skipped = &junitMessage{Content: result.Msg}
+			case checkResultWarning, checkResultCritical:
+				suite.Failures++
+				testCase.Failure = &junitMessage{Message: result.Result, Content: result.Msg}
+			default:
+				suite.Errors++
+				testCase.Error = &junitMessage{Message: result.Result, Content: result.Msg}
+			}
+			suite.TestCases = append(suite.TestCases, testCase)
+		}
+
+		suite.Tests = len(suite.TestCases)
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// csvExporter writes a row by check result. The unreachable hosts have a row without check
+type csvExporter struct{}
+
+func (csvExporter) Extension() string {
+	return ".csv"
+}
+
+func (csvExporter) Export(w io.Writer, report *ExecutionReport) error {
+	writer := csv.NewWriter(w)
+	executionID := report.ExecutionID.String()
+
+	rows := [][]string{{"execution_id", "cluster_id", "host_id", "reachable", "check_id", "result", "msg"}}
+	for _, host := range report.Hosts {
+		reachable := strconv.FormatBool(host.Reachable)
+		if !host.Reachable {
+			rows = append(rows, []string{executionID, report.ClusterID, host.HostID, reachable, "", "", host.Msg})
+		}
+		for _, result := range host.Results {
+			rows = append(rows, []string{
+				executionID, report.ClusterID, host.HostID, reachable, result.CheckID, result.Result, result.Msg})
+		}
+	}
+
+	return writer.WriteAll(rows)
+}
@@ -0,0 +1,80 @@
+package runner
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func exportTestReport() *ExecutionReport {
+	return &ExecutionReport{
+		ExecutionID: uuid.MustParse("6ace2d44-1ff9-4e6a-b8bd-5ebe3ee1d15e"),
+		ClusterID:   "cluster1",
+		Status:      ExecutionCompleted,
+		FinishedAt:  time.Date(2022, 5, 10, 10, 0, 0, 0, time.UTC),
+		Hosts: []*HostResults{
+			&HostResults{
+				HostID:    "host1",
+				Reachable: true,
+				Results: []*CheckResult{
+					&CheckResult{CheckID: "156F64", Result: "passing"},
+					&CheckResult{CheckID: "53D035", Result: "critical", Msg: "expected 30000"},
+					&CheckResult{CheckID: "A1244C", Result: "skipped"},
+				},
+			},
+			&HostResults{HostID: "host2", Reachable: false, Msg: "Failed to connect", Results: []*CheckResult{}},
+		},
+	}
+}
+
+func TestNewResultsExporter(t *testing.T) {
+	for _, format := range []string{"json", "junit", "csv"} {
+		_, err := NewResultsExporter(format)
+		assert.NoError(t, err)
+	}
+
+	_, err := NewResultsExporter("html")
+	assert.EqualError(t, err, "results format html is not supported")
+}
+
+func TestJUnitExporter(t *testing.T) {
+	var output bytes.Buffer
+	err := junitExporter{}.Export(&output, exportTestReport())
+	assert.NoError(t, err)
+
+	expectedOutput := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="trento-6ace2d44-1ff9-4e6a-b8bd-5ebe3ee1d15e" tests="4" failures="1" errors="1">
+  <testsuite name="host1" tests="3" failures="1" errors="0" skipped="1" timestamp="2022-05-10T10:00:00">
+    <testcase classname="host1" name="156F64"></testcase>
+    <testcase classname="host1" name="53D035">
+      <failure message="critical">expected 30000</failure>
+    </testcase>
+    <testcase classname="host1" name="A1244C">
+      <skipped></skipped>
+    </testcase>
+  </testsuite>
+  <testsuite name="host2" tests="1" failures="0" errors="1" skipped="0" timestamp="2022-05-10T10:00:00">
+    <testcase classname="host2" name="reachable">
+      <error message="unreachable">Failed to connect</error>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+	assert.Equal(t, expectedOutput, output.String())
+}
+
+func TestCSVExporter(t *testing.T) {
+	var output bytes.Buffer
+	err := csvExporter{}.Export(&output, exportTestReport())
+	assert.NoError(t, err)
+
+	expectedOutput := "execution_id,cluster_id,host_id,reachable,check_id,result,msg\n" +
+		"6ace2d44-1ff9-4e6a-b8bd-5ebe3ee1d15e,cluster1,host1,true,156F64,passing,\n" +
+		"6ace2d44-1ff9-4e6a-b8bd-5ebe3ee1d15e,cluster1,host1,true,53D035,critical,expected 30000\n" +
+		"6ace2d44-1ff9-4e6a-b8bd-5ebe3ee1d15e,cluster1,host1,true,A1244C,skipped,\n" +
+		"6ace2d44-1ff9-4e6a-b8bd-5ebe3ee1d15e,cluster1,host2,false,,,Failed to connect\n"
+	assert.Equal(t, expectedOutput, output.String())
+}
@@ -0,0 +1,99 @@
+package runner
+
+// SeverityMapper maps the raw checks results to the severity reported to the server. The failing checks
+// get the severity of the catalog, unless the runner configuration overrides it, and the warnings are
+// escalated to critical if the check fails in as many hosts of the cluster as its threshold
+type SeverityMapper struct {
+	severities       map[string]string
+	thresholds       map[string]int
+	defaultThreshold int
+}
+
+// NewSeverityMapper returns the mapper with the catalog rules and the configured overrides
+func NewSeverityMapper(config *Config, catalog *Catalog) *SeverityMapper {
+	m := &SeverityMapper{
+		severities:       make(map[string]string),
+		thresholds:       make(map[string]int),
+		defaultThreshold: config.CriticalThreshold,
+	}
+
+	if catalog != nil {
+		for _, check := range *catalog {
+			if check.Severity != "" {
+				m.severities[check.ID] = check.Severity
+			}
+			if check.CriticalThreshold > 0 {
+				m.thresholds[check.ID] = check.CriticalThreshold
+			}
+		}
+	}
+
+	for checkID, severity := range config.CheckSeverities {
+		m.severities[checkID] = severity
+	}
+	for checkID, threshold := range config.CriticalThresholds {
+		m.thresholds[checkID] = threshold
+	}
+
+	return m
+}
+
+// IsValidSeverity tells if the severity can be used for the failing checks
+func IsValidSeverity(severity string) bool {
+	return severity == checkResultWarning || severity == checkResultCritical
+}
+
+// Map returns a copy of the results with the mapped severities. The passing and skipped results are kept
+func (m *SeverityMapper) Map(results *ExecutionResults) *ExecutionResults {
+	mapped := &ExecutionResults{ClusterID: results.ClusterID, Hosts: make([]*HostResults, 0, len(results.Hosts))}
+	failingHosts := make(map[string]int)
+
+	for _, host := range results.Hosts {
+		mappedHost := &HostResults{
+			HostID:    host.HostID,
+			Reachable: host.Reachable,
+			Msg:       host.Msg,
+			Results:   make([]*CheckResult, 0, len(host.Results)),
+		}
+		for _, result := range host.Results {
+			mappedResult := *result
+			if result.Result != checkResultPassing && result.Result != checkResultSkipped {
+				mappedResult.Result = m.severity(result)
+				failingHosts[result.CheckID]++
+			}
+			mappedHost.Results = append(mappedHost.Results, &mappedResult)
+		}
+		mapped.Hosts = append(mapped.Hosts, mappedHost)
+	}
+
+	for _, host := range mapped.Hosts {
+		for _, result := range host.Results {
+			threshold := m.threshold(result.CheckID)
+			if result.Result == checkResultWarning && threshold > 0 && failingHosts[result.CheckID] >= threshold {
+				result.Result = checkResultCritical
+			}
+		}
+	}
+
+	return mapped
+}
+
+// severity returns the severity of a failing check. The results without a known severity are critical
+func (m *SeverityMapper) severity(result *CheckResult) string {
+	if severity, ok := m.severities[result.CheckID]; ok {
+		return severity
+	}
+	if IsValidSeverity(result.Result) {
+		return result.Result
+	}
+
+	return checkResultCritical
+}
+
+func (m *SeverityMapper) threshold(checkID string) int {
+	if threshold, ok := m.thresholds[checkID]; ok {
+		return threshold
+	}
+
+	return m.defaultThreshold
+}
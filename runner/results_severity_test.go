@@ -0,0 +1,85 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func severityResults(results map[string][]*CheckResult) *ExecutionResults {
+	executionResults := &ExecutionResults{ClusterID: "cluster1", Hosts: []*HostResults{}}
+	for _, hostID := range []string{"host1", "host2", "host3"} {
+		if hostResults, ok := results[hostID]; ok {
+			executionResults.Hosts = append(
+				executionResults.Hosts, &HostResults{HostID: hostID, Reachable: true, Results: hostResults})
+		}
+	}
+
+	return executionResults
+}
+
+func TestSeverityMapper(t *testing.T) {
+	catalog := &Catalog{
+		&CatalogCheck{ID: "A", Severity: "warning"},
+		&CatalogCheck{ID: "B", Severity: "warning"},
+		&CatalogCheck{ID: "C", Severity: "critical"},
+	}
+	config := &Config{CheckSeverities: map[string]string{"B": "critical"}}
+
+	results := severityResults(map[string][]*CheckResult{
+		"host1": {
+			&CheckResult{CheckID: "A", Result: "critical", Msg: "failed"},
+			&CheckResult{CheckID: "B", Result: "warning"},
+			&CheckResult{CheckID: "C", Result: "passing"},
+			&CheckResult{CheckID: "D", Result: "skipped"},
+			&CheckResult{CheckID: "E", Result: "unknown"},
+		},
+	})
+
+	mapped := NewSeverityMapper(config, catalog).Map(results)
+
+	assert.Equal(t, []*CheckResult{
+		&CheckResult{CheckID: "A", Result: "warning", Msg: "failed"},
+		&CheckResult{CheckID: "B", Result: "critical"},
+		&CheckResult{CheckID: "C", Result: "passing"},
+		&CheckResult{CheckID: "D", Result: "skipped"},
+		&CheckResult{CheckID: "E", Result: "critical"},
+	}, mapped.Hosts[0].Results)
+	// The given results are not changed
+	assert.Equal(t, "critical", results.Hosts[0].Results[0].Result)
+}
+
+func TestSeverityMapper_Thresholds(t *testing.T) {
+	catalog := &Catalog{
+		&CatalogCheck{ID: "A", Severity: "warning", CriticalThreshold: 3},
+		&CatalogCheck{ID: "B", Severity: "warning", CriticalThreshold: 3},
+		&CatalogCheck{ID: "C", Severity: "warning"},
+	}
+	config := &Config{CriticalThreshold: 2, CriticalThresholds: map[string]int{"B": 2}}
+
+	results := severityResults(map[string][]*CheckResult{
+		"host1": {
+			&CheckResult{CheckID: "A", Result: "warning"},
+			&CheckResult{CheckID: "B", Result: "warning"},
+			&CheckResult{CheckID: "C", Result: "warning"},
+		},
+		"host2": {
+			&CheckResult{CheckID: "A", Result: "warning"},
+			&CheckResult{CheckID: "B", Result: "warning"},
+			&CheckResult{CheckID: "C", Result: "passing"},
+		},
+	})
+
+	mapped := NewSeverityMapper(config, catalog).Map(results)
+
+	assert.Equal(t, []*CheckResult{
+		&CheckResult{CheckID: "A", Result: "warning"},
+		&CheckResult{CheckID: "B", Result: "critical"},
+		&CheckResult{CheckID: "C", Result: "warning"},
+	}, mapped.Hosts[0].Results)
+	assert.Equal(t, []*CheckResult{
+		&CheckResult{CheckID: "A", Result: "warning"},
+		&CheckResult{CheckID: "B", Result: "critical"},
+		&CheckResult{CheckID: "C", Result: "passing"},
+	}, mapped.Hosts[1].Results)
+}
@@ -0,0 +1,168 @@
+package runner
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+const (
+	// resultsSignatureHeader has the detached JWS of the callbacks request body
+	resultsSignatureHeader = "X-Trento-Results-Signature"
+	// The detached JWS of each results file is written next to it, with this extension
+	resultsSignatureExtension = ".jws"
+)
+
+var ErrResultsSigningKey = errors.New("The results signing key is not valid")
+
+// ResultsSigner signs the results sent to the Trento server and written in the results folder with a detached JWS
+// (RFC 7515, appendix F), so the auditors can verify that they were produced by a trusted runner and not altered later.
+// The payload is not in the JWS, it is the request body or the file content as they are
+type ResultsSigner struct {
+	key       crypto.Signer
+	algorithm string
+	keyID     string
+}
+
+// NewResultsSigner reads the PEM private key of the results signing key file, or returns nil if it is not set.
+// The RSA keys sign with RS256, the P-256 and P-384 ECDSA keys with ES256 and ES384, and the Ed25519 keys with EdDSA
+func NewResultsSigner(config *Config) (*ResultsSigner, error) {
+	if config.ResultsSigningKey == "" {
+		return nil, nil
+	}
+
+	content, err := ioutil.ReadFile(config.ResultsSigningKey)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := parseSigningKey(content)
+	if err != nil {
+		return nil, err
+	}
+
+	signer := &ResultsSigner{key: key, keyID: config.ResultsSigningKeyID}
+	switch publicKey := key.Public().(type) {
+	case *rsa.PublicKey:
+		signer.algorithm = "RS256"
+	case *ecdsa.PublicKey:
+		switch publicKey.Curve {
+		case elliptic.P256():
+			signer.algorithm = "ES256"
+		case elliptic.P384():
+			signer.algorithm = "ES384"
+		default:
+			return nil, fmt.Errorf("%w: the %s curve is not supported", ErrResultsSigningKey, publicKey.Curve.Params().Name)
+		}
+	case ed25519.PublicKey:
+		signer.algorithm = "EdDSA"
+	default:
+		return nil, fmt.Errorf("%w: only RSA, ECDSA and Ed25519 private keys are supported", ErrResultsSigningKey)
+	}
+
+	if signer.keyID == "" {
+		if signer.keyID, err = keyThumbprint(key.Public()); err != nil {
+			return nil, err
+		}
+	}
+
+	return signer, nil
+}
+
+// parseSigningKey reads a PKCS #8, PKCS #1 or SEC 1 private key
+func parseSigningKey(content []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("%w: it is not a PEM file", ErrResultsSigningKey)
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		if signer, ok := key.(crypto.Signer); ok {
+			return signer, nil
+		}
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("%w: only RSA, ECDSA and Ed25519 private keys are supported", ErrResultsSigningKey)
+}
+
+// keyThumbprint identifies the public key, with the sha256 of its DER encoding
+func keyThumbprint(publicKey crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// KeyID returns the kid of the JWS header, which identifies the key that verifies the signatures
+func (s *ResultsSigner) KeyID() string {
+	return s.keyID
+}
+
+// Sign returns the compact detached JWS of the payload, with an empty payload part
+func (s *ResultsSigner) Sign(payload []byte) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": s.algorithm, "kid": s.keyID})
+	if err != nil {
+		return "", err
+	}
+
+	protected := base64.RawURLEncoding.EncodeToString(header)
+	signingInput := protected + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	signature, err := s.sign([]byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+
+	return protected + ".." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func (s *ResultsSigner) sign(input []byte) ([]byte, error) {
+	switch s.algorithm {
+	case "RS256":
+		digest := sha256.Sum256(input)
+		return s.key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	case "ES256":
+		digest := sha256.Sum256(input)
+		return s.signECDSA(digest[:], 32)
+	case "ES384":
+		digest := sha512.Sum384(input)
+		return s.signECDSA(digest[:], 48)
+	default:
+		return s.key.Sign(rand.Reader, input, crypto.Hash(0))
+	}
+}
+
+// signECDSA returns the JWS ECDSA signature, the r and s values with the curve size, instead of the ASN.1 one
+func (s *ResultsSigner) signECDSA(digest []byte, size int) ([]byte, error) {
+	r, sValue, err := ecdsa.Sign(rand.Reader, s.key.(*ecdsa.PrivateKey), digest)
+	if err != nil {
+		return nil, err
+	}
+
+	signature := make([]byte, 2*size)
+	r.FillBytes(signature[:size])
+	sValue.FillBytes(signature[size:])
+
+	return signature, nil
+}
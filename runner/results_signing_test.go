@@ -0,0 +1,157 @@
+package runner
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeSigningKey writes the private key in a PEM file of the folder, returning its path
+func writeSigningKey(t *testing.T, folder string, key crypto.Signer) string {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+
+	keyFile := path.Join(folder, "results.key")
+	assert.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+
+	return keyFile
+}
+
+// verifyDetachedJWS checks the detached JWS of the payload with the public key, as an auditor would,
+// returning its protected header
+func verifyDetachedJWS(jws string, payload []byte, publicKey crypto.PublicKey) (map[string]string, error) {
+	parts := strings.Split(jws, ".")
+	if len(parts) != 3 || parts[1] != "" {
+		return nil, errors.New("not a detached JWS")
+	}
+
+	content, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	var header map[string]string
+	if err := json.Unmarshal(content, &header); err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, err
+	}
+
+	input := []byte(parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload))
+	valid := false
+	switch header["alg"] {
+	case "RS256":
+		digest := sha256.Sum256(input)
+		valid = rsa.VerifyPKCS1v15(publicKey.(*rsa.PublicKey), crypto.SHA256, digest[:], signature) == nil
+	case "ES256":
+		digest := sha256.Sum256(input)
+		valid = len(signature) == 64 && ecdsa.Verify(publicKey.(*ecdsa.PublicKey), digest[:],
+			new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:]))
+	case "ES384":
+		digest := sha512.Sum384(input)
+		valid = len(signature) == 96 && ecdsa.Verify(publicKey.(*ecdsa.PublicKey), digest[:],
+			new(big.Int).SetBytes(signature[:48]), new(big.Int).SetBytes(signature[48:]))
+	case "EdDSA":
+		valid = ed25519.Verify(publicKey.(ed25519.PublicKey), input, signature)
+	default:
+		return nil, fmt.Errorf("unknown algorithm %s", header["alg"])
+	}
+	if !valid {
+		return nil, errors.New("invalid signature")
+	}
+
+	return header, nil
+}
+
+func TestResultsSigner(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	p256Key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p384Key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	_, ed25519Key, _ := ed25519.GenerateKey(rand.Reader)
+
+	payload := []byte(`{"execution_id":"e1","event":"execution_finished"}`)
+	for algorithm, key := range map[string]crypto.Signer{
+		"RS256": rsaKey,
+		"ES256": p256Key,
+		"ES384": p384Key,
+		"EdDSA": ed25519Key,
+	} {
+		signer, err := NewResultsSigner(&Config{ResultsSigningKey: writeSigningKey(t, tmpDir, key)})
+		assert.NoError(t, err, algorithm)
+
+		jws, err := signer.Sign(payload)
+		assert.NoError(t, err, algorithm)
+
+		header, err := verifyDetachedJWS(jws, payload, key.Public())
+		assert.NoError(t, err, algorithm)
+		assert.Equal(t, algorithm, header["alg"])
+		thumbprint, _ := keyThumbprint(key.Public())
+		assert.Equal(t, thumbprint, header["kid"])
+
+		_, err = verifyDetachedJWS(jws, []byte(`{"execution_id":"e1","event":"execution_failed"}`), key.Public())
+		assert.EqualError(t, err, "invalid signature", algorithm)
+	}
+}
+
+func TestResultsSigner_KeyID(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	signer, err := NewResultsSigner(&Config{
+		ResultsSigningKey:   writeSigningKey(t, tmpDir, key),
+		ResultsSigningKeyID: "trento-runner-1",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "trento-runner-1", signer.KeyID())
+
+	jws, _ := signer.Sign([]byte("results"))
+	header, err := verifyDetachedJWS(jws, []byte("results"), key.Public())
+	assert.NoError(t, err)
+	assert.Equal(t, "trento-runner-1", header["kid"])
+}
+
+func TestNewResultsSigner_Errors(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	signer, err := NewResultsSigner(&Config{})
+	assert.NoError(t, err)
+	assert.Nil(t, signer)
+
+	_, err = NewResultsSigner(&Config{ResultsSigningKey: path.Join(tmpDir, "missing.key")})
+	assert.Error(t, err)
+
+	keyFile := path.Join(tmpDir, "invalid.key")
+	ioutil.WriteFile(keyFile, []byte("not a key"), 0600)
+	_, err = NewResultsSigner(&Config{ResultsSigningKey: keyFile})
+	assert.True(t, errors.Is(err, ErrResultsSigningKey))
+
+	p224Key, _ := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	_, err = NewResultsSigner(&Config{ResultsSigningKey: writeSigningKey(t, tmpDir, p224Key)})
+	assert.EqualError(t, err, "The results signing key is not valid: the P-224 curve is not supported")
+}
@@ -0,0 +1,181 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrCircuitOpen = errors.New("The Trento server circuit breaker is open, the server is not available")
+
+// The circuit breaker opens after these consecutive failures, and lets a new request through after the cooldown
+var circuitBreakerThreshold = 5
+var circuitBreakerCooldown = time.Second * 30
+
+var maxRetryInterval = time.Minute
+
+// RetryPolicy retries a call with an exponential backoff, doubling the interval after each attempt
+type RetryPolicy struct {
+	Attempts int
+	Interval time.Duration
+}
+
+// Do runs the call until it succeeds, the attempts are exhausted or the context is done,
+// returning the last error. The onRetry function is called with the error before each retry
+func (p RetryPolicy) Do(ctx context.Context, call func() error, onRetry func(attempt int, wait time.Duration, err error)) error {
+	interval := p.Interval
+
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil || attempt >= p.Attempts {
+			return err
+		}
+
+		if onRetry != nil {
+			onRetry(attempt, interval, err)
+		}
+
+		select {
+		case <-time.After(interval):
+			interval *= 2
+			if interval > maxRetryInterval {
+				interval = maxRetryInterval
+			}
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// CircuitBreakerState is the state of a circuit breaker, exported in the metrics
+type CircuitBreakerState int
+
+const (
+	CircuitBreakerClosed CircuitBreakerState = iota
+	CircuitBreakerOpen
+	CircuitBreakerHalfOpen
+)
+
+// CircuitBreaker stops the requests to a server after repeated failures, so the callers fail fast
+// while the server is down instead of waiting for the timeouts
+type CircuitBreaker struct {
+	name     string
+	mu       sync.Mutex
+	failures int
+	open     bool
+	openedAt time.Time
+	trips    int
+	// A trial request is in flight in the half open state
+	trial bool
+}
+
+func NewCircuitBreaker(name string) *CircuitBreaker {
+	return &CircuitBreaker{name: name}
+}
+
+// Allow tells if a request can be sent. Once the cooldown is elapsed, the breaker is half open and a single
+// trial request is sent to check if the server is back. The other requests fail fast until its result is recorded
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.open {
+		return true
+	}
+	if b.trial || time.Since(b.openedAt) < circuitBreakerCooldown {
+		return false
+	}
+	b.trial = true
+
+	return true
+}
+
+// Record updates the breaker state with the result of a request
+func (b *CircuitBreaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+	if err == nil {
+		if b.open {
+			log.Infof("The %s circuit breaker is closed, the server is available again", b.name)
+		}
+		b.failures = 0
+		b.open = false
+		return
+	}
+
+	b.failures++
+	switch {
+	case b.open:
+		// The server is still down after the cooldown
+		b.openedAt = time.Now()
+	case b.failures >= circuitBreakerThreshold:
+		b.open = true
+		b.openedAt = time.Now()
+		b.trips++
+		log.Errorf("The %s circuit breaker is open after %d consecutive failures, last error: %s", b.name, b.failures, err)
+	}
+}
+
+// Trips returns the number of times the breaker has been opened
+func (b *CircuitBreaker) Trips() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.trips
+}
+
+// State returns whether the breaker is closed, open, or half open once the cooldown is elapsed
+func (b *CircuitBreaker) State() CircuitBreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case !b.open:
+		return CircuitBreakerClosed
+	case time.Since(b.openedAt) < circuitBreakerCooldown:
+		return CircuitBreakerOpen
+	default:
+		return CircuitBreakerHalfOpen
+	}
+}
+
+// breakerTransport records the result of the requests in the circuit breaker.
+// The server errors count as failures, as the server is not able to process the requests
+type breakerTransport struct {
+	base    http.RoundTripper
+	breaker *CircuitBreaker
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.breaker.Allow() {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, ErrCircuitOpen
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	switch {
+	case err != nil:
+		t.breaker.Record(err)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		t.breaker.Record(fmt.Errorf("status code %d", resp.StatusCode))
+	default:
+		t.breaker.Record(nil)
+	}
+
+	return resp, err
+}
+
+func (t *breakerTransport) CloseIdleConnections() {
+	if closer, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
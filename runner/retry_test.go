@@ -0,0 +1,128 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	policy := RetryPolicy{Attempts: 3, Interval: time.Millisecond}
+
+	calls := 0
+	retries := []int{}
+	err := policy.Do(context.Background(), func() error {
+		calls++
+		if calls < 3 {
+			return fmt.Errorf("server unavailable")
+		}
+		return nil
+	}, func(attempt int, wait time.Duration, err error) {
+		retries = append(retries, attempt)
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, []int{1, 2}, retries)
+
+	calls = 0
+	err = policy.Do(context.Background(), func() error {
+		calls++
+		return fmt.Errorf("server unavailable")
+	}, nil)
+
+	assert.EqualError(t, err, "server unavailable")
+	assert.Equal(t, 3, calls)
+}
+
+func TestRetryPolicy_Cancelled(t *testing.T) {
+	policy := RetryPolicy{Attempts: 3, Interval: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+	err := policy.Do(ctx, func() error {
+		calls++
+		return fmt.Errorf("server unavailable")
+	}, nil)
+
+	assert.EqualError(t, err, "server unavailable")
+	assert.Equal(t, 1, calls)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	defer func(cooldown time.Duration) { circuitBreakerCooldown = cooldown }(circuitBreakerCooldown)
+	circuitBreakerCooldown = time.Hour
+
+	breaker := NewCircuitBreaker("test")
+	for i := 0; i < circuitBreakerThreshold-1; i++ {
+		breaker.Record(fmt.Errorf("server unavailable"))
+	}
+	assert.True(t, breaker.Allow())
+
+	assert.Equal(t, CircuitBreakerClosed, breaker.State())
+
+	breaker.Record(fmt.Errorf("server unavailable"))
+	assert.False(t, breaker.Allow())
+	assert.Equal(t, 1, breaker.Trips())
+	assert.Equal(t, CircuitBreakerOpen, breaker.State())
+
+	// A single trial request checks the server again after the cooldown
+	circuitBreakerCooldown = 0
+	assert.Equal(t, CircuitBreakerHalfOpen, breaker.State())
+	assert.True(t, breaker.Allow())
+	assert.False(t, breaker.Allow())
+	breaker.Record(fmt.Errorf("server unavailable"))
+	assert.Equal(t, 1, breaker.Trips())
+
+	assert.True(t, breaker.Allow())
+	assert.False(t, breaker.Allow())
+	breaker.Record(nil)
+	assert.Equal(t, CircuitBreakerClosed, breaker.State())
+	circuitBreakerCooldown = time.Hour
+	assert.True(t, breaker.Allow())
+	assert.True(t, breaker.Allow())
+	breaker.Record(fmt.Errorf("server unavailable"))
+	assert.True(t, breaker.Allow())
+}
+
+func TestBreakerTransport(t *testing.T) {
+	defer func(cooldown time.Duration) { circuitBreakerCooldown = cooldown }(circuitBreakerCooldown)
+	circuitBreakerCooldown = time.Hour
+
+	statusCode := http.StatusServiceUnavailable
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(statusCode)
+	}))
+	defer server.Close()
+
+	breaker := NewCircuitBreaker("test")
+	client := &http.Client{Transport: &breakerTransport{base: http.DefaultTransport, breaker: breaker}}
+
+	for i := 0; i < circuitBreakerThreshold; i++ {
+		resp, err := client.Get(server.URL)
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	_, err := client.Get(server.URL)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, circuitBreakerThreshold, requests)
+
+	// The client errors are not server failures
+	breaker.Record(nil)
+	statusCode = http.StatusBadRequest
+	for i := 0; i < circuitBreakerThreshold; i++ {
+		resp, err := client.Get(server.URL)
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+	assert.True(t, breaker.Allow())
+}
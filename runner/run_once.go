@@ -0,0 +1,163 @@
+package runner
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// ServeMode runs the executions requested through the APIs and the scheduled ones until the runner is stopped
+	ServeMode = "serve"
+	// OneshotMode runs an execution of every scheduled cluster and exits, e.g. in a CI pipeline
+	OneshotMode = "oneshot"
+	// CronMode runs the executions of the scheduled clusters due in the current minute and exits,
+	// for the external schedulers running the runner every minute, as a systemd timer or a kubernetes cron job
+	CronMode = "cron"
+
+	// The exit codes of the oneshot and cron modes, besides the 1 of the runner errors
+	RunOnceFailedExecutionExitCode = 2
+	RunOnceFailedChecksExitCode    = 3
+)
+
+// RunOnceReport is the outcome of the executions run by the oneshot and cron modes
+type RunOnceReport struct {
+	Mode       string             `json:"mode"`
+	Executions []*ExecutionReport `json:"executions"`
+}
+
+// ExitCode returns the exit code of the runner for the executions: 0 if all of them passed, 2 if any of them failed,
+// and 3 if any check is critical or any host is unreachable
+func (r *RunOnceReport) ExitCode() int {
+	exitCode := 0
+	for _, report := range r.Executions {
+		if report.Status == ExecutionFailed {
+			return RunOnceFailedExecutionExitCode
+		}
+
+		for _, host := range report.Hosts {
+			if !host.Reachable {
+				exitCode = RunOnceFailedChecksExitCode
+			}
+			for _, result := range host.Results {
+				if result.Result == checkResultCritical {
+					exitCode = RunOnceFailedChecksExitCode
+				}
+			}
+		}
+	}
+
+	return exitCode
+}
+
+// RunOnce runs the scheduled executions once, as the oneshot and cron modes do, instead of serving the APIs.
+// Only the services running and reporting the executions are started, and they are stopped once the executions are
+// finished, so their results are sent to the Trento server, the results folder and the sinks before returning
+func (a *App) RunOnce(ctx context.Context) (*RunOnceReport, error) {
+	if a.scheduler == nil {
+		return nil, ErrSchedulerDisabled
+	}
+	defer a.shutdown()
+
+	log.Infof("Building catalog....")
+	if err := a.runnerService.BuildCatalog(); err != nil {
+		return nil, err
+	}
+
+	servicesCtx, stopServices := context.WithCancel(ctx)
+	g, servicesCtx := errgroup.WithContext(servicesCtx)
+	a.runExecutionServices(servicesCtx, g)
+
+	reports, err := a.scheduler.RunOnce(ctx, time.Now(), a.config.Mode == CronMode)
+	stopServices()
+	if waitErr := g.Wait(); err == nil {
+		err = waitErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &RunOnceReport{Mode: a.config.Mode, Executions: reports}, nil
+}
+
+// RunOnce starts an execution of each scheduled cluster, or only of the ones due in the minute of the given time,
+// waits for them to finish, and returns their reports sorted by cluster. The schedules are read from the source,
+// without the paused state nor the updates of the running scheduler
+func (s *Scheduler) RunOnce(ctx context.Context, now time.Time, dueOnly bool) ([]*ExecutionReport, error) {
+	schedules, err := LoadSchedules(s.source, s.httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	// The events are received from now on, so the executions finished right away are not missed
+	events, unsubscribe := s.events.Subscribe()
+	defer unsubscribe()
+
+	reports := []*ExecutionReport{}
+	pending := make(map[uuid.UUID]bool)
+	for _, schedule := range schedules {
+		if dueOnly && !schedule.dueAt(now) {
+			continue
+		}
+
+		executionID := uuid.New()
+		log.Infof("Starting the execution %s of cluster %s", executionID.String(), schedule.ClusterID.String())
+		if err := s.runnerService.ScheduleExecution(schedule.newExecution(executionID)); err != nil {
+			log.Errorf("Error starting the execution of cluster %s: %s", schedule.ClusterID.String(), err)
+			reports = append(reports, &ExecutionReport{
+				ExecutionID: executionID,
+				ClusterID:   schedule.ClusterID.String(),
+				Status:      ExecutionFailed,
+				Reason:      err.Error(),
+				FinishedAt:  time.Now().UTC(),
+				Hosts:       []*HostResults{},
+			})
+			continue
+		}
+		pending[executionID] = true
+	}
+
+	collector := newReportCollector()
+	for len(pending) > 0 {
+		select {
+		case event := <-events:
+			if !pending[event.ExecutionID] {
+				continue
+			}
+			report, err := collector.collect(event.ExecutionID, event.Event, event.Payload)
+			if err != nil {
+				log.Warnf("Error collecting the %s event of execution %s: %s", event.Event, event.ExecutionID.String(), err)
+				continue
+			}
+			if report != nil {
+				delete(pending, event.ExecutionID)
+				reports = append(reports, report)
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].ClusterID < reports[j].ClusterID
+	})
+
+	return reports, nil
+}
+
+// dueAt tells if the cron expression of the schedule is due in the minute of the given time.
+// The @every expressions are never due, as they are relative to the start of the scheduler
+func (s *Schedule) dueAt(t time.Time) bool {
+	schedule, err := cron.ParseStandard(s.Cron)
+	if err != nil {
+		return false
+	}
+
+	minute := t.Truncate(time.Minute)
+	return schedule.Next(minute.Add(-time.Second)).Equal(minute)
+}
@@ -0,0 +1,95 @@
+package runner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSchedulerRunOnce(t *testing.T) {
+	runnerService := new(MockRunnerService)
+	events := NewEventsBroadcaster()
+	scheduler := NewScheduler(&Config{Schedules: TestSchedulesFile}, runnerService, events, nil)
+
+	runnerService.On("ScheduleExecution", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		e := args.Get(0).(*ExecutionEvent)
+		clusterID := e.ClusterID.String()
+		if e.ClusterID == dailyClusterID {
+			events.Publish(e.ExecutionID, executionFailedEvent, map[string]string{"cluster_id": clusterID, "reason": "exit status 4"})
+			return
+		}
+		events.Publish(e.ExecutionID, hostCompletedEvent, map[string]interface{}{
+			"cluster_id": clusterID, "host_id": "host1", "reachable": true, "msg": ""})
+		events.Publish(e.ExecutionID, checkResultEvent, map[string]interface{}{
+			"cluster_id": clusterID, "host_id": "host1", "check_id": "156F64", "result": "critical", "msg": ""})
+		events.Publish(e.ExecutionID, executionFinishedEvent, map[string]string{"cluster_id": clusterID})
+	})
+
+	// Every cluster runs once in the oneshot mode
+	reports, err := scheduler.RunOnce(context.Background(), time.Now(), false)
+	assert.NoError(t, err)
+	assert.Len(t, reports, 2)
+	assert.Equal(t, dailyClusterID.String(), reports[0].ClusterID)
+	assert.Equal(t, ExecutionFailed, reports[0].Status)
+	assert.Equal(t, "exit status 4", reports[0].Reason)
+	assert.Equal(t, scheduledClusterID.String(), reports[1].ClusterID)
+	assert.Equal(t, ExecutionCompleted, reports[1].Status)
+	assert.Equal(t, "critical", reports[1].Hosts[0].Results[0].Result)
+	runnerService.AssertNumberOfCalls(t, "ScheduleExecution", 2)
+
+	// Only the clusters due in the current minute run in the cron mode
+	reports, err = scheduler.RunOnce(context.Background(), time.Date(2022, 6, 1, 6, 0, 30, 0, time.Local), true)
+	assert.NoError(t, err)
+	assert.Len(t, reports, 1)
+	assert.Equal(t, scheduledClusterID.String(), reports[0].ClusterID)
+
+	reports, err = scheduler.RunOnce(context.Background(), time.Date(2022, 6, 1, 7, 0, 0, 0, time.Local), true)
+	assert.NoError(t, err)
+	assert.Empty(t, reports)
+}
+
+func TestSchedulerRunOnce_Rejected(t *testing.T) {
+	runnerService := new(MockRunnerService)
+	scheduler := NewScheduler(&Config{Schedules: TestSchedulesFile}, runnerService, NewEventsBroadcaster(), nil)
+	runnerService.On("ScheduleExecution", mock.Anything).Return(ErrExecutionQueueFull)
+
+	reports, err := scheduler.RunOnce(context.Background(), time.Now(), false)
+	assert.NoError(t, err)
+	assert.Len(t, reports, 2)
+	assert.Equal(t, ExecutionFailed, reports[0].Status)
+	assert.Equal(t, ErrExecutionQueueFull.Error(), reports[0].Reason)
+}
+
+func TestScheduleDueAt(t *testing.T) {
+	schedule := &Schedule{Cron: "30 2 * * 1-5"}
+	assert.True(t, schedule.dueAt(time.Date(2022, 6, 1, 2, 30, 0, 0, time.Local)))
+	assert.True(t, schedule.dueAt(time.Date(2022, 6, 1, 2, 30, 59, 0, time.Local)))
+	assert.False(t, schedule.dueAt(time.Date(2022, 6, 1, 2, 31, 0, 0, time.Local)))
+	// Saturday
+	assert.False(t, schedule.dueAt(time.Date(2022, 6, 4, 2, 30, 0, 0, time.Local)))
+
+	assert.False(t, (&Schedule{Cron: "@every 1m"}).dueAt(time.Now()))
+}
+
+func TestRunOnceReport_ExitCode(t *testing.T) {
+	passing := &ExecutionReport{ExecutionID: uuid.New(), Status: ExecutionCompleted, Hosts: []*HostResults{
+		{HostID: "host1", Reachable: true, Results: []*CheckResult{{CheckID: "156F64", Result: "passing"}, {CheckID: "53D035", Result: "warning"}}},
+	}}
+	critical := &ExecutionReport{ExecutionID: uuid.New(), Status: ExecutionCompleted, Hosts: []*HostResults{
+		{HostID: "host1", Reachable: true, Results: []*CheckResult{{CheckID: "156F64", Result: "critical"}}},
+	}}
+	unreachable := &ExecutionReport{ExecutionID: uuid.New(), Status: ExecutionCompleted, Hosts: []*HostResults{
+		{HostID: "host1", Reachable: false, Results: []*CheckResult{}},
+	}}
+	failed := &ExecutionReport{ExecutionID: uuid.New(), Status: ExecutionFailed, Hosts: []*HostResults{}}
+
+	assert.Equal(t, 0, (&RunOnceReport{Executions: []*ExecutionReport{}}).ExitCode())
+	assert.Equal(t, 0, (&RunOnceReport{Executions: []*ExecutionReport{passing}}).ExitCode())
+	assert.Equal(t, RunOnceFailedChecksExitCode, (&RunOnceReport{Executions: []*ExecutionReport{passing, critical}}).ExitCode())
+	assert.Equal(t, RunOnceFailedChecksExitCode, (&RunOnceReport{Executions: []*ExecutionReport{unreachable}}).ExitCode())
+	assert.Equal(t, RunOnceFailedExecutionExitCode, (&RunOnceReport{Executions: []*ExecutionReport{critical, failed}}).ExitCode())
+}
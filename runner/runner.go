@@ -1,100 +1,574 @@
 package runner
 
 import (
+	"context"
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
 	"io/ioutil"
+	"net/http"
 	"os"
+	"os/exec"
 	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 )
 
 //go:embed ansible
 var ansibleFS embed.FS
 
 const (
-	executionChannelSize = 99
+	DefaultExecutionQueueSize = 99
 
 	AnsibleMain        = "ansible/check.yml"
 	AnsibleMeta        = "ansible/meta.yml"
 	AnsibleConfigFile  = "ansible/ansible.cfg"
-	AnsibleInventories = "ansible/inventories/%s/ansible_hosts"
+	AnsibleInventories = "ansible/inventories"
+	AnsibleInventory   = "ansible_hosts"
+	AnsibleExtraVars   = "extra_vars.json"
+	AnsibleChecks      = "ansible/roles/checks"
+	AnsibleProviders   = "ansible/vars"
+	AnsibleSSHAskPass  = "ansible/ssh_askpass.sh"
 
-	executionStartedEvent = "execution_started"
+	checkTimeoutVariable     = "trento_check_timeout"
+	checkTimeoutsVariable    = "trento_check_timeouts"
+	rollingBatchSizeVariable = "trento_rolling_batch_size"
+	remediationVariable      = "trento_remediation"
+
+	sshAskPassScript = "#!/bin/sh\nprintf '%s\\n' \"$" + TrentoSSHPassphrase + "\"\n"
+
+	executionStartedEvent  = "execution_started"
+	executionFinishedEvent = "execution_finished"
+	executionFailedEvent   = "execution_failed"
+	hostCompletedEvent     = "host_completed"
+	checkResultEvent       = "check_result"
+	// A preempted execution is queued again, it is reported as started again once it runs
+	executionPreemptedEvent = "execution_preempted"
+	// The checks fixed by a remediation execution, before their results are reported
+	checksRemediatedEvent = "checks_remediated"
+	// A pre-execution or post-execution hook failed, apart from the execution failure
+	executionHookFailedEvent = "execution_hook_failed"
 )
 
+var ErrCatalogRebuilding = errors.New("The catalog is already being built")
+var ErrDraining = errors.New("The runner is shutting down, no new executions are accepted")
+var ErrExecutionTimeout = errors.New("The execution timed out")
+var ErrExecutionQueueFull = errors.New("The executions queue is full, cannot process more executions")
+var ErrExecutionPreempted = errors.New("The execution was preempted by a higher priority execution")
+
+// executionTimeoutError keeps the error of the terminated checks, so the return code is still available
+type executionTimeoutError struct {
+	timeout time.Duration
+	err     error
+}
+
+func (e *executionTimeoutError) Error() string {
+	return fmt.Sprintf("%s after %s: %s", ErrExecutionTimeout, e.timeout, e.err)
+}
+
+func (e *executionTimeoutError) Unwrap() error {
+	return e.err
+}
+
+func (e *executionTimeoutError) Is(target error) bool {
+	return target == ErrExecutionTimeout
+}
+
 //go:generate mockery --name=RunnerService --inpackage --filename=runner_mock.go
 
 type RunnerService interface {
 	IsCatalogReady() bool
+	GetCatalogStatus() *CatalogStatus
+	IsServerReachable() bool
+	IsDraining() bool
+	Drain()
 	BuildCatalog() error
+	RebuildCatalog() error
 	GetCatalog() *Catalog
 	GetChannel() chan *ExecutionEvent
 	ScheduleExecution(e *ExecutionEvent) error
-	Execute(e *ExecutionEvent) error
+	Execute(ctx context.Context, e *ExecutionEvent) error
+	Reload(config *Config) error
 }
 
 type runnerService struct {
-	config            *Config
-	workerPoolChannel chan *ExecutionEvent
-	callbacksClient   CallbacksClient
-	catalog           *Catalog
-	ready             bool
+	config              *Config
+	workerPoolChannel   chan *ExecutionEvent
+	callbacksClient     CallbacksClient
+	subscriptionClient  *SubscriptionClient
+	serverTransport     http.RoundTripper
+	upstreams           map[string]*upstreamClient
+	callbacksDispatcher *CallbacksDispatcher
+	executionsStore     ExecutionsStore
+	events              *EventsBroadcaster
+	logs                *ExecutionLogs
+	executions          *ExecutionsTracker
+	resultsCache        *ResultsCache
+	changesFilter       *ChangesFilter
+	checkEngine         CheckEngine
+	catalogMu           sync.RWMutex
+	filesMu             sync.RWMutex
+	rebuilding          int32
+	draining            int32
+	catalog             *Catalog
+	manifest            AnsibleManifest
+	ready               bool
+	catalogStatus       string
+	catalogError        string
+	handshake           *CatalogHandshake
+	lastCatalogBuildAt  time.Time
+	maintenanceWindows  *MaintenanceWindows
+	hostFences          *hostFences
+	hostKeys            *HostKeys
+	inventories         *InventoryAudit
+	checkDurations      *CheckDurationMetrics
+	serverConnectivity  *ServerConnectivity
+	startup             *startup
+	// callbacksUrl is the current Trento server callbacks url, changed when the configuration is reloaded
+	callbacksUrlMu sync.RWMutex
+	callbacksUrl   string
 }
 
 func NewRunnerService(config *Config) (*runnerService, error) {
+	serverTransport, err := NewServerTransport(config)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsSigner, err := NewResultsSigner(config)
+	if err != nil {
+		return nil, err
+	}
+	if resultsSigner != nil {
+		log.Infof("The execution results are signed with the key %s", resultsSigner.KeyID())
+	}
+
+	var callbacksClient CallbacksClient
+	dispatcherClients := []CallbacksClient{}
+	if config.CallbacksUrl != "" {
+		serverCallbacksClient := NewCallbacksClient(config.CallbacksUrl, serverTransport)
+		serverCallbacksClient.SetRateLimit(config.CallbacksRateLimit)
+		serverCallbacksClient.SetSigner(resultsSigner)
+		callbacksClient = serverCallbacksClient
+	}
+
+	upstreams := make(map[string]*upstreamClient)
+	for _, upstream := range config.Upstreams {
+		client, err := newUpstreamClient(config, upstream, resultsSigner)
+		if err != nil {
+			return nil, err
+		}
+		upstreams[upstream.Name] = client
+	}
+
+	// Without a Trento server, in the standalone mode, the results are only written in the results folder
+	if config.ResultsDir != "" {
+		resultsDirClient, err := NewResultsDirClient(config.ResultsDir, config.ResultsFormats)
+		if err != nil {
+			return nil, err
+		}
+		resultsDirClient.SetSigner(resultsSigner)
+		if callbacksClient == nil {
+			callbacksClient = resultsDirClient
+		}
+		dispatcherClients = append(dispatcherClients, resultsDirClient)
+	}
+
+	// The report of each execution is passed to the sinks as well, if there are any
+	if len(config.Sinks) > 0 {
+		sinks := []ExecutionSink{}
+		for _, sink := range config.Sinks {
+			executionSink, err := NewExecutionSink(sink, serverTransport, resultsSigner)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, executionSink)
+		}
+		dispatcherClients = append(dispatcherClients, NewSinksClient(sinks...))
+	}
+
+	// The summary of each execution is posted to the Trento server, if it is configured
+	if config.ServerSummaryUrl != "" {
+		dispatcherClients = append(
+			dispatcherClients, NewSummaryClient(config.ServerSummaryUrl, serverTransport, resultsSigner))
+	}
+
+	// The results are published back in the message queue as well, if it is used
+	if config.AmqpUrl != "" {
+		dispatcherClients = append(
+			dispatcherClients, NewAmqpCallbacksClient(config.AmqpUrl, config.AmqpReplyExchange))
+	}
+
+	queueSize := config.ExecutionQueueSize
+	if queueSize <= 0 {
+		queueSize = DefaultExecutionQueueSize
+	}
+
 	runner := &runnerService{
-		config:            config,
-		workerPoolChannel: make(chan *ExecutionEvent, executionChannelSize),
-		callbacksClient:   NewCallbacksClient(config.CallbacksUrl),
-		ready:             false,
+		config:              config,
+		workerPoolChannel:   make(chan *ExecutionEvent, queueSize),
+		callbacksClient:     callbacksClient,
+		serverTransport:     serverTransport,
+		upstreams:           upstreams,
+		callbacksDispatcher: NewCallbacksDispatcher(dispatcherClients...),
+		events:              NewEventsBroadcaster(),
+		logs:                NewExecutionLogs(),
+		executions:          NewExecutionsTracker(),
+		hostFences:          newHostFences(),
+		inventories:         NewInventoryAudit(),
+		checkDurations:      NewCheckDurationMetrics(),
+		callbacksUrl:        config.CallbacksUrl,
+		startup:             newStartup(),
+		ready:               false,
+		// The catalog is built as soon as the runner starts
+		catalogStatus: CatalogStatusBuilding,
+	}
+
+	if config.ExecutionLogsDir != "" {
+		runner.logs.SetFiles(
+			NewExecutionLogFiles(config.ExecutionLogsDir, config.ExecutionLogsMaxSize, config.ExecutionLogsMaxAge))
+	}
+
+	if config.MaintenanceWindows != "" {
+		if runner.maintenanceWindows, err = NewMaintenanceWindows(config.MaintenanceWindows, serverTransport); err != nil {
+			return nil, err
+		}
+	}
+
+	if runner.hostKeys, err = NewHostKeys(config); err != nil {
+		return nil, err
+	}
+
+	if config.CallbacksUrl != "" {
+		runner.serverConnectivity = NewServerConnectivity(config.CallbacksUrl)
+	}
+
+	if config.ServerSubscriptionUrl != "" {
+		runner.subscriptionClient = NewSubscriptionClient(config.ServerSubscriptionUrl, serverTransport)
+	}
+
+	runner.callbacksDispatcher.SetBatchSize(config.CallbacksBatchSize)
+
+	// The callbacks of the default Trento server executions are not sent to the upstreams, and the other way around
+	if config.CallbacksUrl != "" {
+		runner.callbacksDispatcher.SetUpstreamClient("", callbacksClient)
+	}
+	for name, upstream := range upstreams {
+		runner.callbacksDispatcher.SetUpstreamClient(name, upstream.callbacksClient)
+	}
+
+	checkEngine, err := NewCheckEngine(config)
+	if err != nil {
+		return nil, err
+	}
+	runner.checkEngine = checkEngine
+
+	if config.ResultsCacheTTL > 0 {
+		runner.resultsCache = NewResultsCache(config.ResultsCacheTTL)
+	}
+
+	if config.ReportChangesOnly {
+		runner.changesFilter = NewChangesFilter(config.FullResyncInterval)
+	}
+
+	if config.ExecutionsDatabase != "" {
+		executionsStore, err := NewExecutionsStore(config.ExecutionsDatabase)
+		if err != nil {
+			return nil, err
+		}
+		if interrupted, err := executionsStore.FailInterrupted(); err != nil {
+			log.Errorf("Error marking the interrupted executions as failed: %s", err)
+		} else if interrupted > 0 {
+			log.Warnf("Marked %d executions interrupted by the previous runner stop as failed", interrupted)
+		}
+		runner.executionsStore = executionsStore
 	}
 
 	return runner, nil
 }
 
+// circuitBreakers returns the circuit breakers of the Trento server, with an empty name, and of the upstreams
+func (r *runnerService) circuitBreakers() map[string]*CircuitBreaker {
+	breakers := make(map[string]*CircuitBreaker)
+	if breaker := serverTransportBreaker(r.serverTransport); breaker != nil {
+		breakers[""] = breaker
+	}
+	for name, upstream := range r.upstreams {
+		if breaker := serverTransportBreaker(upstream.transport); breaker != nil {
+			breakers[name] = breaker
+		}
+	}
+
+	return breakers
+}
+
 func (c *runnerService) IsCatalogReady() bool {
+	c.catalogMu.RLock()
+	defer c.catalogMu.RUnlock()
+
 	return c.ready
 }
 
+// IsServerReachable checks if the Trento server where the callbacks are sent accepts connections
+func (c *runnerService) IsServerReachable() bool {
+	// There is no server in the standalone mode
+	if c.serverConnectivity == nil {
+		return true
+	}
+
+	return c.serverConnectivity.Reachable()
+}
+
+// IsDraining tells if the runner is shutting down, finishing the running executions
+func (c *runnerService) IsDraining() bool {
+	return atomic.LoadInt32(&c.draining) == 1
+}
+
+// Drain stops accepting new executions
+func (c *runnerService) Drain() {
+	atomic.StoreInt32(&c.draining, 1)
+}
+
+// BuildCatalog prepares the ansible files and builds the catalog as the runner starts. The executions requested
+// meanwhile wait for the ansible files only, and the Trento server is not needed to build the catalog
 func (c *runnerService) BuildCatalog() error {
-	if err := createAnsibleFiles(c.config.AnsibleFolder); err != nil {
+	ctx, span := tracer.Start(context.Background(), "BuildCatalog")
+
+	c.startup.setPhase(StartupPreparing)
+	checksFolders, err := c.prepareAnsibleFiles(ctx)
+	if err != nil {
+		c.setCatalogStatus(CatalogStatusFailed, err)
+		c.startup.setPhase(StartupFailed)
+		endSpan(span, err)
 		return err
 	}
 
-	metaRunner, err := NewAnsibleMetaRunner(c.config)
+	c.startup.setPhase(StartupBuildingCatalog)
+	err = c.rebuildCatalog(ctx, checksFolders)
 	if err != nil {
+		c.startup.setPhase(StartupFailed)
+	} else {
+		c.startup.setPhase(StartupReady)
+	}
+	endSpan(span, err)
+
+	return err
+}
+
+// RebuildCatalog runs the catalog meta-playbook again with the checks currently on disk.
+// The new catalog replaces the current one only if it is built successfully
+func (c *runnerService) RebuildCatalog() error {
+	return c.rebuildCatalog(context.Background(), nil)
+}
+
+// rebuildCatalog builds the catalog with the checks of the sources, which are fetched and copied again
+// unless the folders of the already copied ones are given
+func (c *runnerService) rebuildCatalog(ctx context.Context, checksFolders []string) (err error) {
+	ctx, span := tracer.Start(ctx, "RebuildCatalog")
+	defer func() { endSpan(span, err) }()
+
+	if !atomic.CompareAndSwapInt32(&c.rebuilding, 0, 1) {
+		return ErrCatalogRebuilding
+	}
+	defer atomic.StoreInt32(&c.rebuilding, 0)
+
+	previousCatalog := c.GetCatalog()
+	c.setCatalog(previousCatalog, false)
+	c.setCatalogStatus(CatalogStatusBuilding, nil)
+
+	var catalog *Catalog
+	copyChecks := checksFolders == nil
+	if copyChecks {
+		checksFolders, err = fetchCatalogSources(ctx, NewCatalogSources(c.config))
+	}
+	if err == nil {
+		err = c.updateAnsibleFiles(checksFolders, copyChecks)
+	}
+	if err == nil {
+		catalog, err = runCatalogPlaybook(ctx, c.config, checksFolders)
+	}
+	if err != nil {
+		// Keep serving the previous catalog, if there was one
+		c.setCatalog(previousCatalog, previousCatalog != nil)
+		c.setCatalogStatus(CatalogStatusFailed, err)
 		return err
 	}
 
+	c.setCatalog(catalog, true)
+	c.setCatalogStatus(CatalogStatusReady, nil)
+
+	if c.config.ServerHandshakeUrl != "" {
+		go c.advertiseCatalog(context.Background(), catalog)
+	}
+
+	return nil
+}
+
+// updateAnsibleFiles copies the checks of the fetched catalog sources, as the user provided checks, on top of
+// the embedded ones, so they are part of the catalog and the executions, and updates the manifest. The new
+// executions wait meanwhile, and the files are not changed until the running ones are finished
+func (c *runnerService) updateAnsibleFiles(checksFolders []string, copyChecks bool) error {
+	c.filesMu.Lock()
+	defer c.filesMu.Unlock()
+
+	if copyChecks {
+		if err := copyCatalogFolders(checksFolders, path.Join(c.config.AnsibleFolder, AnsibleChecks)); err != nil {
+			return err
+		}
+	}
+
+	return c.updateManifest()
+}
+
+// updateManifest computes the manifest of the ansible files, once the checks of every source are copied.
+// The executions verify that the files are not modified after it
+func (c *runnerService) updateManifest() error {
+	manifest, err := NewAnsibleManifest(c.config.AnsibleFolder)
+	if err != nil {
+		return err
+	}
+	manifest.Log()
+
+	c.catalogMu.Lock()
+	defer c.catalogMu.Unlock()
+	c.manifest = manifest
+
+	return nil
+}
+
+// verifyManifest fails if the ansible files were modified since the catalog was built
+func (c *runnerService) verifyManifest() error {
+	c.catalogMu.RLock()
+	manifest := c.manifest
+	c.catalogMu.RUnlock()
+
+	if manifest == nil {
+		return nil
+	}
+
+	return manifest.Verify(c.config.AnsibleFolder)
+}
+
+func runCatalogPlaybook(ctx context.Context, config *Config, checksFolders []string) (*Catalog, error) {
+	metaRunner, err := NewAnsibleMetaRunner(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// The playbook writes a temporary file, so the current catalog file is never left half written
+	destination := path.Join(config.AnsibleFolder, CatalogDestinationFile)
+	temporaryDestination := destination + catalogTemporarySuffix
+	metaRunner.SetCatalogDestination(temporaryDestination)
+
 	// The checks catalog metadata playbook creates the checks catalog in the provider file path
-	if err = metaRunner.RunPlaybook(); err != nil {
+	if err = metaRunner.RunPlaybookContext(ctx); err != nil {
 		log.Errorf("Error running the catalog meta-playbook")
-		return err
+		return nil, err
 	}
 
 	// After the playbook is done, recover back the file content
-	catalogFile, err := ioutil.ReadFile(metaRunner.Envs[CatalogDestination])
+	catalogFile, err := ioutil.ReadFile(temporaryDestination)
 	if err != nil {
-		log.Fatal("Error when opening the catalog file: ", err)
+		log.Errorf("Error when opening the catalog file: %s", err)
+		return nil, err
 	}
 
 	var catalog *Catalog
-	err = json.Unmarshal(catalogFile, &catalog)
+	if err = json.Unmarshal(catalogFile, &catalog); err != nil {
+		log.Errorf("Error during Unmarshal(): %s", err)
+		return nil, err
+	}
+
+	// The translations of the later sources replace the ones of the previous sources
+	for _, checksFolder := range checksFolders {
+		translations, err := LoadCatalogTranslations(checksFolder)
+		if err != nil {
+			return nil, err
+		}
+		catalog.MergeTranslations(translations)
+	}
+
+	// The catalog file has the schema and content versions, so the stale ones can be found
+	versionedCatalog, err := json.Marshal(NewCatalogFile(catalog))
 	if err != nil {
-		log.Fatal("Error during Unmarshal(): ", err)
+		return nil, err
+	}
+
+	if err = ioutil.WriteFile(temporaryDestination, versionedCatalog, 0644); err != nil {
+		log.Errorf("Error writing the catalog file: %s", err)
+		return nil, err
+	}
+
+	if err = os.Rename(temporaryDestination, destination); err != nil {
+		log.Errorf("Error replacing the catalog file: %s", err)
+		return nil, err
 	}
 
+	return catalog, nil
+}
+
+func (c *runnerService) setCatalog(catalog *Catalog, ready bool) {
+	c.catalogMu.Lock()
+	defer c.catalogMu.Unlock()
+
+	if c.catalog != catalog {
+		c.handshake = nil
+	}
 	c.catalog = catalog
-	c.ready = true
+	c.ready = ready
+}
 
-	return nil
+// setCatalogStatus updates the catalog build status, with the error of the failed builds
+func (c *runnerService) setCatalogStatus(status string, err error) {
+	c.catalogMu.Lock()
+	defer c.catalogMu.Unlock()
+
+	c.catalogStatus = status
+	c.catalogError = ""
+	if err != nil {
+		c.catalogError = err.Error()
+	}
+	if status != CatalogStatusBuilding {
+		c.lastCatalogBuildAt = time.Now().UTC()
+	}
+}
+
+func (c *runnerService) GetCatalogStatus() *CatalogStatus {
+	c.catalogMu.RLock()
+	defer c.catalogMu.RUnlock()
+
+	status := &CatalogStatus{
+		Status:        c.catalogStatus,
+		Ready:         c.ready,
+		SchemaVersion: CatalogSchemaVersion,
+		Version:       c.catalog.Version(),
+		Error:         c.catalogError,
+		Handshake:     c.handshake,
+	}
+	if c.catalog != nil {
+		status.Checks = len(*c.catalog)
+	}
+	if !c.lastCatalogBuildAt.IsZero() {
+		lastBuildAt := c.lastCatalogBuildAt
+		status.LastBuildAt = &lastBuildAt
+	}
+
+	return status
 }
 
 func (c *runnerService) GetCatalog() *Catalog {
+	c.catalogMu.RLock()
+	defer c.catalogMu.RUnlock()
+
 	return c.catalog
 }
 
@@ -103,40 +577,426 @@ func (c *runnerService) GetChannel() chan *ExecutionEvent {
 }
 
 func (c *runnerService) ScheduleExecution(e *ExecutionEvent) error {
-	if len(c.workerPoolChannel) == executionChannelSize {
-		return fmt.Errorf("Cannot process more executions")
+	if c.IsDraining() {
+		return ErrDraining
+	}
+
+	if _, ok := c.upstreams[e.Upstream]; e.Upstream != "" && !ok {
+		return fmt.Errorf("%w: %s", ErrUpstreamNotFound, e.Upstream)
+	}
+
+	if status, ok := c.executions.Track(e.ExecutionID); !ok {
+		return &duplicateExecutionError{status: status}
+	}
+	if status, ok := c.storedExecutionStatus(e.ExecutionID); ok {
+		c.executions.SetStatus(e.ExecutionID, status, true)
+		return &duplicateExecutionError{status: status}
+	}
+
+	// The executions are rejected once the queue is full, so the callers can retry them later
+	select {
+	case c.workerPoolChannel <- e:
+	default:
+		c.executions.Forget(e.ExecutionID)
+		return ErrExecutionQueueFull
 	}
 
-	c.workerPoolChannel <- e
 	log.Infof("Scheduled event: %s", e.ExecutionID.String())
 	return nil
 }
 
-func (c *runnerService) Execute(e *ExecutionEvent) error {
-	log.Infof("Executing event: %s on cluster: %s", e.ExecutionID.String(), e.ClusterID.String())
+func (c *runnerService) Execute(ctx context.Context, e *ExecutionEvent) error {
+	if e.traceContext.IsValid() {
+		ctx = trace.ContextWithRemoteSpanContext(ctx, e.traceContext)
+	}
+	ctx, span := tracer.Start(ctx, "Execute", trace.WithAttributes(
+		executionIDAttribute.String(e.ExecutionID.String()),
+		clusterIDAttribute.String(e.ClusterID.String()),
+	))
+
+	err := c.execute(ctx, e)
+	endSpan(span, err)
+
+	return err
+}
+
+func (c *runnerService) execute(ctx context.Context, e *ExecutionEvent) error {
+	logger := executionLogger(e)
+	ctx = withLogger(ctx, logger)
+
+	logger.Infof("Executing event: %s on cluster: %s", e.ExecutionID.String(), e.ClusterID.String())
 
 	executionStartedPayload := map[string]string{"cluster_id": e.ClusterID.String()}
-	if err := c.callbacksClient.Callback(e.ExecutionID, executionStartedEvent, executionStartedPayload); err != nil {
-		log.Errorf(
-			"Error running callback. Execution ID: %s, Event: %s. Err: %s", e.ExecutionID.String(), executionStartedEvent, err)
-		return err
+	// A server outage does not abort the execution, the rest of the callbacks are retried by the dispatcher
+	if err := callbacksRetryPolicy().Do(ctx, func() error {
+		return c.executionCallbacksClient(e).Callback(e.ExecutionID, executionStartedEvent, executionStartedPayload)
+	}, nil); err != nil {
+		logger.Errorf(
+			"Error running callback, running the execution anyway. Execution ID: %s, Event: %s. Err: %s",
+			e.ExecutionID.String(), executionStartedEvent, err)
 	}
+	c.events.Publish(e.ExecutionID, executionStartedEvent, executionStartedPayload)
 
-	checksRunner, err := NewAnsibleCheckRunner(c.config, e)
+	record := &ExecutionRecord{
+		ExecutionID: e.ExecutionID,
+		ClusterID:   e.ClusterID,
+		Upstream:    e.Upstream,
+		Status:      ExecutionRunning,
+		StartedAt:   time.Now().UTC(),
+	}
+	c.saveExecutionRecord(record)
+
+	// Nothing to run in the cluster hosts, the execution is finished right away
+	if len(e.Checks) == 0 {
+		logger.Infof("No checks selected in cluster %s, skipping the execution %s", e.ClusterID.String(), e.ExecutionID.String())
+		c.finishExecutionRecord(record, nil, nil)
+		c.dispatchCallback(e, executionFinishedEvent, map[string]string{"cluster_id": e.ClusterID.String()})
+		return nil
+	}
+
+	// The hosts in a maintenance window are not checked, their checks are reported as skipped right away
+	e, maintenanceResults := c.selectHostsOutOfMaintenance(ctx, e)
+	if maintenanceResults != nil {
+		c.reportResults(e, maintenanceResults)
+		if len(e.Limit) == 0 {
+			logger.Infof("All the hosts of execution %s are in maintenance, skipping the playbook", e.ExecutionID.String())
+			c.finishExecutionRecord(record, nil, maintenanceResults)
+			c.dispatchCallback(e, executionFinishedEvent, map[string]string{"cluster_id": e.ClusterID.String()})
+			return nil
+		}
+	}
+
+	// The premium checks are not run without the subscription entitlement, they are reported as skipped
+	e, notEntitledResults := c.selectEntitledChecks(ctx, e)
+	if len(e.Checks) == 0 {
+		logger.Infof("None of the checks of execution %s is entitled, skipping the playbook", e.ExecutionID.String())
+		c.reportResults(e, notEntitledResults)
+		c.finishExecutionRecord(record, nil, notEntitledResults)
+		c.dispatchCallback(e, executionFinishedEvent, map[string]string{"cluster_id": e.ClusterID.String()})
+		return nil
+	}
+
+	inventoryEvent := e
+	catalogVersion := c.GetCatalog().Version()
+	var cachedResults *ExecutionResults
+	// The remediation executions run all the checks, so the cached failing results are remediated as well
+	if c.resultsCache != nil && !e.Remediate {
+		inventoryEvent, cachedResults = c.selectUncachedChecks(e, catalogVersion)
+		// Every check has a fresh result, the last ones are reported again
+		if len(inventoryEvent.Hosts) == 0 {
+			logger.Infof("All the checks results of execution %s are cached, skipping the playbook", e.ExecutionID.String())
+			c.reportResults(e, cachedResults)
+			c.finishExecutionRecord(record, nil, cachedResults)
+			c.dispatchCallback(e, executionFinishedEvent, map[string]string{"cluster_id": e.ClusterID.String()})
+			return nil
+		}
+	}
+
+	if c.config.CloudInventory {
+		inventoryEvent = resolveHostsAddresses(ctx, inventoryEvent)
+	}
+	inventoryEvent = overrideHostsAddresses(ctx, inventoryEvent, c.config.DNSOverrides)
+
+	var unreachableResults *ExecutionResults
+	if c.config.PreflightTimeout > 0 {
+		inventoryEvent, unreachableResults = preflightHosts(ctx, inventoryEvent, c.config.PreflightTimeout, c.config.SSHBastion)
+		if cachedResults != nil {
+			unreachableResults.moveHostsResults(cachedResults)
+		}
+		// The unreachable hosts are reported right away, without waiting for the checks in the rest of hosts
+		c.reportResults(e, unreachableResults)
+	}
+
+	// The hosts with untrusted keys are not checked, and the ones with a changed key report it as their checks results
+	var untrustedResults *ExecutionResults
+	if c.hostKeys != nil && !c.config.DryRun && !e.DryRun {
+		inventoryEvent, untrustedResults = c.hostKeys.verifyHostKeys(ctx, inventoryEvent, c.config.SSHBastion)
+		c.reportResults(e, untrustedResults)
+	}
+
+	// The inventory of the hosts to check is kept for the inventory API, with its hosts changes
+	if inventoryContent, err := NewClusterInventoryContent(inventoryEvent); err == nil {
+		c.inventories.Record(inventoryEvent, inventoryContent)
+	}
+
+	c.logs.Start(e.ExecutionID)
+	defer c.logs.Finish(e.ExecutionID)
+	outputHandler := func(stream, line string) {
+		c.logs.Write(e.ExecutionID, stream, line)
+	}
+	if c.config.PartialResults {
+		outputHandler = c.partialResultsHandler(e, outputHandler)
+	}
+
+	runCtx := ctx
+	if c.config.ExecutionTimeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, c.config.ExecutionTimeout)
+		defer cancel()
+	}
+
+	var results *ExecutionResults
+	var err error
+	if (unreachableResults != nil || untrustedResults != nil) && len(inventoryEvent.targetHosts()) == 0 {
+		logger.Infof("None of the hosts of execution %s is reachable, skipping the playbook", e.ExecutionID.String())
+	} else {
+		// The executions requested while the runner starts wait for the ansible files, not for the catalog
+		err = c.startup.waitPrepared(runCtx)
+		if err == nil {
+			c.filesMu.RLock()
+			err = c.verifyManifest()
+			if err == nil {
+				results, err = c.runWithHooks(ctx, runCtx, inventoryEvent, outputHandler)
+			}
+			c.filesMu.RUnlock()
+		}
+	}
+	if err != nil && ctx.Err() == nil && runCtx.Err() == context.DeadlineExceeded {
+		err = &executionTimeoutError{timeout: c.config.ExecutionTimeout, err: err}
+	}
 	if err != nil {
+		if preemptor := preemptedBy(ctx); preemptor != nil {
+			return c.preempted(ctx, e, record, preemptor)
+		}
+
+		logger.Errorf("Error running the checks: %s", err)
+		executionFailedPayload := map[string]string{"cluster_id": e.ClusterID.String(), "reason": err.Error()}
+		c.dispatchCallback(e, executionFailedEvent, executionFailedPayload)
+		c.finishExecutionRecord(record, err, nil)
 		return err
 	}
 
-	defer os.RemoveAll(path.Dir(checksRunner.Inventory))
+	if results != nil && c.resultsCache != nil {
+		c.resultsCache.Store(catalogVersion, results)
+	}
+	if results != nil && c.checkDurations != nil {
+		c.checkDurations.Observe(results)
+	}
+	if cachedResults != nil {
+		if results == nil {
+			results = &ExecutionResults{ClusterID: e.ClusterID.String(), Hosts: []*HostResults{}}
+		}
+		results.addCachedResults(cachedResults)
+	}
+	if notEntitledResults != nil {
+		if results == nil {
+			results = &ExecutionResults{ClusterID: e.ClusterID.String(), Hosts: []*HostResults{}}
+		}
+		results.addCachedResults(notEntitledResults)
+	}
+	if results != nil {
+		c.reportResults(e, results)
+	}
+	// The unreachable and untrusted hosts and the ones in maintenance are already reported, they are added for the execution summary
+	for _, reported := range []*ExecutionResults{unreachableResults, untrustedResults, maintenanceResults} {
+		if reported == nil || len(reported.Hosts) == 0 {
+			continue
+		}
+		if results == nil {
+			results = &ExecutionResults{ClusterID: e.ClusterID.String(), Hosts: []*HostResults{}}
+		}
+		results.addCachedResults(reported)
+	}
+	c.finishExecutionRecord(record, nil, results)
 
-	if err := checksRunner.RunPlaybook(); err != nil {
-		log.Errorf("Error running the checks playbook")
-		return err
+	executionFinishedPayload := map[string]string{"cluster_id": e.ClusterID.String()}
+	c.dispatchCallback(e, executionFinishedEvent, executionFinishedPayload)
+
+	return nil
+}
+
+// preempted reports the execution cancelled by a higher priority execution of the cluster, which is queued again
+func (c *runnerService) preempted(ctx context.Context, e *ExecutionEvent, record *ExecutionRecord, preemptor *ExecutionEvent) error {
+	err := fmt.Errorf("%w %s", ErrExecutionPreempted, preemptor.ExecutionID.String())
+	loggerFromContext(ctx).Warnf("Execution %s stopped: %s", e.ExecutionID.String(), err)
+
+	executionPreemptedPayload := map[string]string{
+		"cluster_id":   e.ClusterID.String(),
+		"preempted_by": preemptor.ExecutionID.String(),
+	}
+	c.dispatchCallback(e, executionPreemptedEvent, executionPreemptedPayload)
+
+	record.Status = ExecutionPreempted
+	record.Error = err.Error()
+	c.saveExecutionRecord(record)
+
+	return err
+}
+
+// selectUncachedChecks returns a copy of the execution event with the checks to run in each host,
+// excluding the hosts where all the checks results are cached, and the cached results
+func (c *runnerService) selectUncachedChecks(e *ExecutionEvent, catalogVersion string) (*ExecutionEvent, *ExecutionResults) {
+	selected := *e
+	selected.Hosts = []*Host{}
+	cachedResults := &ExecutionResults{ClusterID: e.ClusterID.String(), Hosts: []*HostResults{}}
+
+	for _, host := range e.targetHosts() {
+		cached, pending := c.resultsCache.Get(host.HostID.String(), catalogVersion, e.hostChecks(host))
+		if len(cached) > 0 {
+			cachedResults.addHost(host.HostID.String(), true, "")
+			for _, result := range cached {
+				cachedResults.addResult(host.HostID.String(), result.CheckID, result.Result, result.Msg)
+			}
+		}
+
+		if len(pending) == 0 {
+			continue
+		}
+
+		selectedHost := *host
+		selectedHost.checks = pending
+		selected.Hosts = append(selected.Hosts, &selectedHost)
+	}
+
+	return &selected, cachedResults
+}
+
+// storedExecutionStatus returns the status of the execution recently finished before the runner restarted
+func (c *runnerService) storedExecutionStatus(executionID uuid.UUID) (string, bool) {
+	if c.executionsStore == nil {
+		return "", false
+	}
+
+	record, err := c.executionsStore.Get(executionID)
+	if err != nil || record == nil || record.FinishedAt == nil || time.Since(*record.FinishedAt) >= executionDedupInterval {
+		return "", false
+	}
+
+	return record.Status, true
+}
+
+// Reload applies the settings of the reloaded configuration that do not need a restart: the callbacks url
+// and the full resync interval. The callbacks url cannot be set or unset, as the callbacks clients do not change
+func (c *runnerService) Reload(config *Config) error {
+	if (c.config.CallbacksUrl == "") != (config.CallbacksUrl == "") {
+		return fmt.Errorf("the callbacks url cannot be set or unset without a restart")
+	}
+
+	if c.changesFilter != nil {
+		c.changesFilter.SetResyncInterval(config.FullResyncInterval)
+	}
+
+	if config.CallbacksUrl == c.currentCallbacksUrl() {
+		return nil
+	}
+
+	c.callbacksUrlMu.Lock()
+	c.callbacksUrl = config.CallbacksUrl
+	c.callbacksUrlMu.Unlock()
+
+	if client, ok := c.callbacksClient.(*callbacksClient); ok {
+		client.SetCallbacksUrl(config.CallbacksUrl)
+	}
+	if c.serverConnectivity != nil {
+		c.serverConnectivity.SetUrl(config.CallbacksUrl)
 	}
+	log.Infof("The callbacks are sent to %s now", redactedUrl(config.CallbacksUrl))
 
 	return nil
 }
 
+func (c *runnerService) currentCallbacksUrl() string {
+	c.callbacksUrlMu.RLock()
+	defer c.callbacksUrlMu.RUnlock()
+
+	return c.callbacksUrl
+}
+
+func (c *runnerService) saveExecutionRecord(record *ExecutionRecord) {
+	c.executions.SetStatus(record.ExecutionID, record.Status, record.FinishedAt != nil)
+
+	if c.executionsStore == nil {
+		return
+	}
+
+	if err := c.executionsStore.Save(record); err != nil {
+		log.Errorf("Error saving the execution %s record: %s", record.ExecutionID.String(), err)
+	}
+}
+
+// finishExecutionRecord stores the execution outcome, getting the ansible return code from the error
+func (c *runnerService) finishExecutionRecord(record *ExecutionRecord, err error, results *ExecutionResults) {
+	finishedAt := time.Now().UTC()
+	record.FinishedAt = &finishedAt
+	record.Status = ExecutionCompleted
+
+	if err != nil {
+		record.Status = ExecutionFailed
+		if errors.Is(err, ErrExecutionTimeout) {
+			record.Status = ExecutionTimedOut
+		}
+		record.Error = err.Error()
+		record.ReturnCode = -1
+
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			record.ReturnCode = exitErr.ExitCode()
+		}
+	}
+
+	if results != nil {
+		record.Summary = c.mapSeverities(results).Summary()
+		if c.config.TaskOutputMaxSize > 0 {
+			record.Outputs = results.Outputs(c.config.TaskOutputMaxSize)
+		}
+		record.CheckDurations = results.CheckDurations()
+	}
+
+	c.saveExecutionRecord(record)
+}
+
+// mapSeverities returns the results with the severities of the catalog and the runner configuration
+func (c *runnerService) mapSeverities(results *ExecutionResults) *ExecutionResults {
+	return NewSeverityMapper(c.config, c.GetCatalog()).Map(results)
+}
+
+// executionCallbacksClient returns the client of the Trento server that requested the execution
+func (c *runnerService) executionCallbacksClient(e *ExecutionEvent) CallbacksClient {
+	if upstream, ok := c.upstreams[e.Upstream]; ok && e.Upstream != "" {
+		return upstream.callbacksClient
+	}
+
+	return c.callbacksClient
+}
+
+// reportResults sends the results of each host and check to the server
+func (c *runnerService) reportResults(e *ExecutionEvent, results *ExecutionResults) {
+	results = c.mapSeverities(results)
+	if c.changesFilter != nil {
+		results = c.changesFilter.Filter(e.ExecutionID, results)
+	}
+
+	for _, host := range results.Hosts {
+		hostCompletedPayload := map[string]interface{}{
+			"cluster_id": results.ClusterID,
+			"host_id":    host.HostID,
+			"reachable":  host.Reachable,
+			"msg":        host.Msg,
+		}
+		c.dispatchCallback(e, hostCompletedEvent, hostCompletedPayload)
+
+		for _, result := range host.Results {
+			checkResultPayload := map[string]interface{}{
+				"cluster_id": results.ClusterID,
+				"host_id":    host.HostID,
+				"check_id":   result.CheckID,
+				"result":     result.Result,
+				"msg":        result.Msg,
+			}
+			c.dispatchCallback(e, checkResultEvent, checkResultPayload)
+		}
+	}
+}
+
+func (c *runnerService) dispatchCallback(e *ExecutionEvent, event string, payload interface{}) {
+	c.events.Publish(e.ExecutionID, event, payload)
+
+	if err := c.callbacksDispatcher.DispatchUpstream(e.Upstream, e.ExecutionID, event, payload); err != nil {
+		log.Errorf(
+			"Error dispatching callback. Execution ID: %s, Event: %s. Err: %s", e.ExecutionID.String(), event, err)
+	}
+}
+
 func createAnsibleFiles(folder string) error {
 	log.Infof("Creating the ansible file structure in %s", folder)
 	// Clean the folder if it stores old files
@@ -164,12 +1024,10 @@ func createAnsibleFiles(folder string) error {
 				log.Errorf("Error reading file %s", fileName)
 				return err
 			}
-			f, err := os.Create(path.Join(folder, fileName))
-			if err != nil {
+			if err := ioutil.WriteFile(path.Join(folder, fileName), content, 0644); err != nil {
 				log.Errorf("Error creating file %s", fileName)
 				return err
 			}
-			fmt.Fprintf(f, "%s", content)
 		} else {
 			os.Mkdir(path.Join(folder, fileName), 0755)
 		}
@@ -181,14 +1039,113 @@ func createAnsibleFiles(folder string) error {
 		return err
 	}
 
+	// A full disk, or a concurrent removal, could leave the files half written
+	if err := verifyExtractedFiles(folder); err != nil {
+		log.Errorf("Error verifying the ansible file structure: %s", err)
+		return err
+	}
+
 	log.Info("Ansible file structure successfully created")
 
 	return nil
 }
 
+func createAnsibleConfigFile(config *Config) error {
+	configFile := path.Join(config.AnsibleFolder, AnsibleConfigFile)
+	if err := CreateAnsibleConfig(configFile, NewAnsibleConfigContent(config)); err != nil {
+		log.Errorf("Error creating the ansible configuration file: %s", err)
+		return err
+	}
+
+	if err := createAnsibleWritablePaths(config); err != nil {
+		log.Errorf("Error creating the ansible writable folders: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+// prepareAnsibleFiles creates the ansible file structure with the checks of the catalog sources, out of the runner service
+func prepareAnsibleFiles(config *Config) error {
+	if err := createAnsibleFiles(config.AnsibleFolder); err != nil {
+		return err
+	}
+
+	if err := createAnsibleConfigFile(config); err != nil {
+		return err
+	}
+
+	_, err := copyCatalogSources(context.Background(), NewCatalogSources(config), path.Join(config.AnsibleFolder, AnsibleChecks))
+	return err
+}
+
+func copyCustomChecks(customChecksDir, checksFolder string) error {
+	log.Infof("Copying the custom checks from %s", customChecksDir)
+
+	info, err := os.Stat(customChecksDir)
+	if err != nil {
+		log.Errorf("Error opening the custom checks folder: %s", err)
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("custom checks folder %s is not a directory", customChecksDir)
+	}
+
+	err = filepath.WalkDir(customChecksDir, func(fileName string, dir fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(customChecksDir, fileName)
+		if err != nil {
+			return err
+		}
+		destination := path.Join(checksFolder, relativePath)
+
+		// The translations are merged in the catalog, they are not a check
+		if dir.IsDir() && relativePath == customTranslationsFolder {
+			return filepath.SkipDir
+		}
+		// The checks of a git repository do not have its metadata
+		if dir.IsDir() && dir.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		// Each check folder replaces the one with the same name, so no file of the replaced check is left behind
+		if dir.IsDir() && relativePath != "." && !strings.Contains(relativePath, string(filepath.Separator)) {
+			if err := os.RemoveAll(destination); err != nil {
+				return err
+			}
+		}
+		if dir.IsDir() {
+			return os.MkdirAll(destination, 0755)
+		}
+
+		content, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			log.Errorf("Error reading file %s", fileName)
+			return err
+		}
+		if err := ioutil.WriteFile(destination, content, 0644); err != nil {
+			log.Errorf("Error creating file %s", destination)
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		log.Errorf("An error ocurred copying the custom checks: %s", err)
+		return err
+	}
+
+	log.Info("Custom checks successfully copied")
+
+	return nil
+}
+
 func NewAnsibleMetaRunner(config *Config) (*AnsibleRunner, error) {
 	playbookPath := path.Join(config.AnsibleFolder, AnsibleMeta)
-	ansibleRunner := DefaultAnsibleRunner()
+	ansibleRunner := NewAnsibleRunner(config)
 
 	if err := ansibleRunner.SetPlaybook(playbookPath); err != nil {
 		return ansibleRunner, err
@@ -198,43 +1155,247 @@ func NewAnsibleMetaRunner(config *Config) (*AnsibleRunner, error) {
 	ansibleRunner.SetConfigFile(configFile)
 	destination := path.Join(config.AnsibleFolder, CatalogDestinationFile)
 	ansibleRunner.SetCatalogDestination(destination)
+	setVaultCredentials(config, ansibleRunner)
 
 	return ansibleRunner, nil
 }
 
 func NewAnsibleCheckRunner(config *Config, executionEvent *ExecutionEvent) (*AnsibleRunner, error) {
+	logger := executionLogger(executionEvent)
 	playbookPath := path.Join(config.AnsibleFolder, AnsibleMain)
 
-	ansibleRunner := DefaultAnsibleRunner()
+	ansibleRunner := NewAnsibleRunner(config)
+	// The container is named after the execution run, so it is removed if the execution is cancelled
+	if ansibleRunner.Container != nil {
+		ansibleRunner.Container.Name = "trento-" + executionEvent.runID()
+	}
 
 	if err := ansibleRunner.SetPlaybook(playbookPath); err != nil {
 		return ansibleRunner, err
 	}
 
-	ansibleRunner.Check = true
+	// The remediation runs apply the fix of the checks, the rest only check the hosts
+	ansibleRunner.Check = !executionEvent.remediation
 	configFile := path.Join(config.AnsibleFolder, AnsibleConfigFile)
 	ansibleRunner.SetConfigFile(configFile)
-	ansibleRunner.SetTrentoCallbacksUrl(config.CallbacksUrl)
 	ansibleRunner.SetTrentoExecutionID(executionEvent.ExecutionID.String())
+	ansibleRunner.SetJSONOutput()
+
+	if config.PartialResults {
+		ansibleRunner.SetPartialResults()
+	}
+
+	if config.TaskTimeout > 0 {
+		ansibleRunner.SetTaskTimeout(config.TaskTimeout)
+	}
+
+	setVaultCredentials(config, ansibleRunner)
+	setFactCacheCredentials(config, ansibleRunner)
+
+	if err := setSSHCredentials(config, ansibleRunner); err != nil {
+		logger.Errorf("Error setting the ssh credentials: %s", err)
+		return nil, err
+	}
 
 	inventoryContent, err := NewClusterInventoryContent(executionEvent)
 	if err != nil {
-		log.Errorf("Error generating inventory content: %s", err)
+		logger.Errorf("Error generating inventory content: %s", err)
+		return nil, err
+	}
+
+	if config.SSHAgentForwarding {
+		inventoryContent.SetNodesVariable(sshExtraArgs, fmt.Sprintf("'%s'", sshAgentForwardingArg))
+	}
+
+	// The connection options of the execution replace the configured inventory variables in the hosts that have them
+	if err := setInventoryVariables(config.InventoryVariables, inventoryContent); err != nil {
+		logger.Errorf("Error setting the inventory variables: %s", err)
 		return nil, err
 	}
+	ansibleRunner.secretVariables = secretVariables(config)
 
-	inventoryFile := path.Join(
-		config.AnsibleFolder, fmt.Sprintf(AnsibleInventories, executionEvent.ExecutionID.String()))
+	if err := setConnectionVariables(config, executionEvent, inventoryContent, ansibleRunner); err != nil {
+		logger.Errorf("Error setting the hosts connection options: %s", err)
+		return nil, err
+	}
+
+	workDir := executionWorkDir(config, executionEvent.runID())
+	if err := createWorkDir(workDir); err != nil {
+		logger.Errorf("Error creating the execution work dir: %s", err)
+		return nil, err
+	}
+	ansibleRunner.SetWorkDir(workDir)
+
+	inventoryFile := path.Join(workDir, AnsibleInventory)
 
 	if err := CreateInventory(inventoryFile, inventoryContent); err != nil {
-		log.Errorf("Error creating the inventory file: %s", err)
+		logger.Errorf("Error creating the inventory file: %s", err)
 		return nil, err
 	}
 
 	if err := ansibleRunner.SetInventory(inventoryFile); err != nil {
-		log.Errorf("Error setting the inventory file")
+		logger.Errorf("Error setting the inventory file")
 		return nil, err
 	}
 
+	// Only the placeholders of the configured variables are resolved, the execution ones are given as they are
+	checkVariables, err := resolveVariables(config.CheckVariables)
+	if err != nil {
+		logger.Errorf("Error resolving the check variables: %s", err)
+		return nil, err
+	}
+	variables := checksVariables(config, executionEvent, checkVariables)
+	checks, err := orderedChecks(config)
+	if err != nil {
+		logger.Errorf("Error sorting the checks by their dependencies: %s", err)
+		return nil, err
+	}
+	if checks != nil {
+		order, err := checksOrder(checks)
+		if err != nil {
+			return nil, err
+		}
+		orderedVariables := make(map[string]interface{}, len(variables)+1)
+		for name, value := range variables {
+			orderedVariables[name] = value
+		}
+		orderedVariables[checksOrderVariable] = order
+		variables = orderedVariables
+	}
+	if len(variables) > 0 {
+		extraVarsFile := path.Join(workDir, AnsibleExtraVars)
+		if err := createExtraVarsFile(extraVarsFile, variables); err != nil {
+			logger.Errorf("Error creating the extra vars file: %s", err)
+			return nil, err
+		}
+		ansibleRunner.ExtraVarsFile = extraVarsFile
+	}
+
+	if len(executionEvent.Limit) > 0 {
+		for _, host := range executionEvent.targetHosts() {
+			ansibleRunner.Limit = append(ansibleRunner.Limit, host.HostID.String())
+		}
+	}
+
+	ansibleRunner.Tags = executionEvent.Tags
+	ansibleRunner.SkipTags = executionEvent.SkipTags
+
 	return ansibleRunner, nil
 }
+
+// checksVariables returns the resolved check variables and the execution variables with the runner settings used by
+// the checks playbook: the timeouts of the checks tasks, in seconds, the number of hosts where the rolling checks
+// run at the same time, and if the run is a remediation one
+func checksVariables(
+	config *Config, executionEvent *ExecutionEvent, checkVariables map[string]interface{}) map[string]interface{} {
+
+	rollingBatchSize := config.RollingBatchSize
+	if executionEvent.RollingBatchSize > 0 {
+		rollingBatchSize = executionEvent.RollingBatchSize
+	}
+
+	if config.CheckTimeout <= 0 && len(config.CheckTimeouts) == 0 && rollingBatchSize <= 0 &&
+		!executionEvent.remediation && len(checkVariables) == 0 {
+		return executionEvent.Variables
+	}
+
+	// The execution variables take precedence over the configured ones
+	variables := make(map[string]interface{}, len(checkVariables)+len(executionEvent.Variables)+4)
+	for name, value := range checkVariables {
+		variables[name] = value
+	}
+	for name, value := range executionEvent.Variables {
+		variables[name] = value
+	}
+
+	if config.CheckTimeout > 0 {
+		variables[checkTimeoutVariable] = timeoutSeconds(config.CheckTimeout)
+	}
+
+	if len(config.CheckTimeouts) > 0 {
+		timeouts := make(map[string]int64, len(config.CheckTimeouts))
+		for checkID, timeout := range config.CheckTimeouts {
+			timeouts[checkID] = timeoutSeconds(timeout)
+		}
+		variables[checkTimeoutsVariable] = timeouts
+	}
+
+	if rollingBatchSize > 0 {
+		variables[rollingBatchSizeVariable] = rollingBatchSize
+	}
+
+	if executionEvent.remediation {
+		variables[remediationVariable] = true
+	}
+
+	return variables
+}
+
+// timeoutSeconds returns the timeout rounded up to seconds, as ansible takes them
+func timeoutSeconds(timeout time.Duration) int64 {
+	return int64((timeout + time.Second - 1) / time.Second)
+}
+
+// createExtraVarsFile writes the variables in json, so ansible keeps their types
+func createExtraVarsFile(destination string, variables map[string]interface{}) error {
+	content, err := json.Marshal(variables)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(path.Dir(destination), 0700); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(destination, content, 0600)
+}
+
+// setVaultCredentials configures the vault passwords used to decrypt the encrypted variables of the checks
+func setVaultCredentials(config *Config, ansibleRunner *AnsibleRunner) {
+	if config.VaultPasswordFile != "" {
+		ansibleRunner.SetVaultPasswordFile(config.VaultPasswordFile)
+	}
+
+	if len(config.VaultIDs) > 0 {
+		ansibleRunner.SetVaultIDs(config.VaultIDs)
+	}
+}
+
+// setSSHCredentials configures the ssh private key used to connect to the hosts and its passphrase.
+// The passphrase file is read in every execution, so the secret can be rotated without restarting
+func setSSHCredentials(config *Config, ansibleRunner *AnsibleRunner) error {
+	if config.SSHPrivateKeyFile != "" {
+		ansibleRunner.SetPrivateKeyFile(config.SSHPrivateKeyFile)
+	}
+
+	passphrase, err := readSSHPassphrase(config)
+	if err != nil {
+		return err
+	}
+
+	if passphrase == "" {
+		return nil
+	}
+
+	askPassProgram := path.Join(config.AnsibleFolder, AnsibleSSHAskPass)
+	if err := ioutil.WriteFile(askPassProgram, []byte(sshAskPassScript), 0700); err != nil {
+		return err
+	}
+	ansibleRunner.SetSSHPassphrase(passphrase, askPassProgram)
+
+	return nil
+}
+
+// readSSHPassphrase returns the private key passphrase, reading it from the passphrase file if it is set
+func readSSHPassphrase(config *Config) (string, error) {
+	if config.SSHPassphraseFile == "" {
+		return config.SSHPassphrase, nil
+	}
+
+	content, err := ioutil.ReadFile(config.SSHPassphraseFile)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
@@ -2,7 +2,11 @@
 
 package runner
 
-import mock "github.com/stretchr/testify/mock"
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
 
 // MockRunnerService is an autogenerated mock type for the RunnerService type
 type MockRunnerService struct {
@@ -23,13 +27,18 @@ func (_m *MockRunnerService) BuildCatalog() error {
 	return r0
 }
 
-// Execute provides a mock function with given fields: e
-func (_m *MockRunnerService) Execute(e *ExecutionEvent) error {
-	ret := _m.Called(e)
+// Drain provides a mock function with given fields:
+func (_m *MockRunnerService) Drain() {
+	_m.Called()
+}
+
+// Execute provides a mock function with given fields: ctx, e
+func (_m *MockRunnerService) Execute(ctx context.Context, e *ExecutionEvent) error {
+	ret := _m.Called(ctx, e)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(*ExecutionEvent) error); ok {
-		r0 = rf(e)
+	if rf, ok := ret.Get(0).(func(context.Context, *ExecutionEvent) error); ok {
+		r0 = rf(ctx, e)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -53,6 +62,22 @@ func (_m *MockRunnerService) GetCatalog() *Catalog {
 	return r0
 }
 
+// GetCatalogStatus provides a mock function with given fields:
+func (_m *MockRunnerService) GetCatalogStatus() *CatalogStatus {
+	ret := _m.Called()
+
+	var r0 *CatalogStatus
+	if rf, ok := ret.Get(0).(func() *CatalogStatus); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*CatalogStatus)
+		}
+	}
+
+	return r0
+}
+
 // GetChannel provides a mock function with given fields:
 func (_m *MockRunnerService) GetChannel() chan *ExecutionEvent {
 	ret := _m.Called()
@@ -83,6 +108,62 @@ func (_m *MockRunnerService) IsCatalogReady() bool {
 	return r0
 }
 
+// IsDraining provides a mock function with given fields:
+func (_m *MockRunnerService) IsDraining() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
+// IsServerReachable provides a mock function with given fields:
+func (_m *MockRunnerService) IsServerReachable() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
+// RebuildCatalog provides a mock function with given fields:
+func (_m *MockRunnerService) RebuildCatalog() error {
+	ret := _m.Called()
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Reload provides a mock function with given fields: config
+func (_m *MockRunnerService) Reload(config *Config) error {
+	ret := _m.Called(config)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*Config) error); ok {
+		r0 = rf(config)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // ScheduleExecution provides a mock function with given fields: e
 func (_m *MockRunnerService) ScheduleExecution(e *ExecutionEvent) error {
 	ret := _m.Called(e)
@@ -1,15 +1,27 @@
 package runner
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 
 	"github.com/trento-project/runner/runner/mocks"
 )
@@ -20,7 +32,7 @@ const (
 
 type RunnerTestCase struct {
 	suite.Suite
-	runnerService   RunnerService
+	runnerService   *runnerService
 	ansibleDir      string
 	callbacksClient *mocks.CallbacksClient
 }
@@ -30,19 +42,31 @@ func TestRunnerTestCase(t *testing.T) {
 }
 
 func (suite *RunnerTestCase) SetupTest() {
+	callbacksRetryInterval = time.Millisecond
 	callbacksClient := new(mocks.CallbacksClient)
 	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
 	runnerService, _ := NewRunnerService(&Config{AnsibleFolder: tmpDir})
 	runnerService.callbacksClient = callbacksClient
+	runnerService.callbacksDispatcher = NewCallbacksDispatcher(callbacksClient)
 	suite.runnerService = runnerService
 	suite.ansibleDir = tmpDir
 	suite.callbacksClient = callbacksClient
 }
 
+func (suite *RunnerTestCase) Test_NewRunnerService_Standalone() {
+	runnerService, err := NewRunnerService(&Config{AnsibleFolder: suite.ansibleDir, ResultsDir: suite.ansibleDir})
+	suite.NoError(err)
+
+	suite.IsType(&resultsDirClient{}, runnerService.callbacksClient)
+	suite.Len(runnerService.callbacksDispatcher.callbacksClients, 1)
+	suite.True(runnerService.IsServerReachable())
+}
+
 func (suite *RunnerTestCase) Test_BuildCatalog() {
 	suite.Equal(false, suite.runnerService.IsCatalogReady())
+	suite.Equal(&CatalogStatus{Status: "building", SchemaVersion: CatalogSchemaVersion}, suite.runnerService.GetCatalogStatus())
 
-	cmd := exec.Command("cp", "../test/fixtures/catalog.json", path.Join(suite.ansibleDir, "ansible"))
+	cmd := exec.Command("cp", "../test/fixtures/catalog.json", path.Join(suite.ansibleDir, "ansible/catalog.json.tmp"))
 
 	mockCommand := new(mocks.CustomCommand)
 	customExecCommand = mockCommand.Execute
@@ -82,6 +106,172 @@ func (suite *RunnerTestCase) Test_BuildCatalog() {
 	suite.NoError(err)
 	suite.Equal(true, suite.runnerService.IsCatalogReady())
 	suite.Equal(expectedCatalog, suite.runnerService.GetCatalog())
+
+	status := suite.runnerService.GetCatalogStatus()
+	suite.Equal("ready", status.Status)
+	suite.True(status.Ready)
+	suite.Equal(2, status.Checks)
+	suite.Equal(expectedCatalog.Version(), status.Version)
+	suite.NotNil(status.LastBuildAt)
+	suite.Empty(status.Error)
+	suite.Equal(StartupReady, suite.runnerService.startup.Phase())
+}
+
+func (suite *RunnerTestCase) Test_BuildCatalog_CustomChecksNotFound() {
+	suite.runnerService.config.CustomChecksDir = path.Join(suite.ansibleDir, "other")
+	defer os.RemoveAll(suite.ansibleDir)
+
+	err := suite.runnerService.BuildCatalog()
+	suite.Error(err)
+	suite.Equal("failed", suite.runnerService.GetCatalogStatus().Status)
+	suite.Equal(StartupFailed, suite.runnerService.startup.Phase())
+	// The ansible files are extracted anyway
+	suite.FileExists(path.Join(suite.ansibleDir, "ansible/meta.yml"))
+}
+
+func (suite *RunnerTestCase) Test_RebuildCatalog() {
+	os.MkdirAll(path.Join(suite.ansibleDir, "ansible"), 0755)
+	os.Create(path.Join(suite.ansibleDir, "ansible/meta.yml"))
+	defer os.RemoveAll(suite.ansibleDir)
+
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+
+	mockCommand.On(
+		"Execute", "ansible-playbook", path.Join(suite.ansibleDir, "ansible/meta.yml")).Return(
+		exec.Command("cp", "../test/fixtures/catalog.json", path.Join(suite.ansibleDir, "ansible/catalog.json.tmp")),
+	).Once()
+
+	err := suite.runnerService.RebuildCatalog()
+	suite.NoError(err)
+	suite.Equal(true, suite.runnerService.IsCatalogReady())
+	suite.FileExists(path.Join(suite.ansibleDir, "ansible/catalog.json"))
+	suite.NoFileExists(path.Join(suite.ansibleDir, "ansible/catalog.json.tmp"))
+	previousCatalog := suite.runnerService.GetCatalog()
+
+	// The catalog file has the schema and content versions
+	var catalogFile *CatalogFile
+	content, _ := ioutil.ReadFile(path.Join(suite.ansibleDir, "ansible/catalog.json"))
+	suite.NoError(json.Unmarshal(content, &catalogFile))
+	suite.Equal(CatalogSchemaVersion, catalogFile.SchemaVersion)
+	suite.Equal(previousCatalog.Version(), catalogFile.Version)
+	suite.Equal(previousCatalog, catalogFile.Checks)
+
+	// A failed rebuild keeps the previous catalog
+	mockCommand.On(
+		"Execute", "ansible-playbook", path.Join(suite.ansibleDir, "ansible/meta.yml")).Return(
+		exec.Command("sh", "-c", "exit 1"),
+	).Once()
+
+	err = suite.runnerService.RebuildCatalog()
+	suite.EqualError(err, "exit status 1")
+	suite.Equal(true, suite.runnerService.IsCatalogReady())
+	suite.Equal(previousCatalog, suite.runnerService.GetCatalog())
+
+	status := suite.runnerService.GetCatalogStatus()
+	suite.Equal("failed", status.Status)
+	suite.True(status.Ready)
+	suite.Equal(2, status.Checks)
+	suite.Equal("exit status 1", status.Error)
+}
+
+func (suite *RunnerTestCase) Test_RebuildCatalog_CustomChecks() {
+	customChecksDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(customChecksDir)
+	defer os.RemoveAll(suite.ansibleDir)
+
+	os.MkdirAll(path.Join(customChecksDir, "9.9.9/defaults"), 0755)
+	ioutil.WriteFile(path.Join(customChecksDir, "9.9.9/defaults/main.yml"), []byte("id: ABCDEF\n"), 0644)
+	os.MkdirAll(path.Join(customChecksDir, "translations"), 0755)
+	ioutil.WriteFile(path.Join(customChecksDir, "translations/de.yml"), []byte("156F64:\n  description: Beschreibung\n"), 0644)
+	os.MkdirAll(path.Join(suite.ansibleDir, "ansible/roles/checks/9.9.9/defaults"), 0755)
+	ioutil.WriteFile(path.Join(suite.ansibleDir, "ansible/roles/checks/9.9.9/defaults/main.yml"), []byte("id: 123456\n"), 0644)
+	os.MkdirAll(path.Join(suite.ansibleDir, "ansible/roles/checks/9.9.9/tasks"), 0755)
+	ioutil.WriteFile(path.Join(suite.ansibleDir, "ansible/roles/checks/9.9.9/tasks/main.yml"), []byte("---\n"), 0644)
+	os.Create(path.Join(suite.ansibleDir, "ansible/meta.yml"))
+
+	suite.runnerService.config.CustomChecksDir = customChecksDir
+
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+	mockCommand.On(
+		"Execute", "ansible-playbook", path.Join(suite.ansibleDir, "ansible/meta.yml")).Return(
+		exec.Command("cp", "../test/fixtures/catalog.json", path.Join(suite.ansibleDir, "ansible/catalog.json.tmp")),
+	)
+
+	err := suite.runnerService.RebuildCatalog()
+	suite.NoError(err)
+
+	content, _ := ioutil.ReadFile(path.Join(suite.ansibleDir, "ansible/roles/checks/9.9.9/defaults/main.yml"))
+	suite.Equal("id: ABCDEF\n", string(content))
+	// The custom check replaces the whole check folder
+	suite.NoDirExists(path.Join(suite.ansibleDir, "ansible/roles/checks/9.9.9/tasks"))
+
+	// The translations are merged in the catalog instead of being copied as a check
+	suite.NoDirExists(path.Join(suite.ansibleDir, "ansible/roles/checks/translations"))
+	for _, check := range *suite.runnerService.GetCatalog() {
+		suite.Equal(map[string]*CheckTranslation{"de": {Description: "Beschreibung"}}, check.Translations)
+	}
+}
+
+func (suite *RunnerTestCase) Test_RebuildCatalog_WaitsForExecutions() {
+	customChecksDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(customChecksDir)
+	defer os.RemoveAll(suite.ansibleDir)
+
+	os.MkdirAll(path.Join(customChecksDir, "9.9.9/defaults"), 0755)
+	ioutil.WriteFile(path.Join(customChecksDir, "9.9.9/defaults/main.yml"), []byte("id: ABCDEF\n"), 0644)
+	os.MkdirAll(path.Join(suite.ansibleDir, "ansible/roles/checks"), 0755)
+	os.Create(path.Join(suite.ansibleDir, "ansible/meta.yml"))
+
+	suite.runnerService.config.CustomChecksDir = customChecksDir
+
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+	mockCommand.On(
+		"Execute", "ansible-playbook", path.Join(suite.ansibleDir, "ansible/meta.yml")).Return(
+		exec.Command("cp", "../test/fixtures/catalog.json", path.Join(suite.ansibleDir, "ansible/catalog.json.tmp")),
+	)
+
+	// A running execution holds the ansible files
+	suite.runnerService.filesMu.RLock()
+	rebuilt := make(chan error)
+	go func() {
+		rebuilt <- suite.runnerService.RebuildCatalog()
+	}()
+
+	select {
+	case <-rebuilt:
+		suite.Fail("The catalog was rebuilt while an execution was running")
+	case <-time.After(100 * time.Millisecond):
+	}
+	suite.NoDirExists(path.Join(suite.ansibleDir, "ansible/roles/checks/9.9.9"))
+
+	suite.runnerService.filesMu.RUnlock()
+	suite.NoError(<-rebuilt)
+	suite.FileExists(path.Join(suite.ansibleDir, "ansible/roles/checks/9.9.9/defaults/main.yml"))
+}
+
+func (suite *RunnerTestCase) Test_RebuildCatalog_CustomChecksNotFound() {
+	suite.runnerService.config.CustomChecksDir = path.Join(suite.ansibleDir, "other")
+
+	err := suite.runnerService.RebuildCatalog()
+	suite.Error(err)
+	suite.Equal(false, suite.runnerService.IsCatalogReady())
+
+	status := suite.runnerService.GetCatalogStatus()
+	suite.Equal("failed", status.Status)
+	suite.False(status.Ready)
+	suite.Equal(0, status.Checks)
+	suite.Equal(err.Error(), status.Error)
+}
+
+func (suite *RunnerTestCase) Test_RebuildCatalog_InProgress() {
+	suite.runnerService.rebuilding = 1
+
+	err := suite.runnerService.RebuildCatalog()
+	suite.Equal(ErrCatalogRebuilding, err)
+	suite.Equal(false, suite.runnerService.IsCatalogReady())
 }
 
 func (suite *RunnerTestCase) Test_ScheduleExecution() {
@@ -91,48 +281,772 @@ func (suite *RunnerTestCase) Test_ScheduleExecution() {
 	suite.Equal(execution, <-suite.runnerService.GetChannel())
 }
 
-func (suite *RunnerTestCase) Test_ScheduleExecution_Full() {
-	ch := suite.runnerService.GetChannel()
-	for range [executionChannelSize]int64{} {
-		ch <- &ExecutionEvent{ExecutionID: uuid.New()}
+func (suite *RunnerTestCase) Test_ScheduleExecution_Full() {
+	ch := suite.runnerService.GetChannel()
+	for range [DefaultExecutionQueueSize]int64{} {
+		ch <- &ExecutionEvent{ExecutionID: uuid.New()}
+	}
+
+	execution := &ExecutionEvent{ExecutionID: uuid.New()}
+	err := suite.runnerService.ScheduleExecution(execution)
+	suite.Equal(ErrExecutionQueueFull, err)
+}
+
+func (suite *RunnerTestCase) Test_ScheduleExecution_Duplicate() {
+	execution := &ExecutionEvent{ExecutionID: uuid.New()}
+	suite.NoError(suite.runnerService.ScheduleExecution(execution))
+
+	err := suite.runnerService.ScheduleExecution(&ExecutionEvent{ExecutionID: execution.ExecutionID})
+	suite.ErrorIs(err, ErrDuplicateExecution)
+	status, _ := DuplicateExecutionStatus(err)
+	suite.Equal(ExecutionQueued, status)
+	suite.Equal(execution, <-suite.runnerService.GetChannel())
+	suite.Equal(0, len(suite.runnerService.GetChannel()))
+}
+
+func (suite *RunnerTestCase) Test_ScheduleExecution_FullNotTracked() {
+	runnerService, _ := NewRunnerService(&Config{AnsibleFolder: suite.ansibleDir, ExecutionQueueSize: 1})
+	execution := &ExecutionEvent{ExecutionID: uuid.New()}
+
+	suite.NoError(runnerService.ScheduleExecution(&ExecutionEvent{ExecutionID: uuid.New()}))
+	suite.Equal(ErrExecutionQueueFull, runnerService.ScheduleExecution(execution))
+
+	<-runnerService.GetChannel()
+	suite.NoError(runnerService.ScheduleExecution(execution))
+}
+
+func (suite *RunnerTestCase) Test_ScheduleExecution_QueueSize() {
+	runnerService, _ := NewRunnerService(&Config{AnsibleFolder: suite.ansibleDir, ExecutionQueueSize: 1})
+
+	suite.NoError(runnerService.ScheduleExecution(&ExecutionEvent{ExecutionID: uuid.New()}))
+	suite.Equal(ErrExecutionQueueFull, runnerService.ScheduleExecution(&ExecutionEvent{ExecutionID: uuid.New()}))
+}
+
+func (suite *RunnerTestCase) Test_ScheduleExecution_Draining() {
+	suite.runnerService.Drain()
+
+	err := suite.runnerService.ScheduleExecution(&ExecutionEvent{ExecutionID: uuid.New()})
+	suite.Equal(ErrDraining, err)
+	suite.Equal(true, suite.runnerService.IsDraining())
+}
+
+func (suite *RunnerTestCase) Test_Execute_Cancelled() {
+	os.MkdirAll(path.Join(suite.ansibleDir, "ansible"), 0755)
+	os.Create(path.Join(suite.ansibleDir, "ansible/check.yml"))
+	defer os.RemoveAll(suite.ansibleDir)
+
+	dummyID := uuid.New()
+	clusterDummyID := uuid.New()
+	suite.callbacksClient.On("Callback", dummyID, "execution_started", mock.Anything).Return(nil)
+
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+	mockCommand.On("Execute", "ansible-playbook", mock.Anything, mock.Anything, "--check").Return(
+		exec.Command("sh", "-c", "sleep 10 & wait"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+
+	execution := &ExecutionEvent{ExecutionID: dummyID, ClusterID: clusterDummyID, Checks: []string{"A1244C"}}
+	start := time.Now()
+	err := suite.runnerService.Execute(ctx, execution)
+
+	expectedCallback := &callbackRequest{
+		executionID: dummyID,
+		event:       "execution_failed",
+		payload:     map[string]string{"cluster_id": clusterDummyID.String(), "reason": "signal: terminated"},
+	}
+
+	suite.EqualError(err, "signal: terminated")
+	suite.Less(time.Since(start).Seconds(), 5.0)
+	suite.Equal(expectedCallback, <-suite.runnerService.callbacksDispatcher.queue)
+	suite.NoDirExists(path.Join(suite.ansibleDir, "executions", dummyID.String()))
+}
+
+func (suite *RunnerTestCase) Test_NewRunnerService_Upstreams() {
+	runnerService, err := NewRunnerService(&Config{
+		AnsibleFolder: suite.ansibleDir,
+		CallbacksUrl:  "http://192.168.1.1:8000/api/runner/callbacks",
+		Upstreams: []*Upstream{
+			{Name: "customer1", CallbacksUrl: "https://trento.customer1.example.com/api/runner/callbacks"},
+		},
+	})
+	suite.NoError(err)
+
+	suite.Len(runnerService.callbacksDispatcher.callbacksClients, 0)
+	suite.Len(runnerService.callbacksDispatcher.upstreamsClients, 2)
+	suite.Equal(runnerService.upstreams["customer1"].callbacksClient, runnerService.callbacksDispatcher.upstreamsClients["customer1"])
+	suite.Equal(runnerService.callbacksClient, runnerService.callbacksDispatcher.upstreamsClients[""])
+}
+
+func (suite *RunnerTestCase) Test_Reload() {
+	config := &Config{
+		AnsibleFolder:      suite.ansibleDir,
+		CallbacksUrl:       "http://192.168.1.1:8000/api/runner/callbacks",
+		ReportChangesOnly:  true,
+		FullResyncInterval: time.Hour,
+	}
+	runnerService, err := NewRunnerService(config)
+	suite.NoError(err)
+
+	reloaded := *config
+	reloaded.CallbacksUrl = "http://192.168.1.2:8000/api/runner/callbacks"
+	reloaded.FullResyncInterval = time.Minute
+	suite.NoError(runnerService.Reload(&reloaded))
+
+	suite.Equal("http://192.168.1.2:8000/api/runner/callbacks", runnerService.currentCallbacksUrl())
+	suite.Equal("http://192.168.1.2:8000/api/runner/callbacks", runnerService.callbacksClient.(*callbacksClient).url())
+	suite.Equal("http://192.168.1.2:8000/api/runner/callbacks", runnerService.serverConnectivity.serverUrl())
+	suite.Equal(time.Minute, runnerService.changesFilter.resyncInterval)
+	// The configuration is not changed
+	suite.Equal("http://192.168.1.1:8000/api/runner/callbacks", config.CallbacksUrl)
+
+	// The callbacks clients are created when the runner starts
+	reloaded.CallbacksUrl = ""
+	reloaded.ResultsDir = suite.ansibleDir
+	suite.EqualError(runnerService.Reload(&reloaded), "the callbacks url cannot be set or unset without a restart")
+}
+
+func (suite *RunnerTestCase) Test_AppReload_Rejected() {
+	mockRunnerService := new(MockRunnerService)
+	mockRunnerService.On("Reload", mock.Anything).Return(fmt.Errorf("the callbacks url cannot be set or unset without a restart"))
+
+	deps := setupTestDependencies()
+	deps.runnerService = mockRunnerService
+	deps.executionWorkerPool = NewExecutionWorkerPool(mockRunnerService, 3, DefaultShutdownGracePeriod)
+	app, err := NewAppWithDeps(&Config{}, deps)
+	suite.NoError(err)
+
+	// None of the settings of a rejected configuration is applied
+	suite.Error(app.Reload(&Config{MaxParallelClusters: 8}))
+	suite.Equal(int64(3), atomic.LoadInt64(&deps.executionWorkerPool.workersNumber))
+}
+
+func (suite *RunnerTestCase) Test_ScheduleExecution_UnknownUpstream() {
+	err := suite.runnerService.ScheduleExecution(&ExecutionEvent{ExecutionID: uuid.New(), Upstream: "customer1"})
+
+	suite.True(errors.Is(err, ErrUpstreamNotFound))
+	suite.EqualError(err, "The upstream is not configured: customer1")
+	suite.Len(suite.runnerService.workerPoolChannel, 0)
+}
+
+func (suite *RunnerTestCase) Test_Execute_Upstream() {
+	os.MkdirAll(path.Join(suite.ansibleDir, "ansible"), 0755)
+	os.Create(path.Join(suite.ansibleDir, "ansible/check.yml"))
+	defer os.RemoveAll(suite.ansibleDir)
+
+	dummyID := uuid.New()
+	clusterDummyID := uuid.New()
+	customerClient := new(mocks.CallbacksClient)
+	customerClient.On(
+		"Callback", dummyID, "execution_started", map[string]string{"cluster_id": clusterDummyID.String()}).Return(nil)
+	suite.runnerService.upstreams["customer1"] = &upstreamClient{callbacksClient: customerClient}
+
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+	mockCommand.On("Execute", "ansible-playbook", mock.Anything, mock.Anything, "--check").Return(exec.Command("ls"))
+
+	execution := &ExecutionEvent{
+		ExecutionID: dummyID, ClusterID: clusterDummyID, Checks: []string{"A1244C"}, Upstream: "customer1"}
+	err := suite.runnerService.Execute(context.Background(), execution)
+
+	expectedCallback := &callbackRequest{
+		upstream:    "customer1",
+		executionID: dummyID,
+		event:       "execution_finished",
+		payload:     map[string]string{"cluster_id": clusterDummyID.String()},
+	}
+
+	suite.NoError(err)
+	suite.Equal(expectedCallback, <-suite.runnerService.callbacksDispatcher.queue)
+	customerClient.AssertExpectations(suite.T())
+	suite.callbacksClient.AssertNotCalled(suite.T(), "Callback", dummyID, "execution_started", mock.Anything)
+}
+
+func (suite *RunnerTestCase) Test_Execute() {
+	os.MkdirAll(path.Join(suite.ansibleDir, "ansible"), 0755)
+	os.Create(path.Join(suite.ansibleDir, "ansible/check.yml"))
+	defer os.RemoveAll(suite.ansibleDir)
+
+	dummyID := uuid.New()
+	clusterDummyID := uuid.New()
+	executionStartedPayload := map[string]string{"cluster_id": clusterDummyID.String()}
+	suite.callbacksClient.On(
+		"Callback", dummyID, "execution_started", executionStartedPayload).Return(nil)
+
+	cmd := exec.Command("ls") // Dummy command to execute something
+
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+
+	mockCommand.On(
+		"Execute",
+		"ansible-playbook",
+		path.Join(suite.ansibleDir, "ansible/check.yml"),
+		fmt.Sprintf("--inventory=%s/executions/%s/ansible_hosts", suite.ansibleDir, dummyID.String()),
+		"--check",
+	).Return(cmd)
+
+	execution := &ExecutionEvent{ExecutionID: dummyID, ClusterID: clusterDummyID, Checks: []string{"A1244C"}}
+	err := suite.runnerService.Execute(context.Background(), execution)
+
+	expectedCallback := &callbackRequest{
+		executionID: dummyID,
+		event:       "execution_finished",
+		payload:     map[string]string{"cluster_id": clusterDummyID.String()},
+	}
+
+	suite.NoError(err)
+	suite.Equal(expectedCallback, <-suite.runnerService.callbacksDispatcher.queue)
+}
+
+func (suite *RunnerTestCase) Test_Execute_Traced() {
+	os.MkdirAll(path.Join(suite.ansibleDir, "ansible"), 0755)
+	os.Create(path.Join(suite.ansibleDir, "ansible/check.yml"))
+	defer os.RemoveAll(suite.ansibleDir)
+
+	spanRecorder := tracetest.NewSpanRecorder()
+	defaultTracer := tracer
+	tracer = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)).Tracer(tracerName)
+	defer func() { tracer = defaultTracer }()
+
+	dummyID := uuid.New()
+	clusterDummyID := uuid.New()
+	suite.callbacksClient.On("Callback", dummyID, "execution_started", mock.Anything).Return(nil)
+
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+	mockCommand.On("Execute", "ansible-playbook", mock.Anything, mock.Anything, "--check").Return(exec.Command("ls"))
+
+	header := http.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	execution := &ExecutionEvent{
+		ExecutionID:  dummyID,
+		ClusterID:    clusterDummyID,
+		Checks:       []string{"A1244C"},
+		traceContext: executionTraceContext(propagation.HeaderCarrier(header)),
+	}
+	err := suite.runnerService.Execute(context.Background(), execution)
+	suite.NoError(err)
+
+	spans := spanRecorder.Ended()
+	names := []string{}
+	for _, span := range spans {
+		names = append(names, span.Name())
+	}
+	suite.Equal([]string{"CreateInventory", "RunPlaybook", "Execute"}, names)
+
+	executionSpan := spans[2]
+	suite.Equal("4bf92f3577b34da6a3ce929d0e0e4736", executionSpan.SpanContext().TraceID().String())
+	suite.Equal("00f067aa0ba902b7", executionSpan.Parent().SpanID().String())
+	suite.Contains(executionSpan.Attributes(), executionIDAttribute.String(dummyID.String()))
+	suite.Contains(executionSpan.Attributes(), clusterIDAttribute.String(clusterDummyID.String()))
+	suite.Equal(executionSpan.SpanContext().SpanID(), spans[1].Parent().SpanID())
+}
+
+func (suite *RunnerTestCase) Test_Execute_NoChecksSelected() {
+	dummyID := uuid.New()
+	clusterDummyID := uuid.New()
+	executionStartedPayload := map[string]string{"cluster_id": clusterDummyID.String()}
+	suite.callbacksClient.On(
+		"Callback", dummyID, "execution_started", executionStartedPayload).Return(nil)
+
+	// The playbook must not be run
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+
+	execution := &ExecutionEvent{ExecutionID: dummyID, ClusterID: clusterDummyID, Checks: []string{}}
+	err := suite.runnerService.Execute(context.Background(), execution)
+
+	expectedCallback := &callbackRequest{
+		executionID: dummyID,
+		event:       "execution_finished",
+		payload:     map[string]string{"cluster_id": clusterDummyID.String()},
+	}
+
+	suite.NoError(err)
+	suite.Equal(expectedCallback, <-suite.runnerService.callbacksDispatcher.queue)
+	mockCommand.AssertNotCalled(suite.T(), "Execute", mock.Anything)
+}
+
+func (suite *RunnerTestCase) Test_Execute_Results() {
+	os.MkdirAll(path.Join(suite.ansibleDir, "ansible"), 0755)
+	os.Create(path.Join(suite.ansibleDir, "ansible/check.yml"))
+	defer os.RemoveAll(suite.ansibleDir)
+
+	dummyID := uuid.New()
+	clusterDummyID := uuid.New()
+	executionStartedPayload := map[string]string{"cluster_id": clusterDummyID.String()}
+	suite.callbacksClient.On(
+		"Callback", dummyID, "execution_started", executionStartedPayload).Return(nil)
+
+	cmd := exec.Command("cat", "../test/fixtures/ansible_json_output.txt")
+
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+
+	mockCommand.On(
+		"Execute",
+		"ansible-playbook",
+		path.Join(suite.ansibleDir, "ansible/check.yml"),
+		fmt.Sprintf("--inventory=%s/executions/%s/ansible_hosts", suite.ansibleDir, dummyID.String()),
+		"--check",
+	).Return(cmd)
+
+	execution := &ExecutionEvent{ExecutionID: dummyID, ClusterID: clusterDummyID, Checks: []string{"A1244C"}}
+	err := suite.runnerService.Execute(context.Background(), execution)
+
+	suite.NoError(err)
+
+	expectedEvents := []string{
+		"host_completed", "host_completed", "check_result", "check_result", "execution_finished"}
+	requests := []*callbackRequest{}
+	for range expectedEvents {
+		requests = append(requests, <-suite.runnerService.callbacksDispatcher.queue)
+	}
+
+	for index, event := range expectedEvents {
+		suite.Equal(event, requests[index].event)
+	}
+
+	expectedPayload := map[string]interface{}{
+		"cluster_id": clusterDummyID.String(),
+		"host_id":    "host1",
+		"check_id":   "156F64",
+		"result":     "passing",
+		"msg":        "",
+	}
+	suite.Equal(expectedPayload, requests[3].payload)
+}
+
+func (suite *RunnerTestCase) Test_Execute_CachedResults() {
+	hostID := uuid.New()
+	suite.runnerService.resultsCache = NewResultsCache(time.Hour)
+	suite.runnerService.resultsCache.Store("", &ExecutionResults{
+		Hosts: []*HostResults{
+			&HostResults{
+				HostID:    hostID.String(),
+				Reachable: true,
+				Results:   []*CheckResult{&CheckResult{CheckID: "156F64", Result: "passing"}},
+			},
+		},
+	})
+
+	dummyID := uuid.New()
+	clusterDummyID := uuid.New()
+	suite.callbacksClient.On("Callback", dummyID, "execution_started", mock.Anything).Return(nil)
+
+	// The playbook must not be run
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+
+	execution := &ExecutionEvent{
+		ExecutionID: dummyID,
+		ClusterID:   clusterDummyID,
+		Checks:      []string{"156F64"},
+		Hosts:       []*Host{&Host{HostID: hostID, Address: "192.168.10.1", User: "root"}},
+	}
+	err := suite.runnerService.Execute(context.Background(), execution)
+	suite.NoError(err)
+
+	expectedEvents := []string{"host_completed", "check_result", "execution_finished"}
+	requests := []*callbackRequest{}
+	for range expectedEvents {
+		requests = append(requests, <-suite.runnerService.callbacksDispatcher.queue)
+	}
+	for index, event := range expectedEvents {
+		suite.Equal(event, requests[index].event)
+	}
+
+	expectedPayload := map[string]interface{}{
+		"cluster_id": clusterDummyID.String(),
+		"host_id":    hostID.String(),
+		"check_id":   "156F64",
+		"result":     "passing",
+		"msg":        "",
+	}
+	suite.Equal(expectedPayload, requests[1].payload)
+	mockCommand.AssertNotCalled(suite.T(), "Execute", mock.Anything)
+}
+
+func (suite *RunnerTestCase) Test_Execute_MappedSeverities() {
+	hostID := uuid.New()
+	suite.runnerService.config.CheckSeverities = map[string]string{"156F64": "warning"}
+	suite.runnerService.resultsCache = NewResultsCache(time.Hour)
+	suite.runnerService.resultsCache.Store("", &ExecutionResults{
+		Hosts: []*HostResults{
+			&HostResults{
+				HostID:    hostID.String(),
+				Reachable: true,
+				Results:   []*CheckResult{&CheckResult{CheckID: "156F64", Result: "critical"}},
+			},
+		},
+	})
+
+	dummyID := uuid.New()
+	clusterDummyID := uuid.New()
+	suite.callbacksClient.On("Callback", dummyID, "execution_started", mock.Anything).Return(nil)
+
+	execution := &ExecutionEvent{
+		ExecutionID: dummyID,
+		ClusterID:   clusterDummyID,
+		Checks:      []string{"156F64"},
+		Hosts:       []*Host{&Host{HostID: hostID, Address: "192.168.10.1", User: "root"}},
+	}
+	err := suite.runnerService.Execute(context.Background(), execution)
+	suite.NoError(err)
+
+	<-suite.runnerService.callbacksDispatcher.queue
+	request := <-suite.runnerService.callbacksDispatcher.queue
+	suite.Equal("check_result", request.event)
+	suite.Equal("warning", request.payload.(map[string]interface{})["result"])
+
+	// The raw results are cached, so the configuration changes apply to them
+	cached, _ := suite.runnerService.resultsCache.Get(hostID.String(), "", []string{"156F64"})
+	suite.Equal("critical", cached[0].Result)
+}
+
+func (suite *RunnerTestCase) Test_Execute_NotEntitled() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"premium":false}`))
+	}))
+	defer server.Close()
+
+	hostID := uuid.New()
+	suite.runnerService.subscriptionClient = NewSubscriptionClient(server.URL, nil)
+	suite.runnerService.setCatalog(&Catalog{&CatalogCheck{ID: "156F64", Premium: true}}, true)
+
+	dummyID := uuid.New()
+	clusterDummyID := uuid.New()
+	suite.callbacksClient.On("Callback", dummyID, "execution_started", mock.Anything).Return(nil)
+
+	// The playbook must not be run
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+
+	execution := &ExecutionEvent{
+		ExecutionID: dummyID,
+		ClusterID:   clusterDummyID,
+		Checks:      []string{"156F64"},
+		Hosts:       []*Host{&Host{HostID: hostID, Address: "192.168.10.1", User: "root"}},
+	}
+	err := suite.runnerService.Execute(context.Background(), execution)
+	suite.NoError(err)
+
+	expectedEvents := []string{"host_completed", "check_result", "execution_finished"}
+	requests := []*callbackRequest{}
+	for range expectedEvents {
+		requests = append(requests, <-suite.runnerService.callbacksDispatcher.queue)
+	}
+	for index, event := range expectedEvents {
+		suite.Equal(event, requests[index].event)
+	}
+
+	expectedPayload := map[string]interface{}{
+		"cluster_id": clusterDummyID.String(),
+		"host_id":    hostID.String(),
+		"check_id":   "156F64",
+		"result":     "skipped",
+		"msg":        "not entitled, the check requires a premium subscription",
+	}
+	suite.Equal(expectedPayload, requests[1].payload)
+	mockCommand.AssertNotCalled(suite.T(), "Execute", mock.Anything)
+}
+
+func (suite *RunnerTestCase) Test_Execute_PreflightUnreachable() {
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.NoError(err)
+	closedAddress := closed.Addr().String()
+	closed.Close()
+
+	suite.runnerService.config.PreflightTimeout = time.Second
+
+	dummyID := uuid.New()
+	clusterDummyID := uuid.New()
+	hostID := uuid.New()
+	suite.callbacksClient.On("Callback", dummyID, "execution_started", mock.Anything).Return(nil)
+
+	// The playbook must not be run
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+
+	execution := &ExecutionEvent{
+		ExecutionID: dummyID,
+		ClusterID:   clusterDummyID,
+		Checks:      []string{"156F64"},
+		Hosts:       []*Host{&Host{HostID: hostID, Address: closedAddress, User: "root"}},
+	}
+	err = suite.runnerService.Execute(context.Background(), execution)
+	suite.NoError(err)
+
+	expectedEvents := []string{"host_completed", "execution_finished"}
+	requests := []*callbackRequest{}
+	for range expectedEvents {
+		requests = append(requests, <-suite.runnerService.callbacksDispatcher.queue)
+	}
+	for index, event := range expectedEvents {
+		suite.Equal(event, requests[index].event)
+	}
+
+	suite.Equal(hostID.String(), requests[0].payload.(map[string]interface{})["host_id"])
+	suite.Equal(false, requests[0].payload.(map[string]interface{})["reachable"])
+	mockCommand.AssertNotCalled(suite.T(), "Execute", mock.Anything)
+}
+
+func (suite *RunnerTestCase) Test_SelectUncachedChecks() {
+	host1ID := uuid.New()
+	host2ID := uuid.New()
+	suite.runnerService.resultsCache = NewResultsCache(time.Hour)
+	suite.runnerService.resultsCache.Store("v1", &ExecutionResults{
+		Hosts: []*HostResults{
+			&HostResults{
+				HostID:    host1ID.String(),
+				Reachable: true,
+				Results: []*CheckResult{
+					&CheckResult{CheckID: "156F64", Result: "passing"},
+					&CheckResult{CheckID: "A1244C", Result: "warning"},
+				},
+			},
+			&HostResults{
+				HostID:    host2ID.String(),
+				Reachable: true,
+				Results:   []*CheckResult{&CheckResult{CheckID: "156F64", Result: "critical"}},
+			},
+		},
+	})
+
+	execution := &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Checks:      []string{"156F64", "A1244C"},
+		Hosts: []*Host{
+			&Host{HostID: host1ID, Address: "192.168.10.1", User: "root"},
+			&Host{HostID: host2ID, Address: "192.168.10.2", User: "root"},
+		},
+	}
+
+	selected, cached := suite.runnerService.selectUncachedChecks(execution, "v1")
+
+	suite.Len(selected.Hosts, 1)
+	suite.Equal(host2ID, selected.Hosts[0].HostID)
+	suite.Equal([]string{"A1244C"}, selected.hostChecks(selected.Hosts[0]))
+	suite.Len(execution.Hosts, 2)
+	suite.Equal([]string{"156F64", "A1244C"}, execution.hostChecks(execution.Hosts[1]))
+
+	suite.Len(cached.Hosts, 2)
+	suite.Len(cached.Hosts[0].Results, 2)
+	suite.Equal("critical", cached.Hosts[1].Results[0].Result)
+
+	content, _ := NewClusterInventoryContent(selected)
+	suite.Equal("'[\"A1244C\"]'", content.Groups[0].Nodes[0].Variables["cluster_selected_checks"])
+}
+
+func (suite *RunnerTestCase) Test_Execute_ExecutionRecord() {
+	os.MkdirAll(path.Join(suite.ansibleDir, "ansible"), 0755)
+	os.Create(path.Join(suite.ansibleDir, "ansible/check.yml"))
+	defer os.RemoveAll(suite.ansibleDir)
+
+	store, _ := NewExecutionsStore(path.Join(suite.ansibleDir, "executions.db"))
+	defer store.Close()
+	suite.runnerService.executionsStore = store
+
+	dummyID := uuid.New()
+	clusterDummyID := uuid.New()
+	suite.callbacksClient.On("Callback", dummyID, "execution_started", mock.Anything).Return(nil)
+
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+	mockCommand.On("Execute", "ansible-playbook", mock.Anything, mock.Anything, "--check").Return(
+		exec.Command("cat", "../test/fixtures/ansible_json_output.txt"))
+
+	execution := &ExecutionEvent{ExecutionID: dummyID, ClusterID: clusterDummyID, Checks: []string{"A1244C"}}
+	err := suite.runnerService.Execute(context.Background(), execution)
+	suite.NoError(err)
+
+	record, _ := store.Get(dummyID)
+	suite.Equal(clusterDummyID, record.ClusterID)
+	suite.Equal(ExecutionCompleted, record.Status)
+	suite.Equal(0, record.ReturnCode)
+	suite.NotNil(record.FinishedAt)
+	suite.Equal(map[string]int{"passing": 1, "skipped": 1, "unreachable": 1}, record.Summary)
+
+	failedID := uuid.New()
+	suite.callbacksClient.On("Callback", failedID, "execution_started", mock.Anything).Return(nil)
+	mockCommand.ExpectedCalls = nil
+	mockCommand.On("Execute", "ansible-playbook", mock.Anything, mock.Anything, "--check").Return(
+		exec.Command("sh", "-c", "exit 2"))
+
+	err = suite.runnerService.Execute(context.Background(), &ExecutionEvent{ExecutionID: failedID, ClusterID: clusterDummyID, Checks: []string{"A1244C"}})
+	suite.EqualError(err, "exit status 2")
+
+	record, _ = store.Get(failedID)
+	suite.Equal(ExecutionFailed, record.Status)
+	suite.Equal(2, record.ReturnCode)
+	suite.Equal("exit status 2", record.Error)
+}
+
+func (suite *RunnerTestCase) Test_Execute_PlaybookError() {
+	os.MkdirAll(path.Join(suite.ansibleDir, "ansible"), 0755)
+	os.Create(path.Join(suite.ansibleDir, "ansible/check.yml"))
+	defer os.RemoveAll(suite.ansibleDir)
+
+	dummyID := uuid.New()
+	clusterDummyID := uuid.New()
+	executionStartedPayload := map[string]string{"cluster_id": clusterDummyID.String()}
+	suite.callbacksClient.On(
+		"Callback", dummyID, "execution_started", executionStartedPayload).Return(nil)
+
+	cmd := exec.Command("false")
+
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+
+	mockCommand.On(
+		"Execute",
+		"ansible-playbook",
+		path.Join(suite.ansibleDir, "ansible/check.yml"),
+		fmt.Sprintf("--inventory=%s/executions/%s/ansible_hosts", suite.ansibleDir, dummyID.String()),
+		"--check",
+	).Return(cmd)
+
+	execution := &ExecutionEvent{ExecutionID: dummyID, ClusterID: clusterDummyID, Checks: []string{"A1244C"}}
+	err := suite.runnerService.Execute(context.Background(), execution)
+
+	expectedCallback := &callbackRequest{
+		executionID: dummyID,
+		event:       "execution_failed",
+		payload: map[string]string{
+			"cluster_id": clusterDummyID.String(),
+			"reason":     "exit status 1",
+		},
+	}
+
+	suite.EqualError(err, "exit status 1")
+	suite.Equal(expectedCallback, <-suite.runnerService.callbacksDispatcher.queue)
+}
+
+func (suite *RunnerTestCase) Test_Execute_ModifiedAnsibleFiles() {
+	os.MkdirAll(path.Join(suite.ansibleDir, "ansible"), 0755)
+	ioutil.WriteFile(path.Join(suite.ansibleDir, "ansible/check.yml"), []byte("- hosts: all\n"), 0644)
+	defer os.RemoveAll(suite.ansibleDir)
+
+	suite.NoError(suite.runnerService.updateManifest())
+	ioutil.WriteFile(path.Join(suite.ansibleDir, "ansible/check.yml"), []byte("- hosts: localhost\n"), 0644)
+
+	dummyID := uuid.New()
+	clusterDummyID := uuid.New()
+	suite.callbacksClient.On("Callback", dummyID, "execution_started", mock.Anything).Return(nil)
+
+	// The playbook is not run
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+
+	execution := &ExecutionEvent{ExecutionID: dummyID, ClusterID: clusterDummyID, Checks: []string{"A1244C"}}
+	err := suite.runnerService.Execute(context.Background(), execution)
+
+	suite.True(errors.Is(err, ErrAnsibleFilesModified))
+	suite.Equal("execution_failed", (<-suite.runnerService.callbacksDispatcher.queue).event)
+	mockCommand.AssertNotCalled(suite.T(), "Execute", mock.Anything)
+}
+
+func (suite *RunnerTestCase) Test_Execute_Timeout() {
+	os.MkdirAll(path.Join(suite.ansibleDir, "ansible"), 0755)
+	os.Create(path.Join(suite.ansibleDir, "ansible/check.yml"))
+	defer os.RemoveAll(suite.ansibleDir)
+
+	store, _ := NewExecutionsStore(path.Join(suite.ansibleDir, "executions.db"))
+	defer store.Close()
+	suite.runnerService.executionsStore = store
+	suite.runnerService.config.ExecutionTimeout = time.Millisecond * 50
+
+	dummyID := uuid.New()
+	clusterDummyID := uuid.New()
+	suite.callbacksClient.On("Callback", dummyID, "execution_started", mock.Anything).Return(nil)
+
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+	mockCommand.On("Execute", "ansible-playbook", mock.Anything, mock.Anything, "--check").Return(
+		exec.Command("sleep", "5"))
+
+	execution := &ExecutionEvent{ExecutionID: dummyID, ClusterID: clusterDummyID, Checks: []string{"A1244C"}}
+	err := suite.runnerService.Execute(context.Background(), execution)
+
+	suite.True(errors.Is(err, ErrExecutionTimeout))
+	suite.EqualError(err, "The execution timed out after 50ms: signal: terminated")
+
+	expectedCallback := &callbackRequest{
+		executionID: dummyID,
+		event:       "execution_failed",
+		payload: map[string]string{
+			"cluster_id": clusterDummyID.String(),
+			"reason":     "The execution timed out after 50ms: signal: terminated",
+		},
 	}
+	suite.Equal(expectedCallback, <-suite.runnerService.callbacksDispatcher.queue)
 
-	execution := &ExecutionEvent{ExecutionID: uuid.New()}
-	err := suite.runnerService.ScheduleExecution(execution)
-	suite.EqualError(err, "Cannot process more executions")
+	record, _ := store.Get(dummyID)
+	suite.Equal(ExecutionTimedOut, record.Status)
+	suite.Equal(-1, record.ReturnCode)
 }
 
-func (suite *RunnerTestCase) Test_Execute() {
+func (suite *RunnerTestCase) Test_Execute_Preempted() {
 	os.MkdirAll(path.Join(suite.ansibleDir, "ansible"), 0755)
 	os.Create(path.Join(suite.ansibleDir, "ansible/check.yml"))
 	defer os.RemoveAll(suite.ansibleDir)
 
+	store, _ := NewExecutionsStore(path.Join(suite.ansibleDir, "executions.db"))
+	defer store.Close()
+	suite.runnerService.executionsStore = store
+
 	dummyID := uuid.New()
 	clusterDummyID := uuid.New()
-	executionStartedPayload := map[string]string{"cluster_id": clusterDummyID.String()}
-	suite.callbacksClient.On(
-		"Callback", dummyID, "execution_started", executionStartedPayload).Return(nil)
-
-	cmd := exec.Command("ls") // Dummy command to execute something
+	suite.callbacksClient.On("Callback", dummyID, "execution_started", mock.Anything).Return(nil)
 
 	mockCommand := new(mocks.CustomCommand)
 	customExecCommand = mockCommand.Execute
+	mockCommand.On("Execute", "ansible-playbook", mock.Anything, mock.Anything, "--check").Return(
+		exec.Command("sleep", "5"))
 
-	mockCommand.On(
-		"Execute",
-		"ansible-playbook",
-		path.Join(suite.ansibleDir, "ansible/check.yml"),
-		fmt.Sprintf("--inventory=%s/ansible/inventories/%s/ansible_hosts", suite.ansibleDir, dummyID.String()),
-		"--check",
-	).Return(cmd)
+	// The worker pool cancels the execution once it is preempted
+	preemptor := &ExecutionEvent{ExecutionID: uuid.New(), ClusterID: clusterDummyID, Priority: PriorityHigh}
+	preemption := &executionPreemption{}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), preemptionKey{}, preemption))
+	defer cancel()
+	time.AfterFunc(50*time.Millisecond, func() {
+		preemption.preempt(preemptor)
+		cancel()
+	})
 
-	execution := &ExecutionEvent{ExecutionID: dummyID, ClusterID: clusterDummyID}
-	err := suite.runnerService.Execute(execution)
+	execution := &ExecutionEvent{ExecutionID: dummyID, ClusterID: clusterDummyID, Checks: []string{"A1244C"}, Priority: PriorityLow}
+	err := suite.runnerService.Execute(ctx, execution)
 
-	suite.NoError(err)
+	suite.True(errors.Is(err, ErrExecutionPreempted))
+	suite.EqualError(err, "The execution was preempted by a higher priority execution "+preemptor.ExecutionID.String())
+
+	expectedCallback := &callbackRequest{
+		executionID: dummyID,
+		event:       "execution_preempted",
+		payload: map[string]string{
+			"cluster_id":   clusterDummyID.String(),
+			"preempted_by": preemptor.ExecutionID.String(),
+		},
+	}
+	suite.Equal(expectedCallback, <-suite.runnerService.callbacksDispatcher.queue)
+
+	// The preempted execution is not finished, it is run again later
+	record, _ := store.Get(dummyID)
+	suite.Equal(ExecutionPreempted, record.Status)
+	suite.Nil(record.FinishedAt)
 }
 
 func (suite *RunnerTestCase) Test_Execute_CallbackError() {
+	os.MkdirAll(path.Join(suite.ansibleDir, "ansible"), 0755)
+	os.Create(path.Join(suite.ansibleDir, "ansible/check.yml"))
+	defer os.RemoveAll(suite.ansibleDir)
+
 	dummyID := uuid.New()
 	clusterDummyID := uuid.New()
 	expectedError := fmt.Errorf("error running callback")
@@ -140,10 +1054,18 @@ func (suite *RunnerTestCase) Test_Execute_CallbackError() {
 	suite.callbacksClient.On(
 		"Callback", dummyID, "execution_started", executionStartedPayload).Return(expectedError)
 
-	execution := &ExecutionEvent{ExecutionID: dummyID, ClusterID: clusterDummyID}
-	err := suite.runnerService.Execute(execution)
+	mockCommand := new(mocks.CustomCommand)
+	customExecCommand = mockCommand.Execute
+	mockCommand.On("Execute", "ansible-playbook", mock.Anything, mock.Anything, "--check").Return(
+		exec.Command("true"))
+
+	// The execution is run even if the server is not available
+	execution := &ExecutionEvent{ExecutionID: dummyID, ClusterID: clusterDummyID, Checks: []string{"A1244C"}}
+	err := suite.runnerService.Execute(context.Background(), execution)
 
-	suite.EqualError(err, expectedError.Error())
+	suite.NoError(err)
+	suite.callbacksClient.AssertNumberOfCalls(suite.T(), "Callback", callbacksRetries)
+	suite.Equal("execution_finished", (<-suite.runnerService.callbacksDispatcher.queue).event)
 }
 
 // TODO: This test could be improved to check the definitve ansible files structure
@@ -216,26 +1138,421 @@ func (suite *RunnerTestCase) Test_NewAnsibleCheckRunner() {
 
 	a, err := NewAnsibleCheckRunner(cfg, executionEvent)
 
-	inventoryFile := path.Join(tmpDir, fmt.Sprintf("ansible/inventories/%s/ansible_hosts", executionID.String()))
+	inventoryFile := path.Join(tmpDir, fmt.Sprintf("executions/%s/ansible_hosts", executionID.String()))
 
 	expectedChecksRunner := &AnsibleRunner{
 		Playbook:  path.Join(tmpDir, "ansible/check.yml"),
 		Inventory: inventoryFile,
 		Envs: map[string]string{
-			"ANSIBLE_CONFIG":       path.Join(tmpDir, "ansible/ansible.cfg"),
-			"TRENTO_CALLBACKS_URL": "http://192.168.1.1:8000/api/runner/callbacks",
-			"TRENTO_EXECUTION_ID":  executionID.String(),
+			"ANSIBLE_CONFIG":          path.Join(tmpDir, "ansible/ansible.cfg"),
+			"TRENTO_EXECUTION_ID":     executionID.String(),
+			"ANSIBLE_STDOUT_CALLBACK": "json",
+			"ANSIBLE_LOG_PATH":        path.Join(tmpDir, fmt.Sprintf("executions/%s/ansible.log", executionID.String())),
+			"ANSIBLE_LOCAL_TEMP":      path.Join(tmpDir, fmt.Sprintf("executions/%s/tmp", executionID.String())),
+			"ANSIBLE_RETRY_FILES_SAVE_PATH": path.Join(
+				tmpDir, fmt.Sprintf("executions/%s/retries", executionID.String())),
 		},
 		Check: true,
 	}
 
 	inventoryContent, err := ioutil.ReadFile(inventoryFile)
 	expectedFile := "\n" +
-		"[%s]\n" +
-		"%s ansible_host=192.168.10.1 ansible_user=user1 cluster_selected_checks='[\"check1\",\"check2\"]' provider=azure \n" +
-		"%s ansible_host=192.168.10.2 ansible_user=user2 cluster_selected_checks='[\"check1\",\"check2\"]' provider=azure \n"
+		"[%[1]s]\n" +
+		"%[2]s ansible_host=192.168.10.1 ansible_user=user1 cluster_selected_checks='[\"check1\",\"check2\"]' provider=azure \n" +
+		"%[3]s ansible_host=192.168.10.2 ansible_user=user2 cluster_selected_checks='[\"check1\",\"check2\"]' provider=azure \n" +
+		"[%[1]s:vars]\n" +
+		"cluster_id=%[1]s\n" +
+		"[azure]\n" +
+		"%[2]s\n" +
+		"%[3]s\n"
 
 	suite.NoError(err)
 	suite.Equal(expectedChecksRunner, a)
 	suite.Equal(fmt.Sprintf(expectedFile, clusterID.String(), host1ID.String(), host2ID.String()), string(inventoryContent))
 }
+
+func (suite *RunnerTestCase) Test_NewAnsibleCheckRunner_TaskTimeout() {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	os.MkdirAll(path.Join(tmpDir, "ansible"), 0755)
+	os.Create(path.Join(tmpDir, "ansible/check.yml"))
+	defer os.RemoveAll(tmpDir)
+
+	cfg := &Config{AnsibleFolder: tmpDir, TaskTimeout: 90 * time.Second}
+	executionEvent := &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Provider:    "azure",
+		Checks:      []string{"check1"},
+		Hosts:       []*Host{&Host{HostID: uuid.New(), Address: "192.168.10.1", User: "user1"}},
+	}
+
+	a, err := NewAnsibleCheckRunner(cfg, executionEvent)
+
+	suite.NoError(err)
+	suite.Equal("90", a.Envs["ANSIBLE_TASK_TIMEOUT"])
+}
+
+func (suite *RunnerTestCase) Test_NewAnsibleCheckRunner_ExtraVars() {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	os.MkdirAll(path.Join(tmpDir, "ansible"), 0755)
+	os.Create(path.Join(tmpDir, "ansible/check.yml"))
+	defer os.RemoveAll(tmpDir)
+
+	cfg := &Config{AnsibleFolder: tmpDir}
+
+	executionID := uuid.New()
+	executionEvent := &ExecutionEvent{
+		ExecutionID: executionID,
+		ClusterID:   uuid.New(),
+		Provider:    "azure",
+		Checks:      []string{"check1"},
+		Hosts:       []*Host{&Host{HostID: uuid.New(), Address: "192.168.10.1", User: "user1"}},
+		Variables:   map[string]interface{}{"expected_token_timeout": 30000, "sbd_enabled": true},
+	}
+
+	a, err := NewAnsibleCheckRunner(cfg, executionEvent)
+	suite.NoError(err)
+
+	extraVarsFile := path.Join(tmpDir, fmt.Sprintf("executions/%s/extra_vars.json", executionID.String()))
+	suite.Equal(extraVarsFile, a.ExtraVarsFile)
+
+	content, err := ioutil.ReadFile(extraVarsFile)
+	suite.NoError(err)
+	suite.JSONEq(`{"expected_token_timeout": 30000, "sbd_enabled": true}`, string(content))
+}
+
+func (suite *RunnerTestCase) Test_NewAnsibleCheckRunner_ConfiguredVariables() {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	os.MkdirAll(path.Join(tmpDir, "ansible"), 0755)
+	os.Create(path.Join(tmpDir, "ansible/check.yml"))
+	defer os.RemoveAll(tmpDir)
+
+	ioutil.WriteFile(path.Join(tmpDir, "become_password"), []byte("becomesecret\n"), 0600)
+	os.Setenv("TRENTO_TEST_HANA_PASSWORD", "hanasecret")
+	defer os.Unsetenv("TRENTO_TEST_HANA_PASSWORD")
+
+	cfg := &Config{
+		AnsibleFolder: tmpDir,
+		CheckVariables: map[string]interface{}{
+			"hana_password":          "${env:TRENTO_TEST_HANA_PASSWORD}",
+			"expected_token_timeout": 20000,
+		},
+		InventoryVariables: map[string]string{
+			"ansible_become_password": "${file:" + path.Join(tmpDir, "become_password") + "}",
+		},
+	}
+
+	executionID := uuid.New()
+	executionEvent := &ExecutionEvent{
+		ExecutionID: executionID,
+		ClusterID:   uuid.New(),
+		Checks:      []string{"check1"},
+		Hosts:       []*Host{&Host{HostID: uuid.New(), Address: "192.168.10.1", User: "user1"}},
+		// The placeholders of the execution variables are not resolved
+		Variables: map[string]interface{}{"expected_token_timeout": 30000, "user_home": "${env:HOME}"},
+	}
+
+	a, err := NewAnsibleCheckRunner(cfg, executionEvent)
+	suite.NoError(err)
+
+	content, err := ioutil.ReadFile(a.ExtraVarsFile)
+	suite.NoError(err)
+	suite.JSONEq(
+		`{"hana_password": "hanasecret", "expected_token_timeout": 30000, "user_home": "${env:HOME}"}`, string(content))
+
+	inventoryContent, err := ioutil.ReadFile(a.Inventory)
+	suite.NoError(err)
+	suite.Contains(string(inventoryContent), `ansible_become_password="becomesecret"`)
+
+	// The hosts connection passwords replace the configured inventory variables
+	secretsDir := path.Join(tmpDir, "secrets")
+	os.MkdirAll(secretsDir, 0700)
+	ioutil.WriteFile(path.Join(secretsDir, "host-become-password"), []byte("hostsecret"), 0600)
+	cfg.SecretsProvider = KubernetesSecretsProvider
+	cfg.SecretsDir = secretsDir
+	cfg.HostSecretsPrefix = "host-"
+	executionEvent.Hosts = append(executionEvent.Hosts, &Host{
+		HostID: uuid.New(), Address: "192.168.10.2", User: "user2",
+		Connection: &ConnectionOptions{BecomePasswordSecret: "host-become-password"},
+	})
+	a, err = NewAnsibleCheckRunner(cfg, executionEvent)
+	suite.NoError(err)
+	inventoryContent, err = ioutil.ReadFile(a.Inventory)
+	suite.NoError(err)
+	suite.Contains(string(inventoryContent), `192.168.10.1 ansible_user=user1 ansible_become_password="becomesecret"`)
+	suite.Contains(string(inventoryContent),
+		`192.168.10.2 ansible_user=user2 ansible_become_password='{{ lookup("env", "TRENTO_HOST_SECRET_0") }}'`)
+	suite.Equal("hostsecret", a.Envs["TRENTO_HOST_SECRET_0"])
+
+	os.Unsetenv("TRENTO_TEST_HANA_PASSWORD")
+	_, err = NewAnsibleCheckRunner(cfg, executionEvent)
+	suite.EqualError(err, "cannot resolve the variable hana_password: "+
+		"the TRENTO_TEST_HANA_PASSWORD environment variable is not set")
+}
+
+func (suite *RunnerTestCase) Test_NewAnsibleCheckRunner_CheckTimeouts() {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	os.MkdirAll(path.Join(tmpDir, "ansible"), 0755)
+	os.Create(path.Join(tmpDir, "ansible/check.yml"))
+	defer os.RemoveAll(tmpDir)
+
+	cfg := &Config{
+		AnsibleFolder: tmpDir,
+		CheckTimeout:  45 * time.Second,
+		CheckTimeouts: map[string]time.Duration{"156F64": 90*time.Second + time.Millisecond},
+	}
+
+	executionID := uuid.New()
+	executionEvent := &ExecutionEvent{
+		ExecutionID: executionID,
+		ClusterID:   uuid.New(),
+		Provider:    "azure",
+		Checks:      []string{"156F64"},
+		Hosts:       []*Host{&Host{HostID: uuid.New(), Address: "192.168.10.1", User: "user1"}},
+		Variables:   map[string]interface{}{"sbd_enabled": true},
+	}
+
+	a, err := NewAnsibleCheckRunner(cfg, executionEvent)
+	suite.NoError(err)
+
+	content, err := ioutil.ReadFile(a.ExtraVarsFile)
+	suite.NoError(err)
+	suite.JSONEq(
+		`{"sbd_enabled": true, "trento_check_timeout": 45, "trento_check_timeouts": {"156F64": 91}}`, string(content))
+	// The execution variables are not changed
+	suite.Equal(map[string]interface{}{"sbd_enabled": true}, executionEvent.Variables)
+}
+
+func (suite *RunnerTestCase) Test_NewAnsibleCheckRunner_RollingBatchSize() {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	os.MkdirAll(path.Join(tmpDir, "ansible"), 0755)
+	os.Create(path.Join(tmpDir, "ansible/check.yml"))
+	defer os.RemoveAll(tmpDir)
+
+	cfg := &Config{
+		AnsibleFolder:    tmpDir,
+		RollingBatchSize: 2,
+	}
+
+	executionEvent := &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Provider:    "azure",
+		Checks:      []string{"156F64"},
+		Hosts:       []*Host{&Host{HostID: uuid.New(), Address: "192.168.10.1", User: "user1"}},
+	}
+
+	a, err := NewAnsibleCheckRunner(cfg, executionEvent)
+	suite.NoError(err)
+
+	content, err := ioutil.ReadFile(a.ExtraVarsFile)
+	suite.NoError(err)
+	suite.JSONEq(`{"trento_rolling_batch_size": 2}`, string(content))
+
+	// The execution option overrides the runner one
+	executionEvent.RollingBatchSize = 3
+	a, err = NewAnsibleCheckRunner(cfg, executionEvent)
+	suite.NoError(err)
+
+	content, err = ioutil.ReadFile(a.ExtraVarsFile)
+	suite.NoError(err)
+	suite.JSONEq(`{"trento_rolling_batch_size": 3}`, string(content))
+}
+
+func (suite *RunnerTestCase) Test_NewAnsibleCheckRunner_Remediation() {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	os.MkdirAll(path.Join(tmpDir, "ansible"), 0755)
+	os.Create(path.Join(tmpDir, "ansible/check.yml"))
+	defer os.RemoveAll(tmpDir)
+
+	executionEvent := &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Provider:    "azure",
+		Checks:      []string{"156F64"},
+		Hosts:       []*Host{&Host{HostID: uuid.New(), Address: "192.168.10.1", User: "user1"}},
+		remediation: true,
+	}
+
+	a, err := NewAnsibleCheckRunner(&Config{AnsibleFolder: tmpDir}, executionEvent)
+	suite.NoError(err)
+	suite.False(a.Check)
+
+	content, err := ioutil.ReadFile(a.ExtraVarsFile)
+	suite.NoError(err)
+	suite.JSONEq(`{"trento_remediation": true}`, string(content))
+}
+
+func (suite *RunnerTestCase) Test_NewAnsibleCheckRunner_Limit() {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	os.MkdirAll(path.Join(tmpDir, "ansible"), 0755)
+	os.Create(path.Join(tmpDir, "ansible/check.yml"))
+	defer os.RemoveAll(tmpDir)
+
+	cfg := &Config{AnsibleFolder: tmpDir}
+
+	host1ID := uuid.New()
+	host2ID := uuid.New()
+	executionEvent := &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Provider:    "azure",
+		Checks:      []string{"check1"},
+		Hosts: []*Host{
+			&Host{HostID: host1ID, Address: "192.168.10.1", User: "user1"},
+			&Host{HostID: host2ID, Address: "192.168.10.2", User: "user2"},
+		},
+		Limit: []uuid.UUID{host2ID},
+	}
+
+	a, err := NewAnsibleCheckRunner(cfg, executionEvent)
+	suite.NoError(err)
+	suite.Equal([]string{host2ID.String()}, a.Limit)
+
+	// The rest of hosts are kept in the inventory
+	inventoryContent, _ := ioutil.ReadFile(a.Inventory)
+	suite.Contains(string(inventoryContent), host1ID.String())
+}
+
+func (suite *RunnerTestCase) Test_NewAnsibleCheckRunner_Tags() {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	os.MkdirAll(path.Join(tmpDir, "ansible"), 0755)
+	os.Create(path.Join(tmpDir, "ansible/check.yml"))
+	defer os.RemoveAll(tmpDir)
+
+	executionEvent := &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Provider:    "azure",
+		Checks:      []string{"check1"},
+		Hosts:       []*Host{&Host{HostID: uuid.New(), Address: "192.168.10.1", User: "user1"}},
+		Tags:        []string{"corosync"},
+		SkipTags:    []string{"sbd", "fencing"},
+	}
+
+	a, err := NewAnsibleCheckRunner(&Config{AnsibleFolder: tmpDir}, executionEvent)
+	suite.NoError(err)
+	suite.Equal([]string{"corosync"}, a.Tags)
+	suite.Equal([]string{"sbd", "fencing"}, a.SkipTags)
+}
+
+func (suite *RunnerTestCase) Test_NewAnsibleCheckRunner_SSHCredentials() {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	os.MkdirAll(path.Join(tmpDir, "ansible"), 0755)
+	os.Create(path.Join(tmpDir, "ansible/check.yml"))
+	defer os.RemoveAll(tmpDir)
+
+	passphraseFile := path.Join(tmpDir, "passphrase")
+	ioutil.WriteFile(passphraseFile, []byte("secret\n"), 0600)
+
+	cfg := &Config{
+		CallbacksUrl:       "http://192.168.1.1:8000/api/runner/callbacks",
+		AnsibleFolder:      tmpDir,
+		SSHPrivateKeyFile:  "/root/.ssh/id_rsa",
+		SSHPassphraseFile:  passphraseFile,
+		SSHAgentForwarding: true,
+	}
+
+	executionID := uuid.New()
+	clusterID := uuid.New()
+	hostID := uuid.New()
+	executionEvent := &ExecutionEvent{
+		ExecutionID: executionID,
+		ClusterID:   clusterID,
+		Provider:    "azure",
+		Checks:      []string{"check1"},
+		Hosts: []*Host{
+			&Host{
+				HostID:  hostID,
+				Address: "192.168.10.1",
+				User:    "user1",
+			},
+		},
+	}
+
+	a, err := NewAnsibleCheckRunner(cfg, executionEvent)
+	suite.NoError(err)
+
+	askPassProgram := path.Join(tmpDir, "ansible/ssh_askpass.sh")
+	suite.Equal("/root/.ssh/id_rsa", a.Envs["ANSIBLE_PRIVATE_KEY_FILE"])
+	suite.Equal("secret", a.Envs["TRENTO_SSH_PASSPHRASE"])
+	suite.Equal(askPassProgram, a.Envs["SSH_ASKPASS"])
+	suite.Equal("force", a.Envs["SSH_ASKPASS_REQUIRE"])
+
+	askPass := exec.Command(askPassProgram)
+	askPass.Env = []string{"TRENTO_SSH_PASSPHRASE=secret"}
+	output, err := askPass.Output()
+	suite.NoError(err)
+	suite.Equal("secret\n", string(output))
+
+	inventoryContent, _ := ioutil.ReadFile(a.Inventory)
+	suite.Contains(string(inventoryContent), "ansible_ssh_extra_args='-o ForwardAgent=yes'")
+}
+
+func (suite *RunnerTestCase) Test_NewAnsibleCheckRunner_VaultCredentials() {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	os.MkdirAll(path.Join(tmpDir, "ansible"), 0755)
+	os.Create(path.Join(tmpDir, "ansible/check.yml"))
+	os.Create(path.Join(tmpDir, "ansible/meta.yml"))
+	defer os.RemoveAll(tmpDir)
+
+	cfg := &Config{
+		CallbacksUrl:      "http://192.168.1.1:8000/api/runner/callbacks",
+		AnsibleFolder:     tmpDir,
+		VaultPasswordFile: "/etc/trento/vault_password",
+		VaultIDs:          []string{"hana@/etc/trento/hana_password"},
+	}
+
+	executionEvent := &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Provider:    "azure",
+		Checks:      []string{"check1"},
+		Hosts:       []*Host{&Host{HostID: uuid.New(), Address: "192.168.10.1", User: "user1"}},
+	}
+
+	a, err := NewAnsibleCheckRunner(cfg, executionEvent)
+	suite.NoError(err)
+	suite.Equal("/etc/trento/vault_password", a.Envs["ANSIBLE_VAULT_PASSWORD_FILE"])
+	suite.Equal("hana@/etc/trento/hana_password", a.Envs["ANSIBLE_VAULT_IDENTITY_LIST"])
+
+	// The catalog playbook might read the encrypted variables as well
+	metaRunner, err := NewAnsibleMetaRunner(cfg)
+	suite.NoError(err)
+	suite.Equal("/etc/trento/vault_password", metaRunner.Envs["ANSIBLE_VAULT_PASSWORD_FILE"])
+	suite.Equal("hana@/etc/trento/hana_password", metaRunner.Envs["ANSIBLE_VAULT_IDENTITY_LIST"])
+}
+
+func (suite *RunnerTestCase) Test_NewAnsibleCheckRunner_PartialResults() {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	os.MkdirAll(path.Join(tmpDir, "ansible"), 0755)
+	os.Create(path.Join(tmpDir, "ansible/check.yml"))
+	defer os.RemoveAll(tmpDir)
+
+	executionEvent := &ExecutionEvent{
+		ExecutionID: uuid.New(),
+		ClusterID:   uuid.New(),
+		Provider:    "azure",
+		Checks:      []string{"check1"},
+		Hosts:       []*Host{&Host{HostID: uuid.New(), Address: "192.168.10.1", User: "user1"}},
+	}
+
+	a, err := NewAnsibleCheckRunner(&Config{AnsibleFolder: tmpDir, PartialResults: true}, executionEvent)
+	suite.NoError(err)
+	suite.Equal("1", a.Envs["TRENTO_PARTIAL_RESULTS"])
+}
+
+func (suite *RunnerTestCase) Test_NewAnsibleCheckRunner_SSHPassphraseFileNotFound() {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	os.MkdirAll(path.Join(tmpDir, "ansible"), 0755)
+	os.Create(path.Join(tmpDir, "ansible/check.yml"))
+	defer os.RemoveAll(tmpDir)
+
+	cfg := &Config{
+		AnsibleFolder:     tmpDir,
+		SSHPassphraseFile: path.Join(tmpDir, "passphrase"),
+	}
+
+	_, err := NewAnsibleCheckRunner(cfg, &ExecutionEvent{ExecutionID: uuid.New()})
+	suite.Error(err)
+}
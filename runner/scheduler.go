@@ -0,0 +1,602 @@
+package runner
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ScheduleOverlapSkip skips the scheduled executions due while the previous one of the cluster is running
+	ScheduleOverlapSkip = "skip"
+	// ScheduleOverlapQueue starts one of the skipped executions as soon as the previous one is finished
+	ScheduleOverlapQueue = "queue"
+)
+
+var ErrScheduleNotFound = errors.New("The cluster does not have a schedule")
+var ErrSchedulerDisabled = errors.New("The executions scheduler is disabled")
+var ErrInvalidSchedule = errors.New("The schedule is not valid")
+
+var schedulesRefreshInterval = time.Minute * 5
+var schedulesFetchTimeout = time.Second * 10
+var schedulesRetryPolicy = RetryPolicy{Attempts: 3, Interval: time.Second}
+
+// A scheduled execution is considered lost after this time, if its finished event is never received
+var scheduledExecutionTimeout = time.Hour * 2
+
+// Schedule runs the selected checks of a cluster periodically, using a standard cron expression
+type Schedule struct {
+	ClusterID uuid.UUID `json:"cluster_id" binding:"required"`
+	Cron      string    `json:"cron" binding:"required"`
+	// Jitter delays each execution a random time up to the given duration, e.g. 5m,
+	// so the clusters with the same schedule are not checked at the same time
+	Jitter   string   `json:"jitter,omitempty"`
+	Provider string   `json:"provider" binding:"required"`
+	Checks   []string `json:"checks" binding:"required"`
+	Hosts    []*Host  `json:"hosts" binding:"required"`
+	// Variables are given to the checks of the scheduled executions as ansible extra vars
+	Variables map[string]interface{} `json:"variables,omitempty"`
+	// Upstream is the Trento server where the results of the scheduled executions are sent
+	Upstream string `json:"upstream,omitempty"`
+	// Connection are the ansible connection options of the cluster hosts
+	Connection *ConnectionOptions `json:"connection,omitempty"`
+
+	jitter time.Duration
+}
+
+// ScheduleStatus is the state of a cluster schedule, as returned by the API
+type ScheduleStatus struct {
+	ClusterID        uuid.UUID  `json:"cluster_id"`
+	Cron             string     `json:"cron"`
+	Jitter           string     `json:"jitter,omitempty"`
+	Paused           bool       `json:"paused"`
+	NextRun          *time.Time `json:"next_run,omitempty"`
+	RunningExecution *uuid.UUID `json:"running_execution,omitempty"`
+	Queued           bool       `json:"queued,omitempty"`
+	MissedTicks      int64      `json:"missed_ticks"`
+}
+
+// scheduleUpdate is a cron expression and jitter pushed by the Trento server, replacing the ones of the source
+type scheduleUpdate struct {
+	Cron   string `json:"cron" binding:"required"`
+	Jitter string `json:"jitter"`
+}
+
+type scheduledCluster struct {
+	schedule    *Schedule
+	entryID     cron.EntryID
+	paused      bool
+	running     uuid.UUID
+	startedAt   time.Time
+	queued      bool
+	missedTicks int64
+}
+
+// Scheduler starts the executions of the clusters following their cron schedules.
+// A new execution of a cluster is not started while the previous one is still running,
+// the missed ticks are skipped or queued following the overlap policy
+type Scheduler struct {
+	runnerService RunnerService
+	events        *EventsBroadcaster
+	source        string
+	overlap       string
+	httpClient    *http.Client
+	cron          *cron.Cron
+	ctx           context.Context
+	mu            sync.Mutex
+	clusters      map[uuid.UUID]*scheduledCluster
+	// updates are the schedules changed through the API, kept when the schedules are reloaded
+	updates     map[uuid.UUID]*scheduleUpdate
+	missedTicks int64
+	// version is the one of the schedules last read from the source, so they are only applied again if they changed
+	reloadMu sync.Mutex
+	version  *sourceVersion
+}
+
+func NewScheduler(config *Config, runnerService RunnerService, events *EventsBroadcaster, transport http.RoundTripper) *Scheduler {
+	httpClient := &http.Client{Transport: transport, Timeout: schedulesFetchTimeout}
+
+	overlap := config.ScheduleOverlap
+	if overlap == "" {
+		overlap = ScheduleOverlapSkip
+	}
+
+	return &Scheduler{
+		runnerService: runnerService,
+		events:        events,
+		source:        config.Schedules,
+		overlap:       overlap,
+		httpClient:    httpClient,
+		cron:          cron.New(),
+		ctx:           context.Background(),
+		clusters:      make(map[uuid.UUID]*scheduledCluster),
+		updates:       make(map[uuid.UUID]*scheduleUpdate),
+	}
+}
+
+// LoadSchedules reads the schedules in json from the given file, or from the given url if it is a http one
+func LoadSchedules(source string, httpClient *http.Client) ([]*Schedule, error) {
+	content, err := readSource(httpClient, source, "schedules")
+	if err != nil {
+		return nil, err
+	}
+
+	return parseSchedules(source, content)
+}
+
+// parseSchedules reads the schedules in json read from the given source
+func parseSchedules(source string, content []byte) ([]*Schedule, error) {
+	var schedules []*Schedule
+	if err := json.Unmarshal(content, &schedules); err != nil {
+		return nil, fmt.Errorf("cannot read the schedules in %s: %s", source, err)
+	}
+
+	for _, schedule := range schedules {
+		if err := binding.Validator.ValidateStruct(schedule); err != nil {
+			return nil, fmt.Errorf("invalid schedule in %s: %s", source, err)
+		}
+
+		if err := (&ExecutionEvent{
+			Hosts: schedule.Hosts, Connection: schedule.Connection, Variables: schedule.Variables}).validate(); err != nil {
+			return nil, fmt.Errorf("invalid schedule in %s: %s", source, err)
+		}
+
+		if err := schedule.setTiming(schedule.Cron, schedule.Jitter); err != nil {
+			return nil, err
+		}
+	}
+
+	return schedules, nil
+}
+
+// newExecution returns the execution of the scheduled checks in the cluster hosts
+func (s *Schedule) newExecution(executionID uuid.UUID) *ExecutionEvent {
+	return &ExecutionEvent{
+		ExecutionID: executionID,
+		ClusterID:   s.ClusterID,
+		Provider:    s.Provider,
+		Checks:      s.Checks,
+		Hosts:       s.Hosts,
+		Variables:   s.Variables,
+		Upstream:    s.Upstream,
+		Connection:  s.Connection,
+		// The scheduled executions do not delay the ones requested by the users
+		Priority: PriorityLow,
+	}
+}
+
+// setTiming validates and sets the cron expression and the jitter of the schedule
+func (s *Schedule) setTiming(cronSpec, jitterSpec string) error {
+	if _, err := cron.ParseStandard(cronSpec); err != nil {
+		return fmt.Errorf("cluster %s schedule %s is not valid: %s", s.ClusterID, cronSpec, err)
+	}
+
+	var jitter time.Duration
+	if jitterSpec != "" {
+		var err error
+		jitter, err = time.ParseDuration(jitterSpec)
+		if err != nil || jitter < 0 {
+			return fmt.Errorf("cluster %s jitter %s is not valid", s.ClusterID, jitterSpec)
+		}
+	}
+
+	s.Cron = cronSpec
+	s.Jitter = jitterSpec
+	s.jitter = jitter
+
+	return nil
+}
+
+// sourceVersion identifies the content last read from a source. The http sources are fetched with conditional
+// requests, using the ETag and Last-Modified headers of the last response, and the content is compared by its hash
+// as well, for the files and the servers without those headers
+type sourceVersion struct {
+	etag         string
+	lastModified string
+	hash         [sha256.Size]byte
+}
+
+// readSource reads the given file, or fetches the given url if it is a http one, retrying the failed requests
+func readSource(httpClient *http.Client, source, name string) ([]byte, error) {
+	content, _, err := readSourceIfModified(httpClient, source, name, nil)
+
+	return content, err
+}
+
+// readSourceIfModified reads the source as readSource does, unless it is not modified since the given version.
+// The content is nil if it is not modified, and the returned version is the one of the content read.
+// The source is always read if the version is nil
+func readSourceIfModified(httpClient *http.Client, source, name string, version *sourceVersion) ([]byte, *sourceVersion, error) {
+	var content []byte
+	var current *sourceVersion
+	var err error
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		content, current, err = fetchSource(httpClient, source, name, version)
+	} else {
+		content, err = ioutil.ReadFile(source)
+		current = &sourceVersion{}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if content == nil {
+		return nil, version, nil
+	}
+
+	current.hash = sha256.Sum256(content)
+	if version != nil && version.hash == current.hash {
+		return nil, current, nil
+	}
+
+	return content, current, nil
+}
+
+// fetchSource gets the url content, sending the validators of the given version if it is not nil.
+// The content is nil if the server answers that it is not modified
+func fetchSource(httpClient *http.Client, url, name string, version *sourceVersion) ([]byte, *sourceVersion, error) {
+	content := []byte{}
+	current := &sourceVersion{}
+
+	err := schedulesRetryPolicy.Do(context.Background(), func() error {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		if version != nil {
+			if version.etag != "" {
+				req.Header.Set("If-None-Match", version.etag)
+			}
+			if version.lastModified != "" {
+				req.Header.Set("If-Modified-Since", version.lastModified)
+			}
+		}
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode == http.StatusNotModified && version != nil {
+			content = nil
+			return nil
+		}
+
+		if resp.StatusCode != 200 {
+			return fmt.Errorf("cannot fetch the %s from %s, status code: %d", name, url, resp.StatusCode)
+		}
+
+		current.etag = resp.Header.Get("ETag")
+		current.lastModified = resp.Header.Get("Last-Modified")
+		content, err = ioutil.ReadAll(resp.Body)
+		return err
+	}, func(_ int, wait time.Duration, err error) {
+		log.Warnf("Error fetching the %s, retrying in %s: %s", name, wait, err)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return content, current, nil
+}
+
+// Reload replaces the schedules with the ones in the source, keeping the paused and running state of the clusters.
+// The schedules are applied again even if they are not modified
+func (s *Scheduler) Reload() error {
+	return s.reload(true)
+}
+
+// refresh replaces the schedules with the ones in the source only if they are modified since the last reload,
+// so the clusters schedules are not rebuilt in each tick in the large installations
+func (s *Scheduler) refresh() error {
+	return s.reload(false)
+}
+
+func (s *Scheduler) reload(force bool) error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	version := s.version
+	if force {
+		version = nil
+	}
+
+	content, version, err := readSourceIfModified(s.httpClient, s.source, "schedules", version)
+	if err != nil {
+		return err
+	}
+	if content == nil {
+		log.Debugf("The schedules in %s are not modified", s.source)
+		return nil
+	}
+
+	schedules, err := parseSchedules(s.source, content)
+	if err != nil {
+		return err
+	}
+
+	if err := s.apply(schedules); err != nil {
+		return err
+	}
+	s.version = version
+
+	return nil
+}
+
+// apply replaces the schedules, keeping the paused and running state of the clusters
+func (s *Scheduler) apply(schedules []*Schedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clusters := make(map[uuid.UUID]*scheduledCluster)
+	for _, schedule := range schedules {
+		if update, ok := s.updates[schedule.ClusterID]; ok {
+			if err := schedule.setTiming(update.Cron, update.Jitter); err != nil {
+				return err
+			}
+		}
+
+		cluster := &scheduledCluster{schedule: schedule}
+		if previous, ok := s.clusters[schedule.ClusterID]; ok {
+			cluster.paused = previous.paused
+			cluster.running = previous.running
+			cluster.startedAt = previous.startedAt
+			cluster.queued = previous.queued
+			cluster.missedTicks = previous.missedTicks
+		}
+
+		clusterID := schedule.ClusterID
+		entryID, err := s.cron.AddFunc(schedule.Cron, func() { s.trigger(clusterID) })
+		if err != nil {
+			for _, added := range clusters {
+				s.cron.Remove(added.entryID)
+			}
+			return err
+		}
+		cluster.entryID = entryID
+		clusters[clusterID] = cluster
+	}
+
+	for _, previous := range s.clusters {
+		s.cron.Remove(previous.entryID)
+	}
+	s.clusters = clusters
+
+	log.Infof("Loaded %d clusters schedules from %s", len(clusters), s.source)
+
+	return nil
+}
+
+// Run starts the scheduled executions until the context is done, reloading the schedules periodically
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.ctx = ctx
+
+	events, unsubscribe := s.events.Subscribe()
+	defer unsubscribe()
+
+	if err := s.Reload(); err != nil {
+		return err
+	}
+
+	log.Infof("Starting executions scheduler")
+	s.cron.Start()
+
+	ticker := time.NewTicker(schedulesRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event := <-events:
+			if event.Event == executionFinishedEvent || event.Event == executionFailedEvent {
+				s.finished(event.ExecutionID)
+			}
+		case <-ticker.C:
+			if err := s.refresh(); err != nil {
+				log.Errorf("Error reloading the schedules, keeping the current ones: %s", err)
+			}
+		case <-ctx.Done():
+			log.Infof("Executions scheduler is shutting down.")
+			<-s.cron.Stop().Done()
+			return nil
+		}
+	}
+}
+
+// Pause stops the scheduled executions of the cluster until it is resumed
+func (s *Scheduler) Pause(clusterID uuid.UUID) error {
+	return s.setPaused(clusterID, true)
+}
+
+func (s *Scheduler) Resume(clusterID uuid.UUID) error {
+	return s.setPaused(clusterID, false)
+}
+
+// Update replaces the cron expression and the jitter of the cluster schedule right away, without restarting
+// the runner. The update is kept when the schedules are reloaded from the source
+func (s *Scheduler) Update(clusterID uuid.UUID, cronSpec, jitterSpec string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cluster, ok := s.clusters[clusterID]
+	if !ok {
+		return ErrScheduleNotFound
+	}
+
+	schedule := *cluster.schedule
+	if err := schedule.setTiming(cronSpec, jitterSpec); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidSchedule, err)
+	}
+
+	entryID, err := s.cron.AddFunc(schedule.Cron, func() { s.trigger(clusterID) })
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidSchedule, err)
+	}
+	s.cron.Remove(cluster.entryID)
+	cluster.entryID = entryID
+	cluster.schedule = &schedule
+	s.updates[clusterID] = &scheduleUpdate{Cron: cronSpec, Jitter: jitterSpec}
+
+	log.Infof("Cluster %s schedule updated to %s", clusterID.String(), cronSpec)
+
+	return nil
+}
+
+// MissedTicks returns the number of scheduled executions skipped or queued because the previous one was running
+func (s *Scheduler) MissedTicks() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.missedTicks
+}
+
+func (s *Scheduler) setPaused(clusterID uuid.UUID, paused bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cluster, ok := s.clusters[clusterID]
+	if !ok {
+		return ErrScheduleNotFound
+	}
+	cluster.paused = paused
+
+	return nil
+}
+
+// List returns the state of the clusters schedules
+func (s *Scheduler) List() []*ScheduleStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := []*ScheduleStatus{}
+	for clusterID, cluster := range s.clusters {
+		status := &ScheduleStatus{
+			ClusterID:   clusterID,
+			Cron:        cluster.schedule.Cron,
+			Jitter:      cluster.schedule.Jitter,
+			Paused:      cluster.paused,
+			Queued:      cluster.queued,
+			MissedTicks: cluster.missedTicks,
+		}
+
+		if next := s.cron.Entry(cluster.entryID).Next; !next.IsZero() && !cluster.paused {
+			status.NextRun = &next
+		}
+		if cluster.running != uuid.Nil {
+			running := cluster.running
+			status.RunningExecution = &running
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].ClusterID.String() < statuses[j].ClusterID.String()
+	})
+
+	return statuses
+}
+
+// TriggerAll starts a new execution of every scheduled cluster right away, as if all their schedules were due now.
+// The paused clusters and the ones with a running execution are skipped as usual. It returns the triggered clusters
+func (s *Scheduler) TriggerAll() int {
+	s.mu.Lock()
+	clusterIDs := []uuid.UUID{}
+	for clusterID, cluster := range s.clusters {
+		if !cluster.paused {
+			clusterIDs = append(clusterIDs, clusterID)
+		}
+	}
+	s.mu.Unlock()
+
+	log.Infof("Triggering the scheduled executions of %d clusters", len(clusterIDs))
+	for _, clusterID := range clusterIDs {
+		go s.trigger(clusterID)
+	}
+
+	return len(clusterIDs)
+}
+
+// trigger starts a new execution of the cluster, after the jitter delay, unless it is paused or
+// the previous execution is still running. In that case the tick is missed, and it is queued with the queue policy
+func (s *Scheduler) trigger(clusterID uuid.UUID) {
+	s.mu.Lock()
+	cluster, ok := s.clusters[clusterID]
+	if !ok || cluster.paused {
+		s.mu.Unlock()
+		return
+	}
+
+	if cluster.running != uuid.Nil {
+		if time.Since(cluster.startedAt) < scheduledExecutionTimeout {
+			cluster.missedTicks++
+			s.missedTicks++
+			if s.overlap == ScheduleOverlapQueue {
+				cluster.queued = true
+				log.Warnf("Execution %s of cluster %s is still running, queueing the scheduled execution",
+					cluster.running.String(), clusterID.String())
+			} else {
+				log.Warnf("Execution %s of cluster %s is still running, skipping the scheduled execution",
+					cluster.running.String(), clusterID.String())
+			}
+			s.mu.Unlock()
+			return
+		}
+		log.Warnf("Execution %s of cluster %s did not finish in time, starting a new one",
+			cluster.running.String(), clusterID.String())
+	}
+
+	executionID := uuid.New()
+	cluster.running = executionID
+	cluster.startedAt = time.Now()
+	schedule := cluster.schedule
+	s.mu.Unlock()
+
+	if schedule.jitter > 0 {
+		select {
+		case <-time.After(time.Duration(rand.Int63n(int64(schedule.jitter)))):
+		case <-s.ctx.Done():
+			s.finished(executionID)
+			return
+		}
+	}
+
+	log.Infof("Starting the scheduled execution %s of cluster %s", executionID.String(), clusterID.String())
+	if err := s.runnerService.ScheduleExecution(schedule.newExecution(executionID)); err != nil {
+		log.Errorf("Error starting the scheduled execution of cluster %s: %s", clusterID.String(), err)
+		s.finished(executionID)
+	}
+}
+
+// finished allows the next execution of the cluster running the given execution,
+// starting it right away if a scheduled execution was queued meanwhile
+func (s *Scheduler) finished(executionID uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for clusterID, cluster := range s.clusters {
+		if cluster.running != executionID {
+			continue
+		}
+		cluster.running = uuid.Nil
+		if cluster.queued && s.ctx.Err() == nil {
+			cluster.queued = false
+			log.Infof("Starting the queued scheduled execution of cluster %s", clusterID.String())
+			go s.trigger(clusterID)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	TestSchedulesFile string = "../test/fixtures/schedules.json"
+)
+
+var (
+	scheduledClusterID = uuid.MustParse("9c832998-801e-4a11-9e4d-fb3432e8a8a1")
+	dailyClusterID     = uuid.MustParse("8c832998-801e-4a11-9e4d-fb3432e8a8a1")
+)
+
+type SchedulerTestSuite struct {
+	suite.Suite
+	runnerService *MockRunnerService
+	events        *EventsBroadcaster
+	scheduler     *Scheduler
+}
+
+func TestSchedulerTestSuite(t *testing.T) {
+	suite.Run(t, new(SchedulerTestSuite))
+}
+
+func (suite *SchedulerTestSuite) SetupTest() {
+	schedulesRetryPolicy.Interval = time.Millisecond
+	suite.runnerService = new(MockRunnerService)
+	suite.events = NewEventsBroadcaster()
+	suite.scheduler = NewScheduler(&Config{Schedules: TestSchedulesFile}, suite.runnerService, suite.events, nil)
+}
+
+func (suite *SchedulerTestSuite) TestLoadSchedules() {
+	schedules, err := LoadSchedules(TestSchedulesFile, http.DefaultClient)
+	suite.NoError(err)
+	suite.Len(schedules, 2)
+
+	suite.Equal(scheduledClusterID, schedules[0].ClusterID)
+	suite.Equal("0 */6 * * *", schedules[0].Cron)
+	suite.Equal(5*time.Minute, schedules[0].jitter)
+	suite.Equal("azure", schedules[0].Provider)
+	suite.Equal([]string{"156F64", "A1244C"}, schedules[0].Checks)
+	suite.Len(schedules[0].Hosts, 2)
+	suite.Equal(time.Duration(0), schedules[1].jitter)
+}
+
+func (suite *SchedulerTestSuite) TestLoadSchedules_Url() {
+	content, _ := ioutil.ReadFile(TestSchedulesFile)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/schedules" {
+			w.WriteHeader(404)
+			return
+		}
+		w.Write(content)
+	}))
+	defer server.Close()
+
+	schedules, err := LoadSchedules(server.URL+"/api/schedules", http.DefaultClient)
+	suite.NoError(err)
+	suite.Len(schedules, 2)
+
+	_, err = LoadSchedules(server.URL+"/other", http.DefaultClient)
+	suite.EqualError(
+		err, fmt.Sprintf("cannot fetch the schedules from %s/other, status code: 404", server.URL))
+}
+
+func (suite *SchedulerTestSuite) TestLoadSchedules_Invalid() {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	schedulesFile := path.Join(tmpDir, "schedules.json")
+	schedule := `[{"cluster_id": "%s", "cron": "%s", "jitter": "%s", "provider": "azure", "checks": [], "hosts": []}]`
+
+	ioutil.WriteFile(schedulesFile, []byte(fmt.Sprintf(schedule, scheduledClusterID, "* * *", "")), 0644)
+	_, err := LoadSchedules(schedulesFile, http.DefaultClient)
+	suite.Contains(err.Error(), fmt.Sprintf("cluster %s schedule * * * is not valid", scheduledClusterID))
+
+	ioutil.WriteFile(schedulesFile, []byte(fmt.Sprintf(schedule, scheduledClusterID, "@hourly", "soon")), 0644)
+	_, err = LoadSchedules(schedulesFile, http.DefaultClient)
+	suite.EqualError(err, fmt.Sprintf("cluster %s jitter soon is not valid", scheduledClusterID))
+
+	ioutil.WriteFile(schedulesFile, []byte(`[{"cron": "@hourly"}]`), 0644)
+	_, err = LoadSchedules(schedulesFile, http.DefaultClient)
+	suite.Contains(err.Error(), "invalid schedule in")
+	ioutil.WriteFile(schedulesFile, []byte(fmt.Sprintf(
+		`[{"cluster_id": "%s", "cron": "@hourly", "provider": "azure", "checks": [], "hosts": [], `+
+			`"variables": {"ansible_ssh_common_args": "-o ProxyCommand=id"}}]`, scheduledClusterID)), 0644)
+	_, err = LoadSchedules(schedulesFile, http.DefaultClient)
+	suite.EqualError(err, fmt.Sprintf("invalid schedule in %s: variable ansible_ssh_common_args is reserved", schedulesFile))
+}
+
+func (suite *SchedulerTestSuite) TestReload() {
+	suite.NoError(suite.scheduler.Reload())
+	suite.NoError(suite.scheduler.Pause(scheduledClusterID))
+
+	suite.NoError(suite.scheduler.Reload())
+	suite.Len(suite.scheduler.cron.Entries(), 2)
+
+	statuses := suite.scheduler.List()
+	suite.Len(statuses, 2)
+	suite.Equal(dailyClusterID, statuses[0].ClusterID)
+	suite.False(statuses[0].Paused)
+	suite.Equal(scheduledClusterID, statuses[1].ClusterID)
+	suite.True(statuses[1].Paused)
+	suite.Nil(statuses[1].NextRun)
+
+	suite.Equal(ErrScheduleNotFound, suite.scheduler.Pause(uuid.New()))
+}
+
+// scheduleEntries returns the ids of the cron entries of the scheduler, which change when the schedules are rebuilt
+func scheduleEntries(scheduler *Scheduler) []cron.EntryID {
+	ids := []cron.EntryID{}
+	for _, entry := range scheduler.cron.Entries() {
+		ids = append(ids, entry.ID)
+	}
+
+	return ids
+}
+
+func (suite *SchedulerTestSuite) TestRefresh_NotModified() {
+	content, _ := ioutil.ReadFile(TestSchedulesFile)
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write(content)
+	}))
+	defer server.Close()
+
+	scheduler := NewScheduler(&Config{Schedules: server.URL + "/api/schedules"}, suite.runnerService, suite.events, nil)
+	suite.NoError(scheduler.Reload())
+	entries := scheduleEntries(scheduler)
+	suite.Len(entries, 2)
+
+	// The schedules are not rebuilt if the server answers that they are not modified
+	suite.NoError(scheduler.refresh())
+	suite.Equal(2, requests)
+	suite.Equal(entries, scheduleEntries(scheduler))
+
+	// The reload applies them again, without the validators
+	suite.NoError(scheduler.Reload())
+	suite.Equal(3, requests)
+	suite.NotEqual(entries, scheduleEntries(scheduler))
+}
+
+func (suite *SchedulerTestSuite) TestRefresh_SameContent() {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	content, _ := ioutil.ReadFile(TestSchedulesFile)
+	schedulesFile := path.Join(tmpDir, "schedules.json")
+	ioutil.WriteFile(schedulesFile, content, 0644)
+
+	scheduler := NewScheduler(&Config{Schedules: schedulesFile}, suite.runnerService, suite.events, nil)
+	suite.NoError(scheduler.refresh())
+	entries := scheduleEntries(scheduler)
+	suite.Len(entries, 2)
+
+	// The content is compared by its hash if there are no validators
+	suite.NoError(scheduler.refresh())
+	suite.Equal(entries, scheduleEntries(scheduler))
+
+	ioutil.WriteFile(schedulesFile, []byte(`[]`), 0644)
+	suite.NoError(scheduler.refresh())
+	suite.Empty(scheduler.cron.Entries())
+}
+
+func (suite *SchedulerTestSuite) TestTrigger() {
+	suite.NoError(suite.scheduler.Reload())
+
+	var executionID uuid.UUID
+	suite.runnerService.On("ScheduleExecution", mock.MatchedBy(func(e *ExecutionEvent) bool {
+		executionID = e.ExecutionID
+		return e.ClusterID == dailyClusterID && e.Provider == "aws" && len(e.Hosts) == 1
+	})).Return(nil)
+
+	suite.scheduler.trigger(dailyClusterID)
+	suite.runnerService.AssertNumberOfCalls(suite.T(), "ScheduleExecution", 1)
+	suite.Equal(executionID, *suite.scheduler.List()[0].RunningExecution)
+
+	// The previous execution is still running
+	suite.scheduler.trigger(dailyClusterID)
+	suite.runnerService.AssertNumberOfCalls(suite.T(), "ScheduleExecution", 1)
+
+	suite.scheduler.finished(executionID)
+	suite.Nil(suite.scheduler.List()[0].RunningExecution)
+	suite.scheduler.trigger(dailyClusterID)
+	suite.runnerService.AssertNumberOfCalls(suite.T(), "ScheduleExecution", 2)
+}
+
+func (suite *SchedulerTestSuite) TestTrigger_Queue() {
+	suite.scheduler = NewScheduler(
+		&Config{Schedules: TestSchedulesFile, ScheduleOverlap: ScheduleOverlapQueue}, suite.runnerService, suite.events, nil)
+	suite.NoError(suite.scheduler.Reload())
+	suite.runnerService.On("ScheduleExecution", mock.Anything).Return(nil)
+
+	suite.scheduler.trigger(dailyClusterID)
+	executionID := *suite.scheduler.List()[0].RunningExecution
+
+	// The missed ticks are collapsed in a single queued execution
+	suite.scheduler.trigger(dailyClusterID)
+	suite.scheduler.trigger(dailyClusterID)
+	suite.runnerService.AssertNumberOfCalls(suite.T(), "ScheduleExecution", 1)
+	suite.True(suite.scheduler.List()[0].Queued)
+	suite.Equal(int64(2), suite.scheduler.List()[0].MissedTicks)
+	suite.Equal(int64(2), suite.scheduler.MissedTicks())
+
+	suite.scheduler.finished(executionID)
+	suite.Eventually(func() bool {
+		running := suite.scheduler.List()[0].RunningExecution
+		return running != nil && *running != executionID
+	}, time.Second, time.Millisecond*10)
+	suite.runnerService.AssertNumberOfCalls(suite.T(), "ScheduleExecution", 2)
+	suite.False(suite.scheduler.List()[0].Queued)
+}
+
+func (suite *SchedulerTestSuite) TestTrigger_Skip() {
+	suite.NoError(suite.scheduler.Reload())
+	suite.runnerService.On("ScheduleExecution", mock.Anything).Return(nil)
+
+	suite.scheduler.trigger(dailyClusterID)
+	executionID := *suite.scheduler.List()[0].RunningExecution
+	suite.scheduler.trigger(dailyClusterID)
+	suite.Equal(int64(1), suite.scheduler.MissedTicks())
+	suite.False(suite.scheduler.List()[0].Queued)
+
+	suite.scheduler.finished(executionID)
+	suite.Nil(suite.scheduler.List()[0].RunningExecution)
+	suite.runnerService.AssertNumberOfCalls(suite.T(), "ScheduleExecution", 1)
+}
+
+func (suite *SchedulerTestSuite) TestUpdate() {
+	suite.NoError(suite.scheduler.Reload())
+
+	suite.NoError(suite.scheduler.Update(dailyClusterID, "*/5 * * * *", ""))
+	suite.Len(suite.scheduler.cron.Entries(), 2)
+	suite.Equal("*/5 * * * *", suite.scheduler.List()[0].Cron)
+
+	// The updates are kept when the schedules are read again
+	suite.NoError(suite.scheduler.Reload())
+	suite.Equal("*/5 * * * *", suite.scheduler.List()[0].Cron)
+
+	suite.ErrorIs(suite.scheduler.Update(dailyClusterID, "@hourly", "-1m"), ErrInvalidSchedule)
+	suite.Equal(ErrScheduleNotFound, suite.scheduler.Update(uuid.New(), "@hourly", ""))
+	suite.Equal("*/5 * * * *", suite.scheduler.List()[0].Cron)
+}
+
+func (suite *SchedulerTestSuite) TestTrigger_Paused() {
+	suite.NoError(suite.scheduler.Reload())
+	suite.NoError(suite.scheduler.Pause(dailyClusterID))
+
+	suite.scheduler.trigger(dailyClusterID)
+	suite.runnerService.AssertNotCalled(suite.T(), "ScheduleExecution", mock.Anything)
+
+	suite.runnerService.On("ScheduleExecution", mock.Anything).Return(nil)
+	suite.NoError(suite.scheduler.Resume(dailyClusterID))
+	suite.scheduler.trigger(dailyClusterID)
+	suite.runnerService.AssertNumberOfCalls(suite.T(), "ScheduleExecution", 1)
+}
+
+func (suite *SchedulerTestSuite) TestTriggerAll() {
+	suite.NoError(suite.scheduler.Reload())
+	suite.NoError(suite.scheduler.Pause(scheduledClusterID))
+	suite.runnerService.On("ScheduleExecution", mock.Anything).Return(nil)
+
+	suite.Equal(1, suite.scheduler.TriggerAll())
+	suite.Eventually(func() bool {
+		return suite.scheduler.List()[0].RunningExecution != nil
+	}, time.Second, time.Millisecond*10)
+	suite.runnerService.AssertNumberOfCalls(suite.T(), "ScheduleExecution", 1)
+	suite.Nil(suite.scheduler.List()[1].RunningExecution)
+}
+
+func (suite *SchedulerTestSuite) TestTrigger_Error() {
+	suite.NoError(suite.scheduler.Reload())
+	suite.runnerService.On("ScheduleExecution", mock.Anything).Return(ErrDraining)
+
+	suite.scheduler.trigger(dailyClusterID)
+	suite.Nil(suite.scheduler.List()[0].RunningExecution)
+}
+
+func (suite *SchedulerTestSuite) TestTrigger_Stale() {
+	defer func(timeout time.Duration) { scheduledExecutionTimeout = timeout }(scheduledExecutionTimeout)
+	scheduledExecutionTimeout = 0
+
+	suite.NoError(suite.scheduler.Reload())
+	suite.runnerService.On("ScheduleExecution", mock.Anything).Return(nil)
+
+	suite.scheduler.trigger(dailyClusterID)
+	suite.scheduler.trigger(dailyClusterID)
+	suite.runnerService.AssertNumberOfCalls(suite.T(), "ScheduleExecution", 2)
+}
+
+func (suite *SchedulerTestSuite) TestRun() {
+	suite.runnerService.On("ScheduleExecution", mock.Anything).Return(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- suite.scheduler.Run(ctx)
+	}()
+
+	suite.Eventually(func() bool {
+		return len(suite.scheduler.List()) == 2
+	}, time.Second, time.Millisecond*10)
+
+	suite.scheduler.trigger(dailyClusterID)
+	executionID := *suite.scheduler.List()[0].RunningExecution
+
+	suite.events.Publish(executionID, executionFinishedEvent, nil)
+	suite.Eventually(func() bool {
+		return suite.scheduler.List()[0].RunningExecution == nil
+	}, time.Second, time.Millisecond*10)
+
+	cancel()
+	suite.NoError(<-done)
+}
+
+func (suite *SchedulerTestSuite) TestRun_InvalidSchedules() {
+	scheduler := NewScheduler(&Config{Schedules: "/not/found.json"}, suite.runnerService, suite.events, nil)
+	suite.Error(scheduler.Run(context.Background()))
+}
@@ -0,0 +1,124 @@
+package runner
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// SchedulesHandler lists the clusters schedules, with their paused and running state
+func SchedulesHandler(scheduler *Scheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if scheduler == nil {
+			c.AbortWithStatusJSON(404, gin.H{"status": "nok", "message": "executions scheduler is disabled"})
+			return
+		}
+
+		c.JSON(200, scheduler.List())
+	}
+}
+
+// SchedulesTriggerHandler starts the scheduled executions of all the clusters now, without waiting for their schedules
+func SchedulesTriggerHandler(scheduler *Scheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if scheduler == nil {
+			c.AbortWithStatusJSON(404, gin.H{"status": "nok", "message": "executions scheduler is disabled"})
+			return
+		}
+
+		c.JSON(202, gin.H{"status": "ok", "clusters": scheduler.TriggerAll()})
+	}
+}
+
+// SchedulesReloadHandler reads the schedules from the source and applies them now, even if they are not modified,
+// without waiting for the next refresh
+func SchedulesReloadHandler(scheduler *Scheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if scheduler == nil {
+			c.AbortWithStatusJSON(404, gin.H{"status": "nok", "message": "executions scheduler is disabled"})
+			return
+		}
+
+		if err := scheduler.Reload(); err != nil {
+			c.AbortWithStatusJSON(500, gin.H{"status": "nok", "message": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{"status": "ok", "clusters": len(scheduler.List())})
+	}
+}
+
+// SchedulePauseHandler stops the scheduled executions of the cluster until it is resumed
+func SchedulePauseHandler(scheduler *Scheduler) gin.HandlerFunc {
+	return scheduleStateHandler(scheduler, (*Scheduler).Pause)
+}
+
+func ScheduleResumeHandler(scheduler *Scheduler) gin.HandlerFunc {
+	return scheduleStateHandler(scheduler, (*Scheduler).Resume)
+}
+
+// ScheduleUpdateHandler changes the cron expression and the jitter of the cluster schedule without restarting
+// the runner, e.g. when they are changed in the Trento server
+func ScheduleUpdateHandler(scheduler *Scheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if scheduler == nil {
+			c.AbortWithStatusJSON(404, gin.H{"status": "nok", "message": "executions scheduler is disabled"})
+			return
+		}
+
+		clusterID, err := uuid.Parse(c.Param("cluster_id"))
+		if err != nil {
+			c.AbortWithStatusJSON(400, gin.H{"status": "nok", "message": "invalid cluster id"})
+			return
+		}
+
+		var update scheduleUpdate
+		if err := c.ShouldBindJSON(&update); err != nil {
+			c.AbortWithStatusJSON(400, gin.H{"status": "nok", "message": err.Error()})
+			return
+		}
+
+		err = scheduler.Update(clusterID, update.Cron, update.Jitter)
+		switch {
+		case errors.Is(err, ErrScheduleNotFound):
+			c.AbortWithStatusJSON(404, gin.H{"status": "nok", "message": err.Error()})
+			return
+		case errors.Is(err, ErrInvalidSchedule):
+			c.AbortWithStatusJSON(400, gin.H{"status": "nok", "message": err.Error()})
+			return
+		case err != nil:
+			c.AbortWithStatusJSON(500, gin.H{"status": "nok", "message": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{"status": "ok"})
+	}
+}
+
+func scheduleStateHandler(scheduler *Scheduler, setState func(*Scheduler, uuid.UUID) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if scheduler == nil {
+			c.AbortWithStatusJSON(404, gin.H{"status": "nok", "message": "executions scheduler is disabled"})
+			return
+		}
+
+		clusterID, err := uuid.Parse(c.Param("cluster_id"))
+		if err != nil {
+			c.AbortWithStatusJSON(400, gin.H{"status": "nok", "message": "invalid cluster id"})
+			return
+		}
+
+		err = setState(scheduler, clusterID)
+		if errors.Is(err, ErrScheduleNotFound) {
+			c.AbortWithStatusJSON(404, gin.H{"status": "nok", "message": err.Error()})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(500, gin.H{"status": "nok", "message": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{"status": "ok"})
+	}
+}
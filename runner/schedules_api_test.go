@@ -0,0 +1,182 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type SchedulesApiTestCase struct {
+	suite.Suite
+	config *Config
+}
+
+func TestSchedulesApiTestCase(t *testing.T) {
+	suite.Run(t, new(SchedulesApiTestCase))
+}
+
+func (suite *SchedulesApiTestCase) SetupTest() {
+	suite.config = &Config{Schedules: TestSchedulesFile}
+}
+
+func (suite *SchedulesApiTestCase) newApp(scheduler *Scheduler) *App {
+	deps := setupTestDependencies()
+	deps.scheduler = scheduler
+
+	app, err := NewAppWithDeps(suite.config, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	return app
+}
+
+func (suite *SchedulesApiTestCase) Test_Schedules() {
+	scheduler := NewScheduler(suite.config, new(MockRunnerService), NewEventsBroadcaster(), nil)
+	suite.NoError(scheduler.Reload())
+	app := suite.newApp(scheduler)
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", fmt.Sprintf("/api/schedules/%s/pause", scheduledClusterID), nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(200, resp.Code)
+	suite.JSONEq(`{"status": "ok"}`, resp.Body.String())
+
+	resp = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/api/schedules", nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	var statuses []*ScheduleStatus
+	json.Unmarshal(resp.Body.Bytes(), &statuses)
+	suite.Equal(200, resp.Code)
+	suite.Len(statuses, 2)
+	suite.Equal(dailyClusterID, statuses[0].ClusterID)
+	suite.Equal("@daily", statuses[0].Cron)
+	suite.False(statuses[0].Paused)
+	suite.Equal(scheduledClusterID, statuses[1].ClusterID)
+	suite.Equal("5m", statuses[1].Jitter)
+	suite.True(statuses[1].Paused)
+
+	resp = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", fmt.Sprintf("/api/schedules/%s/resume", scheduledClusterID), nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(200, resp.Code)
+	suite.False(scheduler.List()[1].Paused)
+}
+
+func (suite *SchedulesApiTestCase) Test_Schedules_Errors() {
+	scheduler := NewScheduler(suite.config, new(MockRunnerService), NewEventsBroadcaster(), nil)
+	suite.NoError(scheduler.Reload())
+	app := suite.newApp(scheduler)
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/schedules/invalid/pause", nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(400, resp.Code)
+	suite.JSONEq(`{"status": "nok", "message": "invalid cluster id"}`, resp.Body.String())
+
+	resp = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", fmt.Sprintf("/api/schedules/%s/resume", uuid.New()), nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(404, resp.Code)
+	suite.JSONEq(`{"status": "nok", "message": "The cluster does not have a schedule"}`, resp.Body.String())
+}
+
+func (suite *SchedulesApiTestCase) Test_ScheduleUpdate() {
+	scheduler := NewScheduler(suite.config, new(MockRunnerService), NewEventsBroadcaster(), nil)
+	suite.NoError(scheduler.Reload())
+	app := suite.newApp(scheduler)
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/api/schedules/%s", dailyClusterID),
+		strings.NewReader(`{"cron": "@hourly", "jitter": "1m"}`))
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(200, resp.Code)
+	suite.JSONEq(`{"status": "ok"}`, resp.Body.String())
+	suite.Equal("@hourly", scheduler.List()[0].Cron)
+	suite.Equal("1m", scheduler.List()[0].Jitter)
+
+	resp = httptest.NewRecorder()
+	req = httptest.NewRequest("PUT", fmt.Sprintf("/api/schedules/%s", dailyClusterID),
+		strings.NewReader(`{"cron": "every minute"}`))
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(400, resp.Code)
+	suite.Equal("@hourly", scheduler.List()[0].Cron)
+
+	resp = httptest.NewRecorder()
+	req = httptest.NewRequest("PUT", fmt.Sprintf("/api/schedules/%s", dailyClusterID), strings.NewReader(`{}`))
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(400, resp.Code)
+
+	resp = httptest.NewRecorder()
+	req = httptest.NewRequest("PUT", fmt.Sprintf("/api/schedules/%s", uuid.New()),
+		strings.NewReader(`{"cron": "@hourly"}`))
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(404, resp.Code)
+	suite.JSONEq(`{"status": "nok", "message": "The cluster does not have a schedule"}`, resp.Body.String())
+}
+
+func (suite *SchedulesApiTestCase) Test_SchedulesTrigger() {
+	runnerService := new(MockRunnerService)
+	runnerService.On("ScheduleExecution", mock.Anything).Return(nil)
+	scheduler := NewScheduler(suite.config, runnerService, NewEventsBroadcaster(), nil)
+	suite.NoError(scheduler.Reload())
+	app := suite.newApp(scheduler)
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/executions/trigger", nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(202, resp.Code)
+	suite.JSONEq(`{"status": "ok", "clusters": 2}`, resp.Body.String())
+	suite.Eventually(func() bool {
+		return scheduler.List()[0].RunningExecution != nil
+	}, time.Second, time.Millisecond*10)
+
+	suite.Equal(ErrSchedulerDisabled, suite.newApp(nil).TriggerSchedules())
+}
+
+func (suite *SchedulesApiTestCase) Test_SchedulesReload() {
+	scheduler := NewScheduler(suite.config, new(MockRunnerService), NewEventsBroadcaster(), nil)
+	app := suite.newApp(scheduler)
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/schedules/reload", nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(200, resp.Code)
+	suite.JSONEq(`{"status": "ok", "clusters": 2}`, resp.Body.String())
+
+	scheduler = NewScheduler(&Config{Schedules: "/not/found.json"}, new(MockRunnerService), NewEventsBroadcaster(), nil)
+	app = suite.newApp(scheduler)
+
+	resp = httptest.NewRecorder()
+	app.webEngine.ServeHTTP(resp, httptest.NewRequest("POST", "/api/schedules/reload", nil))
+	suite.Equal(500, resp.Code)
+}
+
+func (suite *SchedulesApiTestCase) Test_Schedules_Disabled() {
+	app := suite.newApp(nil)
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/schedules", nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	suite.Equal(404, resp.Code)
+	suite.JSONEq(`{"status": "nok", "message": "executions scheduler is disabled"}`, resp.Body.String())
+}
@@ -0,0 +1,274 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	FileSecretsProvider       = "file"
+	VaultSecretsProvider      = "vault"
+	KubernetesSecretsProvider = "kubernetes"
+
+	// Names of the secrets read from the secrets providers
+	SSHPrivateKeySecret = "ssh-private-key"
+	SSHPassphraseSecret = "ssh-passphrase"
+	VaultPasswordSecret = "vault-password"
+	ServerTokenSecret   = "server-token"
+	ServerApiKeySecret  = "server-api-key"
+	WebhookSecretSecret = "webhook-secret"
+	RedisPasswordSecret = "redis-password"
+
+	vaultTokenHeader = "X-Vault-Token"
+)
+
+var ErrSecretNotFound = errors.New("The secret is not available in the secrets provider")
+
+var secretsRequestTimeout = time.Second * 10
+
+// SecretsProvider reads the credentials used by the runner from a secrets store
+type SecretsProvider interface {
+	// GetSecret returns the secret with the given name, or ErrSecretNotFound if the store does not have it
+	GetSecret(name string) (string, error)
+}
+
+// NewSecretsProvider returns the configured secrets provider, or nil if the file one is used,
+// as these secrets are already read from the settings and their files
+func NewSecretsProvider(config *Config) (SecretsProvider, error) {
+	switch config.SecretsProvider {
+	case "", FileSecretsProvider:
+		return nil, nil
+	case KubernetesSecretsProvider:
+		return &kubernetesSecretsProvider{dir: config.SecretsDir}, nil
+	case VaultSecretsProvider:
+		return &vaultSecretsProvider{
+			addr:       strings.TrimSuffix(config.SecretsVaultAddr, "/"),
+			roleID:     config.SecretsVaultRoleID,
+			secretID:   config.SecretsVaultSecretID,
+			path:       strings.Trim(config.SecretsVaultPath, "/"),
+			httpClient: &http.Client{Transport: newHTTPTransport(config), Timeout: secretsRequestTimeout},
+		}, nil
+	default:
+		return nil, fmt.Errorf("secrets provider %s is not supported", config.SecretsProvider)
+	}
+}
+
+// LoadSecrets replaces the credentials in the config with the ones in the secrets provider.
+// Ansible reads the private key and the vault password from files, so these secrets are written
+// in a private temporary folder, removed by the returned cleanup function
+func LoadSecrets(config *Config) (func(), error) {
+	cleanup := func() {}
+
+	provider, err := NewSecretsProvider(config)
+	if err != nil || provider == nil {
+		return cleanup, err
+	}
+
+	values := []struct {
+		name  string
+		value *string
+		file  *string
+	}{
+		{SSHPassphraseSecret, &config.SSHPassphrase, &config.SSHPassphraseFile},
+		{ServerTokenSecret, &config.ServerToken, &config.ServerTokenFile},
+		{ServerApiKeySecret, &config.ServerApiKey, &config.ServerApiKeyFile},
+		{WebhookSecretSecret, &config.WebhookSecret, nil},
+		{RedisPasswordSecret, &config.AnsibleFactCacheRedisPassword, nil},
+	}
+	for _, secret := range values {
+		value, err := getOptionalSecret(provider, secret.name)
+		if err != nil {
+			return cleanup, err
+		}
+		if value == "" {
+			continue
+		}
+		*secret.value = value
+		if secret.file != nil {
+			*secret.file = ""
+		}
+	}
+
+	files := []struct {
+		name string
+		file *string
+	}{
+		{SSHPrivateKeySecret, &config.SSHPrivateKeyFile},
+		{VaultPasswordSecret, &config.VaultPasswordFile},
+	}
+	secretsDir := ""
+	for _, secret := range files {
+		value, err := getOptionalSecret(provider, secret.name)
+		if err != nil {
+			return cleanup, err
+		}
+		if value == "" {
+			continue
+		}
+
+		if secretsDir == "" {
+			secretsDir, err = ioutil.TempDir("", "trento-runner-secrets")
+			if err != nil {
+				return cleanup, err
+			}
+			dir := secretsDir
+			cleanup = func() { os.RemoveAll(dir) }
+		}
+
+		// The private keys must end with a new line, ssh rejects them otherwise
+		secretFile := path.Join(secretsDir, secret.name)
+		if err := ioutil.WriteFile(secretFile, []byte(value+"\n"), 0600); err != nil {
+			cleanup()
+			return func() {}, err
+		}
+		*secret.file = secretFile
+	}
+
+	log.Infof("Credentials loaded from the %s secrets provider", config.SecretsProvider)
+
+	return cleanup, nil
+}
+
+func getOptionalSecret(provider SecretsProvider, name string) (string, error) {
+	value, err := provider.GetSecret(name)
+	if errors.Is(err, ErrSecretNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot read the %s secret: %s", name, err)
+	}
+
+	return value, nil
+}
+
+// kubernetesSecretsProvider reads the secrets of a mounted kubernetes secret, where each key is a file
+type kubernetesSecretsProvider struct {
+	dir string
+}
+
+func (p *kubernetesSecretsProvider) GetSecret(name string) (string, error) {
+	content, err := ioutil.ReadFile(path.Join(p.dir, name))
+	if os.IsNotExist(err) {
+		return "", ErrSecretNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(content)), nil
+}
+
+// vaultSecretsProvider reads the secrets of a HashiCorp Vault KV secret, logging in with the AppRole
+// credentials. Both KV versions are supported, the secret is read once and its keys are the secrets names
+type vaultSecretsProvider struct {
+	addr       string
+	roleID     string
+	secretID   string
+	path       string
+	httpClient *http.Client
+
+	once    sync.Once
+	secrets map[string]interface{}
+	err     error
+}
+
+type vaultLoginResponse struct {
+	Auth *struct {
+		ClientToken string `json:"client_token"`
+	} `json:"auth"`
+}
+
+type vaultSecretResponse struct {
+	Data map[string]interface{} `json:"data"`
+}
+
+func (p *vaultSecretsProvider) GetSecret(name string) (string, error) {
+	p.once.Do(func() {
+		p.secrets, p.err = p.readSecrets()
+	})
+	if p.err != nil {
+		return "", p.err
+	}
+
+	value, ok := p.secrets[name]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+
+	return strings.TrimSpace(fmt.Sprint(value)), nil
+}
+
+func (p *vaultSecretsProvider) readSecrets() (map[string]interface{}, error) {
+	token, err := p.login()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, p.addr+"/v1/"+p.path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(vaultTokenHeader, token)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot read the vault secret %s, status code: %d", p.path, resp.StatusCode)
+	}
+
+	var secret vaultSecretResponse
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return nil, err
+	}
+
+	// The KV version 2 secrets have the values in a nested data field, next to their metadata
+	if data, ok := secret.Data["data"].(map[string]interface{}); ok {
+		if _, ok := secret.Data["metadata"]; ok {
+			return data, nil
+		}
+	}
+
+	return secret.Data, nil
+}
+
+func (p *vaultSecretsProvider) login() (string, error) {
+	requestBody, err := json.Marshal(map[string]string{"role_id": p.roleID, "secret_id": p.secretID})
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := p.httpClient.Post(p.addr+"/v1/auth/approle/login", "application/json", bytes.NewBuffer(requestBody))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("cannot log in to vault with the approle credentials, status code: %d", resp.StatusCode)
+	}
+
+	var login vaultLoginResponse
+	if err := json.NewDecoder(resp.Body).Decode(&login); err != nil {
+		return "", err
+	}
+	if login.Auth == nil || login.Auth.ClientToken == "" {
+		return "", fmt.Errorf("the client token is missing in the vault login response")
+	}
+
+	return login.Auth.ClientToken, nil
+}
@@ -0,0 +1,239 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	apiKeyHeader        = "X-Api-Key"
+	authorizationHeader = "Authorization"
+	bearerPrefix        = "Bearer "
+)
+
+// The access tokens are refreshed this time before they expire
+var accessTokenExpiryMargin = time.Second * 30
+var authRequestTimeout = time.Second * 10
+
+// NewServerTransport returns the http transport used in the Trento server requests, with the configured TLS settings,
+// the authentication credentials and a circuit breaker shared by all the requests
+func NewServerTransport(config *Config) (http.RoundTripper, error) {
+	tlsConfig, err := NewServerTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	httpTransport := newHTTPTransport(config)
+	if tlsConfig != nil {
+		httpTransport.TLSClientConfig = tlsConfig
+	}
+	var transport http.RoundTripper = &breakerTransport{base: httpTransport, breaker: NewCircuitBreaker("Trento server")}
+
+	if config.ServerToken == "" && config.ServerTokenFile == "" &&
+		config.ServerApiKey == "" && config.ServerApiKeyFile == "" {
+		return transport, nil
+	}
+
+	auth := &serverAuthTransport{
+		base:       transport,
+		config:     config,
+		authClient: &http.Client{Transport: transport, Timeout: authRequestTimeout},
+	}
+	if err := auth.refresh(); err != nil {
+		if config.ServerAuthUrl == "" {
+			return nil, err
+		}
+		// The auth server might not be available yet, the token is requested again in the first request
+		log.Errorf("Error getting the Trento server access token: %s", err)
+		auth.expiresAt = time.Now()
+	}
+
+	return auth, nil
+}
+
+// serverTransportBreaker returns the circuit breaker of a transport returned by NewServerTransport
+func serverTransportBreaker(transport http.RoundTripper) *CircuitBreaker {
+	if auth, ok := transport.(*serverAuthTransport); ok {
+		transport = auth.base
+	}
+	if breaker, ok := transport.(*breakerTransport); ok {
+		return breaker.breaker
+	}
+
+	return nil
+}
+
+// serverAuthTransport authenticates the requests with a bearer token or an API key. If an auth url is
+// configured, the API key is exchanged by an access token, which is refreshed when it expires.
+// The credentials are read again when the server rejects them, picking up the rotated secrets files
+type serverAuthTransport struct {
+	base       http.RoundTripper
+	config     *Config
+	authClient *http.Client
+
+	mu        sync.Mutex
+	header    string
+	value     string
+	expiresAt time.Time
+}
+
+type accessTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+func (a *serverAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	a.mu.Lock()
+	expired := !a.expiresAt.IsZero() && time.Now().After(a.expiresAt.Add(-accessTokenExpiryMargin))
+	a.mu.Unlock()
+
+	if expired {
+		if err := a.refresh(); err != nil {
+			log.Errorf("Error refreshing the Trento server access token: %s", err)
+		}
+	}
+
+	resp, err := a.base.RoundTrip(a.authenticate(req))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	// The request cannot be sent again if its body cannot be read again
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+
+	log.Warnf("The Trento server rejected the credentials, refreshing them")
+	previous := a.credentials()
+	if err := a.refresh(); err != nil {
+		log.Errorf("Error refreshing the Trento server credentials: %s", err)
+		return resp, nil
+	}
+	if a.credentials() == previous {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retry.Body = body
+	}
+
+	return a.base.RoundTrip(a.authenticate(retry))
+}
+
+func (a *serverAuthTransport) authenticate(req *http.Request) *http.Request {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	authenticated := req.Clone(req.Context())
+	if a.header != "" {
+		authenticated.Header.Set(a.header, a.value)
+	}
+
+	return authenticated
+}
+
+// refresh reads the credentials, getting a new access token from the auth url if it is configured
+func (a *serverAuthTransport) refresh() error {
+	if a.config.ServerToken != "" || a.config.ServerTokenFile != "" {
+		token, err := readSecret(a.config.ServerToken, a.config.ServerTokenFile)
+		if err != nil {
+			return err
+		}
+		a.setCredentials(authorizationHeader, bearerPrefix+token, time.Time{})
+		return nil
+	}
+
+	apiKey, err := readSecret(a.config.ServerApiKey, a.config.ServerApiKeyFile)
+	if err != nil {
+		return err
+	}
+
+	if a.config.ServerAuthUrl == "" {
+		a.setCredentials(apiKeyHeader, apiKey, time.Time{})
+		return nil
+	}
+
+	token, err := a.requestAccessToken(apiKey)
+	if err != nil {
+		return err
+	}
+
+	var expiresAt time.Time
+	if token.ExpiresIn > 0 {
+		expiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	a.setCredentials(authorizationHeader, bearerPrefix+token.AccessToken, expiresAt)
+
+	return nil
+}
+
+func (a *serverAuthTransport) requestAccessToken(apiKey string) (*accessTokenResponse, error) {
+	requestBody, err := json.Marshal(map[string]string{"api_key": apiKey})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := a.authClient.Post(a.config.ServerAuthUrl, "application/json", bytes.NewBuffer(requestBody))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot get an access token from %s, status code: %d", a.config.ServerAuthUrl, resp.StatusCode)
+	}
+
+	var token *accessTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, err
+	}
+	if token == nil || token.AccessToken == "" {
+		return nil, fmt.Errorf("the access token is missing in the %s response", a.config.ServerAuthUrl)
+	}
+
+	return token, nil
+}
+
+func (a *serverAuthTransport) credentials() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.header + ":" + a.value
+}
+
+func (a *serverAuthTransport) setCredentials(header, value string, expiresAt time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.header = header
+	a.value = value
+	a.expiresAt = expiresAt
+}
+
+// readSecret returns the given secret, or the content of the secret file if it is set
+func readSecret(secret, secretFile string) (string, error) {
+	if secretFile == "" {
+		return secret, nil
+	}
+
+	content, err := ioutil.ReadFile(secretFile)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(content)), nil
+}
@@ -0,0 +1,164 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+)
+
+type ServerAuthTestSuite struct {
+	suite.Suite
+	tmpDir          string
+	server          *httptest.Server
+	mu              sync.Mutex
+	validCredential string
+	tokensIssued    int
+	callbacks       []string
+}
+
+func TestServerAuthTestSuite(t *testing.T) {
+	suite.Run(t, new(ServerAuthTestSuite))
+}
+
+func (suite *ServerAuthTestSuite) SetupTest() {
+	suite.tmpDir, _ = ioutil.TempDir(os.TempDir(), "trentotest")
+	suite.tokensIssued = 0
+	suite.callbacks = []string{}
+
+	suite.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		suite.mu.Lock()
+		defer suite.mu.Unlock()
+
+		switch r.URL.Path {
+		case "/api/session":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["api_key"] != "key" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			suite.tokensIssued++
+			token := fmt.Sprintf("token%d", suite.tokensIssued)
+			suite.validCredential = "Bearer " + token
+			json.NewEncoder(w).Encode(map[string]interface{}{"access_token": token, "expires_in": 3600})
+		case "/api/runner/callbacks":
+			credential := r.Header.Get("Authorization")
+			if apiKey := r.Header.Get("X-Api-Key"); apiKey != "" {
+				credential = "key " + apiKey
+			}
+			if credential != suite.validCredential {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			suite.callbacks = append(suite.callbacks, body["event"].(string))
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+}
+
+func (suite *ServerAuthTestSuite) TearDownTest() {
+	suite.server.Close()
+	os.RemoveAll(suite.tmpDir)
+}
+
+func (suite *ServerAuthTestSuite) setValidCredential(credential string) {
+	suite.mu.Lock()
+	defer suite.mu.Unlock()
+
+	suite.validCredential = credential
+}
+
+func (suite *ServerAuthTestSuite) newClient(config *Config) *callbacksClient {
+	transport, err := NewServerTransport(config)
+	suite.NoError(err)
+
+	return NewCallbacksClient(suite.server.URL+"/api/runner/callbacks", transport)
+}
+
+func (suite *ServerAuthTestSuite) TestNoCredentials() {
+	transport, err := NewServerTransport(&Config{})
+	suite.NoError(err)
+	suite.IsType(&breakerTransport{}, transport)
+}
+
+func (suite *ServerAuthTestSuite) TestToken() {
+	suite.setValidCredential("Bearer secret")
+	client := suite.newClient(&Config{ServerToken: "secret"})
+
+	suite.NoError(client.Callback(uuid.New(), "execution_started", nil))
+	suite.Equal([]string{"execution_started"}, suite.callbacks)
+}
+
+func (suite *ServerAuthTestSuite) TestApiKey() {
+	suite.setValidCredential("key secret")
+	client := suite.newClient(&Config{ServerApiKey: "secret"})
+
+	suite.NoError(client.Callback(uuid.New(), "execution_started", nil))
+
+	suite.setValidCredential("key other")
+	suite.Error(client.Callback(uuid.New(), "execution_finished", nil))
+	suite.Equal([]string{"execution_started"}, suite.callbacks)
+}
+
+func (suite *ServerAuthTestSuite) TestTokenFileRotated() {
+	tokenFile := path.Join(suite.tmpDir, "token")
+	ioutil.WriteFile(tokenFile, []byte("secret\n"), 0600)
+
+	suite.setValidCredential("Bearer secret")
+	client := suite.newClient(&Config{ServerTokenFile: tokenFile})
+	suite.NoError(client.Callback(uuid.New(), "execution_started", nil))
+
+	ioutil.WriteFile(tokenFile, []byte("rotated\n"), 0600)
+	suite.setValidCredential("Bearer rotated")
+	suite.NoError(client.Callback(uuid.New(), "execution_finished", nil))
+
+	suite.Equal([]string{"execution_started", "execution_finished"}, suite.callbacks)
+}
+
+func (suite *ServerAuthTestSuite) TestTokenFileNotFound() {
+	_, err := NewServerTransport(&Config{ServerTokenFile: path.Join(suite.tmpDir, "not_found")})
+	suite.Error(err)
+}
+
+func (suite *ServerAuthTestSuite) TestAuthUrl() {
+	client := suite.newClient(&Config{ServerApiKey: "key", ServerAuthUrl: suite.server.URL + "/api/session"})
+	suite.NoError(client.Callback(uuid.New(), "execution_started", nil))
+	suite.Equal(1, suite.tokensIssued)
+
+	// The server revokes the token, a new one is requested and the callback is sent again
+	suite.setValidCredential("Bearer revoked")
+	suite.NoError(client.Callback(uuid.New(), "execution_finished", nil))
+	suite.Equal(2, suite.tokensIssued)
+
+	suite.Equal([]string{"execution_started", "execution_finished"}, suite.callbacks)
+}
+
+func (suite *ServerAuthTestSuite) TestAuthUrl_Expired() {
+	defer func(margin time.Duration) { accessTokenExpiryMargin = margin }(accessTokenExpiryMargin)
+	accessTokenExpiryMargin = time.Hour * 2
+
+	client := suite.newClient(&Config{ServerApiKey: "key", ServerAuthUrl: suite.server.URL + "/api/session"})
+	suite.NoError(client.Callback(uuid.New(), "execution_started", nil))
+	suite.NoError(client.Callback(uuid.New(), "execution_finished", nil))
+
+	suite.Equal(3, suite.tokensIssued)
+}
+
+func (suite *ServerAuthTestSuite) TestAuthUrl_Unavailable() {
+	client := suite.newClient(&Config{ServerApiKey: "other", ServerAuthUrl: suite.server.URL + "/api/session"})
+	suite.Equal(0, suite.tokensIssued)
+	suite.Error(client.Callback(uuid.New(), "execution_started", nil))
+	suite.Empty(suite.callbacks)
+}
@@ -0,0 +1,110 @@
+package runner
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	serverStateUnknown int32 = iota
+	serverStateReachable
+	serverStateUnreachable
+)
+
+// The server is checked again after this interval while it is reachable, and retried with an exponential
+// backoff from the retry interval while it is not
+var serverConnectivityInterval = time.Second * 30
+var serverConnectivityRetryInterval = time.Second
+
+// ServerConnectivity checks in the background if the Trento server accepts connections, so the startup and
+// the readiness checks are not blocked by a server outage. The state changes are logged once
+type ServerConnectivity struct {
+	mu    sync.RWMutex
+	url   string
+	state int32
+}
+
+func NewServerConnectivity(url string) *ServerConnectivity {
+	return &ServerConnectivity{url: url, state: serverStateUnknown}
+}
+
+// SetUrl changes the url of the server, checked again from now on as its state is unknown
+func (s *ServerConnectivity) SetUrl(url string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.url != url {
+		s.url = url
+		atomic.StoreInt32(&s.state, serverStateUnknown)
+	}
+}
+
+func (s *ServerConnectivity) serverUrl() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.url
+}
+
+// Reachable returns the last known state, checking the server now if it was not checked yet
+func (s *ServerConnectivity) Reachable() bool {
+	state := atomic.LoadInt32(&s.state)
+	if state == serverStateUnknown {
+		return s.check(0)
+	}
+
+	return state == serverStateReachable
+}
+
+// Run checks the server until the context is done, retrying sooner while it is not reachable
+func (s *ServerConnectivity) Run(ctx context.Context) {
+	retryInterval := serverConnectivityRetryInterval
+
+	for {
+		wait := serverConnectivityInterval
+		if s.check(retryInterval) {
+			retryInterval = serverConnectivityRetryInterval
+		} else {
+			wait = retryInterval
+			retryInterval *= 2
+			if retryInterval > maxRetryInterval {
+				retryInterval = maxRetryInterval
+			}
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *ServerConnectivity) check(retryInterval time.Duration) bool {
+	url := s.serverUrl()
+	err := checkServerConnectivity(url)
+
+	state := serverStateReachable
+	if err != nil {
+		state = serverStateUnreachable
+	}
+	if previous := atomic.SwapInt32(&s.state, state); previous == state {
+		return err == nil
+	}
+
+	if err != nil {
+		if retryInterval > 0 {
+			log.Warnf("Trento server %s is not reachable, retrying in %s: %s", redactedUrl(url), retryInterval, err)
+		} else {
+			log.Warnf("Trento server %s is not reachable: %s", redactedUrl(url), err)
+		}
+	} else {
+		log.Infof("Trento server %s is reachable", redactedUrl(url))
+	}
+
+	return err == nil
+}
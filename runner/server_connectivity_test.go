@@ -0,0 +1,33 @@
+package runner
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerConnectivity(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	connectivity := NewServerConnectivity(server.URL)
+	assert.True(t, connectivity.Reachable())
+
+	serverConnectivityInterval = time.Millisecond
+	serverConnectivityRetryInterval = time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		connectivity.Run(ctx)
+		close(stopped)
+	}()
+
+	// The outage is found by the next check, without blocking the callers
+	server.Close()
+	assert.Eventually(t, func() bool { return !connectivity.Reachable() }, time.Second, time.Millisecond)
+
+	cancel()
+	<-stopped
+}
@@ -0,0 +1,165 @@
+package runner
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// serverCredentials keeps the CA bundle and the client certificate used to connect to the Trento server,
+// reading the files again once the reload interval is elapsed, so the rotated certificates are picked up
+// without restarting the runner
+type serverCredentials struct {
+	caFile         string
+	certFile       string
+	keyFile        string
+	reloadInterval time.Duration
+
+	mu          sync.Mutex
+	loadedAt    time.Time
+	roots       *x509.CertPool
+	certificate *tls.Certificate
+}
+
+// NewServerTLSConfig returns the TLS configuration of the Trento server clients,
+// or nil if the default one is used
+func NewServerTLSConfig(config *Config) (*tls.Config, error) {
+	if config.ServerCAFile == "" && config.ServerCertFile == "" && !config.ServerInsecureSkipVerify {
+		return nil, nil
+	}
+
+	if config.ServerInsecureSkipVerify && config.ServerCertFile == "" {
+		return &tls.Config{InsecureSkipVerify: true}, nil
+	}
+
+	credentials := &serverCredentials{
+		caFile:         config.ServerCAFile,
+		certFile:       config.ServerCertFile,
+		keyFile:        config.ServerKeyFile,
+		reloadInterval: config.ServerTLSReloadInterval,
+	}
+	if err := credentials.load(); err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		// The server certificate is verified in VerifyConnection with the current CA bundle
+		InsecureSkipVerify: true,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			if config.ServerInsecureSkipVerify {
+				return nil
+			}
+			return credentials.verify(state)
+		},
+	}
+
+	if config.ServerCertFile != "" {
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return credentials.clientCertificate(), nil
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+func (s *serverCredentials) load() error {
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+
+	if s.caFile != "" {
+		content, err := ioutil.ReadFile(s.caFile)
+		if err != nil {
+			return err
+		}
+
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(content) {
+			return fmt.Errorf("no certificates found in the CA bundle %s", s.caFile)
+		}
+	}
+
+	var certificate *tls.Certificate
+	if s.certFile != "" {
+		keyPair, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+		if err != nil {
+			return fmt.Errorf("cannot load the client certificate %s: %s", s.certFile, err)
+		}
+		certificate = &keyPair
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.roots = roots
+	s.certificate = certificate
+	s.loadedAt = time.Now()
+
+	return nil
+}
+
+// reload reads the files again if the reload interval is elapsed, keeping the previous credentials on errors
+func (s *serverCredentials) reload() {
+	if s.reloadInterval <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	expired := time.Since(s.loadedAt) >= s.reloadInterval
+	s.mu.Unlock()
+
+	if !expired {
+		return
+	}
+
+	if err := s.load(); err != nil {
+		log.Errorf("Error reloading the Trento server TLS credentials, keeping the previous ones: %s", err)
+		s.mu.Lock()
+		s.loadedAt = time.Now()
+		s.mu.Unlock()
+	}
+}
+
+func (s *serverCredentials) clientCertificate() *tls.Certificate {
+	s.reload()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.certificate == nil {
+		return &tls.Certificate{}
+	}
+
+	return s.certificate
+}
+
+func (s *serverCredentials) verify(state tls.ConnectionState) error {
+	s.reload()
+
+	s.mu.Lock()
+	roots := s.roots
+	s.mu.Unlock()
+
+	if len(state.PeerCertificates) == 0 {
+		return fmt.Errorf("the Trento server did not send a certificate")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, certificate := range state.PeerCertificates[1:] {
+		intermediates.AddCert(certificate)
+	}
+
+	_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       state.ServerName,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+
+	return err
+}
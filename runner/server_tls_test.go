@@ -0,0 +1,196 @@
+package runner
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+)
+
+type ServerTLSTestSuite struct {
+	suite.Suite
+	tmpDir    string
+	ca        *x509.Certificate
+	caKey     *ecdsa.PrivateKey
+	otherCA   *x509.Certificate
+	otherKey  *ecdsa.PrivateKey
+	server    *httptest.Server
+	clientCNs []string
+}
+
+func TestServerTLSTestSuite(t *testing.T) {
+	suite.Run(t, new(ServerTLSTestSuite))
+}
+
+func (suite *ServerTLSTestSuite) SetupTest() {
+	suite.tmpDir, _ = ioutil.TempDir(os.TempDir(), "trentotest")
+	suite.ca, suite.caKey = suite.newCertificate("ca", nil, nil)
+	suite.otherCA, suite.otherKey = suite.newCertificate("other ca", nil, nil)
+
+	serverCert, serverKey := suite.newCertificate("localhost", suite.ca, suite.caKey)
+	clientPool := x509.NewCertPool()
+	clientPool.AddCert(suite.ca)
+	clientPool.AddCert(suite.otherCA)
+
+	suite.clientCNs = []string{}
+	suite.server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		suite.clientCNs = append(suite.clientCNs, r.TLS.PeerCertificates[0].Subject.CommonName)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	suite.server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{serverCert.Raw}, PrivateKey: serverKey}},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientPool,
+	}
+	suite.server.StartTLS()
+
+	suite.writeCertificate("ca.pem", suite.ca, nil)
+	suite.writeClientCertificate("runner", suite.ca, suite.caKey)
+}
+
+func (suite *ServerTLSTestSuite) TearDownTest() {
+	suite.server.Close()
+	os.RemoveAll(suite.tmpDir)
+}
+
+func (suite *ServerTLSTestSuite) newCertificate(
+	commonName string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{commonName},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+		parent = template
+		parentKey = key
+	}
+
+	content, _ := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	certificate, _ := x509.ParseCertificate(content)
+
+	return certificate, key
+}
+
+func (suite *ServerTLSTestSuite) writeCertificate(name string, certificate *x509.Certificate, key *ecdsa.PrivateKey) {
+	content := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw})
+	ioutil.WriteFile(path.Join(suite.tmpDir, name), content, 0644)
+
+	if key != nil {
+		keyContent, _ := x509.MarshalECPrivateKey(key)
+		content = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyContent})
+		ioutil.WriteFile(path.Join(suite.tmpDir, name+".key"), content, 0600)
+	}
+}
+
+func (suite *ServerTLSTestSuite) writeClientCertificate(
+	commonName string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) {
+
+	certificate, key := suite.newCertificate(commonName, parent, parentKey)
+	suite.writeCertificate("client.pem", certificate, key)
+}
+
+func (suite *ServerTLSTestSuite) config() *Config {
+	return &Config{
+		ServerCAFile:   path.Join(suite.tmpDir, "ca.pem"),
+		ServerCertFile: path.Join(suite.tmpDir, "client.pem"),
+		ServerKeyFile:  path.Join(suite.tmpDir, "client.pem.key"),
+	}
+}
+
+func (suite *ServerTLSTestSuite) TestNewServerTLSConfig_Default() {
+	tlsConfig, err := NewServerTLSConfig(&Config{})
+	suite.NoError(err)
+	suite.Nil(tlsConfig)
+
+	tlsConfig, err = NewServerTLSConfig(&Config{ServerInsecureSkipVerify: true})
+	suite.NoError(err)
+	suite.True(tlsConfig.InsecureSkipVerify)
+	suite.Nil(tlsConfig.VerifyConnection)
+}
+
+func (suite *ServerTLSTestSuite) TestNewServerTLSConfig_Errors() {
+	config := suite.config()
+	config.ServerCAFile = path.Join(suite.tmpDir, "not_found.pem")
+	_, err := NewServerTLSConfig(config)
+	suite.Error(err)
+
+	config = suite.config()
+	config.ServerCAFile = config.ServerKeyFile
+	_, err = NewServerTLSConfig(config)
+	suite.EqualError(err, "no certificates found in the CA bundle "+config.ServerKeyFile)
+
+	config = suite.config()
+	config.ServerKeyFile = config.ServerCAFile
+	_, err = NewServerTLSConfig(config)
+	suite.Contains(err.Error(), "cannot load the client certificate")
+}
+
+func (suite *ServerTLSTestSuite) TestCallbackMutualTLS() {
+	transport, err := NewServerTransport(suite.config())
+	suite.NoError(err)
+
+	client := NewCallbacksClient(suite.server.URL, transport)
+	suite.NoError(client.Callback(uuid.New(), "execution_started", nil))
+	suite.Equal([]string{"runner"}, suite.clientCNs)
+}
+
+func (suite *ServerTLSTestSuite) TestCallbackUnknownServer() {
+	suite.writeCertificate("ca.pem", suite.otherCA, nil)
+
+	transport, err := NewServerTransport(suite.config())
+	suite.NoError(err)
+
+	client := NewCallbacksClient(suite.server.URL, transport)
+	suite.Error(client.Callback(uuid.New(), "execution_started", nil))
+
+	config := suite.config()
+	config.ServerInsecureSkipVerify = true
+	transport, err = NewServerTransport(config)
+	suite.NoError(err)
+
+	client = NewCallbacksClient(suite.server.URL, transport)
+	suite.NoError(client.Callback(uuid.New(), "execution_started", nil))
+}
+
+func (suite *ServerTLSTestSuite) TestCallbackRotatedCertificate() {
+	config := suite.config()
+	config.ServerTLSReloadInterval = time.Millisecond
+	transport, err := NewServerTransport(config)
+	suite.NoError(err)
+
+	client := NewCallbacksClient(suite.server.URL, transport)
+	suite.NoError(client.Callback(uuid.New(), "execution_started", nil))
+
+	suite.writeClientCertificate("rotated runner", suite.otherCA, suite.otherKey)
+	time.Sleep(2 * time.Millisecond)
+	// The rotated certificate is used in the new connections
+	client.httpClient.CloseIdleConnections()
+	suite.NoError(client.Callback(uuid.New(), "execution_started", nil))
+
+	suite.Equal([]string{"runner", "rotated runner"}, suite.clientCNs)
+}
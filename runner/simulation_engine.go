@@ -0,0 +1,150 @@
+package runner
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+const SimulationCheckEngine = "simulation"
+
+// DefaultSimulationResults are the weights of the simulated results if they are not configured
+var DefaultSimulationResults = map[string]int{
+	checkResultPassing:  80,
+	checkResultWarning:  10,
+	checkResultCritical: 10,
+}
+
+// IsSimulatedResult tells if the result can be fabricated by the simulation engine
+func IsSimulatedResult(result string) bool {
+	switch result {
+	case checkResultPassing, checkResultWarning, checkResultCritical, checkResultSkipped:
+		return true
+	}
+
+	return false
+}
+
+// simulationCheckEngine fabricates the checks results instead of connecting to the hosts, for the demo environments
+// and the integration tests of the Trento server without real clusters. The results go through the normal reporting
+// path. The result of a check in a host is the same in every execution, chosen with the results weights, so the
+// simulated landscape is stable while the runner runs
+type simulationCheckEngine struct {
+	results []string
+	weights []int
+	total   int
+	latency time.Duration
+	seed    int64
+}
+
+func NewSimulationCheckEngine(config *Config) *simulationCheckEngine {
+	weights := config.SimulationResults
+	if len(weights) == 0 {
+		weights = DefaultSimulationResults
+	}
+
+	engine := &simulationCheckEngine{latency: config.SimulationLatency, seed: config.SimulationSeed}
+	if engine.seed == 0 {
+		engine.seed = time.Now().UnixNano()
+	}
+
+	// The results are sorted, so the same seed gives the same results
+	for result := range weights {
+		engine.results = append(engine.results, result)
+	}
+	sort.Strings(engine.results)
+	for _, result := range engine.results {
+		engine.weights = append(engine.weights, weights[result])
+		engine.total += weights[result]
+	}
+
+	return engine
+}
+
+func (s *simulationCheckEngine) Run(
+	ctx context.Context, e *ExecutionEvent, outputHandler func(stream, line string)) (*ExecutionResults, error) {
+
+	// There are no files to write in the dry runs
+	if e.DryRun {
+		return nil, nil
+	}
+
+	hosts := e.targetHosts()
+	hostsResults := make([]*HostResults, len(hosts))
+
+	var wg sync.WaitGroup
+	for index, host := range hosts {
+		wg.Add(1)
+		go func(index int, host *Host) {
+			defer wg.Done()
+			hostsResults[index] = s.runHost(ctx, host, e.hostChecks(host), outputHandler)
+		}(index, host)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	return &ExecutionResults{ClusterID: e.ClusterID.String(), Hosts: hostsResults}, nil
+}
+
+// runHost waits the latency, between half and one and a half times the configured one, and returns the host results
+func (s *simulationCheckEngine) runHost(
+	ctx context.Context, host *Host, checks []string, outputHandler func(stream, line string)) *HostResults {
+
+	hostID := host.HostID.String()
+	hostResults := &HostResults{HostID: hostID, Reachable: true, Results: []*CheckResult{}}
+	output := func(format string, args ...interface{}) {
+		if outputHandler != nil {
+			outputHandler(StdoutStream, fmt.Sprintf("%s: %s", hostID, fmt.Sprintf(format, args...)))
+		}
+	}
+
+	if s.latency > 0 {
+		latency := s.latency/2 + time.Duration(rand.Int63n(int64(s.latency)+1))
+		select {
+		case <-time.After(latency):
+		case <-ctx.Done():
+			return hostResults
+		}
+	}
+
+	for _, checkID := range checks {
+		result := s.result(hostID, checkID)
+		msg := ""
+		if result != checkResultPassing {
+			msg = fmt.Sprintf("simulated %s result", result)
+		}
+		output("check %s %s (simulated)", checkID, result)
+		hostResults.Results = append(hostResults.Results, &CheckResult{CheckID: checkID, Result: result, Msg: msg})
+	}
+
+	return hostResults
+}
+
+// result chooses the result of the check in the host with the weights, from a hash of the seed, the host and the check
+func (s *simulationCheckEngine) result(hostID, checkID string) string {
+	if s.total <= 0 {
+		return checkResultPassing
+	}
+
+	hash := fnv.New64a()
+	binary.Write(hash, binary.LittleEndian, s.seed)
+	hash.Write([]byte(hostID + "/" + checkID))
+
+	value := int(hash.Sum64() % uint64(s.total))
+	for index, weight := range s.weights {
+		if value < weight {
+			return s.results[index]
+		}
+		value -= weight
+	}
+
+	return checkResultPassing
+}
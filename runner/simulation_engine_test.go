@@ -0,0 +1,91 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimulationCheckEngine(t *testing.T) {
+	event := chunkedTestEvent(3)
+	event.Checks = []string{"156F64", "53D035", "DA114A"}
+	engine := NewSimulationCheckEngine(&Config{SimulationSeed: 42})
+
+	// The hosts run concurrently, so their lines are interleaved in any order
+	var mu sync.Mutex
+	lines := []string{}
+	results, err := engine.Run(context.Background(), event, func(stream, line string) {
+		mu.Lock()
+		lines = append(lines, line)
+		mu.Unlock()
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, event.ClusterID.String(), results.ClusterID)
+	assert.Len(t, results.Hosts, 3)
+	expectedLines := []string{}
+	for index, host := range results.Hosts {
+		assert.Equal(t, event.Hosts[index].HostID.String(), host.HostID)
+		assert.True(t, host.Reachable)
+		assert.Len(t, host.Results, 3)
+		for _, result := range host.Results {
+			expectedLines = append(expectedLines,
+				fmt.Sprintf("%s: check %s %s (simulated)", host.HostID, result.CheckID, result.Result))
+			assert.True(t, IsSimulatedResult(result.Result))
+			if result.Result == checkResultPassing {
+				assert.Empty(t, result.Msg)
+			} else {
+				assert.Equal(t, "simulated "+result.Result+" result", result.Msg)
+			}
+		}
+	}
+	assert.ElementsMatch(t, expectedLines, lines)
+
+	// The same seed gives the same results
+	again, err := NewSimulationCheckEngine(&Config{SimulationSeed: 42}).Run(context.Background(), event, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, results, again)
+}
+
+func TestSimulationCheckEngine_Weights(t *testing.T) {
+	event := chunkedTestEvent(2)
+	engine := NewSimulationCheckEngine(&Config{SimulationResults: map[string]int{"critical": 1, "passing": 0}})
+
+	results, err := engine.Run(context.Background(), event, nil)
+	assert.NoError(t, err)
+	for _, host := range results.Hosts {
+		assert.Equal(t, []*CheckResult{
+			{CheckID: "156F64", Result: checkResultCritical, Msg: "simulated critical result"},
+		}, host.Results)
+	}
+}
+
+func TestSimulationCheckEngine_DryRun(t *testing.T) {
+	event := chunkedTestEvent(2)
+	event.DryRun = true
+
+	results, err := NewSimulationCheckEngine(&Config{}).Run(context.Background(), event, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, results)
+}
+
+func TestSimulationCheckEngine_Canceled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	engine := NewSimulationCheckEngine(&Config{SimulationLatency: time.Minute})
+	results, err := engine.Run(ctx, chunkedTestEvent(2), nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Nil(t, results)
+}
+
+func TestNewCheckEngine_Simulation(t *testing.T) {
+	engine, err := NewCheckEngine(&Config{CheckEngine: "simulation"})
+	assert.NoError(t, err)
+	assert.IsType(t, &simulationCheckEngine{}, engine)
+	assert.Equal(t, []string{"critical", "passing", "warning"}, engine.(*simulationCheckEngine).results)
+	assert.Equal(t, 100, engine.(*simulationCheckEngine).total)
+}
@@ -0,0 +1,195 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/syslog"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	TrentoSinkType = "trento"
+	FileSinkType   = "file"
+	SyslogSinkType = "syslog"
+
+	defaultSyslogTag = "trento-runner"
+)
+
+// Sink is the configuration of an execution sink. The url is used by the trento sinks, the path by the file
+// sinks, and the network, address and tag by the syslog sinks, which use the local syslog or journald by default
+type Sink struct {
+	Type    string
+	Url     string
+	Path    string
+	Network string
+	Address string
+	Tag     string
+}
+
+// ExecutionSink consumes the report of each finished execution, so the results are integrated in other systems
+// without changing how the executions are run
+type ExecutionSink interface {
+	Consume(report *ExecutionReport) error
+}
+
+// NewExecutionSink creates the execution sink of the given configuration. The trento sinks use the server transport
+// and sign the reports if the signer is not nil
+func NewExecutionSink(sink *Sink, transport http.RoundTripper, signer *ResultsSigner) (ExecutionSink, error) {
+	switch sink.Type {
+	case TrentoSinkType:
+		return &trentoSink{url: sink.Url, httpClient: &http.Client{Transport: transport}, signer: signer}, nil
+	case FileSinkType:
+		return &fileSink{path: sink.Path}, nil
+	case SyslogSinkType:
+		tag := sink.Tag
+		if tag == "" {
+			tag = defaultSyslogTag
+		}
+		writer, err := syslog.Dial(sink.Network, sink.Address, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+		if err != nil {
+			return nil, fmt.Errorf("cannot connect to the syslog: %s", err)
+		}
+		return &syslogSink{writer: writer}, nil
+	default:
+		return nil, fmt.Errorf("sink type %s is not supported", sink.Type)
+	}
+}
+
+// sinksClient collects the callbacks of each execution and passes its report to all the sinks once it is finished.
+// A failing sink does not prevent the other ones from consuming the report
+type sinksClient struct {
+	*reportCollector
+	sinks []ExecutionSink
+}
+
+func NewSinksClient(sinks ...ExecutionSink) *sinksClient {
+	return &sinksClient{reportCollector: newReportCollector(), sinks: sinks}
+}
+
+func (s *sinksClient) Callback(executionID uuid.UUID, event string, payload interface{}) error {
+	report, err := s.collect(executionID, event, payload)
+	if err != nil || report == nil {
+		return err
+	}
+
+	for _, sink := range s.sinks {
+		if err := sink.Consume(report); err != nil {
+			log.Errorf("Error consuming the report of execution %s in the %T sink: %s",
+				executionID.String(), sink, err)
+		}
+	}
+
+	return nil
+}
+
+// trentoSink posts each report to the Trento API
+type trentoSink struct {
+	url        string
+	httpClient *http.Client
+	signer     *ResultsSigner
+}
+
+func (t *trentoSink) Consume(report *ExecutionReport) error {
+	requestBody, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, t.url, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if t.signer != nil {
+		signature, err := t.signer.Sign(requestBody)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(resultsSignatureHeader, signature)
+	}
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("cannot send the report of execution %s, status code: %d",
+			report.ExecutionID.String(), resp.StatusCode)
+	}
+
+	return nil
+}
+
+// fileSink appends each report to a local file, as a json line
+type fileSink struct {
+	path string
+	mu   sync.Mutex
+}
+
+func (f *fileSink) Consume(report *ExecutionReport) error {
+	content, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := os.MkdirAll(path.Dir(f.path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(content, '\n'))
+
+	return err
+}
+
+// syslogSink logs a summary of each report in the syslog, with the error priority if the execution failed
+type syslogSink struct {
+	writer *syslog.Writer
+}
+
+// syslogMessage is the summary of the report logged in the syslog, as the full report may be too long for a message
+type syslogMessage struct {
+	ExecutionID uuid.UUID      `json:"execution_id"`
+	ClusterID   string         `json:"cluster_id"`
+	Status      string         `json:"status"`
+	Reason      string         `json:"reason,omitempty"`
+	Summary     map[string]int `json:"summary"`
+}
+
+func (s *syslogSink) Consume(report *ExecutionReport) error {
+	results := &ExecutionResults{ClusterID: report.ClusterID, Hosts: report.Hosts}
+	content, err := json.Marshal(&syslogMessage{
+		ExecutionID: report.ExecutionID,
+		ClusterID:   report.ClusterID,
+		Status:      report.Status,
+		Reason:      report.Reason,
+		Summary:     results.Summary(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if report.Status == ExecutionFailed {
+		return s.writer.Err(string(content))
+	}
+
+	return s.writer.Info(string(content))
+}
@@ -0,0 +1,144 @@
+package runner
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// sinkFunc consumes the reports with the given function
+type sinkFunc func(report *ExecutionReport) error
+
+func (f sinkFunc) Consume(report *ExecutionReport) error {
+	return f(report)
+}
+
+func TestSinksClient(t *testing.T) {
+	reports := []*ExecutionReport{}
+	failingSink := sinkFunc(func(report *ExecutionReport) error {
+		return os.ErrPermission
+	})
+	sink := sinkFunc(func(report *ExecutionReport) error {
+		reports = append(reports, report)
+		return nil
+	})
+	client := NewSinksClient(failingSink, sink)
+
+	executionID := uuid.New()
+	clusterID := uuid.New().String()
+	assert.NoError(t, client.Callback(executionID, executionStartedEvent, map[string]string{"cluster_id": clusterID}))
+	assert.NoError(t, client.Callback(executionID, hostCompletedEvent, map[string]interface{}{
+		"cluster_id": clusterID, "host_id": "host1", "reachable": true, "msg": ""}))
+	assert.NoError(t, client.Callback(executionID, checkResultEvent, map[string]interface{}{
+		"cluster_id": clusterID, "host_id": "host1", "check_id": "156F64", "result": "passing", "msg": ""}))
+	assert.Empty(t, reports)
+
+	// The report is consumed by the other sinks even if one of them fails
+	assert.NoError(t, client.Callback(executionID, executionFinishedEvent, map[string]string{"cluster_id": clusterID}))
+	assert.Len(t, reports, 1)
+	assert.Equal(t, executionID, reports[0].ExecutionID)
+	assert.Equal(t, ExecutionCompleted, reports[0].Status)
+	assert.Equal(t, "156F64", reports[0].Hosts[0].Results[0].CheckID)
+	assert.Empty(t, client.executions)
+}
+
+func TestNewExecutionSink_Unsupported(t *testing.T) {
+	_, err := NewExecutionSink(&Sink{Type: "kafka"}, nil, nil)
+	assert.EqualError(t, err, "sink type kafka is not supported")
+}
+
+func TestTrentoSink(t *testing.T) {
+	var body []byte
+	status := http.StatusAccepted
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink, err := NewExecutionSink(&Sink{Type: TrentoSinkType, Url: server.URL + "/api/runner/reports"}, nil, nil)
+	assert.NoError(t, err)
+
+	report := &ExecutionReport{ExecutionID: uuid.New(), ClusterID: "cluster1", Status: ExecutionCompleted, Hosts: []*HostResults{}}
+	assert.NoError(t, sink.Consume(report))
+
+	var received *ExecutionReport
+	assert.NoError(t, json.Unmarshal(body, &received))
+	assert.Equal(t, report.ExecutionID, received.ExecutionID)
+
+	status = http.StatusInternalServerError
+	assert.EqualError(t, sink.Consume(report), "cannot send the report of execution "+report.ExecutionID.String()+", status code: 500")
+}
+
+func TestFileSink(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	file := path.Join(tmpDir, "reports", "executions.json")
+	sink, err := NewExecutionSink(&Sink{Type: FileSinkType, Path: file}, nil, nil)
+	assert.NoError(t, err)
+
+	// The reports are appended, one per line
+	firstID, secondID := uuid.New(), uuid.New()
+	assert.NoError(t, sink.Consume(&ExecutionReport{ExecutionID: firstID, Status: ExecutionCompleted}))
+	assert.NoError(t, sink.Consume(&ExecutionReport{ExecutionID: secondID, Status: ExecutionFailed}))
+
+	content, err := ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	assert.Len(t, lines, 2)
+
+	var report *ExecutionReport
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &report))
+	assert.Equal(t, secondID, report.ExecutionID)
+	assert.Equal(t, ExecutionFailed, report.Status)
+}
+
+func TestSyslogSink(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	socket := path.Join(tmpDir, "syslog")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	sink, err := NewExecutionSink(&Sink{Type: SyslogSinkType, Network: "unixgram", Address: socket}, nil, nil)
+	assert.NoError(t, err)
+
+	executionID := uuid.New()
+	assert.NoError(t, sink.Consume(&ExecutionReport{
+		ExecutionID: executionID,
+		ClusterID:   "cluster1",
+		Status:      ExecutionCompleted,
+		Hosts: []*HostResults{&HostResults{
+			HostID:    "host1",
+			Reachable: true,
+			Results:   []*CheckResult{&CheckResult{CheckID: "156F64", Result: "critical"}},
+		}},
+	}))
+
+	buffer := make([]byte, 4096)
+	n, err := conn.Read(buffer)
+	assert.NoError(t, err)
+	message := string(buffer[:n])
+	assert.True(t, strings.HasPrefix(message, "<30>"))
+	assert.Contains(t, message, defaultSyslogTag)
+	assert.Contains(t, message, `"execution_id":"`+executionID.String()+`"`)
+	assert.Contains(t, message, `"critical":1`)
+
+	assert.NoError(t, sink.Consume(&ExecutionReport{ExecutionID: executionID, Status: ExecutionFailed, Reason: "exit status 2"}))
+	n, err = conn.Read(buffer)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(buffer[:n]), "<27>"))
+}
@@ -0,0 +1,118 @@
+package runner
+
+import (
+	"context"
+	"path"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+// The startup phases of the runner service. The ansible files are prepared while the checks sources are fetched,
+// and the catalog is built once both are done. The executions only wait for the prepared files, not for the catalog
+const (
+	StartupPending         = "pending"
+	StartupPreparing       = "preparing"
+	StartupBuildingCatalog = "building_catalog"
+	StartupReady           = "ready"
+	StartupFailed          = "failed"
+)
+
+var startupStatus = map[string]string{
+	StartupPreparing:       "Preparing the ansible files and fetching the checks sources",
+	StartupBuildingCatalog: "Building the catalog",
+	StartupReady:           "Catalog ready",
+	StartupFailed:          "Startup failed",
+}
+
+// startup tracks the startup phase, closing the prepared channel once the ansible files can be used
+type startup struct {
+	mu           sync.Mutex
+	phase        string
+	prepared     chan struct{}
+	preparedOnce sync.Once
+}
+
+func newStartup() *startup {
+	return &startup{phase: StartupPending, prepared: make(chan struct{})}
+}
+
+func (s *startup) Phase() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.phase
+}
+
+// setPhase logs the transitions between the phases, and reports them to systemd as the unit status
+func (s *startup) setPhase(phase string) {
+	s.mu.Lock()
+	previous := s.phase
+	s.phase = phase
+	s.mu.Unlock()
+
+	if previous == phase {
+		return
+	}
+	log.Infof("Startup phase changed from %s to %s", previous, phase)
+
+	switch phase {
+	case StartupReady:
+		notifySystemd("READY=1\nSTATUS=" + startupStatus[phase])
+	default:
+		notifySystemd("STATUS=" + startupStatus[phase])
+	}
+
+	// The executions waiting for the files are released if the preparation failed, so they fail right away
+	if phase != StartupPreparing {
+		s.preparedOnce.Do(func() { close(s.prepared) })
+	}
+}
+
+// waitPrepared waits for the ansible files while they are being prepared. The runner services used out of the
+// startup, as the ones of the tests, have nothing to wait for
+func (s *startup) waitPrepared(ctx context.Context) error {
+	if s.Phase() != StartupPreparing {
+		return nil
+	}
+
+	select {
+	case <-s.prepared:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// prepareAnsibleFiles extracts the ansible files and checks the ansible dependencies while the checks sources are
+// fetched, e.g. cloned, as they are independent. The fetched checks are then copied over the extracted ones
+func (c *runnerService) prepareAnsibleFiles(ctx context.Context) ([]string, error) {
+	var checksFolders []string
+
+	g, groupCtx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		err := createAnsibleFiles(c.config.AnsibleFolder)
+		if err == nil {
+			err = createAnsibleConfigFile(c.config)
+		}
+		if err == nil {
+			err = CheckAnsibleDependencies(groupCtx, c.config)
+		}
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		checksFolders, err = fetchCatalogSources(groupCtx, NewCatalogSources(c.config))
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	if err := copyCatalogFolders(checksFolders, path.Join(c.config.AnsibleFolder, AnsibleChecks)); err != nil {
+		return nil, err
+	}
+
+	return checksFolders, nil
+}
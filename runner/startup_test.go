@@ -0,0 +1,32 @@
+package runner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStartup_WaitPrepared(t *testing.T) {
+	s := newStartup()
+	assert.Equal(t, StartupPending, s.Phase())
+	// Nothing to wait for out of the startup
+	assert.NoError(t, s.waitPrepared(context.Background()))
+
+	s.setPhase(StartupPreparing)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, s.waitPrepared(ctx))
+
+	waited := make(chan error)
+	go func() {
+		waited <- s.waitPrepared(context.Background())
+	}()
+	s.setPhase(StartupBuildingCatalog)
+	assert.NoError(t, <-waited)
+
+	s.setPhase(StartupReady)
+	assert.Equal(t, StartupReady, s.Phase())
+	assert.NoError(t, s.waitPrepared(context.Background()))
+}
@@ -0,0 +1,183 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const notEntitledMsg = "not entitled, the check requires a premium subscription"
+
+var subscriptionTimeout = time.Second * 10
+
+// The entitlement is requested again after this interval, so the subscriptions changes are noticed
+var subscriptionRefreshInterval = time.Minute * 5
+
+// The entitlement is requested again after this interval when the server fails, doubled after each failure
+// up to the refresh interval
+var subscriptionRetryInterval = time.Second * 10
+
+type subscriptionResponse struct {
+	Premium bool `json:"premium"`
+}
+
+// SubscriptionClient reads the premium entitlement of the Trento server subscription
+type SubscriptionClient struct {
+	url        string
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	premium   bool
+	fetchedAt time.Time
+	failures  int
+	retryAt   time.Time
+	fetching  bool
+}
+
+func NewSubscriptionClient(url string, transport http.RoundTripper) *SubscriptionClient {
+	return &SubscriptionClient{
+		url:        url,
+		httpClient: &http.Client{Transport: transport, Timeout: subscriptionTimeout},
+	}
+}
+
+// IsPremium tells if the subscription includes the premium checks. The last known entitlement is kept
+// if the server cannot be reached, and the subscription is not premium until it is read once.
+// The entitlement is requested by a single caller, the others get the last known one meanwhile
+// instead of waiting for the server
+func (s *SubscriptionClient) IsPremium(ctx context.Context) bool {
+	s.mu.Lock()
+	if s.fetching || !s.refreshDue() {
+		premium := s.premium
+		s.mu.Unlock()
+		return premium
+	}
+	s.fetching = true
+	s.mu.Unlock()
+
+	premium, err := s.fetch(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fetching = false
+	if err != nil {
+		s.failures++
+		retryInterval := subscriptionRetryBackoff(s.failures)
+		s.retryAt = time.Now().Add(retryInterval)
+		log.Errorf("Error reading the subscription from %s, the last known entitlement is used, retrying in %s: %s",
+			s.url, retryInterval, err)
+		return s.premium
+	}
+
+	s.premium = premium
+	s.fetchedAt = time.Now()
+	s.failures = 0
+
+	return s.premium
+}
+
+// refreshDue tells if the entitlement must be requested again. It must be called with the lock held
+func (s *SubscriptionClient) refreshDue() bool {
+	if s.failures > 0 {
+		return !time.Now().Before(s.retryAt)
+	}
+
+	return s.fetchedAt.IsZero() || time.Since(s.fetchedAt) >= subscriptionRefreshInterval
+}
+
+// subscriptionRetryBackoff returns the interval to request the entitlement again after the failures
+func subscriptionRetryBackoff(failures int) time.Duration {
+	interval := subscriptionRetryInterval
+	for i := 1; i < failures && interval < subscriptionRefreshInterval; i++ {
+		interval *= 2
+	}
+	if interval > subscriptionRefreshInterval {
+		interval = subscriptionRefreshInterval
+	}
+
+	return interval
+}
+
+func (s *SubscriptionClient) fetch(ctx context.Context) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("cannot read the subscription, status code: %d", resp.StatusCode)
+	}
+
+	var subscription subscriptionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&subscription); err != nil {
+		return false, err
+	}
+
+	return subscription.Premium, nil
+}
+
+// executionSubscriptionClient returns the subscription client of the Trento server that requested
+// the execution, or nil if its premium checks are not gated
+func (c *runnerService) executionSubscriptionClient(e *ExecutionEvent) *SubscriptionClient {
+	if upstream, ok := c.upstreams[e.Upstream]; ok && e.Upstream != "" {
+		return upstream.subscriptionClient
+	}
+
+	return c.subscriptionClient
+}
+
+// selectEntitledChecks returns a copy of the execution event without the premium checks, if the
+// subscription does not include them, and their skipped results in the target hosts
+func (c *runnerService) selectEntitledChecks(ctx context.Context, e *ExecutionEvent) (*ExecutionEvent, *ExecutionResults) {
+	subscriptionClient := c.executionSubscriptionClient(e)
+	if subscriptionClient == nil {
+		return e, nil
+	}
+
+	premiumChecks := map[string]bool{}
+	if catalog := c.GetCatalog(); catalog != nil {
+		for _, check := range *catalog {
+			if check.Premium {
+				premiumChecks[check.ID] = true
+			}
+		}
+	}
+
+	entitledChecks := []string{}
+	notEntitledChecks := []string{}
+	for _, checkID := range e.Checks {
+		if premiumChecks[checkID] {
+			notEntitledChecks = append(notEntitledChecks, checkID)
+		} else {
+			entitledChecks = append(entitledChecks, checkID)
+		}
+	}
+
+	if len(notEntitledChecks) == 0 || subscriptionClient.IsPremium(ctx) {
+		return e, nil
+	}
+
+	selected := *e
+	selected.Checks = entitledChecks
+	notEntitledResults := &ExecutionResults{ClusterID: e.ClusterID.String(), Hosts: []*HostResults{}}
+	for _, host := range e.targetHosts() {
+		notEntitledResults.addHost(host.HostID.String(), true, "")
+		for _, checkID := range notEntitledChecks {
+			notEntitledResults.addResult(host.HostID.String(), checkID, checkResultSkipped, notEntitledMsg)
+		}
+	}
+
+	return &selected, notEntitledResults
+}
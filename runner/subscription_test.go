@@ -0,0 +1,178 @@
+package runner
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+)
+
+type SubscriptionTestSuite struct {
+	suite.Suite
+	server   *httptest.Server
+	response string
+	status   int
+	requests int
+}
+
+func TestSubscriptionTestSuite(t *testing.T) {
+	suite.Run(t, new(SubscriptionTestSuite))
+}
+
+func (suite *SubscriptionTestSuite) SetupTest() {
+	suite.response = `{"premium":true}`
+	suite.status = http.StatusOK
+	suite.requests = 0
+
+	suite.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		suite.requests++
+		w.WriteHeader(suite.status)
+		w.Write([]byte(suite.response))
+	}))
+}
+
+func (suite *SubscriptionTestSuite) TearDownTest() {
+	suite.server.Close()
+	subscriptionRefreshInterval = time.Minute * 5
+	subscriptionRetryInterval = time.Second * 10
+}
+
+func (suite *SubscriptionTestSuite) TestIsPremium() {
+	client := NewSubscriptionClient(suite.server.URL, nil)
+
+	suite.True(client.IsPremium(context.Background()))
+	// The entitlement is not requested again until the refresh interval is elapsed
+	suite.True(client.IsPremium(context.Background()))
+	suite.Equal(1, suite.requests)
+}
+
+func (suite *SubscriptionTestSuite) TestIsPremiumRefresh() {
+	subscriptionRefreshInterval = 0
+	client := NewSubscriptionClient(suite.server.URL, nil)
+
+	suite.True(client.IsPremium(context.Background()))
+
+	// The last known entitlement is kept if the server fails
+	suite.status = http.StatusInternalServerError
+	suite.True(client.IsPremium(context.Background()))
+
+	suite.status = http.StatusOK
+	suite.response = `{"premium":false}`
+	suite.False(client.IsPremium(context.Background()))
+	suite.Equal(3, suite.requests)
+}
+
+func (suite *SubscriptionTestSuite) TestIsPremiumFailureBackoff() {
+	subscriptionRefreshInterval = time.Hour
+	subscriptionRetryInterval = time.Hour
+	suite.status = http.StatusInternalServerError
+	client := NewSubscriptionClient(suite.server.URL, nil)
+
+	// The failures are not requested again until the backoff is elapsed
+	suite.False(client.IsPremium(context.Background()))
+	suite.status = http.StatusOK
+	suite.False(client.IsPremium(context.Background()))
+	suite.Equal(1, suite.requests)
+
+	client.retryAt = time.Now()
+	suite.True(client.IsPremium(context.Background()))
+	suite.Equal(2, suite.requests)
+}
+
+func (suite *SubscriptionTestSuite) TestSubscriptionRetryBackoff() {
+	subscriptionRetryInterval = time.Second * 10
+	subscriptionRefreshInterval = time.Minute
+
+	suite.Equal(time.Second*10, subscriptionRetryBackoff(1))
+	suite.Equal(time.Second*20, subscriptionRetryBackoff(2))
+	suite.Equal(time.Second*40, subscriptionRetryBackoff(3))
+	suite.Equal(time.Minute, subscriptionRetryBackoff(4))
+	suite.Equal(time.Minute, subscriptionRetryBackoff(100))
+}
+
+func (suite *SubscriptionTestSuite) TestIsPremiumFetchNotBlocking() {
+	requested := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(requested)
+		<-release
+		w.Write([]byte(`{"premium":true}`))
+	}))
+	defer server.Close()
+
+	client := NewSubscriptionClient(server.URL, nil)
+	fetched := make(chan bool)
+	go func() {
+		fetched <- client.IsPremium(context.Background())
+	}()
+	<-requested
+
+	// The other callers get the last known entitlement while it is requested
+	suite.False(client.IsPremium(context.Background()))
+	close(release)
+	suite.True(<-fetched)
+	suite.True(client.IsPremium(context.Background()))
+}
+
+func (suite *SubscriptionTestSuite) TestIsPremiumUnknown() {
+	suite.status = http.StatusNotFound
+	client := NewSubscriptionClient(suite.server.URL, nil)
+
+	suite.False(client.IsPremium(context.Background()))
+}
+
+func (suite *SubscriptionTestSuite) TestSelectEntitledChecks() {
+	suite.response = `{"premium":false}`
+	hostID := uuid.New()
+	limitedHostID := uuid.New()
+
+	runnerService, _ := NewRunnerService(&Config{ServerSubscriptionUrl: suite.server.URL})
+	runnerService.setCatalog(&Catalog{
+		&CatalogCheck{ID: "156F64"},
+		&CatalogCheck{ID: "53D035", Premium: true},
+	}, true)
+
+	execution := &ExecutionEvent{
+		ClusterID: uuid.New(),
+		Checks:    []string{"156F64", "53D035"},
+		Hosts:     []*Host{&Host{HostID: hostID}, &Host{HostID: limitedHostID}},
+		Limit:     []uuid.UUID{hostID},
+	}
+	selected, notEntitled := runnerService.selectEntitledChecks(context.Background(), execution)
+
+	suite.Equal([]string{"156F64"}, selected.Checks)
+	suite.Equal([]string{"156F64", "53D035"}, execution.Checks)
+	suite.Equal(&ExecutionResults{
+		ClusterID: execution.ClusterID.String(),
+		Hosts: []*HostResults{
+			&HostResults{
+				HostID:    hostID.String(),
+				Reachable: true,
+				Results:   []*CheckResult{&CheckResult{CheckID: "53D035", Result: "skipped", Msg: notEntitledMsg}},
+			},
+		},
+	}, notEntitled)
+
+	// The premium checks are run with the entitlement
+	suite.response = `{"premium":true}`
+	subscriptionRefreshInterval = 0
+	selected, notEntitled = runnerService.selectEntitledChecks(context.Background(), execution)
+	suite.Equal(execution, selected)
+	suite.Nil(notEntitled)
+}
+
+func (suite *SubscriptionTestSuite) TestSelectEntitledChecksNotGated() {
+	runnerService, _ := NewRunnerService(&Config{})
+	runnerService.setCatalog(&Catalog{&CatalogCheck{ID: "53D035", Premium: true}}, true)
+
+	execution := &ExecutionEvent{Checks: []string{"53D035"}}
+	selected, notEntitled := runnerService.selectEntitledChecks(context.Background(), execution)
+
+	suite.Equal(execution, selected)
+	suite.Nil(notEntitled)
+	suite.Equal(0, suite.requests)
+}
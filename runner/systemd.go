@@ -0,0 +1,103 @@
+package runner
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// The first file descriptor passed by systemd socket activation, after stdin, stdout and stderr
+	listenFdsStart = 3
+	// FileDescriptorName of the socket units of the HTTP and gRPC APIs
+	httpSocketName = "http"
+	grpcSocketName = "grpc"
+)
+
+// The sockets without one of the known names are used by their order in the socket units
+var socketNamesByPosition = []string{httpSocketName, grpcSocketName}
+
+// systemdListeners returns the listening sockets passed by systemd socket activation, by name,
+// or nil if the runner was not started by a socket unit
+func systemdListeners() (map[string]net.Listener, error) {
+	return inheritedListeners(listenFdsStart)
+}
+
+// inheritedListeners reads the sockets passed from the first file descriptor in the LISTEN_FDS environment
+// variables. The variables are removed, so the commands run by the runner do not inherit them
+func inheritedListeners(firstFd int) (map[string]net.Listener, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	listeners := make(map[string]net.Listener)
+	for i := 0; i < count; i++ {
+		fd := firstFd + i
+		syscall.CloseOnExec(fd)
+
+		name := ""
+		if i < len(names) {
+			name = names[i]
+		}
+		if name != httpSocketName && name != grpcSocketName && i < len(socketNamesByPosition) {
+			name = socketNamesByPosition[i]
+		}
+
+		file := os.NewFile(uintptr(fd), name)
+		listener, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("systemd socket %d is not a listening socket: %w", fd, err)
+		}
+
+		if _, ok := listeners[name]; ok || name == "" {
+			log.Warnf("Ignoring the systemd socket %s at %s, it is not used by the runner", name, listener.Addr())
+			listener.Close()
+			continue
+		}
+		listeners[name] = listener
+	}
+
+	return listeners, nil
+}
+
+// notifySystemd sends the state to the service manager, as sd_notify does, if the runner was started
+// by a notify type systemd unit
+func notifySystemd(state string) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return
+	}
+	// Abstract namespace sockets start with @
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		log.Warnf("Error connecting to the systemd notify socket: %s", err)
+		return
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		log.Warnf("Error notifying systemd: %s", err)
+	}
+}
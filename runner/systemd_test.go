@@ -0,0 +1,109 @@
+package runner
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// listenerFd returns a duplicate of the listener socket, as systemd passes it
+func listenerFd(t *testing.T, listener net.Listener) int {
+	file, err := listener.(*net.TCPListener).File()
+	assert.NoError(t, err)
+	defer file.Close()
+
+	fd, err := syscall.Dup(int(file.Fd()))
+	assert.NoError(t, err)
+
+	return fd
+}
+
+func TestInheritedListeners(t *testing.T) {
+	grpcListener, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer grpcListener.Close()
+	httpListener, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer httpListener.Close()
+
+	// The sockets must be consecutive, as the ones passed by systemd
+	grpcFd := listenerFd(t, grpcListener)
+	httpFd := listenerFd(t, httpListener)
+	if httpFd != grpcFd+1 {
+		syscall.Close(grpcFd)
+		syscall.Close(httpFd)
+		t.Skip("the duplicated sockets are not consecutive")
+	}
+
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	os.Setenv("LISTEN_FDS", "2")
+	os.Setenv("LISTEN_FDNAMES", "grpc:http")
+
+	listeners, err := inheritedListeners(grpcFd)
+	assert.NoError(t, err)
+	assert.Len(t, listeners, 2)
+	assert.Equal(t, grpcListener.Addr().String(), listeners[grpcSocketName].Addr().String())
+	assert.Equal(t, httpListener.Addr().String(), listeners[httpSocketName].Addr().String())
+	for _, listener := range listeners {
+		listener.Close()
+	}
+
+	// The variables are not inherited by the commands run by the runner
+	assert.Empty(t, os.Getenv("LISTEN_PID"))
+	assert.Empty(t, os.Getenv("LISTEN_FDS"))
+	assert.Empty(t, os.Getenv("LISTEN_FDNAMES"))
+}
+
+func TestInheritedListeners_Unnamed(t *testing.T) {
+	httpListener, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer httpListener.Close()
+
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	os.Setenv("LISTEN_FDS", "1")
+	os.Setenv("LISTEN_FDNAMES", "trento-runner.socket")
+
+	listeners, err := inheritedListeners(listenerFd(t, httpListener))
+	assert.NoError(t, err)
+	assert.Len(t, listeners, 1)
+	assert.Equal(t, httpListener.Addr().String(), listeners[httpSocketName].Addr().String())
+	listeners[httpSocketName].Close()
+}
+
+func TestInheritedListeners_NotActivated(t *testing.T) {
+	listeners, err := inheritedListeners(listenFdsStart)
+	assert.NoError(t, err)
+	assert.Nil(t, listeners)
+
+	// The sockets passed to another process are not used
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getppid()))
+	os.Setenv("LISTEN_FDS", "1")
+
+	listeners, err = inheritedListeners(listenFdsStart)
+	assert.NoError(t, err)
+	assert.Nil(t, listeners)
+	assert.Empty(t, os.Getenv("LISTEN_FDS"))
+}
+
+func TestNotifySystemd(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	socket := path.Join(tmpDir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	os.Setenv("NOTIFY_SOCKET", socket)
+	defer os.Unsetenv("NOTIFY_SOCKET")
+
+	notifySystemd("READY=1\nSTATUS=Catalog ready")
+
+	buffer := make([]byte, 1024)
+	n, err := conn.Read(buffer)
+	assert.NoError(t, err)
+	assert.Equal(t, "READY=1\nSTATUS=Catalog ready", string(buffer[:n]))
+}
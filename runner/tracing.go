@@ -0,0 +1,90 @@
+package runner
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/trento-project/runner/version"
+)
+
+const (
+	tracerName         = "github.com/trento-project/runner"
+	tracingServiceName = "trento-runner"
+
+	executionIDAttribute = attribute.Key("trento.execution_id")
+	clusterIDAttribute   = attribute.Key("trento.cluster_id")
+)
+
+// The pending spans are flushed on shutdown, giving up after this time
+var tracingShutdownTimeout = time.Second * 5
+
+// tracer is used by all the runner spans. It uses the global tracer provider, which does
+// nothing unless the tracing is enabled
+var tracer = otel.Tracer(tracerName)
+
+// NewTracerProvider sets up the global tracer provider, exporting the spans to the OTLP http
+// endpoint in the configuration. Nil is returned if the tracing is disabled
+func NewTracerProvider(config *Config) (*sdktrace.TracerProvider, error) {
+	if config.TracingEndpoint == "" {
+		return nil, nil
+	}
+
+	endpoint, err := url.Parse(config.TracingEndpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint.Host)}
+	if endpoint.Scheme == "http" {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+	if endpoint.Path != "" && endpoint.Path != "/" {
+		options = append(options, otlptracehttp.WithURLPath(endpoint.Path))
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(), options...)
+	if err != nil {
+		return nil, err
+	}
+
+	tracerProvider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(tracingServiceName),
+			semconv.ServiceVersionKey.String(version.Version),
+		)),
+	)
+
+	otel.SetTracerProvider(tracerProvider)
+
+	return tracerProvider, nil
+}
+
+// executionTraceContext returns the trace context of the request that started the execution,
+// taken from the w3c traceparent header, so the execution is part of the Trento server trace
+func executionTraceContext(header propagation.TextMapCarrier) trace.SpanContext {
+	ctx := propagation.TraceContext{}.Extract(context.Background(), header)
+
+	return trace.SpanContextFromContext(ctx)
+}
+
+// endSpan records the error in the span, if any, and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,161 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrUpstreamNotFound = errors.New("The upstream is not configured")
+
+// The catalog is checked with this interval, so a new one is published shortly after it is built
+var catalogPublishCheckInterval = time.Second * 10
+var catalogPublishTimeout = time.Second * 10
+var catalogPublishRetryPolicy = RetryPolicy{Attempts: 3, Interval: time.Second * 2}
+
+// Upstream is an additional Trento server served by the runner. Its executions are requested with the
+// upstream name, and their callbacks are only sent to its callbacks url, with its own credentials.
+// The TLS settings are shared with the default Trento server
+type Upstream struct {
+	Name         string
+	CallbacksUrl string
+	// The catalog is published in the catalog url each time it is built, and after each catalog interval
+	// if it is set
+	CatalogUrl      string
+	CatalogInterval time.Duration
+	// The premium checks are only run if the subscription read from the subscription url includes them
+	SubscriptionUrl  string
+	ServerToken      string
+	ServerTokenFile  string
+	ServerApiKey     string
+	ServerApiKeyFile string
+	ServerAuthUrl    string
+}
+
+// serverConfig returns the runner configuration with the upstream server settings,
+// so the upstream requests are authenticated as the default server ones
+func (u *Upstream) serverConfig(config *Config) *Config {
+	upstreamConfig := *config
+	upstreamConfig.CallbacksUrl = u.CallbacksUrl
+	upstreamConfig.ServerToken = u.ServerToken
+	upstreamConfig.ServerTokenFile = u.ServerTokenFile
+	upstreamConfig.ServerApiKey = u.ServerApiKey
+	upstreamConfig.ServerApiKeyFile = u.ServerApiKeyFile
+	upstreamConfig.ServerAuthUrl = u.ServerAuthUrl
+
+	return &upstreamConfig
+}
+
+type upstreamClient struct {
+	upstream           *Upstream
+	callbacksClient    CallbacksClient
+	subscriptionClient *SubscriptionClient
+	transport          http.RoundTripper
+}
+
+func newUpstreamClient(config *Config, upstream *Upstream, signer *ResultsSigner) (*upstreamClient, error) {
+	transport, err := NewServerTransport(upstream.serverConfig(config))
+	if err != nil {
+		return nil, fmt.Errorf("upstream %s: %s", upstream.Name, err)
+	}
+
+	callbacksClient := NewCallbacksClient(upstream.CallbacksUrl, transport)
+	callbacksClient.SetRateLimit(config.CallbacksRateLimit)
+	callbacksClient.SetSigner(signer)
+
+	client := &upstreamClient{
+		upstream:        upstream,
+		callbacksClient: callbacksClient,
+		transport:       transport,
+	}
+	if upstream.SubscriptionUrl != "" {
+		client.subscriptionClient = NewSubscriptionClient(upstream.SubscriptionUrl, transport)
+	}
+
+	return client, nil
+}
+
+// CatalogPublisher sends the checks catalog to an upstream, as it is returned by the catalog API
+type CatalogPublisher struct {
+	runnerService RunnerService
+	upstream      *Upstream
+	httpClient    *http.Client
+}
+
+func NewCatalogPublisher(upstream *Upstream, runnerService RunnerService, transport http.RoundTripper) *CatalogPublisher {
+	return &CatalogPublisher{
+		runnerService: runnerService,
+		upstream:      upstream,
+		httpClient:    &http.Client{Transport: transport, Timeout: catalogPublishTimeout},
+	}
+}
+
+// Run publishes the catalog once it is ready, and again when it is rebuilt or the catalog interval
+// is elapsed, until the context is done
+func (p *CatalogPublisher) Run(ctx context.Context) {
+	log.Infof("Starting the catalog publisher of upstream %s", p.upstream.Name)
+
+	ticker := time.NewTicker(catalogPublishCheckInterval)
+	defer ticker.Stop()
+
+	var published *Catalog
+	var publishedAt time.Time
+
+	for {
+		if catalog := p.runnerService.GetCatalog(); p.runnerService.IsCatalogReady() && catalog != nil {
+			expired := p.upstream.CatalogInterval > 0 && time.Since(publishedAt) >= p.upstream.CatalogInterval
+			if catalog != published || expired {
+				if err := p.publish(ctx, catalog); err != nil {
+					log.Errorf("Error publishing the catalog to upstream %s: %s", p.upstream.Name, err)
+				} else {
+					log.Infof("Catalog published to upstream %s", p.upstream.Name)
+				}
+				// A failed catalog is published again in the next interval, or with the next build
+				published = catalog
+				publishedAt = time.Now()
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			log.Infof("Catalog publisher of upstream %s is shutting down.", p.upstream.Name)
+			return
+		}
+	}
+}
+
+func (p *CatalogPublisher) publish(ctx context.Context, catalog *Catalog) error {
+	requestBody, err := json.Marshal(catalog)
+	if err != nil {
+		return err
+	}
+
+	return catalogPublishRetryPolicy.Do(ctx, func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.upstream.CatalogUrl, bytes.NewReader(requestBody))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := p.httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("cannot publish the catalog to %s, status code: %d", p.upstream.CatalogUrl, resp.StatusCode)
+		}
+
+		return nil
+	}, func(_ int, wait time.Duration, err error) {
+		log.Warnf("Error publishing the catalog to upstream %s, retrying in %s: %s", p.upstream.Name, wait, err)
+	})
+}
@@ -0,0 +1,118 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type CatalogPublisherTestSuite struct {
+	suite.Suite
+	server    *httptest.Server
+	mu        sync.Mutex
+	published []Catalog
+	status    int
+}
+
+func TestCatalogPublisherTestSuite(t *testing.T) {
+	suite.Run(t, new(CatalogPublisherTestSuite))
+}
+
+func (suite *CatalogPublisherTestSuite) SetupTest() {
+	catalogPublishCheckInterval = time.Millisecond * 10
+	catalogPublishRetryPolicy = RetryPolicy{Attempts: 1}
+	suite.published = []Catalog{}
+	suite.status = http.StatusAccepted
+
+	suite.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		suite.mu.Lock()
+		defer suite.mu.Unlock()
+
+		if r.Header.Get("Authorization") != "Bearer customertoken" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var catalog Catalog
+		json.NewDecoder(r.Body).Decode(&catalog)
+		suite.published = append(suite.published, catalog)
+		w.WriteHeader(suite.status)
+	}))
+}
+
+func (suite *CatalogPublisherTestSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+func (suite *CatalogPublisherTestSuite) publishedCount() int {
+	suite.mu.Lock()
+	defer suite.mu.Unlock()
+
+	return len(suite.published)
+}
+
+func (suite *CatalogPublisherTestSuite) newPublisher(runnerService RunnerService, interval time.Duration) *CatalogPublisher {
+	upstream := &Upstream{
+		Name:            "customer1",
+		CallbacksUrl:    suite.server.URL + "/api/runner/callbacks",
+		CatalogUrl:      suite.server.URL + "/api/runner/catalog",
+		CatalogInterval: interval,
+		ServerToken:     "customertoken",
+	}
+	client, err := newUpstreamClient(&Config{}, upstream, nil)
+	suite.NoError(err)
+
+	return NewCatalogPublisher(upstream, runnerService, client.transport)
+}
+
+func (suite *CatalogPublisherTestSuite) TestRunPublishesNewCatalogs() {
+	catalog := &Catalog{&CatalogCheck{ID: "ABCDEF", Name: "check", Group: "group"}}
+	runnerService := new(MockRunnerService)
+	runnerService.On("IsCatalogReady").Return(true)
+	runnerService.On("GetCatalog").Return(catalog)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go suite.newPublisher(runnerService, 0).Run(ctx)
+
+	suite.Eventually(func() bool { return suite.publishedCount() == 1 }, time.Second, time.Millisecond*10)
+	// The same catalog is not published again without an interval
+	time.Sleep(time.Millisecond * 50)
+	suite.Equal(1, suite.publishedCount())
+
+	suite.mu.Lock()
+	suite.Equal(*catalog, suite.published[0])
+	suite.mu.Unlock()
+}
+
+func (suite *CatalogPublisherTestSuite) TestRunPublishesAfterInterval() {
+	runnerService := new(MockRunnerService)
+	runnerService.On("IsCatalogReady").Return(true)
+	runnerService.On("GetCatalog").Return(&Catalog{})
+	suite.status = http.StatusInternalServerError
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go suite.newPublisher(runnerService, time.Millisecond*20).Run(ctx)
+
+	suite.Eventually(func() bool { return suite.publishedCount() >= 3 }, time.Second, time.Millisecond*10)
+}
+
+func (suite *CatalogPublisherTestSuite) TestRunCatalogNotReady() {
+	runnerService := new(MockRunnerService)
+	runnerService.On("IsCatalogReady").Return(false)
+	runnerService.On("GetCatalog").Return(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go suite.newPublisher(runnerService, 0).Run(ctx)
+
+	time.Sleep(time.Millisecond * 50)
+	cancel()
+	suite.Equal(0, suite.publishedCount())
+}
@@ -0,0 +1,219 @@
+package runner
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	envPlaceholder  = "env"
+	filePlaceholder = "file"
+)
+
+// The placeholders are ${source:reference}, where the source is env or file
+var variablePlaceholder = regexp.MustCompile(`\$\{([A-Za-z]*):([^}]*)\}`)
+
+// ValidatePlaceholders checks the placeholders of the variables values have a known source and a reference,
+// without resolving them, as the environment variables and files might only exist when the executions run
+func ValidatePlaceholders(value interface{}) error {
+	switch value := value.(type) {
+	case string:
+		for _, match := range variablePlaceholder.FindAllStringSubmatch(value, -1) {
+			if match[1] != envPlaceholder && match[1] != filePlaceholder {
+				return fmt.Errorf("placeholder %s is not supported, it must be ${env:VAR} or ${file:/path}", match[0])
+			}
+			if strings.TrimSpace(match[2]) == "" {
+				return fmt.Errorf("placeholder %s does not have a reference", match[0])
+			}
+		}
+	case map[string]string:
+		for _, name := range sortedStringMapKeys(value) {
+			if err := ValidatePlaceholders(value[name]); err != nil {
+				return fmt.Errorf("variable %s: %s", name, err)
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range sortedMapKeys(value) {
+			if err := ValidatePlaceholders(value[name]); err != nil {
+				return fmt.Errorf("variable %s: %s", name, err)
+			}
+		}
+	case []interface{}:
+		for _, item := range value {
+			if err := ValidatePlaceholders(item); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// resolveVariables returns a copy of the variables with the ${env:VAR} placeholders of their values replaced by the
+// environment variable, and the ${file:/path} ones by the file content without its trailing new line. They are
+// resolved each time an execution is rendered, so the secrets can be rotated without restarting the runner, and
+// never land in the configuration file. The nested maps and lists are resolved too
+func resolveVariables(variables map[string]interface{}) (map[string]interface{}, error) {
+	if len(variables) == 0 {
+		return nil, nil
+	}
+
+	resolved := make(map[string]interface{}, len(variables))
+	for _, name := range sortedMapKeys(variables) {
+		value, err := resolveValue(variables[name])
+		if err != nil {
+			return nil, fmt.Errorf("cannot resolve the variable %s: %s", name, err)
+		}
+		resolved[name] = value
+	}
+
+	return resolved, nil
+}
+
+func resolveValue(value interface{}) (interface{}, error) {
+	switch value := value.(type) {
+	case string:
+		return resolvePlaceholders(value)
+	case map[string]interface{}:
+		resolved := make(map[string]interface{}, len(value))
+		for name, item := range value {
+			resolvedItem, err := resolveValue(item)
+			if err != nil {
+				return nil, err
+			}
+			resolved[name] = resolvedItem
+		}
+		return resolved, nil
+	case []interface{}:
+		resolved := make([]interface{}, 0, len(value))
+		for _, item := range value {
+			resolvedItem, err := resolveValue(item)
+			if err != nil {
+				return nil, err
+			}
+			resolved = append(resolved, resolvedItem)
+		}
+		return resolved, nil
+	}
+
+	return value, nil
+}
+
+func resolvePlaceholders(value string) (string, error) {
+	var resolveErr error
+	resolved := variablePlaceholder.ReplaceAllStringFunc(value, func(placeholder string) string {
+		if resolveErr != nil {
+			return placeholder
+		}
+
+		match := variablePlaceholder.FindStringSubmatch(placeholder)
+		reference := strings.TrimSpace(match[2])
+		switch match[1] {
+		case envPlaceholder:
+			env, ok := os.LookupEnv(reference)
+			if !ok {
+				resolveErr = fmt.Errorf("the %s environment variable is not set", reference)
+			}
+			return env
+		case filePlaceholder:
+			content, err := ioutil.ReadFile(reference)
+			if err != nil {
+				resolveErr = fmt.Errorf("cannot read the %s file: %s", reference, err)
+			}
+			return strings.TrimRight(string(content), "\r\n")
+		default:
+			resolveErr = fmt.Errorf("placeholder %s is not supported", placeholder)
+			return placeholder
+		}
+	})
+	if resolveErr != nil {
+		return "", resolveErr
+	}
+
+	return resolved, nil
+}
+
+// hasPlaceholders tells if the value, or any of its nested values, has a placeholder
+func hasPlaceholders(value interface{}) bool {
+	switch value := value.(type) {
+	case string:
+		return variablePlaceholder.MatchString(value)
+	case map[string]interface{}:
+		for _, item := range value {
+			if hasPlaceholders(item) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range value {
+			if hasPlaceholders(item) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// secretVariables returns the names of the configured variables with placeholders, as their values are secrets
+func secretVariables(config *Config) map[string]bool {
+	var secrets map[string]bool
+	for name, value := range config.CheckVariables {
+		if hasPlaceholders(value) {
+			if secrets == nil {
+				secrets = map[string]bool{}
+			}
+			secrets[name] = true
+		}
+	}
+	for name, value := range config.InventoryVariables {
+		if hasPlaceholders(value) {
+			if secrets == nil {
+				secrets = map[string]bool{}
+			}
+			secrets[name] = true
+		}
+	}
+
+	return secrets
+}
+
+// setInventoryVariables resolves the placeholders of the inventory variables and sets them in all the inventory
+// nodes. The values are quoted, so ansible takes them as they are
+func setInventoryVariables(inventoryVariables map[string]string, inventoryContent *InventoryContent) error {
+	for _, name := range sortedStringMapKeys(inventoryVariables) {
+		value, err := resolvePlaceholders(inventoryVariables[name])
+		if err != nil {
+			return fmt.Errorf("cannot resolve the inventory variable %s: %s", name, err)
+		}
+		inventoryContent.SetNodesVariable(name, strconv.Quote(value))
+	}
+
+	return nil
+}
+
+// sortedMapKeys returns the names of the variables sorted, so the errors are the same in every execution
+func sortedMapKeys(variables map[string]interface{}) []string {
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func sortedStringMapKeys(variables map[string]string) []string {
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,108 @@
+package runner
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveVariables(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+
+	passwordFile := path.Join(tmpDir, "hana_password")
+	ioutil.WriteFile(passwordFile, []byte("hanasecret\n"), 0600)
+	os.Setenv("TRENTO_TEST_HANA_USER", "system")
+	defer os.Unsetenv("TRENTO_TEST_HANA_USER")
+
+	variables := map[string]interface{}{
+		"expected_token_timeout": 30000,
+		"hana_credentials":       "${env:TRENTO_TEST_HANA_USER}/${file:" + passwordFile + "}",
+		"hana": map[string]interface{}{
+			"password": "${file:" + passwordFile + "}",
+			"sids":     []interface{}{"PRD", "${env:TRENTO_TEST_HANA_USER}"},
+		},
+	}
+
+	resolved, err := resolveVariables(variables)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"expected_token_timeout": 30000,
+		"hana_credentials":       "system/hanasecret",
+		"hana": map[string]interface{}{
+			"password": "hanasecret",
+			"sids":     []interface{}{"PRD", "system"},
+		},
+	}, resolved)
+	// The configured variables keep the placeholders
+	assert.Equal(t, "${file:"+passwordFile+"}", variables["hana"].(map[string]interface{})["password"])
+
+	resolved, err = resolveVariables(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, resolved)
+}
+
+func TestResolveVariables_Errors(t *testing.T) {
+	os.Unsetenv("TRENTO_TEST_UNSET")
+
+	_, err := resolveVariables(map[string]interface{}{"password": "${env:TRENTO_TEST_UNSET}"})
+	assert.EqualError(t, err, "cannot resolve the variable password: the TRENTO_TEST_UNSET environment variable is not set")
+
+	_, err = resolveVariables(map[string]interface{}{"password": "${file:/nonexistent/password}"})
+	assert.EqualError(t, err, "cannot resolve the variable password: "+
+		"cannot read the /nonexistent/password file: open /nonexistent/password: no such file or directory")
+
+	_, err = resolveVariables(map[string]interface{}{"password": "${vault:hana}"})
+	assert.EqualError(t, err, "cannot resolve the variable password: placeholder ${vault:hana} is not supported")
+}
+
+func TestSetInventoryVariables(t *testing.T) {
+	os.Setenv("TRENTO_TEST_BECOME_PASSWORD", `become "secret"`)
+	defer os.Unsetenv("TRENTO_TEST_BECOME_PASSWORD")
+
+	event := chunkedTestEvent(2)
+	content, _ := NewClusterInventoryContent(event)
+
+	err := setInventoryVariables(
+		map[string]string{"ansible_become_password": "${env:TRENTO_TEST_BECOME_PASSWORD}"}, content)
+	assert.NoError(t, err)
+	for _, host := range event.Hosts {
+		assert.Equal(t, `"become \"secret\""`,
+			content.getNode(host.HostID.String()).Variables["ansible_become_password"])
+	}
+
+	os.Unsetenv("TRENTO_TEST_BECOME_PASSWORD")
+	err = setInventoryVariables(
+		map[string]string{"ansible_become_password": "${env:TRENTO_TEST_BECOME_PASSWORD}"}, content)
+	assert.EqualError(t, err, "cannot resolve the inventory variable ansible_become_password: "+
+		"the TRENTO_TEST_BECOME_PASSWORD environment variable is not set")
+}
+
+func TestValidatePlaceholders(t *testing.T) {
+	assert.NoError(t, ValidatePlaceholders(map[string]interface{}{
+		"password": "${env:HANA_PASSWORD}",
+		"key":      []interface{}{"${file:/run/secrets/key}", 1},
+		"literal":  "$HOME ${HOME}",
+	}))
+	assert.EqualError(t, ValidatePlaceholders(map[string]interface{}{"key": []interface{}{"${file: }"}}),
+		"variable key: placeholder ${file: } does not have a reference")
+	assert.EqualError(t, ValidatePlaceholders(map[string]string{"password": "${Env:HANA_PASSWORD}"}),
+		"variable password: placeholder ${Env:HANA_PASSWORD} is not supported, it must be ${env:VAR} or ${file:/path}")
+}
+
+func TestSecretVariables(t *testing.T) {
+	assert.Nil(t, secretVariables(&Config{CheckVariables: map[string]interface{}{"expected_token_timeout": 30000}}))
+	assert.Equal(t, map[string]bool{"hana": true, "ansible_become_password": true}, secretVariables(&Config{
+		CheckVariables: map[string]interface{}{
+			"expected_token_timeout": 30000,
+			"hana":                   map[string]interface{}{"sids": []interface{}{"PRD", "${env:HANA_USER}"}},
+		},
+		InventoryVariables: map[string]string{
+			"ansible_become_password": "${file:/run/secrets/become}",
+			"ansible_user":            "trento",
+		},
+	}))
+}
@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/trento-project/runner/version"
+)
+
+var embeddedChecksHashOnce sync.Once
+var embeddedChecksHash string
+
+// VersionInfo is the runner build information, with the embedded checks and the current catalog versions
+type VersionInfo struct {
+	*version.Info
+	EmbeddedChecksHash   string `json:"embedded_checks_hash"`
+	CatalogSchemaVersion int    `json:"catalog_schema_version"`
+	CatalogVersion       string `json:"catalog_version,omitempty"`
+}
+
+// EmbeddedChecksHash identifies the checks embedded in the runner binary. The catalog version
+// might be different, as it includes the custom checks
+func EmbeddedChecksHash() string {
+	embeddedChecksHashOnce.Do(func() {
+		hash := sha256.New()
+		fs.WalkDir(ansibleFS, "ansible", func(fileName string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			content, err := ansibleFS.ReadFile(fileName)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(hash, "%s\n%d\n", fileName, len(content))
+			hash.Write(content)
+			return nil
+		})
+		embeddedChecksHash = fmt.Sprintf("%x", hash.Sum(nil))
+	})
+
+	return embeddedChecksHash
+}
+
+func NewVersionInfo(runnerService RunnerService) *VersionInfo {
+	return &VersionInfo{
+		Info:                 version.GetInfo(),
+		EmbeddedChecksHash:   EmbeddedChecksHash(),
+		CatalogSchemaVersion: CatalogSchemaVersion,
+		CatalogVersion:       runnerService.GetCatalog().Version(),
+	}
+}
+
+func VersionHandler(runnerService RunnerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, NewVersionInfo(runnerService))
+	}
+}
@@ -0,0 +1,55 @@
+package runner
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/trento-project/runner/version"
+)
+
+type VersionApiTestCase struct {
+	suite.Suite
+}
+
+func TestVersionApiTestCase(t *testing.T) {
+	suite.Run(t, new(VersionApiTestCase))
+}
+
+func (suite *VersionApiTestCase) Test_GetVersion() {
+	version.Version = "1.0.0"
+	version.GitSha = "abc1234"
+	defer func() {
+		version.Version = ""
+		version.GitSha = ""
+	}()
+
+	catalog := &Catalog{&CatalogCheck{ID: "156F64"}}
+	mockRunnerService := new(MockRunnerService)
+	mockRunnerService.On("GetCatalog").Return(catalog)
+
+	deps := setupTestDependencies()
+	deps.runnerService = mockRunnerService
+
+	app, err := NewAppWithDeps(&Config{}, deps)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/version", nil)
+	app.webEngine.ServeHTTP(resp, req)
+
+	var info map[string]interface{}
+	json.Unmarshal(resp.Body.Bytes(), &info)
+
+	suite.Equal(200, resp.Code)
+	suite.Equal("1.0.0", info["version"])
+	suite.Equal("abc1234", info["git_sha"])
+	suite.Equal(EmbeddedChecksHash(), info["embedded_checks_hash"])
+	suite.Len(EmbeddedChecksHash(), 64)
+	suite.Equal(float64(CatalogSchemaVersion), info["catalog_schema_version"])
+	suite.Equal(catalog.Version(), info["catalog_version"])
+}
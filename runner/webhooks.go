@@ -0,0 +1,236 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	webhookSignatureHeader = "X-Trento-Signature"
+	webhookEventHeader     = "X-Trento-Event"
+	webhookSignaturePrefix = "sha256="
+	webhooksQueueSize      = 999
+)
+
+var webhookRequestTimeout = time.Second * 10
+var webhooksRetryPolicy = RetryPolicy{Attempts: 3, Interval: time.Second * 2}
+
+// WebhookNotification is the body of the webhooks requests
+type WebhookNotification struct {
+	ExecutionID uuid.UUID      `json:"execution_id"`
+	Event       string         `json:"event"`
+	Payload     interface{}    `json:"payload"`
+	Summary     map[string]int `json:"summary,omitempty"`
+	Timestamp   time.Time      `json:"timestamp"`
+}
+
+// deadLetter is a notification that could not be delivered, as written in the dead letter file
+type deadLetter struct {
+	Url          string               `json:"url"`
+	Notification *WebhookNotification `json:"notification"`
+	Error        string               `json:"error"`
+	Time         time.Time            `json:"time"`
+}
+
+// WebhooksNotifier sends the executions start, finish and failure events to the configured webhooks,
+// signing the body with the HMAC-SHA256 of the secret, if it is set. The failed notifications are retried,
+// and written in the dead letter file once the retries are exhausted
+type WebhooksNotifier struct {
+	urls           []string
+	secret         string
+	deadLetterFile string
+	httpClient     *http.Client
+	events         *EventsBroadcaster
+	queue          chan *WebhookNotification
+	deadLetterMu   sync.Mutex
+}
+
+func NewWebhooksNotifier(config *Config, events *EventsBroadcaster) *WebhooksNotifier {
+	return &WebhooksNotifier{
+		urls:           config.WebhookUrls,
+		secret:         config.WebhookSecret,
+		deadLetterFile: config.WebhookDeadLetterFile,
+		httpClient:     &http.Client{Transport: newHTTPTransport(config), Timeout: webhookRequestTimeout},
+		events:         events,
+		queue:          make(chan *WebhookNotification, webhooksQueueSize),
+	}
+}
+
+// Run sends the notifications until the context is done. The queued notifications are sent, without retries,
+// before returning
+func (w *WebhooksNotifier) Run(ctx context.Context) {
+	events, unsubscribe := w.events.Subscribe()
+	defer unsubscribe()
+
+	log.Infof("Starting webhooks notifier")
+
+	// The notifications are sent in the background, so the slow webhooks do not make the events be discarded
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for notification := range w.queue {
+			w.notify(ctx, notification)
+		}
+	}()
+
+	// The checks results are counted, so the finish notification has the execution summary
+	summaries := make(map[uuid.UUID]map[string]int)
+	for {
+		select {
+		case event := <-events:
+			w.collect(summaries, event)
+		case <-ctx.Done():
+			log.Infof("Webhooks notifier is shutting down... Flushing pending notifications.")
+			for pending := true; pending; {
+				select {
+				case event := <-events:
+					w.collect(summaries, event)
+				default:
+					pending = false
+				}
+			}
+			close(w.queue)
+			<-done
+			return
+		}
+	}
+}
+
+func (w *WebhooksNotifier) collect(summaries map[uuid.UUID]map[string]int, event *LifecycleEvent) {
+	notification := &WebhookNotification{
+		ExecutionID: event.ExecutionID,
+		Event:       event.Event,
+		Payload:     event.Payload,
+		Timestamp:   time.Now().UTC(),
+	}
+
+	switch event.Event {
+	case executionStartedEvent:
+		summaries[event.ExecutionID] = make(map[string]int)
+	case hostCompletedEvent:
+		if payload, ok := event.Payload.(map[string]interface{}); ok && payload["reachable"] == false {
+			w.count(summaries, event.ExecutionID, "unreachable")
+		}
+		return
+	case checkResultEvent:
+		if payload, ok := event.Payload.(map[string]interface{}); ok {
+			w.count(summaries, event.ExecutionID, fmt.Sprint(payload["result"]))
+		}
+		return
+	case executionFinishedEvent, executionFailedEvent:
+		notification.Summary = summaries[event.ExecutionID]
+		delete(summaries, event.ExecutionID)
+	default:
+		return
+	}
+
+	select {
+	case w.queue <- notification:
+	default:
+		w.writeDeadLetter("", notification, fmt.Errorf("the webhooks queue is full"))
+	}
+}
+
+func (w *WebhooksNotifier) count(summaries map[uuid.UUID]map[string]int, executionID uuid.UUID, key string) {
+	summary, ok := summaries[executionID]
+	if !ok {
+		summary = make(map[string]int)
+		summaries[executionID] = summary
+	}
+	summary[key]++
+}
+
+// notify sends the notification to every webhook. The retries are stopped once the context is done
+func (w *WebhooksNotifier) notify(ctx context.Context, notification *WebhookNotification) {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		log.Errorf("Error encoding the webhook notification: %s", err)
+		return
+	}
+
+	for _, url := range w.urls {
+		err := webhooksRetryPolicy.Do(ctx, func() error {
+			return w.post(url, notification.Event, body)
+		}, func(_ int, wait time.Duration, err error) {
+			log.Warnf("Error sending the webhook notification to %s, retrying in %s: %s", url, wait, err)
+		})
+		if err != nil {
+			w.writeDeadLetter(url, notification, err)
+		}
+	}
+}
+
+func (w *WebhooksNotifier) post(url, event string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookEventHeader, event)
+	if w.secret != "" {
+		req.Header.Set(webhookSignatureHeader, webhookSignaturePrefix+signWebhookBody(w.secret, body))
+	}
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s answered with status code %d", url, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// signWebhookBody returns the hex encoded HMAC-SHA256 of the body, so the receivers can check
+// the notification comes from the runner
+func signWebhookBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// writeDeadLetter appends the notification to the dead letter file, as a json line, or logs it if there is no file
+func (w *WebhooksNotifier) writeDeadLetter(url string, notification *WebhookNotification, err error) {
+	letter := &deadLetter{Url: url, Notification: notification, Error: err.Error(), Time: time.Now().UTC()}
+	content, marshalErr := json.Marshal(letter)
+	if marshalErr != nil {
+		log.Errorf("Error encoding the webhook dead letter: %s", marshalErr)
+		return
+	}
+
+	if w.deadLetterFile == "" {
+		log.Errorf("Webhook notification not delivered: %s", content)
+		return
+	}
+
+	w.deadLetterMu.Lock()
+	defer w.deadLetterMu.Unlock()
+
+	f, openErr := os.OpenFile(w.deadLetterFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if openErr != nil {
+		log.Errorf("Error opening the webhooks dead letter file, notification not delivered: %s: %s", openErr, content)
+		return
+	}
+	defer f.Close()
+
+	if _, writeErr := f.Write(append(content, '\n')); writeErr != nil {
+		log.Errorf("Error writing the webhooks dead letter file: %s", writeErr)
+	}
+	log.Errorf("Webhook notification of execution %s not delivered to %s: %s", notification.ExecutionID.String(), url, err)
+}
@@ -0,0 +1,112 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type webhookRequest struct {
+	signature    string
+	event        string
+	notification *WebhookNotification
+	body         []byte
+}
+
+func TestWebhooksNotifier(t *testing.T) {
+	requests := make(chan *webhookRequest, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var notification *WebhookNotification
+		json.Unmarshal(body, &notification)
+		requests <- &webhookRequest{
+			signature:    r.Header.Get("X-Trento-Signature"),
+			event:        r.Header.Get("X-Trento-Event"),
+			notification: notification,
+			body:         body,
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	events := NewEventsBroadcaster()
+	config := &Config{WebhookUrls: []string{server.URL}, WebhookSecret: "hooksecret"}
+	notifier := NewWebhooksNotifier(config, events)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		notifier.Run(ctx)
+		close(done)
+	}()
+	// Wait until the notifier is subscribed
+	assert.Eventually(t, func() bool {
+		events.mu.Lock()
+		defer events.mu.Unlock()
+		return len(events.subscribers) > 0
+	}, time.Second, time.Millisecond)
+
+	executionID := uuid.New()
+	events.Publish(executionID, executionStartedEvent, map[string]string{"cluster_id": "cluster1"})
+	events.Publish(executionID, hostCompletedEvent, map[string]interface{}{"host_id": "host1", "reachable": true})
+	events.Publish(executionID, checkResultEvent, map[string]interface{}{"check_id": "156F64", "result": "passing"})
+	events.Publish(executionID, checkResultEvent, map[string]interface{}{"check_id": "53D035", "result": "critical"})
+	events.Publish(executionID, hostCompletedEvent, map[string]interface{}{"host_id": "host2", "reachable": false})
+	events.Publish(executionID, executionFinishedEvent, map[string]string{"cluster_id": "cluster1"})
+
+	started := <-requests
+	assert.Equal(t, "execution_started", started.event)
+	assert.Equal(t, executionID, started.notification.ExecutionID)
+	assert.Equal(t, "sha256="+signWebhookBody("hooksecret", started.body), started.signature)
+
+	finished := <-requests
+	assert.Equal(t, "execution_finished", finished.event)
+	assert.Equal(t, map[string]int{"passing": 1, "critical": 1, "unreachable": 1}, finished.notification.Summary)
+	assert.Equal(t, map[string]interface{}{"cluster_id": "cluster1"}, finished.notification.Payload)
+
+	cancel()
+	<-done
+	assert.Empty(t, requests)
+}
+
+func TestWebhooksNotifier_DeadLetter(t *testing.T) {
+	webhooksRetryPolicy = RetryPolicy{Attempts: 2, Interval: time.Millisecond}
+	defer func() { webhooksRetryPolicy = RetryPolicy{Attempts: 3, Interval: time.Second * 2} }()
+
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+	deadLetterFile := path.Join(tmpDir, "dead_letters.log")
+
+	config := &Config{WebhookUrls: []string{server.URL}, WebhookDeadLetterFile: deadLetterFile}
+	notifier := NewWebhooksNotifier(config, NewEventsBroadcaster())
+
+	executionID := uuid.New()
+	notifier.notify(context.Background(), &WebhookNotification{ExecutionID: executionID, Event: executionFailedEvent})
+
+	assert.Equal(t, 2, attempts)
+
+	content, err := ioutil.ReadFile(deadLetterFile)
+	assert.NoError(t, err)
+
+	var letter *deadLetter
+	assert.NoError(t, json.Unmarshal(content, &letter))
+	assert.Equal(t, server.URL, letter.Url)
+	assert.Equal(t, executionID, letter.Notification.ExecutionID)
+	assert.Contains(t, letter.Error, "status code 500")
+}
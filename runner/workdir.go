@@ -0,0 +1,199 @@
+package runner
+
+import (
+	"context"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// The executions work dirs are out of the ansible folder, which is created again each time the catalog is built
+	defaultWorkDir = "executions"
+	// The files of each execution in its work dir, besides the inventory and extra vars
+	workDirLogFile = "ansible.log"
+	workDirTemp    = "tmp"
+	workDirRetries = "retries"
+	// The work dirs of the failed executions have this file, so they are kept for the retention
+	failedWorkDirMarker = ".failed"
+
+	AnsibleLogPathEnv        = "ANSIBLE_LOG_PATH"
+	AnsibleLocalTempEnv      = "ANSIBLE_LOCAL_TEMP"
+	AnsibleRetryFilesPathEnv = "ANSIBLE_RETRY_FILES_SAVE_PATH"
+)
+
+// workDirRoot returns the folder with the work dirs of the executions, the work dir if it is set,
+// or a folder next to the ansible folder otherwise
+func workDirRoot(config *Config) string {
+	if config.WorkDir != "" {
+		return config.WorkDir
+	}
+
+	return path.Join(config.AnsibleFolder, defaultWorkDir)
+}
+
+// executionWorkDir returns the scratch folder of the execution, with its inventory, extra vars,
+// ansible log, retry files and temporary files, so the concurrent executions never share any file
+func executionWorkDir(config *Config, executionID string) string {
+	return path.Join(workDirRoot(config), executionID)
+}
+
+// createWorkDir creates the execution work dir, only readable by the runner user as it has the hosts
+// addresses and users. The work dir of a failed attempt, if it was kept, is used again
+func createWorkDir(folder string) error {
+	if err := os.MkdirAll(path.Join(folder, workDirTemp), 0700); err != nil {
+		return err
+	}
+	if err := os.Remove(path.Join(folder, failedWorkDirMarker)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return os.Chmod(folder, 0700)
+}
+
+// SetWorkDir makes ansible write its log, retry and temporary files in the execution work dir
+func (a *AnsibleRunner) SetWorkDir(folder string) {
+	a.setEnv(AnsibleLogPathEnv, path.Join(folder, workDirLogFile))
+	a.setEnv(AnsibleLocalTempEnv, path.Join(folder, workDirTemp))
+	a.setEnv(AnsibleRetryFilesPathEnv, path.Join(folder, workDirRetries))
+}
+
+// releaseWorkDir removes the execution work dir once the checks are run. The work dir of a failed
+// execution is kept for the failed work dir retention instead, if it is set, so the failure can be inspected
+func releaseWorkDir(ctx context.Context, config *Config, folder string, failed bool) error {
+	if failed && config.FailedWorkDirRetention > 0 {
+		loggerFromContext(ctx).Warnf(
+			"The execution failed, its work dir %s is kept for %s", folder, config.FailedWorkDirRetention)
+		return ioutil.WriteFile(path.Join(folder, failedWorkDirMarker), nil, 0600)
+	}
+
+	return removeWorkDir(folder, config.ShredInventories)
+}
+
+// pruneWorkDirs removes the kept work dirs of the failed executions once the retention is over
+func pruneWorkDirs(config *Config) {
+	if config.FailedWorkDirRetention <= 0 {
+		return
+	}
+
+	for _, folder := range workDirs(config) {
+		info, err := os.Stat(path.Join(folder, failedWorkDirMarker))
+		if err != nil || time.Since(info.ModTime()) < config.FailedWorkDirRetention {
+			continue
+		}
+
+		if err := removeWorkDir(folder, config.ShredInventories); err != nil {
+			log.Warnf("Error removing the failed execution work dir %s: %s", folder, err)
+			continue
+		}
+		log.Debugf("Failed execution work dir %s removed", folder)
+	}
+}
+
+// removeStaleWorkDirs removes the work dirs left by the executions running when the runner was stopped.
+// It must be called before any execution starts, the work dirs of the failed executions are kept
+func removeStaleWorkDirs(config *Config) {
+	for _, folder := range workDirs(config) {
+		if _, err := os.Stat(path.Join(folder, failedWorkDirMarker)); err == nil {
+			continue
+		}
+
+		if err := removeWorkDir(folder, config.ShredInventories); err != nil {
+			log.Warnf("Error removing the stale execution work dir %s: %s", folder, err)
+			continue
+		}
+		log.Infof("Stale execution work dir %s removed", folder)
+	}
+}
+
+// The playbook hooks work dirs are named after the execution run id, with the hook stage and number
+var hookRunID = regexp.MustCompile(`^(pre|post)-[0-9]+$`)
+
+// isExecutionWorkDir tells if the folder name is an execution run id, the execution id or the chunk of an execution,
+// or the one of a playbook hook of them
+func isExecutionWorkDir(name string) bool {
+	if index := strings.Index(name, "-hook-"); index >= 0 {
+		if !hookRunID.MatchString(name[index+len("-hook-"):]) {
+			return false
+		}
+		name = name[:index]
+	}
+	if index := strings.Index(name, "-chunk-"); index >= 0 {
+		if _, err := strconv.Atoi(name[index+len("-chunk-"):]); err != nil {
+			return false
+		}
+		name = name[:index]
+	}
+
+	_, err := uuid.Parse(name)
+	return err == nil
+}
+
+func workDirs(config *Config) []string {
+	root := workDirRoot(config)
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("Error reading the executions work dir %s: %s", root, err)
+		}
+		return nil
+	}
+
+	// The work dir might be shared, e.g. a tmpfs, so only the executions work dirs are removed
+	folders := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() && isExecutionWorkDir(entry.Name()) {
+			folders = append(folders, path.Join(root, entry.Name()))
+		}
+	}
+
+	return folders
+}
+
+// removeWorkDir removes the execution work dir. The files are overwritten before they are removed
+// if they are shredded, so their content cannot be recovered from the disk
+func removeWorkDir(folder string, shred bool) error {
+	if shred {
+		err := filepath.WalkDir(folder, func(filePath string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !entry.Type().IsRegular() {
+				return nil
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			return shredFile(filePath, info.Size())
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return os.RemoveAll(folder)
+}
+
+func shredFile(filePath string, size int64) error {
+	f, err := os.OpenFile(filePath, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(make([]byte, size)); err != nil {
+		return err
+	}
+
+	return f.Sync()
+}
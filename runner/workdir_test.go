@@ -0,0 +1,131 @@
+package runner
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecutionWorkDir(t *testing.T) {
+	assert.Equal(t, "/tmp/trento/executions/execution1", executionWorkDir(&Config{AnsibleFolder: "/tmp/trento"}, "execution1"))
+	assert.Equal(
+		t, "/dev/shm/trento/execution1",
+		executionWorkDir(&Config{AnsibleFolder: "/tmp/trento", WorkDir: "/dev/shm/trento"}, "execution1"))
+}
+
+func TestCreateWorkDir(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+	folder := path.Join(tmpDir, "execution1")
+
+	os.MkdirAll(folder, 0755)
+	ioutil.WriteFile(path.Join(folder, failedWorkDirMarker), nil, 0600)
+
+	assert.NoError(t, createWorkDir(folder))
+	info, err := os.Stat(folder)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+	assert.DirExists(t, path.Join(folder, workDirTemp))
+	assert.NoFileExists(t, path.Join(folder, failedWorkDirMarker))
+
+	ansibleRunner := DefaultAnsibleRunner()
+	ansibleRunner.SetWorkDir(folder)
+	assert.Equal(t, map[string]string{
+		"ANSIBLE_LOG_PATH":              path.Join(folder, "ansible.log"),
+		"ANSIBLE_LOCAL_TEMP":            path.Join(folder, "tmp"),
+		"ANSIBLE_RETRY_FILES_SAVE_PATH": path.Join(folder, "retries"),
+	}, ansibleRunner.Envs)
+}
+
+func TestReleaseWorkDir(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+	config := &Config{WorkDir: tmpDir}
+	folder := path.Join(tmpDir, uuid.New().String())
+
+	createWorkDir(folder)
+	assert.NoError(t, releaseWorkDir(context.Background(), config, folder, true))
+	assert.NoDirExists(t, folder)
+
+	config.FailedWorkDirRetention = time.Hour
+	createWorkDir(folder)
+	assert.NoError(t, releaseWorkDir(context.Background(), config, folder, false))
+	assert.NoDirExists(t, folder)
+
+	// The work dirs of the failed executions are kept until the retention is over
+	createWorkDir(folder)
+	assert.NoError(t, releaseWorkDir(context.Background(), config, folder, true))
+	assert.FileExists(t, path.Join(folder, failedWorkDirMarker))
+
+	pruneWorkDirs(config)
+	assert.DirExists(t, folder)
+
+	expired := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(path.Join(folder, failedWorkDirMarker), expired, expired)
+	pruneWorkDirs(config)
+	assert.NoDirExists(t, folder)
+}
+
+func TestRemoveStaleWorkDirs(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+	config := &Config{WorkDir: tmpDir, FailedWorkDirRetention: time.Hour}
+
+	stale := path.Join(tmpDir, uuid.New().String())
+	staleChunk := path.Join(tmpDir, uuid.New().String()+"-chunk-2")
+	staleHook := path.Join(tmpDir, uuid.New().String()+"-chunk-1-hook-post-2")
+	failed := path.Join(tmpDir, uuid.New().String())
+	createWorkDir(stale)
+	createWorkDir(staleChunk)
+	createWorkDir(staleHook)
+	createWorkDir(failed)
+	releaseWorkDir(context.Background(), config, failed, true)
+	// The folders of other applications in a shared work dir are never removed
+	os.MkdirAll(path.Join(tmpDir, "other-application"), 0700)
+	os.MkdirAll(path.Join(tmpDir, uuid.New().String()+"-chunk-backup"), 0700)
+	os.MkdirAll(path.Join(tmpDir, uuid.New().String()+"-hook-backup"), 0700)
+
+	removeStaleWorkDirs(config)
+	assert.NoDirExists(t, stale)
+	assert.NoDirExists(t, staleChunk)
+	assert.NoDirExists(t, staleHook)
+	assert.DirExists(t, failed)
+	assert.DirExists(t, path.Join(tmpDir, "other-application"))
+
+	// The expired failed work dirs are pruned, the other folders are kept
+	expired := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(path.Join(failed, failedWorkDirMarker), expired, expired)
+	ioutil.WriteFile(path.Join(tmpDir, "other-application", failedWorkDirMarker), nil, 0600)
+	os.Chtimes(path.Join(tmpDir, "other-application", failedWorkDirMarker), expired, expired)
+	pruneWorkDirs(config)
+	assert.NoDirExists(t, failed)
+	assert.DirExists(t, path.Join(tmpDir, "other-application"))
+	entries, _ := ioutil.ReadDir(tmpDir)
+	assert.Len(t, entries, 3)
+
+	removeStaleWorkDirs(&Config{WorkDir: path.Join(tmpDir, "other")})
+}
+
+func TestRemoveWorkDir_Shred(t *testing.T) {
+	tmpDir, _ := ioutil.TempDir(os.TempDir(), "trentotest")
+	defer os.RemoveAll(tmpDir)
+	folder := path.Join(tmpDir, "execution1")
+	os.MkdirAll(path.Join(folder, workDirTemp), 0700)
+	ioutil.WriteFile(path.Join(folder, "extra_vars.json"), []byte(`{"password": "secret"}`), 0600)
+	ioutil.WriteFile(path.Join(folder, workDirTemp, "module.py"), []byte(`password = "secret"`), 0600)
+
+	// The content is overwritten before the file is removed
+	assert.NoError(t, shredFile(path.Join(folder, "extra_vars.json"), 22))
+	content, _ := ioutil.ReadFile(path.Join(folder, "extra_vars.json"))
+	assert.Equal(t, make([]byte, 22), content)
+
+	assert.NoError(t, removeWorkDir(folder, true))
+	assert.NoDirExists(t, folder)
+	assert.NoError(t, removeWorkDir(folder, true))
+}
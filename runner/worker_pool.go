@@ -2,53 +2,448 @@ package runner
 
 import (
 	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
-	"golang.org/x/sync/semaphore"
 )
 
-var workersNumber int64 = 3
-var drainTimeout = time.Second * 5
+const (
+	DefaultMaxParallelClusters int64 = 3
+	DefaultShutdownGracePeriod       = time.Minute * 5
+)
+
+// cancelTimeout is how long the cancelled executions have to report their failure
+var cancelTimeout = time.Second * 10
 
 type ExecutionWorkerPool struct {
 	runnerService RunnerService
+	workersNumber int64
+	drainTimeout  time.Duration
+	alive         int32
+	running       int32
+	preemption    bool
+	clustersMu    sync.Mutex
+	// The clusters with a running execution, with the executions waiting for it to finish
+	clusters map[uuid.UUID][]*ExecutionEvent
+	// The executions waiting for a free worker, sorted by priority
+	pending []*ExecutionEvent
+	// The running executions by cluster, so they can be preempted
+	active map[uuid.UUID]*activeExecution
+	// released wakes up the pool when a worker is free, or the workers limit changes
+	released chan struct{}
+	// busy are the workers running executions, waited by the workers wait group when the pool stops
+	busy    int64
+	workers sync.WaitGroup
 }
 
-func NewExecutionWorkerPool(runnerService RunnerService) *ExecutionWorkerPool {
+// activeExecution is a running execution, with the function cancelling it
+type activeExecution struct {
+	execution  *ExecutionEvent
+	cancel     context.CancelFunc
+	preemption *executionPreemption
+}
+
+type preemptionKey struct{}
+
+// executionPreemption records the execution that preempted a running one, before it is cancelled
+type executionPreemption struct {
+	mu sync.Mutex
+	by *ExecutionEvent
+}
+
+func (p *executionPreemption) preempt(by *ExecutionEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.by = by
+}
+
+// preemptedBy returns the execution that preempted the one running with the context, if it was preempted
+func preemptedBy(ctx context.Context) *ExecutionEvent {
+	p, ok := ctx.Value(preemptionKey{}).(*executionPreemption)
+	if !ok {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.by
+}
+
+// NewExecutionWorkerPool creates a pool running up to workersNumber executions concurrently.
+// As each execution targets a single cluster, this limits the clusters being checked at the same time.
+// Two executions never run on the same cluster at once, the later one waits for the running one to finish.
+// The waiting executions are run by priority, and in the order they were requested within the same priority.
+// When the pool is stopped, the running executions have drainTimeout to finish before being cancelled
+func NewExecutionWorkerPool(runnerService RunnerService, workersNumber int64, drainTimeout time.Duration) *ExecutionWorkerPool {
+	if workersNumber <= 0 {
+		workersNumber = DefaultMaxParallelClusters
+	}
+
 	return &ExecutionWorkerPool{
 		runnerService: runnerService,
+		workersNumber: workersNumber,
+		drainTimeout:  drainTimeout,
+		clusters:      make(map[uuid.UUID][]*ExecutionEvent),
+		active:        make(map[uuid.UUID]*activeExecution),
+		released:      make(chan struct{}, 1),
 	}
 }
 
-// Run runs a pool of workers to process the execution requests
+// SetWorkersNumber changes the number of executions run concurrently, when the configuration is reloaded.
+// The running executions are not stopped if it is reduced, the new ones wait for them to finish
+func (e *ExecutionWorkerPool) SetWorkersNumber(workersNumber int64) {
+	if workersNumber <= 0 {
+		workersNumber = DefaultMaxParallelClusters
+	}
+
+	if previous := atomic.SwapInt64(&e.workersNumber, workersNumber); previous != workersNumber {
+		log.Infof("Execution pool workers limit changed from %d to %d", previous, workersNumber)
+		e.wakeUp()
+	}
+}
+
+// EnablePreemption makes the high priority executions cancel the running low priority execution of their cluster.
+// The preempted execution is queued again, and run once the high priority one finishes
+func (e *ExecutionWorkerPool) EnablePreemption() {
+	e.preemption = true
+}
+
+// IsAlive tells if the pool is running and processing the execution requests
+func (e *ExecutionWorkerPool) IsAlive() bool {
+	return atomic.LoadInt32(&e.alive) == 1
+}
+
+// Running returns the number of executions being run
+func (e *ExecutionWorkerPool) Running() int {
+	return int(atomic.LoadInt32(&e.running))
+}
+
+// QueueDepth returns the number of executions waiting to be run, either for a free worker
+// or for the running execution on their cluster
+func (e *ExecutionWorkerPool) QueueDepth() int {
+	e.clustersMu.Lock()
+	defer e.clustersMu.Unlock()
+
+	depth := len(e.runnerService.GetChannel()) + len(e.pending)
+	for _, waiting := range e.clusters {
+		depth += len(waiting)
+	}
+
+	return depth
+}
+
+// Run runs a pool of workers to process the execution requests.
+// Once the context is done, no new executions are accepted and the running ones are drained
 func (e *ExecutionWorkerPool) Run(ctx context.Context) {
-	log.Infof("Starting execution pool. Workers limit: %d", workersNumber)
-	sem := semaphore.NewWeighted(workersNumber)
+	log.Infof("Starting execution pool. Workers limit: %d", atomic.LoadInt64(&e.workersNumber))
+	atomic.StoreInt32(&e.alive, 1)
+	defer atomic.StoreInt32(&e.alive, 0)
+
+	// The executions are not stopped with the pool context, they are given some time to finish
+	executionsCtx, cancelExecutions := context.WithCancel(context.Background())
+	defer cancelExecutions()
+
 	channel := e.runnerService.GetChannel()
 
 	for {
+		// The requests are sorted once they are taken from the channel, up to the size of the channel,
+		// so the full queue still rejects the new requests. The executions waiting for their cluster count as well
+		intake := channel
+		if cap(channel) > 0 && e.queuedCount() >= cap(channel) {
+			intake = nil
+		}
+
 		select {
-		case execution := <-channel:
-			if err := sem.Acquire(ctx, 1); err != nil {
-				log.Debugf("Discarding execution: %d, shutting down already.", execution.ExecutionID)
-				break
+		case execution := <-intake:
+			e.enqueue(execution)
+		case <-e.released:
+		case <-ctx.Done():
+			e.runnerService.Drain()
+			e.discardPending(channel)
+
+			log.Infof(
+				"Execution worker pool is shutting down... Waiting up to %s for active workers to drain.", e.drainTimeout)
+			if e.wait(e.drainTimeout) {
+				return
 			}
 
-			go func() {
-				defer sem.Release(1)
-				e.runnerService.Execute(execution)
-			}()
-		case <-ctx.Done():
-			log.Infof("Projectors worker pool is shutting down... Waiting for active workers to drain.")
+			log.Warnf("Timed out while draining workers, cancelling the running executions")
+			cancelExecutions()
+			if !e.wait(cancelTimeout) {
+				log.Errorf("Timed out while waiting for the cancelled executions")
+			}
+
+			return
+		}
+
+		e.startPending(executionsCtx)
+	}
+}
+
+// enqueue adds the execution to the executions waiting for a free worker, after the ones with the same priority
+func (e *ExecutionWorkerPool) enqueue(execution *ExecutionEvent) {
+	e.clustersMu.Lock()
+	defer e.clustersMu.Unlock()
 
-			ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
-			defer cancel()
+	e.pending = insertByPriority(e.pending, execution, false)
+}
+
+// startPending runs the pending executions while there are free workers. The executions of a running
+// cluster wait for it instead, preempting the running execution if it is allowed
+func (e *ExecutionWorkerPool) startPending(ctx context.Context) {
+	for {
+		execution := e.nextPending()
+		if execution == nil {
+			return
+		}
+
+		if !e.lockCluster(execution) {
+			log.Infof(
+				"Execution %s is waiting for the running execution on cluster %s",
+				execution.ExecutionID.String(), execution.ClusterID.String())
+			e.preempt(execution)
+			continue
+		}
+
+		if !e.acquireWorker() {
+			e.unlockCluster(execution.ClusterID)
+			e.clustersMu.Lock()
+			e.pending = insertByPriority(e.pending, execution, true)
+			e.clustersMu.Unlock()
+			return
+		}
 
-			if err := sem.Acquire(ctx, workersNumber); err != nil {
-				log.Warnf("Timed out while draining workers: %v", err)
+		// The worker runs the waiting executions of the cluster as well, one after the other
+		go func() {
+			defer e.releaseWorker()
+			for next := execution; next != nil; next = e.nextClusterExecution(next.ClusterID) {
+				e.execute(ctx, next)
 			}
+		}()
+	}
+}
+
+// queuedCount returns the number of executions taken from the channel and not started yet
+func (e *ExecutionWorkerPool) queuedCount() int {
+	e.clustersMu.Lock()
+	defer e.clustersMu.Unlock()
+
+	queued := len(e.pending)
+	for _, waiting := range e.clusters {
+		queued += len(waiting)
+	}
+
+	return queued
+}
+
+func (e *ExecutionWorkerPool) nextPending() *ExecutionEvent {
+	e.clustersMu.Lock()
+	defer e.clustersMu.Unlock()
+
+	if len(e.pending) == 0 {
+		return nil
+	}
+
+	execution := e.pending[0]
+	e.pending = e.pending[1:]
+	return execution
+}
+
+// acquireWorker takes a free worker, if the workers limit is not reached. The workers are only taken
+// by the pool loop, so the limit is not exceeded
+func (e *ExecutionWorkerPool) acquireWorker() bool {
+	if atomic.LoadInt64(&e.busy) >= atomic.LoadInt64(&e.workersNumber) {
+		return false
+	}
+
+	atomic.AddInt64(&e.busy, 1)
+	e.workers.Add(1)
+	return true
+}
+
+func (e *ExecutionWorkerPool) releaseWorker() {
+	atomic.AddInt64(&e.busy, -1)
+	e.workers.Done()
+	e.wakeUp()
+}
+
+// wakeUp makes the pool start the pending executions, if there are free workers
+func (e *ExecutionWorkerPool) wakeUp() {
+	select {
+	case e.released <- struct{}{}:
+	default:
+	}
+}
+
+// preempt cancels the running execution of the cluster, if the waiting execution is a high priority one
+// and the running one is a low priority one
+func (e *ExecutionWorkerPool) preempt(execution *ExecutionEvent) {
+	if !e.preemption || execution.Priority != PriorityHigh {
+		return
+	}
+
+	e.clustersMu.Lock()
+	defer e.clustersMu.Unlock()
+
+	running, ok := e.active[execution.ClusterID]
+	if !ok || running.execution.Priority != PriorityLow {
+		return
+	}
+
+	log.Warnf(
+		"Execution %s on cluster %s is preempted by the execution %s",
+		running.execution.ExecutionID.String(), execution.ClusterID.String(), execution.ExecutionID.String())
+	running.preemption.preempt(execution)
+	running.cancel()
+}
+
+func (e *ExecutionWorkerPool) execute(ctx context.Context, execution *ExecutionEvent) {
+	atomic.AddInt32(&e.running, 1)
+	defer atomic.AddInt32(&e.running, -1)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	preemption := &executionPreemption{}
+	ctx = context.WithValue(ctx, preemptionKey{}, preemption)
+
+	e.clustersMu.Lock()
+	e.active[execution.ClusterID] = &activeExecution{execution: execution, cancel: cancel, preemption: preemption}
+	e.clustersMu.Unlock()
+
+	err := e.runnerService.Execute(ctx, execution)
+
+	e.clustersMu.Lock()
+	delete(e.active, execution.ClusterID)
+	e.clustersMu.Unlock()
+
+	if errors.Is(err, ErrExecutionPreempted) {
+		e.requeue(execution)
+		return
+	}
+	if err != nil {
+		log.Errorf(
+			"Execution %s on cluster %s failed: %s",
+			execution.ExecutionID.String(), execution.ClusterID.String(), err)
+		return
+	}
+	log.Infof(
+		"Execution %s on cluster %s finished", execution.ExecutionID.String(), execution.ClusterID.String())
+}
+
+// requeue queues the preempted execution again in its cluster, before the rest of executions with its priority,
+// so it runs right after the execution that preempted it. It is discarded if the runner is shutting down
+func (e *ExecutionWorkerPool) requeue(execution *ExecutionEvent) {
+	if e.runnerService.IsDraining() {
+		log.Warnf("Discarding preempted execution: %s, shutting down already.", execution.ExecutionID.String())
+		return
+	}
 
+	e.clustersMu.Lock()
+	defer e.clustersMu.Unlock()
+
+	log.Infof(
+		"Preempted execution %s is queued again on cluster %s", execution.ExecutionID.String(), execution.ClusterID.String())
+	e.clusters[execution.ClusterID] = insertByPriority(e.clusters[execution.ClusterID], execution, true)
+}
+
+// insertByPriority adds the execution to the queue sorted by priority, either before or after the executions
+// with the same priority
+func insertByPriority(queue []*ExecutionEvent, execution *ExecutionEvent, first bool) []*ExecutionEvent {
+	rank := execution.priorityRank()
+	position := len(queue)
+	for i, queued := range queue {
+		if queued.priorityRank() < rank || (first && queued.priorityRank() == rank) {
+			position = i
+			break
+		}
+	}
+
+	queue = append(queue, nil)
+	copy(queue[position+1:], queue[position:])
+	queue[position] = execution
+	return queue
+}
+
+// lockCluster marks the execution cluster as running, or queues the execution if the cluster is running already
+func (e *ExecutionWorkerPool) lockCluster(execution *ExecutionEvent) bool {
+	e.clustersMu.Lock()
+	defer e.clustersMu.Unlock()
+
+	if waiting, ok := e.clusters[execution.ClusterID]; ok {
+		e.clusters[execution.ClusterID] = insertByPriority(waiting, execution, false)
+		return false
+	}
+
+	e.clusters[execution.ClusterID] = []*ExecutionEvent{}
+	return true
+}
+
+func (e *ExecutionWorkerPool) unlockCluster(clusterID uuid.UUID) {
+	e.clustersMu.Lock()
+	defer e.clustersMu.Unlock()
+
+	delete(e.clusters, clusterID)
+}
+
+// nextClusterExecution returns the next execution waiting for the cluster, keeping it locked,
+// or unlocks the cluster if there are none
+func (e *ExecutionWorkerPool) nextClusterExecution(clusterID uuid.UUID) *ExecutionEvent {
+	e.clustersMu.Lock()
+	defer e.clustersMu.Unlock()
+
+	waiting := e.clusters[clusterID]
+	if len(waiting) == 0 {
+		delete(e.clusters, clusterID)
+		return nil
+	}
+
+	e.clusters[clusterID] = waiting[1:]
+	// The pool takes new requests again, if the full queue stopped it
+	e.wakeUp()
+	return waiting[0]
+}
+
+func (e *ExecutionWorkerPool) wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		e.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// discardPending empties the requests not started yet, as they will not be run
+func (e *ExecutionWorkerPool) discardPending(channel chan *ExecutionEvent) {
+	e.clustersMu.Lock()
+	for clusterID, waiting := range e.clusters {
+		for _, execution := range waiting {
+			log.Warnf("Discarding execution: %s, shutting down already.", execution.ExecutionID.String())
+		}
+		e.clusters[clusterID] = nil
+	}
+	for _, execution := range e.pending {
+		log.Warnf("Discarding execution: %s, shutting down already.", execution.ExecutionID.String())
+	}
+	e.pending = nil
+	e.clustersMu.Unlock()
+
+	for {
+		select {
+		case execution := <-channel:
+			log.Warnf("Discarding execution: %s, shutting down already.", execution.ExecutionID.String())
+		default:
 			return
 		}
 	}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	mock "github.com/stretchr/testify/mock"
@@ -26,12 +27,13 @@ func (suite *WorkerPoolTestCase) Test_Run() {
 	wg.Add(2)
 
 	mockRunnerService := new(MockRunnerService)
-	mockRunnerService.On("Execute", mock.Anything).Run(func(args mock.Arguments) {
+	mockRunnerService.On("Execute", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
 		wg.Done()
 	}).Return(nil)
 	mockRunnerService.On("GetChannel").Return(channel)
+	mockRunnerService.On("Drain").Return()
 
-	workerPool := NewExecutionWorkerPool(mockRunnerService)
+	workerPool := NewExecutionWorkerPool(mockRunnerService, 2, DefaultShutdownGracePeriod)
 
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -44,3 +46,388 @@ func (suite *WorkerPoolTestCase) Test_Run() {
 	mockRunnerService.AssertNumberOfCalls(suite.T(), "Execute", 2)
 	cancel()
 }
+
+func (suite *WorkerPoolTestCase) Test_Run_WorkersLimit() {
+	channel := make(chan *ExecutionEvent)
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	mockRunnerService := new(MockRunnerService)
+	mockRunnerService.On("Execute", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		started <- struct{}{}
+		<-release
+	}).Return(nil)
+	mockRunnerService.On("GetChannel").Return(channel)
+	mockRunnerService.On("Drain").Return()
+
+	workerPool := NewExecutionWorkerPool(mockRunnerService, 1, DefaultShutdownGracePeriod)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go workerPool.Run(ctx)
+	channel <- &ExecutionEvent{ExecutionID: uuid.New()}
+	<-started
+
+	// The second execution is accepted, but it waits for the running one to finish
+	channel <- &ExecutionEvent{ExecutionID: uuid.New()}
+	mockRunnerService.AssertNumberOfCalls(suite.T(), "Execute", 1)
+
+	release <- struct{}{}
+	<-started
+	mockRunnerService.AssertNumberOfCalls(suite.T(), "Execute", 2)
+	release <- struct{}{}
+}
+
+func (suite *WorkerPoolTestCase) Test_SetWorkersNumber() {
+	channel := make(chan *ExecutionEvent)
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	mockRunnerService := new(MockRunnerService)
+	mockRunnerService.On("Execute", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		started <- struct{}{}
+		<-release
+	}).Return(nil)
+	mockRunnerService.On("GetChannel").Return(channel)
+	mockRunnerService.On("Drain").Return()
+
+	workerPool := NewExecutionWorkerPool(mockRunnerService, 1, DefaultShutdownGracePeriod)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go workerPool.Run(ctx)
+	channel <- &ExecutionEvent{ExecutionID: uuid.New(), ClusterID: uuid.New()}
+	<-started
+	channel <- &ExecutionEvent{ExecutionID: uuid.New(), ClusterID: uuid.New()}
+	mockRunnerService.AssertNumberOfCalls(suite.T(), "Execute", 1)
+
+	// The waiting execution starts once there is a new worker, while the first one still runs
+	workerPool.SetWorkersNumber(2)
+	<-started
+	mockRunnerService.AssertNumberOfCalls(suite.T(), "Execute", 2)
+	suite.Equal(2, workerPool.Running())
+
+	release <- struct{}{}
+	release <- struct{}{}
+}
+
+func (suite *WorkerPoolTestCase) Test_Run_Drain() {
+	channel := make(chan *ExecutionEvent, 2)
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	mockRunnerService := new(MockRunnerService)
+	mockRunnerService.On("Execute", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		started <- struct{}{}
+		<-release
+	}).Return(nil)
+	mockRunnerService.On("GetChannel").Return(channel)
+	mockRunnerService.On("Drain").Return()
+
+	workerPool := NewExecutionWorkerPool(mockRunnerService, 1, DefaultShutdownGracePeriod)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		workerPool.Run(ctx)
+		close(stopped)
+	}()
+
+	channel <- &ExecutionEvent{ExecutionID: uuid.New()}
+	<-started
+
+	cancel()
+
+	// The running execution is waited for
+	select {
+	case <-stopped:
+		suite.Fail("The worker pool did not wait for the running execution")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	release <- struct{}{}
+	<-stopped
+
+	mockRunnerService.AssertCalled(suite.T(), "Drain")
+	mockRunnerService.AssertNumberOfCalls(suite.T(), "Execute", 1)
+}
+
+func (suite *WorkerPoolTestCase) Test_Run_DrainTimeout() {
+	channel := make(chan *ExecutionEvent)
+	started := make(chan struct{})
+
+	mockRunnerService := new(MockRunnerService)
+	mockRunnerService.On("Execute", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		started <- struct{}{}
+		// The execution only finishes when it is cancelled
+		<-args.Get(0).(context.Context).Done()
+	}).Return(context.Canceled)
+	mockRunnerService.On("GetChannel").Return(channel)
+	mockRunnerService.On("Drain").Return()
+
+	workerPool := NewExecutionWorkerPool(mockRunnerService, 1, 10*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		workerPool.Run(ctx)
+		close(stopped)
+	}()
+
+	channel <- &ExecutionEvent{ExecutionID: uuid.New()}
+	<-started
+	cancel()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		suite.Fail("The running execution was not cancelled")
+	}
+	suite.False(workerPool.IsAlive())
+}
+
+func (suite *WorkerPoolTestCase) Test_Run_ClusterExclusion() {
+	channel := make(chan *ExecutionEvent)
+	started := make(chan *ExecutionEvent)
+
+	clusterID := uuid.New()
+	first := &ExecutionEvent{ExecutionID: uuid.New(), ClusterID: clusterID}
+	second := &ExecutionEvent{ExecutionID: uuid.New(), ClusterID: clusterID}
+	other := &ExecutionEvent{ExecutionID: uuid.New(), ClusterID: uuid.New()}
+	releases := map[uuid.UUID]chan struct{}{
+		first.ExecutionID:  make(chan struct{}),
+		second.ExecutionID: make(chan struct{}),
+		other.ExecutionID:  make(chan struct{}),
+	}
+
+	mockRunnerService := new(MockRunnerService)
+	mockRunnerService.On("Execute", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		execution := args.Get(1).(*ExecutionEvent)
+		started <- execution
+		<-releases[execution.ExecutionID]
+	}).Return(nil)
+	mockRunnerService.On("GetChannel").Return(channel)
+	mockRunnerService.On("Drain").Return()
+
+	workerPool := NewExecutionWorkerPool(mockRunnerService, 3, DefaultShutdownGracePeriod)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go workerPool.Run(ctx)
+	channel <- first
+	suite.Equal(first, <-started)
+
+	// The second execution waits for the first one on the same cluster, even with free workers
+	channel <- second
+	channel <- other
+	suite.Equal(other, <-started)
+	suite.Equal(1, workerPool.QueueDepth())
+	suite.Equal(2, workerPool.Running())
+
+	close(releases[first.ExecutionID])
+	suite.Equal(second, <-started)
+	suite.Equal(0, workerPool.QueueDepth())
+
+	close(releases[second.ExecutionID])
+	close(releases[other.ExecutionID])
+	suite.Eventually(func() bool { return workerPool.Running() == 0 }, time.Second, time.Millisecond)
+	mockRunnerService.AssertNumberOfCalls(suite.T(), "Execute", 3)
+}
+
+func (suite *WorkerPoolTestCase) Test_Run_ClusterWaitingQueueCapacity() {
+	channel := make(chan *ExecutionEvent, 2)
+	started := make(chan *ExecutionEvent, 5)
+	release := make(chan struct{})
+
+	clusterID := uuid.New()
+	running := &ExecutionEvent{ExecutionID: uuid.New(), ClusterID: clusterID}
+
+	mockRunnerService := new(MockRunnerService)
+	mockRunnerService.On("Execute", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		started <- args.Get(1).(*ExecutionEvent)
+		<-release
+	}).Return(nil)
+	mockRunnerService.On("GetChannel").Return(channel)
+	mockRunnerService.On("Drain").Return()
+
+	workerPool := NewExecutionWorkerPool(mockRunnerService, 3, DefaultShutdownGracePeriod)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go workerPool.Run(ctx)
+	channel <- running
+	suite.Equal(running, <-started)
+
+	// The executions waiting for the cluster fill the queue, so the channel is full afterwards
+	for i := 0; i < 2; i++ {
+		channel <- &ExecutionEvent{ExecutionID: uuid.New(), ClusterID: clusterID}
+	}
+	suite.Eventually(func() bool { return len(channel) == 0 }, time.Second, time.Millisecond)
+	for i := 0; i < 2; i++ {
+		channel <- &ExecutionEvent{ExecutionID: uuid.New(), ClusterID: clusterID}
+	}
+	select {
+	case channel <- &ExecutionEvent{ExecutionID: uuid.New(), ClusterID: clusterID}:
+		suite.Fail("the queue is not full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	suite.Equal(4, workerPool.QueueDepth())
+
+	// The waiting executions run one after the other, and the channel is emptied as they start
+	close(release)
+	for i := 0; i < 4; i++ {
+		<-started
+	}
+	suite.Eventually(func() bool { return workerPool.Running() == 0 }, time.Second, time.Millisecond)
+	mockRunnerService.AssertNumberOfCalls(suite.T(), "Execute", 5)
+}
+
+func (suite *WorkerPoolTestCase) Test_Run_Priority() {
+	channel := make(chan *ExecutionEvent)
+	started := make(chan *ExecutionEvent)
+	release := make(chan struct{})
+
+	running := &ExecutionEvent{ExecutionID: uuid.New(), ClusterID: uuid.New()}
+	low := &ExecutionEvent{ExecutionID: uuid.New(), ClusterID: uuid.New(), Priority: PriorityLow}
+	normal := &ExecutionEvent{ExecutionID: uuid.New(), ClusterID: uuid.New()}
+	high := &ExecutionEvent{ExecutionID: uuid.New(), ClusterID: uuid.New(), Priority: PriorityHigh}
+
+	mockRunnerService := new(MockRunnerService)
+	mockRunnerService.On("Execute", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		started <- args.Get(1).(*ExecutionEvent)
+		<-release
+	}).Return(nil)
+	mockRunnerService.On("GetChannel").Return(channel)
+	mockRunnerService.On("Drain").Return()
+
+	workerPool := NewExecutionWorkerPool(mockRunnerService, 1, DefaultShutdownGracePeriod)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go workerPool.Run(ctx)
+	channel <- running
+	suite.Equal(running, <-started)
+
+	// The waiting executions are run by priority, regardless of the order they were requested
+	channel <- low
+	channel <- normal
+	channel <- high
+	suite.Eventually(func() bool { return workerPool.QueueDepth() == 3 }, time.Second, time.Millisecond)
+
+	release <- struct{}{}
+	suite.Equal(high, <-started)
+	release <- struct{}{}
+	suite.Equal(normal, <-started)
+	release <- struct{}{}
+	suite.Equal(low, <-started)
+	release <- struct{}{}
+}
+
+func (suite *WorkerPoolTestCase) Test_Run_Preemption() {
+	channel := make(chan *ExecutionEvent)
+	started := make(chan *ExecutionEvent)
+	release := make(chan struct{})
+
+	clusterID := uuid.New()
+	scheduled := &ExecutionEvent{ExecutionID: uuid.New(), ClusterID: clusterID, Priority: PriorityLow}
+	requested := &ExecutionEvent{ExecutionID: uuid.New(), ClusterID: clusterID, Priority: PriorityHigh}
+
+	mockRunnerService := new(MockRunnerService)
+	mockRunnerService.On("Execute", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		started <- args.Get(1).(*ExecutionEvent)
+	}).Return(func(ctx context.Context, e *ExecutionEvent) error {
+		select {
+		case <-release:
+			return nil
+		case <-ctx.Done():
+			suite.Equal(requested, preemptedBy(ctx))
+			return ErrExecutionPreempted
+		}
+	})
+	mockRunnerService.On("GetChannel").Return(channel)
+	mockRunnerService.On("Drain").Return()
+	mockRunnerService.On("IsDraining").Return(false)
+
+	workerPool := NewExecutionWorkerPool(mockRunnerService, 2, DefaultShutdownGracePeriod)
+	workerPool.EnablePreemption()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go workerPool.Run(ctx)
+	channel <- scheduled
+	suite.Equal(scheduled, <-started)
+
+	// The scheduled execution is cancelled, and run again once the requested one finishes
+	channel <- requested
+	suite.Equal(requested, <-started)
+	suite.Equal(1, workerPool.QueueDepth())
+
+	release <- struct{}{}
+	suite.Equal(scheduled, <-started)
+	release <- struct{}{}
+
+	suite.Eventually(func() bool { return workerPool.Running() == 0 }, time.Second, time.Millisecond)
+	mockRunnerService.AssertNumberOfCalls(suite.T(), "Execute", 3)
+}
+
+func (suite *WorkerPoolTestCase) Test_Run_PreemptionDisabled() {
+	channel := make(chan *ExecutionEvent)
+	started := make(chan *ExecutionEvent)
+	release := make(chan struct{})
+
+	clusterID := uuid.New()
+	scheduled := &ExecutionEvent{ExecutionID: uuid.New(), ClusterID: clusterID, Priority: PriorityLow}
+	requested := &ExecutionEvent{ExecutionID: uuid.New(), ClusterID: clusterID, Priority: PriorityHigh}
+
+	mockRunnerService := new(MockRunnerService)
+	mockRunnerService.On("Execute", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		started <- args.Get(1).(*ExecutionEvent)
+		<-release
+	}).Return(nil)
+	mockRunnerService.On("GetChannel").Return(channel)
+	mockRunnerService.On("Drain").Return()
+
+	workerPool := NewExecutionWorkerPool(mockRunnerService, 2, DefaultShutdownGracePeriod)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go workerPool.Run(ctx)
+	channel <- scheduled
+	suite.Equal(scheduled, <-started)
+
+	// The requested execution waits for the scheduled one to finish
+	channel <- requested
+	suite.Eventually(func() bool { return workerPool.QueueDepth() == 1 }, time.Second, time.Millisecond)
+
+	release <- struct{}{}
+	suite.Equal(requested, <-started)
+	release <- struct{}{}
+
+	suite.Eventually(func() bool { return workerPool.Running() == 0 }, time.Second, time.Millisecond)
+	mockRunnerService.AssertNumberOfCalls(suite.T(), "Execute", 2)
+}
+
+func (suite *WorkerPoolTestCase) Test_InsertByPriority() {
+	low := &ExecutionEvent{ExecutionID: uuid.New(), Priority: PriorityLow}
+	normal := &ExecutionEvent{ExecutionID: uuid.New()}
+	otherNormal := &ExecutionEvent{ExecutionID: uuid.New(), Priority: PriorityNormal}
+	high := &ExecutionEvent{ExecutionID: uuid.New(), Priority: PriorityHigh}
+
+	queue := insertByPriority([]*ExecutionEvent{}, low, false)
+	queue = insertByPriority(queue, normal, false)
+	queue = insertByPriority(queue, high, false)
+	queue = insertByPriority(queue, otherNormal, false)
+	suite.Equal([]*ExecutionEvent{high, normal, otherNormal, low}, queue)
+
+	// A preempted execution goes before the rest of executions with its priority
+	preempted := &ExecutionEvent{ExecutionID: uuid.New(), Priority: PriorityNormal}
+	queue = insertByPriority(queue, preempted, true)
+	suite.Equal([]*ExecutionEvent{high, preempted, normal, otherNormal, low}, queue)
+}
@@ -1,4 +1,40 @@
 package version
 
+import (
+	"fmt"
+	"runtime"
+)
+
+// These values are set at build time
 var Version string
 var Flavor string
+var GitSha string
+var BuildDate string
+
+// Info identifies the runner build, so the reported checks behavior can be correlated with it
+type Info struct {
+	Version   string `json:"version"`
+	Flavor    string `json:"flavor"`
+	GitSha    string `json:"git_sha"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+func GetInfo() *Info {
+	return &Info{
+		Version:   Version,
+		Flavor:    Flavor,
+		GitSha:    GitSha,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+		Platform:  fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH),
+	}
+}
+
+// String is the human readable build information, printed by the version command
+func (i *Info) String() string {
+	return fmt.Sprintf(
+		"Trento Runner %s version %s\ngit commit %s, built on %s\nbuilt with %s %s\n",
+		i.Flavor, i.Version, i.GitSha, i.BuildDate, i.GoVersion, i.Platform)
+}